@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"sql-horizontal-autoscaler/client"
+	"sql-horizontal-autoscaler/config"
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// command is a subcommand of the binary
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) error
+}
+
+// commands returns every subcommand, in the order listed by the usage text
+func commands() []command {
+	return []command{
+		{"serve", "serve [--config file] [--profile name] [--role all|router|coordinator]", "Run the query router and coordinator (default)", serve},
+		{"router", "router [--config file] [--profile name]", "Run only the query router, following the coordinator's topology", serveRole(roleRouter)},
+		{"coordinator", "coordinator [--config file] [--profile name]", "Run only the coordinator", serveRole(roleCoordinator)},
+		{"topology", "topology show", "Show shards, their status and load, and routing overrides", runTopology},
+		{"scale", "scale out [--zone name] | scale in <shard> --into <shard> [--dry-run]", "Add a shard, or merge a shard into another", runScale},
+		{"drain", "drain <shard> [--duration 1h] [--reason text] [--undo]", "Take a shard out of routing for maintenance", runDrain},
+		{"cleanup", "cleanup [--config file] [--profile name] [--keep-volumes] [--yes]", "Remove the Docker containers, volumes and networks the autoscaler created", runCleanup},
+		{"query", "query [--consistency level] [--allow-dangerous] [--async] [--json] \"<sql>\"", "Run a statement through the query router", runQuery},
+	}
+}
+
+// findCommand looks up a subcommand by name
+func findCommand(name string) (command, bool) {
+	if name == "help" {
+		return command{name: "help", run: func([]string) error { printUsage(); return nil }}, true
+	}
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// printUsage lists the subcommands
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	tw := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands() {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.usage, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(os.Stderr, "\nCommands talking to a running cluster accept --router, --coordinator, --api-key and --timeout.")
+}
+
+// apiFlags registers the flags locating the running services, returning a
+// constructor for a client using them
+func apiFlags(flags *flag.FlagSet) func() *client.Client {
+	routerURL := flags.String("router", envOr("AUTOSCALER_ROUTER_URL", "http://localhost:8080"), "Query router base URL")
+	coordinatorURL := flags.String("coordinator", envOr("AUTOSCALER_COORDINATOR_URL", "http://localhost:9090"), "Coordinator base URL")
+	apiKey := flags.String("api-key", os.Getenv("AUTOSCALER_API_KEY"), "API key sent in the X-API-Key header")
+	timeout := flags.Duration("timeout", 30*time.Second, "Timeout of each request")
+
+	return func() *client.Client {
+		return client.New(client.Config{
+			RouterURL:      *routerURL,
+			CoordinatorURL: *coordinatorURL,
+			APIKey:         *apiKey,
+			Timeout:        *timeout,
+		})
+	}
+}
+
+// envOr returns an environment variable, or a default when it is unset
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// parseInterspersed parses flags that may follow positional arguments, as in
+// "drain shard-2 --duration 1h", returning the positional arguments
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// runTopology implements "topology show"
+func runTopology(args []string) error {
+	flags := flag.NewFlagSet("topology", flag.ExitOnError)
+	newClient := apiFlags(flags)
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 || positional[0] != "show" {
+		return fmt.Errorf("usage: topology show")
+	}
+
+	c := newClient()
+	defer c.Close()
+	ctx := context.Background()
+
+	topology, err := c.Topology(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get topology: %w", err)
+	}
+	shards, err := c.Shards(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to get shard metrics: %w", err)
+	}
+	metricsByShard := make(map[string]client.ShardMetrics, len(shards))
+	for _, shard := range shards {
+		metricsByShard[shard.ShardID] = shard
+	}
+
+	shardIDs := make([]string, 0, len(topology.Shards))
+	for shardID := range topology.Shards {
+		shardIDs = append(shardIDs, shardID)
+	}
+	sort.Strings(shardIDs)
+
+	fmt.Printf("Topology version %s\n\n", topology.Version)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SHARD\tSTATUS\tCPU\tMEMORY\tENTRIES\tCONNECTIONS\tQPS")
+	for _, shardID := range shardIDs {
+		shard, monitored := metricsByShard[shardID]
+		if !monitored {
+			fmt.Fprintf(tw, "%s\t%s\t-\t-\t-\t-\t-\n", shardID, topology.Shards[shardID])
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1f%%\t%.1f%%\t%d\t%d\t%.1f\n", shardID, topology.Shards[shardID],
+			shard.CPUPercent, shard.MemoryPercent, shard.TotalEntries, shard.ConnectionCount, shard.QueriesPerSec)
+	}
+	tw.Flush()
+
+	if len(topology.Directory) > 0 {
+		fmt.Printf("\n%d routing overrides\n", len(topology.Directory))
+		printJSON(topology.Directory)
+	}
+	if len(topology.TimeRanges) > 0 {
+		fmt.Printf("\n%d time ranges\n", len(topology.TimeRanges))
+		printJSON(topology.TimeRanges)
+	}
+	return nil
+}
+
+// runScale implements "scale out" and "scale in"
+func runScale(args []string) error {
+	flags := flag.NewFlagSet("scale", flag.ExitOnError)
+	newClient := apiFlags(flags)
+	zone := flags.String("zone", "", "Zone to place the new shard in (scale out)")
+	into := flags.String("into", "", "Shard receiving the merged shard's rows (scale in)")
+	dryRun := flags.Bool("dry-run", false, "Report what the merge would move without merging (scale in)")
+	positional := parseInterspersed(flags, args)
+	if len(positional) == 0 {
+		return fmt.Errorf("usage: scale out [--zone name] | scale in <shard> --into <shard> [--dry-run]")
+	}
+
+	c := newClient()
+	defer c.Close()
+	ctx := context.Background()
+
+	switch positional[0] {
+	case "out":
+		if len(positional) != 1 {
+			return fmt.Errorf("usage: scale out [--zone name]")
+		}
+		if err := c.ScaleOut(ctx, *zone); err != nil {
+			return fmt.Errorf("failed to scale out: %w", err)
+		}
+		fmt.Println("Scale-out started; the new shard appears in \"topology show\" once it is active")
+		return nil
+
+	case "in":
+		if len(positional) == 2 && *dryRun {
+			plan, err := c.DecommissionPlan(ctx, positional[1], *into)
+			if err != nil {
+				return fmt.Errorf("failed to plan decommissioning %s: %w", positional[1], err)
+			}
+			printJSON(plan)
+			return nil
+		}
+		if len(positional) != 2 || *into == "" {
+			return fmt.Errorf("usage: scale in <shard> --into <shard> [--dry-run]")
+		}
+		result, err := c.MergeShard(ctx, positional[1], *into)
+		if err != nil {
+			return fmt.Errorf("failed to merge %s into %s: %w", positional[1], *into, err)
+		}
+		fmt.Printf("Merged %s into %s in %s\n", result.Source, result.Destination, result.Duration)
+		printJSON(result.RowsCopied)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown scale direction %q, expected out or in", positional[0])
+	}
+}
+
+// runDrain implements "drain", declaring an excluded maintenance window so
+// the router stops sending the shard traffic and its idle connections close
+func runDrain(args []string) error {
+	flags := flag.NewFlagSet("drain", flag.ExitOnError)
+	newClient := apiFlags(flags)
+	duration := flags.Duration("duration", time.Hour, "How long the shard stays drained")
+	reason := flags.String("reason", "drained by operator", "Reason recorded with the maintenance window")
+	undo := flags.Bool("undo", false, "Return a drained shard to routing")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: drain <shard> [--duration 1h] [--reason text] [--undo]")
+	}
+	shardID := positional[0]
+
+	c := newClient()
+	defer c.Close()
+	ctx := context.Background()
+
+	if *undo {
+		if err := c.ClearMaintenance(ctx, shardID); err != nil {
+			return fmt.Errorf("failed to undrain %s: %w", shardID, err)
+		}
+		fmt.Printf("Shard %s is back in routing\n", shardID)
+		return nil
+	}
+
+	window, err := c.SetMaintenance(ctx, shardID, client.MaintenanceRequest{
+		DurationSeconds: int(duration.Seconds()),
+		Mode:            "excluded",
+		Reason:          *reason,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to drain %s: %w", shardID, err)
+	}
+	fmt.Printf("Shard %s is drained until %s\n", shardID, window.End.Format(time.RFC3339))
+	return nil
+}
+
+// runQuery implements "query", printing the rows as a table
+func runQuery(args []string) error {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	newClient := apiFlags(flags)
+	consistency := flags.String("consistency", "", "Read consistency: strong, eventual or bounded_staleness(N)")
+	allowDangerous := flags.Bool("allow-dangerous", false, "Confirm a statement affecting every row of a table")
+	async := flags.Bool("async", false, "Run a long read as a background job, polling until it finishes")
+	asJSON := flags.Bool("json", false, "Print the full response as JSON")
+	positional := parseInterspersed(flags, args)
+	if len(positional) == 0 {
+		return fmt.Errorf("usage: query \"<sql>\"")
+	}
+
+	c := newClient()
+	defer c.Close()
+
+	req := client.QueryRequest{
+		Query:          strings.Join(positional, " "),
+		Consistency:    *consistency,
+		AllowDangerous: *allowDangerous,
+	}
+	if *async {
+		return runAsyncQuery(c, req, *asJSON)
+	}
+
+	resp, err := c.Query(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		printJSON(resp)
+		return nil
+	}
+
+	if resp.RowsAffected != nil {
+		fmt.Printf("%d rows affected\n", *resp.RowsAffected)
+		return nil
+	}
+	printRows(resp.Data)
+	if resp.Truncated {
+		fmt.Println("(truncated at the router's result limit)")
+	}
+	return nil
+}
+
+// runAsyncQuery runs a read as a job, waiting for it and fetching every page
+// of its rows. Interrupting the command leaves the job running; it can be
+// fetched or cancelled through /jobs/{id}.
+func runAsyncQuery(c *client.Client, req client.QueryRequest, asJSON bool) error {
+	ctx := context.Background()
+	job, err := c.SubmitQuery(ctx, req)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Job %s started\n", job.JobID)
+
+	job, err = c.WaitJob(ctx, job.JobID, time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to wait for job: %w", err)
+	}
+	if job.Status != client.JobCompleted {
+		if job.Error != nil {
+			return job.Error
+		}
+		return fmt.Errorf("job %s was %s", job.JobID, job.Status)
+	}
+
+	rows := job.Data
+	for job.NextOffset != nil {
+		job, err = c.GetJob(ctx, job.JobID, *job.NextOffset, 0)
+		if err != nil {
+			return fmt.Errorf("failed to fetch job results: %w", err)
+		}
+		rows = append(rows, job.Data...)
+	}
+	if asJSON {
+		printJSON(rows)
+		return nil
+	}
+	printRows(rows)
+	if job.Truncated {
+		fmt.Println("(truncated at the router's result limit)")
+	}
+	return nil
+}
+
+// runCleanup implements "cleanup", listing the Docker resources owned by the
+// cluster of a configuration and removing them once confirmed with --yes
+func runCleanup(args []string) error {
+	flags := flag.NewFlagSet("cleanup", flag.ExitOnError)
+	configFile := flags.String("config", "config.json", "Path to configuration file")
+	profile := flags.String("profile", os.Getenv("AUTOSCALER_PROFILE"), "Environment overlay merged into the configuration file")
+	keepVolumes := flags.Bool("keep-volumes", false, "Leave the shards' data volumes in place")
+	yes := flags.Bool("yes", false, "Remove the resources instead of listing them")
+	flags.Parse(args)
+
+	cfg, err := config.LoadConfigProfile(*configFile, *profile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	shardManager := sharding.NewDynamicShardManager(cfg.Shards, newShardManagerConfig(cfg))
+
+	resources, err := shardManager.Cleanup(!*yes, *keepVolumes)
+	if len(resources) > 0 {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "KIND\tNAME\tHOST")
+		for _, resource := range resources {
+			host := resource.Host
+			if host == "" {
+				host = "local"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", resource.Kind, resource.Name, host)
+		}
+		tw.Flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(resources) == 0:
+		fmt.Printf("No Docker resources labelled %s=%s\n", sharding.OwnerLabel, cfg.Docker.ContainerPrefix)
+	case *yes:
+		fmt.Printf("Removed %d resources\n", len(resources))
+	default:
+		fmt.Printf("%d resources would be removed; run again with --yes to remove them\n", len(resources))
+	}
+	return nil
+}
+
+// printRows prints result rows as a table with columns in name order, as
+// rows are returned as JSON objects
+func printRows(rows []map[string]interface{}) {
+	columnSet := make(map[string]bool)
+	for _, row := range rows {
+		for column := range row {
+			columnSet[column] = true
+		}
+	}
+	columns := make([]string, 0, len(columnSet))
+	for column := range columnSet {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(columns, "\t"))
+	for _, row := range rows {
+		values := make([]string, len(columns))
+		for i, column := range columns {
+			if value, exists := row[column]; exists && value != nil {
+				values[i] = fmt.Sprint(value)
+			} else {
+				values[i] = "NULL"
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	tw.Flush()
+	fmt.Printf("%d rows\n", len(rows))
+}
+
+// printJSON prints a value as indented JSON
+func printJSON(v interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
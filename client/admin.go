@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ActiveQueries lists statements running on shards, keyed by shard
+type ActiveQueries struct {
+	Queries map[string][]ActiveQuery `json:"queries"`
+	// Errors holds shards whose process list could not be read
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// MaintenanceRequest declares a maintenance window. Start defaults to now;
+// End may instead be given as a duration.
+type MaintenanceRequest struct {
+	Start           *time.Time `json:"start,omitempty"`
+	End             *time.Time `json:"end,omitempty"`
+	DurationSeconds int        `json:"duration_seconds,omitempty"`
+	Mode            string     `json:"mode"`
+	Reason          string     `json:"reason,omitempty"`
+}
+
+// Shards lists per-shard metrics, optionally restricted by a label selector
+// such as "region=us-east,tier!=cold"
+func (c *Client) Shards(ctx context.Context, selector string) ([]ShardMetrics, error) {
+	path := "/shards"
+	if selector != "" {
+		path += "?selector=" + url.QueryEscape(selector)
+	}
+	var shards []ShardMetrics
+	if err := c.do(ctx, c.config.CoordinatorURL, http.MethodGet, path, nil, &shards); err != nil {
+		return nil, err
+	}
+	return shards, nil
+}
+
+// Topology returns the coordinator's current routing view
+func (c *Client) Topology(ctx context.Context) (*Topology, error) {
+	var topology Topology
+	if err := c.do(ctx, c.config.CoordinatorURL, http.MethodGet, "/topology", nil, &topology); err != nil {
+		return nil, err
+	}
+	return &topology, nil
+}
+
+// RingLayout returns the coordinator's ring token ownership, which can be
+// saved for ring.layout_path
+func (c *Client) RingLayout(ctx context.Context) (*RingLayout, error) {
+	var layout RingLayout
+	if err := c.do(ctx, c.config.CoordinatorURL, http.MethodGet, "/topology/ring", nil, &layout); err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+// Directory returns the routing overrides
+func (c *Client) Directory(ctx context.Context) ([]DirectoryEntry, error) {
+	var entries []DirectoryEntry
+	if err := c.do(ctx, c.config.CoordinatorURL, http.MethodGet, "/directory", nil, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// SetDirectoryEntry installs or replaces a routing override without moving
+// any rows
+func (c *Client) SetDirectoryEntry(ctx context.Context, entry DirectoryEntry) error {
+	return c.do(ctx, c.config.CoordinatorURL, http.MethodPut, "/directory", entry, nil)
+}
+
+// DeleteDirectoryEntry removes the routing override for the entry's key or range
+func (c *Client) DeleteDirectoryEntry(ctx context.Context, entry DirectoryEntry) error {
+	return c.do(ctx, c.config.CoordinatorURL, http.MethodDelete, "/directory", entry, nil)
+}
+
+// MoveKey moves a key or key range of a table to another shard
+func (c *Client) MoveKey(ctx context.Context, req MoveKeyRequest) (*MoveResult, error) {
+	var result MoveResult
+	if err := c.do(ctx, c.config.CoordinatorURL, http.MethodPost, "/admin/move-key", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// MergeShard merges a shard into another and decommissions it
+func (c *Client) MergeShard(ctx context.Context, shardID, into string) (*MergeResult, error) {
+	var result MergeResult
+	body := map[string]string{"into": into}
+	if err := c.do(ctx, c.config.CoordinatorURL, http.MethodPost, "/shards/"+url.PathEscape(shardID)+"/merge", body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DecommissionPlan reports what merging a shard away would move, without
+// changing anything. An empty into picks the least loaded active shard.
+func (c *Client) DecommissionPlan(ctx context.Context, shardID, into string) (*DecommissionPlan, error) {
+	path := "/shards/" + url.PathEscape(shardID) + "/decommission-plan"
+	if into != "" {
+		path += "?into=" + url.QueryEscape(into)
+	}
+	var plan DecommissionPlan
+	if err := c.do(ctx, c.config.CoordinatorURL, http.MethodGet, path, nil, &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// SetMaintenance declares a maintenance window for a shard, or for the whole
+// cluster with the shard ID "cluster"
+func (c *Client) SetMaintenance(ctx context.Context, shardID string, req MaintenanceRequest) (*MaintenanceWindow, error) {
+	var window MaintenanceWindow
+	if err := c.do(ctx, c.config.CoordinatorURL, http.MethodPut, "/shards/"+url.PathEscape(shardID)+"/maintenance", req, &window); err != nil {
+		return nil, err
+	}
+	return &window, nil
+}
+
+// ClearMaintenance ends a shard's maintenance window
+func (c *Client) ClearMaintenance(ctx context.Context, shardID string) error {
+	return c.do(ctx, c.config.CoordinatorURL, http.MethodDelete, "/shards/"+url.PathEscape(shardID)+"/maintenance", nil, nil)
+}
+
+// ActiveQueries lists running statements on all shards, or on one shard
+// when shardID is set
+func (c *Client) ActiveQueries(ctx context.Context, shardID string) (*ActiveQueries, error) {
+	path := "/admin/queries"
+	if shardID != "" {
+		path += "?shard=" + url.QueryEscape(shardID)
+	}
+	var result ActiveQueries
+	if err := c.do(ctx, c.config.CoordinatorURL, http.MethodGet, path, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// KillQuery kills a running statement on a shard
+func (c *Client) KillQuery(ctx context.Context, shardID string, processID int64) error {
+	path := fmt.Sprintf("/admin/queries/%s:%d", url.PathEscape(shardID), processID)
+	return c.do(ctx, c.config.CoordinatorURL, http.MethodDelete, path, nil, nil)
+}
+
+// Divergences lists broadcast table copies that missed a write
+func (c *Client) Divergences(ctx context.Context) ([]Divergence, error) {
+	var result struct {
+		Divergences []Divergence `json:"divergences"`
+	}
+	if err := c.do(ctx, c.config.CoordinatorURL, http.MethodGet, "/broadcast/divergences", nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Divergences, nil
+}
+
+// RepairBroadcast re-syncs a broadcast table on one shard, or on every
+// diverged shard when shardID is empty
+func (c *Client) RepairBroadcast(ctx context.Context, table, shardID string) ([]RepairResult, error) {
+	var result struct {
+		Repaired []RepairResult `json:"repaired"`
+	}
+	body := map[string]string{"table": table, "shard": shardID}
+	if err := c.do(ctx, c.config.CoordinatorURL, http.MethodPost, "/broadcast/repair", body, &result); err != nil {
+		return nil, err
+	}
+	return result.Repaired, nil
+}
+
+// ScaleOut asks the coordinator to add a shard, in the given zone if set. The
+// shard is provisioned in the background and appears in Topology once active.
+func (c *Client) ScaleOut(ctx context.Context, zone string) error {
+	return c.do(ctx, c.config.CoordinatorURL, http.MethodPost, "/scale/out", map[string]string{"zone": zone}, nil)
+}
@@ -0,0 +1,274 @@
+// Package client is a Go client for the query router and coordinator HTTP
+// APIs. It reuses pooled connections, retries requests that fail with
+// retryable errors, and decodes responses into typed structs.
+//
+// The router has no interactive transaction API: each query runs on its own
+// and a batch is executed statement by statement. Use a single multi-shard
+// write, which the router commits with two-phase commit, when atomicity
+// across shards is required.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Config configures a Client
+type Config struct {
+	// RouterURL is the base URL of the query router, e.g. http://localhost:8080
+	RouterURL string
+	// CoordinatorURL is the base URL of the coordinator, e.g. http://localhost:9090
+	CoordinatorURL string
+	// APIKey is sent in the X-API-Key header when set
+	APIKey string
+	// BearerToken is sent in the Authorization header when set
+	BearerToken string
+	// Timeout bounds each HTTP attempt (default 30s)
+	Timeout time.Duration
+	// MaxRetries is the number of retries after the first attempt (default 3)
+	MaxRetries int
+	// RetryBackoff is the initial delay between retries, doubled on each
+	// retry (default 100ms)
+	RetryBackoff time.Duration
+	// MaxIdleConnsPerHost sizes the connection pool per service (default 16)
+	MaxIdleConnsPerHost int
+	// RetryAmbiguous also retries errors after which a write may already
+	// have been applied, such as timeouts and dropped connections. Only
+	// enable it when the statements sent are idempotent.
+	RetryAmbiguous bool
+}
+
+// Client talks to the router and coordinator APIs
+type Client struct {
+	config     Config
+	httpClient *http.Client
+}
+
+// New creates a Client, filling in defaults for unset options
+func New(cfg Config) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	} else if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 100 * time.Millisecond
+	}
+	if cfg.MaxIdleConnsPerHost <= 0 {
+		cfg.MaxIdleConnsPerHost = 16
+	}
+	cfg.RouterURL = strings.TrimRight(cfg.RouterURL, "/")
+	cfg.CoordinatorURL = strings.TrimRight(cfg.CoordinatorURL, "/")
+
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConns:        cfg.MaxIdleConnsPerHost * 2,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	return &Client{
+		config:     cfg,
+		httpClient: &http.Client{Transport: transport, Timeout: cfg.Timeout},
+	}
+}
+
+// Close releases idle pooled connections
+func (c *Client) Close() {
+	c.httpClient.CloseIdleConnections()
+}
+
+// Query runs a single statement through the router. Errors returned by the
+// router are returned as *Error.
+func (c *Client) Query(ctx context.Context, req QueryRequest) (*QueryResponse, error) {
+	var resp QueryResponse
+	if err := c.do(ctx, c.config.RouterURL, http.MethodPost, "/query", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Batch runs several statements through the router in one request. Failures
+// of individual statements are reported in their result, not as an error.
+func (c *Client) Batch(ctx context.Context, req BatchRequest) (*BatchResponse, error) {
+	var resp BatchResponse
+	if err := c.do(ctx, c.config.RouterURL, http.MethodPost, "/batch", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ExecuteTemplate runs a named query template registered on the router,
+// filling in its parameters from args
+func (c *Client) ExecuteTemplate(ctx context.Context, name string, args map[string]interface{}) (*QueryResponse, error) {
+	var resp QueryResponse
+	body := map[string]interface{}{"args": args}
+	if err := c.do(ctx, c.config.RouterURL, http.MethodPost, "/templates/"+url.PathEscape(name)+"/execute", body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// do sends a JSON request and decodes the JSON response into out, retrying
+// failures that are safe to retry with exponential backoff
+func (c *Client) do(ctx context.Context, baseURL, method, path string, body, out interface{}) error {
+	if baseURL == "" {
+		return fmt.Errorf("no base URL configured for %s", path)
+	}
+
+	var payload []byte
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		payload = encoded
+	}
+
+	backoff := c.config.RetryBackoff
+	var lastErr error
+	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(retryDelay(lastErr, backoff)):
+			}
+			backoff *= 2
+		}
+
+		lastErr = c.attempt(ctx, baseURL+path, method, payload, out)
+		if lastErr == nil || !c.shouldRetry(ctx, lastErr) {
+			return lastErr
+		}
+	}
+	return lastErr
+}
+
+// attempt performs a single HTTP round trip
+func (c *Client) attempt(ctx context.Context, url, method string, payload []byte, out interface{}) error {
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	if payload != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if c.config.APIKey != "" {
+		httpReq.Header.Set("X-API-Key", c.config.APIKey)
+	}
+	if c.config.BearerToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.config.BearerToken)
+	}
+	// The router stops working on the request once the caller gives up
+	if deadline, ok := ctx.Deadline(); ok {
+		httpReq.Header.Set("X-Request-Deadline", deadline.Format(time.RFC3339Nano))
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return decodeError(resp, data)
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// decodeError builds an *Error from a failed response. The router returns a
+// structured error; the coordinator returns either {"error": "..."} or text.
+func decodeError(resp *http.Response, data []byte) error {
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	if seconds := resp.Header.Get("Retry-After"); seconds != "" {
+		if d, err := time.ParseDuration(seconds + "s"); err == nil {
+			apiErr.RetryAfter = d
+		}
+	}
+
+	var routerResp struct {
+		Error *Error `json:"error"`
+	}
+	if err := json.Unmarshal(data, &routerResp); err == nil && routerResp.Error != nil {
+		routerResp.Error.StatusCode = apiErr.StatusCode
+		routerResp.Error.RetryAfter = apiErr.RetryAfter
+		return routerResp.Error
+	}
+
+	var coordinatorResp struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &coordinatorResp); err == nil && coordinatorResp.Error != "" {
+		apiErr.Message = coordinatorResp.Error
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = resp.Status
+	}
+	apiErr.Retryable = resp.StatusCode == http.StatusServiceUnavailable
+	return apiErr
+}
+
+// shouldRetry reports whether a failed attempt may be retried
+func (c *Client) shouldRetry(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		if !apiErr.Retryable {
+			return false
+		}
+		// These are returned before the statement reached a shard
+		if apiErr.Code == CodeShardUnavailable || apiErr.Code == CodeAtCapacity || apiErr.Code == CodeJobLimit || apiErr.Code == "" {
+			return true
+		}
+		return c.config.RetryAmbiguous
+	}
+
+	// Connection refused means the request was never sent
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return c.config.RetryAmbiguous
+}
+
+// retryDelay honours Retry-After when the server sent one
+func retryDelay(err error, backoff time.Duration) time.Duration {
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > backoff {
+		return apiErr.RetryAfter
+	}
+	return backoff
+}
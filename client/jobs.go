@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Job statuses
+const (
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// SubmitQuery starts a read as a background job on the router, returning the
+// job to poll with GetJob. Writes are rejected.
+func (c *Client) SubmitQuery(ctx context.Context, req QueryRequest) (*Job, error) {
+	var job Job
+	if err := c.do(ctx, c.config.RouterURL, http.MethodPost, "/query/async", req, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetJob returns a job's status and, once completed, up to limit of its rows
+// starting at offset. A zero limit uses the router's page size.
+func (c *Client) GetJob(ctx context.Context, jobID string, offset, limit int) (*Job, error) {
+	params := url.Values{}
+	params.Set("offset", fmt.Sprint(offset))
+	if limit > 0 {
+		params.Set("limit", fmt.Sprint(limit))
+	}
+
+	var job Job
+	path := "/jobs/" + url.PathEscape(jobID) + "?" + params.Encode()
+	if err := c.do(ctx, c.config.RouterURL, http.MethodGet, path, nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// WaitJob polls a job every interval until it is no longer running,
+// returning it with the first page of its rows
+func (c *Client) WaitJob(ctx context.Context, jobID string, interval time.Duration) (*Job, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		job, err := c.GetJob(ctx, jobID, 0, 0)
+		if err != nil || job.Status != JobRunning {
+			return job, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// CancelJob cancels a running job, or discards the results of a finished one
+func (c *Client) CancelJob(ctx context.Context, jobID string) error {
+	return c.do(ctx, c.config.RouterURL, http.MethodDelete, "/jobs/"+url.PathEscape(jobID), nil, nil)
+}
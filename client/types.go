@@ -0,0 +1,317 @@
+package client
+
+import (
+	"fmt"
+	"time"
+)
+
+// Error codes returned by the router
+const (
+	CodeInvalidRequest   = "INVALID_REQUEST"
+	CodeParse            = "PARSE_ERROR"
+	CodeRouting          = "ROUTING_ERROR"
+	CodeNoMatchingShards = "NO_MATCHING_SHARDS"
+	CodeShardUnavailable = "SHARD_UNAVAILABLE"
+	CodeShardDown        = "SHARD_DOWN"
+	CodeTimeout          = "TIMEOUT"
+	CodeDeadlineExceeded = "DEADLINE_EXCEEDED"
+	CodeAtCapacity       = "CLUSTER_AT_CAPACITY"
+	CodeQuery            = "QUERY_ERROR"
+	CodeConflict         = "CONFLICT"
+	CodeQuotaExceeded    = "QUOTA_EXCEEDED"
+	CodeCommitInDoubt    = "COMMIT_IN_DOUBT"
+	CodeResultTooLarge   = "RESULT_TOO_LARGE"
+	CodeDangerous        = "DANGEROUS_STATEMENT"
+	CodePolicy           = "POLICY_VIOLATION"
+	CodeMissingKey       = "MISSING_SHARD_KEY"
+	CodeKeyUpdate        = "SHARD_KEY_UPDATE"
+	CodeJobLimit         = "TOO_MANY_JOBS"
+	CodeVersionConflict  = "VERSION_CONFLICT"
+	CodeUnboundedScatter = "UNBOUNDED_SCATTER"
+	CodeUnknownTemplate  = "UNKNOWN_TEMPLATE"
+	CodeInternal         = "INTERNAL_ERROR"
+)
+
+// Error is an error returned by the router or coordinator. Code is only set
+// for router errors.
+type Error struct {
+	Code       string                 `json:"code"`
+	Message    string                 `json:"message"`
+	Shard      string                 `json:"shard,omitempty"`
+	Retryable  bool                   `json:"retryable"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	StatusCode int                    `json:"-"`
+	RetryAfter time.Duration          `json:"-"`
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
+	}
+	if e.Shard != "" {
+		return fmt.Sprintf("%s on %s: %s", e.Code, e.Shard, e.Message)
+	}
+	return e.Code + ": " + e.Message
+}
+
+// QueryRequest is the body of POST /query
+type QueryRequest struct {
+	Query string `json:"query"`
+	// Consistency is "strong" (default), "eventual", "snapshot" or
+	// "bounded_staleness(N)"
+	Consistency string `json:"consistency,omitempty"`
+	// ShardSelector restricts scatter-gather to shards whose labels match
+	ShardSelector string `json:"shard_selector,omitempty"`
+	// AllowDangerous confirms a DELETE or UPDATE without a WHERE clause, a
+	// TRUNCATE or a DROP
+	AllowDangerous bool `json:"allow_dangerous,omitempty"`
+	// TimeoutMs bounds the query on the router
+	TimeoutMs int64 `json:"timeout_ms,omitempty"`
+	// ExpectedVersion is the version an UPDATE of a versioned table must
+	// find the row at
+	ExpectedVersion *int64 `json:"expected_version,omitempty"`
+	// WriteConcern is "all", "quorum" or "one": how many shards must apply
+	// a write sent to several of them
+	WriteConcern string `json:"write_concern,omitempty"`
+}
+
+// QueryResponse is the result of a query
+type QueryResponse struct {
+	Data         []map[string]interface{} `json:"data"`
+	Shard        string                   `json:"shard,omitempty"`
+	Shards       []string                 `json:"shards,omitempty"`
+	RowsAffected *int64                   `json:"rows_affected,omitempty"`
+	// Truncated is set when the rows were cut at the router's result limit
+	Truncated bool `json:"truncated,omitempty"`
+	// Repairing lists the shards a write failed on, allowed by its write
+	// concern, that the write is being repaired on in the background
+	Repairing []string `json:"repairing,omitempty"`
+	// Buffered lists the shards a write couldn't reach that the router
+	// journaled it for, to apply it once they are back
+	Buffered []string `json:"buffered,omitempty"`
+	// SchemaDrift lists the shards whose answer to SHOW or DESCRIBE differs
+	// from the one returned
+	SchemaDrift []string `json:"schema_drift,omitempty"`
+	// Plans holds each shard's plan for an EXPLAIN, keyed by shard
+	Plans map[string][]map[string]interface{} `json:"plans,omitempty"`
+	Error *Error                              `json:"error,omitempty"`
+}
+
+// BatchRequest is the body of POST /batch
+type BatchRequest struct {
+	Queries []QueryRequest `json:"queries"`
+	// StopOnError skips the remaining queries after the first failure
+	StopOnError bool `json:"stop_on_error,omitempty"`
+}
+
+// BatchResponse holds one result per executed query, in request order
+type BatchResponse struct {
+	Results []QueryResponse `json:"results"`
+}
+
+// Job is the status of a query run with SubmitQuery. Data holds one page of
+// the rows of a completed job, starting at Offset; NextOffset is set while
+// more rows remain.
+type Job struct {
+	JobID      string                   `json:"job_id"`
+	Status     string                   `json:"status"`
+	Query      string                   `json:"query"`
+	CreatedAt  time.Time                `json:"created_at"`
+	FinishedAt *time.Time               `json:"finished_at,omitempty"`
+	Data       []map[string]interface{} `json:"data,omitempty"`
+	Shard      string                   `json:"shard,omitempty"`
+	Shards     []string                 `json:"shards,omitempty"`
+	TotalRows  int                      `json:"total_rows"`
+	Offset     int                      `json:"offset"`
+	NextOffset *int                     `json:"next_offset,omitempty"`
+	Truncated  bool                     `json:"truncated,omitempty"`
+	Error      *Error                   `json:"error,omitempty"`
+}
+
+// DirectoryEntry routes a key or key range of a table to a shard
+type DirectoryEntry struct {
+	Table      string `json:"table"`
+	Key        string `json:"key,omitempty"`
+	RangeStart *int64 `json:"range_start,omitempty"`
+	RangeEnd   *int64 `json:"range_end,omitempty"`
+	ShardID    string `json:"shard_id"`
+}
+
+// Topology is the coordinator's routing view
+type Topology struct {
+	Version    string            `json:"version"`
+	Shards     map[string]string `json:"shards"`
+	Directory  []DirectoryEntry  `json:"directory"`
+	TimeRanges []TimeRange       `json:"time_ranges,omitempty"`
+	// Ring is the checksum of the coordinator's ring layout
+	Ring string `json:"ring"`
+}
+
+// RingToken is a position on the consistent hash ring and its shard
+type RingToken struct {
+	Token uint32 `json:"token"`
+	Shard string `json:"shard"`
+}
+
+// RingLayout is the token ownership of the consistent hash ring
+type RingLayout struct {
+	Checksum string      `json:"checksum"`
+	Tokens   []RingToken `json:"tokens"`
+}
+
+// TimeRange maps rows of a time range table whose timestamp falls in
+// [Start, End) to a shard
+type TimeRange struct {
+	Table    string     `json:"table"`
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end,omitempty"`
+	ShardID  string     `json:"shard_id"`
+	Archived bool       `json:"archived,omitempty"`
+}
+
+// ShardMetrics are the metrics the coordinator collects for a shard
+type ShardMetrics struct {
+	ShardID         string    `json:"shard_id"`
+	CPUPercent      float64   `json:"cpu_percent"`
+	MemoryPercent   float64   `json:"memory_percent"`
+	DiskPercent     float64   `json:"disk_percent"`
+	TotalEntries    int64     `json:"total_entries"`
+	ConnectionCount int64     `json:"connection_count"`
+	QueriesPerSec   float64   `json:"queries_per_second"`
+	Status          string    `json:"status"`
+	LastUpdated     time.Time `json:"last_updated"`
+	// EntryGrowthPerMinute is the rows added per minute since the previous
+	// sample
+	EntryGrowthPerMinute float64            `json:"entry_growth_per_minute"`
+	TableGrowthPerMinute map[string]float64 `json:"table_growth_per_minute,omitempty"`
+	// ReadsPerSec is the SELECTs per second served by the shard and its
+	// mirrors since the previous sample
+	ReadsPerSec float64 `json:"reads_per_second"`
+	// Replicas is the replication state of the shard's replicas
+	Replicas []ReplicaStatus `json:"replicas,omitempty"`
+	// Errors counts the statements that failed on the shard by cause, and
+	// NewErrors those since the previous sample
+	Errors    QueryErrors `json:"errors"`
+	NewErrors QueryErrors `json:"new_errors"`
+}
+
+// QueryErrors counts the statements that failed on a shard by cause
+type QueryErrors struct {
+	Timeouts           int64 `json:"timeouts"`
+	Deadlocks          int64 `json:"deadlocks"`
+	Syntax             int64 `json:"syntax"`
+	AccessDenied       int64 `json:"access_denied"`
+	DiskFull           int64 `json:"disk_full"`
+	TooManyConnections int64 `json:"too_many_connections"`
+	Other              int64 `json:"other"`
+}
+
+// ReplicaStatus is the replication state of a shard's replica
+type ReplicaStatus struct {
+	Name             string    `json:"name"`
+	Address          string    `json:"address"`
+	Region           string    `json:"region,omitempty"`
+	Source           string    `json:"source,omitempty"`
+	Healthy          bool      `json:"healthy"`
+	IORunning        bool      `json:"io_running"`
+	SQLRunning       bool      `json:"sql_running"`
+	LagSeconds       int64     `json:"lag_seconds"`
+	AutoPosition     bool      `json:"auto_position"`
+	RetrievedGTIDSet string    `json:"retrieved_gtid_set,omitempty"`
+	ExecutedGTIDSet  string    `json:"executed_gtid_set,omitempty"`
+	LastError        string    `json:"last_error,omitempty"`
+	CheckedAt        time.Time `json:"checked_at"`
+}
+
+// MoveKeyRequest is the body of POST /admin/move-key. Either Key or both
+// RangeStart and RangeEnd must be set.
+type MoveKeyRequest struct {
+	Table       string `json:"table"`
+	Key         string `json:"key,omitempty"`
+	RangeStart  *int64 `json:"range_start,omitempty"`
+	RangeEnd    *int64 `json:"range_end,omitempty"`
+	Destination string `json:"destination"`
+}
+
+// MoveResult describes keys moved to another shard
+type MoveResult struct {
+	Entry     DirectoryEntry   `json:"entry"`
+	RowsMoved map[string]int64 `json:"rows_moved"`
+	Duration  string           `json:"duration"`
+}
+
+// MergeResult describes a shard merged into another
+type MergeResult struct {
+	Source      string           `json:"source"`
+	Destination string           `json:"destination"`
+	RowsCopied  map[string]int64 `json:"rows_copied"`
+	Duration    string           `json:"duration"`
+}
+
+// DecommissionPlan describes what merging a shard into another would move
+type DecommissionPlan struct {
+	ShardID              string                  `json:"shard_id"`
+	Destination          string                  `json:"destination"`
+	Rows                 int64                   `json:"rows"`
+	TableRows            map[string]int64        `json:"table_rows"`
+	Bytes                int64                   `json:"bytes"`
+	MaxBytesPerSecond    int64                   `json:"max_bytes_per_second"`
+	EstimatedDuration    string                  `json:"estimated_duration,omitempty"`
+	QueriesPerSec        float64                 `json:"queries_per_second"`
+	ReadsPerSec          float64                 `json:"reads_per_second"`
+	EntryGrowthPerMinute float64                 `json:"entry_growth_per_minute"`
+	Candidates           []DecommissionCandidate `json:"candidates"`
+	MirrorsRemoved       []string                `json:"mirrors_removed,omitempty"`
+	CostDelta            float64                 `json:"cost_delta"`
+	Warnings             []string                `json:"warnings,omitempty"`
+	MetricsAsOf          time.Time               `json:"metrics_as_of"`
+}
+
+// DecommissionCandidate is a shard a decommissioned shard could merge into
+type DecommissionCandidate struct {
+	ShardID            string  `json:"shard_id"`
+	RowsAfter          int64   `json:"rows_after"`
+	BytesAfter         int64   `json:"bytes_after"`
+	QueriesPerSecAfter float64 `json:"queries_per_second_after"`
+}
+
+// ActiveQuery is a statement running on a shard
+type ActiveQuery struct {
+	ID          string `json:"id"`
+	ShardID     string `json:"shard_id"`
+	ProcessID   int64  `json:"process_id"`
+	User        string `json:"user"`
+	Host        string `json:"host"`
+	Database    string `json:"database"`
+	State       string `json:"state"`
+	TimeSeconds int64  `json:"time_seconds"`
+	Query       string `json:"query"`
+	OwnUser     bool   `json:"own_user"`
+}
+
+// Divergence records a broadcast table copy that missed a write
+type Divergence struct {
+	Table      string    `json:"table"`
+	ShardID    string    `json:"shard_id"`
+	Query      string    `json:"query"`
+	Error      string    `json:"error"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// RepairResult describes a broadcast table re-synced from a source shard
+type RepairResult struct {
+	Table      string `json:"table"`
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	RowsCopied int64  `json:"rows_copied"`
+}
+
+// MaintenanceWindow is a declared maintenance window for a shard
+type MaintenanceWindow struct {
+	ShardID string    `json:"shard_id"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Mode    string    `json:"mode"`
+	Reason  string    `json:"reason,omitempty"`
+}
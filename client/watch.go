@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// WatchTopology polls the coordinator for topology changes and calls fn with
+// each new version, starting with the current one. It blocks until ctx is
+// cancelled. Failed polls are retried on the next tick and reported to
+// onError when it is set.
+func (c *Client) WatchTopology(ctx context.Context, interval time.Duration, fn func(Topology), onError func(error)) error {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastVersion := ""
+	for {
+		topology, err := c.Topology(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if onError != nil {
+				onError(err)
+			}
+		} else if topology.Version != lastVersion {
+			lastVersion = topology.Version
+			fn(*topology)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -3,20 +3,98 @@ package config
 import (
 	"encoding/json"
 	"fmt"
-	"os"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"sql-horizontal-autoscaler/secrets"
 )
 
 // Config represents the application configuration
 type Config struct {
+	// Shards maps shard IDs to their DSNs. Once the services run, shards are
+	// added and removed concurrently, so use the Shard accessors instead.
 	Shards                     map[string]string `json:"shards"`
+	shardsMutex                sync.RWMutex
 	TableShardKeys             map[string]string `json:"table_shard_keys"`
+	TableDatabases             map[string]string `json:"table_databases"`
+	// ShardLabels tags shards by ID (e.g. {"shard-1": {"region": "us-east"}})
+	// so queries can restrict scatter-gather with a label selector
+	ShardLabels map[string]map[string]string `json:"shard_labels"`
 	ScalingThresholds          ScalingThresholds `json:"scaling_thresholds"`
 	ScalingStrategy            string            `json:"scaling_strategy"`
+	Adaptive                   AdaptiveConfig    `json:"adaptive"`
 	MonitoringIntervalSeconds  int               `json:"monitoring_interval_seconds"`
+	Monitoring                 MonitoringConfig  `json:"monitoring"`
 	Database                   DatabaseConfig    `json:"database"`
 	Docker                     DockerConfig      `json:"docker"`
+	Provisioner                ProvisionerConfig `json:"provisioner"`
+	ShardInit                  ShardInitConfig   `json:"shard_init"`
+	ShardWarmup                ShardWarmupConfig `json:"shard_warmup"`
+	Ramp                       RampConfig        `json:"ramp"`
+	Mirrors                    MirrorsConfig     `json:"mirrors"`
 	Ports                      PortsConfig       `json:"ports"`
 	Limits                     LimitsConfig      `json:"limits"`
+	Secrets                    SecretsConfig     `json:"secrets"`
+	State                      StateConfig       `json:"state"`
+	Reconciler                 ReconcilerConfig  `json:"reconciler"`
+	Rewrite                    RewriteConfig     `json:"rewrite"`
+	Merge                      MergeConfig       `json:"merge"`
+	Results                    ResultsConfig     `json:"results"`
+	HTTP                       HTTPConfig        `json:"http"`
+	Routers                    RoutersConfig     `json:"routers"`
+	Ring                       RingConfig        `json:"ring"`
+	ShardStats                 ShardStatsConfig  `json:"shard_stats"`
+	ParserCache                ParserCacheConfig `json:"parser_cache"`
+	Queries                    QueriesConfig     `json:"queries"`
+	Jobs                       JobsConfig        `json:"jobs"`
+	Audit                      AuditConfig       `json:"audit"`
+	QueryLog                   QueryLogConfig    `json:"query_log"`
+	Broadcast                  BroadcastConfig   `json:"broadcast"`
+	Placement                  PlacementConfig   `json:"placement"`
+	Regions                    RegionsConfig     `json:"regions"`
+	TimeRange                  TimeRangeConfig   `json:"time_range"`
+	TTL                        TTLConfig         `json:"ttl"`
+	IndexAdvisor               IndexAdvisorConfig `json:"index_advisor"`
+	Tenants                    TenantsConfig     `json:"tenants"`
+	TableMaintenance           TableMaintenanceConfig `json:"table_maintenance"`
+	Transactions               TransactionsConfig `json:"transactions"`
+	WriteBuffer                WriteBufferConfig  `json:"write_buffer"`
+	Split                      SplitConfig        `json:"split"`
+	Resync                     ResyncConfig       `json:"resync"`
+	MisplacedRows              MisplacedRowsConfig `json:"misplaced_rows"`
+	Guards                     GuardsConfig       `json:"guards"`
+	Scatter                    ScatterConfig      `json:"scatter"`
+	Policy                     PolicyConfig       `json:"policy"`
+	Templates                  TemplatesConfig    `json:"templates"`
+	Inserts                    InsertsConfig      `json:"inserts"`
+	Updates                    UpdatesConfig      `json:"updates"`
+	WriteConcern               WriteConcernConfig `json:"write_concern"`
+	MaintenanceWindows         []MaintenanceWindowConfig `json:"maintenance_windows"`
+	Alerts                     AlertsConfig      `json:"alerts"`
+	Hooks                      HooksConfig       `json:"hooks"`
+	ProxyExport                ProxyExportConfig `json:"proxy_export"`
+	Capacity                   CapacityConfig    `json:"capacity"`
+	Cost                       CostConfig        `json:"cost"`
+	Priorities                 PrioritiesConfig  `json:"priorities"`
+	// ShardGroups are further clusters run by the same process, each with
+	// its own shards, tables, ring, scaling and ports. A group's settings
+	// override this configuration's like a profile overlay; see ShardGroup.
+	ShardGroups map[string]json.RawMessage `json:"shard_groups"`
+	// Profile is the environment overlay merged into the base file, if any
+	Profile string `json:"-"`
+	// Group is the shard group this configuration is for, empty for the
+	// default group
+	Group string `json:"-"`
+
+	// secretRefs, secretValues and secretDSNs hold the secret references
+	// resolved at startup, their current values and the shard DSNs whose
+	// password is a reference, guarded by secretsMutex
+	secretRefs   map[string]string
+	secretValues map[string]string
+	secretDSNs   map[string]string
+	secretsMutex sync.RWMutex
 }
 
 // ScalingThresholds contains the thresholds for scaling decisions
@@ -26,20 +104,161 @@ type ScalingThresholds struct {
 	ConnectionThreshold         int64   `json:"connection_threshold"`
 	QPSThreshold                float64 `json:"qps_threshold"`
 	TotalEntryThresholdPerShard int64   `json:"total_entry_threshold_per_shard"`
+	// EntryGrowthPerMinute scales out ahead of the entry threshold when a
+	// shard gains rows this fast; 0 disables the trigger
+	EntryGrowthPerMinute float64 `json:"entry_growth_per_minute"`
+	// TooManyConnectionErrors and DiskFullErrors scale out once this many
+	// statements fail on a shard between two samples because it refused
+	// the connection or its disk is full; 0 disables the trigger
+	TooManyConnectionErrors int64 `json:"too_many_connection_errors"`
+	DiskFullErrors          int64 `json:"disk_full_errors"`
+}
+
+// AdaptiveConfig enables scaling on deviation from a learned per-shard
+// baseline instead of the fixed scaling thresholds
+type AdaptiveConfig struct {
+	Enabled bool `json:"enabled"`
+	// HalfLifeHours is how quickly old observations lose weight in the baseline
+	HalfLifeHours float64 `json:"half_life_hours"`
+	// DeviationFactor is the number of standard deviations above the baseline
+	// that triggers scaling
+	DeviationFactor float64 `json:"deviation_factor"`
+	// MinSamples is the number of observations needed before the learned
+	// threshold replaces the fixed one
+	MinSamples int `json:"min_samples"`
+	// HourlyBuckets learns a separate baseline per hour of day (UTC) so daily
+	// cycles are not treated as anomalies
+	HourlyBuckets bool     `json:"hourly_buckets"`
+	Metrics       []string `json:"metrics"`
+}
+
+// MonitoringConfig contains metrics collection scheduling settings
+type MonitoringConfig struct {
+	MaxConcurrentCollections int `json:"max_concurrent_collections"`
+	StaggerMs                int `json:"stagger_ms"`
+	JitterMs                 int `json:"jitter_ms"`
+	// StaleAfterSeconds is the age past which a shard's last sample is left
+	// out of scaling decisions and the shard is reported degraded (default
+	// three monitoring intervals)
+	StaleAfterSeconds int `json:"stale_after_seconds"`
+	// BackoffMaxSeconds caps the exponential backoff between collection
+	// attempts of a failing shard (default eight monitoring intervals)
+	BackoffMaxSeconds int `json:"backoff_max_seconds"`
+	// PushOnlyShards lists the shards, or "*" for all, whose metrics are
+	// only pushed to POST /metrics/ingest and never polled
+	PushOnlyShards []string `json:"push_only_shards"`
 }
 
 // DatabaseConfig contains database connection settings
 type DatabaseConfig struct {
-	Username     string `json:"username"`
-	Password     string `json:"password"`
-	RootPassword string `json:"root_password"`
+	Username       string                       `json:"username"`
+	Password       string                       `json:"password"`
+	RootPassword   string                       `json:"root_password"`
+	DSNParams      map[string]string            `json:"dsn_params"`
+	ShardDSNParams map[string]map[string]string `json:"shard_dsn_params"`
+	// ShardPorts sets the port of configured shards whose DSN doesn't name
+	// the port they are published on
+	ShardPorts map[string]int `json:"shard_ports"`
+	// TLS encrypts connections to every shard; ShardTLS replaces it for
+	// individual shards, where an empty entry turns TLS off
+	TLS      TLSConfig            `json:"tls"`
+	ShardTLS map[string]TLSConfig `json:"shard_tls"`
+	// AuthMode is "password" (default) or "rds_iam" for AWS RDS IAM tokens
+	AuthMode            string            `json:"auth_mode"`
+	ShardAuthModes      map[string]string `json:"shard_auth_modes"`
+	AWSRegion           string            `json:"aws_region"`
+	TokenRefreshSeconds int               `json:"token_refresh_seconds"`
+	// Replicas lists read replica DSNs per shard, used by non-strong reads
+	Replicas               map[string][]string `json:"replicas"`
+	ReplicaLagCheckSeconds int                 `json:"replica_lag_check_seconds"`
+	// ReadBalancer chooses among eligible replicas and broadcast copies:
+	// "least_lag" (default) or "latency", which prefers the lowest recent
+	// latency and error rate
+	ReadBalancer string `json:"read_balancer"`
+	// ReadOnlyFallback serves every read of a shard whose primary is
+	// unreachable from its healthy replicas and mirrors, rejecting its
+	// writes as temporarily unavailable until the primary answers again
+	ReadOnlyFallback bool `json:"read_only_fallback"`
+	// SnapshotWaitTimeoutMs bounds how long a "snapshot" consistency read
+	// waits for a replica to apply the cut before using the primary
+	SnapshotWaitTimeoutMs int `json:"snapshot_wait_timeout_ms"`
+	Pool                   PoolConfig          `json:"pool"`
+	Replication            ReplicationConfig   `json:"replication"`
 }
 
-// DockerConfig contains Docker-related settings
+// ReplicationConfig lets the coordinator manage replication between shards
+// and their configured replicas, which follow their source by GTID
+type ReplicationConfig struct {
+	// Manage points every replica at its shard's primary on startup,
+	// switching both to GTID mode if needed
+	Manage bool `json:"manage"`
+	// User and Password are the account replicas connect to their source as
+	User     string `json:"user"`
+	Password string `json:"password"`
+	// SourceAddresses maps a server's address in its DSN to the address its
+	// replicas reach it at, when they differ
+	SourceAddresses map[string]string `json:"source_addresses"`
+	// AutoFailover promotes the least lagged replica of a shard once metrics
+	// collection from its primary has failed FailoverAfterFailures times in
+	// a row
+	AutoFailover          bool `json:"auto_failover"`
+	FailoverAfterFailures int  `json:"failover_after_failures"`
+	// ApplyTimeoutSeconds bounds how long a replica being promoted may take
+	// to apply what it already received
+	ApplyTimeoutSeconds int `json:"apply_timeout_seconds"`
+}
+
+// PoolConfig controls shard connection pools. Lifetimes of 0 keep
+// connections open indefinitely.
+type PoolConfig struct {
+	MaxOpenConns           int `json:"max_open_conns"`
+	MaxIdleConns           int `json:"max_idle_conns"`
+	ConnMaxLifetimeSeconds int `json:"conn_max_lifetime_seconds"`
+	ConnMaxIdleTimeSeconds int `json:"conn_max_idle_time_seconds"`
+	// ReapIntervalSeconds is how often pools of departed or cordoned shards
+	// are released
+	ReapIntervalSeconds int `json:"reap_interval_seconds"`
+}
+
+// DockerConfig contains Docker-related settings. A missing network is created
+// at startup with NetworkDriver and NetworkSubnet.
 type DockerConfig struct {
-	NetworkName     string `json:"network_name"`
-	Image           string `json:"image"`
-	ContainerPrefix string `json:"container_prefix"`
+	NetworkName     string        `json:"network_name"`
+	NetworkDriver   string        `json:"network_driver"`
+	NetworkSubnet   string        `json:"network_subnet"`
+	Image           string        `json:"image"`
+	ContainerPrefix string        `json:"container_prefix"`
+	Volumes         VolumesConfig `json:"volumes"`
+}
+
+// VolumesConfig controls persistent storage for shard containers. Mode is
+// "none" (default), "named" or "bind"; Retention ("delete" or "archive")
+// decides what happens to a volume when its shard is decommissioned.
+type VolumesConfig struct {
+	Mode    string `json:"mode"`
+	BindDir string `json:"bind_dir"`
+	// Shards overrides the volume of individual shards: a path for a bind
+	// mount or a Docker volume name
+	Shards       map[string]string `json:"shards"`
+	Retention    string            `json:"retention"`
+	ArchiveDir   string            `json:"archive_dir"`
+	ArchiveImage string            `json:"archive_image"`
+}
+
+// ProvisionerConfig selects how new shards are created. Type is "docker"
+// (default), "rds", "cloudsql" or "azure"; the remaining settings only apply to
+// managed cloud instances.
+type ProvisionerConfig struct {
+	Type             string   `json:"type"`
+	InstanceClass    string   `json:"instance_class"`
+	StorageGB        int      `json:"storage_gb"`
+	Region           string   `json:"region"`
+	EngineVersion    string   `json:"engine_version"`
+	Network          string   `json:"network"`
+	Subnet           string   `json:"subnet"`
+	SecurityGroupIDs []string `json:"security_group_ids"`
+	ResourceGroup    string   `json:"resource_group"`
+	Project          string   `json:"project"`
 }
 
 // PortsConfig contains port configuration
@@ -52,30 +271,750 @@ type PortsConfig struct {
 // LimitsConfig contains system limits
 type LimitsConfig struct {
 	MaxShards                      int `json:"max_shards"`
+	MaxStep                        int `json:"max_step"`
+	MaxConcurrentProvisions        int `json:"max_concurrent_provisions"`
+	ReadyTimeoutSeconds            int `json:"ready_timeout_seconds"`
 	MaxConnectionAttempts          int `json:"max_connection_attempts"`
 	ConnectionRetryIntervalSeconds int `json:"connection_retry_interval_seconds"`
 }
 
-// LoadConfig loads configuration from a JSON file
-func LoadConfig(filename string) (*Config, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open config file: %w", err)
+// SecretsConfig contains secret resolution settings. The database passwords,
+// the push secret and the passwords in shard DSNs may be secret references,
+// resolved again every refresh interval so rotated values are picked up.
+type SecretsConfig struct {
+	RefreshIntervalSeconds int `json:"refresh_interval_seconds"`
+}
+
+// StateConfig contains coordinator state snapshot settings
+type StateConfig struct {
+	SnapshotPath            string `json:"snapshot_path"`
+	SnapshotIntervalSeconds int    `json:"snapshot_interval_seconds"`
+}
+
+// ReconcilerConfig contains orphaned container reconciliation settings
+type ReconcilerConfig struct {
+	IntervalSeconds int `json:"interval_seconds"`
+	// OrphanPolicy is "report" (the default), "adopt" or "remove". Adopting
+	// or removing acts on any container that looks like a shard, so only
+	// choose them once the reports show nothing unexpected.
+	OrphanPolicy string `json:"orphan_policy"`
+}
+
+// RewriteConfig contains query rewrite settings applied before execution
+type RewriteConfig struct {
+	Enabled       bool                `json:"enabled"`
+	ExpandStar    bool                `json:"expand_star"`
+	StripColumns  []string            `json:"strip_columns"`
+	InjectColumns map[string][]string `json:"inject_columns"`
+	// PushDownLimit sends LIMIT offset+n to every shard of a multi-shard
+	// query without ORDER BY, and stops waiting for shards once enough rows
+	// have arrived
+	PushDownLimit bool `json:"push_down_limit"`
+}
+
+// MergeConfig contains settings for merging results from multiple shards
+type MergeConfig struct {
+	// DedupMemoryLimit is the number of distinct keys held in memory before
+	// DISTINCT deduplication spills to disk
+	DedupMemoryLimit int    `json:"dedup_memory_limit"`
+	SpillDir         string `json:"spill_dir"`
+}
+
+// ResultsConfig controls how query results are encoded
+type ResultsConfig struct {
+	// LegacyStringValues returns every column as a string instead of using
+	// the column's type (numbers as JSON numbers)
+	LegacyStringValues bool `json:"legacy_string_values"`
+	// SingleShard and ScatterGather cap the rows and bytes a read on one
+	// shard, or across several, may return
+	SingleShard   ResultLimitConfig `json:"single_shard"`
+	ScatterGather ResultLimitConfig `json:"scatter_gather"`
+	// OnLimit is "error" to fail reads over the cap, asking the client to
+	// paginate, or "truncate" to return the rows up to the cap flagged as
+	// truncated
+	OnLimit string `json:"on_limit"`
+	// Estimate, when "count" or "probe", sizes a scatter-gather SELECT
+	// without a LIMIT on every shard before running it, failing it up front
+	// if it would return more than ScatterGather.MaxRows rows. "count" runs
+	// the query wrapped in COUNT(*); "probe" counts only up to the cap.
+	Estimate string `json:"estimate"`
+}
+
+// ResultLimitConfig caps a query response; negative values disable a cap
+type ResultLimitConfig struct {
+	MaxRows  int   `json:"max_rows"`
+	MaxBytes int64 `json:"max_bytes"`
+}
+
+// AuditConfig controls audit logging of write statements
+type AuditConfig struct {
+	Enabled bool   `json:"enabled"`
+	Path    string `json:"path"`
+	// Key keys the hash chain of the log, so it must not be stored with it;
+	// use a secret reference. Changing it requires starting a new log.
+	Key string `json:"key"`
+	// Tables limits auditing to these tables; empty audits every table
+	Tables        []string `json:"tables"`
+	ExcludeTables []string `json:"exclude_tables"`
+}
+
+// QueryLogConfig samples routed queries, with their shards, latency and row
+// counts, into a JSON log for traffic analysis
+type QueryLogConfig struct {
+	Enabled bool `json:"enabled"`
+	// Output is a file path, rotated at MaxSizeMB keeping MaxBackups old
+	// files, or "stdout"
+	Output     string `json:"output"`
+	MaxSizeMB  int    `json:"max_size_mb"`
+	MaxBackups int    `json:"max_backups"`
+	// SampleRate is the fraction of queries logged; TableSampleRates
+	// overrides it per table
+	SampleRate       float64            `json:"sample_rate"`
+	TableSampleRates map[string]float64 `json:"table_sample_rates"`
+	// IncludeLiterals logs SQL as sent; by default literal values are
+	// replaced with "?"
+	IncludeLiterals bool `json:"include_literals"`
+}
+
+// PlacementConfig lists the zones new shards are balanced across
+type PlacementConfig struct {
+	Zones []ZoneConfig `json:"zones"`
+}
+
+// ZoneConfig describes a zone: an availability zone for managed databases, or
+// a Docker host (reached through DockerHost, published ports on Host)
+type ZoneConfig struct {
+	Name       string `json:"name"`
+	DockerHost string `json:"docker_host"`
+	Host       string `json:"host"`
+	// Region is the region the zone is in, given to shards placed in it
+	Region string `json:"region"`
+}
+
+// RegionsConfig spreads the cluster across regions. Configured shards take
+// their region from their "region" label, new shards from their zone.
+type RegionsConfig struct {
+	// Local is the region this process runs in; non-strong reads prefer
+	// replicas there and cross regions only when it has none available
+	Local string `json:"local"`
+	// ReplicaRegions maps replica addresses ("host:port") to their region;
+	// replicas not listed are in their shard's region
+	ReplicaRegions map[string]string `json:"replica_regions"`
+	// WritePolicy is "async" (default), returning once the primary commits,
+	// "local", also waiting for a replica in the local region to apply the
+	// write, or "all", waiting for a replica in every region
+	WritePolicy        string `json:"write_policy"`
+	WriteWaitTimeoutMs int    `json:"write_wait_timeout_ms"`
+}
+
+// TimeRangeConfig shards time-series tables by date range instead of by hash
+type TimeRangeConfig struct {
+	Tables               map[string]TimeRangeTableConfig `json:"tables"`
+	CheckIntervalSeconds int                             `json:"check_interval_seconds"`
+}
+
+// TimeRangeTableConfig configures the time_range policy of one table
+type TimeRangeTableConfig struct {
+	// Column is the timestamp column rows are routed by; it is the table's
+	// shard key
+	Column string `json:"column"`
+	// Period is the granularity of range boundaries: "day", "week" or "month"
+	Period string `json:"period"`
+	// MaxRowsPerShard rolls the table over to a new shard at the next period
+	// boundary once the current range holds this many rows
+	MaxRowsPerShard int64 `json:"max_rows_per_shard"`
+	// ArchiveAfterPeriods detaches ranges that ended this many periods ago;
+	// 0 keeps every range routable
+	ArchiveAfterPeriods int `json:"archive_after_periods"`
+}
+
+// TTLConfig expires rows older than a per-table TTL on every shard, in
+// rate-limited batches
+type TTLConfig struct {
+	Tables          map[string]TTLTableConfig `json:"tables"`
+	IntervalSeconds int                       `json:"interval_seconds"`
+	// BatchSize is the number of rows removed by each DELETE
+	BatchSize int `json:"batch_size"`
+	// MaxRowsPerSecond caps the delete rate on each shard
+	MaxRowsPerSecond int `json:"max_rows_per_second"`
+}
+
+// TTLTableConfig expires rows of one table by a timestamp column
+type TTLTableConfig struct {
+	Column     string `json:"column"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+}
+
+// IndexAdvisorConfig configures the index advisor, which looks for missing
+// indexes in each shard's performance_schema statement digests
+type IndexAdvisorConfig struct {
+	Enabled         bool `json:"enabled"`
+	IntervalSeconds int  `json:"interval_seconds"`
+	// MaxDigests is the number of most expensive digests analyzed per shard
+	MaxDigests int `json:"max_digests"`
+	// MinExecutions ignores digests executed fewer times than this
+	MinExecutions int64 `json:"min_executions"`
+	// MinExaminedPerRow flags digests examining at least this many rows per
+	// row returned even when MySQL reports an index was used
+	MinExaminedPerRow float64 `json:"min_examined_per_row"`
+	// AutoApply creates approved indexes on every shard during the next
+	// advisor pass; otherwise they are applied through the API
+	AutoApply bool `json:"auto_apply"`
+}
+
+// TransactionsConfig controls writes that span several shards
+type TransactionsConfig struct {
+	// TwoPhaseCommit runs multi-shard writes as XA transactions that commit
+	// only once every shard has prepared them. It requires routers.id, which
+	// prefixes the router's XIDs.
+	TwoPhaseCommit bool `json:"two_phase_commit"`
+	// DecisionLogPath records commit decisions, so transactions a failure
+	// left prepared on a shard can be committed once it is back
+	DecisionLogPath         string `json:"decision_log_path"`
+	RecoveryIntervalSeconds int    `json:"recovery_interval_seconds"`
+	// AbortAfterSeconds rolls back prepared transactions that have no
+	// recorded commit decision after this long. Shards must prepare a write
+	// within half of it, or the write is rolled back.
+	AbortAfterSeconds int `json:"abort_after_seconds"`
+}
+
+// WriteBufferConfig journals writes to shards that are briefly unreachable,
+// as while their container restarts, and replays them in order once the
+// shard is back. Only writes that never reached the shard are buffered.
+type WriteBufferConfig struct {
+	Enabled bool `json:"enabled"`
+	// Path is the journal the buffered writes are kept in until replayed
+	Path string `json:"path"`
+	// MaxWrites bounds the writes buffered per shard; further writes fail
+	MaxWrites int `json:"max_writes"`
+	// MaxAgeSeconds fails further writes to a shard once its oldest buffered
+	// write has waited this long, so longer outages surface to clients
+	MaxAgeSeconds         int `json:"max_age_seconds"`
+	ReplayIntervalSeconds int `json:"replay_interval_seconds"`
+}
+
+// GuardsConfig protects against statements affecting every row of a table:
+// DELETE or UPDATE without a WHERE clause, TRUNCATE and DROP. They are
+// rejected unless the request sets allow_dangerous or the table allows them.
+type GuardsConfig struct {
+	// Disabled turns the protection off
+	Disabled bool `json:"disabled"`
+	// AllowedTables lists, per table, the statement types ("delete",
+	// "update", "truncate", "drop") allowed without allow_dangerous
+	AllowedTables map[string][]string `json:"allowed_tables"`
+	// DenyUnboundedScatter rejects SELECTs run on several shards without a
+	// LIMIT, or on a table of more than MaxScatterTableRows estimated rows
+	// across them, unless the request sets allow_dangerous
+	DenyUnboundedScatter bool  `json:"deny_unbounded_scatter"`
+	MaxScatterTableRows  int64 `json:"max_scatter_table_rows"`
+}
+
+// ScatterConfig bounds how many shards a scatter-gather read queries at
+// once; the rest wait for a slot, trading latency for smaller load spikes
+type ScatterConfig struct {
+	// MaxParallel caps every scatter-gather read; 0 is unlimited
+	MaxParallel int `json:"max_parallel"`
+	// TableMaxParallel overrides MaxParallel by table or "db.table"
+	TableMaxParallel map[string]int `json:"table_max_parallel"`
+}
+
+// PolicyConfig restricts the statements clients may run, checked on the
+// parsed statement before it is routed
+type PolicyConfig struct {
+	// Rules apply in addition to each other: a statement runs only if no
+	// rule matching it refuses it
+	Rules []PolicyRule `json:"rules"`
+	// RejectTautologies refuses statements whose WHERE clause is always true
+	// through literal comparisons like "OR 1=1", a common sign of injection
+	RejectTautologies bool `json:"reject_tautologies"`
+}
+
+// PolicyRule allows or denies statement types to some clients on some tables
+type PolicyRule struct {
+	Name string `json:"name"`
+	// Tenants selects clients by tenant name, as configured under tenants,
+	// or by actor for clients without a listed API key; empty matches all
+	Tenants []string `json:"tenants"`
+	// Tables selects tables as "table" or "db.table"; empty matches all
+	Tables []string `json:"tables"`
+	// Allow, when set, refuses every statement type not listed
+	Allow []string `json:"allow"`
+	// Deny refuses the statement types listed
+	Deny []string `json:"deny"`
+}
+
+// TemplatesConfig holds named, parameterized queries and can restrict
+// clients to running only those
+type TemplatesConfig struct {
+	// Templates are available on every router from startup, by name; more
+	// can be registered on a router through POST /templates
+	Templates map[string]QueryTemplate `json:"templates"`
+	// Required lists the tenants that may only run queries through
+	// templates; "*" requires it of every client
+	Required []string `json:"required"`
+	// Managers lists the tenants allowed to register and remove templates
+	// through the API; empty leaves the templates read-only
+	Managers []string `json:"managers"`
+}
+
+// QueryTemplate is a statement with named parameters such as :user_id,
+// which each execution fills in with literal values
+type QueryTemplate struct {
+	SQL string `json:"sql"`
+	// Tenants lists the tenants allowed to execute the template; empty
+	// allows any client
+	Tenants []string `json:"tenants,omitempty"`
+	// RequireShardKey refuses executions that don't route by the shard key,
+	// so the template never scatters to every shard
+	RequireShardKey bool `json:"require_shard_key,omitempty"`
+	// Consistency, ShardSelector, WriteConcern and TimeoutMs apply to every
+	// execution like the fields of a query request
+	Consistency   string `json:"consistency,omitempty"`
+	ShardSelector string `json:"shard_selector,omitempty"`
+	WriteConcern  string `json:"write_concern,omitempty"`
+	TimeoutMs     int64  `json:"timeout_ms,omitempty"`
+}
+
+// Policies for an INSERT that doesn't set its table's shard key
+const (
+	InsertKeyReject       = "reject"
+	InsertKeyDefaultShard = "default_shard"
+	InsertKeyDerive       = "derive"
+	InsertKeyScatter      = "scatter"
+)
+
+// InsertsConfig decides what happens to an INSERT that doesn't set its
+// table's shard key, which would otherwise insert the row on every shard
+type InsertsConfig struct {
+	// MissingShardKey is the policy of tables not listed in Tables: "reject"
+	// (default) or "scatter", which inserts the row on every shard
+	MissingShardKey string `json:"missing_shard_key"`
+	// Tables sets the policy per table, keyed by "table" or "db.table"
+	Tables map[string]InsertKeyPolicy `json:"tables"`
+}
+
+// InsertKeyPolicy is a table's policy for inserts without a shard key
+type InsertKeyPolicy struct {
+	// Action is "reject", "default_shard", "derive" or "scatter"
+	Action string `json:"action"`
+	// DefaultShard receives the rows with the "default_shard" action
+	DefaultShard string `json:"default_shard,omitempty"`
+	// KeyExpression computes the key with the "derive" action from other
+	// columns of the row, e.g. "{tenant_id}" or "{region}-{account_id}"
+	KeyExpression string `json:"key_expression,omitempty"`
+}
+
+// UpdatesConfig decides what happens to an UPDATE assigning its table's
+// shard key, which would leave the row on a shard that no longer owns it
+type UpdatesConfig struct {
+	// ShardKey is "reject" (default) or "move", which moves the rows to the
+	// shard owning the new key in a two-phase commit transaction. Moving
+	// requires transactions.two_phase_commit and an UPDATE setting the key
+	// to a literal WHERE the key equals a literal.
+	ShardKey string `json:"shard_key"`
+	// VersionColumns names the version column of tables using optimistic
+	// concurrency, by table or "db.table". UPDATEs of those tables increment
+	// the version and, given the version the client read, fail with a
+	// conflict when the row has moved on.
+	VersionColumns map[string]string `json:"version_columns"`
+	// RequireVersion rejects UPDATEs of versioned tables that check no version
+	RequireVersion bool `json:"require_version"`
+}
+
+// WriteConcernConfig decides how many shards must apply a write sent to
+// several of them: a write on a broadcast table, or one naming several keys
+// or no key at all
+type WriteConcernConfig struct {
+	// Default is "all" (every shard), "quorum" (a majority) or "one"; a
+	// request's write_concern overrides it
+	Default string `json:"default"`
+	// Tables overrides Default per table
+	Tables map[string]string `json:"tables"`
+	// RepairAttempts and RepairIntervalSeconds bound the background retries
+	// of a write on the shards that failed it, once enough others applied it
+	RepairAttempts        int `json:"repair_attempts"`
+	RepairIntervalSeconds int `json:"repair_interval_seconds"`
+}
+
+// SplitConfig controls splitting a shard by cloning it into a new shard
+type SplitConfig struct {
+	// MaxBytesPerSecond throttles the dump streamed into the new shard;
+	// zero is unthrottled
+	MaxBytesPerSecond int64 `json:"max_bytes_per_second"`
+	// DeleteBatchSize and DeletePauseMs pace the removal of rows each side
+	// no longer owns
+	DeleteBatchSize int `json:"delete_batch_size"`
+	DeletePauseMs   int `json:"delete_pause_ms"`
+}
+
+// ResyncConfig controls rebuilding a shard from a good copy by comparing
+// chunk checksums and copying only the chunks that differ
+type ResyncConfig struct {
+	// ChunkSize is the number of rows compared per checksum
+	ChunkSize int `json:"chunk_size"`
+	// MaxBytesPerSecond throttles the rows copied into the shard; zero is
+	// unthrottled
+	MaxBytesPerSecond int64 `json:"max_bytes_per_second"`
+}
+
+// MisplacedRowsConfig controls relocating rows found on a shard routing
+// doesn't send their key to
+type MisplacedRowsConfig struct {
+	// BatchSize is the number of keys relocated per copy and DELETE
+	BatchSize int `json:"batch_size"`
+	// MaxRowsPerSecond caps the relocation rate; zero is unthrottled
+	MaxRowsPerSecond int `json:"max_rows_per_second"`
+}
+
+// TableMaintenanceConfig schedules ANALYZE TABLE, and optionally OPTIMIZE
+// TABLE, on every shard during recurring low-traffic windows
+type TableMaintenanceConfig struct {
+	Enabled bool `json:"enabled"`
+	// Windows are daily UTC ranges such as "02:00-05:00"; a range may wrap
+	// past midnight
+	Windows []string `json:"windows"`
+	// Tables defaults to every sharded and broadcast table
+	Tables   []string `json:"tables"`
+	Optimize bool     `json:"optimize"`
+	// IntervalHours is the minimum time between runs on a shard, unless
+	// data was migrated onto it since
+	IntervalHours int `json:"interval_hours"`
+	// MaxCPUPercent skips shards busier than this
+	MaxCPUPercent        float64 `json:"max_cpu_percent"`
+	CheckIntervalSeconds int     `json:"check_interval_seconds"`
+}
+
+// InWindow reports whether t falls within one of the maintenance windows
+func (tm TableMaintenanceConfig) InWindow(t time.Time) bool {
+	t = t.UTC()
+	minute := t.Hour()*60 + t.Minute()
+	for _, window := range tm.Windows {
+		start, end, err := parseDailyWindow(window)
+		if err != nil {
+			continue
+		}
+		if start <= end && minute >= start && minute < end {
+			return true
+		}
+		if start > end && (minute >= start || minute < end) {
+			return true
+		}
 	}
-	defer file.Close()
+	return false
+}
 
-	var config Config
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&config); err != nil {
-		return nil, fmt.Errorf("failed to decode config: %w", err)
+// parseDailyWindow parses "HH:MM-HH:MM" into minutes since midnight
+func parseDailyWindow(window string) (int, int, error) {
+	bounds := strings.Split(window, "-")
+	if len(bounds) != 2 {
+		return 0, 0, fmt.Errorf("window %q must have the form HH:MM-HH:MM", window)
 	}
 
-	// Validate configuration
-	if err := config.validate(); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
+	minutes := make([]int, 2)
+	for i, bound := range bounds {
+		t, err := time.Parse("15:04", strings.TrimSpace(bound))
+		if err != nil {
+			return 0, 0, fmt.Errorf("window %q must have the form HH:MM-HH:MM", window)
+		}
+		minutes[i] = t.Hour()*60 + t.Minute()
+	}
+	if minutes[0] == minutes[1] {
+		return 0, 0, fmt.Errorf("window %q is empty", window)
 	}
+	return minutes[0], minutes[1], nil
+}
+
+// TenantsConfig identifies the teams sharing the router by API key and caps
+// their usage. Usage is tracked per router.
+type TenantsConfig struct {
+	Tenants map[string]TenantConfig `json:"tenants"`
+	// Default applies to the "anonymous" tenant, which accounts every
+	// request without an API key listed under a tenant as one client
+	Default QuotaConfig `json:"default"`
+}
+
+// TenantConfig lists a tenant's API keys and quotas
+type TenantConfig struct {
+	APIKeys []string    `json:"api_keys"`
+	Quota   QuotaConfig `json:"quota"`
+}
+
+// QuotaConfig caps usage per clock hour and per UTC day
+type QuotaConfig struct {
+	Hourly UsageLimits `json:"hourly"`
+	Daily  UsageLimits `json:"daily"`
+}
+
+// UsageLimits caps usage within a window; 0 leaves a resource unlimited
+type UsageLimits struct {
+	Queries       int64 `json:"queries"`
+	BytesReturned int64 `json:"bytes_returned"`
+	// ShardTimeMs is the time spent executing on shards, counted once per
+	// shard a query ran on
+	ShardTimeMs int64 `json:"shard_time_ms"`
+}
 
-	return &config, nil
+// BroadcastConfig lists reference tables replicated in full on every shard
+type BroadcastConfig struct {
+	Tables []string `json:"tables"`
+	// SourceShard holds the authoritative copy used for reads and repairs;
+	// empty repairs from the first in-sync shard and reads from the in-sync
+	// copy chosen by the database read balancer
+	SourceShard           string `json:"source_shard"`
+	RepairIntervalSeconds int    `json:"repair_interval_seconds"`
+}
+
+// HTTPConfig contains settings shared by the HTTP servers
+type HTTPConfig struct {
+	// Gzip compresses every response with gzip; superseded by Compression
+	Gzip               bool              `json:"gzip"`
+	Compression        CompressionConfig `json:"compression"`
+	CORSAllowedOrigins []string `json:"cors_allowed_origins"`
+	CORSAllowedMethods []string `json:"cors_allowed_methods"`
+	CORSAllowedHeaders []string `json:"cors_allowed_headers"`
+	// Server tunes the query router's HTTP server
+	Server ServerConfig `json:"server"`
+}
+
+// QueriesConfig contains runaway query management settings
+type QueriesConfig struct {
+	// MaxExecutionSeconds kills the statements the autoscaler runs for
+	// clients that run longer than this on any shard; its own copies, DDL
+	// and maintenance are left running. 0 disables the ceiling
+	MaxExecutionSeconds int `json:"max_execution_seconds"`
+	ReapIntervalSeconds int `json:"reap_interval_seconds"`
+}
+
+// ShardInitConfig runs teams' own seed data, grants or replication setup on
+// each new shard after its schema is created
+type ShardInitConfig struct {
+	// ScriptsDir holds .sql scripts, run against the shard's database, and
+	// .sh scripts, run with the shard in SHARD_* environment variables
+	ScriptsDir string `json:"scripts_dir"`
+	// WebhookURL is sent a POST with the new shard's details
+	WebhookURL     string `json:"webhook_url"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+	// Required keeps a shard whose hooks fail out of service
+	Required bool `json:"required"`
+}
+
+// ShardWarmupConfig primes a new shard's buffer pool and indexes before it
+// joins the ring, so its first real traffic doesn't land on a cold instance
+type ShardWarmupConfig struct {
+	// Queries are run against the new shard; none disables warmup
+	Queries []string `json:"queries"`
+	// VirtualUsers is the number of connections running the queries at once,
+	// each running the whole list Iterations times
+	VirtualUsers   int `json:"virtual_users"`
+	Iterations     int `json:"iterations"`
+	TimeoutSeconds int `json:"timeout_seconds"`
+}
+
+// RampConfig shifts traffic onto a scaled-out shard gradually: it joins the
+// ring owning a fraction of its keys, raised step by step over the ramp
+// while its error rate and latency stay within bounds
+type RampConfig struct {
+	Enabled         bool `json:"enabled"`
+	DurationSeconds int  `json:"duration_seconds"`
+	Steps           int  `json:"steps"`
+	// MinStatements is the statements a step needs before it is judged
+	MinStatements int64   `json:"min_statements"`
+	MaxErrorRate  float64 `json:"max_error_rate"`
+	MaxLatencyMs  float64 `json:"max_latency_ms"`
+	// OnFailure is "pause" (default), holding the weight until the shard
+	// behaves, or "rollback", taking all its keys away
+	OnFailure string `json:"on_failure"`
+}
+
+// StartWeight returns the ring weight new shards join with; 1 is full weight
+func (rc RampConfig) StartWeight() float64 {
+	if !rc.Enabled {
+		return 1
+	}
+	return 1 / float64(rc.Steps)
+}
+
+// MirrorsConfig controls read mirrors: copies of a shard kept in sync by
+// replication that serve its non-strong reads. The coordinator adds mirrors
+// to a shard whose reads outgrow it instead of splitting its key space.
+type MirrorsConfig struct {
+	Enabled bool `json:"enabled"`
+	// ReadQPSThreshold is the SELECTs per second each serving instance (the
+	// shard or one of its mirrors) may take before a mirror is added
+	ReadQPSThreshold float64 `json:"read_qps_threshold"`
+	// ScaleInRatio removes a mirror once the remaining instances would each
+	// serve less than this fraction of the threshold
+	ScaleInRatio    float64 `json:"scale_in_ratio"`
+	MaxPerShard     int     `json:"max_per_shard"`
+	CooldownSeconds int     `json:"cooldown_seconds"`
+	// BasePort is the host port of the first mirror (default 1000 above the
+	// shards' base port)
+	BasePort int `json:"base_port"`
+}
+
+// JobsConfig controls queries run asynchronously through POST /query/async
+type JobsConfig struct {
+	// MaxJobs caps the jobs held by a router, running or holding results
+	MaxJobs int `json:"max_jobs"`
+	// ResultTTLSeconds is how long a finished job's results stay available
+	ResultTTLSeconds int `json:"result_ttl_seconds"`
+	// PageSize is the default number of rows per page of job results
+	PageSize int `json:"page_size"`
+}
+
+// RingConfig controls the consistent hash ring placing keys on shards
+type RingConfig struct {
+	// LayoutPath is a layout saved from GET /topology/ring, loaded at startup
+	// so keys land on the same shards as in the process it was saved from
+	LayoutPath string `json:"layout_path"`
+}
+
+// RoutersConfig contains router registration and heartbeat settings
+type RoutersConfig struct {
+	// ID identifies this router to the coordinator (default query-router-<port>).
+	// With two-phase commit it must be set, unique to the router and kept
+	// across restarts, since recovery resolves the XIDs carrying it.
+	ID string `json:"id"`
+	// CoordinatorURL is where the router registers; empty disables registration
+	CoordinatorURL string `json:"coordinator_url"`
+	// AdvertiseAddress is the base URL the coordinator pushes topology to
+	AdvertiseAddress         string `json:"advertise_address"`
+	HeartbeatIntervalSeconds int    `json:"heartbeat_interval_seconds"`
+	HeartbeatTimeoutSeconds  int    `json:"heartbeat_timeout_seconds"`
+	PushIntervalSeconds      int    `json:"push_interval_seconds"`
+	// SyncDirectory adopts the coordinator's routing directory; set it on
+	// routers that don't run in the coordinator's process
+	SyncDirectory bool `json:"sync_directory"`
+	// PushSecret signs the topologies the coordinator pushes to routers,
+	// which reject unsigned pushes. Without it the coordinator doesn't push
+	// and routers pick up topology changes from heartbeat responses only.
+	PushSecret string `json:"push_secret"`
+}
+
+// ShardStatsConfig controls the per-shard statement counters a router
+// samples, and optionally saves to a table on a metadata shard
+type ShardStatsConfig struct {
+	IntervalSeconds int `json:"interval_seconds"`
+	// Table receives each interval's counters on Shard; empty saves nothing
+	Table string `json:"table"`
+	Shard string `json:"shard"`
+}
+
+// ParserCacheConfig controls the router's cache of parsed queries
+type ParserCacheConfig struct {
+	// Size is how many distinct queries are kept; negative disables the cache
+	Size int `json:"size"`
+}
+
+// CompressionConfig controls negotiated response compression
+type CompressionConfig struct {
+	Enabled bool `json:"enabled"`
+	// Encodings in order of preference ("zstd", "gzip")
+	Encodings    []string `json:"encodings"`
+	MinSizeBytes int      `json:"min_size_bytes"`
+	Paths        []string `json:"paths"`
+}
+
+// MaintenanceWindowConfig declares a maintenance window for a shard, or the
+// whole cluster when ShardID is "cluster"
+type MaintenanceWindowConfig struct {
+	ShardID string    `json:"shard_id"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Mode    string    `json:"mode"`
+	Reason  string    `json:"reason"`
+}
+
+// AlertsConfig contains alert delivery settings
+type AlertsConfig struct {
+	WebhookURLs []string `json:"webhook_urls"`
+}
+
+// ProxyExportConfig keeps an existing ProxySQL or HAProxy layer in sync with
+// the shards, rendering their addresses whenever the topology changes
+type ProxyExportConfig struct {
+	Enabled bool `json:"enabled"`
+	// Format is "proxysql" (mysql_servers and mysql_query_rules, a hostgroup
+	// per shard) or "haproxy" (a backend per shard)
+	Format string `json:"format"`
+	// OutputFile is rewritten with the rendered configuration, after which
+	// ReloadCommand runs (e.g. ["systemctl", "reload", "haproxy"])
+	OutputFile    string   `json:"output_file"`
+	ReloadCommand []string `json:"reload_command"`
+	// AdminDSN is a ProxySQL admin interface the configuration is loaded into
+	AdminDSN      string `json:"admin_dsn"`
+	HostgroupBase int    `json:"hostgroup_base"`
+	RuleIDBase    int    `json:"rule_id_base"`
+	// BackendName prefixes the HAProxy backend names
+	BackendName     string `json:"backend_name"`
+	IntervalSeconds int    `json:"interval_seconds"`
+}
+
+// HooksConfig lists external actions the coordinator runs around scaling,
+// for automation such as opening tickets, warming caches or purging CDNs
+type HooksConfig struct {
+	// PreScale hooks run before a scale-out, split, merge or mirror is added
+	PreScale []HookConfig `json:"pre_scale"`
+	// PostScale hooks run once a scaling event completed or failed
+	PostScale []HookConfig `json:"post_scale"`
+	// ThresholdBreach hooks run whenever a scaling threshold is breached
+	ThresholdBreach []HookConfig `json:"threshold_breach"`
+}
+
+// HookConfig is one hook: an executable or an HTTP endpoint
+type HookConfig struct {
+	Name string `json:"name"`
+	// Command is an executable and its arguments, given the event as JSON
+	// on stdin
+	Command []string `json:"command,omitempty"`
+	// URL is an endpoint the event is posted to as JSON
+	URL            string `json:"url,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+	// AbortOnFailure cancels the scaling action when a pre-scale hook fails
+	AbortOnFailure bool `json:"abort_on_failure,omitempty"`
+}
+
+// CapacityConfig controls router behavior once MaxShards is reached
+type CapacityConfig struct {
+	Action        string  `json:"action"`
+	HotTableCount int     `json:"hot_table_count"`
+	ShedRatio     float64 `json:"shed_ratio"`
+}
+
+// Table priority classes
+const (
+	PriorityCritical = "critical"
+	PriorityNormal   = "normal"
+	PriorityBatch    = "batch"
+)
+
+// PrioritiesConfig assigns tables to priority classes. Hot scaling weighs a
+// table's entries and growth by its class, and while the cluster is at
+// capacity the router sheds batch queries first and never sheds critical ones.
+type PrioritiesConfig struct {
+	// Tables maps a table (or "db.table") to its class; unlisted tables are
+	// normal
+	Tables  map[string]string  `json:"tables"`
+	Weights map[string]float64 `json:"weights"`
+}
+
+// CostConfig prices shards to project the cluster's monthly spend and caps
+// scaling at a budget. A shard costs its ShardCosts entry, else the price of
+// the provisioner's instance class in InstanceClassCosts, else
+// ShardHourlyCost.
+type CostConfig struct {
+	ShardHourlyCost    float64            `json:"shard_hourly_cost"`
+	InstanceClassCosts map[string]float64 `json:"instance_class_costs"`
+	ShardCosts         map[string]float64 `json:"shard_costs"`
+	// MonthlyBudget stops scale-outs that would raise the projected monthly
+	// spend above it; 0 disables the cap
+	MonthlyBudget float64 `json:"monthly_budget"`
+	Currency      string  `json:"currency"`
+}
+
+// LoadConfig loads configuration from a JSON file
+func LoadConfig(filename string) (*Config, error) {
+	return LoadConfigProfile(filename, "")
 }
 
 // validate checks if the configuration is valid
@@ -99,10 +1038,45 @@ func (c *Config) validate() error {
 	if c.ScalingThresholds.TotalEntryThresholdPerShard <= 0 {
 		return fmt.Errorf("total entry threshold must be positive")
 	}
+	if c.ScalingThresholds.EntryGrowthPerMinute < 0 {
+		return fmt.Errorf("entry growth threshold must not be negative")
+	}
+	if c.ScalingThresholds.TooManyConnectionErrors < 0 || c.ScalingThresholds.DiskFullErrors < 0 {
+		return fmt.Errorf("query error thresholds must not be negative")
+	}
 
 	if c.MonitoringIntervalSeconds <= 0 {
 		c.MonitoringIntervalSeconds = 60 // default to 60 seconds
 	}
+	if c.Adaptive.HalfLifeHours <= 0 {
+		c.Adaptive.HalfLifeHours = 72
+	}
+	if c.Adaptive.DeviationFactor <= 0 {
+		c.Adaptive.DeviationFactor = 3
+	}
+	if c.Adaptive.MinSamples <= 0 {
+		c.Adaptive.MinSamples = 30
+	}
+	if len(c.Adaptive.Metrics) == 0 {
+		c.Adaptive.Metrics = []string{"cpu", "memory", "connections", "qps"}
+	}
+	for _, metric := range c.Adaptive.Metrics {
+		if metric != "cpu" && metric != "memory" && metric != "connections" && metric != "qps" {
+			return fmt.Errorf("adaptive metric must be 'cpu', 'memory', 'connections' or 'qps'")
+		}
+	}
+	if c.Monitoring.MaxConcurrentCollections <= 0 {
+		c.Monitoring.MaxConcurrentCollections = 4
+	}
+	if c.Monitoring.StaggerMs < 0 || c.Monitoring.JitterMs < 0 {
+		return fmt.Errorf("monitoring stagger and jitter must not be negative")
+	}
+	if c.Monitoring.StaleAfterSeconds <= 0 {
+		c.Monitoring.StaleAfterSeconds = 3 * c.MonitoringIntervalSeconds
+	}
+	if c.Monitoring.BackoffMaxSeconds <= 0 {
+		c.Monitoring.BackoffMaxSeconds = 8 * c.MonitoringIntervalSeconds
+	}
 
 	// Set defaults for new configuration sections
 	if c.Database.Username == "" {
@@ -114,15 +1088,109 @@ func (c *Config) validate() error {
 	if c.Database.RootPassword == "" {
 		c.Database.RootPassword = "rootpass"
 	}
+	if c.Database.AuthMode == "" {
+		c.Database.AuthMode = "password"
+	}
+	for _, mode := range append([]string{c.Database.AuthMode}, mapValues(c.Database.ShardAuthModes)...) {
+		if mode != "password" && mode != "rds_iam" {
+			return fmt.Errorf("database auth mode must be 'password' or 'rds_iam'")
+		}
+	}
+	if c.Database.TokenRefreshSeconds == 0 {
+		c.Database.TokenRefreshSeconds = 600 // RDS IAM tokens are valid for 15 minutes
+	}
+	if c.Database.ReplicaLagCheckSeconds == 0 {
+		c.Database.ReplicaLagCheckSeconds = 5
+	}
+	if c.Database.SnapshotWaitTimeoutMs == 0 {
+		c.Database.SnapshotWaitTimeoutMs = 1000
+	}
+	if c.Database.ReadBalancer == "" {
+		c.Database.ReadBalancer = "least_lag"
+	}
+	if c.Database.Replication.Manage && c.Database.Replication.User == "" {
+		return fmt.Errorf("managed replication requires a replication user")
+	}
+	if c.Database.Replication.FailoverAfterFailures <= 0 {
+		c.Database.Replication.FailoverAfterFailures = 3
+	}
+	if c.Database.Replication.ApplyTimeoutSeconds <= 0 {
+		c.Database.Replication.ApplyTimeoutSeconds = 30
+	}
+	if c.Database.ReadBalancer != "least_lag" && c.Database.ReadBalancer != "latency" {
+		return fmt.Errorf("database read balancer must be 'least_lag' or 'latency'")
+	}
+	if c.Database.Pool.MaxOpenConns == 0 {
+		c.Database.Pool.MaxOpenConns = 25
+	}
+	if c.Database.Pool.MaxIdleConns == 0 {
+		c.Database.Pool.MaxIdleConns = 5
+	}
+	if c.Database.Pool.ReapIntervalSeconds == 0 {
+		c.Database.Pool.ReapIntervalSeconds = 30
+	}
 	if c.Docker.NetworkName == "" {
 		c.Docker.NetworkName = "autoscaler-network"
 	}
+	if c.Docker.NetworkDriver == "" {
+		c.Docker.NetworkDriver = "bridge"
+	}
+	if c.Docker.NetworkSubnet != "" {
+		if _, _, err := net.ParseCIDR(c.Docker.NetworkSubnet); err != nil {
+			return fmt.Errorf("docker network_subnet must be a CIDR block: %w", err)
+		}
+	}
 	if c.Docker.Image == "" {
 		c.Docker.Image = "mysql:8.0"
 	}
 	if c.Docker.ContainerPrefix == "" {
 		c.Docker.ContainerPrefix = "mysql"
 	}
+	if c.Docker.Volumes.Mode == "" {
+		c.Docker.Volumes.Mode = "none"
+	}
+	switch c.Docker.Volumes.Mode {
+	case "none", "named":
+	case "bind":
+		if c.Docker.Volumes.BindDir == "" {
+			return fmt.Errorf("bind volumes require docker.volumes.bind_dir")
+		}
+	default:
+		return fmt.Errorf("docker volume mode must be 'none', 'named' or 'bind'")
+	}
+	if c.Docker.Volumes.Retention == "" {
+		c.Docker.Volumes.Retention = "delete"
+	}
+	switch c.Docker.Volumes.Retention {
+	case "delete":
+	case "archive":
+		if c.Docker.Volumes.ArchiveDir == "" {
+			return fmt.Errorf("archive retention requires docker.volumes.archive_dir")
+		}
+	default:
+		return fmt.Errorf("volume retention must be 'delete' or 'archive'")
+	}
+	if c.Docker.Volumes.ArchiveImage == "" {
+		c.Docker.Volumes.ArchiveImage = "alpine:3"
+	}
+	if c.Provisioner.Type == "" {
+		c.Provisioner.Type = "docker"
+	}
+	switch c.Provisioner.Type {
+	case "docker":
+	case "rds", "cloudsql", "azure":
+		if c.Provisioner.InstanceClass == "" || c.Provisioner.Region == "" {
+			return fmt.Errorf("%s provisioner requires instance_class and region", c.Provisioner.Type)
+		}
+		if c.Provisioner.Type == "azure" && c.Provisioner.ResourceGroup == "" {
+			return fmt.Errorf("azure provisioner requires resource_group")
+		}
+		if c.Provisioner.StorageGB == 0 {
+			c.Provisioner.StorageGB = 20
+		}
+	default:
+		return fmt.Errorf("provisioner type must be 'docker', 'rds', 'cloudsql' or 'azure'")
+	}
 	if c.Ports.BasePort == 0 {
 		c.Ports.BasePort = 3306
 	}
@@ -135,6 +1203,21 @@ func (c *Config) validate() error {
 	if c.Limits.MaxShards == 0 {
 		c.Limits.MaxShards = 5
 	}
+	if c.Limits.MaxStep == 0 {
+		c.Limits.MaxStep = 1
+	}
+	if c.Limits.MaxStep < 0 {
+		return fmt.Errorf("limits.max_step must be positive")
+	}
+	if c.Limits.MaxConcurrentProvisions == 0 {
+		c.Limits.MaxConcurrentProvisions = 1
+	}
+	if c.Limits.MaxConcurrentProvisions < 0 {
+		return fmt.Errorf("limits.max_concurrent_provisions must be positive")
+	}
+	if c.Limits.ReadyTimeoutSeconds < 0 {
+		return fmt.Errorf("limits.ready_timeout_seconds can't be negative")
+	}
 	if c.Limits.MaxConnectionAttempts == 0 {
 		c.Limits.MaxConnectionAttempts = 30
 	}
@@ -150,15 +1233,789 @@ func (c *Config) validate() error {
 	if c.ScalingThresholds.QPSThreshold == 0 {
 		c.ScalingThresholds.QPSThreshold = 1000.0
 	}
+	if c.Secrets.RefreshIntervalSeconds == 0 {
+		c.Secrets.RefreshIntervalSeconds = 300
+	}
+	if c.State.SnapshotPath == "" {
+		c.State.SnapshotPath = "coordinator-state.json"
+	}
+	if c.State.SnapshotIntervalSeconds == 0 {
+		c.State.SnapshotIntervalSeconds = 30
+	}
+	if c.Merge.DedupMemoryLimit == 0 {
+		c.Merge.DedupMemoryLimit = 100000
+	}
+	if c.Results.SingleShard.MaxRows == 0 {
+		c.Results.SingleShard.MaxRows = 100000
+	}
+	if c.Results.SingleShard.MaxBytes == 0 {
+		c.Results.SingleShard.MaxBytes = 64 << 20
+	}
+	if c.Results.ScatterGather.MaxRows == 0 {
+		c.Results.ScatterGather.MaxRows = 250000
+	}
+	if c.Results.ScatterGather.MaxBytes == 0 {
+		c.Results.ScatterGather.MaxBytes = 128 << 20
+	}
+	if c.Results.OnLimit == "" {
+		c.Results.OnLimit = "error"
+	}
+	if c.Results.OnLimit != "error" && c.Results.OnLimit != "truncate" {
+		return fmt.Errorf("results on_limit must be 'error' or 'truncate'")
+	}
+	if c.Results.Estimate != "" && c.Results.Estimate != "count" && c.Results.Estimate != "probe" {
+		return fmt.Errorf("results estimate must be 'count' or 'probe'")
+	}
+	if c.Audit.Enabled && c.Audit.Path == "" {
+		c.Audit.Path = "audit.log"
+	}
+	if c.Audit.Enabled && c.Audit.Key == "" {
+		return fmt.Errorf("audit logging requires audit.key")
+	}
+	if c.QueryLog.Output == "" {
+		c.QueryLog.Output = "stdout"
+	}
+	if c.QueryLog.MaxSizeMB <= 0 {
+		c.QueryLog.MaxSizeMB = 100
+	}
+	if c.QueryLog.MaxBackups < 0 {
+		return fmt.Errorf("query_log max_backups must not be negative")
+	}
+	if c.QueryLog.SampleRate < 0 || c.QueryLog.SampleRate > 1 {
+		return fmt.Errorf("query_log sample_rate must be between 0 and 1")
+	}
+	for table, rate := range c.QueryLog.TableSampleRates {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("query_log sample rate of table %s must be between 0 and 1", table)
+		}
+	}
+	zoneNames := make(map[string]bool)
+	for _, zone := range c.Placement.Zones {
+		if zone.Name == "" {
+			return fmt.Errorf("placement zones must be named")
+		}
+		if zoneNames[zone.Name] {
+			return fmt.Errorf("duplicate placement zone %s", zone.Name)
+		}
+		zoneNames[zone.Name] = true
+	}
+	if c.TimeRange.CheckIntervalSeconds == 0 {
+		c.TimeRange.CheckIntervalSeconds = 60
+	}
+	for table, policy := range c.TimeRange.Tables {
+		if policy.Column == "" {
+			return fmt.Errorf("time range table %s must name its timestamp column", table)
+		}
+		if policy.Period == "" {
+			policy.Period = "month"
+		}
+		if policy.Period != "day" && policy.Period != "week" && policy.Period != "month" {
+			return fmt.Errorf("time range period of %s must be 'day', 'week' or 'month'", table)
+		}
+		if policy.MaxRowsPerShard == 0 {
+			policy.MaxRowsPerShard = c.ScalingThresholds.TotalEntryThresholdPerShard
+		}
+		if policy.ArchiveAfterPeriods < 0 {
+			return fmt.Errorf("time range archive_after_periods of %s cannot be negative", table)
+		}
+		if key, sharded := c.TableShardKeys[table]; sharded && key != policy.Column {
+			return fmt.Errorf("time range table %s must be sharded by its timestamp column %s, not %s", table, policy.Column, key)
+		}
+		if c.TableShardKeys == nil {
+			c.TableShardKeys = make(map[string]string)
+		}
+		c.TableShardKeys[table] = policy.Column
+		c.TimeRange.Tables[table] = policy
+	}
+	if c.TTL.IntervalSeconds == 0 {
+		c.TTL.IntervalSeconds = 300
+	}
+	if c.TTL.BatchSize == 0 {
+		c.TTL.BatchSize = 1000
+	}
+	if c.TTL.MaxRowsPerSecond == 0 {
+		c.TTL.MaxRowsPerSecond = 5000
+	}
+	for table, ttl := range c.TTL.Tables {
+		if ttl.Column == "" {
+			return fmt.Errorf("ttl table %s must name its timestamp column", table)
+		}
+		if ttl.TTLSeconds <= 0 {
+			return fmt.Errorf("ttl of table %s must be positive", table)
+		}
+	}
+	if c.IndexAdvisor.IntervalSeconds == 0 {
+		c.IndexAdvisor.IntervalSeconds = 600
+	}
+	if c.IndexAdvisor.MaxDigests == 0 {
+		c.IndexAdvisor.MaxDigests = 100
+	}
+	if c.IndexAdvisor.MinExecutions == 0 {
+		c.IndexAdvisor.MinExecutions = 100
+	}
+	if c.IndexAdvisor.MinExaminedPerRow == 0 {
+		c.IndexAdvisor.MinExaminedPerRow = 100
+	}
+	if c.Transactions.DecisionLogPath == "" {
+		c.Transactions.DecisionLogPath = "xa-decisions.log"
+	}
+	if c.ParserCache.Size == 0 {
+		c.ParserCache.Size = 10000
+	}
+	if c.ShardStats.IntervalSeconds == 0 {
+		c.ShardStats.IntervalSeconds = 60
+	}
+	if c.ShardStats.Table != "" && c.ShardStats.Shard == "" {
+		return fmt.Errorf("shard_stats.table needs the metadata shard to write it to in shard_stats.shard")
+	}
+	if c.Transactions.RecoveryIntervalSeconds == 0 {
+		c.Transactions.RecoveryIntervalSeconds = 30
+	}
+	if c.Transactions.AbortAfterSeconds == 0 {
+		c.Transactions.AbortAfterSeconds = 60
+	}
+	if c.WriteBuffer.Path == "" {
+		c.WriteBuffer.Path = "write-buffer.log"
+	}
+	if c.WriteBuffer.MaxWrites == 0 {
+		c.WriteBuffer.MaxWrites = 10000
+	}
+	if c.WriteBuffer.MaxAgeSeconds == 0 {
+		c.WriteBuffer.MaxAgeSeconds = 300
+	}
+	if c.WriteBuffer.ReplayIntervalSeconds == 0 {
+		c.WriteBuffer.ReplayIntervalSeconds = 5
+	}
+	if c.WriteBuffer.MaxWrites < 0 || c.WriteBuffer.MaxAgeSeconds < 0 || c.WriteBuffer.ReplayIntervalSeconds < 0 {
+		return fmt.Errorf("write_buffer max_writes, max_age_seconds and replay_interval_seconds must not be negative")
+	}
+	if c.Guards.MaxScatterTableRows < 0 {
+		return fmt.Errorf("guards max_scatter_table_rows must not be negative")
+	}
+	if c.Scatter.MaxParallel < 0 {
+		return fmt.Errorf("scatter max_parallel must not be negative")
+	}
+	for table, parallel := range c.Scatter.TableMaxParallel {
+		if parallel < 0 {
+			return fmt.Errorf("scatter max_parallel for %s must not be negative", table)
+		}
+	}
+	for table, statements := range c.Guards.AllowedTables {
+		for _, statement := range statements {
+			switch statement {
+			case "delete", "update", "truncate", "drop":
+			default:
+				return fmt.Errorf("guards for %s must allow 'delete', 'update', 'truncate' or 'drop', not %q", table, statement)
+			}
+		}
+	}
+	for i, rule := range c.Policy.Rules {
+		if rule.Name == "" {
+			c.Policy.Rules[i].Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		for _, statement := range append(append([]string{}, rule.Allow...), rule.Deny...) {
+			switch statement {
+			case "select", "insert", "update", "delete", "truncate", "drop", "show", "describe":
+			default:
+				return fmt.Errorf("policy rule %s lists unknown statement type %q", c.Policy.Rules[i].Name, statement)
+			}
+		}
+	}
+	for name, template := range c.Templates.Templates {
+		if name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("template name %q must be non-empty and not contain '/'", name)
+		}
+		if strings.TrimSpace(template.SQL) == "" {
+			return fmt.Errorf("template %s must have sql", name)
+		}
+		if template.TimeoutMs < 0 {
+			return fmt.Errorf("template %s timeout_ms must not be negative", name)
+		}
+	}
+	if c.Inserts.MissingShardKey == "" {
+		c.Inserts.MissingShardKey = InsertKeyReject
+	}
+	if c.Inserts.MissingShardKey != InsertKeyReject && c.Inserts.MissingShardKey != InsertKeyScatter {
+		return fmt.Errorf("inserts missing_shard_key must be '%s' or '%s'", InsertKeyReject, InsertKeyScatter)
+	}
+	for table, policy := range c.Inserts.Tables {
+		switch policy.Action {
+		case InsertKeyReject, InsertKeyScatter:
+		case InsertKeyDefaultShard:
+			if policy.DefaultShard == "" {
+				return fmt.Errorf("insert policy for %s must name a default_shard", table)
+			}
+		case InsertKeyDerive:
+			if !strings.Contains(policy.KeyExpression, "{") {
+				return fmt.Errorf("insert policy for %s needs a key_expression referencing columns as {column}", table)
+			}
+		default:
+			return fmt.Errorf("insert policy for %s must be '%s', '%s', '%s' or '%s'", table,
+				InsertKeyReject, InsertKeyDefaultShard, InsertKeyDerive, InsertKeyScatter)
+		}
+	}
+	if c.Updates.ShardKey == "" {
+		c.Updates.ShardKey = "reject"
+	}
+	if c.Updates.ShardKey != "reject" && c.Updates.ShardKey != "move" {
+		return fmt.Errorf("updates shard_key must be 'reject' or 'move'")
+	}
+	if c.Updates.ShardKey == "move" && !c.Transactions.TwoPhaseCommit {
+		return fmt.Errorf("updates shard_key 'move' requires transactions two_phase_commit")
+	}
+	if c.WriteConcern.Default == "" {
+		c.WriteConcern.Default = "all"
+	}
+	if c.WriteConcern.Default != "all" && c.WriteConcern.Default != "quorum" && c.WriteConcern.Default != "one" {
+		return fmt.Errorf("write_concern default must be 'all', 'quorum' or 'one'")
+	}
+	for name, concern := range c.WriteConcern.Tables {
+		if concern != "all" && concern != "quorum" && concern != "one" {
+			return fmt.Errorf("write_concern of table %s must be 'all', 'quorum' or 'one'", name)
+		}
+	}
+	if c.WriteConcern.RepairAttempts == 0 {
+		c.WriteConcern.RepairAttempts = 5
+	}
+	if c.WriteConcern.RepairIntervalSeconds == 0 {
+		c.WriteConcern.RepairIntervalSeconds = 10
+	}
+	if c.Split.DeleteBatchSize == 0 {
+		c.Split.DeleteBatchSize = 1000
+	}
+	if c.Regions.WritePolicy == "" {
+		c.Regions.WritePolicy = "async"
+	}
+	switch c.Regions.WritePolicy {
+	case "async", "local", "all":
+	default:
+		return fmt.Errorf("regions write_policy must be 'async', 'local' or 'all'")
+	}
+	if c.Regions.WritePolicy == "local" && c.Regions.Local == "" {
+		return fmt.Errorf("regions write_policy 'local' requires regions local")
+	}
+	if c.Regions.WriteWaitTimeoutMs == 0 {
+		c.Regions.WriteWaitTimeoutMs = 2000
+	}
+	if c.Resync.ChunkSize == 0 {
+		c.Resync.ChunkSize = 1000
+	}
+	if c.MisplacedRows.BatchSize == 0 {
+		c.MisplacedRows.BatchSize = 1000
+	}
+	if c.MisplacedRows.BatchSize < 0 || c.MisplacedRows.MaxRowsPerSecond < 0 {
+		return fmt.Errorf("misplaced_rows batch_size and max_rows_per_second can't be negative")
+	}
+	for stage, stageHooks := range map[string][]HookConfig{"pre_scale": c.Hooks.PreScale, "post_scale": c.Hooks.PostScale, "threshold_breach": c.Hooks.ThresholdBreach} {
+		for i := range stageHooks {
+			hook := &stageHooks[i]
+			if hook.Name == "" {
+				hook.Name = fmt.Sprintf("%s-%d", stage, i+1)
+			}
+			if (len(hook.Command) == 0) == (hook.URL == "") {
+				return fmt.Errorf("hook %s needs either a command or a url", hook.Name)
+			}
+			if hook.TimeoutSeconds == 0 {
+				hook.TimeoutSeconds = 30
+			}
+		}
+	}
+	if c.TableMaintenance.IntervalHours == 0 {
+		c.TableMaintenance.IntervalHours = 24
+	}
+	if c.TableMaintenance.MaxCPUPercent == 0 {
+		c.TableMaintenance.MaxCPUPercent = 50
+	}
+	if c.TableMaintenance.CheckIntervalSeconds == 0 {
+		c.TableMaintenance.CheckIntervalSeconds = 300
+	}
+	if c.TableMaintenance.Enabled && len(c.TableMaintenance.Windows) == 0 {
+		return fmt.Errorf("table maintenance needs at least one window")
+	}
+	for _, window := range c.TableMaintenance.Windows {
+		if _, _, err := parseDailyWindow(window); err != nil {
+			return fmt.Errorf("table maintenance: %w", err)
+		}
+	}
+	tenantKeys := make(map[string]string)
+	for name, tenant := range c.Tenants.Tenants {
+		if len(tenant.APIKeys) == 0 {
+			return fmt.Errorf("tenant %s must list at least one api key", name)
+		}
+		for _, key := range tenant.APIKeys {
+			if other, exists := tenantKeys[key]; exists && other != name {
+				return fmt.Errorf("api key of tenant %s is also used by tenant %s", name, other)
+			}
+			tenantKeys[key] = name
+		}
+	}
+	if c.Broadcast.RepairIntervalSeconds == 0 {
+		c.Broadcast.RepairIntervalSeconds = 60
+	}
+	for _, table := range c.Broadcast.Tables {
+		if _, sharded := c.TableShardKeys[table]; sharded {
+			return fmt.Errorf("table %s cannot be both sharded and broadcast", table)
+		}
+	}
+	if c.Queries.ReapIntervalSeconds == 0 {
+		c.Queries.ReapIntervalSeconds = 10
+	}
+	if c.ShardInit.TimeoutSeconds <= 0 {
+		c.ShardInit.TimeoutSeconds = 300
+	}
+	if c.Ramp.DurationSeconds == 0 {
+		c.Ramp.DurationSeconds = 600
+	}
+	if c.Ramp.Steps <= 0 {
+		c.Ramp.Steps = 5
+	}
+	if c.Ramp.MinStatements == 0 {
+		c.Ramp.MinStatements = 50
+	}
+	if c.Ramp.MaxErrorRate == 0 {
+		c.Ramp.MaxErrorRate = 0.05
+	}
+	if c.Ramp.MaxLatencyMs == 0 {
+		c.Ramp.MaxLatencyMs = 500
+	}
+	if c.Ramp.OnFailure == "" {
+		c.Ramp.OnFailure = "pause"
+	}
+	if c.Ramp.OnFailure != "pause" && c.Ramp.OnFailure != "rollback" {
+		return fmt.Errorf("ramp.on_failure must be pause or rollback, got %q", c.Ramp.OnFailure)
+	}
+	if c.ShardWarmup.VirtualUsers <= 0 {
+		c.ShardWarmup.VirtualUsers = 1
+	}
+	if c.ShardWarmup.Iterations <= 0 {
+		c.ShardWarmup.Iterations = 1
+	}
+	if c.ShardWarmup.TimeoutSeconds <= 0 {
+		c.ShardWarmup.TimeoutSeconds = 300
+	}
+	if c.Mirrors.ReadQPSThreshold == 0 {
+		c.Mirrors.ReadQPSThreshold = 500
+	}
+	if c.Mirrors.ScaleInRatio == 0 {
+		c.Mirrors.ScaleInRatio = 0.3
+	}
+	if c.Mirrors.ReadQPSThreshold < 0 || c.Mirrors.ScaleInRatio < 0 || c.Mirrors.ScaleInRatio >= 1 {
+		return fmt.Errorf("mirrors read_qps_threshold must be positive and scale_in_ratio between 0 and 1")
+	}
+	if c.Mirrors.MaxPerShard == 0 {
+		c.Mirrors.MaxPerShard = 2
+	}
+	if c.Mirrors.CooldownSeconds == 0 {
+		c.Mirrors.CooldownSeconds = 300
+	}
+	if c.Mirrors.BasePort == 0 {
+		c.Mirrors.BasePort = c.Ports.BasePort + 1000
+	}
+	if c.Mirrors.Enabled && c.Provisioner.Type != "docker" {
+		return fmt.Errorf("mirrors are only supported by the docker provisioner")
+	}
+	if c.Jobs.MaxJobs == 0 {
+		c.Jobs.MaxJobs = 100
+	}
+	if c.Jobs.ResultTTLSeconds == 0 {
+		c.Jobs.ResultTTLSeconds = 3600
+	}
+	if c.Jobs.PageSize == 0 {
+		c.Jobs.PageSize = 1000
+	}
+	if c.Routers.HeartbeatIntervalSeconds == 0 {
+		c.Routers.HeartbeatIntervalSeconds = 5
+	}
+	if c.Routers.HeartbeatTimeoutSeconds == 0 {
+		c.Routers.HeartbeatTimeoutSeconds = 3 * c.Routers.HeartbeatIntervalSeconds
+	}
+	if c.Routers.PushIntervalSeconds == 0 {
+		c.Routers.PushIntervalSeconds = 2
+	}
+	if err := c.HTTP.Server.validate(); err != nil {
+		return err
+	}
+	if len(c.HTTP.Compression.Encodings) == 0 {
+		c.HTTP.Compression.Encodings = []string{"zstd", "gzip"}
+	}
+	for _, encoding := range c.HTTP.Compression.Encodings {
+		if encoding != "zstd" && encoding != "gzip" {
+			return fmt.Errorf("compression encodings must be 'zstd' or 'gzip'")
+		}
+	}
+	if c.HTTP.Compression.MinSizeBytes == 0 {
+		c.HTTP.Compression.MinSizeBytes = 1024
+	}
+	if len(c.HTTP.Compression.Paths) == 0 {
+		c.HTTP.Compression.Paths = []string{"/query", "/batch"}
+	}
+	if len(c.HTTP.CORSAllowedMethods) == 0 {
+		c.HTTP.CORSAllowedMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	}
+	if len(c.HTTP.CORSAllowedHeaders) == 0 {
+		c.HTTP.CORSAllowedHeaders = []string{"Content-Type", "Authorization", "X-Request-ID"}
+	}
+	if c.ProxyExport.Format == "" {
+		c.ProxyExport.Format = "proxysql"
+	}
+	if c.ProxyExport.Format != "proxysql" && c.ProxyExport.Format != "haproxy" {
+		return fmt.Errorf("proxy export format must be 'proxysql' or 'haproxy'")
+	}
+	if c.ProxyExport.Enabled && c.ProxyExport.OutputFile == "" && c.ProxyExport.AdminDSN == "" {
+		return fmt.Errorf("proxy export requires an output_file or admin_dsn")
+	}
+	if c.ProxyExport.AdminDSN != "" && c.ProxyExport.Format != "proxysql" {
+		return fmt.Errorf("proxy export admin_dsn requires the 'proxysql' format")
+	}
+	if c.ProxyExport.HostgroupBase == 0 {
+		c.ProxyExport.HostgroupBase = 100
+	}
+	if c.ProxyExport.RuleIDBase == 0 {
+		c.ProxyExport.RuleIDBase = 1000
+	}
+	if c.ProxyExport.BackendName == "" {
+		c.ProxyExport.BackendName = "mysql"
+	}
+	if c.ProxyExport.IntervalSeconds == 0 {
+		c.ProxyExport.IntervalSeconds = 10
+	}
+	if c.Capacity.Action == "" {
+		c.Capacity.Action = "none"
+	}
+	if c.Capacity.Action != "none" && c.Capacity.Action != "read_only" && c.Capacity.Action != "shed" {
+		return fmt.Errorf("capacity action must be 'none', 'read_only' or 'shed'")
+	}
+	if c.Capacity.HotTableCount == 0 {
+		c.Capacity.HotTableCount = 1
+	}
+	if c.Capacity.ShedRatio < 0 || c.Capacity.ShedRatio > 1 {
+		return fmt.Errorf("capacity shed ratio must be between 0 and 1")
+	}
+	if c.Capacity.Action == "shed" && c.Capacity.ShedRatio == 0 {
+		c.Capacity.ShedRatio = 0.5
+	}
+	if c.Cost.ShardHourlyCost < 0 || c.Cost.MonthlyBudget < 0 {
+		return fmt.Errorf("cost shard_hourly_cost and monthly_budget must not be negative")
+	}
+	for class, cost := range c.Cost.InstanceClassCosts {
+		if cost < 0 {
+			return fmt.Errorf("cost of instance class %s must not be negative", class)
+		}
+	}
+	for shardID, cost := range c.Cost.ShardCosts {
+		if cost < 0 {
+			return fmt.Errorf("cost of shard %s must not be negative", shardID)
+		}
+	}
+	if c.Cost.Currency == "" {
+		c.Cost.Currency = "USD"
+	}
+	for table, class := range c.Priorities.Tables {
+		if class != PriorityCritical && class != PriorityNormal && class != PriorityBatch {
+			return fmt.Errorf("priority of table %s must be %q, %q or %q", table, PriorityCritical, PriorityNormal, PriorityBatch)
+		}
+	}
+	defaultWeights := map[string]float64{PriorityCritical: 2, PriorityNormal: 1, PriorityBatch: 0.5}
+	if c.Priorities.Weights == nil {
+		c.Priorities.Weights = make(map[string]float64)
+	}
+	for class, weight := range c.Priorities.Weights {
+		if _, known := defaultWeights[class]; !known {
+			return fmt.Errorf("unknown priority class %q in weights", class)
+		}
+		if weight < 0 {
+			return fmt.Errorf("weight of priority class %s must not be negative", class)
+		}
+	}
+	for class, weight := range defaultWeights {
+		if _, set := c.Priorities.Weights[class]; !set {
+			c.Priorities.Weights[class] = weight
+		}
+	}
+	if c.Reconciler.IntervalSeconds == 0 {
+		c.Reconciler.IntervalSeconds = 60
+	}
+	if c.Reconciler.OrphanPolicy == "" {
+		c.Reconciler.OrphanPolicy = "report"
+	}
+	if c.Reconciler.OrphanPolicy != "adopt" && c.Reconciler.OrphanPolicy != "remove" && c.Reconciler.OrphanPolicy != "report" {
+		return fmt.Errorf("reconciler orphan policy must be 'adopt', 'remove' or 'report'")
+	}
+
+	if err := c.registerTLS(); err != nil {
+		return fmt.Errorf("database tls: %w", err)
+	}
+
+	// Apply configured DSN parameters to the initial shards
+	for shardID, dsn := range c.Shards {
+		withParams, err := ApplyDSNParams(dsn, c.DSNParamsFor(shardID))
+		if err != nil {
+			return fmt.Errorf("shard %s: %w", shardID, err)
+		}
+		c.Shards[shardID] = withParams
+	}
+	for shardID, dsns := range c.Database.Replicas {
+		if _, exists := c.Shards[shardID]; !exists {
+			return fmt.Errorf("replicas configured for unknown shard %s", shardID)
+		}
+		for i, dsn := range dsns {
+			withParams, err := ApplyDSNParams(dsn, c.DSNParamsFor(shardID))
+			if err != nil {
+				return fmt.Errorf("replica of shard %s: %w", shardID, err)
+			}
+			dsns[i] = withParams
+		}
+	}
 
+
+	if err := c.validateShardGroups(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// DSNParamsFor returns the DSN parameters that apply to the given shard
+func (c *Config) DSNParamsFor(shardID string) map[string]string {
+	return MergeDSNParams(c.Database.DSNParams, c.Database.ShardDSNParams[shardID])
+}
+
+// IsBroadcastTable reports whether a table is replicated to every shard
+func (c *Config) IsBroadcastTable(table string) bool {
+	for _, broadcast := range c.Broadcast.Tables {
+		if strings.EqualFold(broadcast, table) {
+			return true
+		}
+	}
+	return false
+}
+
+// TablePriority returns the priority class of a table, looked up by its
+// qualified name first
+func (c *Config) TablePriority(table string) string {
+	if class, exists := c.Priorities.Tables[table]; exists {
+		return class
+	}
+	if _, name, qualified := strings.Cut(table, "."); qualified {
+		if class, exists := c.Priorities.Tables[name]; exists {
+			return class
+		}
+	}
+	return PriorityNormal
+}
+
+// QualifiedTableNames returns all sharded tables, qualified with their database
+// ("db.table") when the table lives outside the shard's default database
+func (c *Config) QualifiedTableNames() []string {
+	tableNames := make([]string, 0, len(c.TableShardKeys))
+	for tableName := range c.TableShardKeys {
+		tableNames = append(tableNames, c.QualifiedTableName(tableName))
+	}
+	return tableNames
+}
+
+// QualifiedTableName returns a table's name as QualifiedTableNames lists it
+func (c *Config) QualifiedTableName(tableName string) string {
+	if databaseName, exists := c.TableDatabases[tableName]; exists && !strings.Contains(tableName, ".") {
+		return databaseName + "." + tableName
+	}
+	return tableName
+}
+
+// mapValues returns the values of a string map
+func mapValues(m map[string]string) []string {
+	values := make([]string, 0, len(m))
+	for _, value := range m {
+		values = append(values, value)
+	}
+	return values
+}
+
 // GetShardIDs returns a slice of all shard IDs
 func (c *Config) GetShardIDs() []string {
+	c.shardsMutex.RLock()
+	defer c.shardsMutex.RUnlock()
+
 	shardIDs := make([]string, 0, len(c.Shards))
 	for shardID := range c.Shards {
 		shardIDs = append(shardIDs, shardID)
 	}
 	return shardIDs
 }
+
+// GetShard returns the DSN of a shard
+func (c *Config) GetShard(shardID string) (string, bool) {
+	c.shardsMutex.RLock()
+	defer c.shardsMutex.RUnlock()
+
+	dsn, exists := c.Shards[shardID]
+	return dsn, exists
+}
+
+// SetShard records the DSN of an added or moved shard
+func (c *Config) SetShard(shardID, dsn string) {
+	c.shardsMutex.Lock()
+	defer c.shardsMutex.Unlock()
+
+	if c.Shards == nil {
+		c.Shards = make(map[string]string)
+	}
+	c.Shards[shardID] = dsn
+}
+
+// RemoveShard forgets a removed shard
+func (c *Config) RemoveShard(shardID string) {
+	c.shardsMutex.Lock()
+	defer c.shardsMutex.Unlock()
+
+	delete(c.Shards, shardID)
+}
+
+// ShardCount returns the number of shards
+func (c *Config) ShardCount() int {
+	c.shardsMutex.RLock()
+	defer c.shardsMutex.RUnlock()
+
+	return len(c.Shards)
+}
+
+// secretFields returns the configuration fields that may hold secret
+// references. Tenant API keys are named tenants.tenants.<tenant>.api_keys.<i>;
+// rotated ones take effect on restart.
+func (c *Config) secretFields() map[string]*string {
+	fields := map[string]*string{
+		"database.password":             &c.Database.Password,
+		"database.root_password":        &c.Database.RootPassword,
+		"database.replication.password": &c.Database.Replication.Password,
+		"routers.push_secret":           &c.Routers.PushSecret,
+		"audit.key":                     &c.Audit.Key,
+	}
+	for name, tenant := range c.Tenants.Tenants {
+		for i := range tenant.APIKeys {
+			fields[fmt.Sprintf("tenants.tenants.%s.api_keys.%d", name, i)] = &tenant.APIKeys[i]
+		}
+	}
+	return fields
+}
+
+// ResolveSecrets replaces secret references (e.g. vault:secret/db#password) with
+// their resolved values and remembers the references for later rotation. A
+// shard DSN's password may be a reference too, named shards.<shard ID>.
+func (c *Config) ResolveSecrets(resolver *secrets.Resolver) error {
+	c.secretsMutex.Lock()
+	defer c.secretsMutex.Unlock()
+
+	c.secretRefs = make(map[string]string)
+	c.secretValues = make(map[string]string)
+	c.secretDSNs = make(map[string]string)
+
+	for name, field := range c.secretFields() {
+		if !resolver.IsReference(*field) {
+			continue
+		}
+
+		value, err := resolver.Resolve(*field)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		c.secretRefs[name] = *field
+		c.secretValues[name] = value
+		*field = value
+	}
+
+	for _, shardID := range c.GetShardIDs() {
+		dsn, _ := c.GetShard(shardID)
+		ref, hasRef := dsnPassword(dsn)
+		if !hasRef || !resolver.IsReference(ref) {
+			continue
+		}
+
+		name := "shards." + shardID
+		value, err := resolver.Resolve(ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		resolved, err := withDSNPassword(dsn, value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		c.secretRefs[name] = ref
+		c.secretValues[name] = value
+		c.secretDSNs[shardID] = dsn
+		c.SetShard(shardID, resolved)
+	}
+
+	return nil
+}
+
+// SecretReferences returns the secret references resolved at startup keyed by field name
+func (c *Config) SecretReferences() map[string]string {
+	c.secretsMutex.RLock()
+	defer c.secretsMutex.RUnlock()
+
+	refs := make(map[string]string, len(c.secretRefs))
+	for name, ref := range c.secretRefs {
+		refs[name] = ref
+	}
+	return refs
+}
+
+// SecretValues returns the current resolved values of the referenced fields
+func (c *Config) SecretValues() map[string]string {
+	c.secretsMutex.RLock()
+	defer c.secretsMutex.RUnlock()
+
+	values := make(map[string]string, len(c.secretValues))
+	for name, value := range c.secretValues {
+		values[name] = value
+	}
+	return values
+}
+
+// ApplySecret updates a referenced field with a rotated value. A rotated
+// shard password updates the shard's DSN.
+func (c *Config) ApplySecret(name, value string) error {
+	c.secretsMutex.Lock()
+	defer c.secretsMutex.Unlock()
+
+	if shardID, isShard := strings.CutPrefix(name, "shards."); isShard {
+		dsn, exists := c.secretDSNs[shardID]
+		if !exists {
+			return fmt.Errorf("%s is not a referenced shard password", name)
+		}
+		resolved, err := withDSNPassword(dsn, value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		c.SetShard(shardID, resolved)
+		c.secretValues[name] = value
+		return nil
+	}
+
+	field, exists := c.secretFields()[name]
+	if !exists {
+		return fmt.Errorf("%s is not a secret field", name)
+	}
+	*field = value
+	c.secretValues[name] = value
+	return nil
+}
+
+// TopologyPushSecret returns the secret signing topology pushes, which
+// rotates while the services run
+func (c *Config) TopologyPushSecret() string {
+	c.secretsMutex.RLock()
+	defer c.secretsMutex.RUnlock()
+
+	return c.Routers.PushSecret
+}
+
+// DatabaseCredentials returns the database password and root password,
+// which rotate while the services run
+func (c *Config) DatabaseCredentials() (string, string) {
+	c.secretsMutex.RLock()
+	defer c.secretsMutex.RUnlock()
+
+	return c.Database.Password, c.Database.RootPassword
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MergeDSNParams combines global DSN parameters with per-shard overrides
+func MergeDSNParams(global, perShard map[string]string) map[string]string {
+	merged := make(map[string]string, len(global)+len(perShard))
+	for key, value := range global {
+		merged[key] = value
+	}
+	for key, value := range perShard {
+		merged[key] = value
+	}
+	return merged
+}
+
+// dsnParamEscaper escapes the characters of a DSN parameter value the driver
+// would otherwise read as a separator or unescape, leaving the value as
+// written otherwise (e.g. charset=utf8mb4,utf8)
+var dsnParamEscaper = strings.NewReplacer("%", "%25", "+", "%2B", "&", "%26", "/", "%2F")
+
+// ApplyDSNParams adds the given parameters to a MySQL DSN. Parameters already
+// present in the DSN take precedence over the supplied ones. The DSN's own
+// parameters are kept as written and the others appended in name order.
+func ApplyDSNParams(dsn string, params map[string]string) (string, error) {
+	if len(params) == 0 {
+		return dsn, nil
+	}
+	if _, err := mysql.ParseDSN(dsn); err != nil {
+		return "", fmt.Errorf("invalid DSN: %w", err)
+	}
+
+	// Like the driver, the parameters start at the first '?' after the last
+	// '/', since the password may contain either
+	slash := strings.LastIndex(dsn, "/")
+	if slash < 0 {
+		return "", fmt.Errorf("invalid DSN: missing the slash before the database name")
+	}
+	rawQuery, hasQuery := "", false
+	if idx := strings.IndexByte(dsn[slash:], '?'); idx >= 0 {
+		rawQuery, hasQuery = dsn[slash+idx+1:], true
+	}
+	present := make(map[string]bool)
+	for _, param := range strings.Split(rawQuery, "&") {
+		key, _, _ := strings.Cut(param, "=")
+		present[key] = true
+	}
+
+	var added []string
+	for key, value := range params {
+		if !present[key] {
+			added = append(added, key+"="+dsnParamEscaper.Replace(value))
+		}
+	}
+	if len(added) == 0 {
+		return dsn, nil
+	}
+	sort.Strings(added)
+
+	result := dsn
+	switch {
+	case !hasQuery:
+		result += "?"
+	case rawQuery != "" && !strings.HasSuffix(rawQuery, "&"):
+		result += "&"
+	}
+	result += strings.Join(added, "&")
+
+	// Let the driver validate the parameter values (parseTime, timeouts, ...)
+	if _, err := mysql.ParseDSN(result); err != nil {
+		return "", fmt.Errorf("invalid DSN after applying parameters: %w", err)
+	}
+
+	return result, nil
+}
+
+// dsnPassword returns the password of a MySQL DSN, if it has one
+func dsnPassword(dsn string) (string, bool) {
+	dsnConfig, err := mysql.ParseDSN(dsn)
+	if err != nil || dsnConfig.Passwd == "" {
+		return "", false
+	}
+	return dsnConfig.Passwd, true
+}
+
+// withDSNPassword returns a MySQL DSN with its password replaced
+func withDSNPassword(dsn, password string) (string, error) {
+	dsnConfig, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", fmt.Errorf("invalid DSN: %w", err)
+	}
+	dsnConfig.Passwd = password
+	return dsnConfig.FormatDSN(), nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import "testing"
+
+func TestApplyDSNParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		dsn     string
+		params  map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "no parameters",
+			dsn:    "app:secret@tcp(db:3306)/shop",
+			params: nil,
+			want:   "app:secret@tcp(db:3306)/shop",
+		},
+		{
+			name:   "commas kept, slashes escaped, in name order",
+			dsn:    "app:secret@tcp(db:3306)/shop",
+			params: map[string]string{"parseTime": "true", "charset": "utf8mb4,utf8", "loc": "Europe/Paris"},
+			want:   "app:secret@tcp(db:3306)/shop?charset=utf8mb4,utf8&loc=Europe%2FParis&parseTime=true",
+		},
+		{
+			name:   "DSN parameters win and keep their order",
+			dsn:    "app:secret@tcp(db:3306)/shop?timeout=5s&charset=latin1",
+			params: map[string]string{"charset": "utf8mb4", "readTimeout": "10s"},
+			want:   "app:secret@tcp(db:3306)/shop?timeout=5s&charset=latin1&readTimeout=10s",
+		},
+		{
+			name:   "every parameter already set",
+			dsn:    "app:secret@tcp(db:3306)/shop?parseTime=true",
+			params: map[string]string{"parseTime": "false"},
+			want:   "app:secret@tcp(db:3306)/shop?parseTime=true",
+		},
+		{
+			name:   "password with a question mark",
+			dsn:    "app:se?cr/et@tcp(db:3306)/shop",
+			params: map[string]string{"parseTime": "true"},
+			want:   "app:se?cr/et@tcp(db:3306)/shop?parseTime=true",
+		},
+		{
+			name:   "password with a question mark and parameters",
+			dsn:    "app:se?cret@tcp(db:3306)/shop?timeout=5s",
+			params: map[string]string{"parseTime": "true"},
+			want:   "app:se?cret@tcp(db:3306)/shop?timeout=5s&parseTime=true",
+		},
+		{
+			name:   "separators in a value are escaped",
+			dsn:    "app:secret@tcp(db:3306)/shop",
+			params: map[string]string{"loc": "Etc/GMT+3"},
+			want:   "app:secret@tcp(db:3306)/shop?loc=Etc%2FGMT%2B3",
+		},
+		{
+			name:    "invalid value",
+			dsn:     "app:secret@tcp(db:3306)/shop",
+			params:  map[string]string{"parseTime": "sometimes"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid DSN",
+			dsn:     "app:secret@tcp(db:3306)",
+			params:  map[string]string{"parseTime": "true"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := ApplyDSNParams(test.dsn, test.params)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: ApplyDSNParams(%q) = %q, want an error", test.name, test.dsn, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ApplyDSNParams(%q): %v", test.name, test.dsn, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: ApplyDSNParams(%q) = %q, want %q", test.name, test.dsn, got, test.want)
+		}
+	}
+}
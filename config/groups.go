@@ -0,0 +1,150 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// groupNamePattern restricts shard group names to what can suffix a file name
+var groupNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// groupOwnKeys are the settings a shard group never inherits: its shards and
+// tables are its own, and so is the ring layout saved from its shards
+var groupOwnKeys = []string{"shards", "table_shard_keys", "table_databases", "shard_labels", "shard_groups", "ring"}
+
+// ShardGroupNames returns the names of the configured shard groups, sorted
+func (c *Config) ShardGroupNames() []string {
+	names := make([]string, 0, len(c.ShardGroups))
+	for name := range c.ShardGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ShardGroup returns the configuration of a shard group: this configuration
+// with the group's settings merged over it the way a profile overlay is,
+// except that the group's shards, tables and ring layout replace the base
+// ones instead of merging with them. Files the group would otherwise share
+// with the base (state snapshot, decision log, audit log, query log and
+// proxy export) get the group name inserted before their extension.
+func (c *Config) ShardGroup(name string) (*Config, error) {
+	raw, exists := c.ShardGroups[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown shard group %s", name)
+	}
+
+	encoded, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode base config: %w", err)
+	}
+	base, err := decodeConfigObject(encoded)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range groupOwnKeys {
+		delete(base, key)
+	}
+	overlay, err := decodeConfigObject(raw)
+	if err != nil {
+		return nil, fmt.Errorf("shard group %s: %w", name, err)
+	}
+
+	merged, err := json.Marshal(mergeConfigObjects(base, overlay))
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge shard group %s: %w", name, err)
+	}
+	var group Config
+	if err := json.NewDecoder(bytes.NewReader(merged)).Decode(&group); err != nil {
+		return nil, fmt.Errorf("failed to decode shard group %s: %w", name, err)
+	}
+	group.Profile = c.Profile
+	group.Group = name
+
+	for _, path := range []struct {
+		group *string
+		base  string
+	}{
+		{&group.State.SnapshotPath, c.State.SnapshotPath},
+		{&group.Transactions.DecisionLogPath, c.Transactions.DecisionLogPath},
+		{&group.Audit.Path, c.Audit.Path},
+		{&group.QueryLog.Output, c.QueryLog.Output},
+		{&group.ProxyExport.OutputFile, c.ProxyExport.OutputFile},
+	} {
+		if *path.group == path.base && path.base != "" && path.base != "stdout" {
+			*path.group = ProfilePath(path.base, name)
+		}
+	}
+	// Mirrors keep their distance from the group's shard ports
+	if group.Mirrors.BasePort == c.Mirrors.BasePort {
+		group.Mirrors.BasePort += group.Ports.BasePort - c.Ports.BasePort
+	}
+
+	if err := group.validate(); err != nil {
+		return nil, fmt.Errorf("invalid shard group %s: %w", name, err)
+	}
+	return &group, nil
+}
+
+// validateShardGroups checks every shard group can run alongside the base
+// configuration and the other groups in one process: each table belongs to
+// one group, and no two groups share a port or container prefix
+func (c *Config) validateShardGroups() error {
+	tableGroups := make(map[string]string, len(c.TableShardKeys))
+	for table := range c.TableShardKeys {
+		tableGroups[table] = "the default group"
+	}
+	resources := map[string]string{
+		fmt.Sprintf("query router port %d", c.Ports.QueryRouterPort): "the default group",
+		fmt.Sprintf("coordinator port %d", c.Ports.CoordinatorPort):  "the default group",
+		fmt.Sprintf("base port %d", c.Ports.BasePort):                "the default group",
+		fmt.Sprintf("container prefix %q", c.Docker.ContainerPrefix): "the default group",
+	}
+
+	for _, name := range c.ShardGroupNames() {
+		if !groupNamePattern.MatchString(name) {
+			return fmt.Errorf("shard group name %q may only contain letters, digits, '-' and '_'", name)
+		}
+		group, err := c.ShardGroup(name)
+		if err != nil {
+			return err
+		}
+
+		owner := "shard group " + name
+		for table := range group.TableShardKeys {
+			if other, exists := tableGroups[table]; exists {
+				return fmt.Errorf("table %s belongs to both %s and %s", table, other, owner)
+			}
+			tableGroups[table] = owner
+		}
+		for _, resource := range []string{
+			fmt.Sprintf("query router port %d", group.Ports.QueryRouterPort),
+			fmt.Sprintf("coordinator port %d", group.Ports.CoordinatorPort),
+			fmt.Sprintf("base port %d", group.Ports.BasePort),
+			fmt.Sprintf("container prefix %q", group.Docker.ContainerPrefix),
+		} {
+			if other, exists := resources[resource]; exists {
+				return fmt.Errorf("%s uses the %s of %s; give it its own", owner, resource, other)
+			}
+			resources[resource] = owner
+		}
+	}
+	return nil
+}
+
+// decodeConfigObject decodes a JSON object, keeping numbers as json.Number
+func decodeConfigObject(data []byte) (map[string]interface{}, error) {
+	var object map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("failed to decode config object: %w", err)
+	}
+	if object == nil {
+		return nil, fmt.Errorf("config is not a JSON object")
+	}
+	return object, nil
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProfilePath returns the overlay file for a profile, next to the base file
+// (config.json with profile "prod" is config.prod.json)
+func ProfilePath(filename, profile string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "." + profile + ext
+}
+
+// LoadConfigProfile loads the base configuration file merged with the
+// overlay of a profile. Objects in the overlay merge key by key into the
+// base; any other value, arrays included, replaces the base value, and null
+// removes it. An empty profile loads the base file alone.
+func LoadConfigProfile(filename, profile string) (*Config, error) {
+	base, err := readConfigObject(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile != "" {
+		overlay, err := readConfigObject(ProfilePath(filename, profile))
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", profile, err)
+		}
+		base = mergeConfigObjects(base, overlay)
+	}
+
+	merged, err := json.Marshal(base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge config: %w", err)
+	}
+
+	var config Config
+	if err := json.NewDecoder(bytes.NewReader(merged)).Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+	config.Profile = profile
+
+	// Validate configuration
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return &config, nil
+}
+
+// readConfigObject reads a configuration file holding a JSON object
+func readConfigObject(filename string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+
+	// Numbers stay json.Number so large integers survive the merge
+	var object map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("failed to decode config %s: %w", filename, err)
+	}
+	if object == nil {
+		return nil, fmt.Errorf("config %s is not a JSON object", filename)
+	}
+	return object, nil
+}
+
+// mergeConfigObjects merges an overlay into a base object
+func mergeConfigObjects(base, overlay map[string]interface{}) map[string]interface{} {
+	for key, value := range overlay {
+		if value == nil {
+			delete(base, key)
+			continue
+		}
+		overlayObject, isObject := value.(map[string]interface{})
+		baseObject, baseIsObject := base[key].(map[string]interface{})
+		if isObject && baseIsObject {
+			base[key] = mergeConfigObjects(baseObject, overlayObject)
+			continue
+		}
+		base[key] = value
+	}
+	return base
+}
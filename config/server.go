@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ServerConfig tunes the query router's HTTP server so slow or idle clients
+// can't hold connections open indefinitely. Timeouts are in seconds.
+type ServerConfig struct {
+	ReadTimeoutSeconds       int `json:"read_timeout_seconds"`
+	ReadHeaderTimeoutSeconds int `json:"read_header_timeout_seconds"`
+	// WriteTimeoutSeconds bounds writing a response, so it must exceed the
+	// longest query the router should answer
+	WriteTimeoutSeconds int `json:"write_timeout_seconds"`
+	// IdleTimeoutSeconds is how long a keep-alive connection may wait for
+	// its next request
+	IdleTimeoutSeconds int `json:"idle_timeout_seconds"`
+	MaxHeaderBytes     int `json:"max_header_bytes"`
+	// H2C serves HTTP/2 without TLS next to HTTP/1.1, letting internal
+	// clients multiplex requests over a few connections
+	H2C bool `json:"h2c"`
+	// MaxConcurrentStreams limits the HTTP/2 requests in flight on one
+	// connection
+	MaxConcurrentStreams int `json:"max_concurrent_streams"`
+}
+
+// validate applies the server defaults and rejects negative settings
+func (sc *ServerConfig) validate() error {
+	settings := []struct {
+		name     string
+		value    *int
+		fallback int
+	}{
+		{"read_timeout_seconds", &sc.ReadTimeoutSeconds, 30},
+		{"read_header_timeout_seconds", &sc.ReadHeaderTimeoutSeconds, 10},
+		{"write_timeout_seconds", &sc.WriteTimeoutSeconds, 120},
+		{"idle_timeout_seconds", &sc.IdleTimeoutSeconds, 120},
+		{"max_header_bytes", &sc.MaxHeaderBytes, http.DefaultMaxHeaderBytes},
+		{"max_concurrent_streams", &sc.MaxConcurrentStreams, 250},
+	}
+	for _, setting := range settings {
+		if *setting.value < 0 {
+			return fmt.Errorf("http server %s must not be negative", setting.name)
+		}
+		if *setting.value == 0 {
+			*setting.value = setting.fallback
+		}
+	}
+	return nil
+}
+
+// HTTPServer builds the query router's server for addr with the configured
+// timeouts and protocols
+func (c *Config) HTTPServer(addr string, handler http.Handler) *http.Server {
+	sc := c.HTTP.Server
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(sc.H2C)
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       time.Duration(sc.ReadTimeoutSeconds) * time.Second,
+		ReadHeaderTimeout: time.Duration(sc.ReadHeaderTimeoutSeconds) * time.Second,
+		WriteTimeout:      time.Duration(sc.WriteTimeoutSeconds) * time.Second,
+		IdleTimeout:       time.Duration(sc.IdleTimeoutSeconds) * time.Second,
+		MaxHeaderBytes:    sc.MaxHeaderBytes,
+		Protocols:         protocols,
+		HTTP2: &http.HTTP2Config{
+			MaxConcurrentStreams: sc.MaxConcurrentStreams,
+		},
+	}
+}
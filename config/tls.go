@@ -0,0 +1,161 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TLSConfig encrypts connections to shard databases. Setting any field other
+// than SkipVerify enables TLS.
+type TLSConfig struct {
+	Enabled bool `json:"enabled"`
+	// CACert is a PEM file of the CAs trusted to sign shard certificates;
+	// the system roots are used when empty
+	CACert string `json:"ca_cert"`
+	// ClientCert and ClientKey are PEM files authenticating to the shard
+	ClientCert string `json:"client_cert"`
+	ClientKey  string `json:"client_key"`
+	// SkipVerify accepts any shard certificate, still encrypting traffic
+	SkipVerify bool `json:"skip_verify"`
+	// ServerName is the name verified in shard certificates, by default the
+	// host of the DSN
+	ServerName string `json:"server_name"`
+}
+
+// active reports whether the configuration enables TLS
+func (t TLSConfig) active() bool {
+	return t.Enabled || t.CACert != "" || t.ClientCert != "" || t.ServerName != ""
+}
+
+// tlsConfigName is the name shard TLS configurations are registered under
+// with the MySQL driver; per-shard overrides append the shard ID
+const tlsConfigName = "shards"
+
+var (
+	registeredTLS      = make(map[string]TLSConfig)
+	registeredTLSMutex sync.RWMutex
+)
+
+// registerTLS registers the shard TLS configurations with the MySQL driver
+// and adds the "tls" DSN parameter selecting them, so configured shards,
+// replicas and dynamically provisioned shards all connect over TLS. An
+// explicit "tls" DSN parameter takes precedence.
+func (c *Config) registerTLS() error {
+	if c.Database.TLS.active() {
+		if err := registerTLSConfig(tlsConfigName, c.Database.TLS); err != nil {
+			return err
+		}
+		if _, exists := c.Database.DSNParams["tls"]; !exists {
+			if c.Database.DSNParams == nil {
+				c.Database.DSNParams = make(map[string]string)
+			}
+			c.Database.DSNParams["tls"] = tlsConfigName
+		}
+	}
+
+	for shardID, shardTLS := range c.Database.ShardTLS {
+		name := "false"
+		if shardTLS.active() {
+			name = tlsConfigName + "-" + shardID
+			if err := registerTLSConfig(name, shardTLS); err != nil {
+				return fmt.Errorf("shard %s: %w", shardID, err)
+			}
+		}
+		if c.Database.ShardDSNParams == nil {
+			c.Database.ShardDSNParams = make(map[string]map[string]string)
+		}
+		if c.Database.ShardDSNParams[shardID] == nil {
+			c.Database.ShardDSNParams[shardID] = make(map[string]string)
+		}
+		if _, exists := c.Database.ShardDSNParams[shardID]["tls"]; !exists {
+			c.Database.ShardDSNParams[shardID]["tls"] = name
+		}
+	}
+	return nil
+}
+
+// registerTLSConfig loads the certificates of a TLS configuration and
+// registers it with the MySQL driver under a name
+func registerTLSConfig(name string, t TLSConfig) error {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: t.SkipVerify,
+		ServerName:         t.ServerName,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if t.CACert != "" {
+		pem, err := os.ReadFile(t.CACert)
+		if err != nil {
+			return fmt.Errorf("failed to read TLS CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in TLS CA file %s", t.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (t.ClientCert == "") != (t.ClientKey == "") {
+		return fmt.Errorf("TLS client_cert and client_key must be set together")
+	}
+	if t.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if err := mysql.RegisterTLSConfig(name, tlsConfig); err != nil {
+		return fmt.Errorf("failed to register TLS configuration: %w", err)
+	}
+
+	registeredTLSMutex.Lock()
+	registeredTLS[name] = t
+	registeredTLSMutex.Unlock()
+	return nil
+}
+
+// MySQLClientTLSArgs returns the mysql and mysqldump flags matching the tls
+// parameter of a DSN, so command line tools encrypt like the driver does
+func MySQLClientTLSArgs(dsn string) []string {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil
+	}
+
+	// The tools only verify certificates against an explicit CA file
+	switch cfg.TLSConfig {
+	case "", "false":
+		return nil
+	case "preferred":
+		return []string{"--ssl-mode=PREFERRED"}
+	}
+
+	registeredTLSMutex.RLock()
+	t, exists := registeredTLS[cfg.TLSConfig]
+	registeredTLSMutex.RUnlock()
+
+	var args []string
+	switch {
+	case !exists || t.SkipVerify || t.CACert == "":
+		args = append(args, "--ssl-mode=REQUIRED")
+	case t.ServerName != "":
+		// The tools verify the DSN host, not an overridden name
+		args = append(args, "--ssl-mode=VERIFY_CA")
+	default:
+		args = append(args, "--ssl-mode=VERIFY_IDENTITY")
+	}
+	if t.CACert != "" {
+		args = append(args, "--ssl-ca="+t.CACert)
+	}
+	if t.ClientCert != "" {
+		args = append(args, "--ssl-cert="+t.ClientCert, "--ssl-key="+t.ClientKey)
+	}
+	return args
+}
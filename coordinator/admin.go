@@ -0,0 +1,124 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// MoveKeyRequest is the body of POST /admin/move-key. Either Key or both
+// RangeStart and RangeEnd must be set.
+type MoveKeyRequest struct {
+	Table       string `json:"table"`
+	Key         string `json:"key,omitempty"`
+	RangeStart  *int64 `json:"range_start,omitempty"`
+	RangeEnd    *int64 `json:"range_end,omitempty"`
+	Destination string `json:"destination"`
+}
+
+// handleMoveKey handles POST /admin/move-key, moving a key or key range of a
+// table to another shard and routing it there
+func (c *Coordinator) handleMoveKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req MoveKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+		return
+	}
+
+	keyColumn, exists := c.config.TableShardKeys[req.Table]
+	if !exists {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("table %s has no shard key configured", req.Table)})
+		return
+	}
+
+	entry := sharding.DirectoryEntry{
+		Table:      req.Table,
+		Key:        req.Key,
+		RangeStart: req.RangeStart,
+		RangeEnd:   req.RangeEnd,
+		ShardID:    req.Destination,
+	}
+	if err := entry.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	result, err := c.shardManager.MoveKeys(entry, keyColumn)
+	if err != nil {
+		c.persistDirectory()
+		log.Printf("❌ Failed to move %s keys to %s: %v", req.Table, req.Destination, err)
+		c.recordEvent(ScalingEvent{Target: req.Table, Reason: "move_key", ShardID: req.Destination, Status: "failed", Error: err.Error()})
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+
+	c.persistDirectory()
+	c.markStatsStale(req.Destination)
+	for shardID := range result.RowsMoved {
+		c.markStatsStale(shardID)
+	}
+	c.recordEvent(ScalingEvent{Target: req.Table, Reason: "move_key", ShardID: req.Destination, Status: "completed"})
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleDirectory handles GET/PUT/DELETE /directory requests. PUT installs or
+// replaces an override; DELETE removes the override for the same key or range.
+func (c *Coordinator) handleDirectory(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, c.shardManager.GetDirectory())
+		return
+	}
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var entry sharding.DirectoryEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if !c.shardManager.RemoveDirectoryEntry(entry) {
+			http.Error(w, "No matching directory entry", http.StatusNotFound)
+			return
+		}
+		c.persistDirectory()
+		log.Printf("📒 Removed routing override for %s %s", entry.Table, describeEntry(entry))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := c.shardManager.SetDirectoryEntry(entry); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	c.persistDirectory()
+	log.Printf("📒 Routing %s %s to %s", entry.Table, describeEntry(entry), entry.ShardID)
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// persistDirectory saves a snapshot right away so directory changes survive a
+// restart without waiting for the next snapshot interval
+func (c *Coordinator) persistDirectory() {
+	if err := c.saveSnapshot(); err != nil {
+		log.Printf("Warning: Failed to persist routing directory: %v", err)
+	}
+}
+
+// describeEntry formats the key or range of a directory entry for logs
+func describeEntry(entry sharding.DirectoryEntry) string {
+	if entry.IsRange() && entry.RangeStart != nil && entry.RangeEnd != nil {
+		return fmt.Sprintf("keys %d-%d", *entry.RangeStart, *entry.RangeEnd)
+	}
+	return fmt.Sprintf("key %s", entry.Key)
+}
@@ -0,0 +1,371 @@
+package coordinator
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"sql-horizontal-autoscaler/datastore"
+	"sql-horizontal-autoscaler/parser"
+)
+
+// Index recommendation statuses
+const (
+	IndexPending  = "pending"
+	IndexApproved = "approved"
+	IndexApplied  = "applied"
+	IndexFailed   = "failed"
+)
+
+// maxIndexColumns caps the columns of a recommended composite index
+const maxIndexColumns = 3
+
+// IndexRecommendation is a missing index found by the advisor. Its ID is the
+// name of the index it would create.
+type IndexRecommendation struct {
+	ID      string   `json:"id"`
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"`
+	// Reason is "shard_key" for an unindexed shard key and "filter" for
+	// columns filtered by expensive statements
+	Reason       string     `json:"reason"`
+	Shards       []string   `json:"shards"`
+	Executions   int64      `json:"executions"`
+	RowsExamined int64      `json:"rows_examined"`
+	RowsSent     int64      `json:"rows_sent"`
+	SampleQuery  string     `json:"sample_query,omitempty"`
+	DDL          string     `json:"ddl"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	AppliedAt    *time.Time `json:"applied_at,omitempty"`
+}
+
+// handleIndexAdvice handles GET /advisor/indexes
+func (c *Coordinator) handleIndexAdvice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c.advisorMutex.RLock()
+	recommendations := make([]IndexRecommendation, 0, len(c.indexAdvice))
+	for _, rec := range c.indexAdvice {
+		recommendations = append(recommendations, *rec)
+	}
+	response := map[string]interface{}{
+		"enabled":         c.config.IndexAdvisor.Enabled,
+		"auto_apply":      c.config.IndexAdvisor.AutoApply,
+		"last_run":        c.advisorLastRun,
+		"recommendations": recommendations,
+	}
+	if len(c.advisorErrors) > 0 {
+		response["errors"] = c.advisorErrors
+	}
+	c.advisorMutex.RUnlock()
+
+	sort.Slice(recommendations, func(i, j int) bool {
+		return recommendations[i].RowsExamined > recommendations[j].RowsExamined
+	})
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleIndexAdviceRoutes handles POST /advisor/indexes/{id}/approve and
+// POST /advisor/indexes/{id}/apply
+func (c *Coordinator) handleIndexAdviceRoutes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/advisor/indexes/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, action := parts[0], parts[1]
+	c.advisorMutex.Lock()
+	rec, exists := c.indexAdvice[id]
+	if exists && (action == "approve" || action == "apply") {
+		rec.Status = IndexApproved
+		rec.Error = ""
+	}
+	c.advisorMutex.Unlock()
+	if !exists {
+		http.Error(w, "Unknown index recommendation "+id, http.StatusNotFound)
+		return
+	}
+
+	switch action {
+	case "approve":
+		log.Printf("📇 Index %s approved", id)
+		writeJSON(w, http.StatusOK, c.indexRecommendation(id))
+	case "apply":
+		if err := c.applyIndex(id); err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]interface{}{"error": err.Error(), "recommendation": c.indexRecommendation(id)})
+			return
+		}
+		writeJSON(w, http.StatusOK, c.indexRecommendation(id))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// indexRecommendation returns a copy of a recommendation
+func (c *Coordinator) indexRecommendation(id string) IndexRecommendation {
+	c.advisorMutex.RLock()
+	defer c.advisorMutex.RUnlock()
+	return *c.indexAdvice[id]
+}
+
+// indexAdvisorLoop periodically analyzes statement digests for missing
+// indexes and applies approved ones when auto-apply is enabled
+func (c *Coordinator) indexAdvisorLoop() {
+	ticker := time.NewTicker(time.Duration(c.config.IndexAdvisor.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			c.adviseIndexes()
+			if !c.config.IndexAdvisor.AutoApply {
+				continue
+			}
+			for _, id := range c.approvedIndexes() {
+				if err := c.applyIndex(id); err != nil {
+					log.Printf("Warning: Failed to apply index %s: %v", id, err)
+				}
+			}
+		}
+	}
+}
+
+// adviseIndexes rebuilds the recommendations from every shard's digests,
+// keeping the status of recommendations already approved or applied
+func (c *Coordinator) adviseIndexes() {
+	found := make(map[string]*IndexRecommendation)
+	shardErrors := make(map[string]string)
+
+	for _, shardID := range c.dataStore.ShardIDs() {
+		digests, err := c.dataStore.StatementDigests(shardID, c.config.IndexAdvisor.MaxDigests)
+		if err != nil {
+			shardErrors[shardID] = err.Error()
+			continue
+		}
+		if err := c.adviseShard(shardID, digests, found); err != nil {
+			shardErrors[shardID] = err.Error()
+		}
+	}
+
+	c.advisorMutex.Lock()
+	defer c.advisorMutex.Unlock()
+
+	for id, previous := range c.indexAdvice {
+		rec, exists := found[id]
+		switch {
+		case exists:
+			rec.Status, rec.Error, rec.AppliedAt = previous.Status, previous.Error, previous.AppliedAt
+		case previous.Status != IndexPending:
+			found[id] = previous
+		}
+	}
+	for id, rec := range found {
+		if rec.Status == "" {
+			rec.Status = IndexPending
+			log.Printf("📇 Index advisor recommends %s: %s", id, rec.DDL)
+		}
+	}
+	c.indexAdvice = found
+	c.advisorErrors = shardErrors
+	c.advisorLastRun = time.Now()
+}
+
+// adviseShard adds the recommendations for one shard's digests to found
+func (c *Coordinator) adviseShard(shardID string, digests []datastore.StatementDigest, found map[string]*IndexRecommendation) error {
+	indexes := make(map[string]map[string]map[string][]string)
+	for _, digest := range digests {
+		if digest.Executions < c.config.IndexAdvisor.MinExecutions {
+			continue
+		}
+		filters, err := parser.Filters(digest.DigestText)
+		if err != nil || len(filters) == 0 {
+			// Digests of other statement types or truncated text
+			continue
+		}
+
+		if indexes[digest.Database] == nil {
+			existing, err := c.dataStore.IndexedColumns(shardID, digest.Database)
+			if err != nil {
+				return err
+			}
+			indexes[digest.Database] = existing
+		}
+
+		expensive := digest.NoIndexUsed > 0 || digest.NoGoodIndex > 0 ||
+			float64(digest.RowsExamined)/float64(max(digest.RowsSent, 1)) >= c.config.IndexAdvisor.MinExaminedPerRow
+
+		for table, columns := range groupFilters(filters) {
+			existing := indexes[digest.Database][table]
+			if shardKey, sharded := c.config.TableShardKeys[table]; sharded && !indexLeadsWith(existing, shardKey) {
+				addIndexRecommendation(found, shardID, table, []string{shardKey}, "shard_key", digest)
+			}
+			if expensive && !indexLeadsWith(existing, columns[0]) {
+				addIndexRecommendation(found, shardID, table, columns, "filter", digest)
+			}
+		}
+	}
+	return nil
+}
+
+// groupFilters orders each table's filtered columns for a composite index:
+// equality columns first, then a single range column
+func groupFilters(filters []parser.Filter) map[string][]string {
+	grouped := make(map[string][]string)
+	for _, filter := range filters {
+		if filter.Equality && !containsString(grouped[filter.Table], filter.Column) {
+			grouped[filter.Table] = append(grouped[filter.Table], filter.Column)
+		}
+	}
+	for _, filter := range filters {
+		columns := grouped[filter.Table]
+		if filter.Equality || containsString(columns, filter.Column) {
+			continue
+		}
+		if !hasRange(filters, filter.Table, columns) {
+			grouped[filter.Table] = append(columns, filter.Column)
+		}
+	}
+	for table, columns := range grouped {
+		if len(columns) > maxIndexColumns {
+			grouped[table] = columns[:maxIndexColumns]
+		}
+	}
+	return grouped
+}
+
+// hasRange reports whether columns already include a range column of table
+func hasRange(filters []parser.Filter, table string, columns []string) bool {
+	for _, filter := range filters {
+		if filter.Table == table && !filter.Equality && containsString(columns, filter.Column) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexLeadsWith reports whether any index starts with column
+func indexLeadsWith(indexes map[string][]string, column string) bool {
+	for _, columns := range indexes {
+		if len(columns) > 0 && strings.EqualFold(columns[0], column) {
+			return true
+		}
+	}
+	return false
+}
+
+// addIndexRecommendation merges a digest's evidence into the recommendation
+// for an index, creating it if needed
+func addIndexRecommendation(found map[string]*IndexRecommendation, shardID, table string, columns []string, reason string, digest datastore.StatementDigest) {
+	id := indexName(table, columns)
+	rec, exists := found[id]
+	if !exists {
+		quoted := make([]string, len(columns))
+		for i, column := range columns {
+			quoted[i] = "`" + column + "`"
+		}
+		rec = &IndexRecommendation{
+			ID:          id,
+			Table:       table,
+			Columns:     columns,
+			Reason:      reason,
+			SampleQuery: digest.DigestText,
+			DDL: fmt.Sprintf("CREATE INDEX `%s` ON `%s` (%s) ALGORITHM=INPLACE LOCK=NONE",
+				id, table, strings.Join(quoted, ", ")),
+		}
+		found[id] = rec
+	}
+	if !containsString(rec.Shards, shardID) {
+		rec.Shards = append(rec.Shards, shardID)
+	}
+	rec.Executions += digest.Executions
+	rec.RowsExamined += digest.RowsExamined
+	rec.RowsSent += digest.RowsSent
+}
+
+// indexName builds the name of a recommended index, within MySQL's 64
+// character identifier limit
+func indexName(table string, columns []string) string {
+	name := "idx_" + table + "_" + strings.Join(columns, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// approvedIndexes lists the IDs of approved recommendations
+func (c *Coordinator) approvedIndexes() []string {
+	c.advisorMutex.RLock()
+	defer c.advisorMutex.RUnlock()
+
+	var ids []string
+	for id, rec := range c.indexAdvice {
+		if rec.Status == IndexApproved {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// applyIndex creates a recommended index on every shard. Shards that already
+// have it count as applied; new shards inherit it through the schema copy.
+func (c *Coordinator) applyIndex(id string) error {
+	c.advisorMutex.RLock()
+	rec := *c.indexAdvice[id]
+	c.advisorMutex.RUnlock()
+
+	database := c.config.TableDatabases[rec.Table]
+	var failures []string
+	for _, shardID := range c.dataStore.ShardIDs() {
+		_, err := c.dataStore.ExecuteInternalWrite(rec.DDL, shardID, database)
+		var mysqlErr *mysql.MySQLError
+		if err != nil && !(errors.As(err, &mysqlErr) && mysqlErr.Number == 1061) {
+			failures = append(failures, fmt.Sprintf("%s: %v", shardID, err))
+		}
+	}
+
+	c.advisorMutex.Lock()
+	defer c.advisorMutex.Unlock()
+	current, exists := c.indexAdvice[id]
+	if !exists {
+		return nil
+	}
+	if len(failures) > 0 {
+		current.Status = IndexFailed
+		current.Error = strings.Join(failures, "; ")
+		return fmt.Errorf("failed to create index %s on %d shards: %s", id, len(failures), current.Error)
+	}
+
+	now := time.Now()
+	current.Status = IndexApplied
+	current.Error = ""
+	current.AppliedAt = &now
+	log.Printf("📇 Created index %s on every shard", id)
+	return nil
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package coordinator
+
+import (
+	"log"
+	"time"
+)
+
+// collectionBackoff tracks a shard whose metrics collection keeps failing
+type collectionBackoff struct {
+	failures int
+	next     time.Time
+	// probing is set while a collection attempt is in flight
+	probing bool
+}
+
+// collectionDue reports whether a shard's metrics should be collected this
+// cycle and whether its collection is failing. A failing shard is due once
+// its backoff has expired and no earlier attempt is still in flight.
+func (c *Coordinator) collectionDue(shardID string) (bool, bool) {
+	c.backoffMutex.Lock()
+	defer c.backoffMutex.Unlock()
+
+	b, failing := c.backoff[shardID]
+	if !failing {
+		return true, false
+	}
+	if b.probing || time.Now().Before(b.next) {
+		return false, true
+	}
+	b.probing = true
+	return true, true
+}
+
+// collectShard collects and stores a shard's metrics after delay, holding a
+// slot of semaphore while collecting when one is given
+func (c *Coordinator) collectShard(shardID string, delay time.Duration, semaphore chan struct{}) {
+	select {
+	case <-c.stopChan:
+		return
+	case <-time.After(delay):
+	}
+
+	if semaphore != nil {
+		semaphore <- struct{}{}
+		defer func() { <-semaphore }()
+	}
+
+	shardMetrics, err := c.dataStore.GetShardMetrics(shardID)
+	c.recordCollection(shardID, err)
+	if err != nil {
+		log.Printf("Failed to get metrics for shard %s: %v", shardID, err)
+		return
+	}
+
+	shardMetrics.SelectCount += c.dataStore.ReplicaSelectCount(shardID)
+	shardMetrics.Replicas = c.dataStore.ReplicaStatuses(shardID)
+	applyRates := c.overlayIngested(shardMetrics)
+	queryErrors := c.shardQueryErrors(shardID)
+
+	c.mutex.Lock()
+	previous, exists := c.metrics[shardID]
+	if exists {
+		setEntryGrowth(shardMetrics, previous)
+		setReadRate(shardMetrics, previous)
+	}
+	setQueryErrors(shardMetrics, previous, queryErrors)
+	applyRates()
+	c.setShardMetrics(shardID, shardMetrics)
+	c.mutex.Unlock()
+}
+
+// recordCollection updates a shard's backoff after a collection attempt:
+// each consecutive failure doubles the wait before the next attempt, from
+// one monitoring interval up to the configured maximum, and a success
+// clears it
+func (c *Coordinator) recordCollection(shardID string, err error) {
+	c.backoffMutex.Lock()
+	defer c.backoffMutex.Unlock()
+
+	b, failing := c.backoff[shardID]
+	if err == nil {
+		if failing {
+			log.Printf("✅ Metrics collection from shard %s succeeded after %d failures", shardID, b.failures)
+			delete(c.backoff, shardID)
+		}
+		return
+	}
+
+	if !failing {
+		b = &collectionBackoff{}
+		c.backoff[shardID] = b
+	}
+	b.failures++
+	b.probing = false
+
+	wait := time.Duration(c.config.MonitoringIntervalSeconds) * time.Second
+	limit := time.Duration(c.config.Monitoring.BackoffMaxSeconds) * time.Second
+	for i := 1; i < b.failures && wait < limit; i++ {
+		wait *= 2
+	}
+	if wait > limit {
+		wait = limit
+	}
+	b.next = time.Now().Add(wait)
+	log.Printf("⏳ Backing off metrics collection from shard %s for %s after %d failures", shardID, wait, b.failures)
+}
+
+// pruneBackoff forgets the backoff of shards no longer in the cluster
+func (c *Coordinator) pruneBackoff(shardIDs []string) {
+	current := make(map[string]bool, len(shardIDs))
+	for _, shardID := range shardIDs {
+		current[shardID] = true
+	}
+
+	c.backoffMutex.Lock()
+	defer c.backoffMutex.Unlock()
+	for shardID := range c.backoff {
+		if !current[shardID] {
+			delete(c.backoff, shardID)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package coordinator
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"sql-horizontal-autoscaler/metrics"
+)
+
+// minRelativeSpread keeps a flat baseline (near-zero variance) from turning
+// every small fluctuation into a scaling trigger
+const minRelativeSpread = 0.05
+
+// Baseline is an exponentially weighted moving average and variance of a
+// shard metric, optionally for a single hour of the day
+type Baseline struct {
+	Mean        float64   `json:"mean"`
+	Variance    float64   `json:"variance"`
+	Samples     int       `json:"samples"`
+	LastUpdated time.Time `json:"last_updated"`
+}
+
+// update folds a new observation into the baseline. The weight of older
+// observations halves every halfLife.
+func (b *Baseline) update(value float64, now time.Time, halfLife time.Duration) {
+	if b.Samples == 0 {
+		b.Mean = value
+		b.Variance = 0
+		b.Samples = 1
+		b.LastUpdated = now
+		return
+	}
+
+	alpha := 1 - math.Pow(0.5, float64(now.Sub(b.LastUpdated))/float64(halfLife))
+	diff := value - b.Mean
+	increment := alpha * diff
+	b.Mean += increment
+	b.Variance = (1 - alpha) * (b.Variance + diff*increment)
+	b.Samples++
+	b.LastUpdated = now
+}
+
+// threshold returns the value above which an observation deviates from the
+// baseline by more than factor standard deviations
+func (b *Baseline) threshold(factor float64) float64 {
+	spread := math.Max(math.Sqrt(b.Variance), math.Abs(b.Mean)*minRelativeSpread)
+	return b.Mean + factor*spread
+}
+
+// baselineKey identifies the baseline of a shard metric, per hour of day when
+// hourly buckets are enabled
+func (c *Coordinator) baselineKey(shardID, metric string, now time.Time) string {
+	if c.config.Adaptive.HourlyBuckets {
+		return fmt.Sprintf("%s/%s/%02d", shardID, metric, now.UTC().Hour())
+	}
+	return fmt.Sprintf("%s/%s", shardID, metric)
+}
+
+// adaptiveValues returns the metric values tracked by the adaptive mode
+func adaptiveValues(shardMetrics *metrics.ShardMetrics) map[string]float64 {
+	return map[string]float64{
+		"cpu":         shardMetrics.CPUPercent,
+		"memory":      shardMetrics.MemoryPercent,
+		"connections": float64(shardMetrics.ConnectionCount),
+		"qps":         shardMetrics.QueriesPerSec,
+	}
+}
+
+// thresholdFor returns the scaling threshold of a shard metric: the learned
+// baseline deviation when adaptive mode is enabled and the baseline has enough
+// samples, otherwise the fixed threshold
+func (c *Coordinator) thresholdFor(shardID, metric string, fixed float64) float64 {
+	if !c.config.Adaptive.Enabled || !c.adaptiveMetric(metric) {
+		return fixed
+	}
+
+	c.baselineMutex.RLock()
+	defer c.baselineMutex.RUnlock()
+
+	baseline, exists := c.baselines[c.baselineKey(shardID, metric, time.Now())]
+	if !exists || baseline.Samples < c.config.Adaptive.MinSamples {
+		return fixed
+	}
+	return baseline.threshold(c.config.Adaptive.DeviationFactor)
+}
+
+// adaptiveMetric reports whether a metric is configured for adaptive thresholds
+func (c *Coordinator) adaptiveMetric(metric string) bool {
+	for _, m := range c.config.Adaptive.Metrics {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// updateBaselines folds the latest metrics into the learned baselines. It runs
+// after scaling analysis so an anomaly is judged before it shifts the baseline.
+func (c *Coordinator) updateBaselines() {
+	if !c.config.Adaptive.Enabled {
+		return
+	}
+
+	now := time.Now()
+	halfLife := time.Duration(c.config.Adaptive.HalfLifeHours * float64(time.Hour))
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	c.baselineMutex.Lock()
+	defer c.baselineMutex.Unlock()
+
+	for shardID, shardMetrics := range c.freshMetrics() {
+		for metric, value := range adaptiveValues(shardMetrics) {
+			if !c.adaptiveMetric(metric) {
+				continue
+			}
+
+			key := c.baselineKey(shardID, metric, now)
+			baseline, exists := c.baselines[key]
+			if !exists {
+				baseline = &Baseline{}
+				c.baselines[key] = baseline
+			}
+			baseline.update(value, now, halfLife)
+		}
+	}
+}
+
+// copyBaselines returns a copy of the learned baselines
+func (c *Coordinator) copyBaselines() map[string]*Baseline {
+	c.baselineMutex.RLock()
+	defer c.baselineMutex.RUnlock()
+
+	result := make(map[string]*Baseline, len(c.baselines))
+	for key, baseline := range c.baselines {
+		copied := *baseline
+		result[key] = &copied
+	}
+	return result
+}
+
+// handleBaselines handles GET /baselines requests
+func (c *Coordinator) handleBaselines(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled":   c.config.Adaptive.Enabled,
+		"baselines": c.copyBaselines(),
+	})
+}
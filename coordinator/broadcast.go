@@ -0,0 +1,99 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// RepairRequest is the body of POST /broadcast/repair. An empty shard repairs
+// every diverged copy of the table.
+type RepairRequest struct {
+	Table string `json:"table"`
+	Shard string `json:"shard"`
+}
+
+// handleDivergences handles GET /broadcast/divergences
+func (c *Coordinator) handleDivergences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"divergences": c.shardManager.GetDivergences(),
+	})
+}
+
+// handleRepair handles POST /broadcast/repair, re-syncing diverged copies of a
+// broadcast table from the source shard
+func (c *Coordinator) handleRepair(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RepairRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Table == "" {
+		http.Error(w, "Request body must name the broadcast table in \"table\"", http.StatusBadRequest)
+		return
+	}
+	if !c.config.IsBroadcastTable(req.Table) {
+		http.Error(w, "Table "+req.Table+" is not a broadcast table", http.StatusBadRequest)
+		return
+	}
+
+	targets := []string{req.Shard}
+	if req.Shard == "" {
+		targets = nil
+		for _, divergence := range c.shardManager.GetDivergences() {
+			if divergence.Table == req.Table {
+				targets = append(targets, divergence.ShardID)
+			}
+		}
+	}
+
+	results := make([]*sharding.RepairResult, 0, len(targets))
+	for _, shardID := range targets {
+		result, err := c.shardManager.RepairBroadcastTable(req.Table, c.config.Broadcast.SourceShard, shardID)
+		if err != nil {
+			writeJSON(w, http.StatusConflict, map[string]interface{}{"error": err.Error(), "repaired": results})
+			return
+		}
+		results = append(results, result)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"repaired": results})
+}
+
+// broadcastRepairLoop periodically re-syncs diverged broadcast table copies
+func (c *Coordinator) broadcastRepairLoop() {
+	ticker := time.NewTicker(time.Duration(c.config.Broadcast.RepairIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			for _, divergence := range c.shardManager.GetDivergences() {
+				if _, err := c.shardManager.RepairBroadcastTable(divergence.Table, c.config.Broadcast.SourceShard, divergence.ShardID); err != nil {
+					log.Printf("Warning: Failed to repair %s on shard %s: %v", divergence.Table, divergence.ShardID, err)
+				}
+			}
+		}
+	}
+}
+
+// seedBroadcastTables copies every broadcast table onto a new shard, leaving
+// tables that fail to copy marked as diverged for the repair job
+func (c *Coordinator) seedBroadcastTables(shardID string) {
+	for _, table := range c.config.Broadcast.Tables {
+		if _, err := c.shardManager.RepairBroadcastTable(table, c.config.Broadcast.SourceShard, shardID); err != nil {
+			c.shardManager.RecordDivergence(table, shardID, "seed new shard", err)
+		}
+	}
+}
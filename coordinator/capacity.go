@@ -0,0 +1,85 @@
+package coordinator
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"sql-horizontal-autoscaler/notifier"
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// updateCapacity enters or leaves the at-capacity state after an analysis
+// cycle, alerting operators and telling the router how to protect hot tables
+func (c *Coordinator) updateCapacity() {
+	wasAtCapacity := c.shardManager.GetCapacityStatus().AtCapacity
+
+	if c.capacityHit && !wasAtCapacity {
+		hotTables := c.hottestTables(c.config.Capacity.HotTableCount)
+		c.shardManager.SetCapacityStatus(sharding.CapacityStatus{
+			AtCapacity: true,
+			Action:     c.config.Capacity.Action,
+			HotTables:  hotTables,
+			ShedRatio:  c.config.Capacity.ShedRatio,
+		})
+
+		log.Printf("🛑 Cluster at capacity (%d shards), router action: %s on %v",
+			c.config.Limits.MaxShards, c.config.Capacity.Action, hotTables)
+		c.notify(notifier.Alert{
+			Severity: notifier.SeverityCritical,
+			Title:    "Cluster at capacity",
+			Message: fmt.Sprintf("Scaling is required but the maximum of %d shards is reached; router action: %s",
+				c.config.Limits.MaxShards, c.config.Capacity.Action),
+			Details: map[string]interface{}{
+				"max_shards": c.config.Limits.MaxShards,
+				"action":     c.config.Capacity.Action,
+				"hot_tables": hotTables,
+			},
+		})
+	} else if !c.capacityHit && wasAtCapacity {
+		c.shardManager.SetCapacityStatus(sharding.CapacityStatus{Action: sharding.CapacityActionNone})
+
+		log.Printf("✅ Cluster no longer at capacity")
+		c.notify(notifier.Alert{
+			Severity: notifier.SeverityInfo,
+			Title:    "Cluster capacity recovered",
+			Message:  "Scaling thresholds are no longer breached at the maximum shard count",
+		})
+	}
+}
+
+// hottestTables returns the tables with the most entries across all shards
+func (c *Coordinator) hottestTables(count int) []string {
+	c.mutex.RLock()
+	totals := make(map[string]int64)
+	for _, shardMetrics := range c.metrics {
+		for tableName, entries := range shardMetrics.TableCounts {
+			totals[tableName] += entries
+		}
+	}
+	c.mutex.RUnlock()
+
+	tables := make([]string, 0, len(totals))
+	for tableName := range totals {
+		tables = append(tables, tableName)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return totals[tables[i]] > totals[tables[j]]
+	})
+
+	if len(tables) > count {
+		tables = tables[:count]
+	}
+	return tables
+}
+
+// notify sends an alert through the configured notifier
+func (c *Coordinator) notify(alert notifier.Alert) {
+	if alert.Time.IsZero() {
+		alert.Time = time.Now()
+	}
+	if err := c.notifier.Notify(alert); err != nil {
+		log.Printf("Warning: Failed to deliver alert %q: %v", alert.Title, err)
+	}
+}
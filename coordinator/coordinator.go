@@ -4,13 +4,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 
 	"sql-horizontal-autoscaler/config"
 	"sql-horizontal-autoscaler/datastore"
+	"sql-horizontal-autoscaler/health"
+	"sql-horizontal-autoscaler/hooks"
 	"sql-horizontal-autoscaler/metrics"
+	"sql-horizontal-autoscaler/middleware"
+	"sql-horizontal-autoscaler/notifier"
 	"sql-horizontal-autoscaler/sharding"
 )
 
@@ -22,6 +28,64 @@ type Coordinator struct {
 	metrics      map[string]*metrics.ShardMetrics
 	mutex        sync.RWMutex
 	stopChan     chan struct{}
+
+	scalingHistory []ScalingEvent
+	historyMutex   sync.RWMutex
+	reconciler     *sharding.Reconciler
+	notifier       notifier.Notifier
+	hooks          *hooks.Runner
+	capacityHit    bool
+	budgetHit      bool
+	budgetAlerted  bool
+	baselines      map[string]*Baseline
+	baselineMutex  sync.RWMutex
+	routers        map[string]*RouterInfo
+	routerMutex    sync.RWMutex
+	zoneStats      map[string]*ZoneStats
+	zoneMutex      sync.RWMutex
+	ttlRuns        map[string]*TTLRun
+	ttlMutex       sync.RWMutex
+	monitorBeat    *health.Heartbeat
+	indexAdvice    map[string]*IndexRecommendation
+	advisorErrors  map[string]string
+	advisorLastRun time.Time
+	advisorMutex   sync.RWMutex
+
+	tableMaintenance      map[string]*TableMaintenanceRun
+	tableMaintenanceMutex sync.RWMutex
+
+	// staleShards holds the shards whose last sample is stale, guarded by mutex
+	staleShards map[string]bool
+	// erroringShards holds the shard and error class pairs alerted on as
+	// failing, keyed "shard/class" and guarded by mutex
+	erroringShards map[string]bool
+	// backoff holds the shards whose metrics collection is failing
+	backoff      map[string]*collectionBackoff
+	backoffMutex sync.Mutex
+	// mirrorBusy and mirrorChanged hold the shards whose mirrors are being
+	// changed and when each last changed
+	mirrorBusy    map[string]bool
+	mirrorChanged map[string]time.Time
+	mirrorMutex   sync.Mutex
+	// failoverMutex serializes replica promotions
+	failoverMutex sync.Mutex
+	// ramps holds the traffic ramps of scaled-out shards
+	ramps     map[string]*RampState
+	rampMutex sync.Mutex
+	// ingested holds the latest metrics pushed for each shard by agents
+	ingested    map[string]*ingestedSample
+	ingestMutex sync.Mutex
+	// metricsChanged and metricsRemoved hold when each shard's metrics last
+	// changed or were removed, and metricsUpdated is closed on the next
+	// change; all are guarded by mutex
+	metricsChanged map[string]time.Time
+	metricsRemoved map[string]time.Time
+	metricsUpdated chan struct{}
+	// provisionSlots bounds the scale-outs provisioning shards at once, and
+	// scaleOuts holds the running and waiting ones in queue order
+	provisionSlots chan struct{}
+	scaleOuts      []*QueuedScaleOut
+	provisionMutex sync.Mutex
 }
 
 // NewCoordinator creates a new Coordinator instance
@@ -32,6 +96,26 @@ func NewCoordinator(cfg *config.Config, ds *datastore.DataStore, sm *sharding.Dy
 		shardManager: sm,
 		metrics:      make(map[string]*metrics.ShardMetrics),
 		stopChan:     make(chan struct{}),
+		notifier:     notifier.New(cfg.Alerts.WebhookURLs),
+		hooks:        newHookRunner(cfg.Hooks),
+		baselines:    make(map[string]*Baseline),
+		routers:      make(map[string]*RouterInfo),
+		ttlRuns:      make(map[string]*TTLRun),
+		monitorBeat:  health.NewHeartbeat(),
+		indexAdvice:  make(map[string]*IndexRecommendation),
+
+		tableMaintenance: make(map[string]*TableMaintenanceRun),
+		staleShards:      make(map[string]bool),
+		erroringShards:   make(map[string]bool),
+		backoff:          make(map[string]*collectionBackoff),
+		mirrorBusy:       make(map[string]bool),
+		mirrorChanged:    make(map[string]time.Time),
+		ramps:            make(map[string]*RampState),
+		ingested:         make(map[string]*ingestedSample),
+		metricsChanged:   make(map[string]time.Time),
+		metricsRemoved:   make(map[string]time.Time),
+		metricsUpdated:   make(chan struct{}),
+		provisionSlots:   make(chan struct{}, cfg.Limits.MaxConcurrentProvisions),
 	}
 }
 
@@ -41,24 +125,127 @@ func (c *Coordinator) Start() error {
 	go func() {
 		mux := http.NewServeMux()
 		mux.HandleFunc("/shards", c.handleShards)
+		mux.HandleFunc("/shards/", c.handleShardRoutes)
+		mux.HandleFunc("/shards/metrics", c.handleShardMetrics)
 		mux.HandleFunc("/health", c.handleHealth)
+		mux.HandleFunc("/livez", health.Handler("coordinator", c.livenessChecks()))
+		mux.HandleFunc("/readyz", health.Handler("coordinator", c.readinessChecks()))
+		mux.HandleFunc("/events", c.handleEvents)
+		mux.HandleFunc("/baselines", c.handleBaselines)
+		mux.HandleFunc("/zones", c.handleZones)
+		mux.HandleFunc("/regions", c.handleRegions)
+		mux.HandleFunc("/admin/move-key", c.handleMoveKey)
+		mux.HandleFunc("/admin/queries", c.handleQueries)
+		mux.HandleFunc("/admin/queries/", c.handleQueryRoutes)
+		mux.HandleFunc("/directory", c.handleDirectory)
+		mux.HandleFunc("/broadcast/divergences", c.handleDivergences)
+		mux.HandleFunc("/broadcast/repair", c.handleRepair)
+		mux.HandleFunc("/routers", c.handleRouters)
+		mux.HandleFunc("/topology", c.handleTopology)
+		mux.HandleFunc("/topology/ring", c.handleRingLayout)
+		mux.HandleFunc("/time-ranges", c.handleTimeRanges)
+		mux.HandleFunc("/time-ranges/archive", c.handleArchiveTimeRange)
+		mux.HandleFunc("/ttl", c.handleTTL)
+		mux.HandleFunc("/advisor/indexes", c.handleIndexAdvice)
+		mux.HandleFunc("/advisor/indexes/", c.handleIndexAdviceRoutes)
+		mux.HandleFunc("/table-maintenance", c.handleTableMaintenance)
+		mux.HandleFunc("/scale/out", c.handleScaleOut)
+		mux.HandleFunc("/scale/queue", c.handleScaleQueue)
+		mux.HandleFunc("/cost", c.handleCost)
+		mux.HandleFunc("/splits", c.handleSplits)
+		mux.HandleFunc("/splits/", c.handleSplitRoutes)
+		mux.HandleFunc("/resyncs", c.handleResyncs)
+		mux.HandleFunc("/resyncs/", c.handleResyncRoutes)
+		mux.HandleFunc("/misplaced-rows", c.handleMisplacedRows)
+		mux.HandleFunc("/ramps", c.handleRamps)
+		mux.HandleFunc("/ramps/", c.handleRampRoutes)
+		mux.HandleFunc("/metrics", c.handlePrometheus)
+		mux.HandleFunc("/metrics/ingest", c.handleIngestMetrics)
+		mux.HandleFunc("/proxy-config", c.handleProxyConfig)
+		mux.HandleFunc("/routers/", c.handleRouterRoutes)
 
 		port := fmt.Sprintf(":%d", c.config.Ports.CoordinatorPort)
 		log.Printf("Coordinator HTTP server starting on port %d...", c.config.Ports.CoordinatorPort)
-		if err := http.ListenAndServe(port, mux); err != nil {
+		if err := http.ListenAndServe(port, middleware.Chain(mux, middleware.ForService("coordinator", c.config.HTTP)...)); err != nil {
 			log.Printf("Coordinator HTTP server error: %v", err)
 		}
 	}()
 
+	// Recover state left behind by a previous coordinator
+	c.restoreFromSnapshot()
+
 	// Start monitoring loop
 	go c.monitoringLoop()
 
+	// Start periodic state snapshots
+	go c.snapshotLoop()
+
+	// Push topology changes to registered routers
+	go c.topologyPushLoop()
+
+	// Release connection pools of departed and cordoned shards
+	go c.poolReaperLoop()
+
+	// Re-sync diverged broadcast tables
+	if len(c.config.Broadcast.Tables) > 0 {
+		go c.broadcastRepairLoop()
+	}
+
+	// Roll time range tables over to new shards and archive old ranges
+	if len(c.config.TimeRange.Tables) > 0 {
+		go c.timeRangeLoop()
+	}
+
+	// Delete rows past their table's TTL
+	if len(c.config.TTL.Tables) > 0 {
+		go c.ttlJanitorLoop()
+	}
+
+	// Look for missing indexes in statement digests
+	if c.config.IndexAdvisor.Enabled {
+		go c.indexAdvisorLoop()
+	}
+
+	// Refresh planner statistics during low-traffic windows
+	if c.config.TableMaintenance.Enabled {
+		go c.tableMaintenanceLoop()
+	}
+
+	// Kill queries exceeding the execution-time ceiling
+	if c.config.Queries.MaxExecutionSeconds > 0 {
+		go c.queryReaperLoop()
+	}
+
+	// Shift traffic onto scaled-out shards step by step
+	if c.config.Ramp.Enabled {
+		go c.rampLoop()
+	}
+
+	// Keep ProxySQL or HAProxy configuration in sync with the shards
+	if c.config.ProxyExport.Enabled {
+		go c.proxyExportLoop()
+	}
+
+	// Start orphaned container reconciliation
+	c.reconciler = sharding.NewReconciler(c.shardManager,
+		time.Duration(c.config.Reconciler.IntervalSeconds)*time.Second,
+		c.config.Reconciler.OrphanPolicy, c.handleReconcileAction)
+	c.reconciler.Start()
+
 	return nil
 }
 
 // Stop stops the coordinator
 func (c *Coordinator) Stop() {
 	close(c.stopChan)
+
+	if c.reconciler != nil {
+		c.reconciler.Stop()
+	}
+
+	if err := c.saveSnapshot(); err != nil {
+		log.Printf("Warning: Failed to save final coordinator snapshot: %v", err)
+	}
 }
 
 // handleShards handles GET /shards requests
@@ -68,10 +255,23 @@ func (c *Coordinator) handleShards(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Optionally restrict the listing to shards matching a label selector
+	selector, err := sharding.ParseSelector(r.URL.Query().Get("selector"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	matching := make(map[string]bool)
+	for _, shardID := range c.shardManager.ShardsMatching(selector) {
+		matching[shardID] = true
+	}
+
 	c.mutex.RLock()
 	shards := make([]*metrics.ShardMetrics, 0, len(c.metrics))
-	for _, shardMetrics := range c.metrics {
-		shards = append(shards, shardMetrics)
+	for shardID, shardMetrics := range c.metrics {
+		if selector.Empty() || matching[shardID] {
+			shards = append(shards, shardMetrics)
+		}
 	}
 	c.mutex.RUnlock()
 
@@ -94,12 +294,51 @@ func (c *Coordinator) handleHealth(w http.ResponseWriter, r *http.Request) {
 		"service": "coordinator",
 		"strategy": c.config.ScalingStrategy,
 		"monitoring_interval": c.config.MonitoringIntervalSeconds,
+		"cluster_at_capacity": c.shardManager.GetCapacityStatus().AtCapacity,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(health)
 }
 
+// handleEvents handles GET /events requests
+func (c *Coordinator) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(c.getScalingHistory()); err != nil {
+		log.Printf("Failed to encode events response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// handleReconcileAction records reconciler actions and connects adopted shards
+func (c *Coordinator) handleReconcileAction(action sharding.ReconcileAction) {
+	event := ScalingEvent{
+		Target:  action.ContainerName,
+		Reason:  "orphan_container",
+		ShardID: action.ShardID,
+		Status:  action.Action,
+		Error:   action.Error,
+	}
+
+	if action.Action == "adopted" && action.Shard != nil {
+		if err := c.dataStore.AddShardConnection(action.Shard.ID, action.Shard.DSN, c.tableNames()); err != nil {
+			log.Printf("❌ Failed to add connection for adopted shard %s: %v", action.Shard.ID, err)
+			event.Status = "adopt_failed"
+			event.Error = err.Error()
+		} else {
+			c.config.SetShard(action.Shard.ID, action.Shard.DSN)
+			log.Printf("✅ Adopted orphaned shard %s", action.Shard.ID)
+		}
+	}
+
+	c.recordEvent(event)
+}
+
 // monitoringLoop runs the continuous monitoring and scaling logic
 func (c *Coordinator) monitoringLoop() {
 	log.Printf("Starting monitoring loop with %s strategy (interval: %d seconds)", 
@@ -115,6 +354,7 @@ func (c *Coordinator) monitoringLoop() {
 			return
 		case <-ticker.C:
 			c.collectAndAnalyzeMetrics()
+			c.monitorBeat.Beat()
 		}
 	}
 }
@@ -123,35 +363,62 @@ func (c *Coordinator) monitoringLoop() {
 func (c *Coordinator) collectAndAnalyzeMetrics() {
 	log.Println("Collecting metrics from all shards...")
 
-	// Collect metrics from all shards concurrently
+	shardIDs := c.config.GetShardIDs()
+	sort.Strings(shardIDs)
+
+	c.pruneBackoff(shardIDs)
+	c.pruneIngested(shardIDs)
+
+	// Collect metrics from all shards concurrently, staggering the start of each
+	// collection and bounding how many run at once so shards aren't hit together.
+	// Shards whose collection is failing are only probed once their backoff
+	// expires, without waiting for the probe, so they don't hold up the cycle.
 	var wg sync.WaitGroup
-	metricsChan := make(chan *metrics.ShardMetrics, len(c.config.Shards))
+	semaphore := make(chan struct{}, c.config.Monitoring.MaxConcurrentCollections)
+
+	for i, shardID := range shardIDs {
+		if c.pushOnly(shardID) {
+			continue
+		}
+		due, failing := c.collectionDue(shardID)
+		if !due {
+			continue
+		}
+		if failing {
+			go c.collectShard(shardID, 0, nil)
+			continue
+		}
 
-	for shardID := range c.config.Shards {
 		wg.Add(1)
-		go func(sID string) {
+		go func(sID string, delay time.Duration) {
 			defer wg.Done()
-			metrics, err := c.dataStore.GetShardMetrics(sID)
-			if err != nil {
-				log.Printf("Failed to get metrics for shard %s: %v", sID, err)
-				return
-			}
-			metricsChan <- metrics
-		}(shardID)
+			c.collectShard(sID, delay, semaphore)
+		}(shardID, c.collectionDelay(i))
 	}
 
 	wg.Wait()
-	close(metricsChan)
-
-	// Update stored metrics
-	c.mutex.Lock()
-	for shardMetrics := range metricsChan {
-		c.metrics[shardMetrics.ShardID] = shardMetrics
-	}
-	c.mutex.Unlock()
+	c.updateStaleness()
+	c.updateErrorAlerts()
+	c.checkFailover()
 
 	// Analyze metrics for scaling decisions
+	c.capacityHit = false
+	c.budgetHit = false
 	c.analyzeForScaling()
+	c.analyzeMirrors()
+	c.updateBaselines()
+	c.updateCapacity()
+	c.updateBudget()
+}
+
+// collectionDelay returns the staggered start delay, plus random jitter, for
+// the i-th shard of a collection cycle
+func (c *Coordinator) collectionDelay(i int) time.Duration {
+	delay := time.Duration(i*c.config.Monitoring.StaggerMs) * time.Millisecond
+	if c.config.Monitoring.JitterMs > 0 {
+		delay += time.Duration(rand.Intn(c.config.Monitoring.JitterMs)) * time.Millisecond
+	}
+	return delay
 }
 
 // analyzeForScaling analyzes the collected metrics and makes scaling decisions
@@ -171,141 +438,273 @@ func (c *Coordinator) analyzeForScaling() {
 
 // analyzeHotScaling implements hot scaling logic (individual shard thresholds)
 func (c *Coordinator) analyzeHotScaling() {
-	for shardID, shardMetrics := range c.metrics {
+	for shardID, shardMetrics := range c.freshMetrics() {
 		// Check CPU threshold
-		if shardMetrics.CPUPercent >= c.config.ScalingThresholds.CPUThresholdPercent {
+		cpuThreshold := c.thresholdFor(shardID, "cpu", c.config.ScalingThresholds.CPUThresholdPercent)
+		if shardMetrics.CPUPercent >= cpuThreshold {
 			log.Printf("HOT SCALING TRIGGERED: Shard %s CPU at %.1f%% (threshold: %.1f%%)",
-				shardID, shardMetrics.CPUPercent, c.config.ScalingThresholds.CPUThresholdPercent)
-			c.triggerScaling(shardID, "cpu", shardMetrics.CPUPercent)
+				shardID, shardMetrics.CPUPercent, cpuThreshold)
+			c.triggerScaling(shardID, "cpu", shardMetrics.CPUPercent, 1)
 		}
 
 		// Check memory threshold
-		if shardMetrics.MemoryPercent >= c.config.ScalingThresholds.MemoryThresholdPercent {
+		memoryThreshold := c.thresholdFor(shardID, "memory", c.config.ScalingThresholds.MemoryThresholdPercent)
+		if shardMetrics.MemoryPercent >= memoryThreshold {
 			log.Printf("HOT SCALING TRIGGERED: Shard %s Memory at %.1f%% (threshold: %.1f%%)",
-				shardID, shardMetrics.MemoryPercent, c.config.ScalingThresholds.MemoryThresholdPercent)
-			c.triggerScaling(shardID, "memory", shardMetrics.MemoryPercent)
+				shardID, shardMetrics.MemoryPercent, memoryThreshold)
+			c.triggerScaling(shardID, "memory", shardMetrics.MemoryPercent, 1)
 		}
 
-		// Check entry count threshold
-		if shardMetrics.TotalEntries >= c.config.ScalingThresholds.TotalEntryThresholdPerShard {
-			log.Printf("HOT SCALING TRIGGERED: Shard %s has %d entries (threshold: %d)",
-				shardID, shardMetrics.TotalEntries, c.config.ScalingThresholds.TotalEntryThresholdPerShard)
-			c.triggerScaling(shardID, "entries", float64(shardMetrics.TotalEntries))
+		// Check entry count threshold, weighing tables by priority class
+		entries, growth := c.weightedEntries(shardMetrics)
+		if entries >= float64(c.config.ScalingThresholds.TotalEntryThresholdPerShard) {
+			log.Printf("HOT SCALING TRIGGERED: Shard %s has %.0f weighted entries (threshold: %d)",
+				shardID, entries, c.config.ScalingThresholds.TotalEntryThresholdPerShard)
+			c.triggerScaling(shardID, "entries", entries, 1)
+		}
+
+		// Check entry growth rate, scaling out before the entry threshold is reached
+		growthThreshold := c.config.ScalingThresholds.EntryGrowthPerMinute
+		if growthThreshold > 0 && growth >= growthThreshold {
+			log.Printf("HOT SCALING TRIGGERED: Shard %s growing by %.0f weighted entries/min (threshold: %.0f)",
+				shardID, growth, growthThreshold)
+			c.triggerScaling(shardID, "entry_growth", growth, 1)
 		}
 
 		// Check connection count threshold
-		if shardMetrics.ConnectionCount >= c.config.ScalingThresholds.ConnectionThreshold {
-			log.Printf("HOT SCALING TRIGGERED: Shard %s has %d connections (threshold: %d)",
-				shardID, shardMetrics.ConnectionCount, c.config.ScalingThresholds.ConnectionThreshold)
-			c.triggerScaling(shardID, "connections", float64(shardMetrics.ConnectionCount))
+		connectionThreshold := c.thresholdFor(shardID, "connections", float64(c.config.ScalingThresholds.ConnectionThreshold))
+		if float64(shardMetrics.ConnectionCount) >= connectionThreshold {
+			log.Printf("HOT SCALING TRIGGERED: Shard %s has %d connections (threshold: %.0f)",
+				shardID, shardMetrics.ConnectionCount, connectionThreshold)
+			c.triggerScaling(shardID, "connections", float64(shardMetrics.ConnectionCount), 1)
 		}
 
-		// Check queries per second threshold
-		if shardMetrics.QueriesPerSec >= c.config.ScalingThresholds.QPSThreshold {
+		// Check queries per second threshold, leaving read load to mirrors
+		// while the shard can take another
+		qpsThreshold := c.thresholdFor(shardID, "qps", c.config.ScalingThresholds.QPSThreshold)
+		if shardMetrics.QueriesPerSec >= qpsThreshold && !c.canAddMirror(shardID) {
 			log.Printf("HOT SCALING TRIGGERED: Shard %s has %.1f QPS (threshold: %.1f)",
-				shardID, shardMetrics.QueriesPerSec, c.config.ScalingThresholds.QPSThreshold)
-			c.triggerScaling(shardID, "qps", shardMetrics.QueriesPerSec)
+				shardID, shardMetrics.QueriesPerSec, qpsThreshold)
+			c.triggerScaling(shardID, "qps", shardMetrics.QueriesPerSec, 1)
 		}
+
+		// Check statements failing for too many connections or a full disk
+		c.analyzeQueryErrors(shardID, shardMetrics)
 	}
 }
 
 // analyzeColdScaling implements cold scaling logic (aggregate thresholds)
 func (c *Coordinator) analyzeColdScaling() {
 	var totalEntries int64
-	var avgCPU, avgMemory float64
+	var avgCPU, avgMemory, totalGrowth float64
 	var totalConnections int64
 	var highCPUShards, highMemoryShards []string
+	var newErrors metrics.QueryErrors
 
 	// Calculate aggregate metrics
-	for shardID, shardMetrics := range c.metrics {
+	fresh := c.freshMetrics()
+	for shardID, shardMetrics := range fresh {
 		totalEntries += shardMetrics.TotalEntries
+		totalGrowth += shardMetrics.EntryGrowthPerMinute
 		avgCPU += shardMetrics.CPUPercent
 		avgMemory += shardMetrics.MemoryPercent
 		totalConnections += shardMetrics.ConnectionCount
+		newErrors = newErrors.Plus(shardMetrics.NewErrors)
 
-		if shardMetrics.CPUPercent >= c.config.ScalingThresholds.CPUThresholdPercent {
+		if shardMetrics.CPUPercent >= c.thresholdFor(shardID, "cpu", c.config.ScalingThresholds.CPUThresholdPercent) {
 			highCPUShards = append(highCPUShards, shardID)
 		}
 
-		if shardMetrics.MemoryPercent >= c.config.ScalingThresholds.MemoryThresholdPercent {
+		if shardMetrics.MemoryPercent >= c.thresholdFor(shardID, "memory", c.config.ScalingThresholds.MemoryThresholdPercent) {
 			highMemoryShards = append(highMemoryShards, shardID)
 		}
 	}
 
-	if len(c.metrics) > 0 {
-		avgCPU /= float64(len(c.metrics))
-		avgMemory /= float64(len(c.metrics))
+	if len(fresh) > 0 {
+		avgCPU /= float64(len(fresh))
+		avgMemory /= float64(len(fresh))
 	}
 
 	// Check aggregate thresholds
-	totalThreshold := c.config.ScalingThresholds.TotalEntryThresholdPerShard * int64(len(c.config.Shards))
+	clusterTriggered := false
+	shardCount := c.config.ShardCount()
+	totalThreshold := c.config.ScalingThresholds.TotalEntryThresholdPerShard * int64(shardCount)
 	if totalEntries >= totalThreshold {
 		log.Printf("COLD SCALING TRIGGERED: Total entries %d reached threshold %d across %d shards", 
-			totalEntries, totalThreshold, len(c.config.Shards))
-		c.triggerScaling("cluster", "total_entries", float64(totalEntries))
+			totalEntries, totalThreshold, shardCount)
+		c.triggerScaling("cluster", "total_entries", float64(totalEntries), scalingStep(float64(totalEntries), float64(totalThreshold)))
+		clusterTriggered = true
+	}
+
+	growthThreshold := c.config.ScalingThresholds.EntryGrowthPerMinute * float64(shardCount)
+	if growthThreshold > 0 && totalGrowth >= growthThreshold {
+		log.Printf("COLD SCALING TRIGGERED: Cluster growing by %.0f entries/min (threshold: %.0f across %d shards)",
+			totalGrowth, growthThreshold, shardCount)
+		c.triggerScaling("cluster", "total_entry_growth", totalGrowth, scalingStep(totalGrowth, growthThreshold))
+		clusterTriggered = true
 	}
 
 	// Check if multiple shards have high CPU
-	if len(highCPUShards) >= len(c.config.Shards)/2 {
+	if len(highCPUShards) >= shardCount/2 {
 		log.Printf("COLD SCALING TRIGGERED: %d out of %d shards have high CPU (avg: %.1f%%)", 
-			len(highCPUShards), len(c.config.Shards), avgCPU)
-		c.triggerScaling("cluster", "avg_cpu", avgCPU)
+			len(highCPUShards), shardCount, avgCPU)
+		c.triggerScaling("cluster", "avg_cpu", avgCPU, scalingStep(avgCPU, c.config.ScalingThresholds.CPUThresholdPercent))
+		clusterTriggered = true
 	}
+
+	// Check statements failing anywhere for too many connections or a full disk
+	if threshold := c.config.ScalingThresholds.TooManyConnectionErrors; threshold > 0 && newErrors.TooManyConnections >= threshold {
+		log.Printf("COLD SCALING TRIGGERED: %d statements refused for too many connections (threshold: %d)",
+			newErrors.TooManyConnections, threshold)
+		c.triggerScaling("cluster", metrics.ErrorTooManyConnections, float64(newErrors.TooManyConnections), 1)
+		clusterTriggered = true
+	}
+	if threshold := c.config.ScalingThresholds.DiskFullErrors; threshold > 0 && newErrors.DiskFull >= threshold {
+		log.Printf("COLD SCALING TRIGGERED: %d writes failed on a full disk (threshold: %d)", newErrors.DiskFull, threshold)
+		c.triggerScaling("cluster", metrics.ErrorDiskFull, float64(newErrors.DiskFull), 1)
+		clusterTriggered = true
+	}
+
+	// Check for zones saturated on their own
+	c.analyzeZones(clusterTriggered)
 }
 
-// triggerScaling triggers actual scaling actions by creating new shards
-func (c *Coordinator) triggerScaling(target string, reason string, value float64) {
+// triggerScaling triggers actual scaling actions by creating step new shards,
+// fewer if limits.max_step, limits.max_shards or the budget allow fewer
+func (c *Coordinator) triggerScaling(target string, reason string, value float64, step int) {
 	log.Printf("🚨 SCALING TRIGGERED: Target=%s, Reason=%s, Value=%.1f", target, reason, value)
+	c.runHooks(hooks.StageThresholdBreach, ScalingEvent{Time: time.Now(), Target: target, Reason: reason, Value: value, Status: "breached"})
 
-	// Check if we should scale out (add new shard)
-	currentShardCount := c.shardManager.GetShardCount()
+	// Scaling actions are suppressed while the target is in maintenance
+	if window, active := c.shardManager.ActiveMaintenance(target); active {
+		log.Printf("🔧 Scaling suppressed: %s is in maintenance until %s", target, window.End.Format(time.RFC3339))
+		c.recordEvent(ScalingEvent{Target: target, Reason: reason, Value: value, Status: "suppressed", Error: "maintenance window active"})
+		return
+	}
+
+	// A target whose scale-out is still queued or running is already being
+	// relieved; shards that scale-out has yet to add count as added
+	queued := c.queueScaleOut(target, reason, scalingZone(target), 0, true)
+	if queued == nil {
+		log.Printf("⏳ Scale-out for %s is already pending, not queueing another", target)
+		return
+	}
+	currentShardCount := c.shardManager.GetShardCount() + c.pendingShards()
 	maxShards := c.config.Limits.MaxShards
 
 	if currentShardCount >= maxShards {
 		log.Printf("⚠️  Maximum shard count (%d) reached, cannot scale further", maxShards)
+		c.capacityHit = true
+		c.recordEvent(ScalingEvent{Target: target, Reason: reason, Value: value, Status: "skipped", Error: "maximum shard count reached"})
+		c.finishScaleOut(queued)
 		return
 	}
 
+	costDelta, err := c.checkBudget()
+	if err != nil {
+		log.Printf("⚠️  %v, cannot scale further", err)
+		c.budgetHit = true
+		c.recordEvent(ScalingEvent{Target: target, Reason: reason, Value: value, Status: "skipped", Error: err.Error(), CostDelta: costDelta})
+		c.finishScaleOut(queued)
+		return
+	}
+
+	// Add no more shards at once than allowed, and than fit under the
+	// shard limit and the budget
+	step = min(step, c.config.Limits.MaxStep, maxShards-currentShardCount, c.affordableShards())
+	step = max(step, 1)
+	c.provisionMutex.Lock()
+	queued.Step = step
+	c.provisionMutex.Unlock()
+
 	// Trigger actual shard creation
-	log.Printf("🚀 Initiating shard scale-out: %d → %d shards", currentShardCount, currentShardCount+1)
+	log.Printf("🚀 Initiating shard scale-out: %d → %d shards", currentShardCount, currentShardCount+step)
 
 	go func() {
-		if err := c.scaleOutShard(); err != nil {
-			log.Printf("❌ Failed to scale out: %v", err)
+		defer c.finishScaleOut(queued)
+		if !c.startScaleOut(queued) {
+			return
+		}
+		if c.preScale(ScalingEvent{Target: target, Reason: reason, Value: value, Step: step, CostDelta: costDelta * float64(step)}) != nil {
+			return
 		}
+		var shardIDs []string
+		for len(shardIDs) < step {
+			shardID, err := c.scaleOutShard(queued.Zone)
+			if shardID != "" {
+				shardIDs = append(shardIDs, shardID)
+			}
+			if err != nil {
+				log.Printf("❌ Failed to scale out: %v", err)
+				c.recordEvent(scalingStepEvent(ScalingEvent{Target: target, Reason: reason, Value: value, Status: "failed", Error: err.Error(),
+					Logs: provisioningLogs(err)}, step, shardIDs))
+				return
+			}
+			c.scaleOutCreated(queued)
+		}
+		c.recordEvent(scalingStepEvent(ScalingEvent{Target: target, Reason: reason, Value: value, Status: "completed", CostDelta: costDelta * float64(step)}, step, shardIDs))
 	}()
 }
 
-// scaleOutShard creates a new shard and integrates it into the system
-func (c *Coordinator) scaleOutShard() error {
+// scalingStep returns how many shards a cold scaling trigger adds: one per
+// multiple of the threshold the value reached, so twice the threshold adds two
+func scalingStep(value, threshold float64) int {
+	if threshold <= 0 {
+		return 1
+	}
+	return max(int(value/threshold), 1)
+}
+
+// scalingStepEvent fills in the step of a scale-out and the shards it
+// created, the last of which is the event's ShardID
+func scalingStepEvent(event ScalingEvent, step int, shardIDs []string) ScalingEvent {
+	event.Step = step
+	if len(shardIDs) > 0 {
+		event.ShardID = shardIDs[len(shardIDs)-1]
+	}
+	if step > 1 {
+		event.ShardIDs = shardIDs
+	}
+	return event
+}
+
+// scaleOutShard creates a new shard, in the given zone if one is named, and
+// integrates it into the system, returning the new shard's ID
+func (c *Coordinator) scaleOutShard(zone string) (string, error) {
 	log.Printf("📈 Starting shard scale-out process...")
 
 	// 1. Create new shard
-	newShardInfo, err := c.shardManager.AddNewShard()
+	newShardInfo, err := c.shardManager.AddNewShardInZone(zone)
 	if err != nil {
-		return fmt.Errorf("failed to create new shard: %w", err)
+		return "", fmt.Errorf("failed to create new shard: %w", err)
 	}
 
 	log.Printf("✅ New shard created: %s (port %d)", newShardInfo.ID, newShardInfo.Port)
 
 	// 2. Add new shard to datastore connections
-	tableNames := make([]string, 0, len(c.config.TableShardKeys))
-	for tableName := range c.config.TableShardKeys {
-		tableNames = append(tableNames, tableName)
-	}
-
-	if err := c.dataStore.AddShardConnection(newShardInfo.ID, newShardInfo.DSN, tableNames); err != nil {
+	if err := c.dataStore.AddShardConnection(newShardInfo.ID, newShardInfo.DSN, c.tableNames()); err != nil {
 		log.Printf("❌ Failed to add shard connection: %v", err)
-		return fmt.Errorf("failed to add shard connection: %w", err)
+		return newShardInfo.ID, c.shardManager.AbandonShard(newShardInfo.ID, fmt.Errorf("failed to add shard connection: %w", err))
 	}
 
 	log.Printf("✅ Shard %s integrated into datastore", newShardInfo.ID)
 
+	// Give the new shard its copy of every broadcast table
+	c.seedBroadcastTables(newShardInfo.ID)
+
 	// 3. Update configuration dynamically
-	c.config.Shards[newShardInfo.ID] = newShardInfo.DSN
+	c.config.SetShard(newShardInfo.ID, newShardInfo.DSN)
+
+	// Raise the shard's ring weight as it proves healthy
+	c.startRamp(newShardInfo.ID)
 
 	log.Printf("🎉 Scale-out complete! New shard %s is active and ready", newShardInfo.ID)
 	log.Printf("📊 Current cluster: %d shards active", c.shardManager.GetShardCount())
 
-	return nil
+	return newShardInfo.ID, nil
+}
+
+// tableNames returns the database-qualified names of all sharded tables
+func (c *Coordinator) tableNames() []string {
+	return c.config.QualifiedTableNames()
 }
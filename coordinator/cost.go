@@ -0,0 +1,132 @@
+package coordinator
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"sql-horizontal-autoscaler/notifier"
+)
+
+// hoursPerMonth is the average number of hours in a month, used to project
+// hourly prices to monthly spend
+const hoursPerMonth = 730
+
+// CostEstimate is the projected spend of the cluster, returned by GET /cost
+type CostEstimate struct {
+	Currency string `json:"currency"`
+	// Shards maps each live shard and mirror to its hourly cost
+	Shards         map[string]float64 `json:"shards"`
+	HourlyCost     float64            `json:"hourly_cost"`
+	MonthlyCost    float64            `json:"monthly_cost"`
+	MonthlyBudget  float64            `json:"monthly_budget,omitempty"`
+	NewShardCost   float64            `json:"new_shard_monthly_cost"`
+	BudgetExceeded bool               `json:"budget_exceeded,omitempty"`
+}
+
+// shardHourlyCost returns the hourly cost of a shard, or of a shard not yet
+// created when shardID is empty
+func (c *Coordinator) shardHourlyCost(shardID string) float64 {
+	if cost, exists := c.config.Cost.ShardCosts[shardID]; exists {
+		return cost
+	}
+	if cost, exists := c.config.Cost.InstanceClassCosts[c.config.Provisioner.InstanceClass]; exists {
+		return cost
+	}
+	return c.config.Cost.ShardHourlyCost
+}
+
+// costEstimate prices every shard that is running or being created, and
+// every mirror at the price of its shard
+func (c *Coordinator) costEstimate() CostEstimate {
+	estimate := CostEstimate{
+		Currency:      c.config.Cost.Currency,
+		Shards:        make(map[string]float64),
+		MonthlyBudget: c.config.Cost.MonthlyBudget,
+		NewShardCost:  c.shardHourlyCost("") * hoursPerMonth,
+	}
+	for shardID, shardInfo := range c.shardManager.GetAllShardInfo() {
+		switch shardInfo.Status {
+		case "failed", "removed", "merged":
+			continue
+		}
+		cost := c.shardHourlyCost(shardID)
+		estimate.Shards[shardID] = cost
+		estimate.HourlyCost += cost
+	}
+	for _, mirror := range c.shardManager.Mirrors("") {
+		cost := c.shardHourlyCost(mirror.ShardID)
+		estimate.Shards[mirror.ID] = cost
+		estimate.HourlyCost += cost
+	}
+	estimate.MonthlyCost = estimate.HourlyCost * hoursPerMonth
+	estimate.BudgetExceeded = estimate.MonthlyBudget > 0 && estimate.MonthlyCost > estimate.MonthlyBudget
+	return estimate
+}
+
+// checkBudget returns the monthly cost of one more shard, and an error if it
+// would take the projected spend over the budget
+func (c *Coordinator) checkBudget() (float64, error) {
+	estimate := c.costEstimate()
+	if estimate.MonthlyBudget > 0 && estimate.MonthlyCost+estimate.NewShardCost > estimate.MonthlyBudget {
+		return estimate.NewShardCost, fmt.Errorf("a new shard would raise projected monthly spend to %.2f %s, over the budget of %.2f",
+			estimate.MonthlyCost+estimate.NewShardCost, estimate.Currency, estimate.MonthlyBudget)
+	}
+	return estimate.NewShardCost, nil
+}
+
+// affordableShards returns how many new shards fit under the budget, or
+// limits.max_step when there is no budget
+func (c *Coordinator) affordableShards() int {
+	estimate := c.costEstimate()
+	if estimate.MonthlyBudget <= 0 || estimate.NewShardCost <= 0 {
+		return c.config.Limits.MaxStep
+	}
+	return int((estimate.MonthlyBudget - estimate.MonthlyCost) / estimate.NewShardCost)
+}
+
+// updateBudget alerts operators when the budget starts or stops blocking
+// scale-outs after an analysis cycle
+func (c *Coordinator) updateBudget() {
+	if c.budgetHit == c.budgetAlerted {
+		return
+	}
+	c.budgetAlerted = c.budgetHit
+
+	estimate := c.costEstimate()
+	details := map[string]interface{}{
+		"monthly_cost":           estimate.MonthlyCost,
+		"monthly_budget":         estimate.MonthlyBudget,
+		"new_shard_monthly_cost": estimate.NewShardCost,
+		"currency":               estimate.Currency,
+	}
+	if c.budgetHit {
+		log.Printf("💸 Scaling blocked by budget: projected %.2f + %.2f %s per month exceeds %.2f",
+			estimate.MonthlyCost, estimate.NewShardCost, estimate.Currency, estimate.MonthlyBudget)
+		c.notify(notifier.Alert{
+			Severity: notifier.SeverityCritical,
+			Title:    "Scaling blocked by budget",
+			Message: fmt.Sprintf("Scaling is required but a new shard would add %.2f %s per month to the projected %.2f, over the budget of %.2f",
+				estimate.NewShardCost, estimate.Currency, estimate.MonthlyCost, estimate.MonthlyBudget),
+			Details: details,
+		})
+		return
+	}
+
+	log.Printf("✅ Scaling no longer blocked by budget")
+	c.notify(notifier.Alert{
+		Severity: notifier.SeverityInfo,
+		Title:    "Scaling within budget",
+		Message:  "Scaling is no longer blocked by the monthly budget",
+		Details:  details,
+	})
+}
+
+// handleCost handles GET /cost, returning the cluster's projected spend
+func (c *Coordinator) handleCost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, c.costEstimate())
+}
@@ -0,0 +1,174 @@
+package coordinator
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"sql-horizontal-autoscaler/metrics"
+)
+
+// DecommissionPlan is the response of GET /shards/{id}/decommission-plan:
+// what removing a shard by merging it into another would take, without
+// changing anything
+type DecommissionPlan struct {
+	ShardID     string `json:"shard_id"`
+	Destination string `json:"destination"`
+	// Rows must move to the destination, by table in TableRows, along with
+	// Bytes of data
+	Rows      int64            `json:"rows"`
+	TableRows map[string]int64 `json:"table_rows"`
+	Bytes     int64            `json:"bytes"`
+	// EstimatedDuration is the time to copy Bytes at MaxBytesPerSecond,
+	// during which writes to the shard are blocked
+	MaxBytesPerSecond int64  `json:"max_bytes_per_second"`
+	EstimatedDuration string `json:"estimated_duration,omitempty"`
+	// QueriesPerSec, ReadsPerSec and EntryGrowthPerMinute are the load on
+	// the shard that moves to the destination
+	QueriesPerSec        float64 `json:"queries_per_second"`
+	ReadsPerSec          float64 `json:"reads_per_second"`
+	EntryGrowthPerMinute float64 `json:"entry_growth_per_minute"`
+	// Candidates are the shards the shard could merge into with their load
+	// afterwards, least loaded first
+	Candidates []DecommissionCandidate `json:"candidates"`
+	// MirrorsRemoved are the shard's mirrors, removed with it
+	MirrorsRemoved []string `json:"mirrors_removed,omitempty"`
+	CostDelta      float64  `json:"cost_delta"`
+	Warnings       []string `json:"warnings,omitempty"`
+	// MetricsAsOf is when the shard's metrics the plan is based on were
+	// collected
+	MetricsAsOf time.Time `json:"metrics_as_of"`
+}
+
+// DecommissionCandidate is a shard a decommissioned shard could merge into
+type DecommissionCandidate struct {
+	ShardID            string  `json:"shard_id"`
+	RowsAfter          int64   `json:"rows_after"`
+	BytesAfter         int64   `json:"bytes_after"`
+	QueriesPerSecAfter float64 `json:"queries_per_second_after"`
+}
+
+// handleDecommissionPlan handles GET /shards/{id}/decommission-plan?into=<shard>
+// &max_bytes_per_second=N. Without into, the plan merges into the least
+// loaded active shard; the copy rate defaults to split.max_bytes_per_second.
+func (c *Coordinator) handleDecommissionPlan(w http.ResponseWriter, r *http.Request, shardID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rate := c.config.Split.MaxBytesPerSecond
+	if raw := r.URL.Query().Get("max_bytes_per_second"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "max_bytes_per_second must be a non-negative number"})
+			return
+		}
+		rate = n
+	}
+
+	plan, status, err := c.decommissionPlan(shardID, r.URL.Query().Get("into"), rate)
+	if err != nil {
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, plan)
+}
+
+// decommissionPlan works out what merging a shard into another would move,
+// from the latest metrics of both, with the HTTP status of any error
+func (c *Coordinator) decommissionPlan(shardID, into string, rate int64) (*DecommissionPlan, int, error) {
+	shardInfo, exists := c.shardManager.GetShardInfo(shardID)
+	if !exists {
+		return nil, http.StatusNotFound, fmt.Errorf("shard %s not found", shardID)
+	}
+
+	c.mutex.Lock()
+	all := make(map[string]metrics.ShardMetrics, len(c.metrics))
+	for id, shardMetrics := range c.metrics {
+		all[id] = *shardMetrics
+	}
+	c.mutex.Unlock()
+
+	source, collected := all[shardID]
+	if !collected {
+		return nil, http.StatusConflict, fmt.Errorf("no metrics collected for shard %s yet", shardID)
+	}
+
+	plan := &DecommissionPlan{
+		ShardID:              shardID,
+		Rows:                 source.TotalEntries,
+		TableRows:            source.TableCounts,
+		Bytes:                source.DatabaseSize,
+		MaxBytesPerSecond:    rate,
+		QueriesPerSec:        source.QueriesPerSec,
+		ReadsPerSec:          source.ReadsPerSec,
+		EntryGrowthPerMinute: source.EntryGrowthPerMinute,
+		CostDelta:            -c.shardHourlyCost(shardID) * hoursPerMonth,
+		MetricsAsOf:          source.LastUpdated,
+	}
+	if rate > 0 {
+		plan.EstimatedDuration = time.Duration(float64(source.DatabaseSize) / float64(rate) * float64(time.Second)).Round(time.Second).String()
+	}
+	for _, mirror := range c.shardManager.Mirrors(shardID) {
+		plan.MirrorsRemoved = append(plan.MirrorsRemoved, mirror.ID)
+		plan.CostDelta -= c.shardHourlyCost(shardID) * hoursPerMonth
+	}
+
+	for id, info := range c.shardManager.GetAllShardInfo() {
+		if id == shardID || info.Status != "active" {
+			continue
+		}
+		destination := all[id]
+		plan.Candidates = append(plan.Candidates, DecommissionCandidate{
+			ShardID:            id,
+			RowsAfter:          destination.TotalEntries + source.TotalEntries,
+			BytesAfter:         destination.DatabaseSize + source.DatabaseSize,
+			QueriesPerSecAfter: destination.QueriesPerSec + source.QueriesPerSec,
+		})
+	}
+	if len(plan.Candidates) == 0 {
+		return nil, http.StatusConflict, fmt.Errorf("shard %s is the only active shard, there is nowhere to move its rows", shardID)
+	}
+	sort.Slice(plan.Candidates, func(i, j int) bool {
+		a, b := plan.Candidates[i], plan.Candidates[j]
+		if a.RowsAfter != b.RowsAfter {
+			return a.RowsAfter < b.RowsAfter
+		}
+		return a.ShardID < b.ShardID
+	})
+
+	chosen := plan.Candidates[0]
+	if into != "" {
+		found := false
+		for _, candidate := range plan.Candidates {
+			if candidate.ShardID == into {
+				chosen, found = candidate, true
+			}
+		}
+		if !found {
+			return nil, http.StatusBadRequest, fmt.Errorf("shard %s is not an active shard %s could merge into", into, shardID)
+		}
+	}
+	plan.Destination = chosen.ShardID
+
+	if shardInfo.Status != "active" {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("shard %s is %s; only active shards can be merged", shardID, shardInfo.Status))
+	}
+	if _, collected := all[chosen.ShardID]; !collected {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("no metrics collected for %s yet, its load afterwards only counts %s's", chosen.ShardID, shardID))
+	}
+	if age := time.Since(source.LastUpdated); age > c.staleAfter() {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("metrics of %s are %s old", shardID, age.Round(time.Second)))
+	}
+	if threshold := c.config.ScalingThresholds.TotalEntryThresholdPerShard; chosen.RowsAfter >= threshold {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s would hold %d entries, over the scaling threshold of %d, and may be scaled out again",
+			chosen.ShardID, chosen.RowsAfter, threshold))
+	}
+	if rate == 0 {
+		plan.Warnings = append(plan.Warnings, "no copy rate to estimate the duration at; pass max_bytes_per_second")
+	}
+	return plan, http.StatusOK, nil
+}
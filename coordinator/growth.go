@@ -0,0 +1,34 @@
+package coordinator
+
+import (
+	"sql-horizontal-autoscaler/metrics"
+)
+
+// setEntryGrowth sets the rows added per minute, per shard and per table,
+// between a shard's previous sample and its new one. Rows moved away by a
+// split or merge show as negative growth.
+func setEntryGrowth(current, previous *metrics.ShardMetrics) {
+	minutes := current.LastUpdated.Sub(previous.LastUpdated).Minutes()
+	if minutes <= 0 {
+		return
+	}
+
+	current.EntryGrowthPerMinute = float64(current.TotalEntries-previous.TotalEntries) / minutes
+	current.TableGrowthPerMinute = make(map[string]float64, len(current.TableCounts))
+	for table, count := range current.TableCounts {
+		if before, sampled := previous.TableCounts[table]; sampled {
+			current.TableGrowthPerMinute[table] = float64(count-before) / minutes
+		}
+	}
+}
+
+// setReadRate sets the SELECTs per second served between a shard's previous
+// sample and its new one. A counter that went backwards, after a restart or
+// the removal of a replica, leaves the rate unset for one sample.
+func setReadRate(current, previous *metrics.ShardMetrics) {
+	seconds := current.LastUpdated.Sub(previous.LastUpdated).Seconds()
+	if seconds <= 0 || current.SelectCount < previous.SelectCount {
+		return
+	}
+	current.ReadsPerSec = float64(current.SelectCount-previous.SelectCount) / seconds
+}
@@ -0,0 +1,35 @@
+package coordinator
+
+import (
+	"fmt"
+	"time"
+
+	"sql-horizontal-autoscaler/health"
+)
+
+// livenessChecks are the checks behind /livez: the monitoring loop must keep
+// completing rounds
+func (c *Coordinator) livenessChecks() map[string]health.Check {
+	maxAge := 3 * time.Duration(c.config.MonitoringIntervalSeconds) * time.Second
+	if maxAge < time.Minute {
+		// A round may be slowed by shards timing out
+		maxAge = time.Minute
+	}
+	return map[string]health.Check{
+		"monitoring_loop": c.monitorBeat.Check(maxAge),
+	}
+}
+
+// readinessChecks are the checks behind /readyz: the coordinator can manage
+// the cluster once a shard is active and Docker answers
+func (c *Coordinator) readinessChecks() map[string]health.Check {
+	return map[string]health.Check{
+		"active_shards": func() error {
+			if c.shardManager.GetShardCount() == 0 {
+				return fmt.Errorf("no active shards")
+			}
+			return nil
+		},
+		"docker": c.shardManager.CheckDocker,
+	}
+}
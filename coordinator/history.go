@@ -0,0 +1,59 @@
+package coordinator
+
+import (
+	"time"
+
+	"sql-horizontal-autoscaler/hooks"
+)
+
+// maxScalingHistory bounds the number of scaling events kept in memory
+const maxScalingHistory = 100
+
+// ScalingEvent records a single scaling decision and its outcome
+type ScalingEvent struct {
+	Time    time.Time `json:"time"`
+	Target  string    `json:"target"`
+	Reason  string    `json:"reason"`
+	Value   float64   `json:"value"`
+	ShardID string    `json:"shard_id,omitempty"`
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	// Step is the number of shards a scale-out set out to add, and ShardIDs
+	// the shards it added when that is more than one
+	Step     int      `json:"step,omitempty"`
+	ShardIDs []string `json:"shard_ids,omitempty"`
+	// CostDelta is the change in projected monthly spend of a shard added
+	// or removed by the event
+	CostDelta float64 `json:"cost_delta,omitempty"`
+	// Logs holds the last container output of a shard that failed to
+	// provision and was torn down
+	Logs string `json:"logs,omitempty"`
+}
+
+// recordEvent appends a scaling event to the bounded history
+func (c *Coordinator) recordEvent(event ScalingEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.Status == "completed" || event.Status == "failed" {
+		c.runHooks(hooks.StagePostScale, event)
+	}
+
+	c.historyMutex.Lock()
+	defer c.historyMutex.Unlock()
+
+	c.scalingHistory = append(c.scalingHistory, event)
+	if len(c.scalingHistory) > maxScalingHistory {
+		c.scalingHistory = c.scalingHistory[len(c.scalingHistory)-maxScalingHistory:]
+	}
+}
+
+// getScalingHistory returns a copy of the recorded scaling events
+func (c *Coordinator) getScalingHistory() []ScalingEvent {
+	c.historyMutex.RLock()
+	defer c.historyMutex.RUnlock()
+
+	history := make([]ScalingEvent, len(c.scalingHistory))
+	copy(history, c.scalingHistory)
+	return history
+}
@@ -0,0 +1,66 @@
+package coordinator
+
+import (
+	"log"
+	"time"
+
+	"sql-horizontal-autoscaler/config"
+	"sql-horizontal-autoscaler/hooks"
+)
+
+// newHookRunner creates the runner for the configured hooks
+func newHookRunner(hooksConfig config.HooksConfig) *hooks.Runner {
+	return hooks.NewRunner(map[string][]hooks.Hook{
+		hooks.StagePreScale:        toHooks(hooksConfig.PreScale),
+		hooks.StagePostScale:       toHooks(hooksConfig.PostScale),
+		hooks.StageThresholdBreach: toHooks(hooksConfig.ThresholdBreach),
+	})
+}
+
+// toHooks converts configured hooks
+func toHooks(hookConfigs []config.HookConfig) []hooks.Hook {
+	converted := make([]hooks.Hook, len(hookConfigs))
+	for i, hookConfig := range hookConfigs {
+		converted[i] = hooks.Hook{
+			Name:           hookConfig.Name,
+			Command:        hookConfig.Command,
+			URL:            hookConfig.URL,
+			Timeout:        time.Duration(hookConfig.TimeoutSeconds) * time.Second,
+			AbortOnFailure: hookConfig.AbortOnFailure,
+		}
+	}
+	return converted
+}
+
+// preScale runs the pre-scale hooks before a scaling action and waits for
+// them. If one set to abort on failure fails, the action is recorded as
+// aborted and the error returned, and the caller must not go ahead.
+func (c *Coordinator) preScale(event ScalingEvent) error {
+	if !c.hooks.Has(hooks.StagePreScale) {
+		return nil
+	}
+	event.Time, event.Status = time.Now(), "starting"
+
+	if err := c.hooks.Run(hooks.StagePreScale, event, logHookFailure(hooks.StagePreScale)); err != nil {
+		log.Printf("🪝 %s on %s aborted by a pre-scale hook: %v", event.Reason, event.Target, err)
+		event.Status, event.Error = "aborted", err.Error()
+		c.recordEvent(event)
+		return err
+	}
+	return nil
+}
+
+// runHooks runs the hooks of a stage in the background
+func (c *Coordinator) runHooks(stage string, event ScalingEvent) {
+	if !c.hooks.Has(stage) {
+		return
+	}
+	go c.hooks.Run(stage, event, logHookFailure(stage))
+}
+
+// logHookFailure returns a logger for hooks failing at a stage
+func logHookFailure(stage string) func(string, error) {
+	return func(hook string, err error) {
+		log.Printf("Warning: %s hook %s failed: %v", stage, hook, err)
+	}
+}
@@ -0,0 +1,255 @@
+package coordinator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"sql-horizontal-autoscaler/metrics"
+)
+
+// ingestedSample is a sample an external agent pushed for a shard, with the
+// JSON fields it set; only those replace the coordinator's own readings
+type ingestedSample struct {
+	metrics metrics.ShardMetrics
+	fields  map[string]bool
+	at      time.Time
+}
+
+// ingestableFields are the ShardMetrics fields agents may push. Counters are
+// applied before the coordinator derives rates from them, rates after.
+var ingestableFields = map[string]bool{
+	"shard_id":                true,
+	"cpu_percent":             true,
+	"memory_percent":          true,
+	"disk_percent":            true,
+	"total_entries":           true,
+	"connection_count":        true,
+	"queries_per_second":      true,
+	"status":                  true,
+	"last_updated":            true,
+	"database_size_bytes":     true,
+	"table_counts":            true,
+	"select_count":            true,
+	"entry_growth_per_minute": true,
+	"reads_per_second":        true,
+}
+
+// IngestResult reports the samples accepted by POST /metrics/ingest
+type IngestResult struct {
+	Accepted []string `json:"accepted"`
+}
+
+// handleIngestMetrics handles POST /metrics/ingest, taking one
+// ShardMetrics-shaped sample or an array of them from agents running next to
+// shards the coordinator can't query itself
+func (c *Coordinator) handleIngestMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+		return
+	}
+	payloads := []json.RawMessage{raw}
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(raw, &payloads); err != nil {
+			http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Validate every sample before applying any
+	samples := make([]ingestedSample, 0, len(payloads))
+	for i, payload := range payloads {
+		sample, err := c.parseIngestedSample(payload)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("sample %d: %v", i, err)})
+			return
+		}
+		samples = append(samples, sample)
+	}
+
+	result := IngestResult{Accepted: make([]string, 0, len(samples))}
+	for _, sample := range samples {
+		c.ingestSample(sample)
+		result.Accepted = append(result.Accepted, sample.metrics.ShardID)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// parseIngestedSample decodes a pushed sample, refusing unknown fields and
+// shards the cluster doesn't have
+func (c *Coordinator) parseIngestedSample(payload json.RawMessage) (ingestedSample, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return ingestedSample{}, fmt.Errorf("sample must be a JSON object")
+	}
+
+	sample := ingestedSample{fields: make(map[string]bool, len(fields)), at: time.Now()}
+	var unknown []string
+	for field := range fields {
+		if !ingestableFields[field] {
+			unknown = append(unknown, field)
+		}
+		sample.fields[field] = true
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return ingestedSample{}, fmt.Errorf("unknown or coordinator-managed fields: %s", strings.Join(unknown, ", "))
+	}
+	if err := json.Unmarshal(payload, &sample.metrics); err != nil {
+		return ingestedSample{}, err
+	}
+
+	shardID := sample.metrics.ShardID
+	if shardID == "" {
+		return ingestedSample{}, fmt.Errorf("shard_id is required")
+	}
+	if shardInfo, exists := c.shardManager.GetShardInfo(shardID); !exists || shardInfo.Status == "removed" {
+		return ingestedSample{}, fmt.Errorf("shard %s is not in the cluster", shardID)
+	}
+
+	// Agents with skewed clocks must not make a sample look fresher than it is
+	if sample.fields["last_updated"] && sample.metrics.LastUpdated.Before(sample.at) {
+		sample.at = sample.metrics.LastUpdated
+	}
+	return sample, nil
+}
+
+// ingestSample keeps a pushed sample to overlay on the shard's polled
+// samples and merges it into the shard's current metrics straight away
+func (c *Coordinator) ingestSample(sample ingestedSample) {
+	shardID := sample.metrics.ShardID
+
+	c.ingestMutex.Lock()
+	c.ingested[shardID] = &sample
+	c.ingestMutex.Unlock()
+	queryErrors := c.shardQueryErrors(shardID)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	current := &metrics.ShardMetrics{ShardID: shardID, Status: "healthy", TableCounts: make(map[string]int64)}
+	previous, sampled := c.metrics[shardID]
+	if sampled {
+		copied := *previous
+		current = &copied
+	}
+	applyIngestedCounters(current, &sample)
+	current.LastUpdated = sample.at
+	if sampled {
+		if sample.fields["total_entries"] || sample.fields["table_counts"] {
+			setEntryGrowth(current, previous)
+		}
+		if sample.fields["select_count"] {
+			setReadRate(current, previous)
+		}
+	}
+	applyIngestedRates(current, &sample)
+	setQueryErrors(current, previous, queryErrors)
+	c.setShardMetrics(shardID, current)
+
+	log.Printf("📥 Ingested metrics for shard %s (%d fields)", shardID, len(sample.fields)-1)
+}
+
+// overlayIngested applies a shard's pushed counters to a polled sample, before
+// the coordinator derives rates, and returns a function applying the pushed
+// rates afterwards. Samples older than the staleness limit are ignored.
+func (c *Coordinator) overlayIngested(shardMetrics *metrics.ShardMetrics) func() {
+	c.ingestMutex.Lock()
+	sample, exists := c.ingested[shardMetrics.ShardID]
+	c.ingestMutex.Unlock()
+	if !exists || time.Since(sample.at) > c.staleAfter() {
+		return func() {}
+	}
+
+	applyIngestedCounters(shardMetrics, sample)
+	return func() { applyIngestedRates(shardMetrics, sample) }
+}
+
+// applyIngestedCounters copies the pushed readings and counters of a sample
+func applyIngestedCounters(dst *metrics.ShardMetrics, sample *ingestedSample) {
+	src := sample.metrics
+	if sample.fields["cpu_percent"] {
+		dst.CPUPercent = src.CPUPercent
+	}
+	if sample.fields["memory_percent"] {
+		dst.MemoryPercent = src.MemoryPercent
+	}
+	if sample.fields["disk_percent"] {
+		dst.DiskPercent = src.DiskPercent
+	}
+	if sample.fields["total_entries"] {
+		dst.TotalEntries = src.TotalEntries
+	}
+	if sample.fields["connection_count"] {
+		dst.ConnectionCount = src.ConnectionCount
+	}
+	if sample.fields["queries_per_second"] {
+		dst.QueriesPerSec = src.QueriesPerSec
+	}
+	if sample.fields["status"] {
+		dst.Status = src.Status
+	}
+	if sample.fields["database_size_bytes"] {
+		dst.DatabaseSize = src.DatabaseSize
+	}
+	if sample.fields["table_counts"] {
+		dst.TableCounts = src.TableCounts
+		if !sample.fields["total_entries"] {
+			dst.TotalEntries = 0
+			for _, count := range src.TableCounts {
+				dst.TotalEntries += count
+			}
+		}
+	}
+	if sample.fields["select_count"] {
+		dst.SelectCount = src.SelectCount
+	}
+}
+
+// applyIngestedRates copies the pushed rates of a sample over derived ones
+func applyIngestedRates(dst *metrics.ShardMetrics, sample *ingestedSample) {
+	if sample.fields["entry_growth_per_minute"] {
+		dst.EntryGrowthPerMinute = sample.metrics.EntryGrowthPerMinute
+	}
+	if sample.fields["reads_per_second"] {
+		dst.ReadsPerSec = sample.metrics.ReadsPerSec
+	}
+}
+
+// pushOnly reports whether a shard's metrics only come from POST
+// /metrics/ingest, so the coordinator doesn't poll it
+func (c *Coordinator) pushOnly(shardID string) bool {
+	for _, pushed := range c.config.Monitoring.PushOnlyShards {
+		if pushed == "*" || pushed == shardID {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneIngested forgets the pushed samples of shards no longer in the cluster
+func (c *Coordinator) pruneIngested(shardIDs []string) {
+	current := make(map[string]bool, len(shardIDs))
+	for _, shardID := range shardIDs {
+		current[shardID] = true
+	}
+
+	c.ingestMutex.Lock()
+	defer c.ingestMutex.Unlock()
+	for shardID := range c.ingested {
+		if !current[shardID] {
+			delete(c.ingested, shardID)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package coordinator
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// defaultLogTail and maxLogTail bound the lines GET /shards/{id}/logs returns
+	defaultLogTail = 200
+	maxLogTail     = 5000
+)
+
+// Log sources of GET /shards/{id}/logs
+const (
+	logSourceAll       = "all"
+	logSourceContainer = "container"
+	logSourceErrorLog  = "error_log"
+)
+
+// handleShardLogs handles GET /shards/{id}/logs?tail=N&source=S&follow=true,
+// writing a shard's recent container output and MySQL error log as plain
+// text. source is "all" (default), "container" or "error_log"; follow keeps
+// streaming the container's output until the client disconnects.
+func (c *Coordinator) handleShardLogs(w http.ResponseWriter, r *http.Request, shardID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, exists := c.shardManager.GetShardInfo(shardID); !exists {
+		http.Error(w, fmt.Sprintf("Shard %s not found", shardID), http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	tail := defaultLogTail
+	if raw := query.Get("tail"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, "tail must be a positive number of lines", http.StatusBadRequest)
+			return
+		}
+		tail = min(n, maxLogTail)
+	}
+	source := query.Get("source")
+	if source == "" {
+		source = logSourceAll
+	}
+	if source != logSourceAll && source != logSourceContainer && source != logSourceErrorLog {
+		http.Error(w, "source must be 'all', 'container' or 'error_log'", http.StatusBadRequest)
+		return
+	}
+	follow := query.Get("follow") == "true"
+	if follow && source == logSourceErrorLog {
+		http.Error(w, "follow only applies to container logs", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	out := &flushWriter{w: w}
+	if flusher, ok := w.(http.Flusher); ok {
+		out.flusher = flusher
+	}
+
+	// The error log comes first when following, as the container's output
+	// doesn't end
+	if source != logSourceContainer && follow {
+		c.writeErrorLog(out, shardID, tail)
+	}
+	if source != logSourceErrorLog {
+		fmt.Fprintf(out, "==> container logs of shard %s (last %d lines) <==\n", shardID, tail)
+		if err := c.shardManager.ContainerLogs(r.Context(), shardID, tail, follow, out); err != nil {
+			fmt.Fprintf(out, "(unavailable: %v)\n", err)
+		}
+	}
+	if source != logSourceContainer && !follow {
+		c.writeErrorLog(out, shardID, tail)
+	}
+}
+
+// writeErrorLog writes the last tail entries of a shard's MySQL error log
+func (c *Coordinator) writeErrorLog(w io.Writer, shardID string, tail int) {
+	fmt.Fprintf(w, "==> MySQL error log of shard %s (last %d entries) <==\n", shardID, tail)
+	entries, err := c.dataStore.ErrorLog(shardID, tail)
+	if err != nil {
+		fmt.Fprintf(w, "(unavailable: %v)\n", err)
+		return
+	}
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s [%s] [%s] [%s] %s\n", entry.Logged.Format("2006-01-02T15:04:05.000000Z07:00"),
+			entry.Priority, entry.ErrorCode, entry.Subsystem, entry.Message)
+	}
+}
+
+// flushWriter flushes every write to the client, so followed logs arrive as
+// they are written
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if fw.flusher != nil {
+		fw.flusher.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,121 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// MaintenanceRequest is the body of PUT /shards/{id}/maintenance
+type MaintenanceRequest struct {
+	Start           *time.Time `json:"start,omitempty"`
+	End             *time.Time `json:"end,omitempty"`
+	DurationSeconds int        `json:"duration_seconds,omitempty"`
+	Mode            string     `json:"mode"`
+	Reason          string     `json:"reason,omitempty"`
+}
+
+// handleShardRoutes dispatches /shards/{id}/... requests
+func (c *Coordinator) handleShardRoutes(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/shards/"), "/"), "/")
+	if len(parts) == 3 && parts[0] != "" && parts[1] == "mirrors" {
+		c.handleMirror(w, r, parts[0], parts[2])
+		return
+	}
+	if len(parts) != 2 || parts[0] == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	shardID, action := parts[0], parts[1]
+	switch action {
+	case "maintenance":
+		c.handleMaintenance(w, r, shardID)
+	case "merge":
+		c.handleMerge(w, r, shardID)
+	case "split":
+		c.handleSplit(w, r, shardID)
+	case "readonly":
+		c.handleReadOnly(w, r, shardID)
+	case "mirrors":
+		c.handleMirrors(w, r, shardID)
+	case "promote":
+		c.handlePromote(w, r, shardID)
+	case "resync":
+		c.handleResync(w, r, shardID)
+	case "logs":
+		c.handleShardLogs(w, r, shardID)
+	case "decommission-plan":
+		c.handleDecommissionPlan(w, r, shardID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleMaintenance handles GET/PUT/DELETE /shards/{id}/maintenance requests.
+// The shard ID "cluster" declares a cluster-wide window.
+func (c *Coordinator) handleMaintenance(w http.ResponseWriter, r *http.Request, shardID string) {
+	switch r.Method {
+	case http.MethodGet:
+		window, exists := c.shardManager.GetMaintenance(shardID)
+		if !exists {
+			http.Error(w, "No maintenance window declared", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, window)
+
+	case http.MethodPut:
+		var req MaintenanceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+			return
+		}
+
+		window := sharding.MaintenanceWindow{
+			ShardID: shardID,
+			Start:   time.Now(),
+			Mode:    req.Mode,
+			Reason:  req.Reason,
+		}
+		if req.Start != nil {
+			window.Start = *req.Start
+		}
+		if req.End != nil {
+			window.End = *req.End
+		} else if req.DurationSeconds > 0 {
+			window.End = window.Start.Add(time.Duration(req.DurationSeconds) * time.Second)
+		}
+
+		if err := c.shardManager.SetMaintenance(window); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("🔧 Maintenance window set for %s: %s until %s (%s)",
+			shardID, window.Mode, window.End.Format(time.RFC3339), window.Reason)
+		c.recordEvent(ScalingEvent{Target: shardID, Reason: "maintenance", ShardID: shardID, Status: "scheduled"})
+		writeJSON(w, http.StatusOK, window)
+
+	case http.MethodDelete:
+		c.shardManager.ClearMaintenance(shardID)
+		log.Printf("🔧 Maintenance window cleared for %s", shardID)
+		c.recordEvent(ScalingEvent{Target: shardID, Reason: "maintenance", ShardID: shardID, Status: "cleared"})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
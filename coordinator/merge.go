@@ -0,0 +1,76 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// MergeRequest is the body of POST /shards/{id}/merge
+type MergeRequest struct {
+	Into string `json:"into"`
+}
+
+// handleMerge handles POST /shards/{id}/merge, consolidating the shard into
+// the shard named in the request body
+func (c *Coordinator) handleMerge(w http.ResponseWriter, r *http.Request, shardID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req MergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Into == "" {
+		http.Error(w, "Request body must name the destination shard in \"into\"", http.StatusBadRequest)
+		return
+	}
+
+	result, err := c.mergeShard(shardID, req.Into)
+	if err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// mergeShard merges a shard into another and removes it from the datastore
+// and monitoring, recording the outcome as a scaling event
+func (c *Coordinator) mergeShard(srcID, dstID string) (*sharding.MergeResult, error) {
+	if err := c.preScale(ScalingEvent{Target: srcID, Reason: "merge", ShardID: dstID}); err != nil {
+		return nil, err
+	}
+	result, err := c.shardManager.MergeShards(srcID, dstID)
+	if err != nil {
+		log.Printf("❌ Failed to merge shard %s into %s: %v", srcID, dstID, err)
+		c.recordEvent(ScalingEvent{Target: srcID, Reason: "merge", ShardID: dstID, Status: "failed", Error: err.Error()})
+		return nil, err
+	}
+
+	c.forgetShard(srcID)
+	c.markStatsStale(dstID)
+	c.recordEvent(ScalingEvent{Target: srcID, Reason: "merge", ShardID: dstID, Status: "completed", CostDelta: -c.shardHourlyCost(srcID) * hoursPerMonth})
+	log.Printf("📉 Scale-in complete: %d shards active", c.shardManager.GetShardCount())
+	return result, nil
+}
+
+// restoreMerge re-applies a merge recorded in a snapshot
+func (c *Coordinator) restoreMerge(shardInfo sharding.ShardInfo) {
+	c.shardManager.RestoreMerge(shardInfo)
+	c.forgetShard(shardInfo.ID)
+	log.Printf("♻️  Shard %s remains merged into %s", shardInfo.ID, shardInfo.MergedInto)
+}
+
+// forgetShard stops querying and monitoring a shard that no longer holds data
+func (c *Coordinator) forgetShard(shardID string) {
+	c.removeMirrors(shardID)
+	if err := c.dataStore.RemoveShardConnection(shardID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	c.mutex.Lock()
+	c.config.RemoveShard(shardID)
+	c.deleteShardMetrics(shardID)
+	c.mutex.Unlock()
+}
@@ -0,0 +1,162 @@
+package coordinator
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"sql-horizontal-autoscaler/metrics"
+	"sql-horizontal-autoscaler/sharding"
+)
+
+const (
+	// defaultMetricsWait and maxMetricsWait bound how long GET
+	// /shards/metrics holds a request open waiting for a change
+	defaultMetricsWait = 30 * time.Second
+	maxMetricsWait     = 120 * time.Second
+	// removedMetricsTTL is how long removed shards are reported in deltas
+	removedMetricsTTL = 10 * time.Minute
+)
+
+// MetricsDelta is the response of GET /shards/metrics: the shards whose
+// metrics changed since the requested time and the shards removed since
+type MetricsDelta struct {
+	// AsOf is passed as since in the next request
+	AsOf    time.Time               `json:"as_of"`
+	Shards  []*metrics.ShardMetrics `json:"shards"`
+	Removed []string                `json:"removed,omitempty"`
+}
+
+// setShardMetrics stores a shard's sample, recording when its metrics last
+// changed and waking long-polling requests. A sample differing from the
+// previous one only by its timestamp isn't a change. Must be called with
+// mutex held.
+func (c *Coordinator) setShardMetrics(shardID string, shardMetrics *metrics.ShardMetrics) {
+	previous, exists := c.metrics[shardID]
+	c.metrics[shardID] = shardMetrics
+	if exists && sameMetrics(previous, shardMetrics) {
+		return
+	}
+	delete(c.metricsRemoved, shardID)
+	c.metricsChanged[shardID] = time.Now()
+	c.notifyMetricsChange()
+}
+
+// deleteShardMetrics forgets a shard's metrics, reporting it as removed. Must
+// be called with mutex held.
+func (c *Coordinator) deleteShardMetrics(shardID string) {
+	if _, exists := c.metrics[shardID]; !exists {
+		return
+	}
+	delete(c.metrics, shardID)
+	delete(c.metricsChanged, shardID)
+	c.metricsRemoved[shardID] = time.Now()
+	c.notifyMetricsChange()
+}
+
+// notifyMetricsChange wakes the requests waiting for a change. Must be called
+// with mutex held.
+func (c *Coordinator) notifyMetricsChange() {
+	close(c.metricsUpdated)
+	c.metricsUpdated = make(chan struct{})
+}
+
+// sameMetrics reports whether two samples hold the same readings
+func sameMetrics(a, b *metrics.ShardMetrics) bool {
+	aCopy, bCopy := *a, *b
+	aCopy.LastUpdated, bCopy.LastUpdated = time.Time{}, time.Time{}
+	return reflect.DeepEqual(aCopy, bCopy)
+}
+
+// handleShardMetrics handles GET /shards/metrics?since=<as_of>&wait=<seconds>.
+// Without since every shard is returned. With it, only the shards whose
+// metrics changed after since are, and if none did the request waits up to
+// wait seconds (default 30) for a change before answering with none.
+func (c *Coordinator) handleShardMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	selector, err := sharding.ParseSelector(query.Get("selector"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var since time.Time
+	if raw := query.Get("since"); raw != "" {
+		if since, err = time.Parse(time.RFC3339Nano, raw); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "since must be an RFC 3339 timestamp, such as a previous as_of"})
+			return
+		}
+	}
+	wait := defaultMetricsWait
+	if raw := query.Get("wait"); raw != "" {
+		seconds, err := time.ParseDuration(raw + "s")
+		if err != nil || seconds < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "wait must be a non-negative number of seconds"})
+			return
+		}
+		wait = min(seconds, maxMetricsWait)
+	}
+
+	var matching map[string]bool
+	if !selector.Empty() {
+		matching = make(map[string]bool)
+		for _, shardID := range c.shardManager.ShardsMatching(selector) {
+			matching[shardID] = true
+		}
+	}
+
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+	for {
+		delta, updated := c.metricsDelta(since, matching)
+		if since.IsZero() || len(delta.Shards) > 0 || len(delta.Removed) > 0 {
+			writeJSON(w, http.StatusOK, delta)
+			return
+		}
+
+		select {
+		case <-updated:
+		case <-timeout.C:
+			writeJSON(w, http.StatusOK, delta)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// metricsDelta returns the metrics of the shards changed or removed after
+// since, restricted to matching unless it's nil, with the channel closed on
+// the next change
+func (c *Coordinator) metricsDelta(since time.Time, matching map[string]bool) (MetricsDelta, <-chan struct{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delta := MetricsDelta{AsOf: time.Now(), Shards: []*metrics.ShardMetrics{}}
+	for shardID, shardMetrics := range c.metrics {
+		if matching != nil && !matching[shardID] {
+			continue
+		}
+		if since.IsZero() || c.metricsChanged[shardID].After(since) {
+			delta.Shards = append(delta.Shards, shardMetrics)
+		}
+	}
+	for shardID, removedAt := range c.metricsRemoved {
+		if time.Since(removedAt) > removedMetricsTTL {
+			delete(c.metricsRemoved, shardID)
+			continue
+		}
+		if !since.IsZero() && removedAt.After(since) {
+			delta.Removed = append(delta.Removed, shardID)
+		}
+	}
+
+	sort.Slice(delta.Shards, func(i, j int) bool { return delta.Shards[i].ShardID < delta.Shards[j].ShardID })
+	sort.Strings(delta.Removed)
+	return delta, c.metricsUpdated
+}
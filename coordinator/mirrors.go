@@ -0,0 +1,233 @@
+package coordinator
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// analyzeMirrors adds a read mirror to each shard whose serving instances
+// take more reads than the threshold, and removes one from shards whose
+// remaining instances would stay well under it. A shard changes at most once
+// per cooldown, and one change at a time.
+func (c *Coordinator) analyzeMirrors() {
+	if !c.config.Mirrors.Enabled {
+		return
+	}
+
+	c.mutex.RLock()
+	reads := make(map[string]float64)
+	for shardID, shardMetrics := range c.freshMetrics() {
+		reads[shardID] = shardMetrics.ReadsPerSec
+	}
+	c.mutex.RUnlock()
+
+	threshold := c.config.Mirrors.ReadQPSThreshold
+	for shardID, readsPerSec := range reads {
+		mirrors := c.shardManager.Mirrors(shardID)
+		targets := float64(len(mirrors) + 1)
+
+		switch {
+		case readsPerSec/targets >= threshold && len(mirrors) < c.config.Mirrors.MaxPerShard:
+			if c.startMirrorChange(shardID) {
+				log.Printf("🪞 Shard %s serving %.1f reads/s across %.0f instances (threshold: %.1f each), adding a mirror",
+					shardID, readsPerSec, targets, threshold)
+				go func(sID string, value float64) {
+					defer c.finishMirrorChange(sID)
+					c.addMirror(sID, value)
+				}(shardID, readsPerSec)
+			}
+
+		case len(mirrors) > 0 && readsPerSec/(targets-1) < threshold*c.config.Mirrors.ScaleInRatio:
+			if c.startMirrorChange(shardID) {
+				mirror := mirrors[len(mirrors)-1]
+				log.Printf("🪞 Shard %s serving %.1f reads/s across %.0f instances, removing mirror %s",
+					shardID, readsPerSec, targets, mirror.ID)
+				go func(sID, mirrorID string, value float64) {
+					defer c.finishMirrorChange(sID)
+					c.removeMirror(mirrorID, value)
+				}(shardID, mirror.ID, readsPerSec)
+			}
+		}
+	}
+}
+
+// canAddMirror reports whether read load on a shard can still be absorbed by
+// another mirror rather than by splitting the shard
+func (c *Coordinator) canAddMirror(shardID string) bool {
+	return c.config.Mirrors.Enabled && len(c.shardManager.Mirrors(shardID)) < c.config.Mirrors.MaxPerShard
+}
+
+// startMirrorChange claims a shard for a mirror change, failing while another
+// change is running or the shard's cooldown hasn't expired
+func (c *Coordinator) startMirrorChange(shardID string) bool {
+	c.mirrorMutex.Lock()
+	defer c.mirrorMutex.Unlock()
+
+	cooldown := time.Duration(c.config.Mirrors.CooldownSeconds) * time.Second
+	if c.mirrorBusy[shardID] || time.Since(c.mirrorChanged[shardID]) < cooldown {
+		return false
+	}
+	c.mirrorBusy[shardID] = true
+	return true
+}
+
+// finishMirrorChange releases a shard claimed by startMirrorChange and starts
+// its cooldown
+func (c *Coordinator) finishMirrorChange(shardID string) {
+	c.mirrorMutex.Lock()
+	defer c.mirrorMutex.Unlock()
+
+	delete(c.mirrorBusy, shardID)
+	c.mirrorChanged[shardID] = time.Now()
+}
+
+// addMirror creates a mirror of a shard and starts sending it the shard's
+// non-strong reads, recording the outcome
+func (c *Coordinator) addMirror(shardID string, readsPerSec float64) (*sharding.MirrorInfo, error) {
+	costDelta, err := c.checkBudget()
+	if err != nil {
+		log.Printf("Warning: Not adding a mirror to shard %s: %v", shardID, err)
+		c.recordEvent(ScalingEvent{Target: shardID, Reason: "mirror_add", Value: readsPerSec, Status: "skipped", Error: err.Error()})
+		return nil, err
+	}
+
+	if err := c.preScale(ScalingEvent{Target: shardID, Reason: "mirror_add", Value: readsPerSec, CostDelta: costDelta}); err != nil {
+		return nil, err
+	}
+
+	mirror, err := c.shardManager.AddMirror(shardID)
+	if err == nil {
+		if err = c.dataStore.AddReplica(shardID, mirror.DSN); err != nil {
+			if _, removeErr := c.shardManager.RemoveMirror(mirror.ID); removeErr != nil {
+				log.Printf("Warning: %v", removeErr)
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("❌ Failed to add a mirror to shard %s: %v", shardID, err)
+		c.recordEvent(ScalingEvent{Target: shardID, Reason: "mirror_add", Value: readsPerSec, Status: "failed", Error: err.Error()})
+		return nil, err
+	}
+
+	c.recordEvent(ScalingEvent{Target: shardID, Reason: "mirror_add", Value: readsPerSec, ShardID: mirror.ID, Status: "completed", CostDelta: costDelta})
+	return mirror, nil
+}
+
+// removeMirror stops reading from a mirror and removes it, recording the
+// outcome
+func (c *Coordinator) removeMirror(mirrorID string, readsPerSec float64) error {
+	var shardID, dsn string
+	for _, mirror := range c.shardManager.Mirrors("") {
+		if mirror.ID == mirrorID {
+			shardID, dsn = mirror.ShardID, mirror.DSN
+		}
+	}
+
+	// Reads leave the mirror before it goes away
+	if dsn != "" {
+		if err := c.dataStore.RemoveReplica(shardID, dsn); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	if _, err := c.shardManager.RemoveMirror(mirrorID); err != nil {
+		log.Printf("❌ Failed to remove mirror %s: %v", mirrorID, err)
+		c.recordEvent(ScalingEvent{Target: shardID, Reason: "mirror_remove", Value: readsPerSec, ShardID: mirrorID, Status: "failed", Error: err.Error()})
+		return err
+	}
+
+	c.recordEvent(ScalingEvent{Target: shardID, Reason: "mirror_remove", Value: readsPerSec, ShardID: mirrorID, Status: "completed",
+		CostDelta: -c.shardHourlyCost(shardID) * hoursPerMonth})
+	return nil
+}
+
+// removeMirrors removes every mirror of a shard leaving the cluster
+func (c *Coordinator) removeMirrors(shardID string) {
+	for _, mirror := range c.shardManager.Mirrors(shardID) {
+		c.removeMirror(mirror.ID, 0)
+	}
+}
+
+// restoreMirrors tracks the mirrors of a snapshot again and resumes reading
+// from the active ones. Mirrors still being created stay listed so they can
+// be removed.
+func (c *Coordinator) restoreMirrors(mirrors []sharding.MirrorInfo) {
+	c.shardManager.RestoreMirrors(mirrors)
+	for _, mirror := range mirrors {
+		if mirror.Status != sharding.MirrorActive {
+			continue
+		}
+		if err := c.dataStore.AddReplica(mirror.ShardID, mirror.DSN); err != nil {
+			log.Printf("Warning: Failed to resume reading from mirror %s: %v", mirror.ID, err)
+		}
+	}
+	log.Printf("📂 Restored %d mirrors", len(mirrors))
+}
+
+// handleMirrors handles GET /shards/{id}/mirrors, listing a shard's mirrors,
+// and POST /shards/{id}/mirrors, adding one on an operator's request. The
+// mirror is created in the background and its outcome is recorded in GET
+// /events.
+func (c *Coordinator) handleMirrors(w http.ResponseWriter, r *http.Request, shardID string) {
+	switch r.Method {
+	case http.MethodGet:
+		mirrors := c.shardManager.Mirrors(shardID)
+		if mirrors == nil {
+			mirrors = []sharding.MirrorInfo{}
+		}
+		writeJSON(w, http.StatusOK, mirrors)
+
+	case http.MethodPost:
+		if _, exists := c.shardManager.GetShardInfo(shardID); !exists {
+			http.Error(w, "Shard not found", http.StatusNotFound)
+			return
+		}
+		if len(c.shardManager.Mirrors(shardID)) >= c.config.Mirrors.MaxPerShard {
+			http.Error(w, "Maximum mirror count reached", http.StatusConflict)
+			return
+		}
+		if _, err := c.checkBudget(); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if !c.startMirrorChange(shardID) {
+			http.Error(w, "A mirror change is in progress or cooling down", http.StatusConflict)
+			return
+		}
+
+		log.Printf("🪞 Manual mirror requested for shard %s", shardID)
+		go func() {
+			defer c.finishMirrorChange(shardID)
+			c.addMirror(shardID, 0)
+		}()
+		writeJSON(w, http.StatusAccepted, map[string]string{"shard_id": shardID, "status": "started"})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleMirror handles DELETE /shards/{id}/mirrors/{mirror}, removing a mirror
+func (c *Coordinator) handleMirror(w http.ResponseWriter, r *http.Request, shardID, mirrorID string) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	found := false
+	for _, mirror := range c.shardManager.Mirrors(shardID) {
+		found = found || mirror.ID == mirrorID
+	}
+	if !found {
+		http.Error(w, "Mirror not found", http.StatusNotFound)
+		return
+	}
+
+	if err := c.removeMirror(mirrorID, 0); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,82 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// MisplacedRowsRequest is the body of POST /misplaced-rows; both fields are
+// optional
+type MisplacedRowsRequest struct {
+	// Relocate moves the rows found to the shard their key routes to
+	Relocate bool `json:"relocate,omitempty"`
+	// Shards restricts the audit; empty audits every active shard
+	Shards []string `json:"shards,omitempty"`
+}
+
+// handleMisplacedRows handles GET /misplaced-rows, reporting the latest audit
+// of rows living on the wrong shard, and POST /misplaced-rows, starting one
+func (c *Coordinator) handleMisplacedRows(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		audit := c.shardManager.MisplacedStatus()
+		if audit == nil {
+			http.Error(w, "No misplaced rows audit has run", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, audit)
+
+	case http.MethodPost:
+		var req MisplacedRowsRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+				return
+			}
+		}
+		for _, shardID := range req.Shards {
+			if _, exists := c.shardManager.GetShardInfo(shardID); !exists {
+				http.Error(w, "Shard "+shardID+" not found", http.StatusNotFound)
+				return
+			}
+		}
+		if audit := c.shardManager.MisplacedStatus(); audit != nil && audit.FinishedAt == nil {
+			http.Error(w, "A misplaced rows audit is already running", http.StatusConflict)
+			return
+		}
+
+		opts := sharding.MisplacedOptions{
+			ShardKeys:        c.splitShardKeys(),
+			Shards:           req.Shards,
+			Relocate:         req.Relocate,
+			BatchSize:        c.config.MisplacedRows.BatchSize,
+			MaxRowsPerSecond: c.config.MisplacedRows.MaxRowsPerSecond,
+		}
+		go c.auditMisplacedRows(opts)
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{"status": "started", "relocate": req.Relocate})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// auditMisplacedRows runs an audit, recording its outcome as a scaling event
+// and marking the shards rows were relocated between for table maintenance
+func (c *Coordinator) auditMisplacedRows(opts sharding.MisplacedOptions) {
+	audit, err := c.shardManager.AuditMisplacedRows(opts)
+	if err != nil {
+		log.Printf("❌ Failed to audit misplaced rows: %v", err)
+		c.recordEvent(ScalingEvent{Target: "cluster", Reason: "misplaced_rows", Status: "failed", Error: err.Error()})
+		return
+	}
+	for _, misplaced := range audit.Misplaced {
+		if misplaced.RowsRelocated > 0 {
+			c.markStatsStale(misplaced.Shard)
+			c.markStatsStale(misplaced.Owner)
+		}
+	}
+	c.recordEvent(ScalingEvent{Target: "cluster", Reason: "misplaced_rows", Value: float64(audit.MisplacedRows), Status: "completed"})
+}
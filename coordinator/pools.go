@@ -0,0 +1,47 @@
+package coordinator
+
+import (
+	"log"
+	"time"
+
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// poolReaperLoop periodically releases the connections of shards that left
+// the topology or are cordoned, so scale-out/in cycles do not leak pools
+func (c *Coordinator) poolReaperLoop() {
+	ticker := time.NewTicker(time.Duration(c.config.Database.Pool.ReapIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			c.reapPools()
+		}
+	}
+}
+
+// reapPools closes the pools of removed, merged and failed shards and drains
+// the idle connections of shards excluded for maintenance
+func (c *Coordinator) reapPools() {
+	for _, shardID := range c.dataStore.ShardIDs() {
+		shardInfo, exists := c.shardManager.GetShardInfo(shardID)
+		if !exists || shardInfo.Status == "removed" || shardInfo.Status == "merged" || shardInfo.Status == "failed" {
+			log.Printf("🧹 Closing connection pools of shard %s, which is no longer active", shardID)
+			c.forgetShard(shardID)
+			continue
+		}
+
+		window, active := c.shardManager.ActiveMaintenance(shardID)
+		cordoned := active && window.Mode == sharding.MaintenanceExcluded
+		if cordoned && !c.dataStore.IsDrained(shardID) {
+			log.Printf("🧹 Draining idle connections of cordoned shard %s", shardID)
+			c.dataStore.DrainShard(shardID)
+		} else if !cordoned && c.dataStore.IsDrained(shardID) {
+			log.Printf("🔌 Restoring connection pooling for shard %s", shardID)
+			c.dataStore.UndrainShard(shardID)
+		}
+	}
+}
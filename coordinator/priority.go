@@ -0,0 +1,24 @@
+package coordinator
+
+import (
+	"sql-horizontal-autoscaler/metrics"
+)
+
+// weightedEntries returns a shard's entry count and entry growth per minute
+// with each table's share weighted by its priority class, so a shard filling
+// up with critical rows scales out sooner than one filling with batch rows.
+// Entries outside the sampled tables keep a weight of one.
+func (c *Coordinator) weightedEntries(shardMetrics *metrics.ShardMetrics) (float64, float64) {
+	entries := float64(shardMetrics.TotalEntries)
+	growth := shardMetrics.EntryGrowthPerMinute
+	if len(c.config.Priorities.Tables) == 0 {
+		return entries, growth
+	}
+
+	for table, count := range shardMetrics.TableCounts {
+		weight := c.config.Priorities.Weights[c.config.TablePriority(table)]
+		entries += (weight - 1) * float64(count)
+		growth += (weight - 1) * shardMetrics.TableGrowthPerMinute[table]
+	}
+	return entries, growth
+}
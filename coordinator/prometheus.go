@@ -0,0 +1,60 @@
+package coordinator
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"sql-horizontal-autoscaler/metrics"
+)
+
+// shardGauges are the per-shard samples exported to Prometheus, by metric name
+var shardGauges = []struct {
+	name  string
+	help  string
+	value func(*metrics.ShardMetrics) float64
+}{
+	{"autoscaler_shard_cpu_percent", "CPU usage of the shard's host.", func(m *metrics.ShardMetrics) float64 { return m.CPUPercent }},
+	{"autoscaler_shard_memory_percent", "Memory usage of the shard's host.", func(m *metrics.ShardMetrics) float64 { return m.MemoryPercent }},
+	{"autoscaler_shard_disk_percent", "Disk usage of the shard's host.", func(m *metrics.ShardMetrics) float64 { return m.DiskPercent }},
+	{"autoscaler_shard_connections", "Connections open on the shard.", func(m *metrics.ShardMetrics) float64 { return float64(m.ConnectionCount) }},
+	{"autoscaler_shard_queries_per_second", "Queries per second served by the shard.", func(m *metrics.ShardMetrics) float64 { return m.QueriesPerSec }},
+	{"autoscaler_shard_entries", "Rows in the shard's sharded tables.", func(m *metrics.ShardMetrics) float64 { return float64(m.TotalEntries) }},
+}
+
+// handlePrometheus handles GET /metrics, exporting each shard's latest sample
+// and its classified query errors in the Prometheus text format
+func (c *Coordinator) handlePrometheus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c.mutex.RLock()
+	samples := make([]metrics.ShardMetrics, 0, len(c.metrics))
+	for _, shardMetrics := range c.metrics {
+		samples = append(samples, *shardMetrics)
+	}
+	c.mutex.RUnlock()
+	sort.Slice(samples, func(i, j int) bool { return samples[i].ShardID < samples[j].ShardID })
+
+	var b strings.Builder
+	for _, gauge := range shardGauges {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", gauge.name, gauge.help, gauge.name)
+		for i := range samples {
+			fmt.Fprintf(&b, "%s{shard=%q} %g\n", gauge.name, samples[i].ShardID, gauge.value(&samples[i]))
+		}
+	}
+
+	b.WriteString("# HELP autoscaler_shard_query_errors_total Statements that failed on the shard, by cause.\n")
+	b.WriteString("# TYPE autoscaler_shard_query_errors_total counter\n")
+	for _, sample := range samples {
+		for _, class := range metrics.ErrorClasses {
+			fmt.Fprintf(&b, "autoscaler_shard_query_errors_total{shard=%q,class=%q} %d\n", sample.ShardID, class, sample.Errors.Count(class))
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
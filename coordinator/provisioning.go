@@ -0,0 +1,126 @@
+package coordinator
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// QueuedScaleOut is a scale-out waiting for or holding a provisioning slot
+type QueuedScaleOut struct {
+	Target string `json:"target"`
+	Reason string `json:"reason"`
+	Zone   string `json:"zone,omitempty"`
+	// Step is the number of shards the scale-out adds, Created of which
+	// are active so far
+	Step      int        `json:"step"`
+	Created   int        `json:"created"`
+	QueuedAt  time.Time  `json:"queued_at"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+}
+
+// queueScaleOut adds a scale-out to the provisioning queue. With dedupe set
+// it returns nil instead when a scale-out for the same target is already
+// queued or running.
+func (c *Coordinator) queueScaleOut(target, reason, zone string, step int, dedupe bool) *QueuedScaleOut {
+	c.provisionMutex.Lock()
+	defer c.provisionMutex.Unlock()
+
+	if dedupe {
+		for _, queued := range c.scaleOuts {
+			if queued.Target == target {
+				return nil
+			}
+		}
+	}
+	queued := &QueuedScaleOut{Target: target, Reason: reason, Zone: zone, Step: step, QueuedAt: time.Now()}
+	c.scaleOuts = append(c.scaleOuts, queued)
+	return queued
+}
+
+// startScaleOut waits for a provisioning slot, so at most
+// limits.max_concurrent_provisions scale-outs provision shards at once and
+// the rest run in the order they were queued. It returns false if the
+// coordinator stops first.
+func (c *Coordinator) startScaleOut(queued *QueuedScaleOut) bool {
+	select {
+	case c.provisionSlots <- struct{}{}:
+	case <-c.stopChan:
+		return false
+	}
+	c.provisionMutex.Lock()
+	now := time.Now()
+	queued.StartedAt = &now
+	c.provisionMutex.Unlock()
+	return true
+}
+
+// scaleOutCreated counts a shard a running scale-out made active
+func (c *Coordinator) scaleOutCreated(queued *QueuedScaleOut) {
+	c.provisionMutex.Lock()
+	defer c.provisionMutex.Unlock()
+	queued.Created++
+}
+
+// finishScaleOut removes a scale-out from the queue, releasing its slot if
+// it started
+func (c *Coordinator) finishScaleOut(queued *QueuedScaleOut) {
+	c.provisionMutex.Lock()
+	defer c.provisionMutex.Unlock()
+
+	for i, other := range c.scaleOuts {
+		if other == queued {
+			c.scaleOuts = append(c.scaleOuts[:i], c.scaleOuts[i+1:]...)
+			break
+		}
+	}
+	if queued.StartedAt != nil {
+		<-c.provisionSlots
+	}
+}
+
+// pendingShards returns the number of shards queued scale-outs have yet to
+// make active, which count against limits.max_shards
+func (c *Coordinator) pendingShards() int {
+	c.provisionMutex.Lock()
+	defer c.provisionMutex.Unlock()
+
+	pending := 0
+	for _, queued := range c.scaleOuts {
+		pending += queued.Step - queued.Created
+	}
+	return pending
+}
+
+// handleScaleQueue handles GET /scale/queue, listing the scale-outs running
+// and waiting for a provisioning slot, oldest first
+func (c *Coordinator) handleScaleQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c.provisionMutex.Lock()
+	queue := make([]QueuedScaleOut, 0, len(c.scaleOuts))
+	for _, queued := range c.scaleOuts {
+		queue = append(queue, *queued)
+	}
+	c.provisionMutex.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"max_concurrent_provisions": cap(c.provisionSlots),
+		"scale_outs":                queue,
+	})
+}
+
+// provisioningLogs returns the container output kept with a shard that
+// failed to provision, or "" for other errors
+func provisioningLogs(err error) string {
+	var provisioningErr *sharding.ProvisioningError
+	if errors.As(err, &provisioningErr) {
+		return provisioningErr.Logs
+	}
+	return ""
+}
@@ -0,0 +1,141 @@
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"sql-horizontal-autoscaler/proxyexport"
+)
+
+// proxyExportLoop renders the topology into proxy configuration on startup and
+// whenever it changes, retrying failed exports on the next tick
+func (c *Coordinator) proxyExportLoop() {
+	ticker := time.NewTicker(time.Duration(c.config.ProxyExport.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	lastVersion, lastContent := "", ""
+	for {
+		version := c.shardManager.Topology().Version
+		if version != lastVersion {
+			content, err := c.exportProxyConfig(lastContent)
+			if err != nil {
+				log.Printf("Warning: Failed to export %s config for topology %s: %v", c.config.ProxyExport.Format, version, err)
+			} else {
+				lastVersion, lastContent = version, content
+			}
+		}
+
+		select {
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// exportProxyConfig writes and pushes the proxy configuration of the current
+// shards unless it renders the same as previous, returning what it rendered
+func (c *Coordinator) exportProxyConfig(previous string) (string, error) {
+	exportConfig := c.config.ProxyExport
+	backends := c.proxyBackends()
+	content, err := proxyexport.Render(exportConfig.Format, backends, c.proxyExportOptions())
+	if err != nil || content == previous {
+		return content, err
+	}
+
+	if exportConfig.OutputFile != "" {
+		if err := proxyexport.WriteFile(exportConfig.OutputFile, content); err != nil {
+			return "", err
+		}
+		if len(exportConfig.ReloadCommand) > 0 {
+			if err := runReloadCommand(exportConfig.ReloadCommand); err != nil {
+				return "", err
+			}
+		}
+	}
+	if exportConfig.AdminDSN != "" {
+		statements := proxyexport.ProxySQLStatements(backends, c.proxyExportOptions())
+		if err := proxyexport.PushProxySQL(exportConfig.AdminDSN, statements, 10*time.Second); err != nil {
+			return "", err
+		}
+	}
+
+	log.Printf("🔀 Exported %s config for %d shards", exportConfig.Format, len(backends))
+	return content, nil
+}
+
+// proxyBackends returns the shards serving traffic with the address a proxy
+// reaches them at: the provisioned host, else the host of the shard's DSN
+func (c *Coordinator) proxyBackends() []proxyexport.Backend {
+	var backends []proxyexport.Backend
+	for shardID, shardInfo := range c.shardManager.GetAllShardInfo() {
+		if shardInfo.Status != "active" && shardInfo.Status != "merging" {
+			continue
+		}
+		host := shardInfo.Host
+		if host == "" {
+			host = "127.0.0.1"
+			if dsnConfig, err := mysql.ParseDSN(shardInfo.DSN); err == nil {
+				if dsnHost, _, err := net.SplitHostPort(dsnConfig.Addr); err == nil && dsnHost != "" {
+					host = dsnHost
+				}
+			}
+		}
+		backends = append(backends, proxyexport.Backend{ShardID: shardID, Host: host, Port: shardInfo.Port})
+	}
+	return backends
+}
+
+// proxyExportOptions returns the rendering options of the configured export
+func (c *Coordinator) proxyExportOptions() proxyexport.Options {
+	exportConfig := c.config.ProxyExport
+	return proxyexport.Options{
+		HostgroupBase: exportConfig.HostgroupBase,
+		RuleIDBase:    exportConfig.RuleIDBase,
+		BackendName:   exportConfig.BackendName,
+	}
+}
+
+// runReloadCommand tells the proxy to pick up the rewritten file
+func runReloadCommand(command []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, command[0], command[1:]...).CombinedOutput()
+	if err != nil {
+		if trimmed := strings.TrimSpace(string(output)); trimmed != "" {
+			return fmt.Errorf("reload command %s failed: %w: %s", command[0], err, trimmed)
+		}
+		return fmt.Errorf("reload command %s failed: %w", command[0], err)
+	}
+	return nil
+}
+
+// handleProxyConfig handles GET /proxy-config, rendering the current shards
+// as proxy configuration; ?format= overrides the configured format
+func (c *Coordinator) handleProxyConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = c.config.ProxyExport.Format
+	}
+	content, err := proxyexport.Render(format, c.proxyBackends(), c.proxyExportOptions())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(content))
+}
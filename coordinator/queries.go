@@ -0,0 +1,114 @@
+package coordinator
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sql-horizontal-autoscaler/datastore"
+)
+
+// handleQueries handles GET /admin/queries, listing active queries per shard
+func (c *Coordinator) handleQueries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shardIDs := c.dataStore.ShardIDs()
+	if shardID := r.URL.Query().Get("shard"); shardID != "" {
+		shardIDs = []string{shardID}
+	}
+
+	result := make(map[string][]datastore.ActiveQuery, len(shardIDs))
+	errors := make(map[string]string)
+	for _, shardID := range shardIDs {
+		queries, err := c.dataStore.ActiveQueries(shardID)
+		if err != nil {
+			errors[shardID] = err.Error()
+			continue
+		}
+		result[shardID] = queries
+	}
+
+	response := map[string]interface{}{"queries": result}
+	if len(errors) > 0 {
+		response["errors"] = errors
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleQueryRoutes handles DELETE /admin/queries/{shard}:{process id}
+func (c *Coordinator) handleQueryRoutes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shardID, processID, err := parseQueryID(strings.TrimPrefix(r.URL.Path, "/admin/queries/"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := c.dataStore.KillQuery(shardID, processID); err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+
+	log.Printf("🔪 Killed query %d on shard %s on request", processID, shardID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseQueryID splits a query ID of the form "shard-1:1234"
+func parseQueryID(id string) (string, int64, error) {
+	idx := strings.LastIndex(id, ":")
+	if idx <= 0 {
+		return "", 0, fmt.Errorf("query id must have the form <shard>:<process id>")
+	}
+
+	processID, err := strconv.ParseInt(id[idx+1:], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid process id %q", id[idx+1:])
+	}
+	return id[:idx], processID, nil
+}
+
+// queryReaperLoop kills the statements the autoscaler runs for clients that
+// run longer than the configured execution-time ceiling. Its own copies,
+// index builds and purges aren't tagged as client statements and may run as
+// long as they need.
+func (c *Coordinator) queryReaperLoop() {
+	ceiling := int64(c.config.Queries.MaxExecutionSeconds)
+	ticker := time.NewTicker(time.Duration(c.config.Queries.ReapIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			for _, shardID := range c.dataStore.ShardIDs() {
+				queries, err := c.dataStore.ActiveQueries(shardID)
+				if err != nil {
+					log.Printf("Warning: Failed to list queries on shard %s: %v", shardID, err)
+					continue
+				}
+
+				for _, q := range queries {
+					if !q.Client || q.TimeSeconds < ceiling {
+						continue
+					}
+					if err := c.dataStore.KillQuery(shardID, q.ProcessID); err != nil {
+						log.Printf("Warning: %v", err)
+						continue
+					}
+					log.Printf("🔪 Killed runaway query %s after %ds (ceiling %ds): %s", q.ID, q.TimeSeconds, ceiling, q.Query)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package coordinator
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"sql-horizontal-autoscaler/metrics"
+	"sql-horizontal-autoscaler/notifier"
+)
+
+// alertedErrorClasses are the error classes that alert operators, with the
+// severity of their alert
+var alertedErrorClasses = map[string]string{
+	metrics.ErrorDiskFull:           notifier.SeverityCritical,
+	metrics.ErrorTooManyConnections: notifier.SeverityWarning,
+}
+
+// shardQueryErrors returns the classified errors of the statements that
+// failed on a shard, counted by this process's datastore, which a colocated
+// router shares, and by the routers reporting them in their heartbeats
+func (c *Coordinator) shardQueryErrors(shardID string) metrics.QueryErrors {
+	queryErrors := c.dataStore.ShardCounters()[shardID].ErrorClasses
+
+	c.routerMutex.RLock()
+	defer c.routerMutex.RUnlock()
+	for _, router := range c.routers {
+		queryErrors = queryErrors.Plus(router.Stats.ShardErrors[shardID])
+	}
+	return queryErrors
+}
+
+// setQueryErrors sets a sample's error counts and the errors new since the
+// shard's previous sample, if any
+func setQueryErrors(current, previous *metrics.ShardMetrics, queryErrors metrics.QueryErrors) {
+	current.Errors = queryErrors
+	current.NewErrors = metrics.QueryErrors{}
+	if previous != nil {
+		current.NewErrors = queryErrors.Since(previous.Errors)
+	}
+}
+
+// analyzeQueryErrors scales out shards on which statements fail because the
+// shard ran out of connections or disk, at or above their thresholds per
+// monitoring interval. Callers must hold c.mutex.
+func (c *Coordinator) analyzeQueryErrors(shardID string, shardMetrics *metrics.ShardMetrics) {
+	thresholds := c.config.ScalingThresholds
+	if threshold := thresholds.TooManyConnectionErrors; threshold > 0 && shardMetrics.NewErrors.TooManyConnections >= threshold {
+		log.Printf("HOT SCALING TRIGGERED: Shard %s refused %d statements for too many connections (threshold: %d)",
+			shardID, shardMetrics.NewErrors.TooManyConnections, threshold)
+		c.triggerScaling(shardID, metrics.ErrorTooManyConnections, float64(shardMetrics.NewErrors.TooManyConnections), 1)
+	}
+	if threshold := thresholds.DiskFullErrors; threshold > 0 && shardMetrics.NewErrors.DiskFull >= threshold {
+		log.Printf("HOT SCALING TRIGGERED: Shard %s failed %d writes on a full disk (threshold: %d)",
+			shardID, shardMetrics.NewErrors.DiskFull, threshold)
+		c.triggerScaling(shardID, metrics.ErrorDiskFull, float64(shardMetrics.NewErrors.DiskFull), 1)
+	}
+}
+
+// updateErrorAlerts alerts once when statements start failing on a shard
+// for a full disk or too many connections, and again once a sample passes
+// without such failures
+func (c *Coordinator) updateErrorAlerts() {
+	var alerts []notifier.Alert
+
+	c.mutex.Lock()
+	shardIDs := make([]string, 0, len(c.metrics))
+	for shardID := range c.metrics {
+		shardIDs = append(shardIDs, shardID)
+	}
+	sort.Strings(shardIDs)
+
+	failing := make(map[string]bool)
+	for _, shardID := range shardIDs {
+		newErrors := c.metrics[shardID].NewErrors
+		for _, class := range metrics.ErrorClasses {
+			severity, alerted := alertedErrorClasses[class]
+			if !alerted {
+				continue
+			}
+			key := shardID + "/" + class
+			count := newErrors.Count(class)
+			switch {
+			case count > 0 && !c.erroringShards[key]:
+				log.Printf("⚠️  %d statements failed on shard %s with %s errors", count, shardID, class)
+				alerts = append(alerts, notifier.Alert{
+					Severity: severity,
+					Title:    "Shard query errors",
+					Message:  fmt.Sprintf("%d statements failed on shard %s with %s errors since the previous sample", count, shardID, class),
+					Details: map[string]interface{}{
+						"shard_id": shardID,
+						"class":    class,
+						"count":    count,
+					},
+				})
+				failing[key] = true
+
+			case count == 0 && c.erroringShards[key]:
+				log.Printf("✅ Statements stopped failing on shard %s with %s errors", shardID, class)
+				alerts = append(alerts, notifier.Alert{
+					Severity: notifier.SeverityInfo,
+					Title:    "Shard query errors stopped",
+					Message:  fmt.Sprintf("No statements failed on shard %s with %s errors since the previous sample", shardID, class),
+					Details:  map[string]interface{}{"shard_id": shardID, "class": class},
+				})
+
+			case count > 0:
+				failing[key] = true
+			}
+		}
+	}
+	c.erroringShards = failing
+	c.mutex.Unlock()
+
+	for _, alert := range alerts {
+		c.notify(alert)
+	}
+}
@@ -0,0 +1,220 @@
+package coordinator
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"sql-horizontal-autoscaler/datastore"
+)
+
+// Ramp statuses
+const (
+	RampRamping    = "ramping"
+	RampPaused     = "paused"
+	RampCompleted  = "completed"
+	RampRolledBack = "rolled_back"
+)
+
+// RampState is the progress of shifting traffic onto a scaled-out shard
+type RampState struct {
+	ShardID string `json:"shard_id"`
+	// Weight is the fraction of its keys the shard owns
+	Weight    float64   `json:"weight"`
+	Step      int       `json:"step"`
+	Steps     int       `json:"steps"`
+	Status    string    `json:"status"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Reason explains the last pause or rollback
+	Reason string `json:"reason,omitempty"`
+	// ErrorRate and LatencyMs are those of the last step judged
+	ErrorRate float64 `json:"error_rate"`
+	LatencyMs float64 `json:"latency_ms"`
+
+	// baseline holds the shard's counters at the start of the step
+	baseline datastore.ShardCounters
+}
+
+// startRamp starts raising a scaled-out shard's ring weight from the weight
+// it joined with
+func (c *Coordinator) startRamp(shardID string) {
+	if !c.config.Ramp.Enabled {
+		return
+	}
+
+	c.rampMutex.Lock()
+	defer c.rampMutex.Unlock()
+
+	now := time.Now()
+	c.ramps[shardID] = &RampState{
+		ShardID:   shardID,
+		Weight:    c.config.Ramp.StartWeight(),
+		Step:      1,
+		Steps:     c.config.Ramp.Steps,
+		Status:    RampRamping,
+		StartedAt: now,
+		UpdatedAt: now,
+		baseline:  c.dataStore.ShardCounters()[shardID],
+	}
+	log.Printf("📶 Ramping traffic onto shard %s: step 1/%d at weight %.2f", shardID, c.config.Ramp.Steps, c.config.Ramp.StartWeight())
+}
+
+// rampLoop advances the ramps once per step
+func (c *Coordinator) rampLoop() {
+	interval := time.Duration(c.config.Ramp.DurationSeconds) * time.Second / time.Duration(c.config.Ramp.Steps)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.advanceRamps()
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+// advanceRamps judges the step each ramping or paused shard just went
+// through by its error rate and latency. A healthy step raises the weight,
+// resuming a paused ramp; a misbehaving shard is paused or rolled back.
+// Steps with too few statements to judge count as healthy.
+func (c *Coordinator) advanceRamps() {
+	counters := c.dataStore.ShardCounters()
+
+	c.rampMutex.Lock()
+	defer c.rampMutex.Unlock()
+
+	for shardID, ramp := range c.ramps {
+		if _, exists := c.shardManager.GetShardInfo(shardID); !exists {
+			delete(c.ramps, shardID)
+			continue
+		}
+		if ramp.Status != RampRamping && ramp.Status != RampPaused {
+			continue
+		}
+
+		current, baseline := counters[shardID], ramp.baseline
+		ramp.baseline, ramp.UpdatedAt = current, time.Now()
+		statements := (current.Reads - baseline.Reads) + (current.Writes - baseline.Writes)
+		if statements >= c.config.Ramp.MinStatements {
+			ramp.ErrorRate = float64(current.Errors-baseline.Errors) / float64(statements)
+			ramp.LatencyMs = (current.TimeMs - baseline.TimeMs) / float64(statements)
+
+			var problem string
+			if ramp.ErrorRate > c.config.Ramp.MaxErrorRate {
+				problem = fmt.Sprintf("error rate %.1f%% exceeds %.1f%%", ramp.ErrorRate*100, c.config.Ramp.MaxErrorRate*100)
+			} else if ramp.LatencyMs > c.config.Ramp.MaxLatencyMs {
+				problem = fmt.Sprintf("latency %.1fms exceeds %.1fms", ramp.LatencyMs, c.config.Ramp.MaxLatencyMs)
+			}
+			if problem != "" {
+				if c.config.Ramp.OnFailure == "rollback" {
+					c.rollBackRamp(ramp, problem)
+				} else if ramp.Status != RampPaused {
+					ramp.Status, ramp.Reason = RampPaused, problem
+					log.Printf("⏸️  Ramp of shard %s paused at weight %.2f: %s", shardID, ramp.Weight, problem)
+					c.recordEvent(ScalingEvent{Target: shardID, Reason: "ramp", Value: ramp.Weight, ShardID: shardID, Status: RampPaused, Error: problem})
+				}
+				continue
+			}
+		}
+
+		if ramp.Status == RampPaused {
+			log.Printf("▶️  Ramp of shard %s resumed", shardID)
+			ramp.Status, ramp.Reason = RampRamping, ""
+		}
+		c.raiseRamp(ramp)
+	}
+}
+
+// raiseRamp moves a ramp to its next step, completing it at the last. Must be
+// called with rampMutex held.
+func (c *Coordinator) raiseRamp(ramp *RampState) {
+	ramp.Step++
+	ramp.Weight = float64(ramp.Step) / float64(ramp.Steps)
+	c.shardManager.SetRingWeight(ramp.ShardID, ramp.Weight)
+
+	if ramp.Step >= ramp.Steps {
+		ramp.Status, ramp.Weight = RampCompleted, 1
+		log.Printf("📶 Ramp of shard %s completed, shard at full weight", ramp.ShardID)
+		c.recordEvent(ScalingEvent{Target: ramp.ShardID, Reason: "ramp", Value: 1, ShardID: ramp.ShardID, Status: "completed"})
+		return
+	}
+	log.Printf("📶 Ramping shard %s: step %d/%d at weight %.2f", ramp.ShardID, ramp.Step, ramp.Steps, ramp.Weight)
+}
+
+// rollBackRamp takes every key away from a ramping shard, leaving it in the
+// cluster without traffic for an operator to inspect or merge away. Must be
+// called with rampMutex held.
+func (c *Coordinator) rollBackRamp(ramp *RampState, reason string) {
+	c.shardManager.SetRingWeight(ramp.ShardID, 0)
+	ramp.Status, ramp.Reason, ramp.Weight = RampRolledBack, reason, 0
+	log.Printf("⏪ Ramp of shard %s rolled back: %s", ramp.ShardID, reason)
+	c.recordEvent(ScalingEvent{Target: ramp.ShardID, Reason: "ramp", ShardID: ramp.ShardID, Status: "failed", Error: "rolled back: " + reason})
+}
+
+// handleRamps handles GET /ramps, listing the traffic ramps of scaled-out
+// shards
+func (c *Coordinator) handleRamps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c.rampMutex.Lock()
+	ramps := make([]RampState, 0, len(c.ramps))
+	for _, ramp := range c.ramps {
+		ramps = append(ramps, *ramp)
+	}
+	c.rampMutex.Unlock()
+
+	sort.Slice(ramps, func(i, j int) bool { return ramps[i].ShardID < ramps[j].ShardID })
+	writeJSON(w, http.StatusOK, ramps)
+}
+
+// handleRampRoutes handles POST /ramps/{shard}/pause, /resume and /rollback,
+// letting an operator hold a ramp, continue it, or take the shard's keys away
+func (c *Coordinator) handleRampRoutes(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/ramps/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c.rampMutex.Lock()
+	defer c.rampMutex.Unlock()
+
+	ramp, exists := c.ramps[parts[0]]
+	if !exists {
+		http.Error(w, "Ramp not found", http.StatusNotFound)
+		return
+	}
+	if ramp.Status == RampCompleted || ramp.Status == RampRolledBack {
+		http.Error(w, "Ramp already "+ramp.Status, http.StatusConflict)
+		return
+	}
+
+	switch parts[1] {
+	case "pause":
+		ramp.Status, ramp.Reason = RampPaused, "paused by operator"
+		log.Printf("⏸️  Ramp of shard %s paused by operator", ramp.ShardID)
+	case "resume":
+		ramp.Status, ramp.Reason = RampRamping, ""
+		log.Printf("▶️  Ramp of shard %s resumed by operator", ramp.ShardID)
+	case "rollback":
+		c.rollBackRamp(ramp, "rolled back by operator")
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	ramp.UpdatedAt = time.Now()
+	writeJSON(w, http.StatusOK, ramp)
+}
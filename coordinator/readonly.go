@@ -0,0 +1,94 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// ReadOnlyRequest is the optional body of PUT /shards/{id}/readonly
+type ReadOnlyRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// ReadOnlyResponse reports a shard's write freeze. MySQLError is set when the
+// router rejects writes but super_read_only could not be set on the shard.
+type ReadOnlyResponse struct {
+	sharding.ReadOnlyShard
+	MySQLError string `json:"mysql_error,omitempty"`
+}
+
+// handleReadOnly handles GET/PUT/DELETE /shards/{id}/readonly. PUT freezes
+// writes to the shard both in the router and with super_read_only on the
+// instance, until DELETE lifts the freeze.
+func (c *Coordinator) handleReadOnly(w http.ResponseWriter, r *http.Request, shardID string) {
+	switch r.Method {
+	case http.MethodGet:
+		state, frozen := c.shardManager.GetReadOnly(shardID)
+		if !frozen {
+			http.Error(w, "Shard is not read-only", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, ReadOnlyResponse{ReadOnlyShard: *state})
+
+	case http.MethodPut:
+		var req ReadOnlyRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+				return
+			}
+		}
+
+		// The router stops sending writes before the instance starts failing them
+		state := sharding.ReadOnlyShard{ShardID: shardID, Reason: req.Reason, Since: time.Now()}
+		if existing, frozen := c.shardManager.GetReadOnly(shardID); frozen {
+			state.Since = existing.Since
+		}
+		if err := c.shardManager.SetReadOnly(state); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		response := ReadOnlyResponse{}
+		if err := c.dataStore.SetSuperReadOnly(shardID, true); err != nil {
+			log.Printf("Warning: Shard %s is read-only in the router only: %v", shardID, err)
+			response.MySQLError = err.Error()
+		} else {
+			state.SuperReadOnly = true
+			c.shardManager.SetReadOnly(state)
+		}
+		response.ReadOnlyShard = state
+
+		log.Printf("🧊 Shard %s is read-only (%s)", shardID, state.Reason)
+		c.recordEvent(ScalingEvent{Target: shardID, Reason: "read_only", ShardID: shardID, Status: "frozen"})
+		writeJSON(w, http.StatusOK, response)
+
+	case http.MethodDelete:
+		state, frozen := c.shardManager.GetReadOnly(shardID)
+		if !frozen {
+			http.Error(w, "Shard is not read-only", http.StatusNotFound)
+			return
+		}
+
+		// Writes only resume in the router once the instance accepts them
+		if err := c.dataStore.SetSuperReadOnly(shardID, false); err != nil {
+			if state.SuperReadOnly {
+				writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+				return
+			}
+			log.Printf("Warning: %v", err)
+		}
+		c.shardManager.ClearReadOnly(shardID)
+
+		log.Printf("🧊 Shard %s accepts writes again", shardID)
+		c.recordEvent(ScalingEvent{Target: shardID, Reason: "read_only", ShardID: shardID, Status: "cleared"})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,91 @@
+package coordinator
+
+import (
+	"net/http"
+	"sort"
+)
+
+// RegionStats aggregates the latest metrics of the shards whose primary runs
+// in one region, and of the replicas running there
+type RegionStats struct {
+	Region               string   `json:"region"`
+	Shards               []string `json:"shards"`
+	AvgCPU               float64  `json:"avg_cpu_percent"`
+	AvgMemory            float64  `json:"avg_memory_percent"`
+	TotalEntries         int64    `json:"total_entries"`
+	QueriesPerSec        float64  `json:"queries_per_second"`
+	ReadsPerSec          float64  `json:"reads_per_second"`
+	Replicas             int      `json:"replicas"`
+	HealthyReplicas      int      `json:"healthy_replicas"`
+	MaxReplicaLagSeconds int64    `json:"max_replica_lag_seconds"`
+}
+
+// computeRegionStats aggregates the collected metrics per region. Callers
+// must hold c.mutex.
+func (c *Coordinator) computeRegionStats() []*RegionStats {
+	stats := make(map[string]*RegionStats)
+	region := func(name string) *RegionStats {
+		regionStats, exists := stats[name]
+		if !exists {
+			regionStats = &RegionStats{Region: name, Shards: []string{}}
+			stats[name] = regionStats
+		}
+		return regionStats
+	}
+
+	for shardID, shardMetrics := range c.freshMetrics() {
+		shardRegion := c.shardManager.ShardRegion(shardID)
+		regionStats := region(shardRegion)
+		regionStats.Shards = append(regionStats.Shards, shardID)
+		regionStats.AvgCPU += shardMetrics.CPUPercent
+		regionStats.AvgMemory += shardMetrics.MemoryPercent
+		regionStats.TotalEntries += shardMetrics.TotalEntries
+		regionStats.QueriesPerSec += shardMetrics.QueriesPerSec
+		regionStats.ReadsPerSec += shardMetrics.ReadsPerSec
+
+		for _, replica := range shardMetrics.Replicas {
+			replicaStats := regionStats
+			if replica.Region != "" {
+				replicaStats = region(replica.Region)
+			}
+			replicaStats.Replicas++
+			if replica.Healthy {
+				replicaStats.HealthyReplicas++
+				if replica.LagSeconds > replicaStats.MaxReplicaLagSeconds {
+					replicaStats.MaxReplicaLagSeconds = replica.LagSeconds
+				}
+			}
+		}
+	}
+
+	regions := make([]*RegionStats, 0, len(stats))
+	for _, regionStats := range stats {
+		if shards := len(regionStats.Shards); shards > 0 {
+			sort.Strings(regionStats.Shards)
+			regionStats.AvgCPU /= float64(shards)
+			regionStats.AvgMemory /= float64(shards)
+		}
+		regions = append(regions, regionStats)
+	}
+	sort.Slice(regions, func(i, j int) bool { return regions[i].Region < regions[j].Region })
+	return regions
+}
+
+// handleRegions handles GET /regions, reporting the latest per-region
+// aggregates. Shards and replicas without a region are grouped under "".
+func (c *Coordinator) handleRegions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c.mutex.RLock()
+	regions := c.computeRegionStats()
+	c.mutex.RUnlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"local":        c.config.Regions.Local,
+		"write_policy": c.config.Regions.WritePolicy,
+		"regions":      regions,
+	})
+}
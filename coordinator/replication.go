@@ -0,0 +1,153 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"sql-horizontal-autoscaler/notifier"
+)
+
+// PromoteRequest is the optional body of POST /shards/{id}/promote. Without
+// a replica the least lagged one is promoted.
+type PromoteRequest struct {
+	Replica string `json:"replica,omitempty"`
+}
+
+// checkFailover promotes a replica of every shard whose primary has failed
+// metrics collection too many times in a row
+func (c *Coordinator) checkFailover() {
+	if !c.config.Database.Replication.AutoFailover {
+		return
+	}
+
+	c.backoffMutex.Lock()
+	var failed []string
+	for shardID, b := range c.backoff {
+		if b.failures >= c.config.Database.Replication.FailoverAfterFailures {
+			failed = append(failed, shardID)
+		}
+	}
+	c.backoffMutex.Unlock()
+
+	for _, shardID := range failed {
+		if len(c.dataStore.ReplicaStatuses(shardID)) == 0 {
+			continue
+		}
+		log.Printf("🚨 Primary of shard %s unreachable, failing over", shardID)
+		c.promoteReplica(shardID, "", "failover")
+	}
+}
+
+// chooseReplica picks the replica to promote: the least lagged one whose SQL
+// thread is running, as it can still apply what it received. Mirrors are
+// left out, since the coordinator removes them as read load drops.
+func (c *Coordinator) chooseReplica(shardID string) (string, error) {
+	mirrors := make(map[string]bool)
+	for _, mirror := range c.shardManager.Mirrors(shardID) {
+		mirrors[net.JoinHostPort(mirror.Host, strconv.Itoa(mirror.Port))] = true
+	}
+
+	best, bestLag := "", int64(-1)
+	for _, status := range c.dataStore.ReplicaStatuses(shardID) {
+		if !status.SQLRunning || mirrors[status.Address] {
+			continue
+		}
+		if bestLag < 0 || status.LagSeconds < bestLag {
+			best, bestLag = status.Name, status.LagSeconds
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no replica of shard %s is replicating", shardID)
+	}
+	return best, nil
+}
+
+// promoteReplica makes a replica, chosen when name is empty, the primary of
+// a shard and points the cluster at it, recording and announcing the
+// outcome. It returns the promoted replica's name.
+func (c *Coordinator) promoteReplica(shardID, name, reason string) (string, error) {
+	c.failoverMutex.Lock()
+	defer c.failoverMutex.Unlock()
+
+	var err error
+	if name == "" {
+		name, err = c.chooseReplica(shardID)
+	}
+	var dsn string
+	if err == nil {
+		dsn, err = c.dataStore.PromoteReplica(shardID, name)
+	}
+	if err == nil {
+		err = c.shardManager.SetShardDSN(shardID, dsn)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to promote a replica of shard %s: %v", shardID, err)
+		c.recordEvent(ScalingEvent{Target: shardID, Reason: reason, ShardID: name, Status: "failed", Error: err.Error()})
+		c.notify(notifier.Alert{
+			Severity: notifier.SeverityCritical,
+			Title:    "Replica promotion failed",
+			Message:  fmt.Sprintf("No replica of shard %s could be promoted: %v", shardID, err),
+			Details:  map[string]interface{}{"shard_id": shardID, "replica": name},
+		})
+		return name, err
+	}
+
+	c.mutex.Lock()
+	c.config.SetShard(shardID, dsn)
+	if configured, exists := c.config.Database.Replicas[shardID]; exists {
+		var replicas []string
+		for _, replicaDSN := range configured {
+			if replicaDSN != dsn {
+				replicas = append(replicas, replicaDSN)
+			}
+		}
+		c.config.Database.Replicas[shardID] = replicas
+	}
+	c.mutex.Unlock()
+
+	// Collection resumes against the new primary right away
+	c.backoffMutex.Lock()
+	delete(c.backoff, shardID)
+	c.backoffMutex.Unlock()
+
+	c.recordEvent(ScalingEvent{Target: shardID, Reason: reason, ShardID: name, Status: "completed"})
+	c.notify(notifier.Alert{
+		Severity: notifier.SeverityWarning,
+		Title:    "Replica promoted",
+		Message:  fmt.Sprintf("Replica %s is now the primary of shard %s", name, shardID),
+		Details:  map[string]interface{}{"shard_id": shardID, "replica": name, "reason": reason},
+	})
+	return name, nil
+}
+
+// handlePromote handles POST /shards/{id}/promote, promoting a replica of a
+// shard on an operator's request
+func (c *Coordinator) handlePromote(w http.ResponseWriter, r *http.Request, shardID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req PromoteRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+			return
+		}
+	}
+	if _, exists := c.shardManager.GetShardInfo(shardID); !exists {
+		http.Error(w, "Shard not found", http.StatusNotFound)
+		return
+	}
+
+	promoted, err := c.promoteReplica(shardID, req.Replica, "promote")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"shard_id": shardID, "primary": promoted})
+}
@@ -0,0 +1,127 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// ResyncRequest is the body of POST /shards/{id}/resync. Exactly one of Mirror
+// and SourceDSN names the good copy; the other fields are optional.
+type ResyncRequest struct {
+	Mirror            string `json:"mirror,omitempty"`
+	SourceDSN         string `json:"source_dsn,omitempty"`
+	ChunkSize         int    `json:"chunk_size,omitempty"`
+	MaxBytesPerSecond *int64 `json:"max_bytes_per_second,omitempty"`
+}
+
+// handleResync handles POST /shards/{id}/resync, starting a checksum resync
+// of the shard from one of its mirrors or another server. Progress is
+// reported by GET /resyncs.
+func (c *Coordinator) handleResync(w http.ResponseWriter, r *http.Request, shardID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ResyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+		return
+	}
+	if (req.Mirror == "") == (req.SourceDSN == "") {
+		http.Error(w, "Request body must set one of \"mirror\" and \"source_dsn\"", http.StatusBadRequest)
+		return
+	}
+	if _, exists := c.shardManager.GetShardInfo(shardID); !exists {
+		http.Error(w, "Shard not found", http.StatusNotFound)
+		return
+	}
+
+	opts := sharding.ResyncOptions{
+		Source:            "source_dsn",
+		SourceDSN:         req.SourceDSN,
+		ChunkSize:         c.config.Resync.ChunkSize,
+		MaxBytesPerSecond: c.config.Resync.MaxBytesPerSecond,
+	}
+	if req.Mirror != "" {
+		for _, mirror := range c.shardManager.Mirrors(shardID) {
+			if mirror.ID == req.Mirror && mirror.Status == sharding.MirrorActive {
+				opts.Source, opts.SourceDSN = mirror.ID, mirror.DSN
+			}
+		}
+		if opts.SourceDSN == "" {
+			http.Error(w, "Shard "+shardID+" has no active mirror "+req.Mirror, http.StatusNotFound)
+			return
+		}
+	}
+	if req.ChunkSize > 0 {
+		opts.ChunkSize = req.ChunkSize
+	}
+	if req.MaxBytesPerSecond != nil {
+		opts.MaxBytesPerSecond = *req.MaxBytesPerSecond
+	}
+	if status := c.shardManager.ResyncStatus(shardID); status != nil && status.FinishedAt == nil {
+		http.Error(w, "Shard "+shardID+" is already being resynced", http.StatusConflict)
+		return
+	}
+
+	go c.resyncShard(shardID, opts)
+	writeJSON(w, http.StatusAccepted, map[string]string{"shard_id": shardID, "source": opts.Source, "status": "started"})
+}
+
+// resyncShard runs a checksum resync, recording the outcome as a scaling event
+func (c *Coordinator) resyncShard(shardID string, opts sharding.ResyncOptions) {
+	progress, err := c.shardManager.ResyncShard(shardID, opts)
+	if err != nil {
+		log.Printf("❌ Failed to resync shard %s from %s: %v", shardID, opts.Source, err)
+		c.recordEvent(ScalingEvent{Target: shardID, Reason: "resync", Status: "failed", Error: err.Error()})
+		return
+	}
+	if progress.RowsCopied > 0 || progress.RowsDeleted > 0 {
+		c.markStatsStale(shardID)
+	}
+	c.recordEvent(ScalingEvent{Target: shardID, Reason: "resync", Value: float64(progress.ChunksDiverged), Status: "completed"})
+}
+
+// handleResyncs handles GET /resyncs, reporting the progress of every resync
+func (c *Coordinator) handleResyncs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resyncs := c.shardManager.Resyncs()
+	sort.Slice(resyncs, func(i, j int) bool { return resyncs[i].StartedAt.After(resyncs[j].StartedAt) })
+	writeJSON(w, http.StatusOK, resyncs)
+}
+
+// handleResyncRoutes handles POST /resyncs/{id}/throttle, changing the copy
+// rate of a running resync
+func (c *Coordinator) handleResyncRoutes(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/resyncs/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "throttle" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ThrottleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MaxBytesPerSecond < 0 {
+		http.Error(w, "Request body must set a non-negative \"max_bytes_per_second\"", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.shardManager.SetResyncThrottle(parts[0], req.MaxBytesPerSecond); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, c.shardManager.ResyncStatus(parts[0]))
+}
@@ -0,0 +1,224 @@
+package coordinator
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"sql-horizontal-autoscaler/metrics"
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// RouterStats are the query statistics a router reports with each heartbeat
+type RouterStats struct {
+	Queries       int64   `json:"queries"`
+	Errors        int64   `json:"errors"`
+	QueriesPerSec float64 `json:"queries_per_second"`
+	// ShardErrors counts the statements that failed on each shard by cause
+	// since the router started
+	ShardErrors map[string]metrics.QueryErrors `json:"shard_errors,omitempty"`
+}
+
+// RouterHeartbeat is the body of POST /routers/register and /routers/heartbeat
+type RouterHeartbeat struct {
+	ID              string      `json:"id"`
+	Address         string      `json:"address"`
+	TopologyVersion string      `json:"topology_version"`
+	Stats           RouterStats `json:"stats"`
+}
+
+// RouterInfo is the coordinator's view of a registered router
+type RouterInfo struct {
+	ID              string      `json:"id"`
+	Address         string      `json:"address"`
+	RegisteredAt    time.Time   `json:"registered_at"`
+	LastHeartbeat   time.Time   `json:"last_heartbeat"`
+	TopologyVersion string      `json:"topology_version"`
+	Stats           RouterStats `json:"stats"`
+	Alive           bool        `json:"alive"`
+	StaleTopology   bool        `json:"stale_topology"`
+}
+
+// handleRouterRoutes dispatches /routers/register and /routers/heartbeat
+func (c *Coordinator) handleRouterRoutes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var heartbeat RouterHeartbeat
+	if err := json.NewDecoder(r.Body).Decode(&heartbeat); err != nil || heartbeat.ID == "" {
+		http.Error(w, "Request body must include the router id", http.StatusBadRequest)
+		return
+	}
+
+	switch strings.TrimPrefix(r.URL.Path, "/routers/") {
+	case "register":
+		c.registerRouter(heartbeat)
+	case "heartbeat":
+		if !c.recordHeartbeat(heartbeat) {
+			http.Error(w, "Router not registered", http.StatusNotFound)
+			return
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	// Every response carries the current topology
+	writeJSON(w, http.StatusOK, c.shardManager.Topology())
+}
+
+// handleTopology handles GET /topology, returning the current routing view
+// for clients that poll for topology changes
+func (c *Coordinator) handleTopology(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, c.shardManager.Topology())
+}
+
+// handleRingLayout handles GET /topology/ring, exporting the token ownership
+// of the ring for ring.layout_path
+func (c *Coordinator) handleRingLayout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, c.shardManager.RingLayout())
+}
+
+// handleRouters handles GET /routers, listing the router fleet
+func (c *Coordinator) handleRouters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"topology_version": c.shardManager.Topology().Version,
+		"routers":          c.listRouters(),
+	})
+}
+
+// registerRouter adds or replaces a router in the fleet
+func (c *Coordinator) registerRouter(heartbeat RouterHeartbeat) {
+	c.routerMutex.Lock()
+	defer c.routerMutex.Unlock()
+
+	now := time.Now()
+	c.routers[heartbeat.ID] = &RouterInfo{
+		ID:              heartbeat.ID,
+		Address:         heartbeat.Address,
+		RegisteredAt:    now,
+		LastHeartbeat:   now,
+		TopologyVersion: heartbeat.TopologyVersion,
+		Stats:           heartbeat.Stats,
+	}
+	log.Printf("🛰️  Router %s registered (%s)", heartbeat.ID, heartbeat.Address)
+}
+
+// recordHeartbeat updates a registered router, reporting whether it was known
+func (c *Coordinator) recordHeartbeat(heartbeat RouterHeartbeat) bool {
+	c.routerMutex.Lock()
+	defer c.routerMutex.Unlock()
+
+	router, exists := c.routers[heartbeat.ID]
+	if !exists {
+		return false
+	}
+	router.LastHeartbeat = time.Now()
+	router.TopologyVersion = heartbeat.TopologyVersion
+	router.Stats = heartbeat.Stats
+	if heartbeat.Address != "" {
+		router.Address = heartbeat.Address
+	}
+	return true
+}
+
+// listRouters returns the registered routers with liveness and staleness flags
+func (c *Coordinator) listRouters() []RouterInfo {
+	version := c.shardManager.Topology().Version
+	timeout := time.Duration(c.config.Routers.HeartbeatTimeoutSeconds) * time.Second
+
+	c.routerMutex.RLock()
+	routers := make([]RouterInfo, 0, len(c.routers))
+	for _, router := range c.routers {
+		info := *router
+		info.Alive = time.Since(info.LastHeartbeat) <= timeout
+		info.StaleTopology = info.TopologyVersion != version
+		routers = append(routers, info)
+	}
+	c.routerMutex.RUnlock()
+
+	sort.Slice(routers, func(i, j int) bool { return routers[i].ID < routers[j].ID })
+	return routers
+}
+
+// topologyPushLoop pushes the topology to every live router when it
+// changes. Pushes need the routers' push secret to be signed.
+func (c *Coordinator) topologyPushLoop() {
+	if c.config.TopologyPushSecret() == "" {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(c.config.Routers.PushIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	lastVersion := c.shardManager.Topology().Version
+	for {
+		select {
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			topology := c.shardManager.Topology()
+			if topology.Version == lastVersion {
+				continue
+			}
+			lastVersion = topology.Version
+			c.pushTopology(topology)
+		}
+	}
+}
+
+// pushTopology sends the topology to the /topology endpoint of each live router
+func (c *Coordinator) pushTopology(topology sharding.Topology) {
+	body, err := json.Marshal(topology)
+	if err != nil {
+		log.Printf("Warning: Failed to encode topology: %v", err)
+		return
+	}
+
+	signature := sharding.SignTopology(c.config.TopologyPushSecret(), body)
+	client := &http.Client{Timeout: 5 * time.Second}
+	for _, router := range c.listRouters() {
+		if !router.Alive || router.Address == "" {
+			continue
+		}
+
+		req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(router.Address, "/")+"/topology", bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Warning: Failed to push topology to router %s: %v", router.ID, err)
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(sharding.TopologySignatureHeader, signature)
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Printf("Warning: Failed to push topology to router %s: %v", router.ID, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("Warning: Router %s rejected topology push: %s", router.ID, resp.Status)
+		}
+	}
+	log.Printf("📡 Pushed topology %s to routers", topology.Version)
+}
@@ -0,0 +1,60 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// ScaleOutRequest is the optional body of POST /scale/out
+type ScaleOutRequest struct {
+	Zone string `json:"zone,omitempty"`
+}
+
+// handleScaleOut handles POST /scale/out, adding a shard on an operator's
+// request. The shard is provisioned in the background once a provisioning
+// slot is free, and its outcome is recorded in GET /events.
+func (c *Coordinator) handleScaleOut(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ScaleOutRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if c.shardManager.GetShardCount()+c.pendingShards() >= c.config.Limits.MaxShards {
+		http.Error(w, "Maximum shard count reached", http.StatusConflict)
+		return
+	}
+	costDelta, err := c.checkBudget()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	log.Printf("🚀 Manual scale-out requested (zone %q)", req.Zone)
+	queued := c.queueScaleOut("manual", "scale_out", req.Zone, 1, false)
+	go func() {
+		defer c.finishScaleOut(queued)
+		if !c.startScaleOut(queued) {
+			return
+		}
+		if c.preScale(ScalingEvent{Target: "manual", Reason: "scale_out", CostDelta: costDelta}) != nil {
+			return
+		}
+		shardID, err := c.scaleOutShard(req.Zone)
+		if err != nil {
+			log.Printf("❌ Failed to scale out: %v", err)
+			c.recordEvent(ScalingEvent{Target: "manual", Reason: "scale_out", ShardID: shardID, Status: "failed", Error: err.Error(), Logs: provisioningLogs(err)})
+			return
+		}
+		c.recordEvent(ScalingEvent{Target: "manual", Reason: "scale_out", ShardID: shardID, Status: "completed", CostDelta: costDelta})
+	}()
+	writeJSON(w, http.StatusAccepted, map[string]string{"zone": req.Zone, "status": "started"})
+}
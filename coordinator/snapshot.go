@@ -0,0 +1,198 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sql-horizontal-autoscaler/metrics"
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// Snapshot is the persisted coordinator state used to recover after a restart
+type Snapshot struct {
+	TakenAt        time.Time                        `json:"taken_at"`
+	Metrics        map[string]*metrics.ShardMetrics `json:"metrics"`
+	ScalingHistory []ScalingEvent                   `json:"scaling_history"`
+	Shards         map[string]*sharding.ShardInfo   `json:"shards"`
+	Baselines      map[string]*Baseline             `json:"baselines,omitempty"`
+	Directory      []sharding.DirectoryEntry        `json:"directory,omitempty"`
+	Divergences    []sharding.Divergence            `json:"divergences,omitempty"`
+	TimeRanges     []sharding.TimeRange             `json:"time_ranges,omitempty"`
+	ReadOnly       []sharding.ReadOnlyShard         `json:"read_only,omitempty"`
+	Mirrors        []sharding.MirrorInfo            `json:"mirrors,omitempty"`
+}
+
+// snapshotLoop periodically writes the coordinator state to disk
+func (c *Coordinator) snapshotLoop() {
+	ticker := time.NewTicker(time.Duration(c.config.State.SnapshotIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			if err := c.saveSnapshot(); err != nil {
+				log.Printf("Warning: Failed to save coordinator snapshot: %v", err)
+			}
+		}
+	}
+}
+
+// saveSnapshot atomically writes the current coordinator state to the snapshot file
+func (c *Coordinator) saveSnapshot() error {
+	snapshot := Snapshot{
+		TakenAt:        time.Now(),
+		Metrics:        make(map[string]*metrics.ShardMetrics),
+		ScalingHistory: c.getScalingHistory(),
+		Shards:         c.shardManager.GetAllShardInfo(),
+		Baselines:      c.copyBaselines(),
+		Directory:      c.shardManager.GetDirectory(),
+		Divergences:    c.shardManager.GetDivergences(),
+		TimeRanges:     c.shardManager.GetTimeRanges(),
+		ReadOnly:       c.shardManager.GetReadOnlyShards(),
+		Mirrors:        c.shardManager.Mirrors(""),
+	}
+
+	c.mutex.RLock()
+	for shardID, shardMetrics := range c.metrics {
+		snapshot.Metrics[shardID] = shardMetrics
+	}
+	c.mutex.RUnlock()
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	path := c.config.State.SnapshotPath
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return os.Rename(tmpFile.Name(), path)
+}
+
+// loadSnapshot reads the snapshot file, returning nil if none exists
+func (c *Coordinator) loadSnapshot() (*Snapshot, error) {
+	data, err := os.ReadFile(c.config.State.SnapshotPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
+
+// restoreFromSnapshot restores metrics and history and resumes any shards the
+// previous coordinator had created or was still provisioning
+func (c *Coordinator) restoreFromSnapshot() {
+	snapshot, err := c.loadSnapshot()
+	if err != nil {
+		log.Printf("Warning: Ignoring coordinator snapshot: %v", err)
+		return
+	}
+	if snapshot == nil {
+		return
+	}
+
+	log.Printf("📂 Restoring coordinator state from snapshot taken at %s", snapshot.TakenAt.Format(time.RFC3339))
+
+	c.mutex.Lock()
+	for shardID, shardMetrics := range snapshot.Metrics {
+		c.setShardMetrics(shardID, shardMetrics)
+	}
+	c.mutex.Unlock()
+
+	c.historyMutex.Lock()
+	c.scalingHistory = append(snapshot.ScalingHistory, c.scalingHistory...)
+	c.historyMutex.Unlock()
+
+	c.baselineMutex.Lock()
+	for key, baseline := range snapshot.Baselines {
+		c.baselines[key] = baseline
+	}
+	c.baselineMutex.Unlock()
+
+	if len(snapshot.Directory) > 0 {
+		c.shardManager.RestoreDirectory(snapshot.Directory)
+		log.Printf("📂 Restored %d routing directory entries", len(snapshot.Directory))
+	}
+
+	if len(snapshot.Divergences) > 0 {
+		c.shardManager.RestoreDivergences(snapshot.Divergences)
+		log.Printf("📂 Restored %d diverged broadcast table copies", len(snapshot.Divergences))
+	}
+
+	if len(snapshot.TimeRanges) > 0 {
+		c.shardManager.RestoreTimeRanges(snapshot.TimeRanges)
+		log.Printf("📂 Restored %d time ranges", len(snapshot.TimeRanges))
+	}
+
+	if len(snapshot.ReadOnly) > 0 {
+		c.shardManager.RestoreReadOnly(snapshot.ReadOnly)
+		log.Printf("📂 Restored %d read-only shards", len(snapshot.ReadOnly))
+	}
+
+	if len(snapshot.Mirrors) > 0 {
+		c.restoreMirrors(snapshot.Mirrors)
+	}
+
+	for shardID, shardInfo := range snapshot.Shards {
+		if shardInfo.Status == "merged" {
+			c.restoreMerge(*shardInfo)
+			continue
+		}
+		if _, known := c.shardManager.GetShardInfo(shardID); known {
+			continue
+		}
+		if shardInfo.Status != "active" && shardInfo.Status != "provisioning" {
+			continue
+		}
+
+		go c.resumeShard(*shardInfo)
+	}
+}
+
+// resumeShard re-adopts a shard from the snapshot and reconnects to it
+func (c *Coordinator) resumeShard(shardInfo sharding.ShardInfo) {
+	log.Printf("♻️  Resuming shard %s (status at snapshot: %s)", shardInfo.ID, shardInfo.Status)
+
+	resumed, err := c.shardManager.ResumeShard(shardInfo)
+	if err != nil {
+		log.Printf("❌ Failed to resume shard %s: %v", shardInfo.ID, err)
+		c.recordEvent(ScalingEvent{Target: shardInfo.ID, Reason: "resume", ShardID: shardInfo.ID, Status: "failed", Error: err.Error()})
+		return
+	}
+
+	if err := c.dataStore.AddShardConnection(resumed.ID, resumed.DSN, c.tableNames()); err != nil {
+		log.Printf("❌ Failed to add connection for resumed shard %s: %v", resumed.ID, err)
+		c.recordEvent(ScalingEvent{Target: resumed.ID, Reason: "resume", ShardID: resumed.ID, Status: "failed", Error: err.Error()})
+		return
+	}
+
+	c.config.SetShard(resumed.ID, resumed.DSN)
+	c.recordEvent(ScalingEvent{Target: resumed.ID, Reason: "resume", ShardID: resumed.ID, Status: "completed"})
+	log.Printf("✅ Shard %s resumed and active", resumed.ID)
+}
@@ -0,0 +1,149 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// SplitRequest is the body of POST /shards/{id}/split; both fields are optional
+type SplitRequest struct {
+	Zone              string `json:"zone,omitempty"`
+	MaxBytesPerSecond *int64 `json:"max_bytes_per_second,omitempty"`
+}
+
+// ThrottleRequest is the body of POST /splits/{id}/throttle
+type ThrottleRequest struct {
+	MaxBytesPerSecond int64 `json:"max_bytes_per_second"`
+}
+
+// handleSplit handles POST /shards/{id}/split, starting a clone split of the
+// shard. Progress is reported by GET /splits.
+func (c *Coordinator) handleSplit(w http.ResponseWriter, r *http.Request, shardID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SplitRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if shardInfo, exists := c.shardManager.GetShardInfo(shardID); !exists || shardInfo.Status != "active" {
+		http.Error(w, "Shard "+shardID+" is not active", http.StatusConflict)
+		return
+	}
+	if c.shardManager.GetShardCount() >= c.config.Limits.MaxShards {
+		http.Error(w, "Maximum shard count reached", http.StatusConflict)
+		return
+	}
+	if _, err := c.checkBudget(); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	opts := sharding.SplitOptions{
+		Zone:              req.Zone,
+		ShardKeys:         c.splitShardKeys(),
+		MaxBytesPerSecond: c.config.Split.MaxBytesPerSecond,
+		DeleteBatchSize:   c.config.Split.DeleteBatchSize,
+		DeletePause:       time.Duration(c.config.Split.DeletePauseMs) * time.Millisecond,
+	}
+	if req.MaxBytesPerSecond != nil {
+		opts.MaxBytesPerSecond = *req.MaxBytesPerSecond
+	}
+
+	go c.splitShard(shardID, opts)
+	writeJSON(w, http.StatusAccepted, map[string]string{"source": shardID, "status": "started"})
+}
+
+// splitShardKeys returns the key columns of the sharded tables in the shards'
+// default database, which are the tables a clone split divides
+func (c *Coordinator) splitShardKeys() map[string]string {
+	keys := make(map[string]string)
+	for table, keyColumn := range c.config.TableShardKeys {
+		if _, elsewhere := c.config.TableDatabases[table]; elsewhere || strings.Contains(table, ".") {
+			continue
+		}
+		keys[table] = keyColumn
+	}
+	return keys
+}
+
+// splitShard runs a clone split and integrates the new shard once it is in
+// the ring, recording the outcome as a scaling event
+func (c *Coordinator) splitShard(sourceID string, opts sharding.SplitOptions) {
+	if c.preScale(ScalingEvent{Target: sourceID, Reason: "split"}) != nil {
+		return
+	}
+	progress, err := c.shardManager.SplitShard(sourceID, opts)
+
+	var targetID string
+	if progress != nil {
+		targetID = progress.Target
+	}
+	if target, exists := c.shardManager.GetShardInfo(targetID); exists && target.Status == "active" {
+		if connErr := c.dataStore.AddShardConnection(target.ID, target.DSN, c.tableNames()); connErr != nil {
+			log.Printf("❌ Failed to add connection for split shard %s: %v", target.ID, connErr)
+		}
+		c.config.SetShard(target.ID, target.DSN)
+		c.markStatsStale(target.ID)
+		c.markStatsStale(sourceID)
+	}
+
+	if err != nil {
+		log.Printf("❌ Failed to split shard %s: %v", sourceID, err)
+		c.recordEvent(ScalingEvent{Target: sourceID, Reason: "split", ShardID: targetID, Status: "failed", Error: err.Error(),
+			Logs: provisioningLogs(err)})
+		return
+	}
+	c.recordEvent(ScalingEvent{Target: sourceID, Reason: "split", ShardID: targetID, Status: "completed", CostDelta: c.shardHourlyCost(targetID) * hoursPerMonth})
+	log.Printf("📊 Current cluster: %d shards active", c.shardManager.GetShardCount())
+}
+
+// handleSplits handles GET /splits, reporting the progress of every split
+func (c *Coordinator) handleSplits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	splits := c.shardManager.Splits()
+	sort.Slice(splits, func(i, j int) bool { return splits[i].StartedAt.After(splits[j].StartedAt) })
+	writeJSON(w, http.StatusOK, splits)
+}
+
+// handleSplitRoutes handles POST /splits/{id}/throttle, changing the clone
+// rate of a running split
+func (c *Coordinator) handleSplitRoutes(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/splits/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "throttle" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ThrottleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MaxBytesPerSecond < 0 {
+		http.Error(w, "Request body must set a non-negative \"max_bytes_per_second\"", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.shardManager.SetSplitThrottle(parts[0], req.MaxBytesPerSecond); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, c.shardManager.SplitStatus(parts[0]))
+}
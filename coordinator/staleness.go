@@ -0,0 +1,93 @@
+package coordinator
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"sql-horizontal-autoscaler/metrics"
+	"sql-horizontal-autoscaler/notifier"
+)
+
+// metricsStatusDegraded marks a shard whose last sample is too old to act on
+const metricsStatusDegraded = "degraded"
+
+// staleAfter is the age past which a shard's last sample is stale
+func (c *Coordinator) staleAfter() time.Duration {
+	return time.Duration(c.config.Monitoring.StaleAfterSeconds) * time.Second
+}
+
+// freshMetrics returns the samples recent enough for scaling decisions, so a
+// shard whose collection keeps failing isn't judged on its last sample
+// forever. Callers must hold c.mutex.
+func (c *Coordinator) freshMetrics() map[string]*metrics.ShardMetrics {
+	fresh := make(map[string]*metrics.ShardMetrics, len(c.metrics))
+	for shardID, shardMetrics := range c.metrics {
+		if time.Since(shardMetrics.LastUpdated) <= c.staleAfter() {
+			fresh[shardID] = shardMetrics
+		}
+	}
+	return fresh
+}
+
+// updateStaleness marks shards whose samples went stale as degraded and
+// alerts once when a shard's metrics go stale and again when they recover
+func (c *Coordinator) updateStaleness() {
+	var alerts []notifier.Alert
+
+	c.mutex.Lock()
+	for shardID := range c.staleShards {
+		if _, exists := c.metrics[shardID]; !exists {
+			delete(c.staleShards, shardID)
+		}
+	}
+
+	shardIDs := make([]string, 0, len(c.metrics))
+	for shardID := range c.metrics {
+		shardIDs = append(shardIDs, shardID)
+	}
+	sort.Strings(shardIDs)
+
+	for _, shardID := range shardIDs {
+		shardMetrics := c.metrics[shardID]
+		age := time.Since(shardMetrics.LastUpdated)
+		stale := age > c.staleAfter()
+
+		switch {
+		case stale && !c.staleShards[shardID]:
+			c.staleShards[shardID] = true
+			degraded := *shardMetrics
+			degraded.Status = metricsStatusDegraded
+			c.setShardMetrics(shardID, &degraded)
+
+			log.Printf("⚠️  Metrics for shard %s are %s old, excluding it from scaling decisions", shardID, age.Round(time.Second))
+			alerts = append(alerts, notifier.Alert{
+				Severity: notifier.SeverityWarning,
+				Title:    "Shard metrics stale",
+				Message: fmt.Sprintf("No metrics collected from shard %s for %s; it is excluded from scaling decisions",
+					shardID, age.Round(time.Second)),
+				Details: map[string]interface{}{
+					"shard_id":     shardID,
+					"last_updated": shardMetrics.LastUpdated,
+				},
+			})
+
+		case !stale && c.staleShards[shardID]:
+			delete(c.staleShards, shardID)
+
+			log.Printf("✅ Metrics for shard %s are being collected again", shardID)
+			alerts = append(alerts, notifier.Alert{
+				Severity: notifier.SeverityInfo,
+				Title:    "Shard metrics recovered",
+				Message:  fmt.Sprintf("Metrics are being collected from shard %s again", shardID),
+				Details:  map[string]interface{}{"shard_id": shardID},
+			})
+		}
+	}
+	c.mutex.Unlock()
+
+	for _, alert := range alerts {
+		c.notify(alert)
+	}
+}
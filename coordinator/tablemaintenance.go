@@ -0,0 +1,198 @@
+package coordinator
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TableMaintenanceRun records the last table maintenance pass on one shard
+type TableMaintenanceRun struct {
+	ShardID string    `json:"shard_id"`
+	LastRun time.Time `json:"last_run"`
+	// Stale is set when data was migrated onto the shard since the last run
+	Stale       bool      `json:"stale"`
+	Tables      int       `json:"tables"`
+	Error       string    `json:"error,omitempty"`
+	SkippedAt   time.Time `json:"skipped_at"`
+	SkipReason  string    `json:"skip_reason,omitempty"`
+	DurationSec float64   `json:"duration_seconds"`
+}
+
+// handleTableMaintenance handles GET /table-maintenance, reporting the last
+// ANALYZE/OPTIMIZE pass per shard
+func (c *Coordinator) handleTableMaintenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c.tableMaintenanceMutex.RLock()
+	runs := make([]TableMaintenanceRun, 0, len(c.tableMaintenance))
+	for _, run := range c.tableMaintenance {
+		runs = append(runs, *run)
+	}
+	c.tableMaintenanceMutex.RUnlock()
+
+	sort.Slice(runs, func(i, j int) bool { return runs[i].ShardID < runs[j].ShardID })
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"in_window": c.config.TableMaintenance.InWindow(time.Now()),
+		"runs":      runs,
+	})
+}
+
+// tableMaintenanceLoop analyzes (and optionally optimizes) the tables of each
+// shard once per interval, only inside the configured windows
+func (c *Coordinator) tableMaintenanceLoop() {
+	ticker := time.NewTicker(time.Duration(c.config.TableMaintenance.CheckIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			for _, shardID := range c.dataStore.ShardIDs() {
+				// Windows can close while earlier shards are processed
+				if !c.config.TableMaintenance.InWindow(time.Now()) {
+					break
+				}
+				if !c.tableMaintenanceDue(shardID) {
+					continue
+				}
+				if reason := c.tableMaintenanceBlocker(shardID); reason != "" {
+					c.skipTableMaintenance(shardID, reason)
+					continue
+				}
+				c.maintainShardTables(shardID)
+			}
+		}
+	}
+}
+
+// tableMaintenanceDue reports whether a shard is stale or wasn't maintained
+// within the interval
+func (c *Coordinator) tableMaintenanceDue(shardID string) bool {
+	c.tableMaintenanceMutex.RLock()
+	defer c.tableMaintenanceMutex.RUnlock()
+
+	run, exists := c.tableMaintenance[shardID]
+	if !exists || run.Stale {
+		return true
+	}
+	interval := time.Duration(c.config.TableMaintenance.IntervalHours) * time.Hour
+	return time.Since(run.LastRun) >= interval
+}
+
+// tableMaintenanceBlocker returns why a shard can't be maintained right now,
+// or "" if it can
+func (c *Coordinator) tableMaintenanceBlocker(shardID string) string {
+	if _, active := c.shardManager.ActiveMaintenance(shardID); active {
+		return "shard is under maintenance"
+	}
+	if c.dataStore.IsDrained(shardID) {
+		return "shard is drained"
+	}
+
+	c.mutex.RLock()
+	shardMetrics, exists := c.metrics[shardID]
+	c.mutex.RUnlock()
+	if !exists {
+		return "no metrics collected yet"
+	}
+	if shardMetrics.Status == metricsStatusDegraded {
+		return "metrics are stale"
+	}
+	if shardMetrics.CPUPercent > c.config.TableMaintenance.MaxCPUPercent {
+		return fmt.Sprintf("CPU at %.1f%% (limit %.1f%%)", shardMetrics.CPUPercent, c.config.TableMaintenance.MaxCPUPercent)
+	}
+	return ""
+}
+
+// maintenanceTables returns the tables to maintain, database-qualified where
+// configured
+func (c *Coordinator) maintenanceTables() []string {
+	if len(c.config.TableMaintenance.Tables) > 0 {
+		return c.config.TableMaintenance.Tables
+	}
+	return append(c.tableNames(), c.config.Broadcast.Tables...)
+}
+
+// maintainShardTables runs ANALYZE TABLE, and OPTIMIZE TABLE when enabled, on
+// every table of a shard
+func (c *Coordinator) maintainShardTables(shardID string) {
+	start := time.Now()
+	tables := c.maintenanceTables()
+	var failures []string
+	for _, table := range tables {
+		statements := []string{"ANALYZE TABLE " + quoteTableName(table)}
+		if c.config.TableMaintenance.Optimize {
+			statements = append(statements, "OPTIMIZE TABLE "+quoteTableName(table))
+		}
+		for _, statement := range statements {
+			if err := c.dataStore.MaintainTable(statement, shardID, ""); err != nil {
+				failures = append(failures, err.Error())
+			}
+		}
+	}
+
+	c.tableMaintenanceMutex.Lock()
+	run := c.tableMaintenanceRun(shardID)
+	run.LastRun = time.Now()
+	run.Stale = false
+	run.Tables = len(tables)
+	run.DurationSec = time.Since(start).Seconds()
+	run.Error = strings.Join(failures, "; ")
+	run.SkipReason = ""
+	c.tableMaintenanceMutex.Unlock()
+
+	if len(failures) > 0 {
+		log.Printf("Warning: Table maintenance on shard %s had %d failures: %s", shardID, len(failures), run.Error)
+		return
+	}
+	log.Printf("🧽 Analyzed %d tables on shard %s in %.1fs", len(tables), shardID, time.Since(start).Seconds())
+}
+
+// skipTableMaintenance records why a due shard was skipped
+func (c *Coordinator) skipTableMaintenance(shardID, reason string) {
+	c.tableMaintenanceMutex.Lock()
+	defer c.tableMaintenanceMutex.Unlock()
+
+	run := c.tableMaintenanceRun(shardID)
+	if run.SkipReason != reason {
+		log.Printf("Skipping table maintenance on shard %s: %s", shardID, reason)
+	}
+	run.SkippedAt = time.Now()
+	run.SkipReason = reason
+}
+
+// markStatsStale makes a shard due for table maintenance in the next window
+// after data was migrated onto it
+func (c *Coordinator) markStatsStale(shardID string) {
+	c.tableMaintenanceMutex.Lock()
+	defer c.tableMaintenanceMutex.Unlock()
+	c.tableMaintenanceRun(shardID).Stale = true
+}
+
+// tableMaintenanceRun returns the run record of a shard, creating it if
+// needed. Must be called with the mutex held.
+func (c *Coordinator) tableMaintenanceRun(shardID string) *TableMaintenanceRun {
+	run, exists := c.tableMaintenance[shardID]
+	if !exists {
+		run = &TableMaintenanceRun{ShardID: shardID}
+		c.tableMaintenance[shardID] = run
+	}
+	return run
+}
+
+// quoteTableName quotes a table name that may be database-qualified
+func quoteTableName(table string) string {
+	parts := strings.SplitN(table, ".", 2)
+	for i, part := range parts {
+		parts[i] = "`" + part + "`"
+	}
+	return strings.Join(parts, ".")
+}
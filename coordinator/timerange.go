@@ -0,0 +1,140 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"sql-horizontal-autoscaler/config"
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// ArchiveTimeRangeRequest is the body of POST /time-ranges/archive
+type ArchiveTimeRangeRequest struct {
+	Table   string `json:"table"`
+	ShardID string `json:"shard_id"`
+}
+
+// handleTimeRanges handles GET /time-ranges, listing the ranges of every
+// time range table
+func (c *Coordinator) handleTimeRanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"time_ranges": c.shardManager.GetTimeRanges(),
+	})
+}
+
+// handleArchiveTimeRange handles POST /time-ranges/archive, detaching a shard
+// from a time range table ahead of the automatic archival
+func (c *Coordinator) handleArchiveTimeRange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ArchiveTimeRangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Table == "" || req.ShardID == "" {
+		http.Error(w, "Request body must name the \"table\" and \"shard_id\"", http.StatusBadRequest)
+		return
+	}
+
+	archived, err := c.shardManager.ArchiveTimeRangesOnShard(req.Table, req.ShardID)
+	if err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+
+	c.persistTimeRanges()
+	log.Printf("🗄️  Archived %d time ranges of %s on shard %s", len(archived), req.Table, req.ShardID)
+	c.recordEvent(ScalingEvent{Target: req.Table, Reason: "time_range_archive", ShardID: req.ShardID, Status: "completed"})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"archived": archived})
+}
+
+// timeRangeLoop periodically rolls time range tables over to new shards and
+// archives old ranges
+func (c *Coordinator) timeRangeLoop() {
+	ticker := time.NewTicker(time.Duration(c.config.TimeRange.CheckIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			for table, policy := range c.config.TimeRange.Tables {
+				c.checkTimeRange(table, policy, time.Now())
+			}
+		}
+	}
+}
+
+// checkTimeRange creates a new shard for a table once its current range is
+// full, and archives ranges past the retention
+func (c *Coordinator) checkTimeRange(table string, policy config.TimeRangeTableConfig, now time.Time) {
+	if policy.ArchiveAfterPeriods > 0 {
+		cutoff := sharding.AddPeriods(sharding.PeriodStart(now, policy.Period), policy.Period, -policy.ArchiveAfterPeriods)
+		if archived := c.shardManager.ArchiveTimeRanges(table, cutoff); len(archived) > 0 {
+			c.persistTimeRanges()
+			for _, tr := range archived {
+				log.Printf("🗄️  Archived time range of %s starting %s on shard %s",
+					table, tr.Start.Format(time.RFC3339), tr.ShardID)
+				c.recordEvent(ScalingEvent{Target: table, Reason: "time_range_archive", ShardID: tr.ShardID, Status: "completed"})
+			}
+		}
+	}
+
+	current, exists := c.shardManager.CurrentTimeRange(table)
+	if !exists || current.Start.After(now) {
+		// A rollover is already scheduled for the next period
+		return
+	}
+
+	rows, err := c.shardManager.CountTimeRangeRows(current)
+	if err != nil {
+		log.Printf("Warning: Failed to check the current time range of %s: %v", table, err)
+		return
+	}
+	if rows < policy.MaxRowsPerShard {
+		return
+	}
+
+	log.Printf("📅 Current time range of %s on shard %s holds %d rows (limit %d), rolling over",
+		table, current.ShardID, rows, policy.MaxRowsPerShard)
+
+	if c.preScale(ScalingEvent{Target: table, Reason: "time_range_rollover", Value: float64(rows)}) != nil {
+		return
+	}
+	queued := c.queueScaleOut(table, "time_range_rollover", "", 1, false)
+	if !c.startScaleOut(queued) {
+		c.finishScaleOut(queued)
+		return
+	}
+	shardID, err := c.scaleOutShard("")
+	c.finishScaleOut(queued)
+	if err == nil {
+		var next sharding.TimeRange
+		if next, err = c.shardManager.RollOverTimeRange(table, shardID, now); err == nil {
+			c.persistTimeRanges()
+			log.Printf("📅 %s rows from %s onwards go to shard %s", table, next.Start.Format(time.RFC3339), shardID)
+			c.recordEvent(ScalingEvent{Target: table, Reason: "time_range_rollover", Value: float64(rows), ShardID: shardID, Status: "completed"})
+			return
+		}
+	}
+
+	log.Printf("❌ Failed to roll %s over to a new shard: %v", table, err)
+	c.recordEvent(ScalingEvent{Target: table, Reason: "time_range_rollover", Value: float64(rows), ShardID: shardID, Status: "failed", Error: err.Error(),
+		Logs: provisioningLogs(err)})
+}
+
+// persistTimeRanges saves a snapshot right away so range changes survive a
+// restart without waiting for the next snapshot interval
+func (c *Coordinator) persistTimeRanges() {
+	if err := c.saveSnapshot(); err != nil {
+		log.Printf("Warning: Failed to persist time ranges: %v", err)
+	}
+}
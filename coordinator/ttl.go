@@ -0,0 +1,123 @@
+package coordinator
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"sql-horizontal-autoscaler/config"
+)
+
+// TTLRun records the janitor's last pass over one table on one shard
+type TTLRun struct {
+	Table        string    `json:"table"`
+	ShardID      string    `json:"shard_id"`
+	LastRun      time.Time `json:"last_run"`
+	Deleted      int64     `json:"deleted"`
+	TotalDeleted int64     `json:"total_deleted"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// handleTTL handles GET /ttl, reporting the janitor's last pass per table and shard
+func (c *Coordinator) handleTTL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c.ttlMutex.RLock()
+	runs := make([]TTLRun, 0, len(c.ttlRuns))
+	for _, run := range c.ttlRuns {
+		runs = append(runs, *run)
+	}
+	c.ttlMutex.RUnlock()
+
+	sort.Slice(runs, func(i, j int) bool {
+		if runs[i].Table != runs[j].Table {
+			return runs[i].Table < runs[j].Table
+		}
+		return runs[i].ShardID < runs[j].ShardID
+	})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"runs": runs})
+}
+
+// ttlJanitorLoop periodically deletes expired rows on every shard, so entry
+// counts reflect live data rather than rows nobody prunes
+func (c *Coordinator) ttlJanitorLoop() {
+	ticker := time.NewTicker(time.Duration(c.config.TTL.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			for _, shardID := range c.dataStore.ShardIDs() {
+				// Shards under maintenance or being drained take no writes
+				if _, active := c.shardManager.ActiveMaintenance(shardID); active || c.dataStore.IsDrained(shardID) {
+					continue
+				}
+				for table, ttl := range c.config.TTL.Tables {
+					deleted, err := c.expireRows(table, ttl, shardID)
+					c.recordTTLRun(table, shardID, deleted, err)
+					if err != nil {
+						log.Printf("Warning: Failed to expire %s rows on shard %s: %v", table, shardID, err)
+					} else if deleted > 0 {
+						log.Printf("🧹 Expired %d %s rows older than %ds on shard %s", deleted, table, ttl.TTLSeconds, shardID)
+					}
+				}
+			}
+		}
+	}
+}
+
+// expireRows deletes a table's expired rows on a shard in batches, pausing
+// between batches to stay under the configured delete rate. Expiry is judged
+// by the shard's clock so it agrees with CURRENT_TIMESTAMP defaults.
+func (c *Coordinator) expireRows(table string, ttl config.TTLTableConfig, shardID string) (int64, error) {
+	batchSize := c.config.TTL.BatchSize
+	query := fmt.Sprintf("DELETE FROM `%s` WHERE `%s` < NOW() - INTERVAL %d SECOND LIMIT %d",
+		table, ttl.Column, ttl.TTLSeconds, batchSize)
+	database := c.config.TableDatabases[table]
+
+	var total int64
+	for {
+		affected, err := c.dataStore.ExecuteInternalWrite(query, shardID, database)
+		total += affected
+		if err != nil {
+			return total, err
+		}
+		if affected < int64(batchSize) {
+			return total, nil
+		}
+
+		pause := time.Duration(float64(affected) / float64(c.config.TTL.MaxRowsPerSecond) * float64(time.Second))
+		select {
+		case <-c.stopChan:
+			return total, nil
+		case <-time.After(pause):
+		}
+	}
+}
+
+// recordTTLRun stores the outcome of a janitor pass
+func (c *Coordinator) recordTTLRun(table, shardID string, deleted int64, err error) {
+	c.ttlMutex.Lock()
+	defer c.ttlMutex.Unlock()
+
+	key := table + "@" + shardID
+	run, exists := c.ttlRuns[key]
+	if !exists {
+		run = &TTLRun{Table: table, ShardID: shardID}
+		c.ttlRuns[key] = run
+	}
+	run.LastRun = time.Now()
+	run.Deleted = deleted
+	run.TotalDeleted += deleted
+	run.Error = ""
+	if err != nil {
+		run.Error = err.Error()
+	}
+}
@@ -0,0 +1,105 @@
+package coordinator
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// zoneTargetPrefix marks a scaling target that names a zone rather than a shard
+const zoneTargetPrefix = "zone:"
+
+// ZoneStats aggregates the latest metrics of the shards in one zone
+type ZoneStats struct {
+	Zone             string   `json:"zone"`
+	Shards           []string `json:"shards"`
+	AvgCPU           float64  `json:"avg_cpu_percent"`
+	AvgMemory        float64  `json:"avg_memory_percent"`
+	TotalEntries     int64    `json:"total_entries"`
+	TotalConnections int64    `json:"total_connections"`
+	Saturated        bool     `json:"saturated"`
+}
+
+// computeZoneStats aggregates the collected metrics per zone. Callers must
+// hold c.mutex.
+func (c *Coordinator) computeZoneStats() map[string]*ZoneStats {
+	stats := make(map[string]*ZoneStats)
+	for shardID, shardMetrics := range c.freshMetrics() {
+		zone := ""
+		if shardInfo, exists := c.shardManager.GetShardInfo(shardID); exists {
+			zone = shardInfo.Zone
+		}
+
+		zoneStats, exists := stats[zone]
+		if !exists {
+			zoneStats = &ZoneStats{Zone: zone}
+			stats[zone] = zoneStats
+		}
+		zoneStats.Shards = append(zoneStats.Shards, shardID)
+		zoneStats.AvgCPU += shardMetrics.CPUPercent
+		zoneStats.AvgMemory += shardMetrics.MemoryPercent
+		zoneStats.TotalEntries += shardMetrics.TotalEntries
+		zoneStats.TotalConnections += shardMetrics.ConnectionCount
+	}
+
+	for _, zoneStats := range stats {
+		sort.Strings(zoneStats.Shards)
+		zoneStats.AvgCPU /= float64(len(zoneStats.Shards))
+		zoneStats.AvgMemory /= float64(len(zoneStats.Shards))
+		zoneStats.Saturated = zoneStats.AvgCPU >= c.config.ScalingThresholds.CPUThresholdPercent ||
+			zoneStats.AvgMemory >= c.config.ScalingThresholds.MemoryThresholdPercent
+	}
+	return stats
+}
+
+// analyzeZones records per-zone aggregates and, unless the whole cluster is
+// already scaling, scales out inside a zone that is saturated on its own.
+// Callers must hold c.mutex.
+func (c *Coordinator) analyzeZones(clusterTriggered bool) {
+	stats := c.computeZoneStats()
+
+	c.zoneMutex.Lock()
+	c.zoneStats = stats
+	c.zoneMutex.Unlock()
+
+	// Targeted scale-out only makes sense with more than one zone to choose from
+	if clusterTriggered || len(c.config.Placement.Zones) < 2 {
+		return
+	}
+
+	for zone, zoneStats := range stats {
+		if zone == "" || !zoneStats.Saturated {
+			continue
+		}
+		log.Printf("COLD SCALING TRIGGERED: Zone %s saturated (avg CPU %.1f%%, avg memory %.1f%% across %d shards)",
+			zone, zoneStats.AvgCPU, zoneStats.AvgMemory, len(zoneStats.Shards))
+		c.triggerScaling(zoneTargetPrefix+zone, "zone_saturation", zoneStats.AvgCPU, 1)
+	}
+}
+
+// scalingZone returns the zone a scaling target names, if any
+func scalingZone(target string) string {
+	if strings.HasPrefix(target, zoneTargetPrefix) {
+		return strings.TrimPrefix(target, zoneTargetPrefix)
+	}
+	return ""
+}
+
+// handleZones handles GET /zones, reporting the latest per-zone aggregates
+func (c *Coordinator) handleZones(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c.zoneMutex.RLock()
+	zones := make([]*ZoneStats, 0, len(c.zoneStats))
+	for _, zoneStats := range c.zoneStats {
+		zones = append(zones, zoneStats)
+	}
+	c.zoneMutex.RUnlock()
+
+	sort.Slice(zones, func(i, j int) bool { return zones[i].Zone < zones[j].Zone })
+	writeJSON(w, http.StatusOK, map[string]interface{}{"zones": zones})
+}
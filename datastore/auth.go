@@ -0,0 +1,206 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Authentication modes for shard connections
+const (
+	AuthModePassword = "password"
+	AuthModeRDSIAM   = "rds_iam"
+)
+
+// AuthConfig controls how the datastore authenticates to shards. Unix socket
+// DSNs (user@unix(/path/mysqld.sock)/db) work with either mode's password
+// handling and need no extra configuration.
+type AuthConfig struct {
+	Mode            string
+	ShardModes      map[string]string
+	Region          string
+	RefreshInterval time.Duration
+}
+
+// modeFor returns the authentication mode for a shard
+func (ac AuthConfig) modeFor(shardID string) string {
+	if mode, exists := ac.ShardModes[shardID]; exists {
+		return mode
+	}
+	if ac.Mode == "" {
+		return AuthModePassword
+	}
+	return ac.Mode
+}
+
+// TokenProvider generates short-lived database passwords
+type TokenProvider interface {
+	Token(host string, port string, user string) (string, error)
+}
+
+// RDSIAMTokenProvider generates RDS IAM authentication tokens using the aws CLI
+type RDSIAMTokenProvider struct {
+	Region string
+}
+
+// Token generates an IAM authentication token for the given endpoint and user
+func (p *RDSIAMTokenProvider) Token(host string, port string, user string) (string, error) {
+	args := []string{"rds", "generate-db-auth-token",
+		"--hostname", host,
+		"--port", port,
+		"--username", user}
+	if p.Region != "" {
+		args = append(args, "--region", p.Region)
+	}
+
+	output, err := exec.Command("aws", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("aws rds generate-db-auth-token failed: %w, output: %s", err, string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// rotatingConnector dials MySQL with the most recently generated token as the
+// password, so pools keep working across token rotations
+type rotatingConnector struct {
+	shardID  string
+	cfg      *mysql.Config
+	provider TokenProvider
+	token    string
+	mutex    sync.RWMutex
+}
+
+// newRotatingConnector prepares a connector for token authentication and
+// fetches the first token
+func newRotatingConnector(shardID string, dsn string, provider TokenProvider) (*rotatingConnector, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DSN for shard %s: %w", shardID, err)
+	}
+	if cfg.Net != "tcp" {
+		return nil, fmt.Errorf("token authentication for shard %s requires a tcp DSN", shardID)
+	}
+
+	// Tokens are sent as cleartext passwords, which is only acceptable over TLS
+	cfg.AllowCleartextPasswords = true
+	if cfg.TLSConfig == "" {
+		cfg.TLSConfig = "true"
+	}
+
+	rc := &rotatingConnector{shardID: shardID, cfg: cfg, provider: provider}
+	if err := rc.refresh(); err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// refresh generates a new token
+func (rc *rotatingConnector) refresh() error {
+	host, port, err := net.SplitHostPort(rc.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %s for shard %s: %w", rc.cfg.Addr, rc.shardID, err)
+	}
+
+	token, err := rc.provider.Token(host, port, rc.cfg.User)
+	if err != nil {
+		return fmt.Errorf("failed to generate token for shard %s: %w", rc.shardID, err)
+	}
+
+	rc.mutex.Lock()
+	rc.token = token
+	rc.mutex.Unlock()
+	return nil
+}
+
+// Connect implements driver.Connector using the current token
+func (rc *rotatingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	cfg := rc.cfg.Clone()
+
+	rc.mutex.RLock()
+	cfg.Passwd = rc.token
+	rc.mutex.RUnlock()
+
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+// Driver implements driver.Connector
+func (rc *rotatingConnector) Driver() driver.Driver {
+	return mysql.MySQLDriver{}
+}
+
+// SetAuthConfig configures shard authentication; call before InitializeConnections
+func (ds *DataStore) SetAuthConfig(authConfig AuthConfig) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	ds.authConfig = authConfig
+}
+
+// openDB opens a connection pool for a shard using its authentication mode
+// and the pool configuration. Callers must hold ds.mutex.
+func (ds *DataStore) openDB(shardID string, dsn string) (*sql.DB, error) {
+	if ds.authConfig.modeFor(shardID) != AuthModeRDSIAM {
+		db, err := sql.Open("mysql", dsn)
+		if err != nil {
+			return nil, err
+		}
+		ds.configurePool(shardID, db)
+		return db, nil
+	}
+
+	connector, err := newRotatingConnector(shardID, dsn, &RDSIAMTokenProvider{Region: ds.authConfig.Region})
+	if err != nil {
+		return nil, err
+	}
+	ds.rotatingConnectors = append(ds.rotatingConnectors, connector)
+
+	if ds.tokenRefreshStop == nil {
+		ds.tokenRefreshStop = make(chan struct{})
+		go ds.tokenRefreshLoop(ds.tokenRefreshStop)
+	}
+
+	db := sql.OpenDB(connector)
+	ds.configurePool(shardID, db)
+	return db, nil
+}
+
+// tokenRefreshLoop regenerates authentication tokens before they expire
+func (ds *DataStore) tokenRefreshLoop(stopChan chan struct{}) {
+	interval := ds.authConfig.RefreshInterval
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-ticker.C:
+			ds.mutex.RLock()
+			connectors := append([]*rotatingConnector(nil), ds.rotatingConnectors...)
+			ds.mutex.RUnlock()
+
+			for _, connector := range connectors {
+				if err := connector.refresh(); err != nil {
+					log.Printf("Warning: %v", err)
+				}
+			}
+		}
+	}
+}
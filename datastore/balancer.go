@@ -0,0 +1,169 @@
+package datastore
+
+import (
+	"sync"
+	"time"
+)
+
+// Read balancer strategies
+const (
+	BalancerLeastLag = "least_lag"
+	BalancerLatency  = "latency"
+)
+
+// ReadTarget is a replica, or a shard holding a mirrored copy, able to serve a read
+type ReadTarget struct {
+	Name string
+	Lag  time.Duration
+}
+
+// Balancer picks which of several eligible targets serves a read, and learns
+// from the outcome of reads
+type Balancer interface {
+	// Pick returns the index of the target to read from
+	Pick(targets []ReadTarget) int
+	// Observe records how a read served by a target went
+	Observe(target string, latency time.Duration, err error)
+}
+
+// NewBalancer returns the balancer for a strategy, least lag by default
+func NewBalancer(strategy string) Balancer {
+	if strategy == BalancerLatency {
+		return NewLatencyBalancer()
+	}
+	return LeastLagBalancer{}
+}
+
+// LeastLagBalancer reads from the least lagged target
+type LeastLagBalancer struct{}
+
+// Pick implements Balancer
+func (LeastLagBalancer) Pick(targets []ReadTarget) int {
+	best := 0
+	for i, target := range targets {
+		if target.Lag < targets[best].Lag {
+			best = i
+		}
+	}
+	return best
+}
+
+// Observe implements Balancer
+func (LeastLagBalancer) Observe(string, time.Duration, error) {}
+
+// Latency balancer tuning
+const (
+	// latencyAlpha weighs the newest sample in the moving averages
+	latencyAlpha = 0.2
+	// errorPenalty scales a target's latency by 1 + errorPenalty * error rate
+	errorPenalty = 10
+	// probeInterval re-tries targets that haven't served a read for this
+	// long, so a target recovering from a slow spell gets picked again
+	probeInterval = 10 * time.Second
+)
+
+// LatencyBalancer reads from the target with the lowest exponentially
+// weighted moving average latency, penalized by its recent error rate
+type LatencyBalancer struct {
+	stats map[string]*targetStats
+	mutex sync.Mutex
+}
+
+// targetStats are the moving averages observed for one target
+type targetStats struct {
+	latencyMs float64
+	errorRate float64
+	lastSeen  time.Time
+	picked    time.Time
+}
+
+// NewLatencyBalancer creates a balancer with no observations
+func NewLatencyBalancer() *LatencyBalancer {
+	return &LatencyBalancer{stats: make(map[string]*targetStats)}
+}
+
+// Pick implements Balancer. Targets never observed or not observed recently
+// are picked first to measure them.
+func (lb *LatencyBalancer) Pick(targets []ReadTarget) int {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	now := time.Now()
+	best, bestScore := 0, -1.0
+	for i, target := range targets {
+		stats, seen := lb.stats[target.Name]
+		if !seen {
+			stats = &targetStats{}
+			lb.stats[target.Name] = stats
+		}
+		// One probe per interval; the probe's outcome refreshes lastSeen
+		if now.Sub(stats.lastSeen) >= probeInterval && now.Sub(stats.picked) >= probeInterval {
+			stats.picked = now
+			return i
+		}
+
+		score := stats.latencyMs * (1 + errorPenalty*stats.errorRate)
+		if bestScore < 0 || score < bestScore {
+			best, bestScore = i, score
+		}
+	}
+	lb.stats[targets[best].Name].picked = now
+	return best
+}
+
+// Observe implements Balancer
+func (lb *LatencyBalancer) Observe(target string, latency time.Duration, err error) {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	stats, seen := lb.stats[target]
+	if !seen {
+		stats = &targetStats{}
+		lb.stats[target] = stats
+	}
+
+	failed := 0.0
+	if err != nil {
+		failed = 1
+	}
+	latencyMs := float64(latency.Microseconds()) / 1000
+	if stats.lastSeen.IsZero() {
+		stats.latencyMs, stats.errorRate = latencyMs, failed
+	} else {
+		stats.latencyMs = latencyAlpha*latencyMs + (1-latencyAlpha)*stats.latencyMs
+		stats.errorRate = latencyAlpha*failed + (1-latencyAlpha)*stats.errorRate
+	}
+	stats.lastSeen = time.Now()
+}
+
+// SetBalancer sets how reads choose among replicas and mirrored copies
+func (ds *DataStore) SetBalancer(balancer Balancer) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	ds.balancer = balancer
+}
+
+// readBalancer returns the configured balancer
+func (ds *DataStore) readBalancer() Balancer {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+
+	if ds.balancer == nil {
+		return LeastLagBalancer{}
+	}
+	return ds.balancer
+}
+
+// PickShard chooses which of several shards holding the same data serves a
+// read, such as the in-sync copies of a broadcast table
+func (ds *DataStore) PickShard(shardIDs []string) string {
+	if len(shardIDs) == 0 {
+		return ""
+	}
+	targets := make([]ReadTarget, len(shardIDs))
+	for i, shardID := range shardIDs {
+		targets[i] = ReadTarget{Name: shardID}
+	}
+	return shardIDs[ds.readBalancer().Pick(targets)]
+}
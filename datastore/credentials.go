@@ -0,0 +1,122 @@
+package datastore
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// UpdateShardDSN reconnects a shard's primary with a new DSN, such as one
+// carrying a rotated password. The old pools are closed in the background,
+// letting the statements already running on them finish.
+func (ds *DataStore) UpdateShardDSN(shardID, dsn string) error {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	return ds.updateShardDSN(shardID, dsn)
+}
+
+// updateShardDSN replaces a shard's primary pools with ones using dsn. Must
+// be called with ds.mutex held.
+func (ds *DataStore) updateShardDSN(shardID, dsn string) error {
+	old, exists := ds.connections[shardID]
+	if !exists {
+		return fmt.Errorf("shard %s not found", shardID)
+	}
+
+	db, err := ds.openDB(shardID, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open connection to shard %s: %w", shardID, err)
+	}
+	ds.connections[shardID] = db
+	ds.dsns[shardID] = dsn
+
+	// Per-database pools reopen from the new DSN on next use
+	stale := []*sql.DB{old}
+	for key, schemaDB := range ds.schemaConnections {
+		if strings.HasPrefix(key, shardID+"/") {
+			stale = append(stale, schemaDB)
+			delete(ds.schemaConnections, key)
+		}
+	}
+	go closePools(stale)
+	return nil
+}
+
+// RotatePassword reconnects the primaries and replicas that log in as user
+// with oldPassword using newPassword instead, returning the new DSNs of the
+// primaries by shard
+func (ds *DataStore) RotatePassword(user, oldPassword, newPassword string) (map[string]string, error) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	rotated := make(map[string]string)
+	var errs []error
+	for shardID, dsn := range ds.dsns {
+		updated, matches := replacePassword(dsn, user, oldPassword, newPassword)
+		if !matches {
+			continue
+		}
+		if err := ds.updateShardDSN(shardID, updated); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		rotated[shardID] = updated
+	}
+
+	for shardID, shardReplicas := range ds.replicas {
+		for i, r := range shardReplicas {
+			updated, matches := replacePassword(r.dsn, user, oldPassword, newPassword)
+			if !matches {
+				continue
+			}
+			db, err := ds.openDB(shardID, updated)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to open connection to replica of shard %s: %w", shardID, err))
+				continue
+			}
+
+			// Lag checks may still hold the old replica, so it is replaced
+			// rather than changed in place
+			replaced := &replica{
+				name:      r.name,
+				dsn:       updated,
+				db:        db,
+				schemaDBs: make(map[string]*sql.DB),
+				lag:       r.lag,
+				healthy:   r.healthy,
+				status:    r.status,
+				region:    r.region,
+			}
+			stale := []*sql.DB{r.db}
+			for _, schemaDB := range r.schemaDBs {
+				stale = append(stale, schemaDB)
+			}
+			shardReplicas[i] = replaced
+			go closePools(stale)
+		}
+	}
+
+	return rotated, errors.Join(errs...)
+}
+
+// replacePassword swaps the password of a DSN logging in as user with
+// oldPassword, reporting whether it did
+func replacePassword(dsn, user, oldPassword, newPassword string) (string, bool) {
+	dsnConfig, err := mysql.ParseDSN(dsn)
+	if err != nil || dsnConfig.User != user || dsnConfig.Passwd != oldPassword {
+		return dsn, false
+	}
+	dsnConfig.Passwd = newPassword
+	return dsnConfig.FormatDSN(), true
+}
+
+// closePools closes connection pools that were replaced
+func closePools(pools []*sql.DB) {
+	for _, db := range pools {
+		db.Close()
+	}
+}
@@ -1,25 +1,69 @@
 package datastore
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"sql-horizontal-autoscaler/metrics"
 )
 
 // DataStore manages database connections and query execution
 type DataStore struct {
 	connections     map[string]*sql.DB
+	dsns            map[string]string
+	schemaConnections map[string]*sql.DB
 	mutex           sync.RWMutex
 	metricsCollector *metrics.RealMetricsCollector
+	systemSampler    *metrics.SystemSampler
+
+	authConfig         AuthConfig
+	rotatingConnectors []*rotatingConnector
+	tokenRefreshStop   chan struct{}
+
+	legacyStringValues bool
+
+	replicas           map[string][]*replica
+	replicaCount       map[string]int
+	replicaLagInterval time.Duration
+	replicaLagStop     chan struct{}
+	balancer           Balancer
+	replicationConfig  ReplicationConfig
+	regionConfig       RegionConfig
+
+	poolConfig PoolConfig
+	drained    map[string]bool
+
+	// readOnlyFallback serves reads of shards whose primary is down from
+	// replicas; primaryDown holds those shards and since when
+	readOnlyFallback bool
+	primaryDown      map[string]time.Time
+
+	// snapshotWaitTimeout bounds the wait for a replica to reach the cut of
+	// a snapshot read
+	snapshotWaitTimeout time.Duration
+
+	// counters holds the statements run on each shard, guarded by
+	// countersMutex
+	counters      map[string]*ShardCounters
+	countersMutex sync.Mutex
 }
 
 // NewDataStore creates a new DataStore instance
 func NewDataStore() *DataStore {
 	return &DataStore{
-		connections: make(map[string]*sql.DB),
+		connections:       make(map[string]*sql.DB),
+		dsns:              make(map[string]string),
+		schemaConnections: make(map[string]*sql.DB),
+		poolConfig:        defaultPoolConfig,
+		drained:           make(map[string]bool),
+		primaryDown:       make(map[string]time.Time),
+		counters:          make(map[string]*ShardCounters),
 	}
 }
 
@@ -29,7 +73,7 @@ func (ds *DataStore) InitializeConnections(shards map[string]string, tableNames
 	defer ds.mutex.Unlock()
 
 	for shardID, dsn := range shards {
-		db, err := sql.Open("mysql", dsn)
+		db, err := ds.openDB(shardID, dsn)
 		if err != nil {
 			return fmt.Errorf("failed to open connection to shard %s: %w", shardID, err)
 		}
@@ -40,15 +84,16 @@ func (ds *DataStore) InitializeConnections(shards map[string]string, tableNames
 			return fmt.Errorf("failed to ping shard %s: %w", shardID, err)
 		}
 
-		// Configure connection pool
-		db.SetMaxOpenConns(25)
-		db.SetMaxIdleConns(5)
-
 		ds.connections[shardID] = db
+		ds.dsns[shardID] = dsn
 	}
 
+	// Start background host sampling so metric collection never blocks on CPU measurement
+	ds.systemSampler = metrics.NewSystemSampler(time.Second)
+	ds.systemSampler.Start()
+
 	// Initialize metrics collector with real connections and table names
-	ds.metricsCollector = metrics.NewRealMetricsCollector(ds.connections, tableNames)
+	ds.metricsCollector = metrics.NewRealMetricsCollector(ds.primaryConnection, tableNames, ds.systemSampler)
 
 	return nil
 }
@@ -64,7 +109,7 @@ func (ds *DataStore) AddShardConnection(shardID, dsn string, tableNames []string
 	}
 
 	// Create new database connection
-	db, err := sql.Open("mysql", dsn)
+	db, err := ds.openDB(shardID, dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open connection to shard %s: %w", shardID, err)
 	}
@@ -75,49 +120,153 @@ func (ds *DataStore) AddShardConnection(shardID, dsn string, tableNames []string
 		return fmt.Errorf("failed to ping shard %s: %w", shardID, err)
 	}
 
-	// Configure connection pool
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-
 	// Add to connections map
 	ds.connections[shardID] = db
+	ds.dsns[shardID] = dsn
 
 	// Update metrics collector with new connection
 	if ds.metricsCollector != nil {
-		ds.metricsCollector = metrics.NewRealMetricsCollector(ds.connections, tableNames)
+		ds.metricsCollector = metrics.NewRealMetricsCollector(ds.primaryConnection, tableNames, ds.systemSampler)
 	}
 
 	return nil
 }
 
-// ExecuteQuery executes a query on a specific shard
-func (ds *DataStore) ExecuteQuery(query string, shardID string) ([]map[string]interface{}, error) {
+// RemoveShardConnection closes and forgets all connection pools of a shard
+func (ds *DataStore) RemoveShardConnection(shardID string) error {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	db, exists := ds.connections[shardID]
+	if !exists {
+		return fmt.Errorf("shard %s not found", shardID)
+	}
+
+	delete(ds.connections, shardID)
+	delete(ds.dsns, shardID)
+	for key, schemaDB := range ds.schemaConnections {
+		if strings.HasPrefix(key, shardID+"/") {
+			schemaDB.Close()
+			delete(ds.schemaConnections, key)
+		}
+	}
+	for _, r := range ds.replicas[shardID] {
+		for _, schemaDB := range r.schemaDBs {
+			schemaDB.Close()
+		}
+		r.db.Close()
+	}
+	delete(ds.replicas, shardID)
+	delete(ds.drained, shardID)
+
+	return db.Close()
+}
+
+// ExecuteQuery executes a query on a specific shard. If database is non-empty
+// the query runs with that database as the default schema.
+func (ds *DataStore) ExecuteQuery(query string, shardID string, database string) ([]map[string]interface{}, error) {
+	return ds.executeQueryContext(context.Background(), query, shardID, database)
+}
+
+// executeQueryContext executes a query on a shard, abandoning it when ctx is
+// cancelled
+func (ds *DataStore) executeQueryContext(ctx context.Context, query string, shardID string, database string) ([]map[string]interface{}, error) {
+	db, err := ds.getConnection(shardID, database)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	rows, err := db.QueryContext(ctx, clientStatement(query))
+	if err != nil {
+		ds.countRead(shardID, start, nil, err)
+		ds.observePrimary(shardID, err)
+		return nil, fmt.Errorf("failed to execute query on shard %s: %w", shardID, err)
+	}
+	defer rows.Close()
+
+	data, err := scanRows(rows, ds.useTypedValues(), budgetFrom(ctx))
+	ds.countRead(shardID, start, data, err)
+	return data, err
+}
+
+// primaryConnection returns the connection pool of a shard's primary
+func (ds *DataStore) primaryConnection(shardID string) (*sql.DB, bool) {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+
+	db, exists := ds.connections[shardID]
+	return db, exists
+}
+
+// getConnection returns the connection pool for a shard, opening a dedicated
+// pool on first use when a non-default database is requested
+func (ds *DataStore) getConnection(shardID string, database string) (*sql.DB, error) {
 	ds.mutex.RLock()
 	db, exists := ds.connections[shardID]
+	schemaDB, schemaExists := ds.schemaConnections[shardID+"/"+database]
 	ds.mutex.RUnlock()
 
 	if !exists {
 		return nil, fmt.Errorf("shard %s not found", shardID)
 	}
+	if database == "" {
+		return db, nil
+	}
+	if schemaExists {
+		return schemaDB, nil
+	}
+
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	key := shardID + "/" + database
+	if schemaDB, exists := ds.schemaConnections[key]; exists {
+		return schemaDB, nil
+	}
 
-	rows, err := db.Query(query)
+	dsnConfig, err := mysql.ParseDSN(ds.dsns[shardID])
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query on shard %s: %w", shardID, err)
+		return nil, fmt.Errorf("failed to parse DSN for shard %s: %w", shardID, err)
+	}
+	dsnConfig.DBName = database
+
+	schemaDB, err = ds.openDB(shardID, dsnConfig.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open connection to database %s on shard %s: %w", database, shardID, err)
 	}
-	defer rows.Close()
 
-	return scanRows(rows)
+	ds.schemaConnections[key] = schemaDB
+	return schemaDB, nil
 }
 
-// ExecuteQueryOnAllShards executes a query on all shards concurrently (scatter-gather)
-func (ds *DataStore) ExecuteQueryOnAllShards(query string) ([]map[string]interface{}, error) {
+// ShardIDs returns the IDs of all shards with an open connection, sorted
+func (ds *DataStore) ShardIDs() []string {
 	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+
 	shardIDs := make([]string, 0, len(ds.connections))
 	for shardID := range ds.connections {
 		shardIDs = append(shardIDs, shardID)
 	}
-	ds.mutex.RUnlock()
+	sort.Strings(shardIDs)
+	return shardIDs
+}
+
+// ExecuteQueryOnAllShards executes a query on all shards concurrently (scatter-gather)
+func (ds *DataStore) ExecuteQueryOnAllShards(query string, database string) ([]map[string]interface{}, error) {
+	return ds.ExecuteQueryOnShards(query, ds.ShardIDs(), database)
+}
+
+// ExecuteQueryOnShards executes a query on the given shards concurrently and merges the results
+func (ds *DataStore) ExecuteQueryOnShards(query string, shardIDs []string, database string) ([]map[string]interface{}, error) {
+	return ds.executeOnShards(shardIDs, func(shardID string) ([]map[string]interface{}, error) {
+		return ds.ExecuteQuery(query, shardID, database)
+	})
+}
 
+// executeOnShards runs execute for each shard concurrently and merges the rows
+func (ds *DataStore) executeOnShards(shardIDs []string, execute func(shardID string) ([]map[string]interface{}, error)) ([]map[string]interface{}, error) {
 	// Channel to collect results from all shards
 	type shardResult struct {
 		shardID string
@@ -133,7 +282,7 @@ func (ds *DataStore) ExecuteQueryOnAllShards(query string) ([]map[string]interfa
 		wg.Add(1)
 		go func(sID string) {
 			defer wg.Done()
-			data, err := ds.ExecuteQuery(query, sID)
+			data, err := execute(sID)
 			resultChan <- shardResult{
 				shardID: sID,
 				data:    data,
@@ -152,7 +301,7 @@ func (ds *DataStore) ExecuteQueryOnAllShards(query string) ([]map[string]interfa
 
 	for result := range resultChan {
 		if result.err != nil {
-			errors = append(errors, fmt.Errorf("shard %s: %w", result.shardID, result.err))
+			errors = append(errors, &ShardError{ShardID: result.shardID, Err: result.err})
 		} else {
 			allResults = append(allResults, result.data...)
 		}
@@ -166,24 +315,80 @@ func (ds *DataStore) ExecuteQueryOnAllShards(query string) ([]map[string]interfa
 	return allResults, nil
 }
 
+// GetTableColumns returns the column names of a table on a shard in definition order
+func (ds *DataStore) GetTableColumns(shardID string, database string, table string) ([]string, error) {
+	db, err := ds.getConnection(shardID, database)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(fmt.Sprintf("SHOW COLUMNS FROM `%s`", table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe table %s on shard %s: %w", table, shardID, err)
+	}
+	defer rows.Close()
+
+	data, err := scanRows(rows, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, 0, len(data))
+	for _, row := range data {
+		columns = append(columns, fmt.Sprintf("%v", row["Field"]))
+	}
+	return columns, nil
+}
+
+// EstimateTableRows returns the row count the server estimates for a table on
+// a shard, which costs no scan
+func (ds *DataStore) EstimateTableRows(shardID string, database string, table string) (int64, error) {
+	db, err := ds.getConnection(shardID, database)
+	if err != nil {
+		return 0, err
+	}
+
+	var rows int64
+	err = db.QueryRow("SELECT COALESCE(TABLE_ROWS, 0) FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", table).Scan(&rows)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to estimate rows of table %s on shard %s: %w", table, shardID, err)
+	}
+	return rows, nil
+}
+
 // GetShardMetrics returns real metrics for a shard
 func (ds *DataStore) GetShardMetrics(shardID string) (*metrics.ShardMetrics, error) {
-	if ds.metricsCollector == nil {
+	ds.mutex.RLock()
+	collector := ds.metricsCollector
+	ds.mutex.RUnlock()
+
+	if collector == nil {
 		return nil, fmt.Errorf("metrics collector not initialized")
 	}
 
-	return ds.metricsCollector.CollectShardMetrics(shardID)
+	return collector.CollectShardMetrics(shardID)
 }
 
 
 
-// scanRows converts sql.Rows to a slice of maps
-func scanRows(rows *sql.Rows) ([]map[string]interface{}, error) {
+// scanRows converts sql.Rows to a slice of maps. When typed is set values are
+// decoded according to the column types; otherwise they are returned as strings.
+// A non-nil budget stops the scan once exhausted, returning the rows read so
+// far with a *ResultTooLargeError.
+func scanRows(rows *sql.Rows, typed bool, budget *resultBudget) ([]map[string]interface{}, error) {
 	columns, err := rows.Columns()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get columns: %w", err)
 	}
 
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get column types: %w", err)
+	}
+
 	var results []map[string]interface{}
 
 	for rows.Next() {
@@ -205,14 +410,22 @@ func scanRows(rows *sql.Rows) ([]map[string]interface{}, error) {
 		for i, col := range columns {
 			val := values[i]
 			
-			// Convert byte slices to strings for better JSON serialization
-			if b, ok := val.([]byte); ok {
+			if typed {
+				val = decodeValue(val, columnTypes[i])
+			} else if b, ok := val.([]byte); ok {
+				// Convert byte slices to strings for better JSON serialization
 				val = string(b)
 			}
 			
 			rowMap[col] = val
 		}
 
+		if budget != nil {
+			if err := budget.take(rowSize(rowMap)); err != nil {
+				return results, err
+			}
+		}
+
 		results = append(results, rowMap)
 	}
 
@@ -228,12 +441,26 @@ func (ds *DataStore) Close() error {
 	ds.mutex.Lock()
 	defer ds.mutex.Unlock()
 
+	if ds.systemSampler != nil {
+		ds.systemSampler.Stop()
+	}
+	if ds.tokenRefreshStop != nil {
+		close(ds.tokenRefreshStop)
+		ds.tokenRefreshStop = nil
+	}
+	ds.closeReplicas()
+
 	var errors []error
 	for shardID, db := range ds.connections {
 		if err := db.Close(); err != nil {
 			errors = append(errors, fmt.Errorf("failed to close connection to shard %s: %w", shardID, err))
 		}
 	}
+	for key, db := range ds.schemaConnections {
+		if err := db.Close(); err != nil {
+			errors = append(errors, fmt.Errorf("failed to close connection %s: %w", key, err))
+		}
+	}
 
 	if len(errors) > 0 {
 		return errors[0]
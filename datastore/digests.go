@@ -0,0 +1,90 @@
+package datastore
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// StatementDigest is the aggregated execution history of one normalized
+// statement on a shard, from performance_schema
+type StatementDigest struct {
+	Database     string  `json:"database"`
+	Digest       string  `json:"digest"`
+	DigestText   string  `json:"digest_text"`
+	Executions   int64   `json:"executions"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	RowsExamined int64   `json:"rows_examined"`
+	RowsSent     int64   `json:"rows_sent"`
+	NoIndexUsed  int64   `json:"no_index_used"`
+	NoGoodIndex  int64   `json:"no_good_index_used"`
+}
+
+// StatementDigests returns a shard's most expensive statement digests by total
+// latency. performance_schema must be enabled on the shard.
+func (ds *DataStore) StatementDigests(shardID string, limit int) ([]StatementDigest, error) {
+	db, err := ds.getConnection(shardID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Timers are in picoseconds
+	rows, err := db.Query(`SELECT SCHEMA_NAME, DIGEST, DIGEST_TEXT, COUNT_STAR,
+	SUM_TIMER_WAIT / COUNT_STAR / 1000000000, SUM_ROWS_EXAMINED, SUM_ROWS_SENT,
+	SUM_NO_INDEX_USED, SUM_NO_GOOD_INDEX_USED
+FROM performance_schema.events_statements_summary_by_digest
+WHERE SCHEMA_NAME IS NOT NULL AND DIGEST_TEXT IS NOT NULL AND COUNT_STAR > 0
+ORDER BY SUM_TIMER_WAIT DESC
+LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read statement digests of shard %s: %w", shardID, err)
+	}
+	defer rows.Close()
+
+	var digests []StatementDigest
+	for rows.Next() {
+		var d StatementDigest
+		var digest sql.NullString
+		if err := rows.Scan(&d.Database, &digest, &d.DigestText, &d.Executions, &d.AvgLatencyMs,
+			&d.RowsExamined, &d.RowsSent, &d.NoIndexUsed, &d.NoGoodIndex); err != nil {
+			return nil, fmt.Errorf("failed to read statement digests of shard %s: %w", shardID, err)
+		}
+		d.Digest = digest.String
+		digests = append(digests, d)
+	}
+
+	return digests, rows.Err()
+}
+
+// IndexedColumns returns the columns of every index of a shard database,
+// by table and index name, in index order
+func (ds *DataStore) IndexedColumns(shardID, database string) (map[string]map[string][]string, error) {
+	db, err := ds.getConnection(shardID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT TABLE_NAME, INDEX_NAME, COLUMN_NAME
+FROM information_schema.STATISTICS
+WHERE TABLE_SCHEMA = ?
+ORDER BY TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX`, database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes of %s on shard %s: %w", database, shardID, err)
+	}
+	defer rows.Close()
+
+	indexes := make(map[string]map[string][]string)
+	for rows.Next() {
+		var table, index string
+		var column sql.NullString
+		if err := rows.Scan(&table, &index, &column); err != nil {
+			return nil, fmt.Errorf("failed to list indexes of %s on shard %s: %w", database, shardID, err)
+		}
+		if indexes[table] == nil {
+			indexes[table] = make(map[string][]string)
+		}
+		// Functional index parts have no column name
+		indexes[table][index] = append(indexes[table][index], column.String)
+	}
+
+	return indexes, rows.Err()
+}
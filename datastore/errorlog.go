@@ -0,0 +1,52 @@
+package datastore
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrorLogEntry is a line of a shard's MySQL error log
+type ErrorLogEntry struct {
+	Logged    time.Time `json:"logged"`
+	Priority  string    `json:"priority"`
+	ErrorCode string    `json:"error_code"`
+	Subsystem string    `json:"subsystem"`
+	Message   string    `json:"message"`
+}
+
+// ErrorLog returns the last tail entries of a shard's MySQL error log, oldest
+// first, from performance_schema.error_log (MySQL 8.0.22 and later), so it
+// works for shards the coordinator can't reach through Docker
+func (ds *DataStore) ErrorLog(shardID string, tail int) ([]ErrorLogEntry, error) {
+	db, err := ds.getConnection(shardID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT DATE_FORMAT(LOGGED, '%Y-%m-%d %H:%i:%s.%f'), PRIO, ERROR_CODE, SUBSYSTEM, DATA
+FROM performance_schema.error_log
+ORDER BY LOGGED DESC LIMIT ?`, tail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read error log of shard %s: %w", shardID, err)
+	}
+	defer rows.Close()
+
+	var entries []ErrorLogEntry
+	for rows.Next() {
+		var entry ErrorLogEntry
+		var logged string
+		if err := rows.Scan(&logged, &entry.Priority, &entry.ErrorCode, &entry.Subsystem, &entry.Message); err != nil {
+			return nil, fmt.Errorf("failed to read error log of shard %s: %w", shardID, err)
+		}
+		entry.Logged, _ = time.Parse("2006-01-02 15:04:05.999999", logged)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read error log of shard %s: %w", shardID, err)
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
@@ -0,0 +1,41 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+)
+
+// ShardError attributes a query failure to the shard it happened on
+type ShardError struct {
+	ShardID string
+	Err     error
+}
+
+// Error implements error
+func (se *ShardError) Error() string {
+	return fmt.Sprintf("shard %s: %v", se.ShardID, se.Err)
+}
+
+// Unwrap returns the underlying error
+func (se *ShardError) Unwrap() error {
+	return se.Err
+}
+
+// DeadlineError reports a request's deadline passing before every shard it
+// was sent to answered
+type DeadlineError struct {
+	// Completed lists the shards that answered in time
+	Completed []string
+	// Pending lists the shards abandoned or never queried
+	Pending []string
+}
+
+// Error implements error
+func (de *DeadlineError) Error() string {
+	return fmt.Sprintf("deadline exceeded with %d of %d shards completed", len(de.Completed), len(de.Completed)+len(de.Pending))
+}
+
+// Unwrap returns context.DeadlineExceeded
+func (de *DeadlineError) Unwrap() error {
+	return context.DeadlineExceeded
+}
@@ -0,0 +1,80 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+)
+
+// RowWriter receives the rows of an export as they are read
+type RowWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []interface{}) error
+}
+
+// ExportRows streams the rows of a query on a shard's primary to a writer,
+// one row at a time. The query runs in a single read-only transaction started
+// WITH CONSISTENT SNAPSHOT, so every row comes from the same point in time
+// however long the export takes.
+func (ds *DataStore) ExportRows(ctx context.Context, query string, args []interface{}, shardID string, database string, writer RowWriter) error {
+	db, err := ds.getConnection(shardID, database)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to shard %s: %w", shardID, err)
+	}
+	defer conn.Close()
+
+	for _, statement := range []string{"SET TRANSACTION ISOLATION LEVEL REPEATABLE READ", "START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY"} {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to start snapshot on shard %s: %w", shardID, err)
+		}
+	}
+	// Nothing was written, so the transaction is simply ended
+	defer conn.ExecContext(context.Background(), "ROLLBACK")
+
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to execute query on shard %s: %w", shardID, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("failed to get columns: %w", err)
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return fmt.Errorf("failed to get column types: %w", err)
+	}
+	if err := writer.WriteHeader(columns); err != nil {
+		return err
+	}
+
+	typed := ds.useTypedValues()
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		for i, val := range values {
+			if typed {
+				values[i] = decodeValue(val, columnTypes[i])
+			} else if b, ok := val.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		if err := writer.WriteRow(values); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+	return nil
+}
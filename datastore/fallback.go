@@ -0,0 +1,107 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// WriteUnavailableError rejects a write to a shard whose primary is down
+// while its replicas keep serving reads
+type WriteUnavailableError struct {
+	ShardID string
+	Since   time.Time
+}
+
+func (e *WriteUnavailableError) Error() string {
+	return fmt.Sprintf("shard %s is temporarily write-unavailable: its primary has been unreachable since %s; reads are served by its replicas",
+		e.ShardID, e.Since.Format("15:04:05 MST"))
+}
+
+// SetReadOnlyFallback makes a shard whose primary can't be reached fall back
+// to its healthy replicas: strong reads are served by a replica and writes
+// are rejected with a WriteUnavailableError until the primary answers a ping
+func (ds *DataStore) SetReadOnlyFallback(enabled bool) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	ds.readOnlyFallback = enabled
+}
+
+// PrimaryDown reports whether a shard is in read-only fallback, and since when
+func (ds *DataStore) PrimaryDown(shardID string) (time.Time, bool) {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+	since, down := ds.primaryDown[shardID]
+	return since, down
+}
+
+// observePrimary puts a shard in read-only fallback when a statement on its
+// primary fails to reach the server and a replica can take over its reads
+func (ds *DataStore) observePrimary(shardID string, err error) {
+	if err == nil || !isUnavailable(err) {
+		return
+	}
+
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	if !ds.readOnlyFallback {
+		return
+	}
+	if _, down := ds.primaryDown[shardID]; down {
+		return
+	}
+	for _, r := range ds.replicas[shardID] {
+		if r.healthy {
+			ds.primaryDown[shardID] = time.Now()
+			log.Printf("⚠️  Primary of shard %s unreachable, serving its reads from replicas and rejecting writes: %v", shardID, err)
+			return
+		}
+	}
+}
+
+// probePrimaries pings the primaries of shards in read-only fallback, ending
+// the fallback of those answering again
+func (ds *DataStore) probePrimaries() {
+	ds.mutex.RLock()
+	down := make(map[string]time.Time, len(ds.primaryDown))
+	for shardID, since := range ds.primaryDown {
+		down[shardID] = since
+	}
+	ds.mutex.RUnlock()
+
+	for shardID, since := range down {
+		db, err := ds.getConnection(shardID, "")
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			err = db.PingContext(ctx)
+			cancel()
+		}
+		if err != nil {
+			continue
+		}
+
+		ds.mutex.Lock()
+		delete(ds.primaryDown, shardID)
+		ds.mutex.Unlock()
+		log.Printf("✅ Primary of shard %s reachable again after %s, accepting writes", shardID, time.Since(since).Round(time.Second))
+	}
+}
+
+// fallbackRead serves a strong read of a shard in read-only fallback from its
+// least lagged healthy replica. It returns false when the shard isn't in
+// fallback or has no healthy replica left.
+func (ds *DataStore) fallbackRead(ctx context.Context, query, shardID, database string) ([]map[string]interface{}, bool, error) {
+	if _, down := ds.PrimaryDown(shardID); !down {
+		return nil, false, nil
+	}
+	db, name, err := ds.fallbackReplica(shardID, database, "")
+	if err != nil {
+		return nil, false, nil
+	}
+
+	start := time.Now()
+	data, err := ds.queryReplica(ctx, db, shardID, query)
+	ds.observeRead(name, start, err)
+	return data, true, err
+}
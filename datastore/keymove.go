@@ -0,0 +1,185 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// KeyMove describes an UPDATE changing a row's shard key so that the row now
+// belongs on another shard
+type KeyMove struct {
+	// Update is the UPDATE statement, run on the source shard
+	Update string
+	// Table is the quoted, possibly database-qualified, table name
+	Table       string
+	KeyColumn   string
+	NewKey      interface{}
+	Source      string
+	Destination string
+	Database    string
+}
+
+// ExecuteKeyMove runs a shard key update and moves the updated rows to the
+// shard owning their new key, as one XA transaction across both shards: the
+// source applies the update and deletes the rows now carrying the new key,
+// and the destination inserts them. The results report the rows updated on
+// the source and inserted on the destination. Failures are reported like
+// ExecuteXAWrite's.
+func (ds *DataStore) ExecuteKeyMove(move KeyMove, xid string, decisions *XALog) ([]WriteResult, error) {
+	ctx, cancel := decisions.begin(xid)
+	defer cancel()
+	defer decisions.end(xid)
+	byNewKey := fmt.Sprintf("SELECT * FROM %s WHERE `%s` = ?", move.Table, move.KeyColumn)
+
+	// The source must not already hold rows under the new key, or they would
+	// be moved along with the updated ones
+	src, err := ds.startXA(ctx, move.Source, move.Database, xid)
+	if err != nil {
+		return nil, fmt.Errorf("write rolled back on all shards: %w", err)
+	}
+	var existing int64
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE `%s` = ?", move.Table, move.KeyColumn)
+	if err := src.QueryRowContext(ctx, countQuery, move.NewKey).Scan(&existing); err != nil {
+		abortXA(src, xid)
+		return nil, fmt.Errorf("write rolled back on all shards: %w", &ShardError{ShardID: move.Source, Err: err})
+	}
+	if existing > 0 {
+		abortXA(src, xid)
+		return nil, fmt.Errorf("write rolled back on all shards: shard %s already holds %d rows with %s = %v",
+			move.Source, existing, move.KeyColumn, move.NewKey)
+	}
+
+	updated, columns, rows, err := updateAndTakeRows(ctx, src, move, byNewKey)
+	if err != nil {
+		abortXA(src, xid)
+		return nil, fmt.Errorf("write rolled back on all shards: %w", &ShardError{ShardID: move.Source, Err: err})
+	}
+
+	dst, err := ds.startXA(ctx, move.Destination, move.Database, xid)
+	if err != nil {
+		abortXA(src, xid)
+		return nil, fmt.Errorf("write rolled back on all shards: %w", err)
+	}
+	if err := insertRows(ctx, dst, move.Table, columns, rows); err != nil {
+		abortXA(src, xid)
+		abortXA(dst, xid)
+		return nil, fmt.Errorf("write rolled back on all shards: %w", &ShardError{ShardID: move.Destination, Err: err})
+	}
+
+	rollback := func(conns ...*sql.Conn) {
+		for _, conn := range conns {
+			if _, err := conn.ExecContext(context.Background(), "XA ROLLBACK "+quoteXID(xid)); err != nil {
+				ds.logXAError("", xid, "roll back", err)
+			}
+			conn.Close()
+		}
+	}
+	if err := prepareXABranch(ctx, src, move.Source, xid); err != nil {
+		abortXA(dst, xid)
+		return nil, fmt.Errorf("write rolled back on all shards: %w", err)
+	}
+	if err := prepareXABranch(ctx, dst, move.Destination, xid); err != nil {
+		rollback(src)
+		return nil, fmt.Errorf("write rolled back on all shards: %w", err)
+	}
+
+	shardIDs := []string{move.Source, move.Destination}
+	if err := decisions.RecordCommit(xid, shardIDs); err != nil {
+		rollback(src, dst)
+		return nil, fmt.Errorf("write rolled back on all shards: %w", err)
+	}
+
+	results := []WriteResult{
+		{ShardID: move.Source, RowsAffected: updated},
+		{ShardID: move.Destination, RowsAffected: int64(len(rows))},
+	}
+	inDoubt := &InDoubtError{XID: xid, InDoubt: make(map[string]error)}
+	for i, conn := range []*sql.Conn{src, dst} {
+		_, err := conn.ExecContext(context.Background(), "XA COMMIT "+quoteXID(xid))
+		conn.Close()
+		if err != nil {
+			results[i].RowsAffected = 0
+			results[i].Err = fmt.Errorf("failed to commit on shard %s: %w", shardIDs[i], err)
+			inDoubt.InDoubt[shardIDs[i]] = err
+			continue
+		}
+		inDoubt.Committed = append(inDoubt.Committed, shardIDs[i])
+	}
+
+	if len(inDoubt.InDoubt) > 0 {
+		return results, inDoubt
+	}
+	if err := decisions.RecordDone(xid); err != nil {
+		ds.logXAError("", xid, "record completion of", err)
+	}
+	return results, nil
+}
+
+// updateAndTakeRows runs the update on the source branch, then reads and
+// deletes the rows carrying the new key
+func updateAndTakeRows(ctx context.Context, conn *sql.Conn, move KeyMove, byNewKey string) (int64, []string, [][]interface{}, error) {
+	result, err := conn.ExecContext(ctx, clientStatement(move.Update))
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to execute update: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to read affected rows: %w", err)
+	}
+
+	rows, err := conn.QueryContext(ctx, byNewKey, move.NewKey)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to read updated rows: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+	var values [][]interface{}
+	for rows.Next() {
+		row := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range row {
+			ptrs[i] = &row[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return 0, nil, nil, fmt.Errorf("failed to scan updated row: %w", err)
+		}
+		values = append(values, row)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to read updated rows: %w", err)
+	}
+	rows.Close()
+
+	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE `%s` = ?", move.Table, move.KeyColumn)
+	if _, err := conn.ExecContext(ctx, deleteQuery, move.NewKey); err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to delete updated rows: %w", err)
+	}
+	return updated, columns, values, nil
+}
+
+// insertRows inserts rows read from another shard
+func insertRows(ctx context.Context, conn *sql.Conn, table string, columns []string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = "`" + strings.ReplaceAll(column, "`", "``") + "`"
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(quoted, ", "), placeholders)
+
+	for _, row := range rows {
+		if _, err := conn.ExecContext(ctx, insert, row...); err != nil {
+			return fmt.Errorf("failed to insert moved row: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ResultLimit caps the rows and bytes a read may return; zero means unlimited
+type ResultLimit struct {
+	MaxRows  int
+	MaxBytes int64
+}
+
+// ResultTooLargeError reports a read stopped at its result limit. It is
+// returned together with the rows collected up to the limit.
+type ResultTooLargeError struct {
+	Limit ResultLimit
+}
+
+// Error implements error
+func (e *ResultTooLargeError) Error() string {
+	return fmt.Sprintf("result exceeds the limit of %d rows / %d bytes", e.Limit.MaxRows, e.Limit.MaxBytes)
+}
+
+// resultBudget counts the rows and bytes read against a limit, shared by the
+// shards of a scatter-gather read
+type resultBudget struct {
+	limit ResultLimit
+	rows  int64
+	bytes int64
+}
+
+type resultBudgetKey struct{}
+
+// withResultLimit attaches a budget for limit to ctx
+func withResultLimit(ctx context.Context, limit ResultLimit) context.Context {
+	if limit.MaxRows <= 0 && limit.MaxBytes <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, resultBudgetKey{}, &resultBudget{limit: limit})
+}
+
+// budgetFrom returns the budget attached to ctx, or nil
+func budgetFrom(ctx context.Context) *resultBudget {
+	budget, _ := ctx.Value(resultBudgetKey{}).(*resultBudget)
+	return budget
+}
+
+// take accounts one row of size bytes, failing once the limit is exceeded
+func (b *resultBudget) take(size int64) error {
+	rows := atomic.AddInt64(&b.rows, 1)
+	bytes := atomic.AddInt64(&b.bytes, size)
+	if (b.limit.MaxRows > 0 && rows > int64(b.limit.MaxRows)) ||
+		(b.limit.MaxBytes > 0 && bytes > b.limit.MaxBytes) {
+		return &ResultTooLargeError{Limit: b.limit}
+	}
+	return nil
+}
+
+// rowSize estimates the encoded size of a row
+func rowSize(row map[string]interface{}) int64 {
+	var size int64
+	for column, value := range row {
+		size += int64(len(column))
+		switch v := value.(type) {
+		case string:
+			size += int64(len(v))
+		case []byte:
+			size += int64(len(v))
+		case time.Time:
+			size += 32
+		default:
+			size += 8
+		}
+	}
+	return size
+}
+
+// ExecuteReadLimited is ExecuteRead stopping at limit or when ctx is
+// cancelled. When the limit is hit the rows read so far are returned with a
+// *ResultTooLargeError.
+func (ds *DataStore) ExecuteReadLimited(ctx context.Context, query string, shardID string, database string, maxStaleness time.Duration, limit ResultLimit) ([]map[string]interface{}, bool, error) {
+	return ds.executeReadContext(withResultLimit(ctx, limit), query, shardID, database, maxStaleness)
+}
+
+// EstimateResultRows returns how many rows a read returns on a shard, running
+// it wrapped in a COUNT(*) on the shard's primary. A positive probeLimit
+// counts at most that many rows, so the shard stops reading once it's reached.
+func (ds *DataStore) EstimateResultRows(ctx context.Context, query string, shardID string, database string, probeLimit int) (int64, error) {
+	db, err := ds.getConnection(shardID, database)
+	if err != nil {
+		return 0, err
+	}
+
+	inner := strings.TrimRight(strings.TrimSpace(query), "; \t\n")
+	estimate := fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS result_estimate", inner)
+	if probeLimit > 0 {
+		estimate = fmt.Sprintf("SELECT COUNT(*) FROM (SELECT 1 FROM (%s) AS result_probe LIMIT %d) AS result_estimate", inner, probeLimit)
+	}
+
+	var rows int64
+	if err := db.QueryRowContext(ctx, estimate).Scan(&rows); err != nil {
+		return 0, &ShardError{ShardID: shardID, Err: fmt.Errorf("failed to estimate result size: %w", err)}
+	}
+	return rows, nil
+}
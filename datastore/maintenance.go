@@ -0,0 +1,34 @@
+package datastore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaintainTable runs a table maintenance statement such as ANALYZE TABLE on a
+// shard's primary. MySQL reports failures of these statements as result rows
+// rather than errors, so the rows are checked.
+func (ds *DataStore) MaintainTable(statement string, shardID string, database string) error {
+	db, err := ds.getConnection(shardID, database)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Query(statement)
+	if err != nil {
+		return fmt.Errorf("failed to run %q on shard %s: %w", statement, shardID, err)
+	}
+	defer rows.Close()
+
+	// Columns are Table, Op, Msg_type and Msg_text
+	for rows.Next() {
+		var table, op, msgType, msgText string
+		if err := rows.Scan(&table, &op, &msgType, &msgText); err != nil {
+			return fmt.Errorf("failed to read result of %q on shard %s: %w", statement, shardID, err)
+		}
+		if strings.EqualFold(msgType, "error") {
+			return fmt.Errorf("%s of %s failed on shard %s: %s", op, table, shardID, msgText)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,104 @@
+package datastore
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// PoolConfig controls the size and connection lifetime of shard connection pools
+type PoolConfig struct {
+	MaxOpenConns int
+	MaxIdleConns int
+	// ConnMaxLifetime closes connections after this age; 0 keeps them forever
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime closes connections idle for this long; 0 keeps them forever
+	ConnMaxIdleTime time.Duration
+}
+
+// defaultPoolConfig is used until SetPoolConfig is called
+var defaultPoolConfig = PoolConfig{MaxOpenConns: 25, MaxIdleConns: 5}
+
+// SetPoolConfig configures connection pools; call before InitializeConnections
+func (ds *DataStore) SetPoolConfig(poolConfig PoolConfig) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	ds.poolConfig = poolConfig
+}
+
+// configurePool applies the pool configuration to a newly opened pool of a
+// shard. Callers must hold ds.mutex.
+func (ds *DataStore) configurePool(shardID string, db *sql.DB) {
+	db.SetMaxOpenConns(ds.poolConfig.MaxOpenConns)
+	db.SetConnMaxLifetime(ds.poolConfig.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(ds.poolConfig.ConnMaxIdleTime)
+	if ds.drained[shardID] {
+		db.SetMaxIdleConns(0)
+	} else {
+		db.SetMaxIdleConns(ds.poolConfig.MaxIdleConns)
+	}
+}
+
+// shardPools returns every pool of a shard: primary, per-database and replica
+// pools. Callers must hold ds.mutex.
+func (ds *DataStore) shardPools(shardID string) []*sql.DB {
+	var pools []*sql.DB
+	if db, exists := ds.connections[shardID]; exists {
+		pools = append(pools, db)
+	}
+	for key, db := range ds.schemaConnections {
+		if strings.HasPrefix(key, shardID+"/") {
+			pools = append(pools, db)
+		}
+	}
+	for _, r := range ds.replicas[shardID] {
+		pools = append(pools, r.db)
+		for _, db := range r.schemaDBs {
+			pools = append(pools, db)
+		}
+	}
+	return pools
+}
+
+// DrainShard closes the idle connections of a cordoned shard and stops its
+// pools from keeping any, while leaving them usable
+func (ds *DataStore) DrainShard(shardID string) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	if ds.drained[shardID] {
+		return
+	}
+	if ds.drained == nil {
+		ds.drained = make(map[string]bool)
+	}
+	ds.drained[shardID] = true
+
+	for _, db := range ds.shardPools(shardID) {
+		db.SetMaxIdleConns(0)
+	}
+}
+
+// UndrainShard restores idle connection pooling for a shard
+func (ds *DataStore) UndrainShard(shardID string) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	if !ds.drained[shardID] {
+		return
+	}
+	delete(ds.drained, shardID)
+
+	for _, db := range ds.shardPools(shardID) {
+		db.SetMaxIdleConns(ds.poolConfig.MaxIdleConns)
+	}
+}
+
+// IsDrained reports whether a shard's pools are drained
+func (ds *DataStore) IsDrained(shardID string) bool {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+
+	return ds.drained[shardID]
+}
@@ -0,0 +1,105 @@
+package datastore
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// clientTag prefixes the statements run on behalf of clients, telling them
+// apart from the autoscaler's own copies, DDL and maintenance in the process
+// list
+const clientTag = "/* autoscaler:client */ "
+
+// clientStatement tags a client's statement with clientTag
+func clientStatement(query string) string {
+	return clientTag + query
+}
+
+// ActiveQuery is a statement currently executing on a shard
+type ActiveQuery struct {
+	ID          string `json:"id"`
+	ShardID     string `json:"shard_id"`
+	ProcessID   int64  `json:"process_id"`
+	User        string `json:"user"`
+	Host        string `json:"host"`
+	Database    string `json:"database"`
+	State       string `json:"state"`
+	TimeSeconds int64  `json:"time_seconds"`
+	Query       string `json:"query"`
+	// OwnUser is set for statements issued with the autoscaler's credentials
+	OwnUser bool `json:"own_user"`
+	// Client is set for statements the autoscaler runs on behalf of clients
+	Client bool `json:"client"`
+}
+
+// ActiveQueries lists the statements executing on a shard, excluding the
+// connection used to list them
+func (ds *DataStore) ActiveQueries(shardID string) ([]ActiveQuery, error) {
+	db, err := ds.getConnection(shardID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT ID, USER, HOST, DB, IFNULL(STATE, ''), TIME, INFO,
+	USER = SUBSTRING_INDEX(CURRENT_USER(), '@', 1)
+FROM information_schema.PROCESSLIST
+WHERE COMMAND = 'Query' AND ID <> CONNECTION_ID()`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queries on shard %s: %w", shardID, err)
+	}
+	defer rows.Close()
+
+	var queries []ActiveQuery
+	for rows.Next() {
+		var q ActiveQuery
+		var database, info sql.NullString
+		if err := rows.Scan(&q.ProcessID, &q.User, &q.Host, &database, &q.State, &q.TimeSeconds, &info, &q.OwnUser); err != nil {
+			return nil, fmt.Errorf("failed to read process list of shard %s: %w", shardID, err)
+		}
+		q.ShardID = shardID
+		q.ID = fmt.Sprintf("%s:%d", shardID, q.ProcessID)
+		q.Database = database.String
+		q.Query = info.String
+		q.Client = q.OwnUser && strings.HasPrefix(q.Query, clientTag)
+		queries = append(queries, q)
+	}
+
+	return queries, rows.Err()
+}
+
+// KillQuery aborts the statement running on a shard connection, leaving the
+// connection itself open
+func (ds *DataStore) KillQuery(shardID string, processID int64) error {
+	db, err := ds.getConnection(shardID, "")
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("KILL QUERY %d", processID)); err != nil {
+		return fmt.Errorf("failed to kill query %d on shard %s: %w", processID, shardID, err)
+	}
+	return nil
+}
+
+// SetSuperReadOnly turns super_read_only on or off on a shard's instance,
+// which also rejects writes from users with SUPER. Requires
+// SYSTEM_VARIABLES_ADMIN (or SUPER).
+func (ds *DataStore) SetSuperReadOnly(shardID string, enabled bool) error {
+	db, err := ds.getConnection(shardID, "")
+	if err != nil {
+		return err
+	}
+
+	statements := []string{"SET GLOBAL super_read_only = ON"}
+	if !enabled {
+		// super_read_only implies read_only, which stays on unless cleared
+		statements = []string{"SET GLOBAL super_read_only = OFF", "SET GLOBAL read_only = OFF"}
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("failed to run %q on shard %s: %w", statement, shardID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Write replication policies
+const (
+	// WritePolicyAsync returns once the shard's primary committed; replicas
+	// in every region catch up on their own
+	WritePolicyAsync = "async"
+	// WritePolicyLocal also waits for a replica in the local region to apply
+	// the write, so reads served in this region see it
+	WritePolicyLocal = "local"
+	// WritePolicyAll waits for a replica in every region with replicas
+	WritePolicyAll = "all"
+)
+
+// RegionConfig places this process, the shards and their replicas in regions.
+// Non-strong reads prefer the local region and fall back across regions.
+type RegionConfig struct {
+	// Local is the region this process serves from; empty ignores regions
+	Local string
+	// ReplicaRegions maps a replica's address to its region; replicas not
+	// listed are in their shard's region
+	ReplicaRegions map[string]string
+	// ShardRegion returns the region of a shard's primary
+	ShardRegion func(shardID string) string
+	// WritePolicy is WritePolicyAsync, WritePolicyLocal or WritePolicyAll
+	WritePolicy string
+	// WriteWaitTimeout bounds how long a write waits for replicas
+	WriteWaitTimeout time.Duration
+}
+
+// SetRegionConfig places this process, the shards and their replicas in
+// regions. Replicas added earlier are placed again.
+func (ds *DataStore) SetRegionConfig(regionConfig RegionConfig) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	ds.regionConfig = regionConfig
+	for shardID, shardReplicas := range ds.replicas {
+		for _, r := range shardReplicas {
+			r.region = ds.replicaRegion(shardID, r.dsn)
+		}
+	}
+}
+
+// replicaRegion returns the region of a shard's replica. Must be called with
+// the mutex held.
+func (ds *DataStore) replicaRegion(shardID, dsn string) string {
+	if region, exists := ds.regionConfig.ReplicaRegions[dsnAddress(dsn)]; exists {
+		return region
+	}
+	return ds.shardRegion(shardID)
+}
+
+// shardRegion returns the region of a shard's primary
+func (ds *DataStore) shardRegion(shardID string) string {
+	if ds.regionConfig.ShardRegion == nil {
+		return ""
+	}
+	return ds.regionConfig.ShardRegion(shardID)
+}
+
+// preferLocal narrows the replicas eligible for a read to the local region's.
+// When none are local it returns no replicas if the shard's primary is local,
+// so the primary serves the read, and otherwise every eligible replica, so
+// the read crosses regions. Must be called with the mutex held.
+func (ds *DataStore) preferLocal(shardID string, eligible []*replica) []*replica {
+	local := ds.regionConfig.Local
+	if local == "" {
+		return eligible
+	}
+
+	var inRegion []*replica
+	for _, r := range eligible {
+		if r.region == local {
+			inRegion = append(inRegion, r)
+		}
+	}
+	if len(inRegion) > 0 {
+		return inRegion
+	}
+	if ds.shardRegion(shardID) == local {
+		return nil
+	}
+	return eligible
+}
+
+// fallbackReplica returns a pool of the least lagged healthy replica of a
+// shard other than the one named, regardless of region and lag, for reads
+// whose first target is unreachable, and the replica's name
+func (ds *DataStore) fallbackReplica(shardID, database, exclude string) (*sql.DB, string, error) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	var candidate *replica
+	for _, r := range ds.replicas[shardID] {
+		if r.healthy && r.name != exclude && (candidate == nil || r.lag < candidate.lag) {
+			candidate = r
+		}
+	}
+	if candidate == nil {
+		return nil, "", fmt.Errorf("shard %s has no other healthy replica", shardID)
+	}
+
+	db, err := ds.replicaDB(shardID, candidate, database)
+	return db, candidate.name, err
+}
+
+// isUnavailable reports whether an error means the server couldn't be
+// reached, as opposed to the query failing on it
+func isUnavailable(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.As(err, &netErr)
+}
+
+// awaitReplication waits, per the write policy, for replicas of a shard to
+// apply everything its primary has committed. The write has already
+// committed, so failing to wait is only logged.
+func (ds *DataStore) awaitReplication(shardID string) {
+	ds.mutex.RLock()
+	regionConfig := ds.regionConfig
+	if regionConfig.WritePolicy == WritePolicyLocal && ds.shardRegion(shardID) == regionConfig.Local {
+		// The primary is local, so local reads already see the write
+		ds.mutex.RUnlock()
+		return
+	}
+	primary := ds.connections[shardID]
+	waitFor := make(map[string]*replica)
+	for _, r := range ds.replicas[shardID] {
+		if !r.healthy {
+			continue
+		}
+		if regionConfig.WritePolicy == WritePolicyLocal && r.region != regionConfig.Local {
+			continue
+		}
+		// One replica per region: the least lagged
+		if current, exists := waitFor[r.region]; !exists || r.lag < current.lag {
+			waitFor[r.region] = r
+		}
+	}
+	ds.mutex.RUnlock()
+
+	if primary == nil || len(waitFor) == 0 {
+		if regionConfig.WritePolicy == WritePolicyLocal {
+			log.Printf("Warning: Shard %s has no healthy replica in region %s to wait for", shardID, regionConfig.Local)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), regionConfig.WriteWaitTimeout)
+	defer cancel()
+	var executed string
+	if err := primary.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_executed").Scan(&executed); err != nil {
+		log.Printf("Warning: Failed to read the executed GTIDs of shard %s: %v", shardID, err)
+		return
+	}
+	for region, r := range waitFor {
+		if err := waitForGTIDs(r.db, executed, regionConfig.WriteWaitTimeout); err != nil {
+			log.Printf("Warning: Replica %s in region %s didn't apply a write to shard %s: %v", r.name, region, shardID, err)
+		}
+	}
+}
@@ -0,0 +1,545 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"sql-horizontal-autoscaler/metrics"
+)
+
+// Read staleness bounds accepted by ExecuteRead
+const (
+	// StalenessStrong requires the read to be served by the primary
+	StalenessStrong time.Duration = 0
+	// StalenessUnbounded allows any healthy replica regardless of lag
+	StalenessUnbounded time.Duration = -1
+	// StalenessSnapshot reads every shard at a cut of GTIDs captured on
+	// their primaries together, from replicas that have applied it
+	StalenessSnapshot time.Duration = -2
+)
+
+// replica is a read replica of a shard with its most recently observed lag
+type replica struct {
+	// name identifies the replica to the read balancer
+	name      string
+	dsn       string
+	db        *sql.DB
+	schemaDBs map[string]*sql.DB
+	lag       time.Duration
+	healthy   bool
+	// status is the replica's state from its most recent check
+	status metrics.ReplicaStatus
+	// region is the region the replica runs in
+	region string
+}
+
+// AddReplica registers a read replica for a shard
+func (ds *DataStore) AddReplica(shardID, dsn string) error {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	db, err := ds.openDB(shardID, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open connection to replica of shard %s: %w", shardID, err)
+	}
+
+	// Names stay unique as replicas come and go, so the balancer never
+	// confuses a new replica with a removed one
+	if ds.replicaCount == nil {
+		ds.replicaCount = make(map[string]int)
+	}
+	ds.replicaCount[shardID]++
+	r := &replica{
+		name:      fmt.Sprintf("%s/replica-%d", shardID, ds.replicaCount[shardID]),
+		dsn:       dsn,
+		db:        db,
+		schemaDBs: make(map[string]*sql.DB),
+		region:    ds.replicaRegion(shardID, dsn),
+	}
+	r.refreshLag()
+
+	if ds.replicas == nil {
+		ds.replicas = make(map[string][]*replica)
+	}
+	ds.replicas[shardID] = append(ds.replicas[shardID], r)
+
+	if ds.replicaLagStop == nil {
+		ds.replicaLagStop = make(chan struct{})
+		go ds.replicaLagLoop(ds.replicaLagStop)
+	}
+	return nil
+}
+
+// RemoveReplica stops reading from the replica of a shard registered with
+// dsn and closes its pools
+func (ds *DataStore) RemoveReplica(shardID, dsn string) error {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	shardReplicas := ds.replicas[shardID]
+	for i, r := range shardReplicas {
+		if r.dsn != dsn {
+			continue
+		}
+		ds.replicas[shardID] = append(shardReplicas[:i:i], shardReplicas[i+1:]...)
+		for _, schemaDB := range r.schemaDBs {
+			schemaDB.Close()
+		}
+		return r.db.Close()
+	}
+	return fmt.Errorf("shard %s has no such replica", shardID)
+}
+
+// ReplicaSelectCount returns the SELECTs served so far by the replicas of a
+// shard, summed from each replica's Com_select counter. Replicas that can't
+// be reached are left out.
+func (ds *DataStore) ReplicaSelectCount(shardID string) int64 {
+	ds.mutex.RLock()
+	shardReplicas := append([]*replica(nil), ds.replicas[shardID]...)
+	ds.mutex.RUnlock()
+
+	var total int64
+	for _, r := range shardReplicas {
+		var name string
+		var count int64
+		if err := r.db.QueryRow("SHOW GLOBAL STATUS LIKE 'Com_select'").Scan(&name, &count); err == nil {
+			total += count
+		}
+	}
+	return total
+}
+
+// SetReplicaLagInterval sets how often replica lag is measured; call before AddReplica
+func (ds *DataStore) SetReplicaLagInterval(interval time.Duration) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	ds.replicaLagInterval = interval
+}
+
+// ExecuteRead executes a read query on a shard, using a replica whose lag is
+// within maxStaleness when one is available and the primary otherwise. It
+// returns the rows and whether a replica served them.
+func (ds *DataStore) ExecuteRead(query string, shardID string, database string, maxStaleness time.Duration) ([]map[string]interface{}, bool, error) {
+	return ds.executeReadContext(context.Background(), query, shardID, database, maxStaleness)
+}
+
+// executeReadContext implements ExecuteRead, abandoning the query when ctx is
+// cancelled. The outcome is reported to the read balancer.
+func (ds *DataStore) executeReadContext(ctx context.Context, query string, shardID string, database string, maxStaleness time.Duration) ([]map[string]interface{}, bool, error) {
+	if maxStaleness == StalenessSnapshot {
+		return ds.snapshotRead(ctx, query, shardID, database)
+	}
+
+	start := time.Now()
+	if maxStaleness == StalenessStrong {
+		if data, served, err := ds.fallbackRead(ctx, query, shardID, database); served {
+			return data, true, err
+		}
+		data, err := ds.executeQueryContext(ctx, query, shardID, database)
+		ds.observeRead(shardID, start, err)
+		if err != nil && ctx.Err() == nil {
+			// The failure may just have put the shard in read-only fallback
+			if fallbackData, served, fallbackErr := ds.fallbackRead(ctx, query, shardID, database); served {
+				return fallbackData, true, fallbackErr
+			}
+		}
+		return data, false, err
+	}
+
+	db, target, err := ds.replicaConnection(shardID, database, maxStaleness)
+	if err != nil {
+		log.Printf("Warning: Replica of shard %s unavailable, reading from primary: %v", shardID, err)
+	}
+	if db == nil {
+		data, err := ds.executeQueryContext(ctx, query, shardID, database)
+		ds.observeRead(shardID, start, err)
+		if err == nil || !isUnavailable(err) || ctx.Err() != nil {
+			return data, false, err
+		}
+
+		// The primary is down; any replica, in any region, may still answer
+		fallback, name, fallbackErr := ds.fallbackReplica(shardID, database, "")
+		if fallbackErr != nil {
+			return data, false, err
+		}
+		log.Printf("Warning: Primary of shard %s unavailable, reading from replica %s: %v", shardID, name, err)
+		db, target, start = fallback, name, time.Now()
+	}
+
+	data, err := ds.queryReplica(ctx, db, shardID, query)
+	ds.observeRead(target, start, err)
+	if err != nil && isUnavailable(err) && ctx.Err() == nil {
+		// The replica is down; fall back to the primary, then to another replica
+		log.Printf("Warning: Replica %s unavailable, reading from the primary of shard %s: %v", target, shardID, err)
+		start = time.Now()
+		primaryData, primaryErr := ds.executeQueryContext(ctx, query, shardID, database)
+		ds.observeRead(shardID, start, primaryErr)
+		if primaryErr == nil || !isUnavailable(primaryErr) {
+			return primaryData, false, primaryErr
+		}
+		if fallback, name, fallbackErr := ds.fallbackReplica(shardID, database, target); fallbackErr == nil {
+			start = time.Now()
+			data, err = ds.queryReplica(ctx, fallback, shardID, query)
+			ds.observeRead(name, start, err)
+		}
+	}
+	return data, true, err
+}
+
+// queryReplica runs a read on a replica's pool of a shard
+func (ds *DataStore) queryReplica(ctx context.Context, db *sql.DB, shardID, query string) ([]map[string]interface{}, error) {
+	start := time.Now()
+	rows, err := db.QueryContext(ctx, clientStatement(query))
+	if err != nil {
+		ds.countRead(shardID, start, nil, err)
+		return nil, fmt.Errorf("failed to execute query on replica of shard %s: %w", shardID, err)
+	}
+	defer rows.Close()
+
+	data, err := scanRows(rows, ds.useTypedValues(), budgetFrom(ctx))
+	ds.countRead(shardID, start, data, err)
+	return data, err
+}
+
+// observeRead reports a read's latency and outcome to the balancer. Reads
+// cut short by the caller or the result limit say nothing about the target.
+func (ds *DataStore) observeRead(target string, start time.Time, err error) {
+	var tooLarge *ResultTooLargeError
+	if errors.Is(err, context.Canceled) || errors.As(err, &tooLarge) {
+		return
+	}
+	ds.readBalancer().Observe(target, time.Since(start), err)
+}
+
+// ExecuteReadOnShards executes a read query on several shards concurrently,
+// each honoring the staleness bound
+func (ds *DataStore) ExecuteReadOnShards(query string, shardIDs []string, database string, maxStaleness time.Duration) ([]map[string]interface{}, error) {
+	return ds.executeOnShards(shardIDs, func(shardID string) ([]map[string]interface{}, error) {
+		data, _, err := ds.ExecuteRead(query, shardID, database, maxStaleness)
+		return data, err
+	})
+}
+
+// ExecuteReadOnShardsUntil executes a read query on several shards
+// concurrently. With a positive stopAfter it returns as soon as at least
+// stopAfter rows have arrived, cancelling the queries still running; rows are
+// returned in arrival order, so that only suits queries where any stopAfter
+// rows are a valid answer. limit caps the rows and bytes of all shards
+// together: once exceeded the outstanding queries are cancelled and the rows
+// collected so far are returned with a *ResultTooLargeError. A positive
+// maxParallel bounds the shards queried at once, starting the next as each
+// finishes. Cancelling ctx abandons every query; if its deadline passes, a
+// *DeadlineError lists the shards that answered in time.
+func (ds *DataStore) ExecuteReadOnShardsUntil(ctx context.Context, query string, shardIDs []string, database string, maxStaleness time.Duration, stopAfter int, limit ResultLimit, maxParallel int) ([]map[string]interface{}, error) {
+	ctx, cancel := context.WithCancel(withResultLimit(ctx, limit))
+	defer cancel()
+
+	if maxStaleness == StalenessSnapshot {
+		var err error
+		if ctx, err = ds.withSnapshotCut(ctx, shardIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	type shardResult struct {
+		shardID string
+		data    []map[string]interface{}
+		err     error
+	}
+
+	// Buffered so shards finishing after an early return don't block
+	resultChan := make(chan shardResult, len(shardIDs))
+	var slots chan struct{}
+	if maxParallel > 0 && maxParallel < len(shardIDs) {
+		slots = make(chan struct{}, maxParallel)
+	}
+	for _, shardID := range shardIDs {
+		go func(sID string) {
+			if slots != nil {
+				select {
+				case slots <- struct{}{}:
+					defer func() { <-slots }()
+				case <-ctx.Done():
+					resultChan <- shardResult{shardID: sID, err: ctx.Err()}
+					return
+				}
+			}
+			data, _, err := ds.executeReadContext(ctx, query, sID, database, maxStaleness)
+			resultChan <- shardResult{shardID: sID, data: data, err: err}
+		}(shardID)
+	}
+
+	var allResults []map[string]interface{}
+	completed := make(map[string]bool, len(shardIDs))
+	for received := 0; received < len(shardIDs); received++ {
+		result := <-resultChan
+		var tooLarge *ResultTooLargeError
+		if errors.As(result.err, &tooLarge) {
+			allResults = append(allResults, result.data...)
+			return allResults, tooLarge
+		}
+		if result.err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			deadlineErr := &DeadlineError{}
+			for _, shardID := range shardIDs {
+				if completed[shardID] {
+					deadlineErr.Completed = append(deadlineErr.Completed, shardID)
+				} else {
+					deadlineErr.Pending = append(deadlineErr.Pending, shardID)
+				}
+			}
+			return nil, deadlineErr
+		}
+		if result.err != nil {
+			return nil, &ShardError{ShardID: result.shardID, Err: result.err}
+		}
+		completed[result.shardID] = true
+		allResults = append(allResults, result.data...)
+		if stopAfter > 0 && len(allResults) >= stopAfter {
+			if remaining := len(shardIDs) - received - 1; remaining > 0 {
+				log.Printf("Collected %d rows, cancelling %d outstanding shard queries", len(allResults), remaining)
+			}
+			break
+		}
+	}
+	return allResults, nil
+}
+
+// replicaConnection returns a pool for the healthy replica within the
+// staleness bound chosen by the read balancer, and the replica's name, or nil
+// if no replica qualifies
+func (ds *DataStore) replicaConnection(shardID string, database string, maxStaleness time.Duration) (*sql.DB, string, error) {
+	ds.mutex.RLock()
+	best := ds.pickReplica(shardID, maxStaleness)
+	if best == nil {
+		ds.mutex.RUnlock()
+		return nil, "", nil
+	}
+	db, opened := best.db, true
+	if database != "" {
+		db, opened = best.schemaDBs[database]
+	}
+	ds.mutex.RUnlock()
+	if opened {
+		return db, best.name, nil
+	}
+
+	// The replica's pool for the database is opened on first use
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	db, err := ds.replicaDB(shardID, best, database)
+	if err != nil {
+		return nil, "", err
+	}
+	return db, best.name, nil
+}
+
+// pickReplica returns the healthy replica within the staleness bound chosen
+// by the read balancer, or nil if no replica qualifies. Must be called with
+// the mutex held.
+func (ds *DataStore) pickReplica(shardID string, maxStaleness time.Duration) *replica {
+	var eligible []*replica
+	for _, r := range ds.replicas[shardID] {
+		if !r.healthy || (maxStaleness != StalenessUnbounded && r.lag > maxStaleness) {
+			continue
+		}
+		eligible = append(eligible, r)
+	}
+	if len(eligible) == 0 {
+		return nil
+	}
+
+	// Regions narrow the choice to local replicas, or to the local primary
+	eligible = ds.preferLocal(shardID, eligible)
+	if len(eligible) == 0 {
+		return nil
+	}
+	targets := make([]ReadTarget, 0, len(eligible))
+	for _, r := range eligible {
+		targets = append(targets, ReadTarget{Name: r.name, Lag: r.lag})
+	}
+
+	balancer := ds.balancer
+	if balancer == nil {
+		balancer = LeastLagBalancer{}
+	}
+	return eligible[balancer.Pick(targets)]
+}
+
+// replicaDB returns the pool of a replica for a database, opening it on first
+// use. Must be called with the mutex held.
+func (ds *DataStore) replicaDB(shardID string, r *replica, database string) (*sql.DB, error) {
+	if database == "" {
+		return r.db, nil
+	}
+
+	if schemaDB, exists := r.schemaDBs[database]; exists {
+		return schemaDB, nil
+	}
+
+	dsnConfig, err := mysql.ParseDSN(r.dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse replica DSN: %w", err)
+	}
+	dsnConfig.DBName = database
+
+	schemaDB, err := ds.openDB(shardID, dsnConfig.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replica connection to database %s: %w", database, err)
+	}
+	r.schemaDBs[database] = schemaDB
+	return schemaDB, nil
+}
+
+// replicaLagLoop periodically refreshes the lag of every replica
+func (ds *DataStore) replicaLagLoop(stopChan chan struct{}) {
+	ds.mutex.RLock()
+	interval := ds.replicaLagInterval
+	ds.mutex.RUnlock()
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-ticker.C:
+			ds.mutex.RLock()
+			var all []*replica
+			for _, shardReplicas := range ds.replicas {
+				all = append(all, shardReplicas...)
+			}
+			ds.mutex.RUnlock()
+
+			for _, r := range all {
+				status := r.measure()
+				ds.mutex.Lock()
+				r.setStatus(status)
+				ds.mutex.Unlock()
+			}
+			ds.probePrimaries()
+		}
+	}
+}
+
+// refreshLag measures the replica's lag and stores it
+func (r *replica) refreshLag() {
+	r.setStatus(r.measure())
+}
+
+// setStatus stores a replica's measured state. Callers must hold ds.mutex
+// once the replica is registered.
+func (r *replica) setStatus(status metrics.ReplicaStatus) {
+	r.status = status
+	r.lag = time.Duration(status.LagSeconds) * time.Second
+	r.healthy = status.Healthy
+}
+
+// measure reads the replica's state from SHOW REPLICA STATUS. A replica is
+// healthy while it reports its replication delay; one whose SQL thread is
+// not running reports NULL.
+func (r *replica) measure() metrics.ReplicaStatus {
+	status := metrics.ReplicaStatus{
+		Name:      r.name,
+		Address:   dsnAddress(r.dsn),
+		CheckedAt: time.Now(),
+	}
+
+	rows, err := r.db.Query("SHOW REPLICA STATUS")
+	if err != nil {
+		status.LastError = err.Error()
+		return status
+	}
+	defer rows.Close()
+
+	data, err := scanRows(rows, false, nil)
+	if err != nil {
+		status.LastError = err.Error()
+		return status
+	}
+	if len(data) == 0 {
+		status.LastError = "replication is not configured"
+		return status
+	}
+
+	// Servers before 8.0.22 use the older column names
+	field := func(names ...string) string {
+		for _, name := range names {
+			if value, exists := data[0][name]; exists && value != nil {
+				return fmt.Sprintf("%v", value)
+			}
+		}
+		return ""
+	}
+	status.IORunning = field("Replica_IO_Running", "Slave_IO_Running") == "Yes"
+	status.SQLRunning = field("Replica_SQL_Running", "Slave_SQL_Running") == "Yes"
+	if host := field("Source_Host", "Master_Host"); host != "" {
+		status.Source = net.JoinHostPort(host, field("Source_Port", "Master_Port"))
+	}
+	status.AutoPosition = field("Auto_Position") == "1"
+	status.RetrievedGTIDSet = field("Retrieved_Gtid_Set")
+	status.ExecutedGTIDSet = field("Executed_Gtid_Set")
+	status.LastError = field("Last_IO_Error")
+	if status.LastError == "" {
+		status.LastError = field("Last_SQL_Error")
+	}
+
+	if seconds, err := strconv.ParseInt(field("Seconds_Behind_Source", "Seconds_Behind_Master"), 10, 64); err == nil {
+		status.LagSeconds = seconds
+		status.Healthy = true
+	}
+	return status
+}
+
+// ReplicaStatuses returns the replication state of a shard's replicas from
+// their most recent check
+func (ds *DataStore) ReplicaStatuses(shardID string) []metrics.ReplicaStatus {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+
+	var statuses []metrics.ReplicaStatus
+	for _, r := range ds.replicas[shardID] {
+		status := r.status
+		status.Region = r.region
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// dsnAddress returns the host:port of a DSN, or "" if it can't be parsed
+func dsnAddress(dsn string) string {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return ""
+	}
+	return cfg.Addr
+}
+
+// closeReplicas closes all replica pools. Callers must hold ds.mutex.
+func (ds *DataStore) closeReplicas() {
+	if ds.replicaLagStop != nil {
+		close(ds.replicaLagStop)
+		ds.replicaLagStop = nil
+	}
+	for _, shardReplicas := range ds.replicas {
+		for _, r := range shardReplicas {
+			for _, schemaDB := range r.schemaDBs {
+				schemaDB.Close()
+			}
+			r.db.Close()
+		}
+	}
+	ds.replicas = nil
+}
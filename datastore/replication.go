@@ -0,0 +1,215 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// ReplicationConfig controls how replication between shards and their
+// replicas is set up. Replicas follow their source by GTID auto-positioning,
+// so a replica can be pointed at a newly promoted primary without knowing
+// binary log coordinates.
+type ReplicationConfig struct {
+	// User and Password are the account replicas connect to their source as
+	User     string
+	Password string
+	// SourceAddresses maps a server's address in its DSN to the address its
+	// replicas reach it at, when they differ (e.g. a published Docker port)
+	SourceAddresses map[string]string
+	// ApplyTimeout bounds how long a replica being promoted may take to
+	// apply the transactions it already received
+	ApplyTimeout time.Duration
+}
+
+// gtidModes are the gtid_mode values in the order a server must step through
+// them to reach ON
+var gtidModes = []string{"OFF", "OFF_PERMISSIVE", "ON_PERMISSIVE", "ON"}
+
+// SetReplicationConfig sets how replication is set up by ConfigureReplica
+// and PromoteReplica
+func (ds *DataStore) SetReplicationConfig(replicationConfig ReplicationConfig) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	ds.replicationConfig = replicationConfig
+}
+
+// ConfigureReplica switches a shard's primary and one of its replicas,
+// registered with dsn, to GTID mode and points the replica at the primary.
+// The replica's DSN user needs REPLICATION_SLAVE_ADMIN and
+// SYSTEM_VARIABLES_ADMIN, as does the primary's for the mode change.
+func (ds *DataStore) ConfigureReplica(shardID, dsn string) error {
+	ds.mutex.RLock()
+	primary, primaryDSN := ds.connections[shardID], ds.dsns[shardID]
+	var target *replica
+	for _, r := range ds.replicas[shardID] {
+		if r.dsn == dsn {
+			target = r
+		}
+	}
+	replicationConfig := ds.replicationConfig
+	ds.mutex.RUnlock()
+
+	if primary == nil {
+		return fmt.Errorf("shard %s not found", shardID)
+	}
+	if target == nil {
+		return fmt.Errorf("shard %s has no such replica", shardID)
+	}
+
+	if err := ensureGTIDMode(primary); err != nil {
+		return fmt.Errorf("failed to enable GTID mode on shard %s: %w", shardID, err)
+	}
+	if err := ensureGTIDMode(target.db); err != nil {
+		return fmt.Errorf("failed to enable GTID mode on replica %s: %w", target.name, err)
+	}
+	if err := followSource(target.db, primaryDSN, replicationConfig); err != nil {
+		return fmt.Errorf("failed to point replica %s at shard %s: %w", target.name, shardID, err)
+	}
+	log.Printf("🔁 Replica %s of shard %s follows %s", target.name, shardID, sourceAddress(primaryDSN, replicationConfig))
+	return nil
+}
+
+// PromoteReplica makes a shard's replica, by name, the shard's primary: it
+// applies the transactions the replica already received, stops replication
+// on it and makes it writable, then serves the shard from it and points the
+// shard's other replicas at it. It returns the new primary's DSN. The old
+// primary is forgotten and is not written to again.
+func (ds *DataStore) PromoteReplica(shardID, name string) (string, error) {
+	ds.mutex.RLock()
+	var promoted *replica
+	var retrieved string
+	for _, r := range ds.replicas[shardID] {
+		if r.name == name {
+			promoted, retrieved = r, r.status.RetrievedGTIDSet
+		}
+	}
+	replicationConfig := ds.replicationConfig
+	ds.mutex.RUnlock()
+	if promoted == nil {
+		return "", fmt.Errorf("shard %s has no replica %s", shardID, name)
+	}
+
+	if retrieved != "" {
+		if err := waitForGTIDs(promoted.db, retrieved, replicationConfig.ApplyTimeout); err != nil {
+			log.Printf("Warning: Promoting replica %s before it applied everything it received: %v", name, err)
+		}
+	}
+	for _, statement := range []string{"STOP REPLICA", "RESET REPLICA ALL", "SET GLOBAL super_read_only = OFF", "SET GLOBAL read_only = OFF"} {
+		if _, err := promoted.db.Exec(statement); err != nil {
+			return "", fmt.Errorf("failed to run %q on replica %s: %w", statement, name, err)
+		}
+	}
+
+	ds.mutex.Lock()
+	if old, exists := ds.connections[shardID]; exists {
+		old.Close()
+	}
+	for key, schemaDB := range ds.schemaConnections {
+		if strings.HasPrefix(key, shardID+"/") {
+			schemaDB.Close()
+			delete(ds.schemaConnections, key)
+		}
+	}
+	for _, schemaDB := range promoted.schemaDBs {
+		schemaDB.Close()
+	}
+	ds.connections[shardID] = promoted.db
+	ds.dsns[shardID] = promoted.dsn
+
+	var remaining []*replica
+	for _, r := range ds.replicas[shardID] {
+		if r != promoted {
+			remaining = append(remaining, r)
+		}
+	}
+	ds.replicas[shardID] = remaining
+	ds.mutex.Unlock()
+
+	log.Printf("👑 Promoted replica %s to primary of shard %s", name, shardID)
+	for _, r := range remaining {
+		if err := followSource(r.db, promoted.dsn, replicationConfig); err != nil {
+			log.Printf("Warning: Failed to point replica %s at the new primary of shard %s: %v", r.name, shardID, err)
+		}
+	}
+	return promoted.dsn, nil
+}
+
+// ensureGTIDMode turns on GTID mode, stepping gtid_mode through each value
+// in turn as MySQL requires. The settings are persisted across restarts.
+func ensureGTIDMode(db *sql.DB) error {
+	var mode string
+	if err := db.QueryRow("SELECT @@GLOBAL.gtid_mode").Scan(&mode); err != nil {
+		return err
+	}
+	if mode == "ON" {
+		return nil
+	}
+
+	if _, err := db.Exec("SET PERSIST enforce_gtid_consistency = ON"); err != nil {
+		return err
+	}
+	stepping := false
+	for _, next := range gtidModes {
+		if stepping {
+			if _, err := db.Exec("SET PERSIST gtid_mode = " + next); err != nil {
+				return fmt.Errorf("switching gtid_mode to %s: %w", next, err)
+			}
+		}
+		stepping = stepping || next == strings.ToUpper(mode)
+	}
+	return nil
+}
+
+// followSource points a replica at a source by GTID auto-positioning and
+// starts replication
+func followSource(db *sql.DB, sourceDSN string, replicationConfig ReplicationConfig) error {
+	host, port, err := net.SplitHostPort(sourceAddress(sourceDSN, replicationConfig))
+	if err != nil {
+		return fmt.Errorf("invalid source address: %w", err)
+	}
+
+	changeSource := fmt.Sprintf("CHANGE REPLICATION SOURCE TO SOURCE_HOST = %s, SOURCE_PORT = %s, SOURCE_USER = %s, SOURCE_PASSWORD = %s, SOURCE_AUTO_POSITION = 1, GET_SOURCE_PUBLIC_KEY = 1",
+		quoteString(host), port, quoteString(replicationConfig.User), quoteString(replicationConfig.Password))
+	for _, statement := range []string{"STOP REPLICA", changeSource, "START REPLICA"} {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sourceAddress returns the address replicas reach a server at
+func sourceAddress(dsn string, replicationConfig ReplicationConfig) string {
+	address := dsnAddress(dsn)
+	if mapped, exists := replicationConfig.SourceAddresses[address]; exists {
+		return mapped
+	}
+	return address
+}
+
+// waitForGTIDs waits until a server has applied a GTID set
+func waitForGTIDs(db *sql.DB, gtids string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+5*time.Second)
+	defer cancel()
+
+	var timedOut int
+	if err := db.QueryRowContext(ctx, "SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)", gtids, int(timeout.Seconds())).Scan(&timedOut); err != nil {
+		return err
+	}
+	if timedOut != 0 {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return nil
+}
+
+// quoteString quotes a value as an SQL string literal, for statements that
+// can't take placeholders
+func quoteString(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", "''").Replace(value) + "'"
+}
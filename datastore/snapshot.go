@@ -0,0 +1,147 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// snapshotCutKey is the context key of a snapshot read's cut
+type snapshotCutKey struct{}
+
+// snapshotCut maps each shard to the GTID set its primary had executed when
+// a snapshot read began
+type snapshotCut map[string]string
+
+// SetSnapshotWaitTimeout sets how long a snapshot read waits for a replica to
+// reach the cut before reading from the primary instead
+func (ds *DataStore) SetSnapshotWaitTimeout(timeout time.Duration) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	ds.snapshotWaitTimeout = timeout
+}
+
+// withSnapshotCut captures the cut of a snapshot read across shards and
+// attaches it to ctx, reading every primary's executed GTIDs at once so the
+// markers are as close together as the shards allow
+func (ds *DataStore) withSnapshotCut(ctx context.Context, shardIDs []string) (context.Context, error) {
+	cut := make(snapshotCut, len(shardIDs))
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	errs := make([]error, len(shardIDs))
+	for i, shardID := range shardIDs {
+		wg.Add(1)
+		go func(i int, shardID string) {
+			defer wg.Done()
+			gtids, err := ds.executedGTIDs(ctx, shardID)
+			if err != nil {
+				errs[i] = &ShardError{ShardID: shardID, Err: fmt.Errorf("failed to capture snapshot marker: %w", err)}
+				return
+			}
+			mutex.Lock()
+			cut[shardID] = gtids
+			mutex.Unlock()
+		}(i, shardID)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return context.WithValue(ctx, snapshotCutKey{}, cut), nil
+}
+
+// executedGTIDs returns the GTID set a shard's primary has executed
+func (ds *DataStore) executedGTIDs(ctx context.Context, shardID string) (string, error) {
+	db, err := ds.getConnection(shardID, "")
+	if err != nil {
+		return "", err
+	}
+	var executed string
+	if err := db.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_executed").Scan(&executed); err != nil {
+		return "", err
+	}
+	return executed, nil
+}
+
+// snapshotRead serves a read at the shard's marker in the cut attached to
+// ctx, capturing one for the shard alone when there is none. A replica serves
+// it once WAIT_FOR_EXECUTED_GTID_SET reports it applied the marker; the
+// primary does when the shard has no replica, doesn't use GTIDs, or the
+// replica doesn't catch up in time. The read reflects every write the shard
+// committed before the cut, and may reflect a few committed since.
+func (ds *DataStore) snapshotRead(ctx context.Context, query, shardID, database string) ([]map[string]interface{}, bool, error) {
+	cut, _ := ctx.Value(snapshotCutKey{}).(snapshotCut)
+	gtids, captured := cut[shardID]
+	if !captured {
+		withCut, err := ds.withSnapshotCut(ctx, []string{shardID})
+		if err != nil {
+			return nil, false, err
+		}
+		gtids = withCut.Value(snapshotCutKey{}).(snapshotCut)[shardID]
+	}
+
+	start := time.Now()
+	if gtids != "" {
+		db, target, err := ds.replicaConnection(shardID, database, StalenessUnbounded)
+		if err != nil {
+			log.Printf("Warning: Replica of shard %s unavailable, reading snapshot from primary: %v", shardID, err)
+		}
+		if db != nil {
+			data, err := ds.queryAtGTIDs(ctx, db, shardID, query, gtids)
+			if err == nil {
+				ds.observeRead(target, start, nil)
+				return data, true, nil
+			}
+			if ctx.Err() != nil {
+				return nil, false, err
+			}
+			log.Printf("Warning: Replica %s can't serve snapshot read of shard %s, reading from primary: %v", target, shardID, err)
+			start = time.Now()
+		}
+	}
+
+	// The primary has executed at least the cut
+	data, err := ds.executeQueryContext(ctx, query, shardID, database)
+	ds.observeRead(shardID, start, err)
+	return data, false, err
+}
+
+// queryAtGTIDs runs a read on a replica once it has applied a GTID set, on
+// the connection the wait ran on so the read starts right after it
+func (ds *DataStore) queryAtGTIDs(ctx context.Context, db *sql.DB, shardID, query, gtids string) ([]map[string]interface{}, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ds.mutex.RLock()
+	timeout := ds.snapshotWaitTimeout
+	ds.mutex.RUnlock()
+
+	var timedOut int
+	if err := conn.QueryRowContext(ctx, "SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)", gtids, timeout.Seconds()).Scan(&timedOut); err != nil {
+		return nil, fmt.Errorf("failed to wait for snapshot marker: %w", err)
+	}
+	if timedOut != 0 {
+		return nil, fmt.Errorf("didn't reach the snapshot marker within %s", timeout)
+	}
+
+	start := time.Now()
+	rows, err := conn.QueryContext(ctx, clientStatement(query))
+	if err != nil {
+		ds.countRead(shardID, start, nil, err)
+		return nil, fmt.Errorf("failed to execute query on replica of shard %s: %w", shardID, err)
+	}
+	defer rows.Close()
+
+	data, err := scanRows(rows, ds.useTypedValues(), budgetFrom(ctx))
+	ds.countRead(shardID, start, data, err)
+	return data, err
+}
@@ -0,0 +1,189 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"sql-horizontal-autoscaler/metrics"
+)
+
+// ShardCounters counts the statements run on a shard through this process
+type ShardCounters struct {
+	Reads  int64 `json:"reads"`
+	Writes int64 `json:"writes"`
+	// Errors counts reads and writes that failed, other than those cut short
+	// by the caller
+	Errors int64 `json:"errors"`
+	// ErrorClasses breaks Errors down by cause
+	ErrorClasses metrics.QueryErrors `json:"error_classes"`
+	// BytesRead estimates the size of the rows returned
+	BytesRead int64 `json:"bytes_read"`
+	// BytesWritten is the size of the write statements sent
+	BytesWritten int64 `json:"bytes_written"`
+	// TimeMs is the time the reads and writes took altogether
+	TimeMs float64 `json:"time_ms"`
+}
+
+// countRead adds a read started at start and the rows it returned to a
+// shard's counters
+func (ds *DataStore) countRead(shardID string, start time.Time, data []map[string]interface{}, err error) {
+	var size int64
+	for _, row := range data {
+		size += rowSize(row)
+	}
+
+	ds.countersMutex.Lock()
+	defer ds.countersMutex.Unlock()
+
+	counters := ds.shardCounters(shardID)
+	counters.Reads++
+	counters.BytesRead += size
+	counters.TimeMs += float64(time.Since(start).Microseconds()) / 1000
+	if countsAsError(err) {
+		counters.Errors++
+		counters.ErrorClasses.Add(classifyError(err))
+	}
+}
+
+// countWrite adds a write statement started at start to a shard's counters
+func (ds *DataStore) countWrite(shardID string, start time.Time, query string, err error) {
+	ds.countersMutex.Lock()
+	defer ds.countersMutex.Unlock()
+
+	counters := ds.shardCounters(shardID)
+	counters.Writes++
+	counters.BytesWritten += int64(len(query))
+	counters.TimeMs += float64(time.Since(start).Microseconds()) / 1000
+	if countsAsError(err) {
+		counters.Errors++
+		counters.ErrorClasses.Add(classifyError(err))
+	}
+}
+
+// shardCounters returns a shard's counters, creating them on first use. Must
+// be called with countersMutex held.
+func (ds *DataStore) shardCounters(shardID string) *ShardCounters {
+	counters, exists := ds.counters[shardID]
+	if !exists {
+		counters = &ShardCounters{}
+		ds.counters[shardID] = counters
+	}
+	return counters
+}
+
+// countsAsError reports whether a failure says something about the shard;
+// reads cancelled by the caller or stopped at the result limit don't
+func countsAsError(err error) bool {
+	var tooLarge *ResultTooLargeError
+	return err != nil && !errors.Is(err, context.Canceled) && !errors.As(err, &tooLarge)
+}
+
+// MySQL server error numbers classified in the shard counters
+var mysqlErrorClasses = map[uint16]string{
+	1205: metrics.ErrorTimeout,            // lock wait timeout
+	1317: metrics.ErrorTimeout,            // query interrupted
+	3024: metrics.ErrorTimeout,            // max_execution_time exceeded
+	1213: metrics.ErrorDeadlock,           // deadlock found
+	1064: metrics.ErrorSyntax,             // syntax error
+	1149: metrics.ErrorSyntax,             // syntax error
+	1044: metrics.ErrorAccessDenied,       // access denied to database
+	1045: metrics.ErrorAccessDenied,       // access denied for user
+	1142: metrics.ErrorAccessDenied,       // command denied on table
+	1143: metrics.ErrorAccessDenied,       // command denied on column
+	1227: metrics.ErrorAccessDenied,       // privilege required
+	1021: metrics.ErrorDiskFull,           // disk full
+	1114: metrics.ErrorDiskFull,           // table is full
+	1040: metrics.ErrorTooManyConnections, // max_connections reached
+	1203: metrics.ErrorTooManyConnections, // max_user_connections reached
+}
+
+// classifyError returns the class of a failed statement's error
+func classifyError(err error) string {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		if class, exists := mysqlErrorClasses[mysqlErr.Number]; exists {
+			return class
+		}
+		// Writes failing with ENOSPC surface as storage engine or file errors
+		if strings.Contains(mysqlErr.Message, "No space left on device") || strings.Contains(mysqlErr.Message, "error 28 ") {
+			return metrics.ErrorDiskFull
+		}
+		return metrics.ErrorOther
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return metrics.ErrorTimeout
+	}
+	return metrics.ErrorOther
+}
+
+// ShardCounters returns a copy of every shard's counters since the process
+// started
+func (ds *DataStore) ShardCounters() map[string]ShardCounters {
+	ds.countersMutex.Lock()
+	defer ds.countersMutex.Unlock()
+
+	snapshot := make(map[string]ShardCounters, len(ds.counters))
+	for shardID, counters := range ds.counters {
+		snapshot[shardID] = *counters
+	}
+	return snapshot
+}
+
+// shardStatsSchema creates the table SaveShardCounters appends to
+const shardStatsSchema = `CREATE TABLE IF NOT EXISTS %s (
+	router VARCHAR(255) NOT NULL,
+	shard_id VARCHAR(255) NOT NULL,
+	sampled_at DATETIME(3) NOT NULL,
+	interval_seconds DOUBLE NOT NULL,
+	` + "`reads`" + ` BIGINT NOT NULL,
+	` + "`writes`" + ` BIGINT NOT NULL,
+	` + "`errors`" + ` BIGINT NOT NULL,
+	bytes_read BIGINT NOT NULL,
+	bytes_written BIGINT NOT NULL,
+	time_ms DOUBLE NOT NULL,
+	PRIMARY KEY (router, shard_id, sampled_at)
+)`
+
+// SaveShardCounters appends a router's per-shard counters for an interval to
+// a stats table on a shard, creating the table on first use. The statements
+// aren't counted themselves.
+func (ds *DataStore) SaveShardCounters(shardID, table, router string, sampledAt time.Time, interval time.Duration, counters map[string]ShardCounters) error {
+	if len(counters) == 0 {
+		return nil
+	}
+	db, err := ds.getConnection(shardID, "")
+	if err != nil {
+		return err
+	}
+	quoted := "`" + strings.ReplaceAll(table, "`", "``") + "`"
+	if _, err := db.Exec(fmt.Sprintf(shardStatsSchema, quoted)); err != nil {
+		return fmt.Errorf("failed to create stats table on shard %s: %w", shardID, err)
+	}
+
+	shardIDs := make([]string, 0, len(counters))
+	for counted := range counters {
+		shardIDs = append(shardIDs, counted)
+	}
+	sort.Strings(shardIDs)
+
+	var rows []string
+	var args []interface{}
+	for _, counted := range shardIDs {
+		c := counters[counted]
+		rows = append(rows, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, router, counted, sampledAt.UTC(), interval.Seconds(), c.Reads, c.Writes, c.Errors, c.BytesRead, c.BytesWritten, c.TimeMs)
+	}
+	statement := fmt.Sprintf("INSERT INTO %s (router, shard_id, sampled_at, interval_seconds, `reads`, `writes`, `errors`, bytes_read, bytes_written, time_ms) VALUES %s",
+		quoted, strings.Join(rows, ", "))
+	if _, err := db.Exec(statement, args...); err != nil {
+		return fmt.Errorf("failed to save shard stats on shard %s: %w", shardID, err)
+	}
+	return nil
+}
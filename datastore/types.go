@@ -0,0 +1,56 @@
+package datastore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// SetLegacyStringValues makes query results return every non-NULL column as a
+// string, as older versions did, instead of decoding by column type
+func (ds *DataStore) SetLegacyStringValues(legacy bool) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	ds.legacyStringValues = legacy
+}
+
+// useTypedValues reports whether results are decoded using column types
+func (ds *DataStore) useTypedValues() bool {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+
+	return !ds.legacyStringValues
+}
+
+// decodeValue converts a raw column value into a Go type matching the column's
+// database type, so integers and decimals are emitted as JSON numbers
+func decodeValue(val interface{}, columnType *sql.ColumnType) interface{} {
+	b, ok := val.([]byte)
+	if !ok {
+		return val
+	}
+	s := string(b)
+
+	switch strings.TrimPrefix(columnType.DatabaseTypeName(), "UNSIGNED ") {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "YEAR":
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+		if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return n
+		}
+	case "DECIMAL":
+		// json.Number keeps the exact decimal digits instead of rounding through float64
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return json.Number(s)
+		}
+	case "FLOAT", "DOUBLE":
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+
+	return s
+}
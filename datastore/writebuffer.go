@@ -0,0 +1,296 @@
+package datastore
+
+import (
+	"bufio"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BufferedWrite is a write journaled for a shard that couldn't be reached,
+// to be replayed once it is back
+type BufferedWrite struct {
+	Seq      uint64    `json:"seq"`
+	ShardID  string    `json:"shard_id,omitempty"`
+	Database string    `json:"database,omitempty"`
+	Query    string    `json:"query,omitempty"`
+	QueuedAt time.Time `json:"queued_at,omitempty"`
+	// Table, StatementType, RequestID and Actor describe the write for the
+	// audit log when it is replayed
+	Table         string `json:"table,omitempty"`
+	StatementType string `json:"statement_type,omitempty"`
+	RequestID     string `json:"request_id,omitempty"`
+	Actor         string `json:"actor,omitempty"`
+	// Done marks the record of a write that was replayed or discarded
+	Done bool `json:"done,omitempty"`
+}
+
+// WriteBufferLimits bound the writes buffered for one shard
+type WriteBufferLimits struct {
+	MaxWrites int
+	MaxAge    time.Duration
+}
+
+// WriteBufferError rejects a write to an unreachable shard whose buffer is
+// full or has been waiting too long for the shard to come back
+type WriteBufferError struct {
+	ShardID  string
+	Buffered int
+	Oldest   time.Time
+	Reason   string
+}
+
+func (e *WriteBufferError) Error() string {
+	return fmt.Sprintf("shard %s is unreachable and its write buffer %s (%d writes, oldest from %s)",
+		e.ShardID, e.Reason, e.Buffered, e.Oldest.Format("15:04:05 MST"))
+}
+
+// WriteJournal is an append-only log of the writes buffered for unreachable
+// shards, kept in order per shard until they are replayed
+type WriteJournal struct {
+	path    string
+	file    *os.File
+	limits  WriteBufferLimits
+	pending map[string][]BufferedWrite
+	seq     uint64
+	mutex   sync.Mutex
+}
+
+// OpenWriteJournal opens the write journal, keeping the writes not yet
+// replayed
+func OpenWriteJournal(path string, limits WriteBufferLimits) (*WriteJournal, error) {
+	var writes []BufferedWrite
+	var seq uint64
+	if file, err := os.Open(path); err == nil {
+		done := make(map[uint64]bool)
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+		for scanner.Scan() {
+			var write BufferedWrite
+			if err := json.Unmarshal(scanner.Bytes(), &write); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("corrupt write journal %s: %w", path, err)
+			}
+			seq = max(seq, write.Seq)
+			if write.Done {
+				done[write.Seq] = true
+			} else {
+				writes = append(writes, write)
+			}
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read write journal %s: %w", path, err)
+		}
+		kept := writes[:0]
+		for _, write := range writes {
+			if !done[write.Seq] {
+				kept = append(kept, write)
+			}
+		}
+		writes = kept
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open write journal %s: %w", path, err)
+	}
+
+	// Rewrite the journal with only the writes still to replay
+	j := &WriteJournal{path: path, limits: limits, pending: make(map[string][]BufferedWrite), seq: seq}
+	for _, write := range writes {
+		j.pending[write.ShardID] = append(j.pending[write.ShardID], write)
+	}
+	if err := j.compact(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Compact rewrites the journal with only the writes still to replay,
+// dropping the records of those replayed or discarded since it was opened
+func (j *WriteJournal) Compact() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.compact()
+}
+
+// compact rewrites the journal from the pending writes and appends to the
+// rewritten file from then on. Must be called with the mutex held.
+func (j *WriteJournal) compact() error {
+	var writes []BufferedWrite
+	for _, shardWrites := range j.pending {
+		writes = append(writes, shardWrites...)
+	}
+	sort.Slice(writes, func(a, b int) bool { return writes[a].Seq < writes[b].Seq })
+
+	tmpPath := j.path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to compact write journal %s: %w", j.path, err)
+	}
+	old := j.file
+	j.file = file
+	for _, write := range writes {
+		if err := j.append(write); err != nil {
+			file.Close()
+			j.file = old
+			return err
+		}
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		file.Close()
+		j.file = old
+		return fmt.Errorf("failed to compact write journal %s: %w", j.path, err)
+	}
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// append writes a record and syncs it to disk
+func (j *WriteJournal) append(write BufferedWrite) error {
+	line, err := json.Marshal(write)
+	if err != nil {
+		return fmt.Errorf("failed to encode buffered write: %w", err)
+	}
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write buffered write: %w", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync write journal: %w", err)
+	}
+	return nil
+}
+
+// Buffer durably journals a write for its shard, failing with a
+// *WriteBufferError once the shard's buffer is full or its oldest write
+// passed the maximum age. With onlyIfPending, the write is only buffered
+// behind writes already waiting for the shard, reporting whether it was.
+func (j *WriteJournal) Buffer(write BufferedWrite, onlyIfPending bool) (bool, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	pending := j.pending[write.ShardID]
+	if len(pending) == 0 {
+		if onlyIfPending {
+			return false, nil
+		}
+	} else {
+		oldest := pending[0].QueuedAt
+		switch {
+		case j.limits.MaxWrites > 0 && len(pending) >= j.limits.MaxWrites:
+			return false, &WriteBufferError{ShardID: write.ShardID, Buffered: len(pending), Oldest: oldest, Reason: "is full"}
+		case j.limits.MaxAge > 0 && time.Since(oldest) > j.limits.MaxAge:
+			return false, &WriteBufferError{ShardID: write.ShardID, Buffered: len(pending), Oldest: oldest, Reason: "is older than its maximum age"}
+		}
+	}
+
+	j.seq++
+	write.Seq = j.seq
+	write.QueuedAt = time.Now().UTC()
+	if err := j.append(write); err != nil {
+		return false, err
+	}
+	j.pending[write.ShardID] = append(pending, write)
+	return true, nil
+}
+
+// HasPending reports whether writes are waiting for any of the shards
+func (j *WriteJournal) HasPending(shardIDs ...string) bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	for _, shardID := range shardIDs {
+		if len(j.pending[shardID]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the oldest write waiting for a shard
+func (j *WriteJournal) Next(shardID string) (BufferedWrite, bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	pending := j.pending[shardID]
+	if len(pending) == 0 {
+		return BufferedWrite{}, false
+	}
+	return pending[0], true
+}
+
+// Done records that a shard's oldest write was replayed or given up on
+func (j *WriteJournal) Done(write BufferedWrite) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	pending := j.pending[write.ShardID]
+	if len(pending) == 0 || pending[0].Seq != write.Seq {
+		return fmt.Errorf("write %d is not the next buffered for shard %s", write.Seq, write.ShardID)
+	}
+	if err := j.append(BufferedWrite{Seq: write.Seq, Done: true}); err != nil {
+		return err
+	}
+	j.dropFirst(write.ShardID)
+	return nil
+}
+
+// Discard drops every write waiting for a shard, returning how many
+func (j *WriteJournal) Discard(shardID string) (int, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	discarded := 0
+	for len(j.pending[shardID]) > 0 {
+		if err := j.append(BufferedWrite{Seq: j.pending[shardID][0].Seq, Done: true}); err != nil {
+			return discarded, err
+		}
+		j.dropFirst(shardID)
+		discarded++
+	}
+	return discarded, nil
+}
+
+// dropFirst removes a shard's oldest pending write. Must be called with the
+// mutex held.
+func (j *WriteJournal) dropFirst(shardID string) {
+	if len(j.pending[shardID]) == 1 {
+		delete(j.pending, shardID)
+		return
+	}
+	j.pending[shardID] = j.pending[shardID][1:]
+}
+
+// Pending returns the writes waiting for each shard, oldest first
+func (j *WriteJournal) Pending() map[string][]BufferedWrite {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	pending := make(map[string][]BufferedWrite, len(j.pending))
+	for shardID, writes := range j.pending {
+		pending[shardID] = append([]BufferedWrite(nil), writes...)
+	}
+	return pending
+}
+
+// Close closes the write journal
+func (j *WriteJournal) Close() error {
+	return j.file.Close()
+}
+
+// WriteNotSent reports whether a write failed before it reached its shard:
+// the shard refused the connection, the connection was known to be bad
+// before the statement was sent, or the shard is in read-only fallback.
+// Such a write can be replayed without applying it twice.
+func WriteNotSent(err error) bool {
+	var unavailable *WriteUnavailableError
+	var opErr *net.OpError
+	return errors.As(err, &unavailable) || errors.Is(err, driver.ErrBadConn) || (errors.As(err, &opErr) && opErr.Op == "dial")
+}
@@ -0,0 +1,164 @@
+package datastore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestWriteJournal(t *testing.T, limits WriteBufferLimits) (*WriteJournal, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "write-buffer.log")
+	journal, err := OpenWriteJournal(path, limits)
+	if err != nil {
+		t.Fatalf("open write journal: %v", err)
+	}
+	t.Cleanup(func() { journal.Close() })
+	return journal, path
+}
+
+// countRecords returns the number of records in the journal file
+func countRecords(t *testing.T, path string) int {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	records := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		records++
+	}
+	return records
+}
+
+func TestWriteJournalReplay(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string // shard of each write, buffered in order
+		replay   []string // shards whose oldest write is replayed, in order
+		reopen   bool
+		wantNext map[string]string
+	}{
+		{
+			name:     "replays in order per shard",
+			writes:   []string{"shard-1", "shard-2", "shard-1"},
+			replay:   []string{"shard-1"},
+			wantNext: map[string]string{"shard-1": "UPDATE t SET n = 2", "shard-2": "UPDATE t SET n = 1"},
+		},
+		{
+			name:     "drained shard has nothing next",
+			writes:   []string{"shard-1", "shard-2"},
+			replay:   []string{"shard-1"},
+			wantNext: map[string]string{"shard-1": "", "shard-2": "UPDATE t SET n = 1"},
+		},
+		{
+			name:     "pending writes survive a restart",
+			writes:   []string{"shard-1", "shard-1", "shard-2"},
+			replay:   []string{"shard-1", "shard-2"},
+			reopen:   true,
+			wantNext: map[string]string{"shard-1": "UPDATE t SET n = 2", "shard-2": ""},
+		},
+	}
+
+	for _, test := range tests {
+		journal, path := openTestWriteJournal(t, WriteBufferLimits{})
+
+		counts := make(map[string]int)
+		for _, shardID := range test.writes {
+			counts[shardID]++
+			write := BufferedWrite{ShardID: shardID, Query: fmt.Sprintf("UPDATE t SET n = %d", counts[shardID])}
+			if _, err := journal.Buffer(write, false); err != nil {
+				t.Fatalf("%s: buffer write for %s: %v", test.name, shardID, err)
+			}
+		}
+		for _, shardID := range test.replay {
+			write, exists := journal.Next(shardID)
+			if !exists {
+				t.Fatalf("%s: no write to replay on %s", test.name, shardID)
+			}
+			if err := journal.Done(write); err != nil {
+				t.Fatalf("%s: done with write %d: %v", test.name, write.Seq, err)
+			}
+		}
+
+		if test.reopen {
+			journal.Close()
+			reopened, err := OpenWriteJournal(path, WriteBufferLimits{})
+			if err != nil {
+				t.Fatalf("%s: reopen write journal: %v", test.name, err)
+			}
+			defer reopened.Close()
+			journal = reopened
+		}
+
+		for shardID, want := range test.wantNext {
+			write, _ := journal.Next(shardID)
+			if write.Query != want {
+				t.Errorf("%s: next write for %s = %q, want %q", test.name, shardID, write.Query, want)
+			}
+		}
+	}
+}
+
+func TestWriteJournalLimits(t *testing.T) {
+	journal, _ := openTestWriteJournal(t, WriteBufferLimits{MaxWrites: 2})
+
+	if queued, err := journal.Buffer(BufferedWrite{ShardID: "shard-1"}, true); queued || err != nil {
+		t.Fatalf("Buffer(onlyIfPending) with nothing waiting = %v, %v, want false, nil", queued, err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := journal.Buffer(BufferedWrite{ShardID: "shard-1"}, false); err != nil {
+			t.Fatalf("buffer write %d: %v", i, err)
+		}
+	}
+
+	_, err := journal.Buffer(BufferedWrite{ShardID: "shard-1"}, false)
+	var bufferErr *WriteBufferError
+	if !errors.As(err, &bufferErr) || bufferErr.Buffered != 2 {
+		t.Fatalf("buffer past the limit: err = %v, want a *WriteBufferError for 2 writes", err)
+	}
+	if !journal.HasPending("shard-2", "shard-1") || journal.HasPending("shard-2") {
+		t.Fatalf("HasPending doesn't match the buffered writes")
+	}
+}
+
+// Replayed writes leave done records behind until the journal is compacted
+func TestWriteJournalCompact(t *testing.T) {
+	journal, path := openTestWriteJournal(t, WriteBufferLimits{})
+
+	for i := 0; i < 3; i++ {
+		if _, err := journal.Buffer(BufferedWrite{ShardID: "shard-1", Query: "DELETE FROM t"}, false); err != nil {
+			t.Fatalf("buffer write %d: %v", i, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		write, _ := journal.Next("shard-1")
+		if err := journal.Done(write); err != nil {
+			t.Fatalf("done with write %d: %v", write.Seq, err)
+		}
+	}
+	if records := countRecords(t, path); records != 5 {
+		t.Fatalf("journal has %d records before compacting, want 5", records)
+	}
+
+	if err := journal.Compact(); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if records := countRecords(t, path); records != 1 {
+		t.Fatalf("journal has %d records after compacting, want 1", records)
+	}
+
+	// Appends go to the compacted journal
+	if _, err := journal.Buffer(BufferedWrite{ShardID: "shard-2"}, false); err != nil {
+		t.Fatalf("buffer write after compacting: %v", err)
+	}
+	if records := countRecords(t, path); records != 2 {
+		t.Fatalf("journal has %d records after appending, want 2", records)
+	}
+}
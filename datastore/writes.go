@@ -0,0 +1,173 @@
+package datastore
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ExecuteWrite executes a client's write statement on a shard's primary and
+// returns the number of affected rows
+func (ds *DataStore) ExecuteWrite(query string, shardID string, database string) (int64, error) {
+	return ds.executeWrite(query, shardID, database, true)
+}
+
+// ExecuteInternalWrite executes a write the autoscaler issues itself, such as
+// a TTL purge or an index build. Unlike ExecuteWrite it isn't tagged as a
+// client statement, so the query reaper leaves it running.
+func (ds *DataStore) ExecuteInternalWrite(query string, shardID string, database string) (int64, error) {
+	return ds.executeWrite(query, shardID, database, false)
+}
+
+// executeWrite executes a write statement on a shard's primary, tagging it
+// as a client statement when client is set
+func (ds *DataStore) executeWrite(query string, shardID string, database string, client bool) (int64, error) {
+	if since, down := ds.PrimaryDown(shardID); down {
+		return 0, &WriteUnavailableError{ShardID: shardID, Since: since}
+	}
+	db, err := ds.getConnection(shardID, database)
+	if err != nil {
+		return 0, err
+	}
+
+	statement := query
+	if client {
+		statement = clientStatement(query)
+	}
+
+	start := time.Now()
+	result, err := db.Exec(statement)
+	ds.countWrite(shardID, start, query, err)
+	ds.observePrimary(shardID, err)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute query on shard %s: %w", shardID, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read affected rows on shard %s: %w", shardID, err)
+	}
+
+	ds.mutex.RLock()
+	policy := ds.regionConfig.WritePolicy
+	ds.mutex.RUnlock()
+	if affected > 0 && (policy == WritePolicyLocal || policy == WritePolicyAll) {
+		ds.awaitReplication(shardID)
+	}
+	return affected, nil
+}
+
+// WriteResult is the outcome of a write on one shard
+type WriteResult struct {
+	ShardID      string
+	RowsAffected int64
+	Err          error
+}
+
+// ExecuteWriteOnShards executes a write statement on the given shards
+// concurrently and returns the outcome per shard, in shard order
+func (ds *DataStore) ExecuteWriteOnShards(query string, shardIDs []string, database string) []WriteResult {
+	results := make([]WriteResult, len(shardIDs))
+	var wg sync.WaitGroup
+
+	for i, shardID := range shardIDs {
+		wg.Add(1)
+		go func(i int, sID string) {
+			defer wg.Done()
+			affected, err := ds.ExecuteWrite(query, sID, database)
+			results[i] = WriteResult{ShardID: sID, RowsAffected: affected, Err: err}
+		}(i, shardID)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// ExecuteTwoPhaseWrite executes a write on every given shard in a best-effort
+// two-phase pattern: the statement is first run in an open transaction on all
+// shards, and only if at least required shards prepared successfully are
+// their transactions committed. Too few prepares roll back all shards and are
+// returned as an error; shards that failed to prepare or commit are reported
+// per shard in the results, since the other shards have already committed.
+func (ds *DataStore) ExecuteTwoPhaseWrite(query string, shardIDs []string, database string, required int) ([]WriteResult, error) {
+	type prepared struct {
+		tx       *sql.Tx
+		affected int64
+		err      error
+	}
+
+	// Phase 1: run the statement on every shard without committing
+	prepares := make([]prepared, len(shardIDs))
+	var wg sync.WaitGroup
+	for i, shardID := range shardIDs {
+		wg.Add(1)
+		go func(i int, sID string) {
+			defer wg.Done()
+			prepares[i].tx, prepares[i].affected, prepares[i].err = ds.prepareWrite(query, sID, database)
+		}(i, shardID)
+	}
+	wg.Wait()
+
+	var prepareErr error
+	preparedCount := 0
+	for i, p := range prepares {
+		if p.err == nil {
+			preparedCount++
+		} else if prepareErr == nil {
+			prepareErr = &ShardError{ShardID: shardIDs[i], Err: p.err}
+		}
+	}
+	if preparedCount < required {
+		for _, p := range prepares {
+			if p.tx != nil {
+				p.tx.Rollback()
+			}
+		}
+		return nil, fmt.Errorf("write rolled back on all shards: %w", prepareErr)
+	}
+
+	// Phase 2: commit everywhere the write prepared
+	results := make([]WriteResult, len(shardIDs))
+	for i, p := range prepares {
+		results[i] = WriteResult{ShardID: shardIDs[i], RowsAffected: p.affected}
+		if p.err != nil {
+			results[i] = WriteResult{ShardID: shardIDs[i], Err: p.err}
+			continue
+		}
+		if err := p.tx.Commit(); err != nil {
+			results[i].RowsAffected = 0
+			results[i].Err = fmt.Errorf("failed to commit on shard %s: %w", shardIDs[i], err)
+		}
+	}
+	return results, nil
+}
+
+// prepareWrite runs a write statement inside a new transaction on a shard and
+// returns the uncommitted transaction
+func (ds *DataStore) prepareWrite(query string, shardID string, database string) (*sql.Tx, int64, error) {
+	db, err := ds.getConnection(shardID, database)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction on shard %s: %w", shardID, err)
+	}
+
+	start := time.Now()
+	result, err := tx.Exec(clientStatement(query))
+	ds.countWrite(shardID, start, query, err)
+	if err != nil {
+		tx.Rollback()
+		return nil, 0, fmt.Errorf("failed to execute query on shard %s: %w", shardID, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return nil, 0, fmt.Errorf("failed to read affected rows on shard %s: %w", shardID, err)
+	}
+	return tx, affected, nil
+}
@@ -0,0 +1,436 @@
+package datastore
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlUnknownXID is returned for XA statements naming no known transaction
+const mysqlUnknownXID = 1397
+
+// XA decisions recorded in the decision log
+const (
+	XACommit = "commit"
+	XADone   = "done"
+)
+
+// XADecision is a commit decision, recorded before the commit phase starts
+type XADecision struct {
+	XID      string    `json:"xid"`
+	Decision string    `json:"decision"`
+	Shards   []string  `json:"shards,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// XALog is an append-only log of commit decisions. A transaction prepared on
+// a shard is committed during recovery if and only if its decision was logged.
+// It also tracks the transactions this process is still running, which
+// recovery must leave alone.
+type XALog struct {
+	file    *os.File
+	pending map[string]XADecision
+	// inFlight holds when each running transaction began
+	inFlight map[string]time.Time
+	// abortAfter is how old a transaction without a decision must be before
+	// recovery rolls it back
+	abortAfter time.Duration
+	mutex      sync.Mutex
+}
+
+// OpenXALog opens the decision log, dropping decisions whose transactions
+// were fully committed. Recovery rolls back transactions without a decision
+// once they are older than abortAfter, so this process never decides to
+// commit one that old.
+func OpenXALog(path string, abortAfter time.Duration) (*XALog, error) {
+	pending := make(map[string]XADecision)
+	if file, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var decision XADecision
+			if err := json.Unmarshal(scanner.Bytes(), &decision); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("corrupt decision log %s: %w", path, err)
+			}
+			if decision.Decision == XADone {
+				delete(pending, decision.XID)
+			} else {
+				pending[decision.XID] = decision
+			}
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read decision log %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open decision log %s: %w", path, err)
+	}
+
+	// Rewrite the log with only the pending decisions
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compact decision log %s: %w", path, err)
+	}
+	l := &XALog{file: file, pending: pending, inFlight: make(map[string]time.Time), abortAfter: abortAfter}
+	for _, decision := range pending {
+		if err := l.append(decision); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to compact decision log %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// append writes a decision and syncs it to disk
+func (l *XALog) append(decision XADecision) error {
+	line, err := json.Marshal(decision)
+	if err != nil {
+		return fmt.Errorf("failed to encode decision: %w", err)
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write decision: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync decision log: %w", err)
+	}
+	return nil
+}
+
+// begin marks a transaction as running in this process until end is called,
+// and returns the context bounding its prepare phase: half the abort timeout,
+// leaving the rest to log the decision before recovery could roll it back
+func (l *XALog) begin(xid string) (context.Context, context.CancelFunc) {
+	l.mutex.Lock()
+	l.inFlight[xid] = time.Now()
+	l.mutex.Unlock()
+
+	if l.abortAfter <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), l.abortAfter/2)
+}
+
+// end marks a transaction as no longer running in this process
+func (l *XALog) end(xid string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.inFlight, xid)
+}
+
+// InFlight reports whether this process is still running a transaction, so
+// its branches must not be resolved by recovery
+func (l *XALog) InFlight(xid string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	_, running := l.inFlight[xid]
+	return running
+}
+
+// RecordCommit durably records the decision to commit a transaction. A
+// transaction running for longer than the abort timeout is refused, since
+// its branches are old enough for recovery to roll back.
+func (l *XALog) RecordCommit(xid string, shardIDs []string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if began, running := l.inFlight[xid]; running && l.abortAfter > 0 && time.Since(began) >= l.abortAfter {
+		return fmt.Errorf("transaction %s took longer than the abort timeout of %s to prepare", xid, l.abortAfter)
+	}
+	decision := XADecision{XID: xid, Decision: XACommit, Shards: shardIDs, Time: time.Now().UTC()}
+	if err := l.append(decision); err != nil {
+		return err
+	}
+	l.pending[xid] = decision
+	return nil
+}
+
+// RecordDone records that a transaction committed on every shard
+func (l *XALog) RecordDone(xid string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if err := l.append(XADecision{XID: xid, Decision: XADone, Time: time.Now().UTC()}); err != nil {
+		return err
+	}
+	delete(l.pending, xid)
+	return nil
+}
+
+// Decision returns the pending commit decision of a transaction
+func (l *XALog) Decision(xid string) (XADecision, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	decision, exists := l.pending[xid]
+	return decision, exists
+}
+
+// Pending returns the commit decisions of transactions not yet committed on
+// every shard, oldest first
+func (l *XALog) Pending() []XADecision {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	decisions := make([]XADecision, 0, len(l.pending))
+	for _, decision := range l.pending {
+		decisions = append(decisions, decision)
+	}
+	sort.Slice(decisions, func(i, j int) bool { return decisions[i].Time.Before(decisions[j].Time) })
+	return decisions
+}
+
+// Close closes the decision log
+func (l *XALog) Close() error {
+	return l.file.Close()
+}
+
+// InDoubtError reports a transaction that committed on some shards while
+// others still hold it prepared. The commit decision is logged, so recovery
+// commits the remaining shards once they are reachable.
+type InDoubtError struct {
+	XID       string
+	Committed []string
+	InDoubt   map[string]error
+}
+
+// Error implements error
+func (e *InDoubtError) Error() string {
+	shards := make([]string, 0, len(e.InDoubt))
+	for shardID, err := range e.InDoubt {
+		shards = append(shards, fmt.Sprintf("%s (%v)", shardID, err))
+	}
+	sort.Strings(shards)
+	return fmt.Sprintf("transaction %s committed on %d shards and is in doubt on %s",
+		e.XID, len(e.Committed), strings.Join(shards, ", "))
+}
+
+// ExecuteXAWrite executes a write on every given shard as one XA transaction:
+// each shard prepares the statement, and only if all prepared is the commit
+// decision logged and the transaction committed everywhere. A prepare failure
+// rolls back all shards and is returned as an error. A commit failure leaves
+// the shard's branch prepared and is returned as an *InDoubtError alongside
+// the results. Shards must prepare within half the log's abort timeout;
+// recovery leaves the branches alone while the write runs.
+func (ds *DataStore) ExecuteXAWrite(query string, shardIDs []string, database string, xid string, decisions *XALog) ([]WriteResult, error) {
+	type branch struct {
+		conn     *sql.Conn
+		affected int64
+		err      error
+	}
+
+	ctx, cancel := decisions.begin(xid)
+	defer cancel()
+	defer decisions.end(xid)
+
+	// Phase 1: prepare on every shard
+	branches := make([]branch, len(shardIDs))
+	var wg sync.WaitGroup
+	for i, shardID := range shardIDs {
+		wg.Add(1)
+		go func(i int, sID string) {
+			defer wg.Done()
+			branches[i].conn, branches[i].affected, branches[i].err = ds.prepareXA(ctx, query, sID, database, xid)
+		}(i, shardID)
+	}
+	wg.Wait()
+
+	rollback := func() {
+		for i, b := range branches {
+			if b.conn == nil {
+				continue
+			}
+			if _, err := b.conn.ExecContext(context.Background(), "XA ROLLBACK "+quoteXID(xid)); err != nil {
+				ds.logXAError(shardIDs[i], xid, "roll back", err)
+			}
+			b.conn.Close()
+		}
+	}
+
+	for i, b := range branches {
+		if b.err != nil {
+			rollback()
+			return nil, fmt.Errorf("write rolled back on all shards: %w", &ShardError{ShardID: shardIDs[i], Err: b.err})
+		}
+	}
+
+	if err := decisions.RecordCommit(xid, shardIDs); err != nil {
+		rollback()
+		return nil, fmt.Errorf("write rolled back on all shards: %w", err)
+	}
+
+	// Phase 2: commit everywhere
+	results := make([]WriteResult, len(shardIDs))
+	inDoubt := &InDoubtError{XID: xid, InDoubt: make(map[string]error)}
+	for i, b := range branches {
+		results[i] = WriteResult{ShardID: shardIDs[i], RowsAffected: b.affected}
+		_, err := b.conn.ExecContext(context.Background(), "XA COMMIT "+quoteXID(xid))
+		b.conn.Close()
+		if err != nil {
+			results[i].RowsAffected = 0
+			results[i].Err = fmt.Errorf("failed to commit on shard %s: %w", shardIDs[i], err)
+			inDoubt.InDoubt[shardIDs[i]] = err
+			continue
+		}
+		inDoubt.Committed = append(inDoubt.Committed, shardIDs[i])
+	}
+
+	if len(inDoubt.InDoubt) > 0 {
+		return results, inDoubt
+	}
+	if err := decisions.RecordDone(xid); err != nil {
+		ds.logXAError("", xid, "record completion of", err)
+	}
+	return results, nil
+}
+
+// prepareXA runs a write statement in an XA transaction on a dedicated shard
+// connection and prepares it before ctx expires, returning the connection
+// holding the branch
+func (ds *DataStore) prepareXA(ctx context.Context, query string, shardID string, database string, xid string) (*sql.Conn, int64, error) {
+	conn, err := ds.startXA(ctx, shardID, database, xid)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start := time.Now()
+	result, err := conn.ExecContext(ctx, clientStatement(query))
+	ds.countWrite(shardID, start, query, err)
+	var affected int64
+	if err == nil {
+		affected, err = result.RowsAffected()
+	}
+	if err != nil {
+		abortXA(conn, xid)
+		return nil, 0, fmt.Errorf("failed to execute query on shard %s: %w", shardID, err)
+	}
+
+	if err := prepareXABranch(ctx, conn, shardID, xid); err != nil {
+		return nil, 0, err
+	}
+	return conn, affected, nil
+}
+
+// startXA starts an XA transaction on a dedicated shard connection
+func (ds *DataStore) startXA(ctx context.Context, shardID string, database string, xid string) (*sql.Conn, error) {
+	db, err := ds.getConnection(shardID, database)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection to shard %s: %w", shardID, err)
+	}
+
+	if _, err := conn.ExecContext(ctx, "XA START "+quoteXID(xid)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start transaction on shard %s: %w", shardID, err)
+	}
+	return conn, nil
+}
+
+// prepareXABranch ends and prepares a started XA transaction before ctx
+// expires, rolling it back and closing the connection if that fails
+func prepareXABranch(ctx context.Context, conn *sql.Conn, shardID string, xid string) error {
+	_, err := conn.ExecContext(ctx, "XA END "+quoteXID(xid))
+	if err == nil {
+		_, err = conn.ExecContext(ctx, "XA PREPARE "+quoteXID(xid))
+	}
+	if err != nil {
+		conn.ExecContext(context.Background(), "XA ROLLBACK "+quoteXID(xid))
+		conn.Close()
+		return fmt.Errorf("failed to prepare transaction on shard %s: %w", shardID, err)
+	}
+	return nil
+}
+
+// abortXA rolls back a started, unprepared XA transaction and closes the
+// connection
+func abortXA(conn *sql.Conn, xid string) {
+	ctx := context.Background()
+	conn.ExecContext(ctx, "XA END "+quoteXID(xid))
+	conn.ExecContext(ctx, "XA ROLLBACK "+quoteXID(xid))
+	conn.Close()
+}
+
+// RecoverXA lists the transactions prepared on a shard whose XIDs start with
+// prefix. Listing other sessions' transactions requires XA_RECOVER_ADMIN.
+func (ds *DataStore) RecoverXA(shardID string, prefix string) ([]string, error) {
+	db, err := ds.getConnection(shardID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("XA RECOVER")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prepared transactions on shard %s: %w", shardID, err)
+	}
+	defer rows.Close()
+
+	var xids []string
+	for rows.Next() {
+		var formatID, gtridLength, bqualLength int
+		var data string
+		if err := rows.Scan(&formatID, &gtridLength, &bqualLength, &data); err != nil {
+			return nil, fmt.Errorf("failed to read prepared transactions on shard %s: %w", shardID, err)
+		}
+		// Branches are created without a bqual, so data is the gtrid
+		if bqualLength == 0 && strings.HasPrefix(data, prefix) {
+			xids = append(xids, data)
+		}
+	}
+	return xids, rows.Err()
+}
+
+// ResolveXA commits or rolls back a transaction left prepared on a shard.
+// Transactions the shard no longer knows about count as resolved.
+func (ds *DataStore) ResolveXA(shardID string, xid string, commit bool) error {
+	db, err := ds.getConnection(shardID, "")
+	if err != nil {
+		return err
+	}
+
+	statement := "XA ROLLBACK "
+	if commit {
+		statement = "XA COMMIT "
+	}
+	if _, err := db.Exec(statement + quoteXID(xid)); err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlUnknownXID {
+			return nil
+		}
+		return fmt.Errorf("failed to resolve transaction %s on shard %s: %w", xid, shardID, err)
+	}
+	return nil
+}
+
+// quoteXID quotes an XID as a string literal
+func quoteXID(xid string) string {
+	return "'" + strings.ReplaceAll(xid, "'", "''") + "'"
+}
+
+// logXAError logs a failure to clean up a transaction; recovery resolves it
+func (ds *DataStore) logXAError(shardID, xid, action string, err error) {
+	if shardID == "" {
+		log.Printf("Warning: Failed to %s transaction %s: %v", action, xid, err)
+		return
+	}
+	log.Printf("Warning: Failed to %s transaction %s on shard %s: %v", action, xid, shardID, err)
+}
@@ -0,0 +1,123 @@
+package datastore
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestXALog(t *testing.T, abortAfter time.Duration) (*XALog, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "xa-decisions.log")
+	decisions, err := OpenXALog(path, abortAfter)
+	if err != nil {
+		t.Fatalf("open decision log: %v", err)
+	}
+	t.Cleanup(func() { decisions.Close() })
+	return decisions, path
+}
+
+// A shard slow to prepare must not let recovery roll back the branches the
+// other shards prepared and the router then commit the rest: recovery skips
+// the running transaction, and a commit decision past the abort timeout is
+// refused so the router rolls back instead
+func TestXALogSlowPrepare(t *testing.T) {
+	abortAfter := 50 * time.Millisecond
+	decisions, _ := openTestXALog(t, abortAfter)
+	xid := "xa:router-1:slow.1"
+
+	ctx, cancel := decisions.begin(xid)
+	defer cancel()
+	if !decisions.InFlight(xid) {
+		t.Fatalf("transaction is not in flight after begin")
+	}
+	deadline, bounded := ctx.Deadline()
+	if !bounded || time.Until(deadline) >= abortAfter {
+		t.Fatalf("prepare deadline %v is not within the abort timeout %s", deadline, abortAfter)
+	}
+
+	// The slow shard keeps preparing past the abort timeout, while recovery
+	// would consider the fast shards' branches old enough to roll back
+	time.Sleep(abortAfter)
+	if !decisions.InFlight(xid) {
+		t.Fatalf("recovery would resolve a transaction still running")
+	}
+	if ctx.Err() == nil {
+		t.Fatalf("prepare context outlived the abort timeout")
+	}
+
+	if err := decisions.RecordCommit(xid, []string{"shard-1", "shard-2"}); err == nil {
+		t.Fatalf("commit decision recorded after the abort timeout")
+	}
+	if _, exists := decisions.Decision(xid); exists {
+		t.Fatalf("refused commit decision is pending")
+	}
+
+	decisions.end(xid)
+	if decisions.InFlight(xid) {
+		t.Fatalf("transaction is still in flight after end")
+	}
+}
+
+func TestXALogRecordCommit(t *testing.T) {
+	decisions, path := openTestXALog(t, time.Minute)
+
+	tests := []struct {
+		xid    string
+		shards []string
+		done   bool
+	}{
+		{"xa:router-1:a.1", []string{"shard-1", "shard-2"}, true},
+		{"xa:router-1:b.2", []string{"shard-2", "shard-3"}, false},
+		{"xa:router-1:c.3", []string{"shard-1", "shard-3"}, false},
+	}
+	for _, test := range tests {
+		decisions.begin(test.xid)
+		if err := decisions.RecordCommit(test.xid, test.shards); err != nil {
+			t.Fatalf("record commit of %s: %v", test.xid, err)
+		}
+		decisions.end(test.xid)
+		if test.done {
+			if err := decisions.RecordDone(test.xid); err != nil {
+				t.Fatalf("record done of %s: %v", test.xid, err)
+			}
+		}
+	}
+
+	// Pending decisions survive a restart, oldest first; done ones are dropped
+	decisions.Close()
+	reopened, err := OpenXALog(path, time.Minute)
+	if err != nil {
+		t.Fatalf("reopen decision log: %v", err)
+	}
+	defer reopened.Close()
+
+	pending := reopened.Pending()
+	if len(pending) != 2 || pending[0].XID != "xa:router-1:b.2" || pending[1].XID != "xa:router-1:c.3" {
+		t.Fatalf("pending decisions after reopening = %+v", pending)
+	}
+	for _, test := range tests {
+		decision, exists := reopened.Decision(test.xid)
+		if exists == test.done {
+			t.Errorf("decision of %s pending = %v, want %v", test.xid, exists, !test.done)
+		}
+		if exists && (decision.Decision != XACommit || len(decision.Shards) != len(test.shards)) {
+			t.Errorf("decision of %s = %+v", test.xid, decision)
+		}
+	}
+}
+
+func TestQuoteXID(t *testing.T) {
+	tests := []struct {
+		xid  string
+		want string
+	}{
+		{"xa:router-1:abc.1", "'xa:router-1:abc.1'"},
+		{"xa:it's:1", "'xa:it''s:1'"},
+	}
+	for _, test := range tests {
+		if got := quoteXID(test.xid); got != test.want {
+			t.Errorf("quoteXID(%q) = %q, want %q", test.xid, got, test.want)
+		}
+	}
+}
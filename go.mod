@@ -1,12 +1,12 @@
 module sql-horizontal-autoscaler
 
-go 1.21
+go 1.24
 
 require (
 	github.com/go-sql-driver/mysql v1.7.1
+	github.com/klauspost/compress v1.17.11
 	github.com/shirou/gopsutil/v3 v3.23.12
 	github.com/xwb1989/sqlparser v0.0.0-20180606152119-120387863bf2
-	stathat.com/c/consistent v1.0.0
 )
 
 require (
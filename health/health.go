@@ -0,0 +1,155 @@
+// Package health serves Kubernetes-style liveness and readiness probes built
+// from named dependency checks
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// checkTimeout bounds how long a single check may take
+const checkTimeout = 5 * time.Second
+
+// Check returns nil when the dependency it covers is healthy
+type Check func() error
+
+// CheckResult is the outcome of one check
+type CheckResult struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Report is the body of a probe response
+type Report struct {
+	Status  string        `json:"status"`
+	Service string        `json:"service"`
+	Checks  []CheckResult `json:"checks"`
+}
+
+// Handler runs every check concurrently and answers 200 when all pass and
+// 503 otherwise, with per-check details in the body
+func Handler(service string, checks map[string]Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		report := Run(service, checks)
+		status := http.StatusOK
+		if report.Status != "ok" {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			log.Printf("Failed to encode health report: %v", err)
+		}
+	}
+}
+
+// Run executes the checks and builds a report, sorted by check name
+func Run(service string, checks map[string]Check) Report {
+	report := Report{Status: "ok", Service: service, Checks: make([]CheckResult, 0, len(checks))}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := runCheck(name, check)
+
+			mutex.Lock()
+			report.Checks = append(report.Checks, result)
+			mutex.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	sort.Slice(report.Checks, func(i, j int) bool { return report.Checks[i].Name < report.Checks[j].Name })
+	for _, result := range report.Checks {
+		if result.Status != "ok" {
+			report.Status = "failed"
+		}
+	}
+	return report
+}
+
+// runCheck runs one check, failing it if it doesn't finish within checkTimeout
+func runCheck(name string, check Check) CheckResult {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check() }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(checkTimeout):
+		err = fmt.Errorf("check timed out after %s", checkTimeout)
+	}
+
+	result := CheckResult{
+		Name:       name,
+		Status:     "ok",
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Heartbeat tracks the last time a background loop made progress
+type Heartbeat struct {
+	last int64
+}
+
+// NewHeartbeat creates a heartbeat that starts out fresh
+func NewHeartbeat() *Heartbeat {
+	hb := &Heartbeat{}
+	hb.Beat()
+	return hb
+}
+
+// Beat records progress
+func (hb *Heartbeat) Beat() {
+	atomic.StoreInt64(&hb.last, time.Now().UnixNano())
+}
+
+// Check fails when no beat was recorded within maxAge
+func (hb *Heartbeat) Check(maxAge time.Duration) Check {
+	return func() error {
+		age := time.Since(time.Unix(0, atomic.LoadInt64(&hb.last)))
+		if age > maxAge {
+			return fmt.Errorf("no heartbeat for %s (limit %s)", age.Round(time.Second), maxAge)
+		}
+		return nil
+	}
+}
+
+// HTTPCheck fails unless a GET of url answers with a 2xx status
+func HTTPCheck(url string) Check {
+	client := &http.Client{Timeout: checkTimeout}
+	return func() error {
+		resp, err := client.Get(url)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("%s returned %s", url, resp.Status)
+		}
+		return nil
+	}
+}
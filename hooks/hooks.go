@@ -0,0 +1,126 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Hook stages
+const (
+	// StagePreScale runs before a scaling action changes the cluster
+	StagePreScale = "pre_scale"
+	// StagePostScale runs once a scaling action completed or failed
+	StagePostScale = "post_scale"
+	// StageThresholdBreach runs whenever a scaling threshold is breached
+	StageThresholdBreach = "threshold_breach"
+)
+
+// Hook is an external action run at a stage: an executable, given the
+// invocation as JSON on stdin, or an HTTP endpoint the invocation is posted to
+type Hook struct {
+	Name    string
+	Command []string
+	URL     string
+	Timeout time.Duration
+	// AbortOnFailure makes a failing pre-scale hook cancel the action
+	AbortOnFailure bool
+}
+
+// Invocation is the payload a hook receives
+type Invocation struct {
+	Stage string      `json:"stage"`
+	Hook  string      `json:"hook"`
+	Time  time.Time   `json:"time"`
+	Event interface{} `json:"event"`
+}
+
+// Runner runs the hooks configured for each stage
+type Runner struct {
+	hooks  map[string][]Hook
+	client *http.Client
+}
+
+// NewRunner creates a runner for hooks keyed by stage
+func NewRunner(hooks map[string][]Hook) *Runner {
+	return &Runner{hooks: hooks, client: &http.Client{}}
+}
+
+// Has reports whether any hook runs at a stage
+func (r *Runner) Has(stage string) bool {
+	return len(r.hooks[stage]) > 0
+}
+
+// Run runs the hooks of a stage in order with an event. It returns an error
+// naming the first failing hook that aborts on failure; other failures are
+// passed to onFailure and the remaining hooks still run.
+func (r *Runner) Run(stage string, event interface{}, onFailure func(hook string, err error)) error {
+	for _, hook := range r.hooks[stage] {
+		if err := r.run(stage, hook, event); err != nil {
+			if hook.AbortOnFailure {
+				return fmt.Errorf("hook %s failed: %w", hook.Name, err)
+			}
+			onFailure(hook.Name, err)
+		}
+	}
+	return nil
+}
+
+// run runs one hook, bounded by its timeout
+func (r *Runner) run(stage string, hook Hook, event interface{}) error {
+	body, err := json.Marshal(Invocation{Stage: stage, Hook: hook.Name, Time: time.Now(), Event: event})
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hook.Timeout)
+	defer cancel()
+
+	if hook.URL != "" {
+		return r.post(ctx, hook.URL, body)
+	}
+	return execute(ctx, stage, hook.Command, body)
+}
+
+// post sends an invocation to an HTTP endpoint, failing on a non-2xx status
+func (r *Runner) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+// execute runs an executable with the invocation on stdin and the stage in
+// HOOK_STAGE, failing on a non-zero exit
+func execute(ctx context.Context, stage string, command []string, body []byte) error {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), "HOOK_STAGE="+stage)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if trimmed := strings.TrimSpace(string(output)); trimmed != "" {
+			return fmt.Errorf("%s: %w: %s", command[0], err, trimmed)
+		}
+		return fmt.Errorf("%s: %w", command[0], err)
+	}
+	return nil
+}
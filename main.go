@@ -2,110 +2,529 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
+	"sql-horizontal-autoscaler/audit"
 	"sql-horizontal-autoscaler/config"
 	"sql-horizontal-autoscaler/coordinator"
 	"sql-horizontal-autoscaler/datastore"
+	"sql-horizontal-autoscaler/querylog"
 	"sql-horizontal-autoscaler/router"
+	"sql-horizontal-autoscaler/secrets"
 	"sql-horizontal-autoscaler/sharding"
 )
 
 func main() {
+	// Without a subcommand the binary serves, as it always has
+	args := os.Args[1:]
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	cmd, exists := findCommand(name)
+	if !exists {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		printUsage()
+		os.Exit(2)
+	}
+	if err := cmd.run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Service roles a process can serve
+const (
+	roleAll         = "all"
+	roleRouter      = "router"
+	roleCoordinator = "coordinator"
+)
+
+// serveRole returns a command serving a single role, as serve --role would
+func serveRole(role string) func(args []string) error {
+	return func(args []string) error {
+		return serve(append([]string{"--role", role}, args...))
+	}
+}
+
+// serve runs the query router and coordinator until interrupted; --role runs
+// one of them, so each tier can be deployed and restarted on its own
+func serve(args []string) error {
 	// Parse command line flags
-	configFile := flag.String("config", "config.json", "Path to configuration file")
-	flag.Parse()
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	configFile := flags.String("config", "config.json", "Path to configuration file")
+	profile := flags.String("profile", os.Getenv("AUTOSCALER_PROFILE"),
+		"Environment overlay merged into the configuration file (e.g. prod loads config.prod.json)")
+	verifyAudit := flags.String("verify-audit", "", "Verify the hash chain of an audit log and exit")
+	auditKey := flags.String("audit-key", os.Getenv("AUTOSCALER_AUDIT_KEY"), "Key of the audit log to verify")
+	role := flags.String("role", envOr("AUTOSCALER_ROLE", roleAll), "Services to run: all, router or coordinator")
+	flags.Parse(args)
 
-	log.Println("Starting SQL Horizontal Autoscaler...")
+	if *role != roleAll && *role != roleRouter && *role != roleCoordinator {
+		return fmt.Errorf("unknown role %q, expected all, router or coordinator", *role)
+	}
+	if *verifyAudit != "" {
+		if *auditKey == "" {
+			return fmt.Errorf("verifying an audit log requires -audit-key or AUTOSCALER_AUDIT_KEY")
+		}
+		if _, err := audit.Verify(*verifyAudit, *auditKey); err != nil {
+			log.Fatalf("Audit log %s failed verification: %v", *verifyAudit, err)
+		}
+		log.Printf("Audit log %s verified", *verifyAudit)
+		return nil
+	}
+
+	log.Printf("Starting SQL Horizontal Autoscaler (role %s)...", *role)
 	log.Printf("Using configuration file: %s", *configFile)
+	if *profile != "" {
+		log.Printf("Using configuration profile %s: %s", *profile, config.ProfilePath(*configFile, *profile))
+	}
 
 	// Load configuration
-	cfg, err := config.LoadConfig(*configFile)
+	cfg, err := config.LoadConfigProfile(*configFile, *profile)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	log.Printf("Loaded configuration with %d shards and %s scaling strategy", 
-		len(cfg.Shards), cfg.ScalingStrategy)
+	// Shard groups are derived before secrets are resolved, so each group
+	// resolves and rotates the references it inherits itself
+	configs := []*config.Config{cfg}
+	for _, name := range cfg.ShardGroupNames() {
+		groupConfig, err := cfg.ShardGroup(name)
+		if err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+		configs = append(configs, groupConfig)
+	}
 
-	// Initialize datastore
-	dataStore := datastore.NewDataStore()
+	secretResolver := secrets.NewResolver()
+	var clusters []*cluster
+	for _, clusterConfig := range configs {
+		c, err := newCluster(clusterConfig, secretResolver, *role)
+		if err != nil {
+			// The groups already built are closed by their deferred close
+			return err
+		}
+		defer c.close()
+		clusters = append(clusters, c)
+	}
 
-	// Extract table names from configuration
-	tableNames := make([]string, 0, len(cfg.TableShardKeys))
-	for tableName := range cfg.TableShardKeys {
-		tableNames = append(tableNames, tableName)
+	// The default group's router answers queries on every group's tables
+	if queryRouter := clusters[0].router; queryRouter != nil && len(clusters) > 1 {
+		groupRouters := make(map[string]*router.QueryRouter)
+		for _, c := range clusters[1:] {
+			for table := range c.config.TableShardKeys {
+				groupRouters[table] = c.router
+			}
+		}
+		queryRouter.SetShardGroups(groupRouters)
+		log.Printf("Managing %d shard groups besides the default one: %v", len(clusters)-1, cfg.ShardGroupNames())
 	}
 
-	if err := dataStore.InitializeConnections(cfg.Shards, tableNames); err != nil {
-		log.Fatalf("Failed to initialize database connections: %v", err)
+	// Setup graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	var wg sync.WaitGroup
+
+	// Start every group's Query Router and Coordinator Service
+	for _, c := range clusters {
+		if c.router != nil {
+			wg.Add(1)
+			go func(c *cluster) {
+				defer wg.Done()
+				if err := c.router.Start(); err != nil {
+					log.Printf("Query Router error%s: %v", groupSuffix(c.config), err)
+				}
+			}(c)
+		}
+		if c.coordinator != nil {
+			wg.Add(1)
+			go func(c *cluster) {
+				defer wg.Done()
+				if err := c.coordinator.Start(); err != nil {
+					log.Printf("Coordinator Service error%s: %v", groupSuffix(c.config), err)
+				}
+			}(c)
+		}
 	}
+
+	log.Println("All services started successfully")
+	for _, c := range clusters {
+		if c.router != nil {
+			log.Printf("Query Router%s available at: http://localhost:%d", groupSuffix(c.config), c.config.Ports.QueryRouterPort)
+		}
+		if c.coordinator != nil {
+			log.Printf("Coordinator Service%s available at: http://localhost:%d", groupSuffix(c.config), c.config.Ports.CoordinatorPort)
+		}
+	}
+	log.Println("Press Ctrl+C to shutdown...")
+
+	// Wait for shutdown signal
+	<-sigChan
+	log.Println("Shutdown signal received, stopping services...")
+
+	// Stop coordinators
+	for _, c := range clusters {
+		if c.coordinator != nil {
+			c.coordinator.Stop()
+		}
+	}
+
+	log.Println("Services stopped. Exiting...")
+	return nil
+}
+
+// cluster is the services of one shard group
+type cluster struct {
+	config      *config.Config
+	router      *router.QueryRouter
+	coordinator *coordinator.Coordinator
+	// closers release what the services hold open, last opened first
+	closers []func()
+}
+
+// applyRotatedSecret applies a rotated secret to the configuration and moves
+// the shard connections using it over to the new value
+func applyRotatedSecret(cfg *config.Config, dataStore *datastore.DataStore, shardManager *sharding.DynamicShardManager, name, value string) {
+	oldPassword, _ := cfg.DatabaseCredentials()
+	if err := cfg.ApplySecret(name, value); err != nil {
+		log.Printf("Warning: Failed to apply rotated secret %s: %v", name, err)
+		return
+	}
+	password, rootPassword := cfg.DatabaseCredentials()
+	shardManager.UpdateCredentials(password, rootPassword)
+
+	// A shard's own password only changes its DSN
+	rotated := make(map[string]string)
+	if shardID, isShard := strings.CutPrefix(name, "shards."); isShard {
+		if dsn, exists := cfg.GetShard(shardID); exists {
+			if err := dataStore.UpdateShardDSN(shardID, dsn); err != nil {
+				log.Printf("Warning: Failed to reconnect shard %s with its rotated password: %v", shardID, err)
+			}
+			rotated[shardID] = dsn
+		}
+	} else if password != oldPassword {
+		dsns, err := dataStore.RotatePassword(cfg.Database.Username, oldPassword, password)
+		if err != nil {
+			log.Printf("Warning: Failed to reconnect some shards with the rotated database password: %v", err)
+		}
+		for shardID, dsn := range dsns {
+			cfg.SetShard(shardID, dsn)
+			rotated[shardID] = dsn
+		}
+	}
+
+	for shardID, dsn := range rotated {
+		if err := shardManager.SetShardDSN(shardID, dsn); err != nil {
+			log.Printf("Warning: Failed to update the DSN of shard %s: %v", shardID, err)
+		}
+	}
+	if len(rotated) > 0 {
+		log.Printf("🔑 Reconnected %d shards with rotated credentials", len(rotated))
+	}
+}
+
+// newCluster connects to a shard group's shards and builds the services of
+// the role for it. On failure, what it opened so far is closed again.
+func newCluster(cfg *config.Config, secretResolver *secrets.Resolver, role string) (_ *cluster, err error) {
+	c := &cluster{config: cfg}
 	defer func() {
+		if err != nil {
+			c.close()
+		}
+	}()
+	runRouter := role != roleCoordinator
+	runCoordinator := role != roleRouter
+
+	// Resolve secret references (vault:, aws-sm:, env:) before anything uses them
+	if err := cfg.ResolveSecrets(secretResolver); err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets%s: %w", groupSuffix(cfg), err)
+	}
+
+	// A router on its own learns of new shards from the coordinator
+	if role == roleRouter {
+		if cfg.Routers.CoordinatorURL == "" {
+			return nil, fmt.Errorf("the router role needs routers.coordinator_url to follow the coordinator's topology")
+		}
+		if !cfg.Routers.SyncDirectory {
+			log.Printf("Enabling routers.sync_directory, the router runs apart from the coordinator")
+			cfg.Routers.SyncDirectory = true
+		}
+	}
+
+	log.Printf("Loaded configuration%s with %d shards and %s scaling strategy",
+		groupSuffix(cfg), len(cfg.Shards), cfg.ScalingStrategy)
+
+	// Initialize datastore
+	dataStore := datastore.NewDataStore()
+	dataStore.SetAuthConfig(datastore.AuthConfig{
+		Mode:            cfg.Database.AuthMode,
+		ShardModes:      cfg.Database.ShardAuthModes,
+		Region:          cfg.Database.AWSRegion,
+		RefreshInterval: time.Duration(cfg.Database.TokenRefreshSeconds) * time.Second,
+	})
+	dataStore.SetPoolConfig(datastore.PoolConfig{
+		MaxOpenConns:    cfg.Database.Pool.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.Pool.MaxIdleConns,
+		ConnMaxLifetime: time.Duration(cfg.Database.Pool.ConnMaxLifetimeSeconds) * time.Second,
+		ConnMaxIdleTime: time.Duration(cfg.Database.Pool.ConnMaxIdleTimeSeconds) * time.Second,
+	})
+	dataStore.SetLegacyStringValues(cfg.Results.LegacyStringValues)
+	dataStore.SetReplicaLagInterval(time.Duration(cfg.Database.ReplicaLagCheckSeconds) * time.Second)
+	dataStore.SetBalancer(datastore.NewBalancer(cfg.Database.ReadBalancer))
+	dataStore.SetReadOnlyFallback(cfg.Database.ReadOnlyFallback)
+	dataStore.SetSnapshotWaitTimeout(time.Duration(cfg.Database.SnapshotWaitTimeoutMs) * time.Millisecond)
+
+	// Extract table names (qualified with their database) from configuration
+	tableNames := cfg.QualifiedTableNames()
+
+	// Closed even when connecting fails, releasing the shards already reached
+	c.closers = append(c.closers, func() {
 		if err := dataStore.Close(); err != nil {
 			log.Printf("Error closing datastore: %v", err)
 		}
-	}()
+	})
+	if err := dataStore.InitializeConnections(cfg.Shards, tableNames); err != nil {
+		return nil, fmt.Errorf("failed to initialize database connections%s: %w", groupSuffix(cfg), err)
+	}
+
+	dataStore.SetReplicationConfig(datastore.ReplicationConfig{
+		User:            cfg.Database.Replication.User,
+		Password:        cfg.Database.Replication.Password,
+		SourceAddresses: cfg.Database.Replication.SourceAddresses,
+		ApplyTimeout:    time.Duration(cfg.Database.Replication.ApplyTimeoutSeconds) * time.Second,
+	})
+	for shardID, replicaDSNs := range cfg.Database.Replicas {
+		for _, dsn := range replicaDSNs {
+			if err := dataStore.AddReplica(shardID, dsn); err != nil {
+				return nil, fmt.Errorf("failed to connect to replica of shard %s: %w", shardID, err)
+			}
+			if cfg.Database.Replication.Manage {
+				if err := dataStore.ConfigureReplica(shardID, dsn); err != nil {
+					log.Printf("Warning: %v", err)
+				}
+			}
+		}
+	}
 
 	log.Println("Database connections initialized successfully")
 
 	// Initialize dynamic shard manager
-	shardManagerConfig := &sharding.ShardManagerConfig{
-		BasePort:                       cfg.Ports.BasePort,
-		NetworkName:                    cfg.Docker.NetworkName,
-		DatabaseUsername:               cfg.Database.Username,
-		DatabasePassword:               cfg.Database.Password,
-		DatabaseRootPassword:           cfg.Database.RootPassword,
-		DockerImage:                    cfg.Docker.Image,
-		ContainerPrefix:                cfg.Docker.ContainerPrefix,
-		MaxConnectionAttempts:          cfg.Limits.MaxConnectionAttempts,
-		ConnectionRetryIntervalSeconds: cfg.Limits.ConnectionRetryIntervalSeconds,
-	}
-	shardManager := sharding.NewDynamicShardManager(cfg.Shards, shardManagerConfig)
+	shardManager := sharding.NewDynamicShardManager(cfg.Shards, newShardManagerConfig(cfg))
 	log.Printf("Dynamic shard manager initialized with shards: %v", shardManager.GetAllShards())
+	if runCoordinator {
+		if err := shardManager.EnsureNetwork(); err != nil {
+			log.Printf("Warning: Docker network %s is not available, new shards cannot be provisioned: %v", cfg.Docker.NetworkName, err)
+		}
+	}
 
-	// Initialize services
-	queryRouter := router.NewQueryRouter(cfg, dataStore, shardManager)
-	coordinatorService := coordinator.NewCoordinator(cfg, dataStore, shardManager)
+	// Serve non-strong reads from the local region when it can
+	dataStore.SetRegionConfig(datastore.RegionConfig{
+		Local:            cfg.Regions.Local,
+		ReplicaRegions:   cfg.Regions.ReplicaRegions,
+		ShardRegion:      shardManager.ShardRegion,
+		WritePolicy:      cfg.Regions.WritePolicy,
+		WriteWaitTimeout: time.Duration(cfg.Regions.WriteWaitTimeoutMs) * time.Millisecond,
+	})
+	if cfg.Regions.Local != "" {
+		log.Printf("Serving from region %s with %s write replication", cfg.Regions.Local, cfg.Regions.WritePolicy)
+	}
 
-	// Setup graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// Register tables sharded by time range
+	if len(cfg.TimeRange.Tables) > 0 {
+		policies := make(map[string]sharding.TimeRangePolicy, len(cfg.TimeRange.Tables))
+		for table, policy := range cfg.TimeRange.Tables {
+			policies[table] = sharding.TimeRangePolicy{Column: policy.Column, Period: policy.Period}
+		}
+		shardManager.SetTimeRangePolicies(policies)
+	}
 
-	var wg sync.WaitGroup
+	// Place keys exactly as the process the layout was saved from did
+	if cfg.Ring.LayoutPath != "" {
+		layout, err := sharding.LoadRingLayout(cfg.Ring.LayoutPath)
+		if err == nil {
+			err = shardManager.ImportRingLayout(layout)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to import ring layout: %w", err)
+		}
+		log.Printf("Imported ring layout %s with %d tokens", shardManager.RingLayout().Checksum, len(layout.Tokens))
+	}
 
-	// Start Query Router
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := queryRouter.Start(); err != nil {
-			log.Printf("Query Router error: %v", err)
+	// Register configured maintenance windows
+	for _, window := range cfg.MaintenanceWindows {
+		if err := shardManager.SetMaintenance(sharding.MaintenanceWindow{
+			ShardID: window.ShardID,
+			Start:   window.Start,
+			End:     window.End,
+			Mode:    window.Mode,
+			Reason:  window.Reason,
+		}); err != nil {
+			return nil, fmt.Errorf("invalid maintenance window for %s: %w", window.ShardID, err)
 		}
-	}()
+	}
 
-	// Start Coordinator Service
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := coordinatorService.Start(); err != nil {
-			log.Printf("Coordinator Service error: %v", err)
+	// Watch secret references for rotation
+	if refs := cfg.SecretReferences(); len(refs) > 0 {
+		rotationWatcher := secrets.NewRotationWatcher(secretResolver, refs, cfg.SecretValues(),
+			time.Duration(cfg.Secrets.RefreshIntervalSeconds)*time.Second,
+			func(name, value string) {
+				applyRotatedSecret(cfg, dataStore, shardManager, name, value)
+			})
+		rotationWatcher.Start()
+		c.closers = append(c.closers, rotationWatcher.Stop)
+		log.Printf("Watching %d secret references for rotation", len(refs))
+	}
+
+	// Initialize services
+	if runRouter {
+		queryRouter := router.NewQueryRouter(cfg, dataStore, shardManager)
+		c.router = queryRouter
+		if runCoordinator {
+			queryRouter.SetColocated()
 		}
-	}()
+		if cfg.Audit.Enabled {
+			auditLog, err := audit.Open(cfg.Audit.Path, cfg.Audit.Key, cfg.Audit.Tables, cfg.Audit.ExcludeTables)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open audit log: %w", err)
+			}
+			c.closers = append(c.closers, func() { auditLog.Close() })
+			queryRouter.SetAuditLog(auditLog)
+			log.Printf("Auditing write statements to %s", cfg.Audit.Path)
+		}
+		if cfg.QueryLog.Enabled {
+			queryLog, err := querylog.Open(querylog.Options{
+				Output:           cfg.QueryLog.Output,
+				SampleRate:       cfg.QueryLog.SampleRate,
+				TableSampleRates: cfg.QueryLog.TableSampleRates,
+				Redact:           !cfg.QueryLog.IncludeLiterals,
+				MaxSizeBytes:     int64(cfg.QueryLog.MaxSizeMB) * 1024 * 1024,
+				MaxBackups:       cfg.QueryLog.MaxBackups,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to open query log: %w", err)
+			}
+			c.closers = append(c.closers, func() { queryLog.Close() })
+			queryRouter.SetQueryLog(queryLog)
+			log.Printf("Logging %.0f%% of queries to %s", cfg.QueryLog.SampleRate*100, cfg.QueryLog.Output)
+		}
+		if cfg.Transactions.TwoPhaseCommit {
+			// Recovery only resolves the XIDs carrying this router's ID, so a
+			// default ID shared by routers on other hosts would let one
+			// router commit or roll back another's transactions
+			if cfg.Routers.ID == "" {
+				return nil, fmt.Errorf("transactions.two_phase_commit requires routers.id, unique to each router and kept across restarts")
+			}
+			decisions, err := datastore.OpenXALog(cfg.Transactions.DecisionLogPath, time.Duration(cfg.Transactions.AbortAfterSeconds)*time.Second)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open transaction decision log: %w", err)
+			}
+			c.closers = append(c.closers, func() { decisions.Close() })
+			queryRouter.SetXALog(decisions)
+			log.Printf("Multi-shard writes use two-phase commit, decisions logged to %s", cfg.Transactions.DecisionLogPath)
+		}
+		if cfg.WriteBuffer.Enabled {
+			journal, err := datastore.OpenWriteJournal(cfg.WriteBuffer.Path, datastore.WriteBufferLimits{
+				MaxWrites: cfg.WriteBuffer.MaxWrites,
+				MaxAge:    time.Duration(cfg.WriteBuffer.MaxAgeSeconds) * time.Second,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to open write buffer: %w", err)
+			}
+			c.closers = append(c.closers, func() { journal.Close() })
+			queryRouter.SetWriteBuffer(journal)
+			log.Printf("Writes to unreachable shards are buffered in %s", cfg.WriteBuffer.Path)
+		}
+	}
 
-	log.Println("All services started successfully")
-	log.Printf("Query Router available at: http://localhost:%d", cfg.Ports.QueryRouterPort)
-	log.Printf("Coordinator Service available at: http://localhost:%d", cfg.Ports.CoordinatorPort)
-	log.Println("Press Ctrl+C to shutdown...")
+	if runCoordinator {
+		c.coordinator = coordinator.NewCoordinator(cfg, dataStore, shardManager)
+	}
 
-	// Wait for shutdown signal
-	<-sigChan
-	log.Println("Shutdown signal received, stopping services...")
+	return c, nil
+}
 
-	// Stop coordinator
-	coordinatorService.Stop()
+// close releases what the cluster's services hold open
+func (c *cluster) close() {
+	for i := len(c.closers) - 1; i >= 0; i-- {
+		c.closers[i]()
+	}
+}
 
-	log.Println("Services stopped. Exiting...")
+// groupSuffix names the shard group of a configuration in log messages
+func groupSuffix(cfg *config.Config) string {
+	if cfg.Group == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (shard group %s)", cfg.Group)
+}
+
+// newShardManagerConfig builds the shard manager settings from the configuration
+func newShardManagerConfig(cfg *config.Config) *sharding.ShardManagerConfig {
+	zones := make([]sharding.Zone, 0, len(cfg.Placement.Zones))
+	for _, zone := range cfg.Placement.Zones {
+		zones = append(zones, sharding.Zone{Name: zone.Name, DockerHost: zone.DockerHost, Host: zone.Host, Region: zone.Region})
+	}
+	return &sharding.ShardManagerConfig{
+		BasePort:                       cfg.Ports.BasePort,
+		NetworkName:                    cfg.Docker.NetworkName,
+		NetworkDriver:                  cfg.Docker.NetworkDriver,
+		NetworkSubnet:                  cfg.Docker.NetworkSubnet,
+		DatabaseUsername:               cfg.Database.Username,
+		DatabasePassword:               cfg.Database.Password,
+		DatabaseRootPassword:           cfg.Database.RootPassword,
+		DockerImage:                    cfg.Docker.Image,
+		ContainerPrefix:                cfg.Docker.ContainerPrefix,
+		MaxConnectionAttempts:          cfg.Limits.MaxConnectionAttempts,
+		ConnectionRetryIntervalSeconds: cfg.Limits.ConnectionRetryIntervalSeconds,
+		ReadyTimeout:                   time.Duration(cfg.Limits.ReadyTimeoutSeconds) * time.Second,
+		DSNParams:                      cfg.Database.DSNParams,
+		ShardDSNParams:                 cfg.Database.ShardDSNParams,
+		Provisioner:                    cfg.Provisioner.Type,
+		Cloud: sharding.CloudProvisionerConfig{
+			InstanceClass:    cfg.Provisioner.InstanceClass,
+			StorageGB:        cfg.Provisioner.StorageGB,
+			Region:           cfg.Provisioner.Region,
+			EngineVersion:    cfg.Provisioner.EngineVersion,
+			Network:          cfg.Provisioner.Network,
+			Subnet:           cfg.Provisioner.Subnet,
+			SecurityGroupIDs: cfg.Provisioner.SecurityGroupIDs,
+			ResourceGroup:    cfg.Provisioner.ResourceGroup,
+			Project:          cfg.Provisioner.Project,
+		},
+		ShardLabels:                    cfg.ShardLabels,
+		Zones:                          zones,
+		Init: sharding.InitHookConfig{
+			ScriptsDir: cfg.ShardInit.ScriptsDir,
+			WebhookURL: cfg.ShardInit.WebhookURL,
+			Timeout:    time.Duration(cfg.ShardInit.TimeoutSeconds) * time.Second,
+			Required:   cfg.ShardInit.Required,
+		},
+		Warmup: sharding.WarmupConfig{
+			Queries:      cfg.ShardWarmup.Queries,
+			VirtualUsers: cfg.ShardWarmup.VirtualUsers,
+			Iterations:   cfg.ShardWarmup.Iterations,
+			Timeout:      time.Duration(cfg.ShardWarmup.TimeoutSeconds) * time.Second,
+		},
+		RampStartWeight: cfg.Ramp.StartWeight(),
+		MirrorBasePort:  cfg.Mirrors.BasePort,
+		ShardPorts:      cfg.Database.ShardPorts,
+		Volumes: sharding.VolumeConfig{
+			Mode:         cfg.Docker.Volumes.Mode,
+			BindDir:      cfg.Docker.Volumes.BindDir,
+			Shards:       cfg.Docker.Volumes.Shards,
+			Retention:    cfg.Docker.Volumes.Retention,
+			ArchiveDir:   cfg.Docker.Volumes.ArchiveDir,
+			ArchiveImage: cfg.Docker.Volumes.ArchiveImage,
+		},
+	}
 }
@@ -8,16 +8,13 @@ import (
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/disk"
-	"github.com/shirou/gopsutil/v3/mem"
 )
 
 // RealMetricsCollector collects actual system and database metrics
 type RealMetricsCollector struct {
-	connections map[string]*sql.DB
-	tableNames  []string
+	connection func(shardID string) (*sql.DB, bool)
+	tableNames []string
+	sampler    *SystemSampler
 }
 
 // ShardMetrics represents real metrics for a single shard
@@ -33,6 +30,24 @@ type ShardMetrics struct {
 	LastUpdated     time.Time `json:"last_updated"`
 	DatabaseSize    int64     `json:"database_size_bytes"`
 	TableCounts     map[string]int64 `json:"table_counts"`
+	// EntryGrowthPerMinute and TableGrowthPerMinute are the rows added per
+	// minute since the previous sample, set by the coordinator
+	EntryGrowthPerMinute float64            `json:"entry_growth_per_minute"`
+	TableGrowthPerMinute map[string]float64 `json:"table_growth_per_minute,omitempty"`
+	// SelectCount is the cumulative Com_select counter of the shard's
+	// server, to which the coordinator adds its replicas' counters
+	SelectCount int64 `json:"select_count"`
+	// ReadsPerSec is the SELECTs per second served by the shard and its
+	// replicas since the previous sample, set by the coordinator
+	ReadsPerSec float64 `json:"reads_per_second"`
+	// Replicas is the replication state of the shard's replicas, set by the
+	// coordinator
+	Replicas []ReplicaStatus `json:"replicas,omitempty"`
+	// Errors counts the statements that failed on the shard by cause, through
+	// the coordinator and the routers reporting to it, set by the coordinator
+	Errors QueryErrors `json:"errors"`
+	// NewErrors counts those that failed since the previous sample
+	NewErrors QueryErrors `json:"new_errors"`
 }
 
 // DatabaseStats represents database-specific metrics
@@ -49,17 +64,20 @@ type DatabaseStats struct {
 	InnodbRowsDeleted   int64
 }
 
-// NewRealMetricsCollector creates a new real metrics collector
-func NewRealMetricsCollector(connections map[string]*sql.DB, tableNames []string) *RealMetricsCollector {
+// NewRealMetricsCollector creates a new real metrics collector. connection
+// looks up a shard's connection pool, which the caller may replace or remove
+// while metrics are collected.
+func NewRealMetricsCollector(connection func(shardID string) (*sql.DB, bool), tableNames []string, sampler *SystemSampler) *RealMetricsCollector {
 	return &RealMetricsCollector{
-		connections: connections,
-		tableNames:  tableNames,
+		connection: connection,
+		tableNames: tableNames,
+		sampler:    sampler,
 	}
 }
 
 // CollectShardMetrics collects real metrics for a specific shard
 func (rmc *RealMetricsCollector) CollectShardMetrics(shardID string) (*ShardMetrics, error) {
-	db, exists := rmc.connections[shardID]
+	db, exists := rmc.connection(shardID)
 	if !exists {
 		return nil, fmt.Errorf("shard %s not found", shardID)
 	}
@@ -97,30 +115,20 @@ func (rmc *RealMetricsCollector) CollectShardMetrics(shardID string) (*ShardMetr
 	return metrics, nil
 }
 
-// collectSystemMetrics collects CPU, memory, and disk metrics
+// collectSystemMetrics copies the latest background CPU, memory, and disk sample
 func (rmc *RealMetricsCollector) collectSystemMetrics(metrics *ShardMetrics) error {
-	// CPU usage
-	cpuPercents, err := cpu.Percent(time.Second, false)
-	if err != nil {
-		return fmt.Errorf("failed to get CPU metrics: %w", err)
-	}
-	if len(cpuPercents) > 0 {
-		metrics.CPUPercent = cpuPercents[0]
+	if rmc.sampler == nil {
+		return fmt.Errorf("system sampler not configured")
 	}
 
-	// Memory usage
-	memInfo, err := mem.VirtualMemory()
-	if err != nil {
-		return fmt.Errorf("failed to get memory metrics: %w", err)
+	sample := rmc.sampler.Latest()
+	if sample.SampledAt.IsZero() {
+		return fmt.Errorf("no system sample available yet")
 	}
-	metrics.MemoryPercent = memInfo.UsedPercent
 
-	// Disk usage (root filesystem)
-	diskInfo, err := disk.Usage("/")
-	if err != nil {
-		return fmt.Errorf("failed to get disk metrics: %w", err)
-	}
-	metrics.DiskPercent = diskInfo.UsedPercent
+	metrics.CPUPercent = sample.CPUPercent
+	metrics.MemoryPercent = sample.MemoryPercent
+	metrics.DiskPercent = sample.DiskPercent
 
 	return nil
 }
@@ -227,8 +235,17 @@ func (rmc *RealMetricsCollector) getMySQLStatus(ctx context.Context, db *sql.DB,
 			}
 		}
 	}
-	
-	return rows.Err()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Reads are counted globally, so the coordinator can derive a rate from
+	// consecutive samples
+	var variableName, value string
+	if err := db.QueryRowContext(ctx, "SHOW GLOBAL STATUS LIKE 'Com_select'").Scan(&variableName, &value); err == nil {
+		metrics.SelectCount, _ = strconv.ParseInt(value, 10, 64)
+	}
+	return nil
 }
 
 // getMySQLUptime gets MySQL server uptime in seconds
@@ -0,0 +1,85 @@
+package metrics
+
+// Causes statements failing on a shard are classified by
+const (
+	ErrorTimeout            = "timeout"
+	ErrorDeadlock           = "deadlock"
+	ErrorSyntax             = "syntax"
+	ErrorAccessDenied       = "access_denied"
+	ErrorDiskFull           = "disk_full"
+	ErrorTooManyConnections = "too_many_connections"
+	ErrorOther              = "other"
+)
+
+// ErrorClasses lists every error class, in the order they are reported
+var ErrorClasses = []string{
+	ErrorTimeout, ErrorDeadlock, ErrorSyntax, ErrorAccessDenied,
+	ErrorDiskFull, ErrorTooManyConnections, ErrorOther,
+}
+
+// QueryErrors counts the statements that failed on a shard by cause
+type QueryErrors struct {
+	Timeouts           int64 `json:"timeouts"`
+	Deadlocks          int64 `json:"deadlocks"`
+	Syntax             int64 `json:"syntax"`
+	AccessDenied       int64 `json:"access_denied"`
+	DiskFull           int64 `json:"disk_full"`
+	TooManyConnections int64 `json:"too_many_connections"`
+	Other              int64 `json:"other"`
+}
+
+// counter returns the count of an error class; unknown classes count as other
+func (qe *QueryErrors) counter(class string) *int64 {
+	switch class {
+	case ErrorTimeout:
+		return &qe.Timeouts
+	case ErrorDeadlock:
+		return &qe.Deadlocks
+	case ErrorSyntax:
+		return &qe.Syntax
+	case ErrorAccessDenied:
+		return &qe.AccessDenied
+	case ErrorDiskFull:
+		return &qe.DiskFull
+	case ErrorTooManyConnections:
+		return &qe.TooManyConnections
+	}
+	return &qe.Other
+}
+
+// Add counts one error of a class
+func (qe *QueryErrors) Add(class string) {
+	*qe.counter(class)++
+}
+
+// Count returns the number of errors of a class
+func (qe QueryErrors) Count(class string) int64 {
+	return *qe.counter(class)
+}
+
+// Total returns the number of errors of every class
+func (qe QueryErrors) Total() int64 {
+	var total int64
+	for _, class := range ErrorClasses {
+		total += qe.Count(class)
+	}
+	return total
+}
+
+// Plus returns the sum of two counts
+func (qe QueryErrors) Plus(other QueryErrors) QueryErrors {
+	for _, class := range ErrorClasses {
+		*qe.counter(class) += other.Count(class)
+	}
+	return qe
+}
+
+// Since returns the errors counted after an earlier count of the same
+// counters. A class whose count went backwards, as when a router restarts,
+// counts none.
+func (qe QueryErrors) Since(earlier QueryErrors) QueryErrors {
+	for _, class := range ErrorClasses {
+		*qe.counter(class) = max(qe.Count(class)-earlier.Count(class), 0)
+	}
+	return qe
+}
@@ -0,0 +1,26 @@
+package metrics
+
+import "time"
+
+// ReplicaStatus is the replication state of a shard's replica, as reported
+// by SHOW REPLICA STATUS
+type ReplicaStatus struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	// Region is the region the replica runs in
+	Region string `json:"region,omitempty"`
+	// Source is the address the replica replicates from
+	Source string `json:"source,omitempty"`
+	// Healthy is set while the replica reports its lag; such replicas serve
+	// non-strong reads
+	Healthy    bool  `json:"healthy"`
+	IORunning  bool  `json:"io_running"`
+	SQLRunning bool  `json:"sql_running"`
+	LagSeconds int64 `json:"lag_seconds"`
+	// AutoPosition is set when the replica follows its source by GTID
+	AutoPosition     bool      `json:"auto_position"`
+	RetrievedGTIDSet string    `json:"retrieved_gtid_set,omitempty"`
+	ExecutedGTIDSet  string    `json:"executed_gtid_set,omitempty"`
+	LastError        string    `json:"last_error,omitempty"`
+	CheckedAt        time.Time `json:"checked_at"`
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// SystemSample holds the most recent host-level resource readings
+type SystemSample struct {
+	CPUPercent    float64
+	MemoryPercent float64
+	DiskPercent   float64
+	SampledAt     time.Time
+}
+
+// SystemSampler periodically samples host CPU, memory and disk usage in the
+// background so metric collection never blocks on cpu.Percent
+type SystemSampler struct {
+	interval time.Duration
+	latest   SystemSample
+	mutex    sync.RWMutex
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewSystemSampler creates a new sampler measuring CPU over the given interval
+func NewSystemSampler(interval time.Duration) *SystemSampler {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &SystemSampler{
+		interval: interval,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start launches the background sampling goroutine
+func (s *SystemSampler) Start() {
+	go s.run()
+}
+
+// Stop stops the background sampling goroutine
+func (s *SystemSampler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+	})
+}
+
+// Latest returns the most recent sample without blocking
+func (s *SystemSampler) Latest() SystemSample {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.latest
+}
+
+// run samples continuously until the sampler is stopped
+func (s *SystemSampler) run() {
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		default:
+		}
+
+		// cpu.Percent blocks for the whole interval, which paces the loop
+		sample, err := s.sample()
+		if err != nil {
+			log.Printf("Warning: Failed to sample system metrics: %v", err)
+			select {
+			case <-s.stopChan:
+				return
+			case <-time.After(s.interval):
+			}
+			continue
+		}
+
+		s.mutex.Lock()
+		s.latest = sample
+		s.mutex.Unlock()
+	}
+}
+
+// sample takes a single reading of CPU, memory and disk usage
+func (s *SystemSampler) sample() (SystemSample, error) {
+	sample := SystemSample{}
+
+	cpuPercents, err := cpu.Percent(s.interval, false)
+	if err != nil {
+		return sample, err
+	}
+	if len(cpuPercents) > 0 {
+		sample.CPUPercent = cpuPercents[0]
+	}
+
+	memInfo, err := mem.VirtualMemory()
+	if err != nil {
+		return sample, err
+	}
+	sample.MemoryPercent = memInfo.UsedPercent
+
+	// Disk usage (root filesystem)
+	diskInfo, err := disk.Usage("/")
+	if err != nil {
+		return sample, err
+	}
+	sample.DiskPercent = diskInfo.UsedPercent
+	sample.SampledAt = time.Now()
+
+	return sample, nil
+}
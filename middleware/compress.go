@@ -0,0 +1,256 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported response encodings
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+// encoders creates a compressing writer for each supported encoding
+var encoders = map[string]func(io.Writer) (io.WriteCloser, error){
+	EncodingGzip: func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(w), nil
+	},
+	EncodingZstd: func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	},
+}
+
+// CompressionConfig controls response compression
+type CompressionConfig struct {
+	// Encodings lists the offered encodings in order of server preference
+	Encodings []string
+	// MinSize is the smallest response body, in bytes, worth compressing
+	MinSize int
+	// Paths limits compression to these request paths; empty means all paths
+	Paths []string
+}
+
+// Compress compresses responses with the best encoding both sides support,
+// negotiated through Accept-Encoding. Bodies smaller than MinSize are sent
+// uncompressed.
+func Compress(cfg CompressionConfig) Middleware {
+	paths := make(map[string]bool, len(cfg.Paths))
+	for _, path := range cfg.Paths {
+		paths[path] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Upgraded connections speak their own protocol
+			if len(paths) > 0 && !paths[r.URL.Path] || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), cfg.Encodings)
+			if encoding == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressResponseWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				minSize:        cfg.MinSize,
+				status:         http.StatusOK,
+			}
+			defer cw.finish()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding picks the offered encoding with the highest q-value in the
+// Accept-Encoding header, breaking ties by server preference
+func negotiateEncoding(header string, offered []string) string {
+	if header == "" {
+		return ""
+	}
+
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		accepted[name] = q
+	}
+
+	type candidate struct {
+		encoding string
+		q        float64
+		rank     int
+	}
+	var candidates []candidate
+	for rank, encoding := range offered {
+		if _, supported := encoders[encoding]; !supported {
+			continue
+		}
+		q, ok := accepted[encoding]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > 0 {
+			candidates = append(candidates, candidate{encoding, q, rank})
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].q != candidates[j].q {
+			return candidates[i].q > candidates[j].q
+		}
+		return candidates[i].rank < candidates[j].rank
+	})
+	return candidates[0].encoding
+}
+
+// compressResponseWriter buffers the start of a response until it reaches the
+// minimum size, then switches to compressing everything written
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	status   int
+	buffer   []byte
+	encoder  io.WriteCloser
+	started  bool
+	hijacked bool
+}
+
+// WriteHeader records the status; it is sent once the encoding is decided
+func (cw *compressResponseWriter) WriteHeader(status int) {
+	if !cw.started {
+		cw.status = status
+	}
+}
+
+// Write buffers until minSize bytes are available, then compresses
+func (cw *compressResponseWriter) Write(b []byte) (int, error) {
+	if cw.started {
+		if cw.encoder != nil {
+			return cw.encoder.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buffer = append(cw.buffer, b...)
+	if len(cw.buffer) < cw.minSize {
+		return len(b), nil
+	}
+	if err := cw.start(true); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// start sends the headers, compressed or not, and flushes the buffer
+func (cw *compressResponseWriter) start(compress bool) error {
+	cw.started = true
+
+	// Already encoded bodies and bodiless statuses are passed through
+	if cw.Header().Get("Content-Encoding") != "" || cw.status == http.StatusNoContent || cw.status == http.StatusNotModified {
+		compress = false
+	}
+
+	if compress {
+		encoder, err := encoders[cw.encoding](cw.ResponseWriter)
+		if err != nil {
+			compress = false
+		} else {
+			cw.encoder = encoder
+			cw.Header().Set("Content-Encoding", cw.encoding)
+			cw.Header().Del("Content-Length")
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buffered := cw.buffer
+	cw.buffer = nil
+	if len(buffered) == 0 {
+		return nil
+	}
+	if cw.encoder != nil {
+		_, err := cw.encoder.Write(buffered)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buffered)
+	return err
+}
+
+// Flush sends what was written so far to the client, for streamed responses.
+// A response flushed before reaching the minimum size is compressed anyway,
+// since more is on its way.
+func (cw *compressResponseWriter) Flush() {
+	if !cw.started {
+		if err := cw.start(true); err != nil {
+			return
+		}
+	}
+	if flusher, ok := cw.encoder.(interface{ Flush() error }); ok {
+		if err := flusher.Flush(); err != nil {
+			return
+		}
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets upgraded connections (e.g. WebSockets) take over the connection,
+// which is only possible before anything was written
+func (cw *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if cw.started || len(cw.buffer) > 0 {
+		return nil, nil, fmt.Errorf("response already started, connection can't be hijacked")
+	}
+	hijacker, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		cw.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// finish writes a response that stayed under the minimum size uncompressed and
+// closes the encoder otherwise
+func (cw *compressResponseWriter) finish() {
+	if cw.hijacked {
+		return
+	}
+	if !cw.started {
+		cw.start(false)
+		return
+	}
+	if cw.encoder != nil {
+		cw.encoder.Close()
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import "testing"
+
+func TestNegotiateEncoding(t *testing.T) {
+	both := []string{EncodingZstd, EncodingGzip}
+
+	tests := []struct {
+		header  string
+		offered []string
+		want    string
+	}{
+		{"", both, ""},
+		{"gzip", both, EncodingGzip},
+		{"gzip, zstd", both, EncodingZstd},
+		{"gzip, zstd", []string{EncodingGzip, EncodingZstd}, EncodingGzip},
+		{"gzip;q=1.0, zstd;q=0.5", both, EncodingGzip},
+		{"GZIP", both, EncodingGzip},
+		{"zstd;q=0, gzip", both, EncodingGzip},
+		{"zstd;q=0, gzip;q=0", both, ""},
+		{"*", both, EncodingZstd},
+		{"*;q=0.5, gzip", both, EncodingGzip},
+		{"zstd;q=0, *", both, EncodingGzip},
+		{"br", both, ""},
+		{"br, gzip", []string{"br", EncodingGzip}, EncodingGzip},
+		{"gzip", nil, ""},
+		{"gzip;q=abc", both, EncodingGzip},
+	}
+
+	for _, test := range tests {
+		if got := negotiateEncoding(test.header, test.offered); got != test.want {
+			t.Errorf("negotiateEncoding(%q, %v) = %q, want %q", test.header, test.offered, got, test.want)
+		}
+	}
+}
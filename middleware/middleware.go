@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"sql-horizontal-autoscaler/config"
+)
+
+// Middleware wraps an http.Handler with additional behavior
+type Middleware func(http.Handler) http.Handler
+
+// RequestIDHeader is the header used to propagate request IDs
+const RequestIDHeader = "X-Request-ID"
+
+type contextKey string
+
+const requestIDKey contextKey = "request_id"
+
+// Chain applies middlewares so that the first one listed is the outermost
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// RequestIDFromContext returns the request ID stored by the RequestID middleware
+func RequestIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// RequestID reuses an incoming X-Request-ID header or generates a new ID, and
+// exposes it on the response and the request context
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if id == "" {
+				id = newRequestID()
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+			ctx := context.WithValue(r.Context(), requestIDKey, id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// newRequestID generates a random 16 byte hex request ID
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(buf)
+}
+
+// statusRecorder captures the status code and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	n, err := sr.ResponseWriter.Write(b)
+	sr.bytes += n
+	return n, err
+}
+
+// Flush sends buffered data to the client, for streamed responses
+func (sr *statusRecorder) Flush() {
+	if flusher, ok := sr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets upgraded connections (e.g. WebSockets) take over the connection
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := sr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	sr.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// accessLogEntry is a single structured access log line
+type accessLogEntry struct {
+	Service    string  `json:"service"`
+	RequestID  string  `json:"request_id"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	Bytes      int     `json:"bytes"`
+	DurationMs float64 `json:"duration_ms"`
+	RemoteAddr string  `json:"remote_addr"`
+}
+
+// AccessLog logs one JSON line per request
+func AccessLog(service string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+			entry, _ := json.Marshal(accessLogEntry{
+				Service:    service,
+				RequestID:  RequestIDFromContext(r.Context()),
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Status:     recorder.status,
+				Bytes:      recorder.bytes,
+				DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+				RemoteAddr: r.RemoteAddr,
+			})
+			log.Printf("access %s", entry)
+		})
+	}
+}
+
+// Recover turns handler panics into 500 responses instead of killing the connection
+func Recover() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if err := recover(); err != nil {
+					log.Printf("❌ Panic serving %s %s (request %s): %v\n%s",
+						r.Method, r.URL.Path, RequestIDFromContext(r.Context()), err, debug.Stack())
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CORSConfig controls cross-origin resource sharing headers
+type CORSConfig struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+}
+
+// CORS adds CORS headers for allowed origins and answers preflight requests
+func CORS(cfg CORSConfig) Middleware {
+	allowed := make(map[string]bool, len(cfg.AllowedOrigins))
+	for _, origin := range cfg.AllowedOrigins {
+		allowed[origin] = true
+	}
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin != "" && (allowed["*"] || allowed[origin]) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+				w.Header().Set("Access-Control-Expose-Headers", RequestIDHeader)
+
+				if r.Method == http.MethodOptions {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Standard returns the middleware stack shared by the autoscaler's HTTP services.
+// Compression is enabled when at least one encoding is configured.
+func Standard(service string, compression CompressionConfig, cors CORSConfig) []Middleware {
+	middlewares := []Middleware{RequestID(), AccessLog(service), Recover()}
+	if len(cors.AllowedOrigins) > 0 {
+		middlewares = append(middlewares, CORS(cors))
+	}
+	if len(compression.Encodings) > 0 {
+		middlewares = append(middlewares, Compress(compression))
+	}
+	return middlewares
+}
+
+// ForService returns the standard middleware stack of the named service as
+// the http section of the configuration sets it up
+func ForService(service string, httpConfig config.HTTPConfig) []Middleware {
+	var compression CompressionConfig
+	if httpConfig.Compression.Enabled {
+		compression = CompressionConfig{
+			Encodings: httpConfig.Compression.Encodings,
+			MinSize:   httpConfig.Compression.MinSizeBytes,
+			Paths:     httpConfig.Compression.Paths,
+		}
+	} else if httpConfig.Gzip {
+		compression = CompressionConfig{Encodings: []string{EncodingGzip}}
+	}
+
+	return Standard(service, compression, CORSConfig{
+		AllowedOrigins: httpConfig.CORSAllowedOrigins,
+		AllowedMethods: httpConfig.CORSAllowedMethods,
+		AllowedHeaders: httpConfig.CORSAllowedHeaders,
+	})
+}
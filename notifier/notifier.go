@@ -0,0 +1,96 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Alert severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Alert is a notification about a noteworthy cluster condition
+type Alert struct {
+	Severity string                 `json:"severity"`
+	Title    string                 `json:"title"`
+	Message  string                 `json:"message"`
+	Time     time.Time              `json:"time"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+}
+
+// Notifier delivers alerts to operators
+type Notifier interface {
+	Notify(alert Alert) error
+}
+
+// LogNotifier writes alerts to the application log
+type LogNotifier struct{}
+
+// Notify logs the alert
+func (n *LogNotifier) Notify(alert Alert) error {
+	log.Printf("📣 ALERT [%s] %s: %s", alert.Severity, alert.Title, alert.Message)
+	return nil
+}
+
+// WebhookNotifier posts alerts as JSON to an HTTP endpoint
+type WebhookNotifier struct {
+	URL    string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to the given URL
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify posts the alert to the webhook
+func (n *WebhookNotifier) Notify(alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	resp, err := n.client.Post(n.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post alert to %s: %w", n.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned status %d", n.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+// MultiNotifier fans an alert out to several notifiers
+type MultiNotifier []Notifier
+
+// Notify delivers the alert to every notifier, returning the first error
+func (mn MultiNotifier) Notify(alert Alert) error {
+	var firstErr error
+	for _, n := range mn {
+		if err := n.Notify(alert); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// New creates a notifier that logs every alert and posts it to the given webhooks
+func New(webhookURLs []string) Notifier {
+	notifiers := MultiNotifier{&LogNotifier{}}
+	for _, url := range webhookURLs {
+		notifiers = append(notifiers, NewWebhookNotifier(url))
+	}
+	return notifiers
+}
@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// dualTable is the table MySQL reads from in a SELECT without a FROM clause,
+// such as "SELECT DATABASE()"
+const dualTable = "dual"
+
+// IsAdmin reports whether the statement inspects the server or its schema
+// rather than reading table data: SHOW TABLES, SHOW CREATE TABLE, DESCRIBE
+// and SELECTs without a FROM clause. Any one shard can answer it.
+func (pr *ParseResult) IsAdmin() bool {
+	return pr.IsSchema() || (pr.StatementType == StatementSelect && pr.TableName == dualTable)
+}
+
+// IsSchema reports whether the statement describes the schema, which every
+// shard should answer the same way
+func (pr *ParseResult) IsSchema() bool {
+	return pr.StatementType == StatementShow || pr.StatementType == StatementDescribe
+}
+
+// parseShow handles SHOW TABLES and SHOW CREATE TABLE. The parser doesn't
+// keep the table of SHOW CREATE TABLE, so it is read from the query text.
+func parseShow(stmt *sqlparser.Show, query string) (*ParseResult, error) {
+	result := &ParseResult{}
+
+	switch strings.ToLower(stmt.Type) {
+	case "tables":
+		if stmt.ShowTablesOpt != nil {
+			result.DatabaseName = stmt.ShowTablesOpt.DbName
+		}
+	case "create table":
+		databaseName, tableName := adminTableName(query, 3)
+		if tableName == "" {
+			return result, fmt.Errorf("could not extract table name from SHOW CREATE TABLE")
+		}
+		result.DatabaseName = databaseName
+		result.TableName = tableName
+	default:
+		return result, fmt.Errorf("unsupported SHOW statement: SHOW %s", strings.ToUpper(stmt.Type))
+	}
+
+	return result, nil
+}
+
+// parseDescribe handles DESCRIBE and DESC, which the parser reads without
+// their table. EXPLAIN shares their statement type; EXPLAIN of a statement is
+// split off by SplitExplain before parsing, and any other EXPLAIN is rejected.
+func parseDescribe(query string) (*ParseResult, error) {
+	result := &ParseResult{}
+
+	keyword := strings.ToLower(strings.SplitN(strings.TrimSpace(query), " ", 2)[0])
+	if keyword != "describe" && keyword != "desc" {
+		return result, fmt.Errorf("unsupported SQL statement type")
+	}
+
+	databaseName, tableName := adminTableName(query, 1)
+	if tableName == "" {
+		return result, fmt.Errorf("could not extract table name from DESCRIBE")
+	}
+	result.DatabaseName = databaseName
+	result.TableName = tableName
+	return result, nil
+}
+
+// adminTableName extracts the database qualifier (if any) and table name
+// from the word at position index of a statement
+func adminTableName(query string, index int) (string, string) {
+	words := strings.Fields(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+	if index >= len(words) {
+		return "", ""
+	}
+
+	name := strings.ReplaceAll(words[index], "`", "")
+	if databaseName, tableName, qualified := strings.Cut(name, "."); qualified {
+		return databaseName, tableName
+	}
+	return "", name
+}
@@ -0,0 +1,179 @@
+package parser
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+// Cache remembers the parse results of recent queries, so a query the router
+// sees again skips the SQL parser. Queries are keyed by their text with runs
+// of whitespace outside quotes and comments collapsed, so the same statement
+// formatted differently shares an entry; queries differing in a literal or a
+// comment don't. The
+// least recently used entry is evicted once the cache holds its capacity.
+type Cache struct {
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+	hits     int64
+	misses   int64
+	mutex    sync.Mutex
+}
+
+// cacheEntry is a cached parse result and its key
+type cacheEntry struct {
+	key    string
+	result ParseResult
+}
+
+// CacheStats reports how well the cache is serving queries
+type CacheStats struct {
+	Capacity int     `json:"capacity"`
+	Size     int     `json:"size"`
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRate  float64 `json:"hit_rate"`
+}
+
+// NewCache creates a cache holding the parse results of up to capacity
+// queries; a capacity of 0 or less caches nothing
+func NewCache(capacity int) *Cache {
+	return &Cache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Parse parses a query like Parse, returning a copy of the cached result when
+// the query was parsed before. Queries that fail to parse aren't cached.
+func (c *Cache) Parse(query string, tableShardKeys map[string]string) (*ParseResult, error) {
+	if c == nil || c.capacity <= 0 {
+		return Parse(query, tableShardKeys)
+	}
+
+	key := normalizeQuery(query)
+	c.mutex.Lock()
+	if element, exists := c.entries[key]; exists {
+		c.order.MoveToFront(element)
+		c.hits++
+		result := element.Value.(*cacheEntry).result.clone()
+		c.mutex.Unlock()
+		return result, nil
+	}
+	c.misses++
+	c.mutex.Unlock()
+
+	result, err := Parse(query, tableShardKeys)
+	if err != nil {
+		return result, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, exists := c.entries[key]; exists {
+		// Another request parsed the same query meanwhile
+		c.order.MoveToFront(element)
+		return result, nil
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, result: *result.clone()})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	return result, nil
+}
+
+// Stats returns the cache's size and hit rate since it was created
+func (c *Cache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := CacheStats{
+		Capacity: max(c.capacity, 0),
+		Size:     c.order.Len(),
+		Hits:     c.hits,
+		Misses:   c.misses,
+	}
+	if total := c.hits + c.misses; total > 0 {
+		stats.HitRate = float64(c.hits) / float64(total)
+	}
+	return stats
+}
+
+// clone copies a parse result, so callers adjusting the one they're given
+// don't change the cached one
+func (pr *ParseResult) clone() *ParseResult {
+	result := *pr
+	if pr.ShardKeyValues != nil {
+		result.ShardKeyValues = append([]interface{}(nil), pr.ShardKeyValues...)
+	}
+	return &result
+}
+
+// normalizeQuery collapses each run of whitespace outside quoted strings,
+// identifiers and comments into a single space and trims the query. Comments
+// are kept verbatim: a line comment ends at its newline, so collapsing that
+// newline would turn the rest of the statement into comment text.
+func normalizeQuery(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	var quote byte
+	space := false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		if quote != 0 {
+			b.WriteByte(ch)
+			switch {
+			case ch == '\\' && quote != '`' && i+1 < len(query):
+				i++
+				b.WriteByte(query[i])
+			case ch == quote:
+				quote = 0
+			}
+			continue
+		}
+		switch ch {
+		case ' ', '\t', '\n', '\r', '\f', '\v':
+			space = true
+			continue
+		case '\'', '"', '`':
+			quote = ch
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+
+		if end := commentEnd(query, i); end > i {
+			b.WriteString(query[i:end])
+			i = end - 1
+			continue
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
+
+// commentEnd returns the end of the comment starting at i, including the
+// newline ending a line comment, or i when no comment starts there
+func commentEnd(query string, i int) int {
+	switch {
+	case query[i] == '#' || strings.HasPrefix(query[i:], "--"):
+		if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+			return i + end + 1
+		}
+		return len(query)
+	case strings.HasPrefix(query[i:], "/*"):
+		if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+			return i + 2 + end + 2
+		}
+		return len(query)
+	}
+	return i
+}
@@ -0,0 +1,50 @@
+package parser
+
+import "testing"
+
+// A line comment ends at its newline, so a query whose WHERE follows a
+// comment on the next line must not share a cache entry with one whose WHERE
+// is part of the comment
+func TestCacheKeepsLineCommentsApart(t *testing.T) {
+	shardKeys := map[string]string{"users": "user_id"}
+	filtered := "DELETE FROM users -- x\nWHERE user_id = 5"
+	unfiltered := "DELETE FROM users -- x WHERE user_id = 5"
+
+	if normalizeQuery(filtered) == normalizeQuery(unfiltered) {
+		t.Fatalf("queries share the cache key %q", normalizeQuery(filtered))
+	}
+
+	cache := NewCache(10)
+	result, err := cache.Parse(filtered, shardKeys)
+	if err != nil {
+		t.Fatalf("parse %q: %v", filtered, err)
+	}
+	if !result.HasWhere || !result.HasShardKey {
+		t.Fatalf("parse %q: want a WHERE on the shard key, got %+v", filtered, result)
+	}
+
+	result, err = cache.Parse(unfiltered, shardKeys)
+	if err != nil {
+		t.Fatalf("parse %q: %v", unfiltered, err)
+	}
+	if result.HasWhere || result.HasShardKey {
+		t.Fatalf("parse %q: want no WHERE, got %+v", unfiltered, result)
+	}
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"  SELECT *\n\tFROM  users ", "SELECT * FROM users"},
+		{"SELECT 'a  b' FROM users", "SELECT 'a  b' FROM users"},
+		{"SELECT 1 # one\nFROM users", "SELECT 1 # one\nFROM users"},
+		{"SELECT /* it's  */ 'a  b'", "SELECT /* it's  */ 'a  b'"},
+	}
+	for _, test := range tests {
+		if got := normalizeQuery(test.query); got != test.want {
+			t.Errorf("normalizeQuery(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
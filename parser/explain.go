@@ -0,0 +1,33 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// explainPattern matches EXPLAIN with its options and the statement explained
+var explainPattern = regexp.MustCompile(`(?is)^\s*(explain(?:\s+analyze)?(?:\s+format\s*=\s*\w+)?)\s+(.+?)\s*;?\s*$`)
+
+// SplitExplain separates "EXPLAIN [ANALYZE] [FORMAT=...] <statement>" into
+// the EXPLAIN keyword with its options and the statement explained. ok is
+// false for other queries and for EXPLAIN of a table, which is DESCRIBE.
+// EXPLAIN ANALYZE runs the statement, so it is only accepted for SELECT.
+func SplitExplain(query string) (prefix, statement string, ok bool) {
+	match := explainPattern.FindStringSubmatch(query)
+	if match == nil {
+		return "", "", false
+	}
+	prefix, statement = match[1], match[2]
+
+	keyword := strings.ToLower(strings.Fields(statement)[0])
+	switch keyword {
+	case "select":
+	case "insert", "update", "delete", "replace":
+		if strings.Contains(strings.ToLower(prefix), "analyze") {
+			return "", "", false
+		}
+	default:
+		return "", "", false
+	}
+	return prefix, statement, true
+}
@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// Filter is a column a statement filters or joins on
+type Filter struct {
+	Table  string
+	Column string
+	// Equality is set for "=" and IN comparisons, which can use any index
+	// prefix, as opposed to range comparisons
+	Equality bool
+}
+
+// Filters returns the columns compared in a statement's WHERE and JOIN
+// conditions. Unqualified columns of multi-table statements are skipped since
+// their table is ambiguous. Statement digests are accepted: their "?"
+// placeholders parse as arguments and "(...)" lists are collapsed.
+func Filters(query string) ([]Filter, error) {
+	stmt, err := sqlparser.Parse(strings.ReplaceAll(query, "(...)", "(?)"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SQL query: %w", err)
+	}
+
+	var tableExprs sqlparser.TableExprs
+	var where *sqlparser.Where
+	switch stmt := stmt.(type) {
+	case *sqlparser.Select:
+		tableExprs, where = stmt.From, stmt.Where
+	case *sqlparser.Update:
+		tableExprs, where = stmt.TableExprs, stmt.Where
+	case *sqlparser.Delete:
+		tableExprs, where = stmt.TableExprs, stmt.Where
+	default:
+		return nil, nil
+	}
+
+	fc := &filterCollector{aliases: make(map[string]string), seen: make(map[string]bool)}
+	var conditions []sqlparser.Expr
+	fc.collectTables(tableExprs, &conditions)
+	if where != nil {
+		conditions = append(conditions, where.Expr)
+	}
+	for _, condition := range conditions {
+		fc.walk(condition)
+	}
+	return fc.filters, nil
+}
+
+// filterCollector accumulates the filters of one statement
+type filterCollector struct {
+	// aliases maps table names and aliases to table names
+	aliases map[string]string
+	// only is the table of a single-table statement
+	only    string
+	filters []Filter
+	seen    map[string]bool
+}
+
+// collectTables records the statement's tables and gathers JOIN conditions
+func (fc *filterCollector) collectTables(exprs sqlparser.TableExprs, conditions *[]sqlparser.Expr) {
+	for _, expr := range exprs {
+		switch expr := expr.(type) {
+		case *sqlparser.AliasedTableExpr:
+			_, table := extractTableName(expr)
+			if table == "" {
+				continue
+			}
+			fc.aliases[table] = table
+			if !expr.As.IsEmpty() {
+				fc.aliases[expr.As.String()] = table
+			}
+		case *sqlparser.JoinTableExpr:
+			fc.collectTables(sqlparser.TableExprs{expr.LeftExpr, expr.RightExpr}, conditions)
+			if expr.Condition.On != nil {
+				*conditions = append(*conditions, expr.Condition.On)
+			}
+		case *sqlparser.ParenTableExpr:
+			fc.collectTables(expr.Exprs, conditions)
+		}
+	}
+
+	tables := make(map[string]bool)
+	for _, table := range fc.aliases {
+		tables[table] = true
+	}
+	fc.only = ""
+	if len(tables) == 1 {
+		for table := range tables {
+			fc.only = table
+		}
+	}
+}
+
+// walk records the columns compared in an expression
+func (fc *filterCollector) walk(expr sqlparser.Expr) {
+	switch expr := expr.(type) {
+	case *sqlparser.AndExpr:
+		fc.walk(expr.Left)
+		fc.walk(expr.Right)
+	case *sqlparser.OrExpr:
+		fc.walk(expr.Left)
+		fc.walk(expr.Right)
+	case *sqlparser.ParenExpr:
+		fc.walk(expr.Expr)
+	case *sqlparser.RangeCond:
+		if col, ok := expr.Left.(*sqlparser.ColName); ok {
+			fc.add(col, false)
+		}
+	case *sqlparser.ComparisonExpr:
+		var equality bool
+		switch expr.Operator {
+		case sqlparser.EqualStr, sqlparser.InStr, sqlparser.NullSafeEqualStr:
+			equality = true
+		case sqlparser.LessThanStr, sqlparser.GreaterThanStr, sqlparser.LessEqualStr, sqlparser.GreaterEqualStr:
+		default:
+			return
+		}
+		if col, ok := expr.Left.(*sqlparser.ColName); ok {
+			fc.add(col, equality)
+		}
+		if col, ok := expr.Right.(*sqlparser.ColName); ok {
+			fc.add(col, equality)
+		}
+	}
+}
+
+// add records a column, resolving its table through the statement's aliases
+func (fc *filterCollector) add(col *sqlparser.ColName, equality bool) {
+	table := fc.only
+	if qualifier := col.Qualifier.Name.String(); qualifier != "" {
+		table = fc.aliases[qualifier]
+	}
+	if table == "" {
+		return
+	}
+
+	filter := Filter{Table: table, Column: col.Name.String(), Equality: equality}
+	key := fmt.Sprintf("%s.%s.%t", filter.Table, filter.Column, filter.Equality)
+	if !fc.seen[key] {
+		fc.seen[key] = true
+		fc.filters = append(fc.filters, filter)
+	}
+}
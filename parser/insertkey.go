@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// keyPlaceholder matches a {column} reference in a shard key expression
+var keyPlaceholder = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// ExpressionColumns returns the columns referenced by a shard key expression
+// such as "{tenant_id}-{region}"
+func ExpressionColumns(expression string) []string {
+	var columns []string
+	for _, match := range keyPlaceholder.FindAllStringSubmatch(expression, -1) {
+		columns = append(columns, match[1])
+	}
+	return columns
+}
+
+// DeriveInsertShardKey adds the shard key column to an INSERT ... VALUES that
+// doesn't set it, computing each row's key from the expression with the row's
+// literal column values substituted. It returns the rewritten statement and
+// each row's key, in row order, for the caller to route the insert on.
+func DeriveInsertShardKey(query, shardKey, expression string) (string, []string, error) {
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse SQL query: %w", err)
+	}
+	insert, ok := stmt.(*sqlparser.Insert)
+	if !ok {
+		return "", nil, fmt.Errorf("not an INSERT statement")
+	}
+	rows, ok := insert.Rows.(sqlparser.Values)
+	if !ok || len(rows) == 0 {
+		return "", nil, fmt.Errorf("shard key can only be derived for INSERT ... VALUES")
+	}
+
+	columnIndex := make(map[string]int, len(insert.Columns))
+	for i, col := range insert.Columns {
+		columnIndex[strings.ToLower(col.String())] = i
+	}
+	if _, exists := columnIndex[strings.ToLower(shardKey)]; exists {
+		return "", nil, fmt.Errorf("INSERT already sets shard key %s", shardKey)
+	}
+
+	// A lone placeholder keeps the source value's type, e.g. an integer
+	single := keyPlaceholder.FindString(expression) == expression
+
+	keys := make([]string, 0, len(rows))
+	for r, row := range rows {
+		var missing error
+		key := keyPlaceholder.ReplaceAllStringFunc(expression, func(placeholder string) string {
+			column := strings.Trim(placeholder, "{}")
+			i, exists := columnIndex[strings.ToLower(column)]
+			if !exists || i >= len(row) {
+				missing = fmt.Errorf("shard key expression needs column %s, which the INSERT doesn't set", column)
+				return ""
+			}
+			value := extractLiteralValue(row[i])
+			if value == nil {
+				missing = fmt.Errorf("shard key expression needs a literal value for column %s", column)
+				return ""
+			}
+			return fmt.Sprintf("%v", value)
+		})
+		if missing != nil {
+			return "", nil, missing
+		}
+
+		keyExpr := sqlparser.Expr(sqlparser.NewStrVal([]byte(key)))
+		if single {
+			keyExpr = row[columnIndex[strings.ToLower(ExpressionColumns(expression)[0])]]
+		}
+		rows[r] = append(row, keyExpr)
+		keys = append(keys, key)
+	}
+	insert.Columns = append(insert.Columns, sqlparser.NewColIdent(shardKey))
+
+	return sqlparser.String(insert), keys, nil
+}
@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeriveInsertShardKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		expression string
+		wantQuery  string
+		wantKeys   []string
+		wantErr    bool
+	}{
+		{
+			name:       "single row, lone placeholder keeps the value",
+			query:      "INSERT INTO orders (tenant_id, total) VALUES (7, 10)",
+			expression: "{tenant_id}",
+			wantQuery:  "insert into orders(tenant_id, total, shard_key) values (7, 10, 7)",
+			wantKeys:   []string{"7"},
+		},
+		{
+			name:       "multi-row, one key per row",
+			query:      "INSERT INTO orders (tenant_id, region) VALUES (1, 'eu'), (2, 'us'), (1, 'us')",
+			expression: "{tenant_id}-{region}",
+			wantQuery:  "insert into orders(tenant_id, region, shard_key) values (1, 'eu', '1-eu'), (2, 'us', '2-us'), (1, 'us', '1-us')",
+			wantKeys:   []string{"1-eu", "2-us", "1-us"},
+		},
+		{
+			name:       "multi-row, same key",
+			query:      "INSERT INTO orders (tenant_id, total) VALUES (3, 1), (3, 2)",
+			expression: "{tenant_id}",
+			wantQuery:  "insert into orders(tenant_id, total, shard_key) values (3, 1, 3), (3, 2, 3)",
+			wantKeys:   []string{"3", "3"},
+		},
+		{
+			name:       "missing column",
+			query:      "INSERT INTO orders (total) VALUES (1)",
+			expression: "{tenant_id}",
+			wantErr:    true,
+		},
+		{
+			name:       "non-literal value in a later row",
+			query:      "INSERT INTO orders (tenant_id) VALUES (1), (NOW())",
+			expression: "{tenant_id}",
+			wantErr:    true,
+		},
+		{
+			name:       "key already set",
+			query:      "INSERT INTO orders (tenant_id, shard_key) VALUES (1, 1)",
+			expression: "{tenant_id}",
+			wantErr:    true,
+		},
+		{
+			name:       "INSERT ... SELECT",
+			query:      "INSERT INTO orders (tenant_id) SELECT tenant_id FROM staged",
+			expression: "{tenant_id}",
+			wantErr:    true,
+		},
+	}
+
+	for _, test := range tests {
+		query, keys, err := DeriveInsertShardKey(test.query, "shard_key", test.expression)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: DeriveInsertShardKey(%q) = %q, %v, want an error", test.name, test.query, query, keys)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: DeriveInsertShardKey(%q): %v", test.name, test.query, err)
+			continue
+		}
+		if query != test.wantQuery {
+			t.Errorf("%s: DeriveInsertShardKey(%q) query = %q, want %q", test.name, test.query, query, test.wantQuery)
+		}
+		if !reflect.DeepEqual(keys, test.wantKeys) {
+			t.Errorf("%s: DeriveInsertShardKey(%q) keys = %v, want %v", test.name, test.query, keys, test.wantKeys)
+		}
+	}
+}
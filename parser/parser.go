@@ -2,15 +2,58 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/xwb1989/sqlparser"
 )
 
+// Statement types recognized by the parser
+const (
+	StatementSelect   = "select"
+	StatementInsert   = "insert"
+	StatementUpdate   = "update"
+	StatementDelete   = "delete"
+	StatementTruncate = "truncate"
+	StatementDrop     = "drop"
+	StatementShow     = "show"
+	StatementDescribe = "describe"
+)
+
 // ParseResult contains the result of parsing a SQL query
 type ParseResult struct {
+	StatementType string
 	TableName    string
+	DatabaseName string
 	ShardKeyValue interface{}
 	HasShardKey  bool
+	// ShardKeyValues holds every shard key value when the query targets a
+	// known set of keys (e.g. "user_id = 1 OR user_id = 2")
+	ShardKeyValues []interface{}
+	// Distinct is set for SELECT DISTINCT queries, whose merged results must
+	// be deduplicated across shards
+	Distinct bool
+	// HasLimit is set for SELECT queries with a LIMIT
+	HasLimit bool
+	// HasWhere is set for UPDATE and DELETE statements with a WHERE clause
+	HasWhere bool
+	// ShardKeyColumn is the shard key of a SELECT's, INSERT's or UPDATE's
+	// table, if it has one
+	ShardKeyColumn string
+	// UpdatesShardKey is set for an UPDATE assigning the shard key, and
+	// NewShardKeyValue to the value assigned when it is a literal
+	UpdatesShardKey  bool
+	NewShardKeyValue interface{}
+	// AlwaysTrue is set when the WHERE clause holds for every row through
+	// literal comparisons such as "OR 1=1"
+	AlwaysTrue bool
+	// VersionColumn is set for an UPDATE the router rewrote to increment its
+	// table's version column, and ExpectedVersion to the version it checks
+	VersionColumn   string
+	ExpectedVersion interface{}
+	// Idempotent is set for a write that leaves the same rows when run
+	// again: a DELETE, or an UPDATE assigning only literal values, on one
+	// table and without a LIMIT
+	Idempotent bool
 }
 
 // Parse parses a SQL query and extracts the shard key value if present
@@ -26,19 +69,71 @@ func Parse(query string, tableShardKeys map[string]string) (*ParseResult, error)
 	// Handle different types of SQL statements
 	switch stmt := stmt.(type) {
 	case *sqlparser.Select:
-		return parseSelect(stmt, tableShardKeys)
+		result, err = parseSelect(stmt, tableShardKeys)
+		result.StatementType = StatementSelect
 	case *sqlparser.Insert:
-		return parseInsert(stmt, tableShardKeys)
+		result, err = parseInsert(stmt, tableShardKeys)
+		result.StatementType = StatementInsert
 	case *sqlparser.Update:
-		return parseUpdate(stmt, tableShardKeys)
+		result, err = parseUpdate(stmt, tableShardKeys)
+		result.StatementType = StatementUpdate
 	case *sqlparser.Delete:
-		return parseDelete(stmt, tableShardKeys)
+		result, err = parseDelete(stmt, tableShardKeys)
+		result.StatementType = StatementDelete
+	case *sqlparser.DDL:
+		if stmt.Action != sqlparser.TruncateStr && stmt.Action != sqlparser.DropStr {
+			return result, fmt.Errorf("unsupported SQL statement type")
+		}
+		result.StatementType = stmt.Action
+		result.TableName = stmt.Table.Name.String()
+		result.DatabaseName = stmt.Table.Qualifier.String()
+	case *sqlparser.Show:
+		result, err = parseShow(stmt, query)
+		result.StatementType = StatementShow
+	case *sqlparser.OtherRead:
+		result, err = parseDescribe(query)
+		result.StatementType = StatementDescribe
 	default:
 		return result, fmt.Errorf("unsupported SQL statement type")
 	}
+
+	if where := whereOf(stmt); where != nil && result != nil {
+		result.AlwaysTrue = alwaysTrue(where.Expr)
+	}
+	return result, err
+}
+
+// IsWrite reports whether the parsed statement modifies data
+func (pr *ParseResult) IsWrite() bool {
+	switch pr.StatementType {
+	case StatementSelect, StatementShow, StatementDescribe:
+		return false
+	}
+	return true
+}
+
+// IsDDL reports whether the statement is TRUNCATE or DROP, which commit
+// implicitly and can't run inside a transaction
+func (pr *ParseResult) IsDDL() bool {
+	return pr.StatementType == StatementTruncate || pr.StatementType == StatementDrop
+}
+
+// Dangerous describes why a statement affects every row of its table, or
+// returns "" for statements limited by a WHERE clause
+func (pr *ParseResult) Dangerous() string {
+	switch {
+	case pr.StatementType == StatementTruncate:
+		return "TRUNCATE"
+	case pr.StatementType == StatementDrop:
+		return "DROP"
+	case (pr.StatementType == StatementUpdate || pr.StatementType == StatementDelete) && !pr.HasWhere:
+		return strings.ToUpper(pr.StatementType) + " without a WHERE clause"
+	}
+	return ""
 }
 
-// parseSelect handles SELECT statements
+// parseSelect handles SELECT statements, descending into a derived table in
+// the FROM clause and into subqueries compared with the shard key
 func parseSelect(stmt *sqlparser.Select, tableShardKeys map[string]string) (*ParseResult, error) {
 	result := &ParseResult{}
 
@@ -47,43 +142,104 @@ func parseSelect(stmt *sqlparser.Select, tableShardKeys map[string]string) (*Par
 		return result, fmt.Errorf("no FROM clause found")
 	}
 
-	tableName := extractTableName(stmt.From[0])
+	if derived, ok := derivedTable(stmt.From[0]); ok {
+		return parseDerivedSelect(stmt, derived, tableShardKeys)
+	}
+
+	databaseName, tableName := extractTableName(stmt.From[0])
 	if tableName == "" {
 		return result, fmt.Errorf("could not extract table name")
 	}
 
 	result.TableName = tableName
+	result.DatabaseName = databaseName
+	result.Distinct = stmt.Distinct != ""
+	result.HasLimit = stmt.Limit != nil
 
 	// Check if this table has a shard key configured
-	shardKey, exists := tableShardKeys[tableName]
+	shardKey, exists := lookupShardKey(tableShardKeys, databaseName, tableName)
 	if !exists {
 		return result, nil // No shard key configured for this table
 	}
+	result.ShardKeyColumn = shardKey
 
 	// Extract shard key value from WHERE clause
 	if stmt.Where != nil {
-		shardKeyValue := extractShardKeyValue(stmt.Where.Expr, shardKey)
-		if shardKeyValue != nil {
-			result.ShardKeyValue = shardKeyValue
-			result.HasShardKey = true
-		}
+		setShardKeyValues(result, extractShardKeyValues(stmt.Where.Expr, shardKey))
 	}
 
 	return result, nil
 }
 
+// parseDerivedSelect handles a SELECT reading from a derived table, which
+// takes the table and shard key values of the derived table's query. A
+// restriction of the outer WHERE clause on the shard key is used when the
+// derived table reads a table directly and passes the shard key through under
+// its own name.
+func parseDerivedSelect(stmt *sqlparser.Select, derived *sqlparser.Select, tableShardKeys map[string]string) (*ParseResult, error) {
+	result, err := parseSelect(derived, tableShardKeys)
+	if err != nil {
+		return result, err
+	}
+	result.Distinct = stmt.Distinct != ""
+	result.HasLimit = stmt.Limit != nil
+
+	if len(result.ShardKeyValues) == 0 && result.ShardKeyColumn != "" && stmt.Where != nil &&
+		fromTable(derived) && selectsColumn(derived, result.ShardKeyColumn) {
+		setShardKeyValues(result, extractShardKeyValues(stmt.Where.Expr, result.ShardKeyColumn))
+	}
+	return result, nil
+}
+
+// derivedTable returns the query of a derived table in a FROM clause, if the
+// table expression is one built from a plain SELECT
+func derivedTable(tableExpr sqlparser.TableExpr) (*sqlparser.Select, bool) {
+	aliased, ok := tableExpr.(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return nil, false
+	}
+	subquery, ok := aliased.Expr.(*sqlparser.Subquery)
+	if !ok {
+		return nil, false
+	}
+	sel, ok := subquery.Select.(*sqlparser.Select)
+	return sel, ok
+}
+
+// selectsColumn reports whether a SELECT returns a column under its own name,
+// through * or by naming it without an alias
+func selectsColumn(sel *sqlparser.Select, column string) bool {
+	for _, expr := range sel.SelectExprs {
+		switch expr := expr.(type) {
+		case *sqlparser.StarExpr:
+			return true
+		case *sqlparser.AliasedExpr:
+			col, ok := expr.Expr.(*sqlparser.ColName)
+			if !ok || col.Name.String() != column {
+				continue
+			}
+			if expr.As.IsEmpty() || expr.As.String() == column {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // parseInsert handles INSERT statements
 func parseInsert(stmt *sqlparser.Insert, tableShardKeys map[string]string) (*ParseResult, error) {
 	result := &ParseResult{}
 
 	tableName := stmt.Table.Name.String()
 	result.TableName = tableName
+	result.DatabaseName = stmt.Table.Qualifier.String()
 
 	// Check if this table has a shard key configured
-	shardKey, exists := tableShardKeys[tableName]
+	shardKey, exists := lookupShardKey(tableShardKeys, result.DatabaseName, tableName)
 	if !exists {
 		return result, nil
 	}
+	result.ShardKeyColumn = shardKey
 
 	// For INSERT statements, we need to find the shard key in the column list
 	if rows, ok := stmt.Rows.(sqlparser.Values); ok && len(rows) > 0 {
@@ -93,8 +249,7 @@ func parseInsert(stmt *sqlparser.Insert, tableShardKeys map[string]string) (*Par
 				// Extract the value from the first row
 				if i < len(rows[0]) {
 					if val := extractLiteralValue(rows[0][i]); val != nil {
-						result.ShardKeyValue = val
-						result.HasShardKey = true
+						setShardKeyValues(result, []interface{}{val})
 					}
 				}
 				break
@@ -109,25 +264,33 @@ func parseInsert(stmt *sqlparser.Insert, tableShardKeys map[string]string) (*Par
 func parseUpdate(stmt *sqlparser.Update, tableShardKeys map[string]string) (*ParseResult, error) {
 	result := &ParseResult{}
 
-	tableName := extractTableName(stmt.TableExprs[0])
+	databaseName, tableName := extractTableName(stmt.TableExprs[0])
 	if tableName == "" {
 		return result, fmt.Errorf("could not extract table name from UPDATE")
 	}
 	result.TableName = tableName
+	result.DatabaseName = databaseName
+	result.HasWhere = stmt.Where != nil
+	result.Idempotent = len(stmt.TableExprs) == 1 && stmt.Limit == nil && assignsLiterals(stmt.Exprs)
 
 	// Check if this table has a shard key configured
-	shardKey, exists := tableShardKeys[tableName]
+	shardKey, exists := lookupShardKey(tableShardKeys, databaseName, tableName)
 	if !exists {
 		return result, nil
 	}
+	result.ShardKeyColumn = shardKey
+
+	// Assigning the shard key may leave the row on a shard that no longer owns it
+	for _, assignment := range stmt.Exprs {
+		if strings.EqualFold(assignment.Name.Name.String(), shardKey) {
+			result.UpdatesShardKey = true
+			result.NewShardKeyValue = extractLiteralValue(assignment.Expr)
+		}
+	}
 
 	// Extract shard key value from WHERE clause
 	if stmt.Where != nil {
-		shardKeyValue := extractShardKeyValue(stmt.Where.Expr, shardKey)
-		if shardKeyValue != nil {
-			result.ShardKeyValue = shardKeyValue
-			result.HasShardKey = true
-		}
+		setShardKeyValues(result, extractShardKeyValues(stmt.Where.Expr, shardKey))
 	}
 
 	return result, nil
@@ -137,64 +300,167 @@ func parseUpdate(stmt *sqlparser.Update, tableShardKeys map[string]string) (*Par
 func parseDelete(stmt *sqlparser.Delete, tableShardKeys map[string]string) (*ParseResult, error) {
 	result := &ParseResult{}
 
-	tableName := extractTableName(stmt.TableExprs[0])
+	databaseName, tableName := extractTableName(stmt.TableExprs[0])
 	if tableName == "" {
 		return result, fmt.Errorf("could not extract table name from DELETE")
 	}
 	result.TableName = tableName
+	result.DatabaseName = databaseName
+	result.HasWhere = stmt.Where != nil
+	result.Idempotent = len(stmt.TableExprs) == 1 && len(stmt.Targets) == 0 && stmt.Limit == nil
 
 	// Check if this table has a shard key configured
-	shardKey, exists := tableShardKeys[tableName]
+	shardKey, exists := lookupShardKey(tableShardKeys, databaseName, tableName)
 	if !exists {
 		return result, nil
 	}
 
 	// Extract shard key value from WHERE clause
 	if stmt.Where != nil {
-		shardKeyValue := extractShardKeyValue(stmt.Where.Expr, shardKey)
-		if shardKeyValue != nil {
-			result.ShardKeyValue = shardKeyValue
-			result.HasShardKey = true
-		}
+		setShardKeyValues(result, extractShardKeyValues(stmt.Where.Expr, shardKey))
 	}
 
 	return result, nil
 }
 
-// extractTableName extracts the table name from a TableExpr
-func extractTableName(tableExpr sqlparser.TableExpr) string {
+// assignsLiterals reports whether an UPDATE's assignments are all literal
+// values, so a row it updated is left as is when it runs again
+func assignsLiterals(exprs sqlparser.UpdateExprs) bool {
+	for _, assignment := range exprs {
+		switch assignment.Expr.(type) {
+		case *sqlparser.SQLVal, *sqlparser.NullVal, sqlparser.BoolVal:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// extractTableName extracts the database qualifier (if any) and table name from a TableExpr
+func extractTableName(tableExpr sqlparser.TableExpr) (string, string) {
 	switch table := tableExpr.(type) {
 	case *sqlparser.AliasedTableExpr:
 		if tableName, ok := table.Expr.(sqlparser.TableName); ok {
-			return tableName.Name.String()
+			return tableName.Qualifier.String(), tableName.Name.String()
 		}
 	}
-	return ""
+	return "", ""
 }
 
-// extractShardKeyValue recursively searches for the shard key in the WHERE expression
-func extractShardKeyValue(expr sqlparser.Expr, shardKey string) interface{} {
+// lookupShardKey finds the shard key for a table, preferring a "db.table" entry
+// over a plain table entry
+func lookupShardKey(tableShardKeys map[string]string, databaseName, tableName string) (string, bool) {
+	if databaseName != "" {
+		if shardKey, exists := tableShardKeys[databaseName+"."+tableName]; exists {
+			return shardKey, true
+		}
+	}
+	shardKey, exists := tableShardKeys[tableName]
+	return shardKey, exists
+}
+
+// setShardKeyValues records the extracted shard key values on the result. A
+// single value makes the query a single-shard query.
+func setShardKeyValues(result *ParseResult, values []interface{}) {
+	if len(values) == 0 {
+		return
+	}
+	result.ShardKeyValues = values
+	if len(values) == 1 {
+		result.ShardKeyValue = values[0]
+		result.HasShardKey = true
+	}
+}
+
+// extractShardKeyValues recursively searches for the shard key in the WHERE
+// expression and returns the set of values the query is restricted to
+func extractShardKeyValues(expr sqlparser.Expr, shardKey string) []interface{} {
 	switch expr := expr.(type) {
 	case *sqlparser.ComparisonExpr:
 		// Check if this is a comparison with our shard key
-		if colName, ok := expr.Left.(*sqlparser.ColName); ok {
-			if colName.Name.String() == shardKey && expr.Operator == "=" {
-				return extractLiteralValue(expr.Right)
+		colName, ok := expr.Left.(*sqlparser.ColName)
+		if !ok || colName.Name.String() != shardKey {
+			return nil
+		}
+		if subquery, ok := expr.Right.(*sqlparser.Subquery); ok && (expr.Operator == sqlparser.EqualStr || expr.Operator == sqlparser.InStr) {
+			return subqueryKeyValues(subquery)
+		}
+		switch expr.Operator {
+		case sqlparser.EqualStr:
+			if val := extractLiteralValue(expr.Right); val != nil {
+				return []interface{}{val}
+			}
+		case sqlparser.InStr:
+			tuple, ok := expr.Right.(sqlparser.ValTuple)
+			if !ok {
+				return nil
 			}
+			values := make([]interface{}, 0, len(tuple))
+			for _, item := range tuple {
+				val := extractLiteralValue(item)
+				if val == nil {
+					return nil
+				}
+				values = append(values, val)
+			}
+			return dedupeValues(values)
 		}
 	case *sqlparser.AndExpr:
 		// Recursively check both sides of AND
-		if val := extractShardKeyValue(expr.Left, shardKey); val != nil {
-			return val
+		if vals := extractShardKeyValues(expr.Left, shardKey); vals != nil {
+			return vals
 		}
-		return extractShardKeyValue(expr.Right, shardKey)
+		return extractShardKeyValues(expr.Right, shardKey)
 	case *sqlparser.OrExpr:
-		// For OR expressions, we can't determine a single shard
-		return nil
+		// An OR only narrows the shards if every branch restricts the shard key
+		left := extractShardKeyValues(expr.Left, shardKey)
+		if left == nil {
+			return nil
+		}
+		right := extractShardKeyValues(expr.Right, shardKey)
+		if right == nil {
+			return nil
+		}
+		return dedupeValues(append(left, right...))
+	case *sqlparser.ParenExpr:
+		return extractShardKeyValues(expr.Expr, shardKey)
 	}
 	return nil
 }
 
+// subqueryKeyValues returns the values a subquery compared with the shard key
+// can return: those its WHERE clause restricts its single selected column to,
+// as in "user_id IN (SELECT user_id FROM users WHERE user_id = 5)"
+func subqueryKeyValues(subquery *sqlparser.Subquery) []interface{} {
+	sel, ok := subquery.Select.(*sqlparser.Select)
+	if !ok || len(sel.SelectExprs) != 1 || sel.Where == nil {
+		return nil
+	}
+	expr, ok := sel.SelectExprs[0].(*sqlparser.AliasedExpr)
+	if !ok {
+		return nil
+	}
+	col, ok := expr.Expr.(*sqlparser.ColName)
+	if !ok {
+		return nil
+	}
+	return extractShardKeyValues(sel.Where.Expr, col.Name.String())
+}
+
+// dedupeValues removes duplicate values while preserving order
+func dedupeValues(values []interface{}) []interface{} {
+	seen := make(map[string]bool, len(values))
+	result := make([]interface{}, 0, len(values))
+	for _, val := range values {
+		key := fmt.Sprintf("%v", val)
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, val)
+		}
+	}
+	return result
+}
+
 // extractLiteralValue extracts the actual value from a literal expression
 func extractLiteralValue(expr sqlparser.Expr) interface{} {
 	switch val := expr.(type) {
@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseShardKeyValues(t *testing.T) {
+	shardKeys := map[string]string{"users": "user_id", "orders": "user_id"}
+
+	tests := []struct {
+		query string
+		want  []interface{}
+	}{
+		{"SELECT * FROM users WHERE user_id = 5", []interface{}{"5"}},
+		{"SELECT * FROM users WHERE user_id = 1 OR user_id = 2", []interface{}{"1", "2"}},
+		{"SELECT * FROM users WHERE (user_id = 1 OR user_id = 2) AND name = 'a'", []interface{}{"1", "2"}},
+		{"SELECT * FROM users WHERE user_id = 1 OR user_id = 1", []interface{}{"1"}},
+		{"SELECT * FROM users WHERE user_id = 1 OR name = 'a'", nil},
+		{"SELECT * FROM users WHERE user_id IN (3, 1, 3)", []interface{}{"3", "1"}},
+		{"SELECT * FROM users WHERE user_id IN (1, NOW())", nil},
+		{"SELECT * FROM users WHERE user_id IN (1, 2) OR user_id = 3", []interface{}{"1", "2", "3"}},
+		{"SELECT * FROM users WHERE user_id > 5", nil},
+		{"SELECT * FROM orders WHERE user_id IN (SELECT user_id FROM users WHERE user_id = 7)", []interface{}{"7"}},
+		{"SELECT * FROM orders WHERE user_id = (SELECT id FROM users WHERE id IN (8, 9))", []interface{}{"8", "9"}},
+		{"SELECT * FROM orders WHERE user_id IN (SELECT user_id FROM users)", nil},
+		{"SELECT * FROM orders WHERE user_id IN (SELECT user_id FROM users WHERE name = 'a')", nil},
+		{"UPDATE users SET name = 'b' WHERE user_id = 1 OR user_id = 2", []interface{}{"1", "2"}},
+		{"DELETE FROM users WHERE user_id IN (4, 5)", []interface{}{"4", "5"}},
+	}
+
+	for _, test := range tests {
+		result, err := Parse(test.query, shardKeys)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result.ShardKeyValues, test.want) {
+			t.Errorf("Parse(%q) shard key values = %v, want %v", test.query, result.ShardKeyValues, test.want)
+		}
+		if single := len(test.want) == 1; result.HasShardKey != single {
+			t.Errorf("Parse(%q) HasShardKey = %v, want %v", test.query, result.HasShardKey, single)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// RewriteOptions controls the AST transformations applied before execution
+type RewriteOptions struct {
+	// StripColumns are left out when SELECT * is expanded (e.g. shard_info)
+	StripColumns []string
+	// InjectColumns are added to the select list if not already selected
+	InjectColumns []string
+	// TableColumns lists the known columns per table, used to expand SELECT *
+	TableColumns []string
+	// PushDownLimit rewrites LIMIT n OFFSET m into LIMIT m+n for each shard
+	PushDownLimit bool
+}
+
+// RewriteResult contains the rewritten query and what the merge stage must undo
+type RewriteResult struct {
+	Query           string
+	InjectedColumns []string
+	// Offset and Limit must be applied to the merged rows when the limit was
+	// pushed down; Limit is -1 if there was nothing to push down
+	Offset     int
+	Limit      int
+	HasOrderBy bool
+	// StopAtLimit is set when any Offset+Limit rows collected from the shards
+	// form a valid answer, so the scatter can stop once that many arrived. It
+	// is not set for DISTINCT, grouped or aggregated queries.
+	StopAtLimit bool
+}
+
+// Rewrite applies the rewrite options to a SELECT query. Other statements are
+// returned unchanged.
+func Rewrite(query string, opts RewriteOptions) (*RewriteResult, error) {
+	result := &RewriteResult{Query: query, Limit: -1}
+
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SQL query: %w", err)
+	}
+
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok {
+		return result, nil
+	}
+
+	result.HasOrderBy = len(sel.OrderBy) > 0
+
+	// Table columns don't describe the columns of a derived table
+	if fromTable(sel) {
+		if len(opts.TableColumns) > 0 {
+			expandStar(sel, opts.TableColumns, opts.StripColumns)
+		}
+		result.InjectedColumns = injectColumns(sel, opts.InjectColumns)
+	}
+
+	// Without ORDER BY any N rows are valid, so each shard only needs offset+N
+	if opts.PushDownLimit && sel.Limit != nil && !result.HasOrderBy {
+		offset, limit, ok := limitValues(sel.Limit)
+		if ok {
+			sel.Limit = &sqlparser.Limit{
+				Rowcount: sqlparser.NewIntVal([]byte(strconv.Itoa(offset + limit))),
+			}
+			result.Offset = offset
+			result.Limit = limit
+			result.StopAtLimit = sel.Distinct == "" && len(sel.GroupBy) == 0 && sel.Having == nil && !containsAggregate(sel.SelectExprs)
+		}
+	}
+
+	result.Query = sqlparser.String(sel)
+	return result, nil
+}
+
+// fromTable reports whether a SELECT reads from a single table rather than a
+// join or derived table
+func fromTable(sel *sqlparser.Select) bool {
+	if len(sel.From) != 1 {
+		return false
+	}
+	aliased, ok := sel.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return false
+	}
+	_, ok = aliased.Expr.(sqlparser.TableName)
+	return ok
+}
+
+// expandStar replaces an unqualified or table-qualified * with an explicit
+// column list, leaving out stripped columns
+func expandStar(sel *sqlparser.Select, columns []string, strip []string) {
+
+	stripped := make(map[string]bool, len(strip))
+	for _, col := range strip {
+		stripped[col] = true
+	}
+
+	exprs := make(sqlparser.SelectExprs, 0, len(sel.SelectExprs))
+	for _, expr := range sel.SelectExprs {
+		star, ok := expr.(*sqlparser.StarExpr)
+		if !ok {
+			exprs = append(exprs, expr)
+			continue
+		}
+
+		for _, col := range columns {
+			if stripped[col] {
+				continue
+			}
+			exprs = append(exprs, &sqlparser.AliasedExpr{
+				Expr: &sqlparser.ColName{Name: sqlparser.NewColIdent(col), Qualifier: star.TableName},
+			})
+		}
+	}
+	sel.SelectExprs = exprs
+}
+
+// injectColumns appends columns to the select list that are not already
+// selected, returning the names of the injected columns
+func injectColumns(sel *sqlparser.Select, columns []string) []string {
+	if len(columns) == 0 {
+		return nil
+	}
+
+	selected := make(map[string]bool)
+	for _, expr := range sel.SelectExprs {
+		switch expr := expr.(type) {
+		case *sqlparser.StarExpr:
+			// Every column is already selected
+			return nil
+		case *sqlparser.AliasedExpr:
+			if !expr.As.IsEmpty() {
+				selected[expr.As.String()] = true
+			} else if col, ok := expr.Expr.(*sqlparser.ColName); ok {
+				selected[col.Name.String()] = true
+			}
+		}
+	}
+
+	// Extra columns would change grouped, aggregated or DISTINCT results
+	if len(sel.GroupBy) > 0 || sel.Distinct != "" || containsAggregate(sel.SelectExprs) {
+		return nil
+	}
+
+	var injected []string
+	for _, col := range columns {
+		if selected[col] {
+			continue
+		}
+		sel.SelectExprs = append(sel.SelectExprs, &sqlparser.AliasedExpr{
+			Expr: &sqlparser.ColName{Name: sqlparser.NewColIdent(col)},
+		})
+		injected = append(injected, col)
+	}
+	return injected
+}
+
+// containsAggregate reports whether any select expression is an aggregate call
+func containsAggregate(exprs sqlparser.SelectExprs) bool {
+	for _, expr := range exprs {
+		aliased, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			continue
+		}
+		if fn, ok := aliased.Expr.(*sqlparser.FuncExpr); ok && fn.IsAggregate() {
+			return true
+		}
+	}
+	return false
+}
+
+// limitValues returns the integer offset and row count of a LIMIT clause
+func limitValues(limit *sqlparser.Limit) (int, int, bool) {
+	rowcount, ok := intLiteral(limit.Rowcount)
+	if !ok {
+		return 0, 0, false
+	}
+
+	offset := 0
+	if limit.Offset != nil {
+		if offset, ok = intLiteral(limit.Offset); !ok {
+			return 0, 0, false
+		}
+	}
+
+	return offset, rowcount, true
+}
+
+// intLiteral returns the value of an integer literal expression
+func intLiteral(expr sqlparser.Expr) (int, bool) {
+	val, ok := expr.(*sqlparser.SQLVal)
+	if !ok || val.Type != sqlparser.IntVal {
+		return 0, false
+	}
+	n, err := strconv.Atoi(string(val.Val))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// whereOf returns the WHERE clause of a SELECT, UPDATE or DELETE, or nil
+func whereOf(stmt sqlparser.Statement) *sqlparser.Where {
+	switch stmt := stmt.(type) {
+	case *sqlparser.Select:
+		return stmt.Where
+	case *sqlparser.Update:
+		return stmt.Where
+	case *sqlparser.Delete:
+		return stmt.Where
+	}
+	return nil
+}
+
+// alwaysTrue reports whether a condition holds for every row through
+// comparisons of literals or of a column with itself, such as "1=1" or
+// "'a'='a'", alone or in an OR. Such conditions rarely come from an
+// application and are a common sign of SQL injection.
+func alwaysTrue(expr sqlparser.Expr) bool {
+	switch expr := expr.(type) {
+	case *sqlparser.OrExpr:
+		return alwaysTrue(expr.Left) || alwaysTrue(expr.Right)
+	case *sqlparser.AndExpr:
+		return alwaysTrue(expr.Left) && alwaysTrue(expr.Right)
+	case *sqlparser.ParenExpr:
+		return alwaysTrue(expr.Expr)
+	case sqlparser.BoolVal:
+		return bool(expr)
+	case *sqlparser.SQLVal:
+		return expr.Type == sqlparser.IntVal && strings.Trim(string(expr.Val), "0") != ""
+	case *sqlparser.ComparisonExpr:
+		if expr.Operator != sqlparser.EqualStr && expr.Operator != sqlparser.NullSafeEqualStr {
+			return false
+		}
+		return sameLiteral(expr.Left, expr.Right) || sameColumn(expr.Left, expr.Right)
+	}
+	return false
+}
+
+// sameLiteral reports whether two expressions are the same literal
+func sameLiteral(left, right sqlparser.Expr) bool {
+	l, lok := left.(*sqlparser.SQLVal)
+	r, rok := right.(*sqlparser.SQLVal)
+	return lok && rok && l.Type == r.Type && l.Type != sqlparser.ValArg && bytes.Equal(l.Val, r.Val)
+}
+
+// sameColumn reports whether two expressions are the same column
+func sameColumn(left, right sqlparser.Expr) bool {
+	l, lok := left.(*sqlparser.ColName)
+	r, rok := right.(*sqlparser.ColName)
+	return lok && rok && sqlparser.String(l) == sqlparser.String(r)
+}
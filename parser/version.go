@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// ApplyVersionCheck rewrites an UPDATE of a table with a version column to
+// increment the version, and to only match rows still at the expected version.
+// Without an expected version, one the WHERE clause already pins with
+// "version = <literal>" is checked instead. It returns the rewritten statement
+// and the version checked, nil if none is.
+func ApplyVersionCheck(query, column string, expected *int64) (string, interface{}, error) {
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse SQL query: %w", err)
+	}
+	update, ok := stmt.(*sqlparser.Update)
+	if !ok {
+		return "", nil, fmt.Errorf("not an UPDATE statement")
+	}
+
+	for _, assignment := range update.Exprs {
+		if strings.EqualFold(assignment.Name.Name.String(), column) {
+			return "", nil, fmt.Errorf("version column %s is maintained by the router and cannot be set", column)
+		}
+	}
+
+	versionColumn := &sqlparser.ColName{Name: sqlparser.NewColIdent(column)}
+	update.Exprs = append(update.Exprs, &sqlparser.UpdateExpr{
+		Name: versionColumn,
+		Expr: &sqlparser.BinaryExpr{Operator: sqlparser.PlusStr, Left: versionColumn, Right: sqlparser.NewIntVal([]byte("1"))},
+	})
+
+	var version interface{}
+	if expected != nil {
+		version = strconv.FormatInt(*expected, 10)
+		check := &sqlparser.ComparisonExpr{
+			Operator: sqlparser.EqualStr,
+			Left:     versionColumn,
+			Right:    sqlparser.NewIntVal([]byte(version.(string))),
+		}
+		if update.Where == nil {
+			update.Where = sqlparser.NewWhere(sqlparser.WhereStr, check)
+		} else {
+			update.Where.Expr = &sqlparser.AndExpr{Left: &sqlparser.ParenExpr{Expr: update.Where.Expr}, Right: check}
+		}
+	} else if update.Where != nil {
+		if values := extractShardKeyValues(update.Where.Expr, column); len(values) == 1 {
+			version = values[0]
+		}
+	}
+
+	return sqlparser.String(update), version, nil
+}
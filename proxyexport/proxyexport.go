@@ -0,0 +1,205 @@
+package proxyexport
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// Export formats
+const (
+	// FormatProxySQL renders mysql_servers rows and mysql_query_rules
+	FormatProxySQL = "proxysql"
+	// FormatHAProxy renders one backend section per shard
+	FormatHAProxy = "haproxy"
+)
+
+// commentTag marks the ProxySQL rows the exporter owns, so it only ever
+// replaces its own servers and rules
+const commentTag = "sql-horizontal-autoscaler"
+
+// header starts every rendered file
+const header = "Generated by sql-horizontal-autoscaler from the shard topology; do not edit"
+
+// Backend is a shard the proxy routes to
+type Backend struct {
+	ShardID string
+	Host    string
+	Port    int
+}
+
+// Options shape the rendered configuration
+type Options struct {
+	// HostgroupBase is the first ProxySQL hostgroup; shard-N gets
+	// HostgroupBase + N and shards without a number the ones after
+	HostgroupBase int
+	// RuleIDBase is the first rule_id of the exported query rules
+	RuleIDBase int
+	// BackendName prefixes the HAProxy backend of each shard
+	BackendName string
+}
+
+// Hostgroups assigns each shard its ProxySQL hostgroup, stable across scale
+// events for shards named by number
+func Hostgroups(backends []Backend, base int) map[string]int {
+	hostgroups := make(map[string]int, len(backends))
+	next := base + 1
+	var unnumbered []string
+	for _, backend := range backends {
+		if num := shardNumber(backend.ShardID); num > 0 {
+			hostgroups[backend.ShardID] = base + num
+			if base+num >= next {
+				next = base + num + 1
+			}
+		} else {
+			unnumbered = append(unnumbered, backend.ShardID)
+		}
+	}
+	sort.Strings(unnumbered)
+	for _, shardID := range unnumbered {
+		hostgroups[shardID] = next
+		next++
+	}
+	return hostgroups
+}
+
+// ProxySQLStatements returns the admin statements replacing the exporter's
+// servers and query rules with the given backends and loading them. Each
+// shard gets its own hostgroup, and a rule sends statements carrying a
+// "shard=<id>" comment to it.
+func ProxySQLStatements(backends []Backend, opts Options) []string {
+	backends = sorted(backends)
+	hostgroups := Hostgroups(backends, opts.HostgroupBase)
+
+	statements := []string{
+		fmt.Sprintf("DELETE FROM mysql_servers WHERE comment LIKE '%s:%%'", commentTag),
+		fmt.Sprintf("DELETE FROM mysql_query_rules WHERE comment LIKE '%s:%%'", commentTag),
+	}
+	for _, backend := range backends {
+		statements = append(statements, fmt.Sprintf(
+			"INSERT INTO mysql_servers (hostgroup_id, hostname, port, comment) VALUES (%d, %s, %d, %s)",
+			hostgroups[backend.ShardID], quote(backend.Host), backend.Port, quote(commentTag+":"+backend.ShardID)))
+	}
+	for i, backend := range backends {
+		pattern := `shard=` + regexp.QuoteMeta(backend.ShardID) + `\b`
+		statements = append(statements, fmt.Sprintf(
+			"INSERT INTO mysql_query_rules (rule_id, active, match_pattern, destination_hostgroup, apply, comment) VALUES (%d, 1, %s, %d, 1, %s)",
+			opts.RuleIDBase+i, quote(pattern), hostgroups[backend.ShardID], quote(commentTag+":"+backend.ShardID)))
+	}
+	return append(statements,
+		"LOAD MYSQL SERVERS TO RUNTIME",
+		"SAVE MYSQL SERVERS TO DISK",
+		"LOAD MYSQL QUERY RULES TO RUNTIME",
+		"SAVE MYSQL QUERY RULES TO DISK",
+	)
+}
+
+// Render returns the configuration of the backends in a format, as a file
+func Render(format string, backends []Backend, opts Options) (string, error) {
+	var b strings.Builder
+	switch format {
+	case FormatProxySQL:
+		fmt.Fprintf(&b, "-- %s\n", header)
+		for _, statement := range ProxySQLStatements(backends, opts) {
+			fmt.Fprintf(&b, "%s;\n", statement)
+		}
+	case FormatHAProxy:
+		fmt.Fprintf(&b, "# %s\n", header)
+		for _, backend := range sorted(backends) {
+			fmt.Fprintf(&b, "\nbackend %s_%s\n", opts.BackendName, backend.ShardID)
+			b.WriteString("    mode tcp\n")
+			fmt.Fprintf(&b, "    server %s %s check\n", backend.ShardID, net.JoinHostPort(backend.Host, strconv.Itoa(backend.Port)))
+		}
+	default:
+		return "", fmt.Errorf("unknown proxy config format %q", format)
+	}
+	return b.String(), nil
+}
+
+// WriteFile replaces a file with the rendered configuration atomically, so a
+// proxy reloading concurrently never reads half of it
+func WriteFile(path, content string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp.Name(), err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set mode of %s: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// PushProxySQL runs the statements on a ProxySQL admin interface in order
+func PushProxySQL(adminDSN string, statements []string, timeout time.Duration) error {
+	db, err := sql.Open("mysql", adminDSN)
+	if err != nil {
+		return fmt.Errorf("failed to open ProxySQL admin connection: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// The admin interface has no transactions; use one connection so the
+	// statements run in order
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to ProxySQL admin: %w", err)
+	}
+	defer conn.Close()
+
+	for _, statement := range statements {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("ProxySQL admin rejected %q: %w", statement, err)
+		}
+	}
+	return nil
+}
+
+// sorted returns the backends in shard ID order
+func sorted(backends []Backend) []Backend {
+	backends = append([]Backend{}, backends...)
+	sort.Slice(backends, func(i, j int) bool { return backends[i].ShardID < backends[j].ShardID })
+	return backends
+}
+
+// shardNumber returns N for a shard ID ending in "-N", or 0
+func shardNumber(shardID string) int {
+	idx := strings.LastIndex(shardID, "-")
+	if idx < 0 {
+		return 0
+	}
+	num, err := strconv.Atoi(shardID[idx+1:])
+	if err != nil || num < 0 {
+		return 0
+	}
+	return num
+}
+
+// quote returns a string literal for the admin interface, which is SQLite
+// underneath and takes backslashes literally
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
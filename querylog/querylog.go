@@ -0,0 +1,186 @@
+package querylog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutputStdout writes entries to standard output instead of a file
+const OutputStdout = "stdout"
+
+// Entry is a single logged query
+type Entry struct {
+	Timestamp     time.Time `json:"timestamp"`
+	RequestID     string    `json:"request_id,omitempty"`
+	Actor         string    `json:"actor,omitempty"`
+	StatementType string    `json:"statement_type,omitempty"`
+	Table         string    `json:"table,omitempty"`
+	Query         string    `json:"query"`
+	Shard         string    `json:"shard,omitempty"`
+	Shards        []string  `json:"shards,omitempty"`
+	LatencyMs     float64   `json:"latency_ms"`
+	Rows          int       `json:"rows"`
+	RowsAffected  *int64    `json:"rows_affected,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// Options configures a Logger
+type Options struct {
+	// Output is a file path, or OutputStdout
+	Output string
+	// SampleRate is the fraction of queries logged, unless TableSampleRates
+	// sets one for the query's table
+	SampleRate       float64
+	TableSampleRates map[string]float64
+	// Redact replaces literal values in logged SQL with "?"
+	Redact bool
+	// MaxSizeBytes rotates the file once it grows past this size, keeping
+	// MaxBackups older files as path.1 (newest) to path.N
+	MaxSizeBytes int64
+	MaxBackups   int
+}
+
+// Logger writes sampled queries as JSON lines
+type Logger struct {
+	opts   Options
+	rates  map[string]float64
+	out    io.Writer
+	file   *os.File
+	size   int64
+	random *rand.Rand
+	mutex  sync.Mutex
+}
+
+// Open opens the query log for appending
+func Open(opts Options) (*Logger, error) {
+	l := &Logger{
+		opts:   opts,
+		rates:  make(map[string]float64, len(opts.TableSampleRates)),
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for table, rate := range opts.TableSampleRates {
+		l.rates[strings.ToLower(table)] = rate
+	}
+
+	if opts.Output == "" || opts.Output == OutputStdout {
+		l.out = os.Stdout
+		return l, nil
+	}
+	if err := l.openFile(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Sampled decides whether a query on the table is logged
+func (l *Logger) Sampled(table string) bool {
+	if l == nil {
+		return false
+	}
+	rate, exists := l.rates[strings.ToLower(table)]
+	if !exists {
+		rate = l.opts.SampleRate
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.random.Float64() < rate
+}
+
+// Record writes an entry, redacting its query if configured
+func (l *Logger) Record(entry Entry) error {
+	if l == nil {
+		return nil
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+	if l.opts.Redact {
+		entry.Query = Redact(entry.Query)
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode query log entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.file != nil && l.opts.MaxSizeBytes > 0 && l.size+int64(len(line)) > l.opts.MaxSizeBytes && l.size > 0 {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.out.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write query log entry: %w", err)
+	}
+	return nil
+}
+
+// Close closes the query log file
+func (l *Logger) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// openFile opens the log file for appending, picking up its current size
+func (l *Logger) openFile() error {
+	file, err := os.OpenFile(l.opts.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open query log %s: %w", l.opts.Output, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat query log %s: %w", l.opts.Output, err)
+	}
+	l.file = file
+	l.out = file
+	l.size = info.Size()
+	return nil
+}
+
+// rotate shifts the backups up by one, dropping the oldest, moves the
+// current file to path.1 and starts a new one. Must be called with the
+// mutex held.
+func (l *Logger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to close query log for rotation: %w", err)
+	}
+
+	path := l.opts.Output
+	if l.opts.MaxBackups > 0 {
+		for i := l.opts.MaxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+		}
+		if err := os.Rename(path, path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate query log: %w", err)
+		}
+	} else if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to rotate query log: %w", err)
+	}
+	return l.openFile()
+}
+
+// literal matches SQL string, hex and numeric literals. Digits inside
+// identifiers, such as shard1_db, are not word-boundary separated and stay.
+var literal = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*"|\b0x[0-9A-Fa-f]+\b|\b\d+(?:\.\d+)?(?:[eE][-+]?\d+)?\b`)
+
+// Redact replaces the literal values of a statement with "?", keeping its
+// shape for analysis without exposing the data
+func Redact(query string) string {
+	return literal.ReplaceAllString(query, "?")
+}
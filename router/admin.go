@@ -0,0 +1,112 @@
+package router
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sql-horizontal-autoscaler/parser"
+)
+
+// autoIncrementOption matches the table option of SHOW CREATE TABLE that
+// naturally differs between shards
+var autoIncrementOption = regexp.MustCompile(`\s*AUTO_INCREMENT=\d+`)
+
+// executeAdmin serves SHOW, DESCRIBE and SELECTs without a FROM clause.
+// Schema statements run on every available shard and answer with the result
+// most shards agree on, listing the others as drifted; the rest run on one
+// shard picked by the read balancer.
+func (qr *QueryRouter) executeAdmin(r *http.Request, query string, parseResult *parser.ParseResult, database string, maxStaleness time.Duration) (*QueryResponse, *APIError) {
+	targetShards, err := qr.filterMaintenance(qr.dataStore.ShardIDs(), false)
+	if err != nil {
+		return nil, newAPIError(ErrCodeShardUnavailable, err.Error())
+	}
+
+	if !parseResult.IsSchema() {
+		shardID := qr.dataStore.PickShard(targetShards)
+		data, _, err := qr.dataStore.ExecuteReadLimited(r.Context(), query, shardID, database, maxStaleness, qr.resultLimit(false))
+		if err != nil {
+			log.Printf("Failed to execute query on shard %s: %v", shardID, err)
+			return nil, classifyExecutionError(err, shardID)
+		}
+		return &QueryResponse{Data: data, Shard: shardID}, nil
+	}
+
+	results := make([][]map[string]interface{}, len(targetShards))
+	errs := make([]error, len(targetShards))
+	var wg sync.WaitGroup
+	for i, shardID := range targetShards {
+		wg.Add(1)
+		go func(i int, shardID string) {
+			defer wg.Done()
+			results[i], _, errs[i] = qr.dataStore.ExecuteReadLimited(r.Context(), query, shardID, database, maxStaleness, qr.resultLimit(false))
+		}(i, shardID)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			log.Printf("Failed to execute query on shard %s: %v", targetShards[i], err)
+			return nil, classifyExecutionError(err, targetShards[i])
+		}
+	}
+
+	// Group shards by their answer and keep the one most shards give
+	groups := make(map[string][]int)
+	var order []string
+	for i, data := range results {
+		fingerprint := schemaFingerprint(data)
+		if _, exists := groups[fingerprint]; !exists {
+			order = append(order, fingerprint)
+		}
+		groups[fingerprint] = append(groups[fingerprint], i)
+	}
+	majority := order[0]
+	for _, fingerprint := range order[1:] {
+		if len(groups[fingerprint]) > len(groups[majority]) {
+			majority = fingerprint
+		}
+	}
+
+	response := &QueryResponse{Data: results[groups[majority][0]], Shards: targetShards}
+	for _, fingerprint := range order {
+		if fingerprint == majority {
+			continue
+		}
+		for _, i := range groups[fingerprint] {
+			response.SchemaDrift = append(response.SchemaDrift, targetShards[i])
+		}
+	}
+	if len(response.SchemaDrift) > 0 {
+		sort.Strings(response.SchemaDrift)
+		log.Printf("Warning: schema drift on shards %v for %q", response.SchemaDrift, query)
+	}
+	return response, nil
+}
+
+// schemaFingerprint reduces a shard's answer to a schema statement to a
+// comparable string. Column names are left out, as SHOW TABLES names its
+// column after the shard's database, and AUTO_INCREMENT counters are ignored.
+func schemaFingerprint(data []map[string]interface{}) string {
+	var b strings.Builder
+	for _, row := range data {
+		columns := make([]string, 0, len(row))
+		for column := range row {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+
+		for _, column := range columns {
+			value := fmt.Sprintf("%v", row[column])
+			b.WriteString(autoIncrementOption.ReplaceAllString(value, ""))
+			b.WriteByte(0)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package router
+
+import (
+	"log"
+	"net/http"
+
+	"sql-horizontal-autoscaler/audit"
+	"sql-horizontal-autoscaler/datastore"
+	"sql-horizontal-autoscaler/middleware"
+	"sql-horizontal-autoscaler/parser"
+)
+
+// SetAuditLog enables audit logging of write statements
+func (qr *QueryRouter) SetAuditLog(auditLog *audit.Logger) {
+	qr.auditLog = auditLog
+}
+
+// executeWrite runs a write statement on the target shards, records each
+// shard's outcome in the audit log and returns the total affected rows and
+// the shards it was buffered for until they are reachable again.
+// Writes spanning several shards use two-phase commit when it is enabled.
+func (qr *QueryRouter) executeWrite(r *http.Request, query string, parseResult *parser.ParseResult, shardIDs []string, database string) (int64, []string, error) {
+	affected, _, buffered, err := qr.executeWriteConcern(r, query, parseResult, shardIDs, database, WriteConcernAll)
+	return affected, buffered, err
+}
+
+// executeWriteConcern runs a write like executeWrite, succeeding once the
+// shards its write concern requires applied or buffered it. The shards it
+// failed on are returned, and the write is retried on them in the
+// background. A write that may have applied on a shard before failing is
+// only retried when running it twice is harmless; otherwise it fails as if
+// the write concern wasn't met. Two-phase commit writes apply on every shard
+// or none, whatever the write concern, and are never buffered.
+func (qr *QueryRouter) executeWriteConcern(r *http.Request, query string, parseResult *parser.ParseResult, shardIDs []string, database string, concern string) (int64, []string, []string, error) {
+	// A write is never abandoned once sent, so its outcome stays known; one
+	// whose deadline passed during routing isn't sent at all
+	if deadlinePassed(r.Context()) {
+		return 0, nil, nil, &datastore.DeadlineError{Pending: shardIDs}
+	}
+	if len(shardIDs) > 1 && qr.xaLog != nil && !parseResult.IsDDL() {
+		results, err := qr.dataStore.ExecuteXAWrite(query, shardIDs, database, qr.newXID(), qr.xaLog)
+		qr.auditWrites(r, query, parseResult, results)
+		var total int64
+		for _, result := range results {
+			total += result.RowsAffected
+		}
+		if err == nil {
+			err = checkVersionMatched(parseResult, total)
+		}
+		return total, nil, nil, err
+	}
+
+	results, buffered := qr.writeOrBuffer(r, query, parseResult, shardIDs, database)
+
+	var total int64
+	var firstErr, unrepairable error
+	var failed []string
+	for _, result := range results {
+		total += result.RowsAffected
+		if result.Err != nil {
+			failed = append(failed, result.ShardID)
+			shardErr := &datastore.ShardError{ShardID: result.ShardID, Err: result.Err}
+			if firstErr == nil {
+				firstErr = shardErr
+			}
+			if unrepairable == nil && !repairable(parseResult, result.Err) {
+				unrepairable = shardErr
+			}
+		}
+	}
+
+	if firstErr == nil {
+		return total, nil, buffered, checkVersionMatched(parseResult, total)
+	}
+	if len(shardIDs)-len(failed) < requiredAcks(concern, len(shardIDs)) {
+		return total, nil, nil, firstErr
+	}
+	if unrepairable != nil {
+		log.Printf("⚠️  Write applied on %d of %d shards but can't be repaired on %v, since it isn't idempotent: %v",
+			len(shardIDs)-len(failed), len(shardIDs), failed, unrepairable)
+		return total, nil, nil, unrepairable
+	}
+
+	log.Printf("⚠️  Write applied on %d of %d shards, enough for write concern %s; repairing %v in the background: %v",
+		len(shardIDs)-len(failed), len(shardIDs), concern, failed, firstErr)
+	requestID, actor := middleware.RequestIDFromContext(r.Context()), middleware.Actor(r)
+	for _, shardID := range failed {
+		go qr.repairWrite(requestID, actor, query, parseResult, shardID, database)
+	}
+	return total, failed, buffered, nil
+}
+
+// repairable reports whether a write that failed on a shard with err may be
+// retried there: it never reached the shard, or applying it twice is the
+// same as applying it once. The version check a versioned UPDATE was
+// rewritten with increments the version, so it is never idempotent.
+func repairable(parseResult *parser.ParseResult, err error) bool {
+	return datastore.WriteNotSent(err) || (parseResult.Idempotent && parseResult.VersionColumn == "")
+}
+
+// auditWrites records each shard's outcome of a write in the audit log
+func (qr *QueryRouter) auditWrites(r *http.Request, query string, parseResult *parser.ParseResult, results []datastore.WriteResult) {
+	qr.auditWritesBy(middleware.RequestIDFromContext(r.Context()), middleware.Actor(r), query, parseResult, results)
+}
+
+// auditWritesBy records each shard's outcome of a write in the audit log,
+// attributed to a request that may have been answered already
+func (qr *QueryRouter) auditWritesBy(requestID, actor string, query string, parseResult *parser.ParseResult, results []datastore.WriteResult) {
+	if !qr.auditLog.Audits(parseResult.TableName) {
+		return
+	}
+
+	for _, result := range results {
+		entry := audit.Entry{
+			RequestID:     requestID,
+			Actor:         actor,
+			StatementType: parseResult.StatementType,
+			Table:         parseResult.TableName,
+			Shard:         result.ShardID,
+			RowsAffected:  result.RowsAffected,
+			Query:         query,
+		}
+		if result.Err != nil {
+			entry.Error = result.Err.Error()
+		}
+		if err := qr.auditLog.Record(entry); err != nil {
+			log.Printf("Warning: Failed to audit write on shard %s: %v", result.ShardID, err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package router
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync/atomic"
+)
+
+// maxBatchSize is the largest number of queries accepted in one batch
+const maxBatchSize = 1000
+
+// BatchRequest is the body of POST /batch
+type BatchRequest struct {
+	Queries []QueryRequest `json:"queries"`
+	// StopOnError skips the remaining queries after the first failure
+	StopOnError bool `json:"stop_on_error,omitempty"`
+}
+
+// BatchResponse holds one response per executed query, in request order.
+// Failed queries carry their error in the response.
+type BatchResponse struct {
+	Results []QueryResponse `json:"results"`
+}
+
+// handleBatch handles POST /batch requests, executing each query in order.
+// Queries are independent: a batch is not a transaction.
+func (qr *QueryRouter) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		qr.sendError(w, newAPIError(ErrCodeInvalidRequest, "Invalid JSON request"))
+		return
+	}
+
+	response, apiErr := qr.executeBatch(r, req)
+	if apiErr != nil {
+		qr.sendError(w, apiErr)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode batch response: %v", err)
+	}
+}
+
+// executeBatch validates a batch and executes its queries in order
+func (qr *QueryRouter) executeBatch(r *http.Request, req BatchRequest) (*BatchResponse, *APIError) {
+	if len(req.Queries) == 0 {
+		return nil, newAPIError(ErrCodeInvalidRequest, "Batch must contain at least one query")
+	}
+	if len(req.Queries) > maxBatchSize {
+		return nil, newAPIError(ErrCodeInvalidRequest, "Batch exceeds the maximum of 1000 queries")
+	}
+
+	response := &BatchResponse{Results: make([]QueryResponse, 0, len(req.Queries))}
+	for _, query := range req.Queries {
+		result, apiErr := qr.executeQuery(r, query)
+		if apiErr != nil {
+			atomic.AddInt64(&qr.errorCount, 1)
+			response.Results = append(response.Results, QueryResponse{Error: apiErr})
+			if req.StopOnError {
+				break
+			}
+			continue
+		}
+		response.Results = append(response.Results, *result)
+	}
+
+	log.Printf("Batch executed: %d of %d queries run", len(response.Results), len(req.Queries))
+	return response, nil
+}
@@ -0,0 +1,93 @@
+package router
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"sql-horizontal-autoscaler/datastore"
+	"sql-horizontal-autoscaler/parser"
+)
+
+// broadcastReadShard picks the copy of a broadcast table serving a read: the
+// configured source shard, or else the in-sync copy the read balancer prefers
+func (qr *QueryRouter) broadcastReadShard(table string) (string, error) {
+	if qr.config.Broadcast.SourceShard != "" {
+		return qr.shardManager.BroadcastSource(table, qr.config.Broadcast.SourceShard)
+	}
+	copies := qr.shardManager.BroadcastCopies(table)
+	if len(copies) == 0 {
+		return "", fmt.Errorf("no shard holds an in-sync copy of broadcast table %s", table)
+	}
+	return qr.dataStore.PickShard(copies), nil
+}
+
+// executeBroadcast serves a query on a broadcast table. Reads go to a single
+// in-sync copy; writes are applied to every shard with a two-phase pattern
+// and succeed once the shards their write concern requires committed. Shards
+// that fail to prepare or commit are recorded as diverged, for the
+// coordinator to repair.
+func (qr *QueryRouter) executeBroadcast(r *http.Request, query string, parseResult *parser.ParseResult, database string, maxStaleness time.Duration, concern string) (*QueryResponse, *APIError) {
+	table := parseResult.TableName
+
+	if !parseResult.IsWrite() {
+		sourceShard, err := qr.broadcastReadShard(table)
+		if err != nil {
+			return nil, newAPIError(ErrCodeShardUnavailable, err.Error())
+		}
+
+		data, _, err := qr.dataStore.ExecuteReadLimited(r.Context(), query, sourceShard, database, maxStaleness, qr.resultLimit(false))
+		truncated, err := qr.truncateOnLimit(err)
+		if err != nil {
+			log.Printf("Failed to execute query on shard %s: %v", sourceShard, err)
+			return nil, classifyExecutionError(err, sourceShard)
+		}
+		return &QueryResponse{Data: data, Shard: sourceShard, Truncated: truncated}, nil
+	}
+
+	targetShards, err := qr.filterMaintenance(qr.shardManager.GetAllShards(), true)
+	if err != nil {
+		return nil, newAPIError(ErrCodeShardUnavailable, err.Error())
+	}
+
+	log.Printf("Broadcasting write on %s to %d shards", table, len(targetShards))
+
+	required := requiredAcks(concern, len(targetShards))
+	results, err := qr.dataStore.ExecuteTwoPhaseWrite(query, targetShards, database, required)
+	if err != nil {
+		log.Printf("Failed to prepare broadcast write: %v", err)
+		return nil, classifyExecutionError(err, "")
+	}
+	qr.auditWrites(r, query, parseResult, results)
+
+	// Shards agree on the affected rows, so report the count of one commit
+	var affected int64
+	var committed, diverged []string
+	var firstErr datastore.WriteResult
+	for _, result := range results {
+		if result.Err != nil {
+			qr.shardManager.RecordDivergence(table, result.ShardID, query, result.Err)
+			diverged = append(diverged, result.ShardID)
+			if firstErr.Err == nil {
+				firstErr = result
+			}
+			continue
+		}
+		affected = result.RowsAffected
+		committed = append(committed, result.ShardID)
+	}
+
+	if len(committed) < required {
+		apiErr := classifyExecutionError(firstErr.Err, firstErr.ShardID)
+		apiErr.Message = fmt.Sprintf("Broadcast write committed on %d of %d shards, short of write concern %s; the others are marked diverged for repair: %v",
+			len(committed), len(targetShards), concern, firstErr.Err)
+		if apiErr.Details == nil {
+			apiErr.Details = make(map[string]interface{})
+		}
+		apiErr.Details["committed"] = committed
+		apiErr.Details["diverged"] = diverged
+		return nil, apiErr
+	}
+	return &QueryResponse{Shards: committed, RowsAffected: &affected, Repairing: diverged}, nil
+}
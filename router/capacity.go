@@ -0,0 +1,80 @@
+package router
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+
+	"sql-horizontal-autoscaler/config"
+	"sql-horizontal-autoscaler/parser"
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// checkCapacity applies load shedding or read-only protection to the hottest
+// tables while the cluster is at capacity. Batch tables are shed first,
+// whether hot or not, and critical tables are never shed.
+func (qr *QueryRouter) checkCapacity(parseResult *parser.ParseResult) error {
+	status := qr.shardManager.GetCapacityStatus()
+	if !status.AtCapacity || status.Action == sharding.CapacityActionNone {
+		return nil
+	}
+
+	switch qr.config.TablePriority(qualifiedTable(parseResult)) {
+	case config.PriorityCritical:
+		return nil
+	case config.PriorityBatch:
+		return fmt.Errorf("cluster at capacity: batch query on table %s shed, retry later", parseResult.TableName)
+	}
+
+	names := qr.hotTableNames(parseResult)
+	isHot := false
+	for _, tableName := range status.HotTables {
+		if slices.Contains(names, tableName) {
+			isHot = true
+			break
+		}
+	}
+	if !isHot {
+		return nil
+	}
+
+	switch status.Action {
+	case sharding.CapacityActionReadOnly:
+		if parseResult.IsWrite() {
+			return fmt.Errorf("cluster at capacity: table %s is read-only", parseResult.TableName)
+		}
+	case sharding.CapacityActionShed:
+		if rand.Float64() < status.ShedRatio {
+			return fmt.Errorf("cluster at capacity: query on table %s shed, retry later", parseResult.TableName)
+		}
+	}
+	return nil
+}
+
+// qualifiedTable returns the query's table, qualified with its database when
+// the query names one
+func qualifiedTable(parseResult *parser.ParseResult) string {
+	if parseResult.DatabaseName != "" {
+		return parseResult.DatabaseName + "." + parseResult.TableName
+	}
+	return parseResult.TableName
+}
+
+// hotTableNames returns the names the shard metrics, and so the hot tables,
+// may know the query's table by: none when the query names a table of the
+// same name in another database
+func (qr *QueryRouter) hotTableNames(parseResult *parser.ParseResult) []string {
+	name := qr.config.QualifiedTableName(parseResult.TableName)
+	if parseResult.DatabaseName == "" {
+		return []string{name}
+	}
+	if databaseName, _, qualified := strings.Cut(name, "."); qualified {
+		if databaseName != parseResult.DatabaseName {
+			return nil
+		}
+		return []string{name}
+	}
+	// A table configured by its qualified name is counted under it
+	return []string{name, qualifiedTable(parseResult)}
+}
@@ -0,0 +1,46 @@
+package router
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sql-horizontal-autoscaler/datastore"
+)
+
+// Read consistency levels accepted in QueryRequest.Consistency
+const (
+	ConsistencyStrong           = "strong"
+	ConsistencyEventual         = "eventual"
+	ConsistencyBoundedStaleness = "bounded_staleness"
+	ConsistencySnapshot         = "snapshot"
+)
+
+// parseConsistency converts a consistency level into the maximum staleness a
+// read may be served with. The empty level is strong.
+func parseConsistency(level string) (time.Duration, error) {
+	level = strings.TrimSpace(level)
+	switch level {
+	case "", ConsistencyStrong:
+		return datastore.StalenessStrong, nil
+	case ConsistencyEventual:
+		return datastore.StalenessUnbounded, nil
+	case ConsistencySnapshot:
+		return datastore.StalenessSnapshot, nil
+	}
+
+	if strings.HasPrefix(level, ConsistencyBoundedStaleness+"(") && strings.HasSuffix(level, ")") {
+		arg := strings.TrimSuffix(strings.TrimPrefix(level, ConsistencyBoundedStaleness+"("), ")")
+		seconds, err := strconv.Atoi(strings.TrimSpace(arg))
+		if err != nil || seconds < 0 {
+			return 0, fmt.Errorf("bounded_staleness requires a non-negative number of seconds")
+		}
+		if seconds == 0 {
+			return datastore.StalenessStrong, nil
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	return 0, fmt.Errorf("consistency must be 'strong', 'eventual', 'snapshot' or 'bounded_staleness(seconds)'")
+}
@@ -0,0 +1,65 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// deadlineHeader carries a request's absolute deadline as an RFC 3339 time
+const deadlineHeader = "X-Request-Deadline"
+
+// requestDeadline derives the context a query runs under from the
+// X-Request-Deadline header and the request's timeout_ms, whichever is
+// earlier. Without either the request's own context is used.
+func requestDeadline(r *http.Request, req QueryRequest) (context.Context, context.CancelFunc, *APIError) {
+	var deadline time.Time
+	if header := r.Header.Get(deadlineHeader); header != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, header)
+		if err != nil {
+			return nil, nil, newAPIError(ErrCodeInvalidRequest, fmt.Sprintf("%s must be an RFC 3339 time: %v", deadlineHeader, err))
+		}
+		deadline = parsed
+	}
+	if req.TimeoutMs < 0 {
+		return nil, nil, newAPIError(ErrCodeInvalidRequest, "timeout_ms cannot be negative")
+	}
+	if req.TimeoutMs > 0 {
+		if timeout := time.Now().Add(time.Duration(req.TimeoutMs) * time.Millisecond); deadline.IsZero() || timeout.Before(deadline) {
+			deadline = timeout
+		}
+	}
+
+	if deadline.IsZero() {
+		ctx, cancel := context.WithCancel(r.Context())
+		return ctx, cancel, nil
+	}
+	ctx, cancel := context.WithDeadline(r.Context(), deadline)
+	return ctx, cancel, nil
+}
+
+// deadlinePassed reports whether a request's deadline has passed
+func deadlinePassed(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// deadlineExceeded creates the error of a request whose deadline passed at a
+// stage, listing the shards that answered in time and those that didn't
+func deadlineExceeded(stage string, completed, pending []string) *APIError {
+	if completed == nil {
+		completed = []string{}
+	}
+	if pending == nil {
+		pending = []string{}
+	}
+	apiErr := newAPIError(ErrCodeDeadlineExceeded, fmt.Sprintf("Request deadline exceeded during %s with %d of %d shards completed",
+		stage, len(completed), len(completed)+len(pending)))
+	apiErr.Details = map[string]interface{}{
+		"stage":     stage,
+		"completed": completed,
+		"pending":   pending,
+	}
+	return apiErr
+}
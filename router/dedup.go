@@ -0,0 +1,173 @@
+package router
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sql-horizontal-autoscaler/parser"
+)
+
+// dedupeDistinct removes rows duplicated across shards for SELECT DISTINCT
+// queries; each shard only deduplicates its own rows
+func (qr *QueryRouter) dedupeDistinct(data []map[string]interface{}, parseResult *parser.ParseResult, shardCount int) ([]map[string]interface{}, error) {
+	if !parseResult.Distinct || shardCount < 2 {
+		return data, nil
+	}
+
+	deduped, err := dedupeRows(data, qr.config.Merge.DedupMemoryLimit, qr.config.Merge.SpillDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deduplicate results: %w", err)
+	}
+
+	log.Printf("Deduplicated DISTINCT results across %d shards: %d -> %d rows", shardCount, len(data), len(deduped))
+	return deduped, nil
+}
+
+// dedupeRows keeps the first occurrence of each row, keyed on the selected
+// columns. When more than memoryLimit distinct keys are seen the keys are
+// hash-partitioned to temporary files in spillDir and each partition is
+// deduplicated on its own, bounding the size of the in-memory key set.
+func dedupeRows(rows []map[string]interface{}, memoryLimit int, spillDir string) ([]map[string]interface{}, error) {
+	keys := make([]string, len(rows))
+	seen := make(map[string]struct{})
+	keep := make([]bool, len(rows))
+
+	for i, row := range rows {
+		key, err := rowKey(row)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		if len(seen) >= memoryLimit {
+			seen = nil
+			var spillErr error
+			if keep, spillErr = dedupeSpilled(keys, rows, memoryLimit, spillDir); spillErr != nil {
+				return nil, spillErr
+			}
+			break
+		}
+		seen[key] = struct{}{}
+		keep[i] = true
+	}
+
+	result := make([]map[string]interface{}, 0, len(rows))
+	for i, row := range rows {
+		if keep[i] {
+			result = append(result, row)
+		}
+	}
+	return result, nil
+}
+
+// dedupeSpilled partitions row keys by hash into temporary files and marks the
+// first row of each key within every partition
+func dedupeSpilled(keys []string, rows []map[string]interface{}, memoryLimit int, spillDir string) ([]bool, error) {
+	// Compute the remaining keys before writing partitions
+	for i := range rows {
+		if keys[i] != "" {
+			continue
+		}
+		key, err := rowKey(rows[i])
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+
+	partitionCount := len(rows)/memoryLimit + 1
+	log.Printf("DISTINCT merge exceeded %d keys, spilling %d rows to %d partitions", memoryLimit, len(rows), partitionCount)
+
+	files := make([]*os.File, partitionCount)
+	writers := make([]*bufio.Writer, partitionCount)
+	defer func() {
+		for _, file := range files {
+			if file != nil {
+				file.Close()
+				os.Remove(file.Name())
+			}
+		}
+	}()
+
+	for p := range files {
+		file, err := os.CreateTemp(spillDir, "distinct-spill-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create spill file: %w", err)
+		}
+		files[p] = file
+		writers[p] = bufio.NewWriter(file)
+	}
+
+	// Each line is "<row index>\t<key>"; JSON keys never contain newlines
+	for i, key := range keys {
+		hash := fnv.New32a()
+		hash.Write([]byte(key))
+		p := int(hash.Sum32() % uint32(partitionCount))
+		if _, err := fmt.Fprintf(writers[p], "%d\t%s\n", i, key); err != nil {
+			return nil, fmt.Errorf("failed to write spill file: %w", err)
+		}
+		keys[i] = ""
+	}
+
+	keep := make([]bool, len(rows))
+	for p, file := range files {
+		if err := writers[p].Flush(); err != nil {
+			return nil, fmt.Errorf("failed to write spill file: %w", err)
+		}
+		if _, err := file.Seek(0, 0); err != nil {
+			return nil, fmt.Errorf("failed to read spill file: %w", err)
+		}
+
+		seen := make(map[string]struct{})
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			tab := strings.IndexByte(line, '\t')
+			index, err := strconv.Atoi(line[:tab])
+			if err != nil {
+				return nil, fmt.Errorf("corrupt spill file: %w", err)
+			}
+			key := line[tab+1:]
+			if _, exists := seen[key]; !exists {
+				seen[key] = struct{}{}
+				keep[index] = true
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read spill file: %w", err)
+		}
+	}
+
+	return keep, nil
+}
+
+// rowKey encodes a row's values in column order so equal rows share a key
+func rowKey(row map[string]interface{}) (string, error) {
+	columns := make([]string, 0, len(row))
+	for col := range row {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+
+	values := make([]interface{}, len(columns))
+	for i, col := range columns {
+		values[i] = row[col]
+	}
+
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode row: %w", err)
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,221 @@
+package router
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+
+	"github.com/go-sql-driver/mysql"
+	"sql-horizontal-autoscaler/datastore"
+)
+
+// Error codes returned in API error responses
+const (
+	ErrCodeInvalidRequest   = "INVALID_REQUEST"
+	ErrCodeParse            = "PARSE_ERROR"
+	ErrCodeRouting          = "ROUTING_ERROR"
+	ErrCodeNoMatchingShards = "NO_MATCHING_SHARDS"
+	ErrCodeShardUnavailable = "SHARD_UNAVAILABLE"
+	ErrCodeShardDown        = "SHARD_DOWN"
+	ErrCodeTimeout          = "TIMEOUT"
+	ErrCodeDeadlineExceeded = "DEADLINE_EXCEEDED"
+	ErrCodeAtCapacity       = "CLUSTER_AT_CAPACITY"
+	ErrCodeQuery            = "QUERY_ERROR"
+	ErrCodeConflict         = "CONFLICT"
+	ErrCodeQuotaExceeded    = "QUOTA_EXCEEDED"
+	ErrCodeCommitInDoubt    = "COMMIT_IN_DOUBT"
+	ErrCodeResultTooLarge   = "RESULT_TOO_LARGE"
+	ErrCodeDangerous        = "DANGEROUS_STATEMENT"
+	ErrCodePolicy           = "POLICY_VIOLATION"
+	ErrCodeMissingKey       = "MISSING_SHARD_KEY"
+	ErrCodeKeyUpdate        = "SHARD_KEY_UPDATE"
+	ErrCodeJobLimit         = "TOO_MANY_JOBS"
+	ErrCodeVersionConflict  = "VERSION_CONFLICT"
+	ErrCodeUnboundedScatter = "UNBOUNDED_SCATTER"
+	ErrCodeUnknownTemplate  = "UNKNOWN_TEMPLATE"
+	ErrCodeInternal         = "INTERNAL_ERROR"
+)
+
+// errorStatus maps each error code to its HTTP status
+var errorStatus = map[string]int{
+	ErrCodeInvalidRequest:   http.StatusBadRequest,
+	ErrCodeParse:            http.StatusBadRequest,
+	ErrCodeRouting:          http.StatusInternalServerError,
+	ErrCodeNoMatchingShards: http.StatusBadRequest,
+	ErrCodeShardUnavailable: http.StatusServiceUnavailable,
+	ErrCodeShardDown:        http.StatusBadGateway,
+	ErrCodeTimeout:          http.StatusGatewayTimeout,
+	ErrCodeDeadlineExceeded: http.StatusGatewayTimeout,
+	ErrCodeAtCapacity:       http.StatusServiceUnavailable,
+	ErrCodeQuery:            http.StatusUnprocessableEntity,
+	ErrCodeConflict:         http.StatusConflict,
+	ErrCodeQuotaExceeded:    http.StatusTooManyRequests,
+	ErrCodeCommitInDoubt:    http.StatusBadGateway,
+	ErrCodeResultTooLarge:   http.StatusUnprocessableEntity,
+	ErrCodeDangerous:        http.StatusForbidden,
+	ErrCodePolicy:           http.StatusForbidden,
+	ErrCodeMissingKey:       http.StatusBadRequest,
+	ErrCodeKeyUpdate:        http.StatusBadRequest,
+	ErrCodeJobLimit:         http.StatusTooManyRequests,
+	ErrCodeVersionConflict:  http.StatusConflict,
+	ErrCodeUnboundedScatter: http.StatusForbidden,
+	ErrCodeUnknownTemplate:  http.StatusNotFound,
+	ErrCodeInternal:         http.StatusInternalServerError,
+}
+
+// APIError is the machine-readable error returned in API responses
+type APIError struct {
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Shard     string                 `json:"shard,omitempty"`
+	Retryable bool                   `json:"retryable"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// Error implements error
+func (e *APIError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Status returns the HTTP status for the error
+func (e *APIError) Status() int {
+	if status, exists := errorStatus[e.Code]; exists {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// newAPIError creates an API error; errors of unavailable shards, timeouts
+// and capacity limits are retryable
+func newAPIError(code, message string) *APIError {
+	return &APIError{
+		Code:      code,
+		Message:   message,
+		Retryable: code == ErrCodeShardUnavailable || code == ErrCodeShardDown || code == ErrCodeTimeout || code == ErrCodeAtCapacity,
+	}
+}
+
+// onShard attributes the error to a shard
+func (e *APIError) onShard(shardID string) *APIError {
+	e.Shard = shardID
+	return e
+}
+
+// MySQL server error numbers used to classify execution failures
+const (
+	mysqlLockWaitTimeout     = 1205
+	mysqlDeadlock            = 1213
+	mysqlQueryInterrupted    = 1317
+	mysqlMaxExecutionTime    = 3024
+	mysqlDuplicateEntry      = 1062
+	mysqlSyntaxError         = 1064
+	mysqlTooManyConnections  = 1040
+	mysqlServerShuttingDown  = 1053
+	mysqlReadOnlyTransaction = 1792
+	mysqlReadOnlyServer      = 1290
+)
+
+// classifyExecutionError converts a query execution failure into an API
+// error, attributing it to the failing shard when known
+func classifyExecutionError(err error, shardID string) *APIError {
+	// Retrying a partially committed write would apply it twice
+	var inDoubt *datastore.InDoubtError
+	if errors.As(err, &inDoubt) {
+		shards := make([]string, 0, len(inDoubt.InDoubt))
+		for shardID := range inDoubt.InDoubt {
+			shards = append(shards, shardID)
+		}
+		sort.Strings(shards)
+		apiErr := newAPIError(ErrCodeCommitInDoubt, "Write committed on some shards only: "+err.Error())
+		apiErr.Details = map[string]interface{}{
+			"xid":       inDoubt.XID,
+			"committed": inDoubt.Committed,
+			"in_doubt":  shards,
+			"recovery":  "the commit decision is logged; the router commits the in-doubt shards once they are reachable, see GET /transactions",
+		}
+		return apiErr
+	}
+
+	var conflict *versionConflictError
+	if errors.As(err, &conflict) {
+		return conflict.apiError()
+	}
+
+	var writeUnavailable *datastore.WriteUnavailableError
+	if errors.As(err, &writeUnavailable) {
+		apiErr := newAPIError(ErrCodeShardUnavailable, writeUnavailable.Error())
+		apiErr.Details = map[string]interface{}{
+			"read_only_fallback": true,
+			"primary_down_since": writeUnavailable.Since,
+		}
+		return apiErr.onShard(writeUnavailable.ShardID)
+	}
+
+	var bufferErr *datastore.WriteBufferError
+	if errors.As(err, &bufferErr) {
+		apiErr := newAPIError(ErrCodeShardUnavailable, bufferErr.Error())
+		apiErr.Details = map[string]interface{}{
+			"buffered_writes":  bufferErr.Buffered,
+			"oldest_queued_at": bufferErr.Oldest,
+		}
+		return apiErr.onShard(bufferErr.ShardID)
+	}
+
+	var deadlineErr *datastore.DeadlineError
+	if errors.As(err, &deadlineErr) {
+		return deadlineExceeded("execution", deadlineErr.Completed, deadlineErr.Pending)
+	}
+
+	var tooLarge *datastore.ResultTooLargeError
+	if errors.As(err, &tooLarge) {
+		apiErr := newAPIError(ErrCodeResultTooLarge, fmt.Sprintf(
+			"Result exceeds the limit of %d rows or %d bytes; add a LIMIT and paginate with OFFSET or a key range",
+			tooLarge.Limit.MaxRows, tooLarge.Limit.MaxBytes))
+		apiErr.Details = map[string]interface{}{
+			"max_rows":  tooLarge.Limit.MaxRows,
+			"max_bytes": tooLarge.Limit.MaxBytes,
+		}
+		return apiErr
+	}
+
+	var shardErr *datastore.ShardError
+	if errors.As(err, &shardErr) {
+		shardID = shardErr.ShardID
+	}
+
+	apiErr := newAPIError(ErrCodeQuery, "Failed to execute query: "+err.Error())
+
+	var mysqlErr *mysql.MySQLError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &mysqlErr):
+		apiErr.Details = map[string]interface{}{"mysql_error": mysqlErr.Number}
+		switch mysqlErr.Number {
+		case mysqlLockWaitTimeout, mysqlQueryInterrupted, mysqlMaxExecutionTime:
+			apiErr.Code = ErrCodeTimeout
+			apiErr.Retryable = true
+		case mysqlDeadlock:
+			apiErr.Code = ErrCodeConflict
+			apiErr.Retryable = true
+		case mysqlDuplicateEntry:
+			apiErr.Code = ErrCodeConflict
+		case mysqlSyntaxError:
+			apiErr.Code = ErrCodeParse
+		case mysqlTooManyConnections, mysqlServerShuttingDown, mysqlReadOnlyTransaction, mysqlReadOnlyServer:
+			apiErr.Code = ErrCodeShardUnavailable
+			apiErr.Retryable = true
+		}
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		apiErr.Code = ErrCodeTimeout
+		apiErr.Retryable = true
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, mysql.ErrInvalidConn), errors.As(err, &netErr):
+		apiErr.Code = ErrCodeShardDown
+		apiErr.Retryable = true
+	}
+
+	return apiErr.onShard(shardID)
+}
@@ -0,0 +1,81 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"sql-horizontal-autoscaler/parser"
+)
+
+// Result size estimates of results.estimate
+const (
+	// estimateCount counts every row the read would return on each shard
+	estimateCount = "count"
+	// estimateProbe counts rows only up to the scatter-gather row cap
+	estimateProbe = "probe"
+)
+
+// checkResultEstimate sizes a scatter-gather SELECT without a LIMIT on every
+// shard before running it, rejecting it when the rows it would return exceed
+// results.scatter_gather.max_rows so they're never gathered into memory.
+// Shards that can't be estimated count as empty, so a failed estimate never
+// blocks the read; the result limit still applies to it.
+func (qr *QueryRouter) checkResultEstimate(ctx context.Context, query string, parseResult *parser.ParseResult, shardIDs []string, database string) *APIError {
+	results := qr.config.Results
+	maxRows := results.ScatterGather.MaxRows
+	if results.Estimate == "" || results.OnLimit != "error" || maxRows <= 0 ||
+		parseResult.StatementType != parser.StatementSelect || parseResult.HasLimit || len(shardIDs) < 2 {
+		return nil
+	}
+
+	probeLimit := 0
+	if results.Estimate == estimateProbe {
+		probeLimit = maxRows + 1
+	}
+
+	var total int64
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, max(qr.scatterParallelism(parseResult, database), 0))
+	for _, shardID := range shardIDs {
+		wg.Add(1)
+		go func(shardID string) {
+			defer wg.Done()
+			if cap(slots) > 0 {
+				slots <- struct{}{}
+				defer func() { <-slots }()
+			}
+			rows, err := qr.dataStore.EstimateResultRows(ctx, query, shardID, database, probeLimit)
+			if err != nil {
+				log.Printf("Warning: %v", err)
+				return
+			}
+			mutex.Lock()
+			total += rows
+			mutex.Unlock()
+		}(shardID)
+	}
+	wg.Wait()
+
+	if total <= int64(maxRows) {
+		return nil
+	}
+
+	estimated := fmt.Sprintf("about %d", total)
+	if probeLimit > 0 {
+		estimated = fmt.Sprintf("at least %d", total)
+	}
+	log.Printf("📏 Rejecting scatter-gather read: it would return %s rows across %d shards, over the limit of %d", estimated, len(shardIDs), maxRows)
+	apiErr := newAPIError(ErrCodeResultTooLarge, fmt.Sprintf(
+		"Result would be %s rows across %d shards, over the limit of %d rows; add a LIMIT and paginate with OFFSET or a key range",
+		estimated, len(shardIDs), maxRows))
+	apiErr.Details = map[string]interface{}{
+		"estimated_rows": total,
+		"estimate":       results.Estimate,
+		"max_rows":       maxRows,
+		"shards":         len(shardIDs),
+	}
+	return apiErr
+}
@@ -0,0 +1,105 @@
+package router
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"sql-horizontal-autoscaler/parser"
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// explain runs EXPLAIN of a statement on each shard the statement would be
+// routed to and returns every shard's plan keyed by shard. The plan is of the
+// statement as it would be sent, after rewriting, and is read from each
+// shard's primary.
+func (qr *QueryRouter) explain(r *http.Request, prefix, statement string, selector *sharding.LabelSelector) (*QueryResponse, *APIError) {
+	parseResult, err := qr.parseCache.Parse(statement, qr.config.TableShardKeys)
+	if err != nil {
+		return nil, newAPIError(ErrCodeParse, fmt.Sprintf("Failed to parse query: %v", err))
+	}
+	if apiErr := qr.policy.check(qr.usage.tenant(r), parseResult); apiErr != nil {
+		return nil, apiErr
+	}
+
+	database := ""
+	if parseResult.DatabaseName == "" {
+		database = qr.config.TableDatabases[parseResult.TableName]
+	}
+
+	targetShards, apiErr := qr.explainShards(parseResult, selector)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	targetShards, err = qr.filterMaintenance(targetShards, false)
+	if err != nil {
+		return nil, newAPIError(ErrCodeShardUnavailable, err.Error())
+	}
+
+	if !parseResult.IsWrite() {
+		statement = qr.rewriteQuery(statement, parseResult, database, len(targetShards) > 1).Query
+	}
+	query := prefix + " " + statement
+	log.Printf("Explaining query on %d shards: %v", len(targetShards), targetShards)
+
+	plans := make([][]map[string]interface{}, len(targetShards))
+	errs := make([]error, len(targetShards))
+	var wg sync.WaitGroup
+	for i, shardID := range targetShards {
+		wg.Add(1)
+		go func(i int, shardID string) {
+			defer wg.Done()
+			plans[i], errs[i] = qr.dataStore.ExecuteQuery(query, shardID, database)
+		}(i, shardID)
+	}
+	wg.Wait()
+
+	response := &QueryResponse{Shards: targetShards, Plans: make(map[string][]map[string]interface{}, len(targetShards))}
+	for i, shardID := range targetShards {
+		if errs[i] != nil {
+			log.Printf("Failed to explain query on shard %s: %v", shardID, errs[i])
+			return nil, classifyExecutionError(errs[i], shardID)
+		}
+		response.Plans[shardID] = plans[i]
+	}
+	return response, nil
+}
+
+// explainShards returns the shards a statement would be routed to
+func (qr *QueryRouter) explainShards(parseResult *parser.ParseResult, selector *sharding.LabelSelector) ([]string, *APIError) {
+	switch {
+	case qr.config.IsBroadcastTable(parseResult.TableName):
+		// Broadcast reads are served by any one shard; writes go to all
+		if parseResult.IsWrite() {
+			return qr.dataStore.ShardIDs(), nil
+		}
+		return []string{qr.dataStore.PickShard(qr.dataStore.ShardIDs())}, nil
+
+	case parseResult.HasShardKey:
+		shardID, err := qr.shardManager.GetShardForTable(parseResult.TableName, fmt.Sprintf("%v", parseResult.ShardKeyValue))
+		if err != nil {
+			return nil, newAPIError(ErrCodeRouting, fmt.Sprintf("Failed to determine target shard: %v", err))
+		}
+		return []string{shardID}, nil
+
+	case len(parseResult.ShardKeyValues) > 1:
+		shardIDs, err := qr.shardsForKeys(parseResult.TableName, parseResult.ShardKeyValues)
+		if err != nil {
+			return nil, newAPIError(ErrCodeRouting, fmt.Sprintf("Failed to determine target shards: %v", err))
+		}
+		return shardIDs, nil
+	}
+
+	targetShards := qr.dataStore.ShardIDs()
+	if !selector.Empty() {
+		targetShards = intersectShards(targetShards, qr.shardManager.ShardsMatching(selector))
+		if len(targetShards) == 0 {
+			return nil, newAPIError(ErrCodeNoMatchingShards, "No shards match the shard selector")
+		}
+	}
+	if rangeShards, isTimeRange := qr.shardManager.TimeRangeShards(parseResult.TableName); isTimeRange {
+		targetShards = intersectShards(targetShards, rangeShards)
+	}
+	return targetShards, nil
+}
@@ -0,0 +1,190 @@
+package router
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"sql-horizontal-autoscaler/parser"
+)
+
+// exportErrorTrailer reports an export that failed after streaming started
+const exportErrorTrailer = "X-Export-Error"
+
+// handleExport handles GET /export?table=orders[&shard=shard-2][&from=...&to=...][&format=csv|ndjson],
+// streaming a table out of one shard, or out of every shard holding it in
+// turn, for ETL or seeding downstream systems. Each shard's rows come from a
+// single consistent snapshot of it. from and to bound the shard key, from
+// inclusive and to exclusive. An error once rows have been sent is reported
+// in the X-Export-Error trailer.
+func (qr *QueryRouter) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	table := params.Get("table")
+	shardKey, sharded := qr.config.TableShardKeys[table]
+	if table == "" || (!sharded && !qr.config.IsBroadcastTable(table)) {
+		qr.sendError(w, newAPIError(ErrCodeInvalidRequest, fmt.Sprintf("Unknown table %q", table)))
+		return
+	}
+	format := params.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "ndjson" {
+		qr.sendError(w, newAPIError(ErrCodeInvalidRequest, "format must be csv or ndjson"))
+		return
+	}
+
+	if apiErr := qr.policy.check(qr.usage.tenant(r), &parser.ParseResult{StatementType: parser.StatementSelect, TableName: table}); apiErr != nil {
+		qr.sendError(w, apiErr)
+		return
+	}
+
+	query := "SELECT * FROM `" + table + "`"
+	var args []interface{}
+	if params.Has("from") || params.Has("to") {
+		if !sharded {
+			qr.sendError(w, newAPIError(ErrCodeInvalidRequest, fmt.Sprintf("Table %s has no shard key to range over", table)))
+			return
+		}
+		query += " WHERE 1 = 1"
+		if from := params.Get("from"); from != "" {
+			query += " AND `" + shardKey + "` >= ?"
+			args = append(args, from)
+		}
+		if to := params.Get("to"); to != "" {
+			query += " AND `" + shardKey + "` < ?"
+			args = append(args, to)
+		}
+	}
+	if sharded {
+		query += " ORDER BY `" + shardKey + "`"
+	}
+
+	targetShards, apiErr := qr.exportShards(table, sharded, params.Get("shard"))
+	if apiErr != nil {
+		qr.sendError(w, apiErr)
+		return
+	}
+
+	var writer exportWriter
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		writer = &csvExport{writer: csv.NewWriter(w)}
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		writer = &ndjsonExport{encoder: json.NewEncoder(w)}
+	}
+	w.Header().Set("Trailer", exportErrorTrailer)
+
+	log.Printf("📤 Exporting %s from %d shards as %s", table, len(targetShards), format)
+	database := qr.config.TableDatabases[table]
+	for _, shardID := range targetShards {
+		err := qr.dataStore.ExportRows(r.Context(), query, args, shardID, database, writer)
+		if err == nil {
+			err = writer.Flush()
+		}
+		if err != nil {
+			log.Printf("❌ Export of %s from shard %s failed: %v", table, shardID, err)
+			w.Header().Set(exportErrorTrailer, fmt.Sprintf("shard %s: %v", shardID, err))
+			return
+		}
+	}
+}
+
+// exportShards returns the shards an export reads from: the one requested,
+// or every shard holding the table, and any one shard for a broadcast table
+func (qr *QueryRouter) exportShards(table string, sharded bool, shardID string) ([]string, *APIError) {
+	shardIDs := qr.dataStore.ShardIDs()
+	if shardID != "" {
+		if !slices.Contains(shardIDs, shardID) {
+			return nil, newAPIError(ErrCodeRouting, fmt.Sprintf("Shard %s not found", shardID))
+		}
+		shardIDs = []string{shardID}
+	} else if !sharded {
+		shardIDs = []string{qr.dataStore.PickShard(shardIDs)}
+	} else if rangeShards, isTimeRange := qr.shardManager.TimeRangeShards(table); isTimeRange {
+		shardIDs = intersectShards(shardIDs, rangeShards)
+	}
+
+	shardIDs, err := qr.filterMaintenance(shardIDs, false)
+	if err != nil {
+		return nil, newAPIError(ErrCodeShardUnavailable, err.Error())
+	}
+	return shardIDs, nil
+}
+
+// exportWriter writes exported rows in a format
+type exportWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []interface{}) error
+	Flush() error
+}
+
+// csvExport writes rows as CSV with one header line for all shards. NULL is
+// written as an empty field.
+type csvExport struct {
+	writer  *csv.Writer
+	columns []string
+	record  []string
+}
+
+// WriteHeader writes the header the first time
+func (e *csvExport) WriteHeader(columns []string) error {
+	if e.columns != nil {
+		return nil
+	}
+	e.columns, e.record = columns, make([]string, len(columns))
+	return e.writer.Write(columns)
+}
+
+// WriteRow writes a row
+func (e *csvExport) WriteRow(values []interface{}) error {
+	for i, value := range values {
+		if value == nil {
+			e.record[i] = ""
+		} else {
+			e.record[i] = fmt.Sprint(value)
+		}
+	}
+	return e.writer.Write(e.record)
+}
+
+// Flush writes buffered rows out
+func (e *csvExport) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// ndjsonExport writes each row as a JSON object on its own line
+type ndjsonExport struct {
+	encoder *json.Encoder
+	columns []string
+}
+
+// WriteHeader remembers the columns
+func (e *ndjsonExport) WriteHeader(columns []string) error {
+	e.columns = columns
+	return nil
+}
+
+// WriteRow writes a row
+func (e *ndjsonExport) WriteRow(values []interface{}) error {
+	row := make(map[string]interface{}, len(values))
+	for i, value := range values {
+		row[e.columns[i]] = value
+	}
+	return e.encoder.Encode(row)
+}
+
+// Flush does nothing; rows are written as they are encoded
+func (e *ndjsonExport) Flush() error {
+	return nil
+}
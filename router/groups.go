@@ -0,0 +1,7 @@
+package router
+
+// SetShardGroups hands the queries on tables of other shard groups to those
+// groups' routers, keyed by table. It must be called before Start.
+func (qr *QueryRouter) SetShardGroups(groupRouters map[string]*QueryRouter) {
+	qr.shardGroups = groupRouters
+}
@@ -0,0 +1,146 @@
+package router
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"sql-horizontal-autoscaler/parser"
+)
+
+// tableRowsTTL is how long an estimated table size is reused
+const tableRowsTTL = time.Minute
+
+// tableRowsEstimate is a table's estimated row count on a set of shards
+type tableRowsEstimate struct {
+	rows int64
+	at   time.Time
+}
+
+// checkDangerous rejects a statement affecting every row of its table, which
+// scatter-gather would run on every shard, unless the request sets
+// allow_dangerous or the table allows that statement type
+func (qr *QueryRouter) checkDangerous(req QueryRequest, parseResult *parser.ParseResult) *APIError {
+	if qr.config.Guards.Disabled {
+		return nil
+	}
+	reason := parseResult.Dangerous()
+	if reason == "" {
+		return nil
+	}
+
+	table := parseResult.TableName
+	if parseResult.DatabaseName != "" {
+		table = parseResult.DatabaseName + "." + table
+	}
+
+	if req.AllowDangerous {
+		log.Printf("⚠️  Running %s on %s with allow_dangerous", reason, table)
+		return nil
+	}
+	for _, allowed := range [][]string{qr.config.Guards.AllowedTables[table], qr.config.Guards.AllowedTables[parseResult.TableName]} {
+		for _, statement := range allowed {
+			if statement == parseResult.StatementType {
+				log.Printf("⚠️  Running %s on %s, allowed by guards configuration", reason, table)
+				return nil
+			}
+		}
+	}
+
+	apiErr := newAPIError(ErrCodeDangerous, fmt.Sprintf(
+		"%s on %s would affect every row on every shard; set \"allow_dangerous\": true to run it", reason, table))
+	apiErr.Details = map[string]interface{}{
+		"statement": parseResult.StatementType,
+		"table":     table,
+	}
+	return apiErr
+}
+
+// checkUnboundedScatter rejects a SELECT fanned out to several shards that
+// has no LIMIT, or reads a table larger than guards.max_scatter_table_rows,
+// unless the request sets allow_dangerous
+func (qr *QueryRouter) checkUnboundedScatter(req QueryRequest, parseResult *parser.ParseResult, shardIDs []string, database string) *APIError {
+	guards := qr.config.Guards
+	if guards.Disabled || !guards.DenyUnboundedScatter || parseResult.StatementType != parser.StatementSelect || len(shardIDs) < 2 {
+		return nil
+	}
+
+	table := parseResult.TableName
+	if parseResult.DatabaseName != "" {
+		table = parseResult.DatabaseName + "." + table
+	}
+
+	var reason string
+	var rows int64
+	if !parseResult.HasLimit {
+		reason = "has no shard key condition and no LIMIT"
+	} else if guards.MaxScatterTableRows > 0 {
+		rows = qr.estimateTableRows(parseResult, shardIDs, database)
+		if rows > guards.MaxScatterTableRows {
+			reason = fmt.Sprintf("has no shard key condition and %s holds about %d rows, over the %d allowed", table, rows, guards.MaxScatterTableRows)
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+
+	if req.AllowDangerous {
+		log.Printf("⚠️  Scattering SELECT on %s to %d shards with allow_dangerous: it %s", table, len(shardIDs), reason)
+		return nil
+	}
+
+	hint := "add a LIMIT"
+	if parseResult.ShardKeyColumn != "" {
+		hint = fmt.Sprintf("filter on shard key %s", parseResult.ShardKeyColumn)
+		if !parseResult.HasLimit {
+			hint += " or add a LIMIT"
+		}
+	}
+	apiErr := newAPIError(ErrCodeUnboundedScatter, fmt.Sprintf(
+		"SELECT on %s %s, so it would scan every one of %d shards; %s, use POST /query/async or GET /export for full reads, or set \"allow_dangerous\": true to run it",
+		table, reason, len(shardIDs), hint))
+	apiErr.Details = map[string]interface{}{
+		"table":     table,
+		"shards":    len(shardIDs),
+		"has_limit": parseResult.HasLimit,
+	}
+	if parseResult.ShardKeyColumn != "" {
+		apiErr.Details["shard_key"] = parseResult.ShardKeyColumn
+	}
+	if rows > 0 {
+		apiErr.Details["estimated_rows"] = rows
+		apiErr.Details["max_scatter_table_rows"] = guards.MaxScatterTableRows
+	}
+	return apiErr
+}
+
+// estimateTableRows returns the estimated rows of the query's table across
+// the shards, cached for tableRowsTTL. Shards that can't be estimated count
+// as empty, so the guard never blocks a read on a failed estimate.
+func (qr *QueryRouter) estimateTableRows(parseResult *parser.ParseResult, shardIDs []string, database string) int64 {
+	if database == "" {
+		database = parseResult.DatabaseName
+	}
+
+	var total int64
+	for _, shardID := range shardIDs {
+		cacheKey := shardID + "/" + database + "." + parseResult.TableName
+
+		qr.tableRowsMutex.Lock()
+		estimate, exists := qr.tableRows[cacheKey]
+		qr.tableRowsMutex.Unlock()
+		if !exists || time.Since(estimate.at) > tableRowsTTL {
+			rows, err := qr.dataStore.EstimateTableRows(shardID, database, parseResult.TableName)
+			if err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			estimate = tableRowsEstimate{rows: rows, at: time.Now()}
+
+			qr.tableRowsMutex.Lock()
+			qr.tableRows[cacheKey] = estimate
+			qr.tableRowsMutex.Unlock()
+		}
+		total += estimate.rows
+	}
+	return total
+}
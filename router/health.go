@@ -0,0 +1,47 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"sql-horizontal-autoscaler/health"
+)
+
+// livenessChecks are the checks behind /livez: the router's background
+// loops must keep making progress
+func (qr *QueryRouter) livenessChecks() map[string]health.Check {
+	checks := make(map[string]health.Check)
+	if qr.config.Routers.CoordinatorURL != "" {
+		// A heartbeat round trip takes at most the 5s client timeout
+		interval := time.Duration(qr.config.Routers.HeartbeatIntervalSeconds) * time.Second
+		checks["registration_loop"] = qr.registrationBeat.Check(3*interval + 5*time.Second)
+	}
+	if qr.xaLog != nil {
+		interval := time.Duration(qr.config.Transactions.RecoveryIntervalSeconds) * time.Second
+		checks["xa_recovery_loop"] = qr.recoveryBeat.Check(max(3*interval, time.Minute))
+	}
+	if qr.writeBuffer != nil {
+		interval := time.Duration(qr.config.WriteBuffer.ReplayIntervalSeconds) * time.Second
+		checks["write_replay_loop"] = qr.replayBeat.Check(max(3*interval, time.Minute))
+	}
+	return checks
+}
+
+// readinessChecks are the checks behind /readyz: the router can serve
+// queries once a shard is active and the coordinator and Docker answer
+func (qr *QueryRouter) readinessChecks() map[string]health.Check {
+	checks := map[string]health.Check{
+		"active_shards": func() error {
+			if qr.shardManager.GetShardCount() == 0 {
+				return fmt.Errorf("no active shards")
+			}
+			return nil
+		},
+		"docker": qr.shardManager.CheckDocker,
+	}
+	if qr.config.Routers.CoordinatorURL != "" {
+		checks["coordinator"] = health.HTTPCheck(strings.TrimRight(qr.config.Routers.CoordinatorURL, "/") + "/livez")
+	}
+	return checks
+}
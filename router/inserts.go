@@ -0,0 +1,113 @@
+package router
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"sql-horizontal-autoscaler/config"
+	"sql-horizontal-autoscaler/parser"
+)
+
+// insertKeyPolicy returns the policy for INSERTs into a table that don't set
+// its shard key, preferring a "db.table" entry
+func (qr *QueryRouter) insertKeyPolicy(parseResult *parser.ParseResult) config.InsertKeyPolicy {
+	if parseResult.DatabaseName != "" {
+		if policy, exists := qr.config.Inserts.Tables[parseResult.DatabaseName+"."+parseResult.TableName]; exists {
+			return policy
+		}
+	}
+	if policy, exists := qr.config.Inserts.Tables[parseResult.TableName]; exists {
+		return policy
+	}
+	return config.InsertKeyPolicy{Action: qr.config.Inserts.MissingShardKey}
+}
+
+// applyInsertKeyPolicy handles an INSERT into a sharded table that doesn't
+// set the shard key, which would otherwise be inserted on every shard. It
+// rejects the insert, or derives the key, rewriting the query and parse
+// result to route on it, or returns the default shard to run it on.
+func (qr *QueryRouter) applyInsertKeyPolicy(req *QueryRequest, parseResult *parser.ParseResult) (string, *APIError) {
+	if parseResult.StatementType != parser.StatementInsert || parseResult.ShardKeyColumn == "" || parseResult.HasShardKey {
+		return "", nil
+	}
+
+	policy := qr.insertKeyPolicy(parseResult)
+	switch policy.Action {
+	case config.InsertKeyScatter:
+		log.Printf("⚠️  INSERT into %s sets no %s, inserting on every shard", parseResult.TableName, parseResult.ShardKeyColumn)
+		return "", nil
+
+	case config.InsertKeyDefaultShard:
+		log.Printf("INSERT into %s sets no %s, routing to default shard %s", parseResult.TableName, parseResult.ShardKeyColumn, policy.DefaultShard)
+		return policy.DefaultShard, nil
+
+	case config.InsertKeyDerive:
+		query, keys, err := parser.DeriveInsertShardKey(req.Query, parseResult.ShardKeyColumn, policy.KeyExpression)
+		if err != nil {
+			return "", missingShardKeyError(parseResult, err.Error())
+		}
+
+		// The rewritten statement runs whole on the shard it is routed to, so
+		// rows belonging on different shards can't share it
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			values[i] = key
+		}
+		shards, err := qr.shardsForKeys(parseResult.TableName, values)
+		if err != nil {
+			return "", newAPIError(ErrCodeRouting, fmt.Sprintf("Failed to determine target shards: %v", err))
+		}
+		if len(shards) > 1 {
+			apiErr := newAPIError(ErrCodeRouting, fmt.Sprintf("INSERT into %s has rows for %d shards %v; split it into one INSERT per shard", parseResult.TableName, len(shards), shards))
+			apiErr.Details = map[string]interface{}{
+				"table":     parseResult.TableName,
+				"shard_key": parseResult.ShardKeyColumn,
+				"shards":    shards,
+			}
+			return "", apiErr
+		}
+
+		req.Query = query
+		parseResult.ShardKeyValue = keys[0]
+		parseResult.ShardKeyValues = []interface{}{keys[0]}
+		parseResult.HasShardKey = true
+		return "", nil
+
+	default:
+		return "", missingShardKeyError(parseResult, "")
+	}
+}
+
+// missingShardKeyError rejects an INSERT that doesn't set its shard key
+func missingShardKeyError(parseResult *parser.ParseResult, reason string) *APIError {
+	message := fmt.Sprintf("INSERT into %s must set shard key %s", parseResult.TableName, parseResult.ShardKeyColumn)
+	if reason != "" {
+		message += ": " + reason
+	}
+	apiErr := newAPIError(ErrCodeMissingKey, message)
+	apiErr.Details = map[string]interface{}{
+		"table":     parseResult.TableName,
+		"shard_key": parseResult.ShardKeyColumn,
+	}
+	return apiErr
+}
+
+// executeOnDefaultShard runs an INSERT without a shard key on the default
+// shard configured for its table
+func (qr *QueryRouter) executeOnDefaultShard(r *http.Request, query string, parseResult *parser.ParseResult, shardID, database string) (*QueryResponse, *APIError) {
+	if _, exists := qr.shardManager.GetShardInfo(shardID); !exists {
+		return nil, newAPIError(ErrCodeRouting, fmt.Sprintf("Default shard %s of table %s does not exist", shardID, parseResult.TableName))
+	}
+	if err := qr.checkMaintenance(shardID, true); err != nil {
+		return nil, newAPIError(ErrCodeShardUnavailable, err.Error()).onShard(shardID)
+	}
+
+	rewritten := qr.rewriteQuery(query, parseResult, database, false)
+	affected, buffered, err := qr.executeWrite(r, rewritten.Query, parseResult, []string{shardID}, database)
+	if err != nil {
+		log.Printf("Failed to execute query on shard %s: %v", shardID, err)
+		return nil, classifyExecutionError(err, shardID)
+	}
+	return &QueryResponse{Shard: shardID, RowsAffected: &affected, Buffered: buffered}, nil
+}
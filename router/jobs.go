@@ -0,0 +1,303 @@
+package router
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Job statuses
+const (
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// job is a read running in the background on behalf of a tenant
+type job struct {
+	id       string
+	tenant   string
+	query    string
+	status   string
+	created  time.Time
+	finished time.Time
+	response *QueryResponse
+	err      *APIError
+	cancel   context.CancelFunc
+}
+
+// JobStatus is the body of the job endpoints. Data holds one page of the
+// rows of a completed job, starting at Offset; NextOffset is set while more
+// rows remain.
+type JobStatus struct {
+	JobID      string                   `json:"job_id"`
+	Status     string                   `json:"status"`
+	Query      string                   `json:"query"`
+	CreatedAt  time.Time                `json:"created_at"`
+	FinishedAt *time.Time               `json:"finished_at,omitempty"`
+	Data       []map[string]interface{} `json:"data,omitempty"`
+	Shard      string                   `json:"shard,omitempty"`
+	Shards     []string                 `json:"shards,omitempty"`
+	TotalRows  int                      `json:"total_rows"`
+	Offset     int                      `json:"offset"`
+	NextOffset *int                     `json:"next_offset,omitempty"`
+	Truncated  bool                     `json:"truncated,omitempty"`
+	Error      *APIError                `json:"error,omitempty"`
+}
+
+// handleAsyncQuery handles POST /query/async, starting a read in the
+// background and answering 202 with the job to poll at GET /jobs/{id}
+func (qr *QueryRouter) handleAsyncQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req QueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		qr.sendError(w, newAPIError(ErrCodeInvalidRequest, "Invalid JSON request"))
+		return
+	}
+
+	j, apiErr := qr.submitJob(r, req)
+	if apiErr != nil {
+		qr.sendError(w, apiErr)
+		return
+	}
+
+	qr.jobsMutex.Lock()
+	status := qr.jobStatus(j, 0, 0)
+	qr.jobsMutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/jobs/"+j.id)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(status)
+}
+
+// submitJob validates an async query and starts it. Only reads run as jobs:
+// a write cannot be cancelled once sent to the shards.
+func (qr *QueryRouter) submitJob(r *http.Request, req QueryRequest) (*job, *APIError) {
+	if req.Query == "" {
+		return nil, newAPIError(ErrCodeInvalidRequest, "Query cannot be empty")
+	}
+	parseResult, err := qr.parseCache.Parse(req.Query, qr.config.TableShardKeys)
+	if err != nil {
+		return nil, newAPIError(ErrCodeParse, fmt.Sprintf("Failed to parse query: %v", err))
+	}
+	if parseResult.IsWrite() {
+		return nil, newAPIError(ErrCodeInvalidRequest, "Only reads can run as async jobs; send writes to /query")
+	}
+
+	tenant := qr.usage.tenant(r)
+	if apiErr := qr.usage.check(tenant); apiErr != nil {
+		return nil, apiErr
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, newAPIError(ErrCodeInternal, err.Error())
+	}
+
+	// The job outlives the request, keeping its values (request ID, caller)
+	// but not its cancellation
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	j := &job{
+		id:      id,
+		tenant:  tenant,
+		query:   req.Query,
+		status:  JobRunning,
+		created: time.Now(),
+		cancel:  cancel,
+	}
+
+	qr.jobsMutex.Lock()
+	qr.expireJobs()
+	if len(qr.jobs) >= qr.config.Jobs.MaxJobs {
+		qr.jobsMutex.Unlock()
+		cancel()
+		apiErr := newAPIError(ErrCodeJobLimit, fmt.Sprintf("The router already holds %d jobs; fetch or cancel finished jobs, or retry later", len(qr.jobs)))
+		apiErr.Retryable = true
+		return nil, apiErr
+	}
+	qr.jobs[id] = j
+	qr.jobsMutex.Unlock()
+
+	log.Printf("Started job %s: %s", id, req.Query)
+	go qr.runJob(j, r.WithContext(ctx), req)
+	return j, nil
+}
+
+// runJob executes a job's query and stores its outcome, unless the job was
+// cancelled meanwhile
+func (qr *QueryRouter) runJob(j *job, r *http.Request, req QueryRequest) {
+	defer j.cancel()
+	response, apiErr := qr.executeQuery(r, req)
+
+	qr.jobsMutex.Lock()
+	defer qr.jobsMutex.Unlock()
+	if j.status != JobRunning {
+		return
+	}
+	j.finished = time.Now()
+	if apiErr != nil {
+		atomic.AddInt64(&qr.errorCount, 1)
+		j.status = JobFailed
+		j.err = apiErr
+		log.Printf("Job %s failed: %v", j.id, apiErr)
+		return
+	}
+	j.status = JobCompleted
+	j.response = response
+	log.Printf("Job %s completed with %d rows in %s", j.id, len(response.Data), j.finished.Sub(j.created).Round(time.Millisecond))
+}
+
+// handleJob handles GET /jobs/{id}, returning the job's status and, once
+// completed, a page of its rows selected with ?offset= and ?limit=, and
+// DELETE /jobs/{id}, which cancels a running job or discards the results of
+// a finished one
+func (qr *QueryRouter) handleJob(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	tenant := qr.usage.tenant(r)
+	switch r.Method {
+	case http.MethodGet:
+		offset, limit, err := parsePage(r, qr.config.Jobs.PageSize)
+		if err != nil {
+			qr.sendError(w, newAPIError(ErrCodeInvalidRequest, err.Error()))
+			return
+		}
+
+		qr.jobsMutex.Lock()
+		qr.expireJobs()
+		j, exists := qr.jobs[id]
+		found := exists && j.tenant == tenant
+		var status JobStatus
+		if found {
+			status = qr.jobStatus(j, offset, limit)
+		}
+		qr.jobsMutex.Unlock()
+
+		if !found {
+			http.Error(w, "No job "+id, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+
+	case http.MethodDelete:
+		qr.jobsMutex.Lock()
+		j, exists := qr.jobs[id]
+		if !exists || j.tenant != tenant {
+			qr.jobsMutex.Unlock()
+			http.Error(w, "No job "+id, http.StatusNotFound)
+			return
+		}
+		if j.status == JobRunning {
+			j.status = JobCancelled
+			j.finished = time.Now()
+			j.cancel()
+			log.Printf("Job %s cancelled on request", id)
+		} else {
+			delete(qr.jobs, id)
+		}
+		status := qr.jobStatus(j, 0, 0)
+		qr.jobsMutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// jobStatus describes a job with up to limit of its rows from offset. Must
+// be called with the jobs mutex held.
+func (qr *QueryRouter) jobStatus(j *job, offset, limit int) JobStatus {
+	status := JobStatus{
+		JobID:     j.id,
+		Status:    j.status,
+		Query:     j.query,
+		CreatedAt: j.created,
+		Offset:    offset,
+		Error:     j.err,
+	}
+	if !j.finished.IsZero() {
+		finished := j.finished
+		status.FinishedAt = &finished
+	}
+	if j.response == nil {
+		return status
+	}
+
+	rows := j.response.Data
+	status.Shard = j.response.Shard
+	status.Shards = j.response.Shards
+	status.Truncated = j.response.Truncated
+	status.TotalRows = len(rows)
+	if offset >= len(rows) || limit <= 0 {
+		return status
+	}
+	end := offset + limit
+	if end < len(rows) {
+		status.NextOffset = &end
+	} else {
+		end = len(rows)
+	}
+	status.Data = rows[offset:end]
+	return status
+}
+
+// expireJobs drops finished jobs older than the result TTL. Must be called
+// with the jobs mutex held.
+func (qr *QueryRouter) expireJobs() {
+	ttl := time.Duration(qr.config.Jobs.ResultTTLSeconds) * time.Second
+	for id, j := range qr.jobs {
+		if j.status != JobRunning && time.Since(j.finished) > ttl {
+			delete(qr.jobs, id)
+		}
+	}
+}
+
+// parsePage reads the offset and limit query parameters
+func parsePage(r *http.Request, defaultLimit int) (int, int, error) {
+	offset, limit := 0, defaultLimit
+	if value := r.URL.Query().Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+		offset = parsed
+	}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			return 0, 0, fmt.Errorf("limit must be a positive integer")
+		}
+		limit = parsed
+	}
+	return offset, limit, nil
+}
+
+// newJobID returns a random, unguessable job ID
+func newJobID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
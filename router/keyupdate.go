@@ -0,0 +1,86 @@
+package router
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"sql-horizontal-autoscaler/datastore"
+	"sql-horizontal-autoscaler/parser"
+)
+
+// updateShardKey handles an UPDATE assigning its table's shard key. With the
+// "move" policy, rows whose new key belongs to another shard are moved there
+// in one two-phase commit transaction; otherwise such updates are rejected.
+// It returns a nil response and error when the rows stay on their shard and
+// the update can be routed as usual.
+func (qr *QueryRouter) updateShardKey(r *http.Request, query string, parseResult *parser.ParseResult, database string) (*QueryResponse, *APIError) {
+	if qr.config.Updates.ShardKey != "move" || qr.xaLog == nil {
+		return nil, shardKeyUpdateError(parseResult,
+			"delete the row and insert it with the new key, or set updates.shard_key to \"move\"")
+	}
+	if !parseResult.HasShardKey || len(parseResult.ShardKeyValues) != 1 || parseResult.NewShardKeyValue == nil {
+		return nil, shardKeyUpdateError(parseResult,
+			fmt.Sprintf("moving rows requires SET %[1]s = <literal> WHERE %[1]s = <literal>", parseResult.ShardKeyColumn))
+	}
+
+	oldKey := fmt.Sprintf("%v", parseResult.ShardKeyValue)
+	newKey := fmt.Sprintf("%v", parseResult.NewShardKeyValue)
+	source, err := qr.shardManager.GetShardForTable(parseResult.TableName, oldKey)
+	if err != nil {
+		return nil, newAPIError(ErrCodeRouting, fmt.Sprintf("Failed to determine target shard: %v", err))
+	}
+	destination, err := qr.shardManager.GetShardForTable(parseResult.TableName, newKey)
+	if err != nil {
+		return nil, newAPIError(ErrCodeRouting, fmt.Sprintf("Failed to determine target shard: %v", err))
+	}
+	if source == destination {
+		return nil, nil
+	}
+
+	for _, shardID := range []string{source, destination} {
+		if err := qr.checkMaintenance(shardID, true); err != nil {
+			return nil, newAPIError(ErrCodeShardUnavailable, err.Error()).onShard(shardID)
+		}
+	}
+
+	table := "`" + parseResult.TableName + "`"
+	if parseResult.DatabaseName != "" {
+		table = "`" + parseResult.DatabaseName + "`." + table
+	}
+
+	log.Printf("🚚 Moving %s rows from %s to %s: %s %s → %s", parseResult.TableName, source, destination,
+		parseResult.ShardKeyColumn, oldKey, newKey)
+	results, err := qr.dataStore.ExecuteKeyMove(datastore.KeyMove{
+		Update:      query,
+		Table:       table,
+		KeyColumn:   parseResult.ShardKeyColumn,
+		NewKey:      parseResult.NewShardKeyValue,
+		Source:      source,
+		Destination: destination,
+		Database:    database,
+	}, qr.newXID(), qr.xaLog)
+	qr.auditWrites(r, query, parseResult, results)
+	if err != nil {
+		log.Printf("Failed to move %s rows from %s to %s: %v", parseResult.TableName, source, destination, err)
+		return nil, classifyExecutionError(err, "")
+	}
+
+	affected := results[0].RowsAffected
+	if err := checkVersionMatched(parseResult, affected); err != nil {
+		return nil, classifyExecutionError(err, source)
+	}
+	return &QueryResponse{Shards: []string{source, destination}, RowsAffected: &affected}, nil
+}
+
+// shardKeyUpdateError rejects an UPDATE assigning its table's shard key
+func shardKeyUpdateError(parseResult *parser.ParseResult, hint string) *APIError {
+	apiErr := newAPIError(ErrCodeKeyUpdate, fmt.Sprintf(
+		"UPDATE of shard key %s on %s could leave rows on the wrong shard; %s",
+		parseResult.ShardKeyColumn, parseResult.TableName, hint))
+	apiErr.Details = map[string]interface{}{
+		"table":     parseResult.TableName,
+		"shard_key": parseResult.ShardKeyColumn,
+	}
+	return apiErr
+}
@@ -0,0 +1,55 @@
+package router
+
+import (
+	"fmt"
+
+	"sql-horizontal-autoscaler/datastore"
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// checkMaintenance returns an error if a shard in maintenance, frozen
+// read-only, or in read-only fallback, cannot serve the query
+func (qr *QueryRouter) checkMaintenance(shardID string, isWrite bool) error {
+	if since, down := qr.dataStore.PrimaryDown(shardID); down && isWrite {
+		return &datastore.WriteUnavailableError{ShardID: shardID, Since: since}
+	}
+	if state, frozen := qr.shardManager.GetReadOnly(shardID); frozen && isWrite {
+		if state.Reason != "" {
+			return fmt.Errorf("shard %s is read-only: %s", shardID, state.Reason)
+		}
+		return fmt.Errorf("shard %s is read-only", shardID)
+	}
+
+	window, active := qr.shardManager.ActiveMaintenance(shardID)
+	if !active {
+		return nil
+	}
+
+	if window.Mode == sharding.MaintenanceExcluded {
+		return fmt.Errorf("shard %s is excluded for maintenance until %s", shardID, window.End.Format("15:04:05 MST"))
+	}
+	if isWrite {
+		return fmt.Errorf("shard %s is read-only for maintenance until %s", shardID, window.End.Format("15:04:05 MST"))
+	}
+	return nil
+}
+
+// filterMaintenance drops excluded shards from a read fan-out. Writes fail if
+// any target shard cannot accept them, so they are never applied partially.
+func (qr *QueryRouter) filterMaintenance(shardIDs []string, isWrite bool) ([]string, error) {
+	available := make([]string, 0, len(shardIDs))
+	for _, shardID := range shardIDs {
+		if err := qr.checkMaintenance(shardID, isWrite); err != nil {
+			if isWrite {
+				return nil, err
+			}
+			continue
+		}
+		available = append(available, shardID)
+	}
+
+	if len(available) == 0 {
+		return nil, fmt.Errorf("all target shards are in maintenance")
+	}
+	return available, nil
+}
@@ -0,0 +1,135 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sql-horizontal-autoscaler/config"
+	"sql-horizontal-autoscaler/parser"
+)
+
+// tautologyRule names violations of policy.reject_tautologies
+const tautologyRule = "reject_tautologies"
+
+// PolicyViolations counts the statements one rule refused to one tenant on
+// one table
+type PolicyViolations struct {
+	Rule      string    `json:"rule"`
+	Tenant    string    `json:"tenant"`
+	Table     string    `json:"table"`
+	Statement string    `json:"statement"`
+	Count     int64     `json:"count"`
+	LastAt    time.Time `json:"last_at"`
+}
+
+// policyEngine checks parsed statements against the configured policy and
+// counts what it refuses
+type policyEngine struct {
+	config     config.PolicyConfig
+	violations map[PolicyViolations]*PolicyViolations
+	mutex      sync.Mutex
+}
+
+// newPolicyEngine creates an engine for the configured policy
+func newPolicyEngine(cfg config.PolicyConfig) *policyEngine {
+	return &policyEngine{config: cfg, violations: make(map[PolicyViolations]*PolicyViolations)}
+}
+
+// check refuses a statement that a rule matching the tenant and table
+// doesn't allow, or that looks injected
+func (pe *policyEngine) check(tenant string, parseResult *parser.ParseResult) *APIError {
+	table := parseResult.TableName
+	if parseResult.DatabaseName != "" {
+		table = parseResult.DatabaseName + "." + table
+	}
+	statement := parseResult.StatementType
+
+	if pe.config.RejectTautologies && parseResult.AlwaysTrue {
+		return pe.refuse(tautologyRule, tenant, table, statement,
+			fmt.Sprintf("%s on %s has a WHERE clause that is always true", strings.ToUpper(statement), table))
+	}
+	for _, rule := range pe.config.Rules {
+		if !policyMatches(rule.Tenants, tenant) || !(policyMatches(rule.Tables, table) || policyMatches(rule.Tables, parseResult.TableName)) {
+			continue
+		}
+		if (len(rule.Allow) > 0 && !policyMatches(rule.Allow, statement)) || (len(rule.Deny) > 0 && policyMatches(rule.Deny, statement)) {
+			return pe.refuse(rule.Name, tenant, table, statement,
+				fmt.Sprintf("%s on %s is not allowed for %s by policy rule %s", strings.ToUpper(statement), table, tenant, rule.Name))
+		}
+	}
+	return nil
+}
+
+// refuse logs and counts a violation and returns its error
+func (pe *policyEngine) refuse(rule, tenant, table, statement, message string) *APIError {
+	key := PolicyViolations{Rule: rule, Tenant: tenant, Table: table, Statement: statement}
+
+	pe.mutex.Lock()
+	violations, exists := pe.violations[key]
+	if !exists {
+		violations = &key
+		pe.violations[key] = violations
+	}
+	violations.Count++
+	violations.LastAt = time.Now()
+	pe.mutex.Unlock()
+
+	log.Printf("🚫 Policy violation: %s", message)
+	apiErr := newAPIError(ErrCodePolicy, message)
+	apiErr.Details = map[string]interface{}{
+		"rule":      rule,
+		"tenant":    tenant,
+		"table":     table,
+		"statement": statement,
+	}
+	return apiErr
+}
+
+// snapshot returns the violation counts, most frequent first
+func (pe *policyEngine) snapshot() []PolicyViolations {
+	pe.mutex.Lock()
+	defer pe.mutex.Unlock()
+
+	snapshot := make([]PolicyViolations, 0, len(pe.violations))
+	for _, violations := range pe.violations {
+		snapshot = append(snapshot, *violations)
+	}
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].Count > snapshot[j].Count })
+	return snapshot
+}
+
+// policyMatches reports whether a rule's list selects a value; an empty list
+// selects everything
+func policyMatches(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, entry := range list {
+		if entry == value {
+			return true
+		}
+	}
+	return false
+}
+
+// handlePolicy handles GET /policy, reporting the configured rules and how
+// often each refused a statement
+func (qr *QueryRouter) handlePolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules":              qr.policy.config.Rules,
+		"reject_tautologies": qr.policy.config.RejectTautologies,
+		"violations":         qr.policy.snapshot(),
+	})
+}
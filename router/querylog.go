@@ -0,0 +1,53 @@
+package router
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"sql-horizontal-autoscaler/middleware"
+	"sql-horizontal-autoscaler/querylog"
+)
+
+// SetQueryLog enables sampled logging of routed queries
+func (qr *QueryRouter) SetQueryLog(queryLog *querylog.Logger) {
+	qr.queryLog = queryLog
+}
+
+// logQuery records a routed query in the query log if its table is sampled.
+// Queries that fail to parse are sampled at the default rate.
+func (qr *QueryRouter) logQuery(r *http.Request, req QueryRequest, response *QueryResponse, apiErr *APIError, latency time.Duration) {
+	if qr.queryLog == nil {
+		return
+	}
+
+	entry := querylog.Entry{
+		RequestID: middleware.RequestIDFromContext(r.Context()),
+		Actor:     middleware.Actor(r),
+		Query:     req.Query,
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+	}
+	if parseResult, err := qr.parseCache.Parse(req.Query, qr.config.TableShardKeys); err == nil {
+		entry.StatementType = parseResult.StatementType
+		entry.Table = parseResult.TableName
+		if parseResult.DatabaseName != "" {
+			entry.Table = parseResult.DatabaseName + "." + parseResult.TableName
+		}
+	}
+	if !qr.queryLog.Sampled(entry.Table) {
+		return
+	}
+
+	if response != nil {
+		entry.Shard = response.Shard
+		entry.Shards = response.Shards
+		entry.Rows = len(response.Data)
+		entry.RowsAffected = response.RowsAffected
+	}
+	if apiErr != nil {
+		entry.Error = apiErr.Error()
+	}
+	if err := qr.queryLog.Record(entry); err != nil {
+		log.Printf("Warning: Failed to log query: %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"sql-horizontal-autoscaler/metrics"
+	"sql-horizontal-autoscaler/sharding"
+)
+
+// routerHeartbeat mirrors the coordinator's heartbeat request body
+type routerHeartbeat struct {
+	ID              string      `json:"id"`
+	Address         string      `json:"address"`
+	TopologyVersion string      `json:"topology_version"`
+	Stats           routerStats `json:"stats"`
+}
+
+// routerStats are the query statistics reported to the coordinator
+type routerStats struct {
+	Queries       int64   `json:"queries"`
+	Errors        int64   `json:"errors"`
+	QueriesPerSec float64 `json:"queries_per_second"`
+	// ShardErrors counts the statements that failed on each shard by cause
+	// since the router started
+	ShardErrors map[string]metrics.QueryErrors `json:"shard_errors,omitempty"`
+}
+
+// registrationLoop registers the router with the coordinator and sends
+// heartbeats, re-registering whenever the coordinator forgets the router
+func (qr *QueryRouter) registrationLoop() {
+	interval := time.Duration(qr.config.Routers.HeartbeatIntervalSeconds) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	registered := false
+	lastQueries := int64(0)
+	lastBeat := time.Now()
+
+	for {
+		queries := atomic.LoadInt64(&qr.queryCount)
+		elapsed := time.Since(lastBeat).Seconds()
+		heartbeat := routerHeartbeat{
+			ID:              qr.routerID(),
+			Address:         qr.config.Routers.AdvertiseAddress,
+			TopologyVersion: qr.topologyVersion(),
+			Stats: routerStats{
+				Queries: queries,
+				Errors:  atomic.LoadInt64(&qr.errorCount),
+			},
+		}
+		if !qr.colocated {
+			heartbeat.Stats.ShardErrors = qr.shardErrors()
+		}
+		if elapsed > 0 {
+			heartbeat.Stats.QueriesPerSec = float64(queries-lastQueries) / elapsed
+		}
+		lastQueries, lastBeat = queries, time.Now()
+		qr.registrationBeat.Beat()
+
+		path := "/routers/heartbeat"
+		if !registered {
+			path = "/routers/register"
+		}
+
+		status, err := qr.sendHeartbeat(path, heartbeat)
+		switch {
+		case err != nil:
+			log.Printf("Warning: Failed to reach coordinator: %v", err)
+			registered = false
+		case status == http.StatusNotFound:
+			registered = false
+		default:
+			registered = true
+		}
+
+		<-ticker.C
+	}
+}
+
+// shardErrors returns the classified errors of every shard a statement failed on
+func (qr *QueryRouter) shardErrors() map[string]metrics.QueryErrors {
+	byShard := make(map[string]metrics.QueryErrors)
+	for shardID, counters := range qr.dataStore.ShardCounters() {
+		if counters.Errors > 0 {
+			byShard[shardID] = counters.ErrorClasses
+		}
+	}
+	return byShard
+}
+
+// SetColocated marks the router as sharing its datastore with the
+// coordinator, which then reads the router's shard error counts itself
+// rather than from heartbeats
+func (qr *QueryRouter) SetColocated() {
+	qr.colocated = true
+}
+
+// sendHeartbeat posts a heartbeat and applies the topology in the response
+func (qr *QueryRouter) sendHeartbeat(path string, heartbeat routerHeartbeat) (int, error) {
+	body, err := json.Marshal(heartbeat)
+	if err != nil {
+		return 0, err
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post(qr.config.Routers.CoordinatorURL+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+
+	var topology sharding.Topology
+	if err := json.NewDecoder(resp.Body).Decode(&topology); err != nil {
+		return resp.StatusCode, fmt.Errorf("invalid topology in response: %w", err)
+	}
+	qr.applyTopology(topology)
+	return resp.StatusCode, nil
+}
+
+// handleTopology handles POST /topology pushes from the coordinator, which
+// must be signed with the routers' push secret
+func (qr *QueryRouter) handleTopology(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	secret := qr.config.TopologyPushSecret()
+	if secret == "" {
+		http.Error(w, "Topology pushes are not enabled", http.StatusForbidden)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid topology", http.StatusBadRequest)
+		return
+	}
+	if !sharding.VerifyTopology(secret, body, r.Header.Get(sharding.TopologySignatureHeader)) {
+		log.Printf("Warning: Rejected topology push with an invalid signature from %s", r.RemoteAddr)
+		http.Error(w, "Invalid topology signature", http.StatusUnauthorized)
+		return
+	}
+
+	var topology sharding.Topology
+	if err := json.Unmarshal(body, &topology); err != nil {
+		http.Error(w, "Invalid topology", http.StatusBadRequest)
+		return
+	}
+
+	qr.applyTopology(topology)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyTopology records the topology version the router is serving. Routers
+// that don't share the coordinator's shard manager also adopt its shards,
+// directory and time ranges; a version whose shards couldn't all be adopted
+// isn't recorded, so the coordinator sends it again.
+func (qr *QueryRouter) applyTopology(topology sharding.Topology) {
+	qr.applyMutex.Lock()
+	defer qr.applyMutex.Unlock()
+
+	if topology.Version == qr.topologyVersion() {
+		return
+	}
+
+	if qr.config.Routers.SyncDirectory {
+		qr.shardManager.RestoreDirectory(topology.Directory)
+		qr.shardManager.RestoreTimeRanges(topology.TimeRanges)
+		qr.shardManager.RestoreRingWeights(topology.RingWeights)
+		if !qr.shardManager.AdoptShards(topology, qr.connectShard) {
+			return
+		}
+	}
+	if ring := qr.shardManager.RingLayout().Checksum; topology.Ring != "" && topology.Ring != ring {
+		log.Printf("Warning: Router ring %s differs from coordinator ring %s; import the coordinator's GET /topology/ring with ring.layout_path", ring, topology.Ring)
+	}
+	qr.topologyMutex.Lock()
+	qr.appliedTopology = topology.Version
+	qr.topologyMutex.Unlock()
+	log.Printf("Applied topology %s from coordinator", topology.Version)
+}
+
+// connectShard opens the connection to a shard adopted from the coordinator's
+// topology
+func (qr *QueryRouter) connectShard(shardInfo *sharding.ShardInfo) error {
+	if err := qr.dataStore.AddShardConnection(shardInfo.ID, shardInfo.DSN, qr.config.QualifiedTableNames()); err != nil {
+		return err
+	}
+	qr.config.SetShard(shardInfo.ID, shardInfo.DSN)
+	return nil
+}
+
+// handleRingLayout handles GET /topology/ring, exporting the token ownership
+// of the router's ring
+func (qr *QueryRouter) handleRingLayout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(qr.shardManager.RingLayout())
+}
+
+// topologyVersion returns the version of the topology the router is serving
+func (qr *QueryRouter) topologyVersion() string {
+	qr.topologyMutex.RLock()
+	defer qr.topologyMutex.RUnlock()
+
+	if qr.appliedTopology != "" {
+		return qr.appliedTopology
+	}
+	return qr.shardManager.Topology().Version
+}
+
+// routerID returns the configured router ID, defaulting to the router's port
+func (qr *QueryRouter) routerID() string {
+	if qr.config.Routers.ID != "" {
+		return qr.config.Routers.ID
+	}
+	return fmt.Sprintf("query-router-%d", qr.config.Ports.QueryRouterPort)
+}
@@ -0,0 +1,152 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"sql-horizontal-autoscaler/datastore"
+	"sql-horizontal-autoscaler/parser"
+)
+
+// rewriteQuery applies the configured AST rewrites to a query. multiShard
+// controls whether the LIMIT is pushed down to each shard.
+func (qr *QueryRouter) rewriteQuery(query string, parseResult *parser.ParseResult, database string, multiShard bool) *parser.RewriteResult {
+	rewriteConfig := qr.config.Rewrite
+	unchanged := &parser.RewriteResult{Query: query, Limit: -1}
+	if !rewriteConfig.Enabled {
+		return unchanged
+	}
+
+	opts := parser.RewriteOptions{
+		StripColumns:  rewriteConfig.StripColumns,
+		InjectColumns: rewriteConfig.InjectColumns[parseResult.TableName],
+		PushDownLimit: rewriteConfig.PushDownLimit && multiShard,
+	}
+
+	if rewriteConfig.ExpandStar {
+		columns, err := qr.tableColumns(database, parseResult)
+		if err != nil {
+			log.Printf("Warning: Cannot expand SELECT * for table %s: %v", parseResult.TableName, err)
+		}
+		opts.TableColumns = columns
+	}
+
+	result, err := parser.Rewrite(query, opts)
+	if err != nil {
+		log.Printf("Warning: Query rewrite failed, executing original query: %v", err)
+		return unchanged
+	}
+
+	if result.Query != query {
+		log.Printf("Rewrote query: %s", result.Query)
+	}
+	return result
+}
+
+// readOnShards runs a read on several shards, at most the table's scatter
+// parallelism at a time, returning early once a pushed-down LIMIT has been
+// satisfied when the query allows it
+func (qr *QueryRouter) readOnShards(ctx context.Context, rewritten *parser.RewriteResult, parseResult *parser.ParseResult, shardIDs []string, database string, maxStaleness time.Duration) ([]map[string]interface{}, error) {
+	stopAfter := 0
+	if rewritten.StopAtLimit && len(shardIDs) > 1 {
+		stopAfter = rewritten.Offset + rewritten.Limit
+	}
+	maxParallel := qr.scatterParallelism(parseResult, database)
+	if maxParallel > 0 && maxParallel < len(shardIDs) {
+		log.Printf("Querying %d shards at most %d at a time", len(shardIDs), maxParallel)
+	}
+	return qr.dataStore.ExecuteReadOnShardsUntil(ctx, rewritten.Query, shardIDs, database, maxStaleness, stopAfter, qr.resultLimit(len(shardIDs) > 1), maxParallel)
+}
+
+// scatterParallelism returns how many shards a read of the query's table may
+// query at once, preferring a "db.table" entry; 0 is unlimited
+func (qr *QueryRouter) scatterParallelism(parseResult *parser.ParseResult, database string) int {
+	scatter := qr.config.Scatter
+	if database == "" {
+		database = parseResult.DatabaseName
+	}
+	if database != "" {
+		if parallel, exists := scatter.TableMaxParallel[database+"."+parseResult.TableName]; exists {
+			return parallel
+		}
+	}
+	if parallel, exists := scatter.TableMaxParallel[parseResult.TableName]; exists {
+		return parallel
+	}
+	return scatter.MaxParallel
+}
+
+// resultLimit returns the configured cap for a read on one shard or several
+func (qr *QueryRouter) resultLimit(multiShard bool) datastore.ResultLimit {
+	limit := qr.config.Results.SingleShard
+	if multiShard {
+		limit = qr.config.Results.ScatterGather
+	}
+	return datastore.ResultLimit{MaxRows: limit.MaxRows, MaxBytes: limit.MaxBytes}
+}
+
+// truncateOnLimit reports whether a read stopped at the result limit should
+// be answered with its rows flagged as truncated, clearing err if so
+func (qr *QueryRouter) truncateOnLimit(err error) (bool, error) {
+	var tooLarge *datastore.ResultTooLargeError
+	if errors.As(err, &tooLarge) && qr.config.Results.OnLimit == "truncate" {
+		return true, nil
+	}
+	return false, err
+}
+
+// tableColumns returns the (cached) column list of the query's table
+func (qr *QueryRouter) tableColumns(database string, parseResult *parser.ParseResult) ([]string, error) {
+	if database == "" {
+		database = parseResult.DatabaseName
+	}
+	cacheKey := database + "." + parseResult.TableName
+
+	qr.columnMutex.RLock()
+	columns, exists := qr.columnCache[cacheKey]
+	qr.columnMutex.RUnlock()
+	if exists {
+		return columns, nil
+	}
+
+	shards := qr.shardManager.GetAllShards()
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("no active shards")
+	}
+
+	columns, err := qr.dataStore.GetTableColumns(shards[0], database, parseResult.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	qr.columnMutex.Lock()
+	qr.columnCache[cacheKey] = columns
+	qr.columnMutex.Unlock()
+
+	return columns, nil
+}
+
+// applyRewriteToResults undoes the rewrite on merged rows: injected columns are
+// removed and a pushed-down LIMIT/OFFSET is applied to the merged set
+func applyRewriteToResults(data []map[string]interface{}, result *parser.RewriteResult) []map[string]interface{} {
+	for _, row := range data {
+		for _, col := range result.InjectedColumns {
+			delete(row, col)
+		}
+	}
+
+	if result.Limit < 0 {
+		return data
+	}
+	if result.Offset >= len(data) {
+		return []map[string]interface{}{}
+	}
+	end := result.Offset + result.Limit
+	if end > len(data) {
+		end = len(data)
+	}
+	return data[result.Offset:end]
+}
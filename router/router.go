@@ -5,10 +5,17 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"sql-horizontal-autoscaler/audit"
 	"sql-horizontal-autoscaler/config"
 	"sql-horizontal-autoscaler/datastore"
+	"sql-horizontal-autoscaler/health"
+	"sql-horizontal-autoscaler/middleware"
 	"sql-horizontal-autoscaler/parser"
+	"sql-horizontal-autoscaler/querylog"
 	"sql-horizontal-autoscaler/sharding"
 )
 
@@ -17,11 +24,76 @@ type QueryRouter struct {
 	config       *config.Config
 	dataStore    *datastore.DataStore
 	shardManager *sharding.DynamicShardManager
+	columnCache  map[string][]string
+	columnMutex  sync.RWMutex
+	// parseCache keeps the parse results of recent queries
+	parseCache *parser.Cache
+	// tableRows caches the estimated size of tables read by scatter-gather
+	tableRows      map[string]tableRowsEstimate
+	tableRowsMutex sync.Mutex
+
+	queryCount      int64
+	errorCount      int64
+	appliedTopology string
+	topologyMutex   sync.RWMutex
+	// applyMutex serializes applying pushed and polled topologies
+	applyMutex sync.Mutex
+
+	auditLog *audit.Logger
+	queryLog *querylog.Logger
+	usage    *usageTracker
+	policy   *policyEngine
+	// templates are the named queries clients may execute
+	templates *templateRegistry
+
+	// xaLog records commit decisions when multi-shard writes use two-phase commit
+	xaLog        *datastore.XALog
+	xaSequence   uint64
+	recoveryBeat *health.Heartbeat
+	// registrationBeat tracks the registration loop for the liveness probe
+	registrationBeat *health.Heartbeat
+	// colocated is set when the coordinator runs in the same process
+	colocated bool
+	// writeBuffer journals writes for unreachable shards when enabled
+	writeBuffer *writeBuffer
+	replayBeat  *health.Heartbeat
+
+	// jobs holds queries run through POST /query/async
+	jobs      map[string]*job
+	jobsMutex sync.Mutex
+
+	// shardStats samples the load each shard takes through this router
+	shardStats *shardStats
+
+	// shardGroups are the routers of other shard groups, by table
+	shardGroups map[string]*QueryRouter
 }
 
 // QueryRequest represents the incoming query request
 type QueryRequest struct {
 	Query string `json:"query"`
+	// Consistency is "strong" (default), "eventual", "snapshot" or
+	// "bounded_staleness(N)" and controls whether reads may be served by
+	// replicas
+	Consistency string `json:"consistency,omitempty"`
+	// ShardSelector restricts scatter-gather to shards whose labels match,
+	// e.g. "region=us-east,tier!=cold"
+	ShardSelector string `json:"shard_selector,omitempty"`
+	// AllowDangerous confirms a DELETE or UPDATE without a WHERE clause, a
+	// TRUNCATE or a DROP
+	AllowDangerous bool `json:"allow_dangerous,omitempty"`
+	// TimeoutMs bounds the query, like an X-Request-Deadline header; the
+	// earlier of the two applies
+	TimeoutMs int64 `json:"timeout_ms,omitempty"`
+	// ExpectedVersion is the version an UPDATE of a table with a version
+	// column must find the row at, failing with a conflict otherwise
+	ExpectedVersion *int64 `json:"expected_version,omitempty"`
+	// WriteConcern is "all", "quorum" or "one" and decides how many shards
+	// must apply a write sent to several of them
+	WriteConcern string `json:"write_concern,omitempty"`
+
+	// template is the template the query was bound from, if any
+	template string
 }
 
 // QueryResponse represents the response to a query
@@ -29,15 +101,41 @@ type QueryResponse struct {
 	Data   []map[string]interface{} `json:"data"`
 	Shard  string                   `json:"shard,omitempty"`
 	Shards []string                 `json:"shards,omitempty"`
-	Error  string                   `json:"error,omitempty"`
+	// RowsAffected is set for write statements
+	RowsAffected *int64 `json:"rows_affected,omitempty"`
+	// Truncated is set when the rows were cut at the result limit
+	Truncated bool `json:"truncated,omitempty"`
+	// Repairing lists the shards a write failed on that it is being
+	// repaired on in the background, as its write concern allowed
+	Repairing []string `json:"repairing,omitempty"`
+	// Buffered lists the shards a write couldn't reach that it was journaled
+	// for, to be applied once they are back; it affected no rows on them yet
+	Buffered []string `json:"buffered,omitempty"`
+	// SchemaDrift lists the shards whose answer to SHOW or DESCRIBE differs
+	// from the one returned
+	SchemaDrift []string `json:"schema_drift,omitempty"`
+	// Plans holds each shard's plan for an EXPLAIN, keyed by shard
+	Plans map[string][]map[string]interface{} `json:"plans,omitempty"`
+	Error *APIError                           `json:"error,omitempty"`
 }
 
 // NewQueryRouter creates a new QueryRouter instance
 func NewQueryRouter(cfg *config.Config, ds *datastore.DataStore, sm *sharding.DynamicShardManager) *QueryRouter {
 	return &QueryRouter{
-		config:       cfg,
-		dataStore:    ds,
-		shardManager: sm,
+		config:           cfg,
+		dataStore:        ds,
+		shardManager:     sm,
+		columnCache:      make(map[string][]string),
+		parseCache:       parser.NewCache(cfg.ParserCache.Size),
+		tableRows:        make(map[string]tableRowsEstimate),
+		usage:            newUsageTracker(cfg.Tenants),
+		policy:           newPolicyEngine(cfg.Policy),
+		templates:        newTemplateRegistry(cfg),
+		registrationBeat: health.NewHeartbeat(),
+		recoveryBeat:     health.NewHeartbeat(),
+		replayBeat:       health.NewHeartbeat(),
+		jobs:             make(map[string]*job),
+		shardStats:       &shardStats{lastAt: time.Now()},
 	}
 }
 
@@ -45,11 +143,42 @@ func NewQueryRouter(cfg *config.Config, ds *datastore.DataStore, sm *sharding.Dy
 func (qr *QueryRouter) Start() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/query", qr.handleQuery)
+	mux.HandleFunc("/query/async", qr.handleAsyncQuery)
+	mux.HandleFunc("/jobs/", qr.handleJob)
+	mux.HandleFunc("/batch", qr.handleBatch)
+	mux.HandleFunc("/ws", qr.handleStream)
 	mux.HandleFunc("/health", qr.handleHealth)
+	mux.HandleFunc("/livez", health.Handler("query-router", qr.livenessChecks()))
+	mux.HandleFunc("/readyz", health.Handler("query-router", qr.readinessChecks()))
+	mux.HandleFunc("/topology", qr.handleTopology)
+	mux.HandleFunc("/topology/ring", qr.handleRingLayout)
+	mux.HandleFunc("/usage", qr.handleUsage)
+	mux.HandleFunc("/policy", qr.handlePolicy)
+	mux.HandleFunc("/templates", qr.handleTemplates)
+	mux.HandleFunc("/templates/", qr.handleTemplate)
+	mux.HandleFunc("/export", qr.handleExport)
+	mux.HandleFunc("/stats/shards", qr.handleShardStats)
+	mux.HandleFunc("/stats/parser", qr.handleParserStats)
+	mux.HandleFunc("/transactions", qr.handleTransactions)
+	mux.HandleFunc("/transactions/", qr.handleTransactionRoutes)
+	mux.HandleFunc("/write-buffer", qr.handleWriteBuffer)
+	mux.HandleFunc("/write-buffer/", qr.handleWriteBufferShard)
+
+	if qr.config.Routers.CoordinatorURL != "" {
+		go qr.registrationLoop()
+	}
+	if qr.xaLog != nil {
+		go qr.xaRecoveryLoop()
+	}
+	if qr.writeBuffer != nil {
+		go qr.writeReplayLoop()
+	}
+	go qr.shardStatsLoop()
 
 	port := fmt.Sprintf(":%d", qr.config.Ports.QueryRouterPort)
 	log.Printf("Query Router starting on port %d...", qr.config.Ports.QueryRouterPort)
-	return http.ListenAndServe(port, mux)
+	server := qr.config.HTTPServer(port, middleware.Chain(mux, middleware.ForService("query-router", qr.config.HTTP)...))
+	return server.ListenAndServe()
 }
 
 // handleQuery handles POST /query requests
@@ -62,23 +191,144 @@ func (qr *QueryRouter) handleQuery(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
 	var req QueryRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		qr.sendErrorResponse(w, "Invalid JSON request", http.StatusBadRequest)
+		atomic.AddInt64(&qr.queryCount, 1)
+		qr.sendError(w, newAPIError(ErrCodeInvalidRequest, "Invalid JSON request"))
 		return
 	}
 
-	if req.Query == "" {
-		qr.sendErrorResponse(w, "Query cannot be empty", http.StatusBadRequest)
+	response, apiErr := qr.executeQuery(r, req)
+	if apiErr != nil {
+		qr.sendError(w, apiErr)
 		return
 	}
+	qr.sendResponse(w, *response)
+}
 
-	log.Printf("Received query: %s", req.Query)
+// executeQuery executes a single query request within the quotas of the
+// tenant behind it, accounting the tenant's usage
+func (qr *QueryRouter) executeQuery(r *http.Request, req QueryRequest) (*QueryResponse, *APIError) {
+	tenant := qr.usage.tenant(r)
+	if apiErr := qr.checkTemplateOnly(tenant, req); apiErr != nil {
+		atomic.AddInt64(&qr.queryCount, 1)
+		return nil, apiErr
+	}
+	if apiErr := qr.usage.check(tenant); apiErr != nil {
+		atomic.AddInt64(&qr.queryCount, 1)
+		return nil, apiErr
+	}
+
+	ctx, cancel, apiErr := requestDeadline(r, req)
+	if apiErr != nil {
+		atomic.AddInt64(&qr.queryCount, 1)
+		return nil, apiErr
+	}
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	start := time.Now()
+	response, apiErr := qr.routeQuery(r, req)
+	if apiErr != nil && apiErr.Code != ErrCodeDeadlineExceeded && deadlinePassed(ctx) {
+		var pending []string
+		if apiErr.Shard != "" {
+			pending = []string{apiErr.Shard}
+		}
+		apiErr = deadlineExceeded("execution", nil, pending)
+	}
+	latency := time.Since(start)
+	qr.usage.record(tenant, response, latency)
+	qr.logQuery(r, req, response, apiErr, latency)
+	return response, apiErr
+}
+
+// routeQuery parses, routes and executes a single query request
+func (qr *QueryRouter) routeQuery(r *http.Request, req QueryRequest) (*QueryResponse, *APIError) {
+	atomic.AddInt64(&qr.queryCount, 1)
+
+	if req.Query == "" {
+		return nil, newAPIError(ErrCodeInvalidRequest, "Query cannot be empty")
+	}
+
+	maxStaleness, err := parseConsistency(req.Consistency)
+	if err != nil {
+		return nil, newAPIError(ErrCodeInvalidRequest, err.Error())
+	}
+
+	selector, err := sharding.ParseSelector(req.ShardSelector)
+	if err != nil {
+		return nil, newAPIError(ErrCodeInvalidRequest, err.Error())
+	}
+
+	log.Printf("Received query (request %s): %s", middleware.RequestIDFromContext(r.Context()), req.Query)
+
+	if prefix, statement, isExplain := parser.SplitExplain(req.Query); isExplain {
+		return qr.explain(r, prefix, statement, selector)
+	}
 
 	// Parse the SQL query to extract shard key information
-	parseResult, err := parser.Parse(req.Query, qr.config.TableShardKeys)
+	parseResult, err := qr.parseCache.Parse(req.Query, qr.config.TableShardKeys)
 	if err != nil {
 		log.Printf("Failed to parse query: %v", err)
-		qr.sendErrorResponse(w, fmt.Sprintf("Failed to parse query: %v", err), http.StatusBadRequest)
-		return
+		return nil, newAPIError(ErrCodeParse, fmt.Sprintf("Failed to parse query: %v", err))
+	}
+	if deadlinePassed(r.Context()) {
+		return nil, deadlineExceeded("parsing", nil, nil)
+	}
+
+	// Tables of other shard groups are routed by their group's router
+	if groupRouter, exists := qr.shardGroups[parseResult.TableName]; exists {
+		return groupRouter.routeQuery(r, req)
+	}
+
+	if apiErr := qr.policy.check(qr.usage.tenant(r), parseResult); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := qr.checkDangerous(req, parseResult); apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Writes always go to the primary, on as many shards as their write
+	// concern requires
+	concern := WriteConcernAll
+	if parseResult.IsWrite() {
+		maxStaleness = datastore.StalenessStrong
+		var apiErr *APIError
+		if concern, apiErr = qr.writeConcern(req, parseResult); apiErr != nil {
+			return nil, apiErr
+		}
+	}
+
+	if err := qr.checkCapacity(parseResult); err != nil {
+		return nil, newAPIError(ErrCodeAtCapacity, err.Error())
+	}
+
+	// Unqualified tables that live in a non-default database run against that schema
+	database := ""
+	if parseResult.DatabaseName == "" {
+		database = qr.config.TableDatabases[parseResult.TableName]
+	}
+
+	if parseResult.IsAdmin() {
+		return qr.executeAdmin(r, req.Query, parseResult, database, maxStaleness)
+	}
+
+	if qr.config.IsBroadcastTable(parseResult.TableName) {
+		return qr.executeBroadcast(r, req.Query, parseResult, database, maxStaleness, concern)
+	}
+
+	defaultShard, apiErr := qr.applyInsertKeyPolicy(&req, parseResult)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if defaultShard != "" {
+		return qr.executeOnDefaultShard(r, req.Query, parseResult, defaultShard, database)
+	}
+	if apiErr := qr.applyVersionCheck(&req, parseResult); apiErr != nil {
+		return nil, apiErr
+	}
+	if parseResult.UpdatesShardKey {
+		if response, apiErr := qr.updateShardKey(r, req.Query, parseResult, database); response != nil || apiErr != nil {
+			return response, apiErr
+		}
 	}
 
 	var response QueryResponse
@@ -86,51 +336,195 @@ func (qr *QueryRouter) handleQuery(w http.ResponseWriter, r *http.Request) {
 	if parseResult.HasShardKey {
 		// Single shard query - use consistent hashing to determine target shard
 		shardKeyStr := fmt.Sprintf("%v", parseResult.ShardKeyValue)
-		targetShard, err := qr.shardManager.GetShard(shardKeyStr)
+		targetShard, err := qr.shardManager.GetShardForTable(parseResult.TableName, shardKeyStr)
 		if err != nil {
 			log.Printf("Failed to determine target shard: %v", err)
-			qr.sendErrorResponse(w, fmt.Sprintf("Failed to determine target shard: %v", err), http.StatusInternalServerError)
-			return
+			return nil, newAPIError(ErrCodeRouting, fmt.Sprintf("Failed to determine target shard: %v", err))
+		}
+
+		if err := qr.checkMaintenance(targetShard, parseResult.IsWrite()); err != nil {
+			return nil, newAPIError(ErrCodeShardUnavailable, err.Error()).onShard(targetShard)
 		}
 
 		log.Printf("Routing query to single shard: %s (key: %s)", targetShard, shardKeyStr)
 
 		// Execute query on the target shard
-		data, err := qr.dataStore.ExecuteQuery(req.Query, targetShard)
+		rewritten := qr.rewriteQuery(req.Query, parseResult, database, false)
+		if parseResult.IsWrite() {
+			affected, buffered, err := qr.executeWrite(r, rewritten.Query, parseResult, []string{targetShard}, database)
+			if err != nil {
+				log.Printf("Failed to execute query on shard %s: %v", targetShard, err)
+				return nil, classifyExecutionError(err, targetShard)
+			}
+			return &QueryResponse{Shard: targetShard, RowsAffected: &affected, Buffered: buffered}, nil
+		}
+
+		data, fromReplica, err := qr.dataStore.ExecuteReadLimited(r.Context(), rewritten.Query, targetShard, database, maxStaleness, qr.resultLimit(false))
+		truncated, err := qr.truncateOnLimit(err)
 		if err != nil {
 			log.Printf("Failed to execute query on shard %s: %v", targetShard, err)
-			qr.sendErrorResponse(w, fmt.Sprintf("Failed to execute query: %v", err), http.StatusInternalServerError)
-			return
+			return nil, classifyExecutionError(err, targetShard)
+		}
+
+		if fromReplica {
+			log.Printf("Served query from a replica of shard %s", targetShard)
 		}
 
 		response = QueryResponse{
-			Data:  data,
-			Shard: targetShard,
+			Data:      applyRewriteToResults(data, rewritten),
+			Shard:     targetShard,
+			Truncated: truncated,
+		}
+	} else if len(parseResult.ShardKeyValues) > 1 {
+		// Multi-key query - execute only on the shards owning the keys
+		targetShards, err := qr.shardsForKeys(parseResult.TableName, parseResult.ShardKeyValues)
+		if err != nil {
+			log.Printf("Failed to determine target shards: %v", err)
+			return nil, newAPIError(ErrCodeRouting, fmt.Sprintf("Failed to determine target shards: %v", err))
+		}
+
+		targetShards, err = qr.filterMaintenance(targetShards, parseResult.IsWrite())
+		if err != nil {
+			return nil, newAPIError(ErrCodeShardUnavailable, err.Error())
+		}
+
+		log.Printf("Routing query to %d shards for %d keys: %v", len(targetShards), len(parseResult.ShardKeyValues), targetShards)
+
+		rewritten := qr.rewriteQuery(req.Query, parseResult, database, len(targetShards) > 1)
+		if parseResult.IsWrite() {
+			affected, repairing, buffered, err := qr.executeWriteConcern(r, rewritten.Query, parseResult, targetShards, database, concern)
+			if err != nil {
+				log.Printf("Failed to execute multi-key query: %v", err)
+				return nil, classifyExecutionError(err, "")
+			}
+			return &QueryResponse{Shards: targetShards, RowsAffected: &affected, Repairing: repairing, Buffered: buffered}, nil
+		}
+
+		data, err := qr.readOnShards(r.Context(), rewritten, parseResult, targetShards, database, maxStaleness)
+		truncated, err := qr.truncateOnLimit(err)
+		if err != nil {
+			log.Printf("Failed to execute multi-key query: %v", err)
+			return nil, classifyExecutionError(err, "")
+		}
+
+		data, err = qr.dedupeDistinct(data, parseResult, len(targetShards))
+		if err != nil {
+			return nil, newAPIError(ErrCodeInternal, err.Error())
+		}
+
+		response = QueryResponse{
+			Data:      applyRewriteToResults(data, rewritten),
+			Shards:    targetShards,
+			Truncated: truncated,
 		}
 	} else {
 		// Scatter-gather query - execute on all shards
-		log.Printf("Performing scatter-gather query across all shards")
+		targetShards := qr.dataStore.ShardIDs()
+		if selector.Empty() {
+			log.Printf("Performing scatter-gather query across all shards")
+		} else {
+			targetShards = intersectShards(targetShards, qr.shardManager.ShardsMatching(selector))
+			if len(targetShards) == 0 {
+				return nil, newAPIError(ErrCodeNoMatchingShards, fmt.Sprintf("No shards match selector %q", req.ShardSelector))
+			}
+			log.Printf("Performing scatter-gather query across shards matching %q: %v", req.ShardSelector, targetShards)
+		}
 
-		data, err := qr.dataStore.ExecuteQueryOnAllShards(req.Query)
+		// Time range tables only live on the shards holding their ranges
+		if rangeShards, isTimeRange := qr.shardManager.TimeRangeShards(parseResult.TableName); isTimeRange {
+			targetShards = intersectShards(targetShards, rangeShards)
+		}
+
+		targetShards, err := qr.filterMaintenance(targetShards, parseResult.IsWrite())
+		if err != nil {
+			return nil, newAPIError(ErrCodeShardUnavailable, err.Error())
+		}
+
+		if apiErr := qr.checkUnboundedScatter(req, parseResult, targetShards, database); apiErr != nil {
+			return nil, apiErr
+		}
+
+		rewritten := qr.rewriteQuery(req.Query, parseResult, database, true)
+		if parseResult.IsWrite() {
+			affected, repairing, buffered, err := qr.executeWriteConcern(r, rewritten.Query, parseResult, targetShards, database, concern)
+			if err != nil {
+				log.Printf("Failed to execute scatter-gather query: %v", err)
+				return nil, classifyExecutionError(err, "")
+			}
+			return &QueryResponse{Shards: targetShards, RowsAffected: &affected, Repairing: repairing, Buffered: buffered}, nil
+		}
+
+		if apiErr := qr.checkResultEstimate(r.Context(), rewritten.Query, parseResult, targetShards, database); apiErr != nil {
+			return nil, apiErr
+		}
+
+		data, err := qr.readOnShards(r.Context(), rewritten, parseResult, targetShards, database, maxStaleness)
+		truncated, err := qr.truncateOnLimit(err)
 		if err != nil {
 			log.Printf("Failed to execute scatter-gather query: %v", err)
-			qr.sendErrorResponse(w, fmt.Sprintf("Failed to execute query: %v", err), http.StatusInternalServerError)
-			return
+			return nil, classifyExecutionError(err, "")
+		}
+
+		data, err = qr.dedupeDistinct(data, parseResult, len(targetShards))
+		if err != nil {
+			return nil, newAPIError(ErrCodeInternal, err.Error())
 		}
 
 		response = QueryResponse{
-			Data:   data,
-			Shards: qr.shardManager.GetAllShards(),
+			Data:      applyRewriteToResults(data, rewritten),
+			Shards:    targetShards,
+			Truncated: truncated,
 		}
 	}
 
-	// Send successful response
+	return &response, nil
+}
+
+// sendResponse sends a successful query response
+func (qr *QueryRouter) sendResponse(w http.ResponseWriter, response QueryResponse) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		log.Printf("Failed to encode response: %v", err)
 	}
 
-	log.Printf("Query executed successfully, returned %d rows", len(response.Data))
+	if response.RowsAffected != nil {
+		log.Printf("Query executed successfully, affected %d rows", *response.RowsAffected)
+	} else {
+		log.Printf("Query executed successfully, returned %d rows", len(response.Data))
+	}
+}
+
+// shardsForKeys returns the distinct shards owning the given shard key values
+func (qr *QueryRouter) shardsForKeys(table string, values []interface{}) ([]string, error) {
+	seen := make(map[string]bool)
+	var shards []string
+	for _, value := range values {
+		shard, err := qr.shardManager.GetShardForTable(table, fmt.Sprintf("%v", value))
+		if err != nil {
+			return nil, err
+		}
+		if !seen[shard] {
+			seen[shard] = true
+			shards = append(shards, shard)
+		}
+	}
+	return shards, nil
+}
+
+// intersectShards returns the shards of a that are also in b, in a's order
+func intersectShards(a, b []string) []string {
+	inB := make(map[string]bool, len(b))
+	for _, shardID := range b {
+		inB[shardID] = true
+	}
+
+	var result []string
+	for _, shardID := range a {
+		if inB[shardID] {
+			result = append(result, shardID)
+		}
+	}
+	return result
 }
 
 // handleHealth handles GET /health requests
@@ -144,19 +538,28 @@ func (qr *QueryRouter) handleHealth(w http.ResponseWriter, r *http.Request) {
 		"status": "healthy",
 		"service": "query-router",
 		"shards": qr.shardManager.GetAllShards(),
+		"cluster_at_capacity": qr.shardManager.GetCapacityStatus().AtCapacity,
+		"parser_cache": qr.parseCache.Stats(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(health)
 }
 
-// sendErrorResponse sends an error response
-func (qr *QueryRouter) sendErrorResponse(w http.ResponseWriter, message string, statusCode int) {
+// sendError sends an error response with the status mapped from its code
+func (qr *QueryRouter) sendError(w http.ResponseWriter, apiErr *APIError) {
+	atomic.AddInt64(&qr.errorCount, 1)
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+	if apiErr.Code == ErrCodeAtCapacity {
+		w.Header().Set("Retry-After", "30")
+	}
+	if apiErr.Code == ErrCodeQuotaExceeded {
+		w.Header().Set("Retry-After", retryAfter(apiErr))
+	}
+	w.WriteHeader(apiErr.Status())
 
 	response := QueryResponse{
-		Error: message,
+		Error: apiErr,
 	}
 
 	json.NewEncoder(w).Encode(response)
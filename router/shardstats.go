@@ -0,0 +1,167 @@
+package router
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"sql-horizontal-autoscaler/datastore"
+)
+
+// ShardLoad is the load a shard took through this router
+type ShardLoad struct {
+	Shard string `json:"shard"`
+	// Total counts since the router started
+	Total datastore.ShardCounters `json:"total"`
+	// Interval counts the last sampling interval
+	Interval     datastore.ShardCounters `json:"interval"`
+	ReadsPerSec  float64                 `json:"reads_per_sec"`
+	WritesPerSec float64                 `json:"writes_per_sec"`
+	// ReadShare and WriteShare are the shard's fractions of the interval's
+	// reads and writes across all shards
+	ReadShare  float64 `json:"read_share"`
+	WriteShare float64 `json:"write_share"`
+}
+
+// ShardStatsReport is the per-shard load of one sampling interval
+type ShardStatsReport struct {
+	Router          string      `json:"router"`
+	SampledAt       time.Time   `json:"sampled_at"`
+	IntervalSeconds float64     `json:"interval_seconds"`
+	Shards          []ShardLoad `json:"shards"`
+	// Imbalance is the busiest shard's statements per second over the mean
+	// across shards: 1 is perfectly even, 0 means no traffic
+	Imbalance float64 `json:"imbalance"`
+}
+
+// shardStats samples the datastore's per-shard counters every interval
+type shardStats struct {
+	last   map[string]datastore.ShardCounters
+	lastAt time.Time
+	report *ShardStatsReport
+	mutex  sync.Mutex
+}
+
+// shardStatsLoop samples the per-shard counters every interval, saving each
+// interval to the stats table when one is configured
+func (qr *QueryRouter) shardStatsLoop() {
+	ticker := time.NewTicker(time.Duration(qr.config.ShardStats.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		report, deltas := qr.sampleShardStats()
+		if qr.config.ShardStats.Table == "" {
+			continue
+		}
+		interval := time.Duration(report.IntervalSeconds * float64(time.Second))
+		if err := qr.dataStore.SaveShardCounters(qr.config.ShardStats.Shard, qr.config.ShardStats.Table, report.Router,
+			report.SampledAt, interval, deltas); err != nil {
+			log.Printf("Warning: Failed to save shard stats: %v", err)
+		}
+	}
+}
+
+// sampleShardStats closes the current interval, returning its report and
+// each shard's counters within it
+func (qr *QueryRouter) sampleShardStats() (*ShardStatsReport, map[string]datastore.ShardCounters) {
+	qr.shardStats.mutex.Lock()
+	defer qr.shardStats.mutex.Unlock()
+
+	now := time.Now()
+	current := qr.dataStore.ShardCounters()
+	report, deltas := qr.shardStatsReport(current, qr.shardStats.last, now.Sub(qr.shardStats.lastAt), now)
+	qr.shardStats.last, qr.shardStats.lastAt, qr.shardStats.report = current, now, report
+	return report, deltas
+}
+
+// shardStatsReport compares the counters to those at the start of an
+// interval. Every shard is listed, so idle shards show in the imbalance.
+func (qr *QueryRouter) shardStatsReport(current, last map[string]datastore.ShardCounters, elapsed time.Duration, now time.Time) (*ShardStatsReport, map[string]datastore.ShardCounters) {
+	shardIDs := qr.dataStore.ShardIDs()
+	for shardID := range current {
+		if !slices.Contains(shardIDs, shardID) {
+			shardIDs = append(shardIDs, shardID)
+		}
+	}
+	sort.Strings(shardIDs)
+
+	report := &ShardStatsReport{Router: qr.routerID(), SampledAt: now, IntervalSeconds: elapsed.Seconds(), Shards: []ShardLoad{}}
+	deltas := make(map[string]datastore.ShardCounters, len(shardIDs))
+	var reads, writes int64
+	for _, shardID := range shardIDs {
+		total, before := current[shardID], last[shardID]
+		delta := datastore.ShardCounters{
+			Reads:        total.Reads - before.Reads,
+			Writes:       total.Writes - before.Writes,
+			Errors:       total.Errors - before.Errors,
+			ErrorClasses: total.ErrorClasses.Since(before.ErrorClasses),
+			BytesRead:    total.BytesRead - before.BytesRead,
+			BytesWritten: total.BytesWritten - before.BytesWritten,
+			TimeMs:       total.TimeMs - before.TimeMs,
+		}
+		deltas[shardID] = delta
+		reads += delta.Reads
+		writes += delta.Writes
+		report.Shards = append(report.Shards, ShardLoad{Shard: shardID, Total: total, Interval: delta})
+	}
+
+	var busiest float64
+	for i := range report.Shards {
+		load := &report.Shards[i]
+		if elapsed > 0 {
+			load.ReadsPerSec = float64(load.Interval.Reads) / elapsed.Seconds()
+			load.WritesPerSec = float64(load.Interval.Writes) / elapsed.Seconds()
+		}
+		if reads > 0 {
+			load.ReadShare = float64(load.Interval.Reads) / float64(reads)
+		}
+		if writes > 0 {
+			load.WriteShare = float64(load.Interval.Writes) / float64(writes)
+		}
+		if statements := load.ReadsPerSec + load.WritesPerSec; statements > busiest {
+			busiest = statements
+		}
+	}
+	if len(report.Shards) > 0 && elapsed > 0 && reads+writes > 0 {
+		mean := float64(reads+writes) / elapsed.Seconds() / float64(len(report.Shards))
+		report.Imbalance = busiest / mean
+	}
+	return report, deltas
+}
+
+// handleShardStats handles GET /stats/shards, reporting the per-shard load
+// of the last sampling interval, or since the router started before the
+// first interval ends
+func (qr *QueryRouter) handleShardStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	qr.shardStats.mutex.Lock()
+	report := qr.shardStats.report
+	if report == nil {
+		now := time.Now()
+		report, _ = qr.shardStatsReport(qr.dataStore.ShardCounters(), nil, now.Sub(qr.shardStats.lastAt), now)
+	}
+	qr.shardStats.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// handleParserStats handles GET /stats/parser, reporting the parse cache's
+// size and hit rate
+func (qr *QueryRouter) handleParserStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(qr.parseCache.Stats())
+}
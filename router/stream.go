@@ -0,0 +1,198 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sql-horizontal-autoscaler/sharding"
+	"sql-horizontal-autoscaler/websocket"
+)
+
+const (
+	// maxStreamInFlight bounds the queries a single session runs concurrently;
+	// further messages wait until one finishes
+	maxStreamInFlight = 16
+	// streamTopologyInterval is how often subscribed sessions check for a new
+	// topology version
+	streamTopologyInterval = time.Second
+)
+
+// Stream message types
+const (
+	StreamQuery             = "query"
+	StreamBatch             = "batch"
+	StreamSubscribeTopology = "subscribe_topology"
+	StreamResult            = "result"
+	StreamBatchResult       = "batch_result"
+	StreamTopology          = "topology"
+	StreamError             = "error"
+)
+
+// StreamMessage is a message sent by a client over /ws. ID is echoed back on
+// the reply so responses can be correlated while queries run concurrently.
+type StreamMessage struct {
+	ID   string `json:"id,omitempty"`
+	Type string `json:"type"`
+	// Query is set for "query" messages
+	Query *QueryRequest `json:"query,omitempty"`
+	// Batch is set for "batch" messages
+	Batch *BatchRequest `json:"batch,omitempty"`
+}
+
+// StreamReply is a message sent by the router over /ws
+type StreamReply struct {
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type"`
+	Result   *QueryResponse     `json:"result,omitempty"`
+	Batch    *BatchResponse     `json:"batch,omitempty"`
+	Topology *sharding.Topology `json:"topology,omitempty"`
+	Error    *APIError          `json:"error,omitempty"`
+}
+
+// streamSession is one client connection on /ws
+type streamSession struct {
+	qr        *QueryRouter
+	conn      *websocket.Conn
+	request   *http.Request
+	inFlight  chan struct{}
+	wg        sync.WaitGroup
+	done      chan struct{}
+	subscribe sync.Once
+}
+
+// handleStream handles GET /ws, upgrading to a WebSocket over which clients
+// send many queries and receive correlated replies, plus topology change
+// notifications when subscribed
+func (qr *QueryRouter) handleStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		log.Printf("Warning: Rejected WebSocket session from %s: %v", r.RemoteAddr, err)
+		return
+	}
+
+	session := &streamSession{
+		qr:       qr,
+		conn:     conn,
+		request:  r,
+		inFlight: make(chan struct{}, maxStreamInFlight),
+		done:     make(chan struct{}),
+	}
+	log.Printf("🔌 WebSocket session opened from %s", r.RemoteAddr)
+	session.run()
+	log.Printf("🔌 WebSocket session from %s closed", r.RemoteAddr)
+}
+
+// run reads messages until the client disconnects, then waits for queries
+// still in flight before closing the connection
+func (s *streamSession) run() {
+	defer func() {
+		close(s.done)
+		s.wg.Wait()
+		s.conn.Close()
+	}()
+
+	for {
+		data, err := s.conn.ReadMessage()
+		if err != nil {
+			if !errors.Is(err, websocket.ErrClosed) {
+				log.Printf("Warning: WebSocket session from %s failed: %v", s.request.RemoteAddr, err)
+			}
+			return
+		}
+
+		var msg StreamMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			s.reply(StreamReply{Type: StreamError, Error: newAPIError(ErrCodeInvalidRequest, "Invalid JSON message")})
+			continue
+		}
+
+		switch msg.Type {
+		case StreamQuery:
+			if msg.Query == nil {
+				s.reply(StreamReply{ID: msg.ID, Type: StreamError, Error: newAPIError(ErrCodeInvalidRequest, "Query message must carry a \"query\"")})
+				continue
+			}
+			s.dispatch(func() {
+				result, apiErr := s.qr.executeQuery(s.request, *msg.Query)
+				if apiErr != nil {
+					atomic.AddInt64(&s.qr.errorCount, 1)
+					result = &QueryResponse{Error: apiErr}
+				}
+				s.reply(StreamReply{ID: msg.ID, Type: StreamResult, Result: result})
+			})
+		case StreamBatch:
+			if msg.Batch == nil {
+				s.reply(StreamReply{ID: msg.ID, Type: StreamError, Error: newAPIError(ErrCodeInvalidRequest, "Batch message must carry a \"batch\"")})
+				continue
+			}
+			s.dispatch(func() {
+				result, apiErr := s.qr.executeBatch(s.request, *msg.Batch)
+				if apiErr != nil {
+					s.reply(StreamReply{ID: msg.ID, Type: StreamError, Error: apiErr})
+					return
+				}
+				s.reply(StreamReply{ID: msg.ID, Type: StreamBatchResult, Batch: result})
+			})
+		case StreamSubscribeTopology:
+			s.subscribe.Do(func() {
+				s.wg.Add(1)
+				go s.watchTopology()
+			})
+		default:
+			s.reply(StreamReply{ID: msg.ID, Type: StreamError, Error: newAPIError(ErrCodeInvalidRequest, "Unknown message type \""+msg.Type+"\"")})
+		}
+	}
+}
+
+// dispatch runs fn in the background once a slot is free
+func (s *streamSession) dispatch(fn func()) {
+	s.inFlight <- struct{}{}
+	s.wg.Add(1)
+	go func() {
+		defer func() {
+			<-s.inFlight
+			s.wg.Done()
+		}()
+		fn()
+	}()
+}
+
+// watchTopology sends the current topology, then every new version until the
+// session ends
+func (s *streamSession) watchTopology() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(streamTopologyInterval)
+	defer ticker.Stop()
+
+	var version string
+	for {
+		if topology := s.qr.shardManager.Topology(); topology.Version != version {
+			version = topology.Version
+			s.reply(StreamReply{Type: StreamTopology, Topology: &topology})
+		}
+
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reply encodes and sends a message; a failed write closes the connection so
+// the read loop ends
+func (s *streamSession) reply(reply StreamReply) {
+	data, err := json.Marshal(reply)
+	if err != nil {
+		log.Printf("Failed to encode WebSocket reply: %v", err)
+		return
+	}
+	if err := s.conn.WriteText(data); err != nil {
+		s.conn.Close()
+	}
+}
@@ -0,0 +1,484 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sql-horizontal-autoscaler/config"
+	"sql-horizontal-autoscaler/parser"
+)
+
+// Sources of a template
+const (
+	TemplateSourceConfig = "config"
+	TemplateSourceAPI    = "api"
+)
+
+// QueryTemplate is a named, parameterized query clients execute by name
+type QueryTemplate struct {
+	Name string `json:"name"`
+	config.QueryTemplate
+	// Params are the template's parameters in order of first use
+	Params []string `json:"params"`
+	// Source is "config" for templates configured under templates and "api"
+	// for those registered through POST /templates
+	Source       string    `json:"source"`
+	RegisteredAt time.Time `json:"registered_at"`
+	Executions   int64     `json:"executions"`
+	Failures     int64     `json:"failures"`
+	// Refused counts executions by tenants the template doesn't allow
+	Refused int64 `json:"refused"`
+}
+
+// TemplateRequest is the body of POST /templates
+type TemplateRequest struct {
+	Name string `json:"name"`
+	config.QueryTemplate
+}
+
+// TemplateExecution is the body of POST /templates/{name}/execute
+type TemplateExecution struct {
+	// Args holds a value for each parameter: a string, number, boolean or
+	// null, or a non-empty list of those, which expands to a comma-separated
+	// list for IN (...)
+	Args map[string]interface{} `json:"args"`
+}
+
+// templateRegistry holds the templates of a router
+type templateRegistry struct {
+	config    config.TemplatesConfig
+	templates map[string]*QueryTemplate
+	mutex     sync.Mutex
+}
+
+// newTemplateRegistry creates a registry holding the configured templates;
+// templates that don't parse are skipped with a warning
+func newTemplateRegistry(cfg *config.Config) *templateRegistry {
+	tr := &templateRegistry{config: cfg.Templates, templates: make(map[string]*QueryTemplate)}
+	for name, tmpl := range cfg.Templates.Templates {
+		template, err := newQueryTemplate(name, tmpl, cfg.TableShardKeys)
+		if err != nil {
+			log.Printf("Warning: Skipping template %s: %v", name, err)
+			continue
+		}
+		template.Source = TemplateSourceConfig
+		tr.templates[name] = template
+	}
+	return tr
+}
+
+// newQueryTemplate checks a template and finds its parameters. The statement
+// must parse with its parameters bound, and route by shard key if the
+// template requires it.
+func newQueryTemplate(name string, tmpl config.QueryTemplate, tableShardKeys map[string]string) (*QueryTemplate, error) {
+	if name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("template name must be non-empty and not contain '/'")
+	}
+	if strings.TrimSpace(tmpl.SQL) == "" {
+		return nil, fmt.Errorf("template must have sql")
+	}
+	if tmpl.TimeoutMs < 0 {
+		return nil, fmt.Errorf("timeout_ms must not be negative")
+	}
+	if _, err := parseConsistency(tmpl.Consistency); err != nil {
+		return nil, err
+	}
+	switch tmpl.WriteConcern {
+	case "", WriteConcernAll, WriteConcernQuorum, WriteConcernOne:
+	default:
+		return nil, fmt.Errorf("write_concern must be %q, %q or %q", WriteConcernAll, WriteConcernQuorum, WriteConcernOne)
+	}
+
+	var params []string
+	seen := make(map[string]bool)
+	sample, _ := bindParams(tmpl.SQL, func(param string) (string, error) {
+		if !seen[param] {
+			seen[param] = true
+			params = append(params, param)
+		}
+		return "0", nil
+	})
+	if prefix, _, isExplain := parser.SplitExplain(sample); isExplain {
+		return nil, fmt.Errorf("templates can't be %s statements", strings.ToUpper(prefix))
+	}
+	parseResult, err := parser.Parse(sample, tableShardKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sql: %w", err)
+	}
+	if tmpl.RequireShardKey && !routesByKey(parseResult) {
+		return nil, fmt.Errorf("sql must filter on the shard key of %s to require it", parseResult.TableName)
+	}
+	if params == nil {
+		params = []string{}
+	}
+	return &QueryTemplate{Name: name, QueryTemplate: tmpl, Params: params, RegisteredAt: time.Now()}, nil
+}
+
+// routesByKey reports whether a statement goes to the shards owning its
+// shard key values rather than to every shard
+func routesByKey(parseResult *parser.ParseResult) bool {
+	return parseResult.HasShardKey || len(parseResult.ShardKeyValues) > 0
+}
+
+// register adds a template, failing if one of the same name exists
+func (tr *templateRegistry) register(template *QueryTemplate) error {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	if _, exists := tr.templates[template.Name]; exists {
+		return fmt.Errorf("template %s already exists", template.Name)
+	}
+	tr.templates[template.Name] = template
+	return nil
+}
+
+// remove deletes a template, reporting whether it existed
+func (tr *templateRegistry) remove(name string) bool {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	_, exists := tr.templates[name]
+	delete(tr.templates, name)
+	return exists
+}
+
+// get returns a copy of a template
+func (tr *templateRegistry) get(name string) (QueryTemplate, bool) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	template, exists := tr.templates[name]
+	if !exists {
+		return QueryTemplate{}, false
+	}
+	return *template, true
+}
+
+// list returns copies of every template, by name
+func (tr *templateRegistry) list() []QueryTemplate {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	templates := make([]QueryTemplate, 0, len(tr.templates))
+	for _, template := range tr.templates {
+		templates = append(templates, *template)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates
+}
+
+// count updates a template's execution counters
+func (tr *templateRegistry) count(name string, update func(*QueryTemplate)) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	if template, exists := tr.templates[name]; exists {
+		update(template)
+	}
+}
+
+// required reports whether a tenant may only run queries through templates
+func (tr *templateRegistry) required(tenant string) bool {
+	for _, required := range tr.config.Required {
+		if required == "*" || required == tenant {
+			return true
+		}
+	}
+	return false
+}
+
+// bindParams replaces each :name parameter outside quoted strings,
+// identifiers and comments with the text bind returns for it. A colon not
+// followed by a letter or underscore, as in :=, is left alone.
+func bindParams(sql string, bind func(param string) (string, error)) (string, error) {
+	var b strings.Builder
+	b.Grow(len(sql))
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		ch := sql[i]
+		if quote != 0 {
+			b.WriteByte(ch)
+			switch {
+			case ch == '\\' && quote != '`' && i+1 < len(sql):
+				i++
+				b.WriteByte(sql[i])
+			case ch == quote:
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '-' && strings.HasPrefix(sql[i:], "-- "), ch == '#':
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			b.WriteString(sql[i : i+end])
+			i += end - 1
+			continue
+		case ch == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql) - i - 2
+			} else {
+				end += 2
+			}
+			b.WriteString(sql[i : i+2+end])
+			i += 1 + end
+			continue
+		case ch == ':' && i+1 < len(sql) && isParamStart(sql[i+1]):
+			end := i + 1
+			for end < len(sql) && (isParamStart(sql[end]) || (sql[end] >= '0' && sql[end] <= '9')) {
+				end++
+			}
+			value, err := bind(sql[i+1 : end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i = end - 1
+			continue
+		}
+		b.WriteByte(ch)
+	}
+	return b.String(), nil
+}
+
+// isParamStart reports whether a byte may start a parameter name
+func isParamStart(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+// bindTemplate fills in a template's parameters with SQL literals of the
+// arguments given, every one of which must be a parameter of the template
+func bindTemplate(template QueryTemplate, args map[string]interface{}) (string, error) {
+	for name := range args {
+		if !slices.Contains(template.Params, name) {
+			return "", fmt.Errorf("template %s has no parameter %q", template.Name, name)
+		}
+	}
+	return bindParams(template.SQL, func(param string) (string, error) {
+		value, exists := args[param]
+		if !exists {
+			return "", fmt.Errorf("missing argument for parameter %q", param)
+		}
+		literal, err := sqlLiteral(value, true)
+		if err != nil {
+			return "", fmt.Errorf("argument %q: %w", param, err)
+		}
+		return literal, nil
+	})
+}
+
+// sqlLiteral renders a JSON argument as an SQL literal. Lists, when allowed,
+// render as their comma-separated elements.
+func sqlLiteral(value interface{}, allowList bool) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case json.Number:
+		if _, err := strconv.ParseFloat(v.String(), 64); err != nil {
+			return "", fmt.Errorf("invalid number %s", v)
+		}
+		return v.String(), nil
+	case string:
+		return "'" + strings.NewReplacer(`\`, `\\`, "'", "''").Replace(v) + "'", nil
+	case []interface{}:
+		if !allowList {
+			return "", fmt.Errorf("lists can't be nested")
+		}
+		if len(v) == 0 {
+			return "", fmt.Errorf("lists must not be empty")
+		}
+		literals := make([]string, len(v))
+		for i, element := range v {
+			literal, err := sqlLiteral(element, false)
+			if err != nil {
+				return "", err
+			}
+			literals[i] = literal
+		}
+		return strings.Join(literals, ", "), nil
+	}
+	return "", fmt.Errorf("must be a string, number, boolean, null or list")
+}
+
+// executeTemplate runs a template with the given arguments as the tenant
+// behind the request, within the template's routing hints
+func (qr *QueryRouter) executeTemplate(r *http.Request, name string, args map[string]interface{}) (*QueryResponse, *APIError) {
+	template, exists := qr.templates.get(name)
+	if !exists {
+		return nil, newAPIError(ErrCodeUnknownTemplate, fmt.Sprintf("No template %s", name))
+	}
+
+	tenant := qr.usage.tenant(r)
+	if !policyMatches(template.Tenants, tenant) {
+		qr.templates.count(name, func(t *QueryTemplate) { t.Refused++ })
+		log.Printf("🚫 Template %s refused to %s", name, tenant)
+		apiErr := newAPIError(ErrCodePolicy, fmt.Sprintf("Template %s is not allowed for %s", name, tenant))
+		apiErr.Details = map[string]interface{}{"template": name, "tenant": tenant}
+		return nil, apiErr
+	}
+
+	query, err := bindTemplate(template, args)
+	if err != nil {
+		return nil, newAPIError(ErrCodeInvalidRequest, err.Error())
+	}
+	if template.RequireShardKey {
+		parseResult, err := qr.parseCache.Parse(query, qr.config.TableShardKeys)
+		if err != nil {
+			return nil, newAPIError(ErrCodeParse, fmt.Sprintf("Failed to parse query: %v", err))
+		}
+		if !routesByKey(parseResult) {
+			return nil, newAPIError(ErrCodeInvalidRequest, fmt.Sprintf("Template %s must be executed with shard key values", name))
+		}
+	}
+
+	response, apiErr := qr.executeQuery(r, QueryRequest{
+		Query:         query,
+		Consistency:   template.Consistency,
+		ShardSelector: template.ShardSelector,
+		TimeoutMs:     template.TimeoutMs,
+		WriteConcern:  template.WriteConcern,
+		template:      name,
+	})
+	qr.templates.count(name, func(t *QueryTemplate) {
+		t.Executions++
+		if apiErr != nil {
+			t.Failures++
+		}
+	})
+	return response, apiErr
+}
+
+// checkTemplateOnly refuses a query sent outside a template by a tenant that
+// may only run templates
+func (qr *QueryRouter) checkTemplateOnly(tenant string, req QueryRequest) *APIError {
+	if req.template != "" || !qr.templates.required(tenant) {
+		return nil
+	}
+	log.Printf("🚫 Refused a query outside a template from %s", tenant)
+	apiErr := newAPIError(ErrCodePolicy, fmt.Sprintf("%s may only run queries through templates", tenant))
+	apiErr.Details = map[string]interface{}{"tenant": tenant}
+	return apiErr
+}
+
+// canManageTemplates reports whether the tenant behind a request may
+// register and remove templates. Only tenants listed as managers may, so
+// without any the templates are read-only through the API and a tenant
+// restricted to templates can't register its own.
+func (qr *QueryRouter) canManageTemplates(r *http.Request) bool {
+	return slices.Contains(qr.templates.config.Managers, qr.usage.tenant(r))
+}
+
+// handleTemplates handles GET /templates, listing the templates, and POST
+// /templates, registering one
+func (qr *QueryRouter) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"templates": qr.templates.list(),
+			"required":  qr.templates.config.Required,
+		})
+
+	case http.MethodPost:
+		if !qr.canManageTemplates(r) {
+			http.Error(w, "Not allowed to manage templates", http.StatusForbidden)
+			return
+		}
+		var req TemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+			return
+		}
+		template, err := newQueryTemplate(req.Name, req.QueryTemplate, qr.config.TableShardKeys)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid template: %v", err), http.StatusBadRequest)
+			return
+		}
+		template.Source = TemplateSourceAPI
+		if err := qr.templates.register(template); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("📝 Template %s registered by %s", template.Name, qr.usage.tenant(r))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(template)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTemplate handles GET and DELETE /templates/{name} and POST
+// /templates/{name}/execute
+func (qr *QueryRouter) handleTemplate(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/templates/"), "/")
+	if name, found := strings.CutSuffix(path, "/execute"); found {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req TemplateExecution
+		decoder := json.NewDecoder(r.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&req); err != nil {
+			qr.sendError(w, newAPIError(ErrCodeInvalidRequest, "Invalid JSON request"))
+			return
+		}
+		response, apiErr := qr.executeTemplate(r, name, req.Args)
+		if apiErr != nil {
+			qr.sendError(w, apiErr)
+			return
+		}
+		qr.sendResponse(w, *response)
+		return
+	}
+	if path == "" || strings.Contains(path, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		template, exists := qr.templates.get(path)
+		if !exists {
+			http.Error(w, "No template "+path, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(template)
+
+	case http.MethodDelete:
+		if !qr.canManageTemplates(r) {
+			http.Error(w, "Not allowed to manage templates", http.StatusForbidden)
+			return
+		}
+		if !qr.templates.remove(path) {
+			http.Error(w, "No template "+path, http.StatusNotFound)
+			return
+		}
+		log.Printf("🗑️  Template %s removed by %s", path, qr.usage.tenant(r))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
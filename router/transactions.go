@@ -0,0 +1,213 @@
+package router
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"sql-horizontal-autoscaler/datastore"
+)
+
+// PreparedTransaction is an XA transaction branch left prepared on a shard
+type PreparedTransaction struct {
+	XID     string `json:"xid"`
+	ShardID string `json:"shard_id"`
+	// Decision is "commit" when the commit decision was logged and "" when
+	// the transaction never reached the commit phase
+	Decision   string  `json:"decision"`
+	AgeSeconds float64 `json:"age_seconds"`
+}
+
+// RecoveryReport is the outcome of a recovery pass
+type RecoveryReport struct {
+	Prepared   []PreparedTransaction  `json:"prepared"`
+	Committed  []PreparedTransaction  `json:"committed"`
+	RolledBack []PreparedTransaction  `json:"rolled_back"`
+	Pending    []datastore.XADecision `json:"pending_decisions"`
+	Errors     map[string]string      `json:"errors,omitempty"`
+}
+
+// SetXALog enables two-phase commit of multi-shard writes, logging commit
+// decisions to decisions
+func (qr *QueryRouter) SetXALog(decisions *datastore.XALog) {
+	qr.xaLog = decisions
+}
+
+// xaPrefix is the prefix of every XID created by this router, so recovery
+// leaves other routers' transactions alone
+func (qr *QueryRouter) xaPrefix() string {
+	return "xa:" + qr.routerID() + ":"
+}
+
+// newXID creates a unique XID that carries its creation time
+func (qr *QueryRouter) newXID() string {
+	seq := atomic.AddUint64(&qr.xaSequence, 1)
+	return qr.xaPrefix() + strconv.FormatInt(time.Now().UnixNano(), 36) + "." + strconv.FormatUint(seq, 36)
+}
+
+// xidAge returns how long ago an XID of this router was created
+func (qr *QueryRouter) xidAge(xid string) time.Duration {
+	stamp := strings.TrimPrefix(xid, qr.xaPrefix())
+	if idx := strings.Index(stamp, "."); idx >= 0 {
+		stamp = stamp[:idx]
+	}
+	nanos, err := strconv.ParseInt(stamp, 36, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Since(time.Unix(0, nanos))
+}
+
+// handleTransactions handles GET /transactions, listing transactions left
+// prepared on shards and commit decisions not yet applied everywhere
+func (qr *QueryRouter) handleTransactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if qr.xaLog == nil {
+		http.Error(w, "Two-phase commit is not enabled", http.StatusNotFound)
+		return
+	}
+
+	report := qr.scanPrepared()
+	report.Pending = qr.xaLog.Pending()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// handleTransactionRoutes handles POST /transactions/recover, which runs a
+// recovery pass right away, and POST /transactions/{xid}/forget, which drops
+// a commit decision whose remaining shards were lost for good
+func (qr *QueryRouter) handleTransactionRoutes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if qr.xaLog == nil {
+		http.Error(w, "Two-phase commit is not enabled", http.StatusNotFound)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/transactions/"), "/")
+	if path == "recover" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(qr.recoverTransactions())
+		return
+	}
+
+	xid, found := strings.CutSuffix(path, "/forget")
+	if !found || xid == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if _, exists := qr.xaLog.Decision(xid); !exists {
+		http.Error(w, "No pending decision for transaction "+xid, http.StatusNotFound)
+		return
+	}
+	if err := qr.xaLog.RecordDone(xid); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("⚠️  Commit decision of transaction %s dropped on request", xid)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// xaRecoveryLoop periodically resolves transactions left prepared by failed
+// commits or a router crash
+func (qr *QueryRouter) xaRecoveryLoop() {
+	ticker := time.NewTicker(time.Duration(qr.config.Transactions.RecoveryIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		qr.recoverTransactions()
+		qr.recoveryBeat.Beat()
+	}
+}
+
+// scanPrepared lists this router's transactions prepared on each shard
+func (qr *QueryRouter) scanPrepared() *RecoveryReport {
+	report := &RecoveryReport{Errors: make(map[string]string)}
+	for _, shardID := range qr.dataStore.ShardIDs() {
+		xids, err := qr.dataStore.RecoverXA(shardID, qr.xaPrefix())
+		if err != nil {
+			report.Errors[shardID] = err.Error()
+			continue
+		}
+		for _, xid := range xids {
+			prepared := PreparedTransaction{XID: xid, ShardID: shardID, AgeSeconds: qr.xidAge(xid).Seconds()}
+			if decision, exists := qr.xaLog.Decision(xid); exists {
+				prepared.Decision = decision.Decision
+			}
+			report.Prepared = append(report.Prepared, prepared)
+		}
+	}
+	return report
+}
+
+// recoverTransactions commits prepared transactions with a logged commit
+// decision and rolls back those without one (presumed abort). Only
+// transactions older than the abort timeout and no longer running in this
+// router are touched, so commits still in progress are left alone. Decisions
+// applied on every shard are then closed.
+func (qr *QueryRouter) recoverTransactions() *RecoveryReport {
+	grace := time.Duration(qr.config.Transactions.AbortAfterSeconds) * time.Second
+	report := qr.scanPrepared()
+
+	unresolved := make(map[string]bool)
+	for _, prepared := range report.Prepared {
+		if qr.xidAge(prepared.XID) < grace || qr.xaLog.InFlight(prepared.XID) {
+			unresolved[prepared.XID] = true
+			continue
+		}
+
+		commit := prepared.Decision == datastore.XACommit
+		if err := qr.dataStore.ResolveXA(prepared.ShardID, prepared.XID, commit); err != nil {
+			log.Printf("Warning: %v", err)
+			report.Errors[prepared.ShardID] = err.Error()
+			unresolved[prepared.XID] = true
+			continue
+		}
+		if commit {
+			log.Printf("♻️  Committed in-doubt transaction %s on shard %s", prepared.XID, prepared.ShardID)
+			report.Committed = append(report.Committed, prepared)
+		} else {
+			log.Printf("♻️  Rolled back transaction %s prepared on shard %s without a commit decision", prepared.XID, prepared.ShardID)
+			report.RolledBack = append(report.RolledBack, prepared)
+		}
+	}
+
+	// A decision is done once none of its shards holds it prepared; shards
+	// that couldn't be scanned may still do
+	active := make(map[string]bool)
+	for _, shardID := range qr.dataStore.ShardIDs() {
+		active[shardID] = true
+	}
+	for _, decision := range qr.xaLog.Pending() {
+		if unresolved[decision.XID] || qr.xidAge(decision.XID) < grace || qr.xaLog.InFlight(decision.XID) {
+			continue
+		}
+		scanned := true
+		for _, shardID := range decision.Shards {
+			if _, failed := report.Errors[shardID]; failed || !active[shardID] {
+				scanned = false
+			}
+		}
+		if !scanned {
+			continue
+		}
+		if err := qr.xaLog.RecordDone(decision.XID); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	report.Pending = qr.xaLog.Pending()
+	if len(report.Pending) > 0 {
+		log.Printf("Warning: %d transactions are still in doubt; see GET /transactions", len(report.Pending))
+	}
+	return report
+}
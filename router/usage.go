@@ -0,0 +1,239 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"sql-horizontal-autoscaler/config"
+	"sql-horizontal-autoscaler/middleware"
+)
+
+// UsageCounters is the usage of a tenant within a window
+type UsageCounters struct {
+	Queries       int64   `json:"queries"`
+	BytesReturned int64   `json:"bytes_returned"`
+	ShardTimeMs   float64 `json:"shard_time_ms"`
+}
+
+// UsageWindow is a tenant's usage within the current hour or day
+type UsageWindow struct {
+	Start  time.Time          `json:"start"`
+	Usage  UsageCounters      `json:"usage"`
+	Limits config.UsageLimits `json:"limits"`
+}
+
+// TenantUsage is the usage of one tenant since the router started
+type TenantUsage struct {
+	Tenant string        `json:"tenant"`
+	Hourly UsageWindow   `json:"hourly"`
+	Daily  UsageWindow   `json:"daily"`
+	Total  UsageCounters `json:"total"`
+}
+
+// usageTracker accounts query usage per tenant and enforces quotas
+type usageTracker struct {
+	config  config.TenantsConfig
+	keys    map[string]string
+	tenants map[string]*TenantUsage
+	mutex   sync.Mutex
+}
+
+// newUsageTracker creates a tracker for the configured tenants
+func newUsageTracker(cfg config.TenantsConfig) *usageTracker {
+	keys := make(map[string]string)
+	for name, tenant := range cfg.Tenants {
+		for _, key := range tenant.APIKeys {
+			keys[key] = name
+		}
+	}
+	return &usageTracker{config: cfg, keys: keys, tenants: make(map[string]*TenantUsage)}
+}
+
+// anonymousTenant accounts every client without a listed API key. Unlisted
+// keys and bearer tokens are unverified, so each one becoming its own tenant
+// would let a client reset its quota by making up a new one.
+const anonymousTenant = "anonymous"
+
+// tenant identifies the tenant behind a request by its listed API key
+func (ut *usageTracker) tenant(r *http.Request) string {
+	if name, exists := ut.keys[r.Header.Get(middleware.APIKeyHeader)]; exists {
+		return name
+	}
+	return anonymousTenant
+}
+
+// usage returns a tenant's usage with its windows rolled forward to now.
+// Must be called with the mutex held.
+func (ut *usageTracker) usage(tenant string, now time.Time) *TenantUsage {
+	usage, exists := ut.tenants[tenant]
+	if !exists {
+		quota := ut.config.Default
+		if tenantConfig, listed := ut.config.Tenants[tenant]; listed {
+			quota = tenantConfig.Quota
+		}
+		usage = &TenantUsage{
+			Tenant: tenant,
+			Hourly: UsageWindow{Limits: quota.Hourly},
+			Daily:  UsageWindow{Limits: quota.Daily},
+		}
+		ut.tenants[tenant] = usage
+	}
+
+	if hour := now.Truncate(time.Hour); !usage.Hourly.Start.Equal(hour) {
+		usage.Hourly.Start = hour
+		usage.Hourly.Usage = UsageCounters{}
+	}
+	utc := now.UTC()
+	if day := time.Date(utc.Year(), utc.Month(), utc.Day(), 0, 0, 0, 0, time.UTC); !usage.Daily.Start.Equal(day) {
+		usage.Daily.Start = day
+		usage.Daily.Usage = UsageCounters{}
+	}
+	return usage
+}
+
+// check fails once a tenant has used up any of its hourly or daily quotas
+func (ut *usageTracker) check(tenant string) *APIError {
+	ut.mutex.Lock()
+	defer ut.mutex.Unlock()
+
+	now := time.Now()
+	usage := ut.usage(tenant, now)
+	for _, window := range []struct {
+		name   string
+		window UsageWindow
+		length time.Duration
+	}{
+		{"hourly", usage.Hourly, time.Hour},
+		{"daily", usage.Daily, 24 * time.Hour},
+	} {
+		resource, limit := window.window.exceeded()
+		if resource == "" {
+			continue
+		}
+
+		resetAt := window.window.Start.Add(window.length)
+		apiErr := newAPIError(ErrCodeQuotaExceeded,
+			fmt.Sprintf("Tenant %s used its %s %s quota of %d", tenant, window.name, resource, limit))
+		apiErr.Details = map[string]interface{}{
+			"tenant":              tenant,
+			"window":              window.name,
+			"resource":            resource,
+			"limit":               limit,
+			"reset_at":            resetAt,
+			"retry_after_seconds": int(resetAt.Sub(now).Seconds()) + 1,
+		}
+		return apiErr
+	}
+	return nil
+}
+
+// exceeded returns the first resource whose limit the window has reached
+func (w UsageWindow) exceeded() (string, int64) {
+	switch {
+	case w.Limits.Queries > 0 && w.Usage.Queries >= w.Limits.Queries:
+		return "queries", w.Limits.Queries
+	case w.Limits.BytesReturned > 0 && w.Usage.BytesReturned >= w.Limits.BytesReturned:
+		return "bytes_returned", w.Limits.BytesReturned
+	case w.Limits.ShardTimeMs > 0 && w.Usage.ShardTimeMs >= float64(w.Limits.ShardTimeMs):
+		return "shard_time_ms", w.Limits.ShardTimeMs
+	}
+	return "", 0
+}
+
+// record adds an executed query to a tenant's usage. Shard time is the
+// query's execution time counted once per shard it ran on.
+func (ut *usageTracker) record(tenant string, response *QueryResponse, elapsed time.Duration) {
+	shards := 1
+	var bytesReturned int64
+	if response != nil {
+		if len(response.Shards) > 1 {
+			shards = len(response.Shards)
+		}
+		if len(response.Data) > 0 {
+			counter := &countingWriter{}
+			json.NewEncoder(counter).Encode(response.Data)
+			bytesReturned = counter.n
+		}
+	}
+	added := UsageCounters{
+		Queries:       1,
+		BytesReturned: bytesReturned,
+		ShardTimeMs:   float64(elapsed.Microseconds()) / 1000 * float64(shards),
+	}
+
+	ut.mutex.Lock()
+	defer ut.mutex.Unlock()
+
+	usage := ut.usage(tenant, time.Now())
+	usage.Hourly.Usage.add(added)
+	usage.Daily.Usage.add(added)
+	usage.Total.add(added)
+}
+
+// add adds other to the counters
+func (uc *UsageCounters) add(other UsageCounters) {
+	uc.Queries += other.Queries
+	uc.BytesReturned += other.BytesReturned
+	uc.ShardTimeMs += other.ShardTimeMs
+}
+
+// snapshot returns the usage of every tenant, sorted by tenant
+func (ut *usageTracker) snapshot() []TenantUsage {
+	ut.mutex.Lock()
+	defer ut.mutex.Unlock()
+
+	now := time.Now()
+	usages := make([]TenantUsage, 0, len(ut.tenants))
+	for tenant := range ut.tenants {
+		usages = append(usages, *ut.usage(tenant, now))
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Tenant < usages[j].Tenant })
+	return usages
+}
+
+// countingWriter counts the bytes written to it
+type countingWriter struct {
+	n int64
+}
+
+// Write implements io.Writer
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.n += int64(len(p))
+	return len(p), nil
+}
+
+// handleUsage handles GET /usage, reporting per-tenant usage and quotas.
+// ?tenant= restricts the report to one tenant.
+func (qr *QueryRouter) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	usages := qr.usage.snapshot()
+	if tenant := r.URL.Query().Get("tenant"); tenant != "" {
+		filtered := []TenantUsage{}
+		for _, usage := range usages {
+			if usage.Tenant == tenant {
+				filtered = append(filtered, usage)
+			}
+		}
+		usages = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"tenants": usages})
+}
+
+// retryAfter returns the Retry-After value of a quota error
+func retryAfter(apiErr *APIError) string {
+	if seconds, ok := apiErr.Details["retry_after_seconds"].(int); ok {
+		return strconv.Itoa(seconds)
+	}
+	return ""
+}
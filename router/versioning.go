@@ -0,0 +1,98 @@
+package router
+
+import (
+	"fmt"
+	"log"
+
+	"sql-horizontal-autoscaler/parser"
+)
+
+// versionConflictError reports a versioned UPDATE that matched no row at the
+// expected version
+type versionConflictError struct {
+	Table    string
+	Column   string
+	Expected interface{}
+}
+
+func (e *versionConflictError) Error() string {
+	return fmt.Sprintf("no %s row matched %s = %v", e.Table, e.Column, e.Expected)
+}
+
+// apiError converts the conflict into the error returned to the client
+func (e *versionConflictError) apiError() *APIError {
+	apiErr := newAPIError(ErrCodeVersionConflict, fmt.Sprintf(
+		"Version conflict on %s: no row matched %s = %v; the row was changed or deleted since it was read, re-read it and retry",
+		e.Table, e.Column, e.Expected))
+	apiErr.Details = map[string]interface{}{
+		"table":            e.Table,
+		"version_column":   e.Column,
+		"expected_version": e.Expected,
+	}
+	return apiErr
+}
+
+// versionColumn returns the version column of the query's table, preferring
+// a "db.table" entry, or "" when the table isn't versioned
+func (qr *QueryRouter) versionColumn(parseResult *parser.ParseResult) string {
+	if parseResult.DatabaseName != "" {
+		if column, exists := qr.config.Updates.VersionColumns[parseResult.DatabaseName+"."+parseResult.TableName]; exists {
+			return column
+		}
+	}
+	return qr.config.Updates.VersionColumns[parseResult.TableName]
+}
+
+// applyVersionCheck rewrites an UPDATE of a versioned table to increment its
+// version and check the version the client expects, recording both in the
+// parse result for the write to be checked against
+func (qr *QueryRouter) applyVersionCheck(req *QueryRequest, parseResult *parser.ParseResult) *APIError {
+	if parseResult.StatementType != parser.StatementUpdate {
+		if req.ExpectedVersion != nil {
+			return newAPIError(ErrCodeInvalidRequest, "expected_version only applies to UPDATE statements")
+		}
+		return nil
+	}
+
+	column := qr.versionColumn(parseResult)
+	if column == "" {
+		if req.ExpectedVersion != nil {
+			return newAPIError(ErrCodeInvalidRequest, fmt.Sprintf("Table %s has no version column in updates.version_columns", parseResult.TableName))
+		}
+		return nil
+	}
+
+	query, expected, err := parser.ApplyVersionCheck(req.Query, column, req.ExpectedVersion)
+	if err != nil {
+		return newAPIError(ErrCodeInvalidRequest, err.Error())
+	}
+	if expected == nil && qr.config.Updates.RequireVersion {
+		apiErr := newAPIError(ErrCodeInvalidRequest, fmt.Sprintf(
+			"UPDATE of %s must check its version: pass expected_version or add %s = <version> to the WHERE clause",
+			parseResult.TableName, column))
+		apiErr.Details = map[string]interface{}{
+			"table":          parseResult.TableName,
+			"version_column": column,
+		}
+		return apiErr
+	}
+
+	log.Printf("Checking version column %s of %s: %s", column, parseResult.TableName, query)
+	req.Query = query
+	parseResult.VersionColumn = column
+	parseResult.ExpectedVersion = expected
+	return nil
+}
+
+// checkVersionMatched reports a conflict when a write checking a version
+// affected no rows
+func checkVersionMatched(parseResult *parser.ParseResult, affected int64) error {
+	if parseResult.ExpectedVersion == nil || affected > 0 {
+		return nil
+	}
+	return &versionConflictError{
+		Table:    parseResult.TableName,
+		Column:   parseResult.VersionColumn,
+		Expected: parseResult.ExpectedVersion,
+	}
+}
@@ -0,0 +1,364 @@
+package router
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sql-horizontal-autoscaler/audit"
+	"sql-horizontal-autoscaler/datastore"
+	"sql-horizontal-autoscaler/middleware"
+	"sql-horizontal-autoscaler/parser"
+)
+
+// maxReplayFailures bounds the buffered writes kept for GET /write-buffer
+// after a shard rejected them on replay
+const maxReplayFailures = 100
+
+// ReplayFailure is a buffered write its shard rejected on replay, after the
+// client was told it was accepted
+type ReplayFailure struct {
+	datastore.BufferedWrite
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// BufferedShard summarizes the writes waiting for an unreachable shard
+type BufferedShard struct {
+	ShardID          string    `json:"shard_id"`
+	Writes           int       `json:"writes"`
+	OldestQueuedAt   time.Time `json:"oldest_queued_at"`
+	OldestAgeSeconds float64   `json:"oldest_age_seconds"`
+}
+
+// WriteBufferReport is the response of GET /write-buffer
+type WriteBufferReport struct {
+	Shards   []BufferedShard `json:"shards"`
+	Failures []ReplayFailure `json:"failures"`
+}
+
+// writeBuffer holds writes for unreachable shards until they can be replayed
+type writeBuffer struct {
+	journal  *datastore.WriteJournal
+	failures []ReplayFailure
+	// order holds a lock per shard, held from deciding whether a write runs
+	// or is buffered until it ran or was buffered, so no write overtakes
+	// one still on its way into the buffer. Writes running directly share
+	// it; buffering and replaying take it exclusively.
+	order map[string]*sync.RWMutex
+	mutex sync.Mutex
+}
+
+// SetWriteBuffer enables buffering writes to unreachable shards in journal
+func (qr *QueryRouter) SetWriteBuffer(journal *datastore.WriteJournal) {
+	qr.writeBuffer = &writeBuffer{journal: journal, order: make(map[string]*sync.RWMutex)}
+}
+
+// lockShards takes the ordering locks of shards, shared or exclusively, in
+// shard order so writes spanning the same shards can't deadlock, and returns
+// a function releasing them
+func (wb *writeBuffer) lockShards(shardIDs []string, exclusive bool) func() {
+	sorted := append([]string(nil), shardIDs...)
+	sort.Strings(sorted)
+
+	var locks []*sync.RWMutex
+	wb.mutex.Lock()
+	for _, shardID := range sorted {
+		if _, exists := wb.order[shardID]; !exists {
+			wb.order[shardID] = &sync.RWMutex{}
+		}
+		locks = append(locks, wb.order[shardID])
+	}
+	wb.mutex.Unlock()
+
+	for _, lock := range locks {
+		if exclusive {
+			lock.Lock()
+		} else {
+			lock.RLock()
+		}
+	}
+	return func() {
+		for _, lock := range locks {
+			if exclusive {
+				lock.Unlock()
+			} else {
+				lock.RUnlock()
+			}
+		}
+	}
+}
+
+// buffersWrite reports whether a write may be buffered: a versioned write
+// must know at once whether it matched a row, and a schema change replayed
+// late would leave the shard's schema drifting from the others
+func (qr *QueryRouter) buffersWrite(parseResult *parser.ParseResult) bool {
+	return qr.writeBuffer != nil && parseResult.ExpectedVersion == nil && !parseResult.IsDDL()
+}
+
+// writeOrBuffer runs a write on its shards and records their outcomes in the
+// audit log. With the write buffer enabled, the write is buffered for shards
+// that already have writes waiting, so it replays in order behind them, and
+// for shards it could not reach; the shards it was buffered for are returned
+// and succeed with no affected rows. Shards whose buffer is full or too old
+// fail with a *datastore.WriteBufferError. Writes to reachable shards with
+// nothing waiting run concurrently; only those queued behind buffered writes
+// are serialized, keeping them in the order they were accepted.
+func (qr *QueryRouter) writeOrBuffer(r *http.Request, query string, parseResult *parser.ParseResult, shardIDs []string, database string) ([]datastore.WriteResult, []string) {
+	if !qr.buffersWrite(parseResult) {
+		results := qr.dataStore.ExecuteWriteOnShards(query, shardIDs, database)
+		qr.auditWrites(r, query, parseResult, results)
+		return results, nil
+	}
+
+	write := datastore.BufferedWrite{
+		Database:      database,
+		Query:         query,
+		Table:         parseResult.TableName,
+		StatementType: parseResult.StatementType,
+		RequestID:     middleware.RequestIDFromContext(r.Context()),
+		Actor:         middleware.Actor(r),
+	}
+
+	// Buffering and replaying take the locks exclusively, so the shards'
+	// pending writes can't change while they are shared
+	unlock := qr.writeBuffer.lockShards(shardIDs, false)
+	exclusive := qr.writeBuffer.journal.HasPending(shardIDs...)
+	if exclusive {
+		unlock()
+		unlock = qr.writeBuffer.lockShards(shardIDs, true)
+	}
+	defer func() { unlock() }()
+
+	var results []datastore.WriteResult
+	var buffered, direct []string
+	for _, shardID := range shardIDs {
+		if !exclusive {
+			direct = append(direct, shardID)
+			continue
+		}
+		write.ShardID = shardID
+		queued, err := qr.writeBuffer.journal.Buffer(write, true)
+		switch {
+		case err != nil:
+			results = append(results, datastore.WriteResult{ShardID: shardID, Err: err})
+		case queued:
+			results = append(results, datastore.WriteResult{ShardID: shardID})
+			buffered = append(buffered, shardID)
+		default:
+			direct = append(direct, shardID)
+		}
+	}
+
+	executed := qr.dataStore.ExecuteWriteOnShards(query, direct, database)
+	qr.auditWrites(r, query, parseResult, executed)
+	var unsent []string
+	for _, result := range executed {
+		if result.Err != nil && datastore.WriteNotSent(result.Err) {
+			unsent = append(unsent, result.ShardID)
+		}
+	}
+	// Any write overtaking this one while the locks are swapped was sent
+	// before this one was answered, so it was concurrent with it anyway
+	if len(unsent) > 0 && !exclusive {
+		unlock()
+		unlock = qr.writeBuffer.lockShards(unsent, true)
+	}
+	for i, result := range executed {
+		if result.Err == nil || !datastore.WriteNotSent(result.Err) {
+			continue
+		}
+		write.ShardID = result.ShardID
+		if _, err := qr.writeBuffer.journal.Buffer(write, false); err != nil {
+			executed[i].Err = err
+			continue
+		}
+		log.Printf("📥 Buffered write for unreachable shard %s: %v", result.ShardID, result.Err)
+		executed[i].Err = nil
+		buffered = append(buffered, result.ShardID)
+	}
+
+	// Report the outcomes in shard order, like ExecuteWriteOnShards
+	outcomes := make(map[string]datastore.WriteResult, len(shardIDs))
+	for _, result := range append(results, executed...) {
+		outcomes[result.ShardID] = result
+	}
+	results = results[:0]
+	for _, shardID := range shardIDs {
+		results = append(results, outcomes[shardID])
+	}
+	sort.Strings(buffered)
+	return results, buffered
+}
+
+// writeReplayLoop replays buffered writes every replay interval
+func (qr *QueryRouter) writeReplayLoop() {
+	ticker := time.NewTicker(time.Duration(qr.config.WriteBuffer.ReplayIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		replayed := 0
+		for shardID := range qr.writeBuffer.journal.Pending() {
+			replayed += qr.replayWrites(shardID)
+		}
+
+		// The journal only appends, so drop the records of the writes
+		// replayed rather than let it grow until the next restart
+		if replayed > 0 {
+			if err := qr.writeBuffer.journal.Compact(); err != nil {
+				log.Printf("Warning: Failed to compact write journal: %v", err)
+			}
+		}
+		qr.replayBeat.Beat()
+	}
+}
+
+// replayWrites replays a shard's buffered writes in order, stopping while
+// the shard still can't be reached, and returns how many it replayed. A
+// write the shard rejects for any other reason is dropped and kept as a
+// replay failure, since replaying it again could apply it twice or block the
+// writes behind it.
+func (qr *QueryRouter) replayWrites(shardID string) int {
+	replayed := 0
+	for qr.replayNext(shardID) {
+		replayed++
+	}
+	if replayed > 0 {
+		log.Printf("♻️  Replayed %d buffered writes on shard %s", replayed, shardID)
+	}
+	return replayed
+}
+
+// replayNext replays a shard's oldest buffered write under the shard's
+// ordering lock, reporting whether it was replayed or dropped
+func (qr *QueryRouter) replayNext(shardID string) bool {
+	unlock := qr.writeBuffer.lockShards([]string{shardID}, true)
+	defer unlock()
+
+	write, exists := qr.writeBuffer.journal.Next(shardID)
+	if !exists {
+		return false
+	}
+	affected, err := qr.dataStore.ExecuteWrite(write.Query, shardID, write.Database)
+	if err != nil && datastore.WriteNotSent(err) {
+		return false
+	}
+	qr.auditReplay(write, affected, err)
+	if err != nil {
+		log.Printf("❌ Shard %s rejected buffered write %d on replay: %v", shardID, write.Seq, err)
+		qr.writeBuffer.recordFailure(write, err)
+	}
+	if err := qr.writeBuffer.journal.Done(write); err != nil {
+		log.Printf("Warning: Failed to record replay of buffered write %d on shard %s: %v", write.Seq, shardID, err)
+		return false
+	}
+	return true
+}
+
+// auditReplay records the outcome of a replayed write in the audit log
+func (qr *QueryRouter) auditReplay(write datastore.BufferedWrite, affected int64, err error) {
+	if !qr.auditLog.Audits(write.Table) {
+		return
+	}
+
+	entry := audit.Entry{
+		RequestID:     write.RequestID,
+		Actor:         write.Actor,
+		StatementType: write.StatementType,
+		Table:         write.Table,
+		Shard:         write.ShardID,
+		RowsAffected:  affected,
+		Query:         write.Query,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if err := qr.auditLog.Record(entry); err != nil {
+		log.Printf("Warning: Failed to audit replayed write on shard %s: %v", write.ShardID, err)
+	}
+}
+
+// recordFailure keeps a write rejected on replay, dropping the oldest beyond
+// maxReplayFailures
+func (wb *writeBuffer) recordFailure(write datastore.BufferedWrite, err error) {
+	wb.mutex.Lock()
+	defer wb.mutex.Unlock()
+
+	wb.failures = append(wb.failures, ReplayFailure{BufferedWrite: write, Error: err.Error(), FailedAt: time.Now().UTC()})
+	if len(wb.failures) > maxReplayFailures {
+		wb.failures = wb.failures[len(wb.failures)-maxReplayFailures:]
+	}
+}
+
+// report summarizes the buffered writes per shard and the replay failures
+func (wb *writeBuffer) report() WriteBufferReport {
+	report := WriteBufferReport{Shards: []BufferedShard{}}
+	for shardID, writes := range wb.journal.Pending() {
+		report.Shards = append(report.Shards, BufferedShard{
+			ShardID:          shardID,
+			Writes:           len(writes),
+			OldestQueuedAt:   writes[0].QueuedAt,
+			OldestAgeSeconds: time.Since(writes[0].QueuedAt).Seconds(),
+		})
+	}
+	sort.Slice(report.Shards, func(i, j int) bool { return report.Shards[i].ShardID < report.Shards[j].ShardID })
+
+	wb.mutex.Lock()
+	report.Failures = append([]ReplayFailure{}, wb.failures...)
+	wb.mutex.Unlock()
+	return report
+}
+
+// handleWriteBuffer handles GET /write-buffer, listing the writes waiting for
+// unreachable shards and those rejected on replay
+func (qr *QueryRouter) handleWriteBuffer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if qr.writeBuffer == nil {
+		http.Error(w, "Write buffering is not enabled", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(qr.writeBuffer.report())
+}
+
+// handleWriteBufferShard handles DELETE /write-buffer/{shard}, which drops
+// the writes waiting for a shard that was lost for good
+func (qr *QueryRouter) handleWriteBufferShard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if qr.writeBuffer == nil {
+		http.Error(w, "Write buffering is not enabled", http.StatusNotFound)
+		return
+	}
+
+	shardID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/write-buffer/"), "/")
+	if shardID == "" {
+		http.Error(w, "Shard ID is required", http.StatusBadRequest)
+		return
+	}
+
+	discarded, err := qr.writeBuffer.journal.Discard(shardID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if discarded > 0 {
+		log.Printf("Warning: Discarded %d buffered writes for shard %s", discarded, shardID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"shard_id":  shardID,
+		"discarded": discarded,
+	})
+}
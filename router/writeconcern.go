@@ -0,0 +1,76 @@
+package router
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"sql-horizontal-autoscaler/datastore"
+	"sql-horizontal-autoscaler/parser"
+)
+
+// Write concerns of a write sent to several shards
+const (
+	// WriteConcernAll fails the write unless every shard applied it
+	WriteConcernAll = "all"
+	// WriteConcernQuorum needs a majority of the shards
+	WriteConcernQuorum = "quorum"
+	// WriteConcernOne needs a single shard
+	WriteConcernOne = "one"
+)
+
+// writeConcern returns the write concern of a request: its own, else its
+// table's, else the configured default
+func (qr *QueryRouter) writeConcern(req QueryRequest, parseResult *parser.ParseResult) (string, *APIError) {
+	switch req.WriteConcern {
+	case WriteConcernAll, WriteConcernQuorum, WriteConcernOne:
+		return req.WriteConcern, nil
+	case "":
+	default:
+		return "", newAPIError(ErrCodeInvalidRequest, fmt.Sprintf("write_concern must be %q, %q or %q", WriteConcernAll, WriteConcernQuorum, WriteConcernOne))
+	}
+	if concern, exists := qr.config.WriteConcern.Tables[parseResult.TableName]; exists {
+		return concern, nil
+	}
+	return qr.config.WriteConcern.Default, nil
+}
+
+// requiredAcks returns how many of n shards must apply a write under a
+// write concern
+func requiredAcks(concern string, n int) int {
+	switch concern {
+	case WriteConcernOne:
+		return min(1, n)
+	case WriteConcernQuorum:
+		return n/2 + 1
+	}
+	return n
+}
+
+// repairWrite retries in the background a write that failed on a shard after
+// enough others applied it, up to write_concern.repair_attempts times. It
+// runs after the request was answered, so it is given the request's ID and
+// actor for the audit log rather than the request. A write that isn't
+// idempotent is given up on once an attempt may have reached the shard.
+func (qr *QueryRouter) repairWrite(requestID, actor string, query string, parseResult *parser.ParseResult, shardID string, database string) {
+	concernConfig := qr.config.WriteConcern
+	interval := time.Duration(concernConfig.RepairIntervalSeconds) * time.Second
+
+	var err error
+	for attempt := 1; attempt <= concernConfig.RepairAttempts; attempt++ {
+		time.Sleep(interval)
+
+		var affected int64
+		affected, err = qr.dataStore.ExecuteWrite(query, shardID, database)
+		qr.auditWritesBy(requestID, actor, query, parseResult, []datastore.WriteResult{{ShardID: shardID, RowsAffected: affected, Err: err}})
+		if err == nil {
+			log.Printf("🔧 Repaired write on shard %s after %d attempts", shardID, attempt)
+			return
+		}
+		if !repairable(parseResult, err) {
+			log.Printf("❌ Gave up repairing write on shard %s, which may have applied it before failing: %v", shardID, err)
+			return
+		}
+	}
+	log.Printf("❌ Gave up repairing write on shard %s after %d attempts: %v", shardID, concernConfig.RepairAttempts, err)
+}
@@ -0,0 +1,81 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// VaultProvider resolves "secret/path#field" references using the vault CLI.
+// VAULT_ADDR and VAULT_TOKEN are taken from the environment.
+type VaultProvider struct{}
+
+// Resolve reads a single field from a Vault KV secret
+func (p *VaultProvider) Resolve(ref string) (string, error) {
+	path, field := splitField(ref)
+	if field == "" {
+		return "", fmt.Errorf("vault reference %q must specify a field (path#field)", ref)
+	}
+
+	cmd := exec.Command("vault", "kv", "get", fmt.Sprintf("-field=%s", field), path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("vault kv get failed: %w, output: %s", err, stderr.String())
+	}
+
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
+
+// AWSSecretsManagerProvider resolves "arn[#field]" references using the aws CLI.
+// When a field is given the secret string is decoded as JSON.
+type AWSSecretsManagerProvider struct{}
+
+// Resolve reads a secret string (or a JSON field within it) from Secrets Manager
+func (p *AWSSecretsManagerProvider) Resolve(ref string) (string, error) {
+	secretID, field := splitField(ref)
+
+	cmd := exec.Command("aws", "secretsmanager", "get-secret-value",
+		"--secret-id", secretID,
+		"--query", "SecretString",
+		"--output", "text")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("aws secretsmanager get-secret-value failed: %w, output: %s", err, stderr.String())
+	}
+
+	secretString := strings.TrimRight(string(output), "\r\n")
+	if field == "" {
+		return secretString, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(secretString), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", secretID, err)
+	}
+
+	value, exists := fields[field]
+	if !exists {
+		return "", fmt.Errorf("field %s not found in secret %s", field, secretID)
+	}
+
+	return fmt.Sprintf("%v", value), nil
+}
+
+// EnvProvider resolves "NAME" references from environment variables
+type EnvProvider struct{}
+
+// Resolve reads the named environment variable
+func (p *EnvProvider) Resolve(ref string) (string, error) {
+	value, exists := os.LookupEnv(ref)
+	if !exists {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+	return value, nil
+}
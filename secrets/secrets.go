@@ -0,0 +1,135 @@
+package secrets
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider resolves a secret reference (without its scheme prefix) to a value
+type Provider interface {
+	Resolve(ref string) (string, error)
+}
+
+// Resolver dispatches secret references to providers based on their scheme
+type Resolver struct {
+	providers map[string]Provider
+}
+
+// NewResolver creates a resolver with the built-in providers registered
+func NewResolver() *Resolver {
+	return &Resolver{
+		providers: map[string]Provider{
+			"vault":  &VaultProvider{},
+			"aws-sm": &AWSSecretsManagerProvider{},
+			"env":    &EnvProvider{},
+		},
+	}
+}
+
+// Register adds or replaces the provider for a scheme
+func (r *Resolver) Register(scheme string, provider Provider) {
+	r.providers[scheme] = provider
+}
+
+// IsReference reports whether the value refers to a registered provider
+func (r *Resolver) IsReference(value string) bool {
+	scheme, _, found := strings.Cut(value, ":")
+	if !found {
+		return false
+	}
+	_, exists := r.providers[scheme]
+	return exists
+}
+
+// Resolve returns the secret value for a reference, or the value itself if it
+// is not a reference
+func (r *Resolver) Resolve(value string) (string, error) {
+	if !r.IsReference(value) {
+		return value, nil
+	}
+
+	scheme, ref, _ := strings.Cut(value, ":")
+	secret, err := r.providers[scheme].Resolve(ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s secret: %w", scheme, err)
+	}
+	return secret, nil
+}
+
+// splitField splits "path#field" into its path and optional field
+func splitField(ref string) (string, string) {
+	path, field, _ := strings.Cut(ref, "#")
+	return path, field
+}
+
+// RotationWatcher periodically re-resolves secret references and reports changes
+type RotationWatcher struct {
+	resolver *Resolver
+	refs     map[string]string
+	values   map[string]string
+	interval time.Duration
+	onChange func(name, value string)
+	mutex    sync.Mutex
+	stopChan chan struct{}
+}
+
+// NewRotationWatcher creates a watcher for the named references. The initial
+// values are the ones already resolved at startup.
+func NewRotationWatcher(resolver *Resolver, refs, initial map[string]string, interval time.Duration, onChange func(name, value string)) *RotationWatcher {
+	values := make(map[string]string, len(initial))
+	for name, value := range initial {
+		values[name] = value
+	}
+	return &RotationWatcher{
+		resolver: resolver,
+		refs:     refs,
+		values:   values,
+		interval: interval,
+		onChange: onChange,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start starts the background refresh loop
+func (w *RotationWatcher) Start() {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stopChan:
+				return
+			case <-ticker.C:
+				w.refresh()
+			}
+		}
+	}()
+}
+
+// Stop stops the background refresh loop
+func (w *RotationWatcher) Stop() {
+	close(w.stopChan)
+}
+
+// refresh re-resolves every reference and invokes onChange for rotated values
+func (w *RotationWatcher) refresh() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for name, ref := range w.refs {
+		value, err := w.resolver.Resolve(ref)
+		if err != nil {
+			log.Printf("Warning: Failed to refresh secret %s: %v", name, err)
+			continue
+		}
+		if value != w.values[name] {
+			w.values[name] = value
+			log.Printf("🔑 Secret %s rotated", name)
+			w.onChange(name, value)
+		}
+	}
+}
@@ -63,13 +63,16 @@ cleanup_existing() {
     docker rm $(docker ps -aq --filter "name=mysql-shard") 2>/dev/null || true
     
     docker network rm $NETWORK_NAME 2>/dev/null || true
+
+    # Remove coordinator state that refers to the removed containers
+    rm -f coordinator-state.json
     echo -e "${GREEN}✅ Cleanup complete${NC}"
 }
 
 # Function to create Docker network
 create_network() {
     echo -e "\n${BLUE}🌐 Creating Docker network...${NC}"
-    docker network create $NETWORK_NAME 2>/dev/null || true
+    docker network create --label sql-autoscaler.owner=$CONTAINER_PREFIX $NETWORK_NAME 2>/dev/null || true
     echo -e "${GREEN}✅ Network '$NETWORK_NAME' ready${NC}"
 }
 
@@ -80,6 +83,7 @@ start_initial_shard() {
     docker run -d \
         --name ${CONTAINER_PREFIX}-shard-1 \
         --network $NETWORK_NAME \
+        --label sql-autoscaler.owner=$CONTAINER_PREFIX \
         -p ${BASE_PORT}:3306 \
         -e MYSQL_ROOT_PASSWORD=$DB_ROOT_PASSWORD \
         -e MYSQL_DATABASE=shard1_db \
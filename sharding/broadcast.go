@@ -0,0 +1,199 @@
+package sharding
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// Divergence records a broadcast table write that did not commit on a shard,
+// leaving its copy of the table out of sync with the others
+type Divergence struct {
+	Table      string    `json:"table"`
+	ShardID    string    `json:"shard_id"`
+	Query      string    `json:"query"`
+	Error      string    `json:"error"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// RepairResult describes a broadcast table re-synced from a source shard
+type RepairResult struct {
+	Table      string `json:"table"`
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	RowsCopied int64  `json:"rows_copied"`
+}
+
+// divergenceKey identifies a diverged copy of a broadcast table
+func divergenceKey(table, shardID string) string {
+	return table + "/" + shardID
+}
+
+// RecordDivergence marks a shard's copy of a broadcast table as out of sync.
+// The first failure is kept until the copy is repaired.
+func (dsm *DynamicShardManager) RecordDivergence(table, shardID, query string, cause error) {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	key := divergenceKey(table, shardID)
+	if _, exists := dsm.divergences[key]; exists {
+		return
+	}
+
+	dsm.divergences[key] = Divergence{
+		Table:      table,
+		ShardID:    shardID,
+		Query:      query,
+		Error:      cause.Error(),
+		DetectedAt: time.Now(),
+	}
+	log.Printf("⚠️  Broadcast table %s diverged on shard %s: %v", table, shardID, cause)
+}
+
+// GetDivergences returns the diverged broadcast table copies, oldest first
+func (dsm *DynamicShardManager) GetDivergences() []Divergence {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	divergences := make([]Divergence, 0, len(dsm.divergences))
+	for _, divergence := range dsm.divergences {
+		divergences = append(divergences, divergence)
+	}
+	sort.Slice(divergences, func(i, j int) bool {
+		return divergences[i].DetectedAt.Before(divergences[j].DetectedAt)
+	})
+	return divergences
+}
+
+// RestoreDivergences re-records divergences loaded from a snapshot
+func (dsm *DynamicShardManager) RestoreDivergences(divergences []Divergence) {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	for _, divergence := range divergences {
+		dsm.divergences[divergenceKey(divergence.Table, divergence.ShardID)] = divergence
+	}
+}
+
+// BroadcastSource returns the shard holding an authoritative copy of a
+// broadcast table: the preferred shard unless it is inactive or diverged,
+// otherwise the first active shard whose copy is in sync
+func (dsm *DynamicShardManager) BroadcastSource(table, preferred string) (string, error) {
+	return dsm.broadcastSource(table, preferred, "")
+}
+
+// BroadcastCopies returns the active shards whose copy of a broadcast table
+// is in sync, in shard order
+func (dsm *DynamicShardManager) BroadcastCopies(table string) []string {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	var copies []string
+	for shardID := range dsm.shards {
+		if dsm.inSync(table, shardID) {
+			copies = append(copies, shardID)
+		}
+	}
+	sort.Strings(copies)
+	return copies
+}
+
+// inSync reports whether a shard is active and its copy of a broadcast table
+// hasn't diverged. Must be called with the mutex held.
+func (dsm *DynamicShardManager) inSync(table, shardID string) bool {
+	shardInfo, exists := dsm.shards[shardID]
+	if !exists || shardInfo.Status != "active" {
+		return false
+	}
+	_, diverged := dsm.divergences[divergenceKey(table, shardID)]
+	return !diverged
+}
+
+// broadcastSource implements BroadcastSource, never choosing the excluded shard
+func (dsm *DynamicShardManager) broadcastSource(table, preferred, exclude string) (string, error) {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	inSync := func(shardID string) bool {
+		return shardID != exclude && dsm.inSync(table, shardID)
+	}
+
+	if preferred != "" && inSync(preferred) {
+		return preferred, nil
+	}
+
+	shardIDs := make([]string, 0, len(dsm.shards))
+	for shardID := range dsm.shards {
+		shardIDs = append(shardIDs, shardID)
+	}
+	sort.Strings(shardIDs)
+
+	for _, shardID := range shardIDs {
+		if inSync(shardID) {
+			return shardID, nil
+		}
+	}
+	return "", fmt.Errorf("no shard holds an in-sync copy of broadcast table %s", table)
+}
+
+// RepairBroadcastTable replaces the target shard's copy of a broadcast table
+// with the rows of an in-sync source, in one transaction, and clears the
+// target's divergence
+func (dsm *DynamicShardManager) RepairBroadcastTable(table, preferredSource, targetID string) (*RepairResult, error) {
+	sourceID, err := dsm.broadcastSource(table, preferredSource, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	src, srcExists := dsm.GetShardInfo(sourceID)
+	dst, dstExists := dsm.GetShardInfo(targetID)
+	if !srcExists || !dstExists {
+		return nil, fmt.Errorf("both shards must exist (source %s: %t, target %s: %t)", sourceID, srcExists, targetID, dstExists)
+	}
+
+	log.Printf("🔧 Re-syncing broadcast table %s on shard %s from %s", table, targetID, sourceID)
+
+	srcDB, err := sql.Open("mysql", src.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to source: %w", err)
+	}
+	defer srcDB.Close()
+
+	dstDB, err := sql.Open("mysql", dst.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to target: %w", err)
+	}
+	defer dstDB.Close()
+
+	if err := ensureTable(srcDB, dstDB, table); err != nil {
+		return nil, err
+	}
+
+	tx, err := dstDB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM `%s`", table)); err != nil {
+		return nil, fmt.Errorf("failed to clear %s on shard %s: %w", table, targetID, err)
+	}
+
+	copied, err := copyTable(srcDB, tx, table, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit repaired rows: %w", err)
+	}
+
+	dsm.mutex.Lock()
+	delete(dsm.divergences, divergenceKey(table, targetID))
+	dsm.mutex.Unlock()
+
+	log.Printf("✅ Broadcast table %s on shard %s re-synced (%d rows)", table, targetID, copied)
+	return &RepairResult{Table: table, Source: sourceID, Target: targetID, RowsCopied: copied}, nil
+}
@@ -0,0 +1,35 @@
+package sharding
+
+// Capacity actions the router can take while the cluster is at capacity
+const (
+	CapacityActionNone     = "none"
+	CapacityActionReadOnly = "read_only"
+	CapacityActionShed     = "shed"
+)
+
+// CapacityStatus describes whether the cluster can still scale out and how
+// the router should protect the hottest tables if it cannot
+type CapacityStatus struct {
+	AtCapacity bool     `json:"at_capacity"`
+	Action     string   `json:"action"`
+	HotTables  []string `json:"hot_tables,omitempty"`
+	ShedRatio  float64  `json:"shed_ratio,omitempty"`
+}
+
+// SetCapacityStatus records the cluster capacity state
+func (dsm *DynamicShardManager) SetCapacityStatus(status CapacityStatus) {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	dsm.capacity = status
+}
+
+// GetCapacityStatus returns the cluster capacity state
+func (dsm *DynamicShardManager) GetCapacityStatus() CapacityStatus {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	status := dsm.capacity
+	status.HotTables = append([]string(nil), dsm.capacity.HotTables...)
+	return status
+}
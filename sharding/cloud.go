@@ -0,0 +1,288 @@
+package sharding
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// Cloud provisioner types
+const (
+	ProvisionerDocker   = "docker"
+	ProvisionerRDS      = "rds"
+	ProvisionerCloudSQL = "cloudsql"
+	ProvisionerAzure    = "azure"
+)
+
+// CloudProvisionerConfig contains the settings for managed database instances
+type CloudProvisionerConfig struct {
+	InstanceClass    string
+	StorageGB        int
+	Region           string
+	EngineVersion    string
+	Network          string
+	Subnet           string
+	SecurityGroupIDs []string
+	ResourceGroup    string
+	Project          string
+}
+
+// cloudProvisioner provisions shards as managed MySQL instances using the
+// provider's CLI (aws, gcloud or az)
+type cloudProvisioner struct {
+	dsm      *DynamicShardManager
+	provider string
+	cfg      CloudProvisionerConfig
+}
+
+// Name implements Provisioner
+func (cp *cloudProvisioner) Name() string {
+	return cp.provider
+}
+
+// instanceName returns the managed instance identifier for a shard
+func (cp *cloudProvisioner) instanceName(shardInfo *ShardInfo) string {
+	return cp.dsm.containerName(shardInfo.ID)
+}
+
+// Provision creates the managed instance, waits for it and records its endpoint
+func (cp *cloudProvisioner) Provision(shardInfo *ShardInfo) error {
+	name := cp.instanceName(shardInfo)
+	log.Printf("☁️  Creating %s instance %s for shard %s", cp.provider, name, shardInfo.ID)
+
+	password, _ := cp.dsm.credentials()
+	var commands [][]string
+	switch cp.provider {
+	case ProvisionerRDS:
+		create := []string{"aws", "rds", "create-db-instance",
+			"--db-instance-identifier", name,
+			"--engine", "mysql",
+			"--db-instance-class", cp.cfg.InstanceClass,
+			"--allocated-storage", strconv.Itoa(cp.cfg.StorageGB),
+			"--master-username", cp.dsm.config.DatabaseUsername,
+			"--master-user-password", password,
+			"--db-name", shardInfo.DatabaseName,
+			"--no-publicly-accessible",
+			"--region", cp.cfg.Region}
+		if cp.cfg.EngineVersion != "" {
+			create = append(create, "--engine-version", cp.cfg.EngineVersion)
+		}
+		if cp.cfg.Subnet != "" {
+			create = append(create, "--db-subnet-group-name", cp.cfg.Subnet)
+		}
+		if len(cp.cfg.SecurityGroupIDs) > 0 {
+			create = append(create, append([]string{"--vpc-security-group-ids"}, cp.cfg.SecurityGroupIDs...)...)
+		}
+		if shardInfo.Zone != "" {
+			create = append(create, "--availability-zone", shardInfo.Zone)
+		}
+		commands = [][]string{
+			create,
+			{"aws", "rds", "wait", "db-instance-available", "--db-instance-identifier", name, "--region", cp.cfg.Region},
+		}
+	case ProvisionerCloudSQL:
+		version := cp.cfg.EngineVersion
+		if version == "" {
+			version = "MYSQL_8_0"
+		}
+		create := []string{"gcloud", "sql", "instances", "create", name,
+			"--database-version", version,
+			"--tier", cp.cfg.InstanceClass,
+			"--region", cp.cfg.Region,
+			"--storage-size", fmt.Sprintf("%dGB", cp.cfg.StorageGB)}
+		if cp.cfg.Network != "" {
+			create = append(create, "--network", cp.cfg.Network, "--no-assign-ip")
+		}
+		if shardInfo.Zone != "" {
+			create = append(create, "--zone", shardInfo.Zone)
+		}
+		commands = [][]string{
+			cp.gcloudArgs(create),
+			cp.gcloudArgs([]string{"gcloud", "sql", "databases", "create", shardInfo.DatabaseName, "--instance", name}),
+			cp.gcloudArgs([]string{"gcloud", "sql", "users", "create", cp.dsm.config.DatabaseUsername,
+				"--instance", name, "--password", password}),
+		}
+	case ProvisionerAzure:
+		create := []string{"az", "mysql", "flexible-server", "create",
+			"--name", name,
+			"--resource-group", cp.cfg.ResourceGroup,
+			"--location", cp.cfg.Region,
+			"--sku-name", cp.cfg.InstanceClass,
+			"--storage-size", strconv.Itoa(cp.cfg.StorageGB),
+			"--admin-user", cp.dsm.config.DatabaseUsername,
+			"--admin-password", password,
+			"--yes"}
+		if cp.cfg.EngineVersion != "" {
+			create = append(create, "--version", cp.cfg.EngineVersion)
+		}
+		if cp.cfg.Network != "" {
+			create = append(create, "--vnet", cp.cfg.Network)
+		}
+		if cp.cfg.Subnet != "" {
+			create = append(create, "--subnet", cp.cfg.Subnet)
+		}
+		if shardInfo.Zone != "" {
+			create = append(create, "--zone", shardInfo.Zone)
+		}
+		commands = [][]string{
+			create,
+			{"az", "mysql", "flexible-server", "db", "create",
+				"--server-name", name,
+				"--resource-group", cp.cfg.ResourceGroup,
+				"--database-name", shardInfo.DatabaseName},
+		}
+	default:
+		return fmt.Errorf("unknown provisioner %s", cp.provider)
+	}
+
+	for _, args := range commands {
+		if output, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			return fmt.Errorf("%s failed: %w, output: %s", strings.Join(args[:3], " "), err, string(output))
+		}
+	}
+
+	host, err := cp.endpoint(shardInfo)
+	if err != nil {
+		return err
+	}
+
+	shardInfo.Host = host
+	shardInfo.Port = 3306
+	dsn, err := cp.dsm.buildDSN(shardInfo.ID, shardInfo.Host, shardInfo.Port, shardInfo.DatabaseName)
+	if err != nil {
+		return fmt.Errorf("failed to build DSN: %w", err)
+	}
+	shardInfo.DSN = dsn
+
+	log.Printf("☁️  %s instance %s available at %s", cp.provider, name, host)
+	return nil
+}
+
+// gcloudArgs appends the configured project to a gcloud command
+func (cp *cloudProvisioner) gcloudArgs(args []string) []string {
+	if cp.cfg.Project != "" {
+		args = append(args, "--project", cp.cfg.Project)
+	}
+	return args
+}
+
+// describeArgs returns the command printing the instance's endpoint hostname
+func (cp *cloudProvisioner) describeArgs(shardInfo *ShardInfo) []string {
+	name := cp.instanceName(shardInfo)
+	switch cp.provider {
+	case ProvisionerRDS:
+		return []string{"aws", "rds", "describe-db-instances",
+			"--db-instance-identifier", name,
+			"--region", cp.cfg.Region,
+			"--query", "DBInstances[0].Endpoint.Address",
+			"--output", "text"}
+	case ProvisionerCloudSQL:
+		return cp.gcloudArgs([]string{"gcloud", "sql", "instances", "describe", name,
+			"--format", "value(ipAddresses[0].ipAddress)"})
+	default:
+		return []string{"az", "mysql", "flexible-server", "show",
+			"--name", name,
+			"--resource-group", cp.cfg.ResourceGroup,
+			"--query", "fullyQualifiedDomainName",
+			"--output", "tsv"}
+	}
+}
+
+// endpoint returns the hostname of the shard's managed instance
+func (cp *cloudProvisioner) endpoint(shardInfo *ShardInfo) (string, error) {
+	args := cp.describeArgs(shardInfo)
+	output, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to look up endpoint for %s: %w, output: %s", cp.instanceName(shardInfo), err, string(output))
+	}
+
+	host := strings.TrimSpace(string(output))
+	if host == "" || host == "None" {
+		return "", fmt.Errorf("instance %s has no endpoint yet", cp.instanceName(shardInfo))
+	}
+	return host, nil
+}
+
+// WaitReady polls the instance over the network until it accepts connections
+func (cp *cloudProvisioner) WaitReady(shardInfo *ShardInfo) error {
+	db, err := sql.Open("mysql", shardInfo.DSN)
+	if err != nil {
+		return fmt.Errorf("failed to open connection: %w", err)
+	}
+	defer db.Close()
+
+	maxAttempts := cp.dsm.config.MaxConnectionAttempts
+	deadline := cp.dsm.readyDeadline()
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return fmt.Errorf("shard %s failed to become ready within %s", shardInfo.ID, cp.dsm.config.ReadyTimeout)
+		}
+		if err := db.Ping(); err == nil {
+			log.Printf("✅ Shard %s is ready after %d attempts", shardInfo.ID, attempt)
+			return nil
+		}
+		time.Sleep(time.Duration(cp.dsm.config.ConnectionRetryIntervalSeconds) * time.Second)
+	}
+
+	return fmt.Errorf("shard %s failed to become ready within %d attempts", shardInfo.ID, maxAttempts)
+}
+
+// SetupSchema applies the schema with a local mysql client
+func (cp *cloudProvisioner) SetupSchema(shardInfo *ShardInfo) error {
+	createTablesSQL, err := cp.dsm.shardSchema(shardInfo)
+	if err != nil {
+		return err
+	}
+
+	cmd, err := localMySQLCommand("mysql", shardInfo.DSN)
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = strings.NewReader(createTablesSQL)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create tables: %w, output: %s", err, string(output))
+	}
+
+	log.Printf("📊 Schema setup complete for shard %s", shardInfo.ID)
+	return nil
+}
+
+// Exists reports whether the managed instance still exists
+func (cp *cloudProvisioner) Exists(shardInfo *ShardInfo) bool {
+	args := cp.describeArgs(shardInfo)
+	return exec.Command(args[0], args[1:]...).Run() == nil
+}
+
+// Remove deletes the managed instance without keeping a final snapshot
+func (cp *cloudProvisioner) Remove(shardInfo *ShardInfo) error {
+	name := cp.instanceName(shardInfo)
+
+	var args []string
+	switch cp.provider {
+	case ProvisionerRDS:
+		args = []string{"aws", "rds", "delete-db-instance",
+			"--db-instance-identifier", name,
+			"--skip-final-snapshot",
+			"--region", cp.cfg.Region}
+	case ProvisionerCloudSQL:
+		args = cp.gcloudArgs([]string{"gcloud", "sql", "instances", "delete", name, "--quiet"})
+	default:
+		args = []string{"az", "mysql", "flexible-server", "delete",
+			"--name", name,
+			"--resource-group", cp.cfg.ResourceGroup,
+			"--yes"}
+	}
+
+	if output, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w, output: %s", strings.Join(args[:3], " "), err, string(output))
+	}
+
+	log.Printf("🗑️  Deleted %s instance %s", cp.provider, name)
+	return nil
+}
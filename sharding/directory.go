@@ -0,0 +1,261 @@
+package sharding
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// DirectoryEntry routes a table's key, or an inclusive integer key range, to a
+// specific shard regardless of the hash ring. An entry without a table applies
+// to every table.
+type DirectoryEntry struct {
+	Table      string `json:"table"`
+	Key        string `json:"key,omitempty"`
+	RangeStart *int64 `json:"range_start,omitempty"`
+	RangeEnd   *int64 `json:"range_end,omitempty"`
+	ShardID    string `json:"shard_id"`
+}
+
+// IsRange reports whether the entry covers a key range rather than one key
+func (e *DirectoryEntry) IsRange() bool {
+	return e.RangeStart != nil || e.RangeEnd != nil
+}
+
+// Validate checks that the entry names exactly one key or a complete range
+func (e *DirectoryEntry) Validate() error {
+	if e.ShardID == "" {
+		return fmt.Errorf("shard is required")
+	}
+	if e.IsRange() {
+		if e.Key != "" {
+			return fmt.Errorf("specify either a key or a range, not both")
+		}
+		if e.RangeStart == nil || e.RangeEnd == nil {
+			return fmt.Errorf("a range needs both range_start and range_end")
+		}
+		if *e.RangeStart > *e.RangeEnd {
+			return fmt.Errorf("range_start must not be greater than range_end")
+		}
+		return nil
+	}
+	if e.Key == "" {
+		return fmt.Errorf("a key or a range is required")
+	}
+	return nil
+}
+
+// Matches reports whether the entry covers the given table and key
+func (e *DirectoryEntry) Matches(table, key string) bool {
+	if e.Table != "" && e.Table != table {
+		return false
+	}
+	if !e.IsRange() {
+		return e.Key == key
+	}
+
+	n, err := strconv.ParseInt(key, 10, 64)
+	if err != nil {
+		return false
+	}
+	return n >= *e.RangeStart && n <= *e.RangeEnd
+}
+
+// sameTarget reports whether two entries cover the same key or range
+func (e *DirectoryEntry) sameTarget(other *DirectoryEntry) bool {
+	if e.Table != other.Table || e.IsRange() != other.IsRange() {
+		return false
+	}
+	if !e.IsRange() {
+		return e.Key == other.Key
+	}
+	return *e.RangeStart == *other.RangeStart && *e.RangeEnd == *other.RangeEnd
+}
+
+// SetDirectoryEntry installs a routing override, replacing any entry for the
+// same key or range
+func (dsm *DynamicShardManager) SetDirectoryEntry(entry DirectoryEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	if _, exists := dsm.shards[entry.ShardID]; !exists {
+		return fmt.Errorf("shard %s not found", entry.ShardID)
+	}
+
+	for i := range dsm.directory {
+		if dsm.directory[i].sameTarget(&entry) {
+			dsm.directory[i] = entry
+			return nil
+		}
+	}
+	dsm.directory = append(dsm.directory, entry)
+	return nil
+}
+
+// shadows reports whether the entry routes some keys of a range entry
+// elsewhere: an exact key inside the range, or an overlapping range taking
+// precedence over it
+func (e *DirectoryEntry) shadows(rangeEntry *DirectoryEntry) bool {
+	if e.Table != "" && e.Table != rangeEntry.Table {
+		return false
+	}
+	if !e.IsRange() {
+		n, err := strconv.ParseInt(e.Key, 10, 64)
+		return err == nil && n >= *rangeEntry.RangeStart && n <= *rangeEntry.RangeEnd
+	}
+	overlaps := *e.RangeStart <= *rangeEntry.RangeEnd && *e.RangeEnd >= *rangeEntry.RangeStart
+	return overlaps && e.precedes(rangeEntry)
+}
+
+// within reports whether the entry covers only keys of the table of a range
+// entry inside its range
+func (e *DirectoryEntry) within(rangeEntry *DirectoryEntry) bool {
+	if e.Table != rangeEntry.Table {
+		return false
+	}
+	if !e.IsRange() {
+		return true
+	}
+	return *e.RangeStart >= *rangeEntry.RangeStart && *e.RangeEnd <= *rangeEntry.RangeEnd
+}
+
+// checkRangeMove fails when entries that take precedence over a range would
+// keep routing some of its keys away from rows moved under it and can't be
+// dropped for it: global entries, and ranges reaching outside it
+func (dsm *DynamicShardManager) checkRangeMove(entry DirectoryEntry) error {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	for i := range dsm.directory {
+		existing := &dsm.directory[i]
+		if existing.ShardID == entry.ShardID || !existing.shadows(&entry) || existing.within(&entry) {
+			continue
+		}
+		target := existing.describe()
+		if existing.Table == "" {
+			target = "global " + target
+		}
+		return fmt.Errorf("the override for %s routes keys of this range to %s; remove it first", target, existing.ShardID)
+	}
+	return nil
+}
+
+// setMovedRange installs the override for a range whose rows were moved,
+// dropping the entries for its table inside the range that would otherwise
+// keep routing their keys to shards the rows were deleted from
+func (dsm *DynamicShardManager) setMovedRange(entry DirectoryEntry) ([]DirectoryEntry, error) {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	if _, exists := dsm.shards[entry.ShardID]; !exists {
+		return nil, fmt.Errorf("shard %s not found", entry.ShardID)
+	}
+
+	var dropped []DirectoryEntry
+	kept := []DirectoryEntry{entry}
+	for _, existing := range dsm.directory {
+		switch {
+		case existing.sameTarget(&entry):
+		case existing.shadows(&entry) && existing.within(&entry):
+			dropped = append(dropped, existing)
+		default:
+			kept = append(kept, existing)
+		}
+	}
+	dsm.directory = kept
+	return dropped, nil
+}
+
+// describe names the key or range an entry covers
+func (e *DirectoryEntry) describe() string {
+	if e.IsRange() {
+		return fmt.Sprintf("range %d-%d", *e.RangeStart, *e.RangeEnd)
+	}
+	return "key " + e.Key
+}
+
+// RemoveDirectoryEntry removes the override for the same key or range as the
+// given entry, reporting whether one existed
+func (dsm *DynamicShardManager) RemoveDirectoryEntry(entry DirectoryEntry) bool {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	for i := range dsm.directory {
+		if dsm.directory[i].sameTarget(&entry) {
+			dsm.directory = append(dsm.directory[:i], dsm.directory[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// GetDirectory returns a copy of all routing overrides
+func (dsm *DynamicShardManager) GetDirectory() []DirectoryEntry {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	return append([]DirectoryEntry{}, dsm.directory...)
+}
+
+// RestoreDirectory replaces the routing overrides with persisted entries. The
+// target shards are not checked since they may still be resuming.
+func (dsm *DynamicShardManager) RestoreDirectory(entries []DirectoryEntry) {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	dsm.directory = append([]DirectoryEntry{}, entries...)
+}
+
+// lookupDirectory returns the shard a directory entry routes the key to. Exact
+// key entries take precedence over ranges, table-specific entries over global
+// ones, and narrower ranges over wider ones.
+func (dsm *DynamicShardManager) lookupDirectory(table, key string) (string, bool) {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	var best *DirectoryEntry
+	for i := range dsm.directory {
+		entry := &dsm.directory[i]
+		if entry.Matches(table, key) && (best == nil || entry.precedes(best)) {
+			best = entry
+		}
+	}
+
+	if best == nil {
+		return "", false
+	}
+	return best.ShardID, true
+}
+
+// precedes reports whether the entry is more specific than another matching entry
+func (e *DirectoryEntry) precedes(other *DirectoryEntry) bool {
+	if e.IsRange() != other.IsRange() {
+		return !e.IsRange()
+	}
+	if (e.Table == "") != (other.Table == "") {
+		return e.Table != ""
+	}
+	if e.IsRange() {
+		return *e.RangeEnd-*e.RangeStart < *other.RangeEnd-*other.RangeStart
+	}
+	return false
+}
+
+// GetShardForTable returns the shard for a table's key, consulting directory
+// overrides for the table, then the table's time ranges if it is sharded by
+// time, before the consistent hash ring
+func (dsm *DynamicShardManager) GetShardForTable(table, key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("key cannot be empty")
+	}
+	if shardID, exists := dsm.lookupDirectory(table, key); exists {
+		return dsm.resolveMerged(shardID), nil
+	}
+	if shardID, isTimeRange, err := dsm.timeRangeShard(table, key); isTimeRange {
+		return shardID, err
+	}
+	return dsm.GetShard(key)
+}
@@ -0,0 +1,162 @@
+package sharding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InitHookConfig runs a team's own fixtures, grants or replication setup on
+// every new shard once its schema is in place
+type InitHookConfig struct {
+	// ScriptsDir holds .sql scripts, run against the shard's database, and
+	// .sh scripts, run locally with the shard described in SHARD_*
+	// environment variables. Scripts run in file name order.
+	ScriptsDir string
+	// WebhookURL receives a POST with the new shard's ShardInfo as JSON
+	WebhookURL string
+	// Timeout bounds each script and the webhook call
+	Timeout time.Duration
+	// Required fails the shard when a hook fails instead of only warning
+	Required bool
+}
+
+// runInitHooks runs the init scripts, then calls the webhook, stopping at
+// the first failure
+func (dsm *DynamicShardManager) runInitHooks(shardInfo *ShardInfo) error {
+	hooks := dsm.config.Init
+	if hooks.ScriptsDir != "" {
+		scripts, err := initScripts(hooks.ScriptsDir)
+		if err != nil {
+			return err
+		}
+		for _, script := range scripts {
+			if err := dsm.runInitScript(shardInfo, script); err != nil {
+				return fmt.Errorf("init script %s: %w", filepath.Base(script), err)
+			}
+		}
+		if len(scripts) > 0 {
+			log.Printf("🌱 Ran %d init scripts on shard %s", len(scripts), shardInfo.ID)
+		}
+	}
+
+	if hooks.WebhookURL != "" {
+		if err := dsm.callInitWebhook(shardInfo); err != nil {
+			return fmt.Errorf("init webhook: %w", err)
+		}
+		log.Printf("🌱 Init webhook accepted shard %s", shardInfo.ID)
+	}
+	return nil
+}
+
+// initScripts lists the .sql and .sh files of the scripts directory in name order
+func initScripts(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read init scripts directory: %w", err)
+	}
+
+	var scripts []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if !entry.IsDir() && (ext == ".sql" || ext == ".sh") {
+			scripts = append(scripts, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(scripts)
+	return scripts, nil
+}
+
+// runInitScript runs one init script against a shard
+func (dsm *DynamicShardManager) runInitScript(shardInfo *ShardInfo, script string) error {
+	var cmd *exec.Cmd
+	if filepath.Ext(script) == ".sql" {
+		content, err := os.ReadFile(script)
+		if err != nil {
+			return fmt.Errorf("failed to read script: %w", err)
+		}
+		cmd, err = dsm.shardMySQLCommand(shardInfo)
+		if err != nil {
+			return err
+		}
+		cmd.Stdin = bytes.NewReader(content)
+	} else {
+		cmd = exec.Command("/bin/sh", script)
+		cmd.Env = append(os.Environ(), dsm.initScriptEnv(shardInfo)...)
+	}
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	timer := time.AfterFunc(dsm.config.Init.Timeout, func() { cmd.Process.Kill() })
+	err := cmd.Wait()
+	if !timer.Stop() {
+		return fmt.Errorf("timed out after %s", dsm.config.Init.Timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%w, output: %s", err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// initScriptEnv describes a shard to shell init scripts
+func (dsm *DynamicShardManager) initScriptEnv(shardInfo *ShardInfo) []string {
+	password, _ := dsm.credentials()
+	env := []string{
+		"SHARD_ID=" + shardInfo.ID,
+		"SHARD_HOST=" + shardInfo.Host,
+		"SHARD_PORT=" + strconv.Itoa(shardInfo.Port),
+		"SHARD_DATABASE=" + shardInfo.DatabaseName,
+		"SHARD_DSN=" + shardInfo.DSN,
+		"SHARD_ZONE=" + shardInfo.Zone,
+		"SHARD_NUMBER=" + strconv.Itoa(shardNumber(shardInfo.ID)),
+		"MYSQL_USER=" + dsm.config.DatabaseUsername,
+		"MYSQL_PWD=" + password,
+	}
+	if dsm.provisioner.Name() == ProvisionerDocker {
+		env = append(env, "SHARD_CONTAINER="+dsm.containerName(shardInfo.ID))
+	}
+	return env
+}
+
+// callInitWebhook posts the new shard to the init webhook, which must answer 2xx
+func (dsm *DynamicShardManager) callInitWebhook(shardInfo *ShardInfo) error {
+	body, err := json.Marshal(shardInfo)
+	if err != nil {
+		return fmt.Errorf("failed to encode shard: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dsm.config.Init.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dsm.config.Init.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
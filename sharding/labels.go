@@ -0,0 +1,199 @@
+package sharding
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Label selector operators
+const (
+	selectorEquals    = "="
+	selectorNotEquals = "!="
+	selectorIn        = "in"
+	selectorNotIn     = "notin"
+	selectorExists    = "exists"
+	selectorNotExists = "!exists"
+)
+
+// labelRequirement is a single comma-separated term of a label selector
+type labelRequirement struct {
+	key      string
+	operator string
+	values   []string
+}
+
+// LabelSelector selects shards by their labels. Terms are comma-separated and
+// must all match: "region=us-east", "tier!=cold", "zone in (a, b)",
+// "zone notin (c)", "ssd" (label present) and "!ssd" (label absent).
+type LabelSelector struct {
+	requirements []labelRequirement
+}
+
+// ParseSelector parses a label selector; an empty selector matches every shard
+func ParseSelector(selector string) (*LabelSelector, error) {
+	var terms []string
+	depth, start := 0, 0
+	for i, ch := range selector {
+		switch ch {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses in selector %q", selector)
+	}
+	terms = append(terms, selector[start:])
+
+	ls := &LabelSelector{}
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		req, err := parseRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+		ls.requirements = append(ls.requirements, req)
+	}
+	return ls, nil
+}
+
+// parseRequirement parses one selector term
+func parseRequirement(term string) (labelRequirement, error) {
+	if idx := strings.Index(term, "!="); idx > 0 {
+		return labelRequirement{key: strings.TrimSpace(term[:idx]), operator: selectorNotEquals,
+			values: []string{strings.TrimSpace(term[idx+2:])}}, nil
+	}
+	if idx := strings.Index(term, "="); idx > 0 {
+		value := strings.TrimPrefix(term[idx+1:], "=")
+		return labelRequirement{key: strings.TrimSpace(term[:idx]), operator: selectorEquals,
+			values: []string{strings.TrimSpace(value)}}, nil
+	}
+
+	if open := strings.Index(term, "("); open > 0 {
+		if !strings.HasSuffix(term, ")") {
+			return labelRequirement{}, fmt.Errorf("invalid selector term %q", term)
+		}
+		fields := strings.Fields(term[:open])
+		if len(fields) != 2 || (fields[1] != selectorIn && fields[1] != selectorNotIn) {
+			return labelRequirement{}, fmt.Errorf("invalid selector term %q, expected \"key in (...)\" or \"key notin (...)\"", term)
+		}
+
+		var values []string
+		for _, value := range strings.Split(term[open+1:len(term)-1], ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		if len(values) == 0 {
+			return labelRequirement{}, fmt.Errorf("selector term %q has no values", term)
+		}
+		return labelRequirement{key: fields[0], operator: fields[1], values: values}, nil
+	}
+
+	if strings.ContainsAny(term, " \t") {
+		return labelRequirement{}, fmt.Errorf("invalid selector term %q", term)
+	}
+	if strings.HasPrefix(term, "!") {
+		return labelRequirement{key: term[1:], operator: selectorNotExists}, nil
+	}
+	return labelRequirement{key: term, operator: selectorExists}, nil
+}
+
+// Matches reports whether labels satisfy every term of the selector
+func (ls *LabelSelector) Matches(labels map[string]string) bool {
+	for _, req := range ls.requirements {
+		value, exists := labels[req.key]
+		switch req.operator {
+		case selectorEquals:
+			if !exists || value != req.values[0] {
+				return false
+			}
+		case selectorNotEquals:
+			if exists && value == req.values[0] {
+				return false
+			}
+		case selectorIn:
+			if !exists || !containsString(req.values, value) {
+				return false
+			}
+		case selectorNotIn:
+			if exists && containsString(req.values, value) {
+				return false
+			}
+		case selectorExists:
+			if !exists {
+				return false
+			}
+		case selectorNotExists:
+			if exists {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Empty reports whether the selector has no terms
+func (ls *LabelSelector) Empty() bool {
+	return len(ls.requirements) == 0
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ShardsMatching returns the active shards whose labels match the selector, sorted
+func (dsm *DynamicShardManager) ShardsMatching(selector *LabelSelector) []string {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	var shardIDs []string
+	for shardID, shardInfo := range dsm.shards {
+		if shardInfo.Status == "active" && selector.Matches(shardInfo.Labels) {
+			shardIDs = append(shardIDs, shardID)
+		}
+	}
+	sort.Strings(shardIDs)
+	return shardIDs
+}
+
+// SetShardLabels replaces the labels of a shard
+func (dsm *DynamicShardManager) SetShardLabels(shardID string, labels map[string]string) error {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	shardInfo, exists := dsm.shards[shardID]
+	if !exists {
+		return fmt.Errorf("shard %s not found", shardID)
+	}
+	shardInfo.Labels = copyLabels(labels)
+	return nil
+}
+
+// copyLabels returns a copy of a label map, or nil if it is empty
+func copyLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	copied := make(map[string]string, len(labels))
+	for k, v := range labels {
+		copied[k] = v
+	}
+	return copied
+}
@@ -0,0 +1,35 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ContainerLogs writes the last tail lines a shard's container logged, with
+// timestamps, to w. The MySQL image logs its error log to stderr, so it is
+// included. With follow it keeps writing new lines until ctx is done. It
+// applies to the docker provisioner only.
+func (dsm *DynamicShardManager) ContainerLogs(ctx context.Context, shardID string, tail int, follow bool, w io.Writer) error {
+	if dsm.provisioner.Name() != ProvisionerDocker {
+		return fmt.Errorf("shards provisioned by %s have no container", dsm.provisioner.Name())
+	}
+
+	containerName := dsm.containerName(shardID)
+	if err := dsm.dockerCommandContext(ctx, shardID, "inspect", containerName).Run(); err != nil {
+		return fmt.Errorf("container %s of shard %s not found", containerName, shardID)
+	}
+
+	args := []string{"logs", "--timestamps", "--tail", strconv.Itoa(tail)}
+	if follow {
+		args = append(args, "--follow")
+	}
+	cmd := dsm.dockerCommandContext(ctx, shardID, append(args, containerName)...)
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Run(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to read logs of container %s: %w", containerName, err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package sharding
+
+import (
+	"fmt"
+	"time"
+)
+
+// ClusterMaintenanceID is the maintenance key for cluster-wide windows
+const ClusterMaintenanceID = "cluster"
+
+// Maintenance modes control how the router treats a shard during a window
+const (
+	MaintenanceReadOnly = "read_only"
+	MaintenanceExcluded = "excluded"
+)
+
+// MaintenanceWindow describes a period during which scaling is suppressed and
+// the router limits traffic to a shard (or the whole cluster)
+type MaintenanceWindow struct {
+	ShardID string    `json:"shard_id"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Mode    string    `json:"mode"`
+	Reason  string    `json:"reason,omitempty"`
+}
+
+// Validate checks that the window is well formed
+func (mw *MaintenanceWindow) Validate() error {
+	if mw.Mode != MaintenanceReadOnly && mw.Mode != MaintenanceExcluded {
+		return fmt.Errorf("maintenance mode must be '%s' or '%s'", MaintenanceReadOnly, MaintenanceExcluded)
+	}
+	if !mw.End.After(mw.Start) {
+		return fmt.Errorf("maintenance window must end after it starts")
+	}
+	return nil
+}
+
+// ActiveAt reports whether the window covers the given time
+func (mw *MaintenanceWindow) ActiveAt(t time.Time) bool {
+	return !t.Before(mw.Start) && t.Before(mw.End)
+}
+
+// SetMaintenance declares a maintenance window for a shard or the cluster
+func (dsm *DynamicShardManager) SetMaintenance(window MaintenanceWindow) error {
+	if err := window.Validate(); err != nil {
+		return err
+	}
+
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	if window.ShardID != ClusterMaintenanceID {
+		if _, exists := dsm.shards[window.ShardID]; !exists {
+			return fmt.Errorf("shard %s not found", window.ShardID)
+		}
+	}
+
+	dsm.maintenance[window.ShardID] = &window
+	return nil
+}
+
+// ClearMaintenance removes the maintenance window for a shard or the cluster
+func (dsm *DynamicShardManager) ClearMaintenance(shardID string) {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	delete(dsm.maintenance, shardID)
+}
+
+// GetMaintenance returns the declared window for a shard (active or not)
+func (dsm *DynamicShardManager) GetMaintenance(shardID string) (*MaintenanceWindow, bool) {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	window, exists := dsm.maintenance[shardID]
+	if !exists {
+		return nil, false
+	}
+	copied := *window
+	return &copied, true
+}
+
+// ActiveMaintenance returns the window currently in effect for a shard, taking
+// cluster-wide windows into account. Excluded takes precedence over read-only.
+func (dsm *DynamicShardManager) ActiveMaintenance(shardID string) (*MaintenanceWindow, bool) {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	now := time.Now()
+	var active *MaintenanceWindow
+	for _, key := range []string{shardID, ClusterMaintenanceID} {
+		window, exists := dsm.maintenance[key]
+		if !exists || !window.ActiveAt(now) {
+			continue
+		}
+		if active == nil || window.Mode == MaintenanceExcluded {
+			active = window
+		}
+	}
+
+	if active == nil {
+		return nil, false
+	}
+	copied := *active
+	return &copied, true
+}
@@ -3,28 +3,55 @@ package sharding
 import (
 	"fmt"
 	"log"
-	"os/exec"
+	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"stathat.com/c/consistent"
+	"sql-horizontal-autoscaler/config"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 // DynamicShardManager manages dynamic shard creation and consistent hashing
 type DynamicShardManager struct {
-	ring         *consistent.Consistent
+	ring         *hashRing
 	shards       map[string]*ShardInfo
 	mutex        sync.RWMutex
 	nextShardNum int
 	config       *ShardManagerConfig
+	maintenance  map[string]*MaintenanceWindow
+	capacity     CapacityStatus
+	provisioner  Provisioner
+	directory    []DirectoryEntry
+	divergences  map[string]Divergence
+	timePolicies map[string]TimeRangePolicy
+	timeRanges   map[string][]TimeRange
+	splits       map[string]*splitState
+	readOnly     map[string]*ReadOnlyShard
+	splitMutex   sync.Mutex
+	// mirrors holds the read mirrors of every shard by mirror ID
+	mirrors       map[string]*MirrorInfo
+	nextMirrorNum int
+	// resyncs holds the latest checksum resync of each shard
+	resyncs     map[string]*resyncState
+	resyncMutex sync.Mutex
+	// misplaced holds the latest audit of rows living on the wrong shard
+	misplaced      *MisplacedAudit
+	misplacedMutex sync.Mutex
+	// credentialsMutex guards the passwords in config, which rotate while
+	// shards are provisioned
+	credentialsMutex sync.RWMutex
 }
 
 // ShardManagerConfig contains configuration for the shard manager
 type ShardManagerConfig struct {
 	BasePort                       int
 	NetworkName                    string
+	NetworkDriver                  string
+	NetworkSubnet                  string
 	DatabaseUsername               string
 	DatabasePassword               string
 	DatabaseRootPassword           string
@@ -32,31 +59,77 @@ type ShardManagerConfig struct {
 	ContainerPrefix                string
 	MaxConnectionAttempts          int
 	ConnectionRetryIntervalSeconds int
+	DSNParams                      map[string]string
+	ShardDSNParams                 map[string]map[string]string
+	Provisioner                    string
+	Cloud                          CloudProvisionerConfig
+	Volumes                        VolumeConfig
+	// ShardLabels assigns labels to shards by ID, including shards created later
+	ShardLabels map[string]map[string]string
+	// Zones lists the failure domains new shards are balanced across
+	Zones []Zone
+	// Init runs seed scripts and a webhook on each new shard
+	Init InitHookConfig
+	// Warmup primes each new shard before it joins the ring
+	Warmup WarmupConfig
+	// ReadyTimeout bounds the wait for a new shard to accept connections on
+	// top of MaxConnectionAttempts; zero leaves only the attempts
+	ReadyTimeout time.Duration
+	// RampStartWeight is the ring weight scaled-out shards join with, raised
+	// later by the coordinator; 1 or more joins at full weight
+	RampStartWeight float64
+	// MirrorBasePort is the host port of the first read mirror
+	MirrorBasePort int
+	// ShardPorts sets the port of configured shards by ID, for DSNs whose
+	// address isn't the port the shard is published on
+	ShardPorts map[string]int
 }
 
 // ShardInfo contains information about a shard
 type ShardInfo struct {
 	ID          string    `json:"id"`
+	Host        string    `json:"host,omitempty"`
 	Port        int       `json:"port"`
 	DSN         string    `json:"dsn"`
 	DatabaseName string   `json:"database_name"`
 	Status      string    `json:"status"`
 	CreatedAt   time.Time `json:"created_at"`
+	// MergedInto is the shard that took over this shard's key space
+	MergedInto  string    `json:"merged_into,omitempty"`
+	// Labels are free-form key/value tags used to select subsets of shards
+	Labels map[string]string `json:"labels,omitempty"`
+	// Zone is the availability zone or Docker host the shard runs in
+	Zone string `json:"zone,omitempty"`
+	// Region is the region the shard's primary runs in
+	Region string `json:"region,omitempty"`
 }
 
 // NewDynamicShardManager creates a new dynamic shard manager
 func NewDynamicShardManager(initialShards map[string]string, config *ShardManagerConfig) *DynamicShardManager {
-	ring := consistent.New()
+	ring := newHashRing()
 	shards := make(map[string]*ShardInfo)
 
-	// Add initial shards to the ring and track them
-	nextShardNum := 1
-	for shardID, dsn := range initialShards {
+	// Add initial shards to the ring and track them, in shard number order so
+	// fallback ports and database names don't depend on map iteration
+	shardIDs := make([]string, 0, len(initialShards))
+	for shardID := range initialShards {
+		shardIDs = append(shardIDs, shardID)
+	}
+	sortShardIDs(shardIDs)
+
+	nextShardNum := len(shardIDs) + 1
+	for i, shardID := range shardIDs {
+		dsn := initialShards[shardID]
 		ring.Add(shardID)
 
-		// Extract port from DSN or calculate it
-		port := config.BasePort + nextShardNum - 1
-		dbName := fmt.Sprintf("shard%d_db", nextShardNum)
+		num := shardNumber(shardID)
+		if num == 0 {
+			num = i + 1
+		}
+		if num >= nextShardNum {
+			nextShardNum = num + 1
+		}
+		port, dbName := initialShardAddress(config, shardID, dsn, num)
 
 		shards[shardID] = &ShardInfo{
 			ID:          shardID,
@@ -65,30 +138,104 @@ func NewDynamicShardManager(initialShards map[string]string, config *ShardManage
 			DatabaseName: dbName,
 			Status:      "active",
 			CreatedAt:   time.Now(),
+			Labels:      copyLabels(config.ShardLabels[shardID]),
+			Zone:        config.ShardLabels[shardID][ZoneLabel],
+			Region:      config.ShardLabels[shardID][RegionLabel],
 		}
+	}
+
+	// New shards take the port of their number; skip numbers whose port a
+	// configured shard already publishes
+	usedPorts := make(map[int]bool, len(shards))
+	for _, shardInfo := range shards {
+		usedPorts[shardInfo.Port] = true
+	}
+	for usedPorts[config.BasePort+nextShardNum-1] {
 		nextShardNum++
 	}
 
-	return &DynamicShardManager{
+	dsm := &DynamicShardManager{
 		ring:         ring,
 		shards:       shards,
 		nextShardNum: nextShardNum,
 		config:       config,
+		maintenance:  make(map[string]*MaintenanceWindow),
+		divergences:  make(map[string]Divergence),
+		timeRanges:   make(map[string][]TimeRange),
+		splits:       make(map[string]*splitState),
+		readOnly:     make(map[string]*ReadOnlyShard),
+		mirrors:      make(map[string]*MirrorInfo),
+
+		nextMirrorNum: 1,
+		resyncs:       make(map[string]*resyncState),
+	}
+	dsm.provisioner = newProvisioner(dsm)
+	return dsm
+}
+
+// initialShardAddress returns the port and database of a configured shard:
+// the port from ShardPorts, else from its DSN, else the one its number would
+// have been given, and the database from its DSN, else its number's default
+func initialShardAddress(config *ShardManagerConfig, shardID, dsn string, num int) (int, string) {
+	port := config.BasePort + num - 1
+	dbName := fmt.Sprintf("shard%d_db", num)
+
+	if dsnConfig, err := mysql.ParseDSN(dsn); err == nil {
+		if _, portText, err := net.SplitHostPort(dsnConfig.Addr); err == nil {
+			if dsnPort, err := strconv.Atoi(portText); err == nil {
+				port = dsnPort
+			}
+		}
+		if dsnConfig.DBName != "" {
+			dbName = dsnConfig.DBName
+		}
+	} else {
+		log.Printf("Warning: Failed to parse DSN of shard %s, assuming port %d: %v", shardID, port, err)
+	}
+	if configured, exists := config.ShardPorts[shardID]; exists {
+		port = configured
+	}
+	return port, dbName
+}
+
+// sortShardIDs orders shard IDs by shard number, then by ID
+func sortShardIDs(shardIDs []string) {
+	sort.Slice(shardIDs, func(i, j int) bool {
+		ni, nj := shardNumber(shardIDs[i]), shardNumber(shardIDs[j])
+		if ni != nj {
+			return ni < nj
+		}
+		return shardIDs[i] < shardIDs[j]
+	})
+}
+
+// newProvisioner returns the provisioner selected in the configuration
+func newProvisioner(dsm *DynamicShardManager) Provisioner {
+	switch dsm.config.Provisioner {
+	case ProvisionerRDS, ProvisionerCloudSQL, ProvisionerAzure:
+		return &cloudProvisioner{dsm: dsm, provider: dsm.config.Provisioner, cfg: dsm.config.Cloud}
+	default:
+		return &dockerProvisioner{dsm: dsm}
 	}
 }
 
-// GetShard returns the shard ID for a given key using consistent hashing
+// GetShard returns the shard ID for a given key using consistent hashing,
+// unless a table-independent directory entry overrides the key
 func (dsm *DynamicShardManager) GetShard(key string) (string, error) {
 	if key == "" {
 		return "", fmt.Errorf("key cannot be empty")
 	}
 
+	if shardID, exists := dsm.lookupDirectory("", key); exists {
+		return dsm.resolveMerged(shardID), nil
+	}
+
 	shard, err := dsm.ring.Get(key)
 	if err != nil {
 		return "", fmt.Errorf("failed to get shard for key %s: %w", key, err)
 	}
 
-	return shard, nil
+	return dsm.resolveMerged(shard), nil
 }
 
 // GetAllShards returns all active shards
@@ -121,78 +268,253 @@ func (dsm *DynamicShardManager) GetAllShardInfo() map[string]*ShardInfo {
 	
 	result := make(map[string]*ShardInfo)
 	for k, v := range dsm.shards {
-		result[k] = v
+		copied := *v
+		result[k] = &copied
 	}
 	return result
 }
 
-// AddNewShard dynamically creates and adds a new shard
+// AddNewShard dynamically creates and adds a new shard, placed in the zone
+// with the fewest shards
 func (dsm *DynamicShardManager) AddNewShard() (*ShardInfo, error) {
+	return dsm.AddNewShardInZone("")
+}
+
+// AddNewShardInZone dynamically creates and adds a new shard in the given
+// zone; an empty zone balances the shard across the configured zones. The
+// shard joins the ring at RampStartWeight. A shard that fails to come up is
+// torn down, returning a *ProvisioningError.
+func (dsm *DynamicShardManager) AddNewShardInZone(zone string) (*ShardInfo, error) {
+	shardInfo, err := dsm.createShard(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	if weight := dsm.config.RampStartWeight; weight > 0 && weight < 1 {
+		dsm.ring.SetWeight(shardInfo.ID, weight)
+	}
+	if err := dsm.completeProvisioning(shardInfo); err != nil {
+		dsm.ring.SetWeight(shardInfo.ID, 1)
+		return nil, dsm.abandonShard(shardInfo, err)
+	}
+
+	log.Printf("✅ Successfully created and activated shard: %s", shardInfo.ID)
+	return dsm.copyShardInfo(shardInfo.ID), nil
+}
+
+// createShard reserves the next shard ID and provisions its instance, leaving
+// the shard in the "provisioning" state outside the ring
+func (dsm *DynamicShardManager) createShard(zone string) (*ShardInfo, error) {
+	if zone != "" && !dsm.hasZone(zone) {
+		return nil, fmt.Errorf("zone %s is not configured", zone)
+	}
+
 	dsm.mutex.Lock()
-	defer dsm.mutex.Unlock()
 
 	// Generate new shard configuration
 	newShardID := fmt.Sprintf("shard-%d", dsm.nextShardNum)
-	newPort := dsm.config.BasePort + dsm.nextShardNum - 1
 	newDBName := fmt.Sprintf("shard%d_db", dsm.nextShardNum)
-	newDSN := fmt.Sprintf("%s:%s@tcp(127.0.0.1:%d)/%s",
-		dsm.config.DatabaseUsername, dsm.config.DatabasePassword, newPort, newDBName)
 
-	log.Printf("🚀 Creating new shard: %s on port %d", newShardID, newPort)
+	log.Printf("🚀 Creating new shard: %s using the %s provisioner", newShardID, dsm.provisioner.Name())
 
-	// Create new shard info
+	// Create new shard info and reserve its ID while it is provisioned, so the
+	// in-progress shard is visible to snapshots without holding the lock
 	shardInfo := &ShardInfo{
 		ID:          newShardID,
-		Port:        newPort,
-		DSN:         newDSN,
 		DatabaseName: newDBName,
 		Status:      "provisioning",
 		CreatedAt:   time.Now(),
+		Labels:      copyLabels(dsm.config.ShardLabels[newShardID]),
+		Zone:        dsm.placeShard(zone),
+	}
+	if shardInfo.Zone != "" {
+		if shardInfo.Labels == nil {
+			shardInfo.Labels = make(map[string]string)
+		}
+		shardInfo.Labels[ZoneLabel] = shardInfo.Zone
+		log.Printf("📍 Placing shard %s in zone %s", newShardID, shardInfo.Zone)
+	}
+	shardInfo.Region = dsm.zoneRegion(shardInfo.Zone, shardInfo.Labels[RegionLabel])
+	if shardInfo.Region != "" {
+		if shardInfo.Labels == nil {
+			shardInfo.Labels = make(map[string]string)
+		}
+		shardInfo.Labels[RegionLabel] = shardInfo.Region
 	}
+	dsm.shards[newShardID] = shardInfo
+	dsm.nextShardNum++
 
-	// Start Docker container for new shard
-	if err := dsm.provisionDockerShard(shardInfo); err != nil {
-		return nil, fmt.Errorf("failed to provision shard %s: %w", newShardID, err)
+	// The provisioner fills in host, port and DSN on a copy, published once
+	// complete since readers share the tracked shard info
+	provisioned := *shardInfo
+	provisioned.Labels = copyLabels(shardInfo.Labels)
+	dsm.mutex.Unlock()
+
+	// Create the backing instance
+	if err := dsm.provisioner.Provision(&provisioned); err != nil {
+		dsm.setShardStatus(newShardID, "failed")
+		return nil, dsm.abandonShard(&provisioned, fmt.Errorf("failed to provision shard %s: %w", newShardID, err))
 	}
 
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+	if current, exists := dsm.shards[newShardID]; exists {
+		provisioned.Status = current.Status
+	}
+	dsm.shards[newShardID] = &provisioned
+	return &provisioned, nil
+}
+
+// buildDSN builds the DSN for a provisioned shard
+func (dsm *DynamicShardManager) buildDSN(shardID, host string, port int, dbName string) (string, error) {
+	password, _ := dsm.credentials()
+	return config.ApplyDSNParams(fmt.Sprintf("%s:%s@tcp(%s:%d)/%s",
+		dsm.config.DatabaseUsername, password, host, port, dbName),
+		config.MergeDSNParams(dsm.config.DSNParams, dsm.config.ShardDSNParams[shardID]))
+}
+
+// completeProvisioning waits for a created instance, sets up its schema and
+// adds the shard to the consistent hash ring
+func (dsm *DynamicShardManager) completeProvisioning(shardInfo *ShardInfo) error {
 	// Wait for shard to be ready
-	if err := dsm.waitForShardReady(shardInfo); err != nil {
-		return nil, fmt.Errorf("shard %s failed to become ready: %w", newShardID, err)
+	if err := dsm.provisioner.WaitReady(shardInfo); err != nil {
+		dsm.setShardStatus(shardInfo.ID, "failed")
+		return fmt.Errorf("shard %s failed to become ready: %w", shardInfo.ID, err)
 	}
 
-	// Setup database schema and initial data
-	if err := dsm.setupShardSchema(shardInfo); err != nil {
-		log.Printf("Warning: Failed to setup schema for shard %s: %v", newShardID, err)
+	// Setup database schema
+	if err := dsm.provisioner.SetupSchema(shardInfo); err != nil {
+		log.Printf("Warning: Failed to setup schema for shard %s: %v", shardInfo.ID, err)
 		// Don't fail completely, shard can still be used
 	}
 
+	// Run the configured init scripts and webhook
+	if err := dsm.runInitHooks(shardInfo); err != nil {
+		if dsm.config.Init.Required {
+			dsm.setShardStatus(shardInfo.ID, "failed")
+			return fmt.Errorf("init hooks failed for shard %s: %w", shardInfo.ID, err)
+		}
+		log.Printf("Warning: Init hooks failed for shard %s: %v", shardInfo.ID, err)
+	}
+
+	// Prime the instance before it takes traffic
+	dsm.warmShard(shardInfo)
+
 	// Add to consistent hash ring
-	dsm.ring.Add(newShardID)
-	
-	// Update shard status and tracking
-	shardInfo.Status = "active"
-	dsm.shards[newShardID] = shardInfo
-	dsm.nextShardNum++
+	dsm.ring.Add(shardInfo.ID)
 
-	log.Printf("✅ Successfully created and activated shard: %s", newShardID)
-	return shardInfo, nil
+	// Update shard status
+	dsm.setShardStatus(shardInfo.ID, "active")
+	return nil
+}
+
+// ResumeShard re-registers a shard recorded in a coordinator snapshot. Active
+// shards are added straight back to the ring; shards that were still
+// provisioning are completed if their instance exists.
+func (dsm *DynamicShardManager) ResumeShard(snapshot ShardInfo) (*ShardInfo, error) {
+	dsm.mutex.Lock()
+	if existing, exists := dsm.shards[snapshot.ID]; exists && existing.Status != "failed" {
+		dsm.mutex.Unlock()
+		return nil, fmt.Errorf("shard %s is already registered", snapshot.ID)
+	}
+
+	// Never hand out this shard's number (and port) again
+	if num := shardNumber(snapshot.ID); num >= dsm.nextShardNum {
+		dsm.nextShardNum = num + 1
+	}
+
+	shardInfo := &snapshot
+	wasActive := shardInfo.Status == "active"
+	shardInfo.Status = "provisioning"
+	dsm.shards[shardInfo.ID] = shardInfo
+	dsm.mutex.Unlock()
+
+	if !dsm.provisioner.Exists(shardInfo) {
+		// A retained volume lets the shard come back with its data
+		if dsm.provisioner.Name() != ProvisionerDocker || !dsm.volumeExists(shardInfo.ID) {
+			dsm.setShardStatus(shardInfo.ID, "failed")
+			return nil, fmt.Errorf("instance for shard %s no longer exists", shardInfo.ID)
+		}
+
+		log.Printf("♻️  Recreating container for shard %s on its existing volume", shardInfo.ID)
+		if err := dsm.provisionDockerShard(shardInfo); err != nil {
+			dsm.setShardStatus(shardInfo.ID, "failed")
+			return nil, fmt.Errorf("failed to recreate container for shard %s: %w", shardInfo.ID, err)
+		}
+		wasActive = false
+	}
+
+	if wasActive {
+		dsm.ring.Add(shardInfo.ID)
+		dsm.setShardStatus(shardInfo.ID, "active")
+	} else if err := dsm.completeProvisioning(shardInfo); err != nil {
+		return nil, err
+	}
+
+	log.Printf("♻️  Resumed shard %s from snapshot", shardInfo.ID)
+	return dsm.copyShardInfo(shardInfo.ID), nil
+}
+
+// containerName returns the container (or managed instance) name for a shard
+func (dsm *DynamicShardManager) containerName(shardID string) string {
+	return fmt.Sprintf("%s-%s", dsm.config.ContainerPrefix, shardID)
+}
+
+// setShardStatus updates the status of a tracked shard
+func (dsm *DynamicShardManager) setShardStatus(shardID, status string) {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	if shardInfo, exists := dsm.shards[shardID]; exists {
+		shardInfo.Status = status
+	}
+}
+
+// copyShardInfo returns a copy of a tracked shard's information
+func (dsm *DynamicShardManager) copyShardInfo(shardID string) *ShardInfo {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	shardInfo, exists := dsm.shards[shardID]
+	if !exists {
+		return nil
+	}
+	copied := *shardInfo
+	return &copied
+}
+
+// shardNumber extracts N from a "shard-N" ID, returning 0 if it has no number
+func shardNumber(shardID string) int {
+	num, err := strconv.Atoi(strings.TrimPrefix(shardID, "shard-"))
+	if err != nil {
+		return 0
+	}
+	return num
 }
 
 // provisionDockerShard creates a new Docker container for the shard
 func (dsm *DynamicShardManager) provisionDockerShard(shardInfo *ShardInfo) error {
-	containerName := fmt.Sprintf("%s-%s", dsm.config.ContainerPrefix, shardInfo.ID)
+	containerName := dsm.containerName(shardInfo.ID)
+
+	volumeArgs, err := dsm.volumeArgs(shardInfo.ID)
+	if err != nil {
+		return err
+	}
 
-	cmd := exec.Command("docker", "run", "-d",
+	password, rootPassword := dsm.credentials()
+	args := []string{"run", "-d",
 		"--name", containerName,
 		"--network", dsm.config.NetworkName,
+		"--label", dsm.ownerLabel(),
 		"-p", fmt.Sprintf("%d:3306", shardInfo.Port),
-		"-e", fmt.Sprintf("MYSQL_ROOT_PASSWORD=%s", dsm.config.DatabaseRootPassword),
+		"-e", fmt.Sprintf("MYSQL_ROOT_PASSWORD=%s", rootPassword),
 		"-e", fmt.Sprintf("MYSQL_DATABASE=%s", shardInfo.DatabaseName),
 		"-e", fmt.Sprintf("MYSQL_USER=%s", dsm.config.DatabaseUsername),
-		"-e", fmt.Sprintf("MYSQL_PASSWORD=%s", dsm.config.DatabasePassword),
-		dsm.config.DockerImage)
+		"-e", fmt.Sprintf("MYSQL_PASSWORD=%s", password)}
+	args = append(args, volumeArgs...)
+	args = append(args, dsm.config.DockerImage)
 
-	output, err := cmd.CombinedOutput()
+	output, err := dsm.dockerCommand(shardInfo.ID, args...).CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("docker run failed: %w, output: %s", err, string(output))
 	}
@@ -203,15 +525,20 @@ func (dsm *DynamicShardManager) provisionDockerShard(shardInfo *ShardInfo) error
 
 // waitForShardReady waits for the shard to be ready to accept connections
 func (dsm *DynamicShardManager) waitForShardReady(shardInfo *ShardInfo) error {
-	containerName := fmt.Sprintf("%s-%s", dsm.config.ContainerPrefix, shardInfo.ID)
+	containerName := dsm.containerName(shardInfo.ID)
 	maxAttempts := dsm.config.MaxConnectionAttempts
 
 	log.Printf("⏳ Waiting for shard %s to be ready...", shardInfo.ID)
 
+	deadline := dsm.readyDeadline()
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		cmd := exec.Command("docker", "exec", containerName,
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return fmt.Errorf("shard %s failed to become ready within %s", shardInfo.ID, dsm.config.ReadyTimeout)
+		}
+		password, _ := dsm.credentials()
+		cmd := dsm.dockerCommand(shardInfo.ID, "exec", containerName,
 			"mysqladmin", "ping", "-h", "localhost", "-u", dsm.config.DatabaseUsername,
-			fmt.Sprintf("-p%s", dsm.config.DatabasePassword))
+			fmt.Sprintf("-p%s", password))
 
 		if err := cmd.Run(); err == nil {
 			log.Printf("✅ Shard %s is ready after %d attempts", shardInfo.ID, attempt)
@@ -228,63 +555,55 @@ func (dsm *DynamicShardManager) waitForShardReady(shardInfo *ShardInfo) error {
 	return fmt.Errorf("shard %s failed to become ready within %d attempts", shardInfo.ID, maxAttempts)
 }
 
-// setupShardSchema creates tables and initial data for the new shard
+// setupShardSchema creates the tables of the new shard
 func (dsm *DynamicShardManager) setupShardSchema(shardInfo *ShardInfo) error {
-	containerName := fmt.Sprintf("%s-%s", dsm.config.ContainerPrefix, shardInfo.ID)
-	
 	// Create tables
-	createTablesSQL := fmt.Sprintf(`
-CREATE TABLE IF NOT EXISTS users (
+	createTablesSQL, err := dsm.shardSchema(shardInfo)
+	if err != nil {
+		return err
+	}
+
+	cmd, err := dsm.shardMySQLCommand(shardInfo)
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = strings.NewReader(createTablesSQL)
+	
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create tables: %w, output: %s", err, string(output))
+	}
+
+	log.Printf("📊 Schema setup complete for shard %s", shardInfo.ID)
+	return nil
+}
+
+// shardSchemaStatements returns the bundled DDL statements creating a shard's
+// tables, used when no existing shard can supply the schema
+func shardSchemaStatements(shardID string) []string {
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS users (
     user_id INT PRIMARY KEY,
     name VARCHAR(100),
     email VARCHAR(100),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     shard_info VARCHAR(50) DEFAULT '%s'
-);
-
-CREATE TABLE IF NOT EXISTS orders (
+)`, shardID),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS orders (
     order_id INT PRIMARY KEY,
     customer_id INT,
     product_name VARCHAR(100),
     amount DECIMAL(10,2),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     shard_info VARCHAR(50) DEFAULT '%s'
-);
-
-CREATE TABLE IF NOT EXISTS products (
+)`, shardID),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS products (
     product_id INT PRIMARY KEY,
     name VARCHAR(100),
     price DECIMAL(10,2),
     category VARCHAR(50),
     shard_info VARCHAR(50) DEFAULT '%s'
-);`, shardInfo.ID, shardInfo.ID, shardInfo.ID)
-
-	cmd := exec.Command("docker", "exec", "-i", containerName,
-		"mysql", "-u", dsm.config.DatabaseUsername,
-		fmt.Sprintf("-p%s", dsm.config.DatabasePassword), shardInfo.DatabaseName)
-	cmd.Stdin = strings.NewReader(createTablesSQL)
-	
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to create tables: %w, output: %s", err, string(output))
+)`, shardID),
 	}
-
-	// Insert some initial data
-	shardNum, _ := strconv.Atoi(shardInfo.ID[len("shard-"):])
-	baseID := shardNum * 1000
-
-	for i := 1; i <= 10; i++ {
-		userID := baseID + i
-		insertSQL := fmt.Sprintf("INSERT IGNORE INTO users (user_id, name, email) VALUES (%d, 'User %d', 'user%d@%s.com');", 
-			userID, userID, userID, shardInfo.ID)
-		
-		cmd := exec.Command("docker", "exec", containerName,
-			"mysql", "-u", dsm.config.DatabaseUsername,
-			fmt.Sprintf("-p%s", dsm.config.DatabasePassword), shardInfo.DatabaseName, "-e", insertSQL)
-		cmd.Run() // Ignore errors for INSERT IGNORE
-	}
-
-	log.Printf("📊 Schema and initial data setup complete for shard %s", shardInfo.ID)
-	return nil
 }
 
 // RemoveShard removes a shard from the ring (for future use)
@@ -302,6 +621,37 @@ func (dsm *DynamicShardManager) RemoveShard(shardID string) error {
 	return fmt.Errorf("shard %s not found", shardID)
 }
 
+// SetShardDSN points a shard at another server holding its data, after one
+// of its replicas was promoted
+func (dsm *DynamicShardManager) SetShardDSN(shardID, dsn string) error {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	shardInfo, exists := dsm.shards[shardID]
+	if !exists {
+		return fmt.Errorf("shard %s not found", shardID)
+	}
+	shardInfo.DSN = dsn
+	return nil
+}
+
+// UpdateCredentials updates the credentials used for newly provisioned shards
+func (dsm *DynamicShardManager) UpdateCredentials(password, rootPassword string) {
+	dsm.credentialsMutex.Lock()
+	defer dsm.credentialsMutex.Unlock()
+
+	dsm.config.DatabasePassword = password
+	dsm.config.DatabaseRootPassword = rootPassword
+}
+
+// credentials returns the current database password and root password
+func (dsm *DynamicShardManager) credentials() (string, string) {
+	dsm.credentialsMutex.RLock()
+	defer dsm.credentialsMutex.RUnlock()
+
+	return dsm.config.DatabasePassword, dsm.config.DatabaseRootPassword
+}
+
 // GetShardCount returns the current number of active shards
 func (dsm *DynamicShardManager) GetShardCount() int {
 	dsm.mutex.RLock()
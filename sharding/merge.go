@@ -0,0 +1,281 @@
+package sharding
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// mergeBatchSize is the number of rows inserted per statement when copying
+const mergeBatchSize = 500
+
+// MergeResult describes a completed shard consolidation
+type MergeResult struct {
+	Source      string           `json:"source"`
+	Destination string           `json:"destination"`
+	RowsCopied  map[string]int64 `json:"rows_copied"`
+	Duration    string           `json:"duration"`
+}
+
+// MergeShards consolidates the source shard into the destination: all rows are
+// copied and verified in a single transaction, the source's key space is
+// mapped to the destination and the source instance is decommissioned. The
+// source is read-only for the duration of the copy.
+func (dsm *DynamicShardManager) MergeShards(srcID, dstID string) (*MergeResult, error) {
+	if srcID == dstID {
+		return nil, fmt.Errorf("cannot merge shard %s into itself", srcID)
+	}
+
+	dsm.mutex.Lock()
+	src, srcExists := dsm.shards[srcID]
+	dst, dstExists := dsm.shards[dstID]
+	if !srcExists || !dstExists {
+		dsm.mutex.Unlock()
+		return nil, fmt.Errorf("both shards must exist (source %s: %t, destination %s: %t)", srcID, srcExists, dstID, dstExists)
+	}
+	if src.Status != "active" || dst.Status != "active" {
+		dsm.mutex.Unlock()
+		return nil, fmt.Errorf("both shards must be active (source: %s, destination: %s)", src.Status, dst.Status)
+	}
+	src.Status = "merging"
+	srcCopy, dstCopy := *src, *dst
+	dsm.mutex.Unlock()
+
+	start := time.Now()
+	log.Printf("🔀 Merging shard %s into %s", srcID, dstID)
+
+	// Block writes to the source while its rows are copied
+	restore, err := dsm.freezeShards([]string{srcID}, fmt.Sprintf("merging into %s", dstID))
+	if err != nil {
+		dsm.setShardStatus(srcID, "active")
+		return nil, err
+	}
+	defer restore()
+
+	rowsCopied, err := copyShardData(&srcCopy, &dstCopy)
+	if err != nil {
+		dsm.setShardStatus(srcID, "active")
+		return nil, fmt.Errorf("failed to merge shard %s into %s: %w", srcID, dstID, err)
+	}
+
+	// Keep the source in the ring so its key space resolves to the destination
+	dsm.mutex.Lock()
+	src.Status = "merged"
+	src.MergedInto = dstID
+	dsm.mutex.Unlock()
+
+	if err := dsm.provisioner.Remove(&srcCopy); err != nil {
+		log.Printf("Warning: Failed to decommission merged shard %s: %v", srcID, err)
+	}
+
+	log.Printf("✅ Merged shard %s into %s in %s", srcID, dstID, time.Since(start).Round(time.Millisecond))
+	return &MergeResult{
+		Source:      srcID,
+		Destination: dstID,
+		RowsCopied:  rowsCopied,
+		Duration:    time.Since(start).Round(time.Millisecond).String(),
+	}, nil
+}
+
+// RestoreMerge records a merge that completed before a restart so the merged
+// shard's key space keeps resolving to its destination
+func (dsm *DynamicShardManager) RestoreMerge(shardInfo ShardInfo) {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	if num := shardNumber(shardInfo.ID); num >= dsm.nextShardNum {
+		dsm.nextShardNum = num + 1
+	}
+
+	shardInfo.Status = "merged"
+	dsm.shards[shardInfo.ID] = &shardInfo
+	dsm.ring.Add(shardInfo.ID)
+}
+
+// resolveMerged follows merges from a ring member to the shard now owning its keys
+func (dsm *DynamicShardManager) resolveMerged(shardID string) string {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	for i := 0; i < len(dsm.shards); i++ {
+		shardInfo, exists := dsm.shards[shardID]
+		if !exists || shardInfo.Status != "merged" || shardInfo.MergedInto == "" {
+			break
+		}
+		shardID = shardInfo.MergedInto
+	}
+	return shardID
+}
+
+// copyShardData copies every table of the source shard into the destination
+// in one transaction, committing only if the row counts verify
+func copyShardData(src, dst *ShardInfo) (map[string]int64, error) {
+	srcDB, err := sql.Open("mysql", src.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to source: %w", err)
+	}
+	defer srcDB.Close()
+
+	dstDB, err := sql.Open("mysql", dst.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to destination: %w", err)
+	}
+	defer dstDB.Close()
+
+	tables, err := listTables(srcDB)
+	if err != nil {
+		return nil, err
+	}
+
+	// DDL commits implicitly, so missing tables are created before the copy starts
+	for _, table := range tables {
+		if err := ensureTable(srcDB, dstDB, table); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := dstDB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rowsCopied := make(map[string]int64, len(tables))
+	for _, table := range tables {
+		var before int64
+		if err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM `%s`", table)).Scan(&before); err != nil {
+			return nil, fmt.Errorf("failed to count rows in %s: %w", table, err)
+		}
+
+		copied, err := copyTable(srcDB, tx, table, "")
+		if err != nil {
+			return nil, err
+		}
+
+		var sourceCount, after int64
+		if err := srcDB.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM `%s`", table)).Scan(&sourceCount); err != nil {
+			return nil, fmt.Errorf("failed to count source rows in %s: %w", table, err)
+		}
+		if err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM `%s`", table)).Scan(&after); err != nil {
+			return nil, fmt.Errorf("failed to count rows in %s: %w", table, err)
+		}
+		if copied != sourceCount || after-before != sourceCount {
+			return nil, fmt.Errorf("verification failed for %s: source has %d rows, copied %d, destination gained %d",
+				table, sourceCount, copied, after-before)
+		}
+
+		rowsCopied[table] = copied
+		log.Printf("   Copied %d rows of %s", copied, table)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit merged rows: %w", err)
+	}
+	return rowsCopied, nil
+}
+
+// listTables returns the base tables of a shard's database
+func listTables(db *sql.DB) ([]string, error) {
+	rows, err := db.Query("SHOW FULL TABLES WHERE Table_type = 'BASE TABLE'")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name, tableType string
+		if err := rows.Scan(&name, &tableType); err != nil {
+			return nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// ensureTable creates a table on the destination using the source's definition
+// if it does not exist yet
+func ensureTable(srcDB, dstDB *sql.DB, table string) error {
+	var name, createSQL string
+	if err := srcDB.QueryRow(fmt.Sprintf("SHOW CREATE TABLE `%s`", table)).Scan(&name, &createSQL); err != nil {
+		return fmt.Errorf("failed to read definition of %s: %w", table, err)
+	}
+
+	createSQL = strings.Replace(createSQL, "CREATE TABLE", "CREATE TABLE IF NOT EXISTS", 1)
+	if _, err := dstDB.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create %s on destination: %w", table, err)
+	}
+	return nil
+}
+
+// copyTable streams the rows of a table matching where (all rows if empty)
+// from the source into the transaction using batched multi-row INSERTs,
+// returning the number of rows copied
+func copyTable(srcDB *sql.DB, tx *sql.Tx, table string, where string, args ...interface{}) (int64, error) {
+	query := fmt.Sprintf("SELECT * FROM `%s`", table)
+	if where != "" {
+		query += " WHERE " + where
+	}
+
+	rows, err := srcDB.Query(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = "`" + col + "`"
+	}
+	insertPrefix := fmt.Sprintf("INSERT INTO `%s` (%s) VALUES ", table, strings.Join(quoted, ", "))
+	rowPlaceholder := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+
+	var copied int64
+	var batch []interface{}
+	batchRows := 0
+
+	flush := func() error {
+		if batchRows == 0 {
+			return nil
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat(rowPlaceholder+", ", batchRows), ", ")
+		if _, err := tx.Exec(insertPrefix+placeholders, batch...); err != nil {
+			return fmt.Errorf("failed to insert into %s: %w", table, err)
+		}
+		copied += int64(batchRows)
+		batch = batch[:0]
+		batchRows = 0
+		return nil
+	}
+
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return copied, fmt.Errorf("failed to read row from %s: %w", table, err)
+		}
+
+		batch = append(batch, values...)
+		batchRows++
+		if batchRows == mergeBatchSize {
+			if err := flush(); err != nil {
+				return copied, err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return copied, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+
+	return copied, flush()
+}
@@ -0,0 +1,251 @@
+package sharding
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mirror statuses
+const (
+	MirrorProvisioning = "provisioning"
+	MirrorActive       = "active"
+)
+
+// mirrorServerIDBase offsets mirror server IDs from the shards', which use
+// the image default
+const mirrorServerIDBase = 1000
+
+// MirrorInfo describes a read mirror: a copy of a shard kept in sync by
+// MySQL replication that serves the shard's non-strong reads
+type MirrorInfo struct {
+	ID        string    `json:"id"`
+	ShardID   string    `json:"shard_id"`
+	Host      string    `json:"host"`
+	Port      int       `json:"port"`
+	DSN       string    `json:"dsn"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddMirror clones an active shard into a new read mirror: a container
+// started read-only, loaded from a consistent dump of the shard and then
+// replicating from it. It only applies to the docker provisioner.
+func (dsm *DynamicShardManager) AddMirror(shardID string) (*MirrorInfo, error) {
+	if dsm.provisioner.Name() != ProvisionerDocker {
+		return nil, fmt.Errorf("mirrors are only supported by the docker provisioner, not %s", dsm.provisioner.Name())
+	}
+	source := dsm.copyShardInfo(shardID)
+	if source == nil {
+		return nil, fmt.Errorf("shard %s not found", shardID)
+	}
+	if source.Status != "active" {
+		return nil, fmt.Errorf("shard %s is %s, not active", shardID, source.Status)
+	}
+
+	host := dsm.shardHost(shardID)
+	dsm.mutex.Lock()
+	num := dsm.nextMirrorNum
+	dsm.nextMirrorNum++
+	mirror := &MirrorInfo{
+		ID:        fmt.Sprintf("mirror-%d", num),
+		ShardID:   shardID,
+		Host:      host,
+		Port:      dsm.config.MirrorBasePort + num - 1,
+		Status:    MirrorProvisioning,
+		CreatedAt: time.Now(),
+	}
+	dsm.mirrors[mirror.ID] = mirror
+	dsm.mutex.Unlock()
+
+	log.Printf("🪞 Creating mirror %s of shard %s on port %d", mirror.ID, shardID, mirror.Port)
+	dsn, err := dsm.buildDSN(shardID, mirror.Host, mirror.Port, source.DatabaseName)
+	if err == nil {
+		err = dsm.startMirror(source, mirror, num)
+	}
+	if err != nil {
+		if _, removeErr := dsm.RemoveMirror(mirror.ID); removeErr != nil {
+			log.Printf("Warning: %v", removeErr)
+		}
+		return nil, fmt.Errorf("failed to create mirror of shard %s: %w", shardID, err)
+	}
+
+	dsm.mutex.Lock()
+	mirror.DSN = dsn
+	mirror.Status = MirrorActive
+	copied := *mirror
+	dsm.mutex.Unlock()
+
+	log.Printf("✅ Mirror %s of shard %s is replicating", mirror.ID, shardID)
+	return &copied, nil
+}
+
+// startMirror creates the mirror's container, loads it from the source and
+// starts replication
+func (dsm *DynamicShardManager) startMirror(source *ShardInfo, mirror *MirrorInfo, num int) error {
+	password, rootPassword := dsm.credentials()
+	args := []string{"run", "-d",
+		"--name", dsm.containerName(mirror.ID),
+		"--network", dsm.config.NetworkName,
+		"--label", dsm.ownerLabel(),
+		"-p", fmt.Sprintf("%d:3306", mirror.Port),
+		"-e", fmt.Sprintf("MYSQL_ROOT_PASSWORD=%s", rootPassword),
+		"-e", fmt.Sprintf("MYSQL_DATABASE=%s", source.DatabaseName),
+		"-e", fmt.Sprintf("MYSQL_USER=%s", dsm.config.DatabaseUsername),
+		"-e", fmt.Sprintf("MYSQL_PASSWORD=%s", password),
+		dsm.config.DockerImage,
+		fmt.Sprintf("--server-id=%d", mirrorServerIDBase+num),
+		"--read-only=ON"}
+	if output, err := dsm.dockerCommand(source.ID, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("docker run failed: %w, output: %s", err, string(output))
+	}
+
+	if err := dsm.waitForMirrorReady(mirror); err != nil {
+		return err
+	}
+
+	changeSource := fmt.Sprintf("CHANGE REPLICATION SOURCE TO SOURCE_HOST='%s', SOURCE_PORT=3306, SOURCE_USER='root', SOURCE_PASSWORD='%s', GET_SOURCE_PUBLIC_KEY=1",
+		dsm.containerName(source.ID), strings.ReplaceAll(rootPassword, "'", "''"))
+	if err := dsm.mirrorSQL(mirror, changeSource); err != nil {
+		return fmt.Errorf("failed to configure replication: %w", err)
+	}
+	if err := dsm.loadMirror(source, mirror); err != nil {
+		return err
+	}
+	if err := dsm.mirrorSQL(mirror, "START REPLICA"); err != nil {
+		return fmt.Errorf("failed to start replication: %w", err)
+	}
+	return nil
+}
+
+// waitForMirrorReady waits for the mirror's server to accept connections
+func (dsm *DynamicShardManager) waitForMirrorReady(mirror *MirrorInfo) error {
+	for attempt := 1; attempt <= dsm.config.MaxConnectionAttempts; attempt++ {
+		_, rootPassword := dsm.credentials()
+		cmd := dsm.dockerCommand(mirror.ShardID, "exec", dsm.containerName(mirror.ID),
+			"mysqladmin", "ping", "-h", "localhost", "-u", "root",
+			fmt.Sprintf("-p%s", rootPassword))
+		if cmd.Run() == nil {
+			return nil
+		}
+		time.Sleep(time.Duration(dsm.config.ConnectionRetryIntervalSeconds) * time.Second)
+	}
+	return fmt.Errorf("mirror %s not ready after %d attempts", mirror.ID, dsm.config.MaxConnectionAttempts)
+}
+
+// loadMirror pipes a consistent dump of the source, recording its binary log
+// position, into the mirror. Root is used on both ends since reading and
+// setting the replication position needs privileges the shard user lacks.
+func (dsm *DynamicShardManager) loadMirror(source *ShardInfo, mirror *MirrorInfo) error {
+	_, rootPassword := dsm.credentials()
+	passwordFlag := fmt.Sprintf("-p%s", rootPassword)
+	dump := dsm.dockerCommand(source.ID, "exec", dsm.containerName(source.ID),
+		"mysqldump", "-u", "root", passwordFlag,
+		"--single-transaction", "--source-data=1", "--triggers", "--routines",
+		"--no-tablespaces", "--databases", source.DatabaseName)
+	load := dsm.dockerCommand(mirror.ShardID, "exec", "-i", dsm.containerName(mirror.ID),
+		"mysql", "-u", "root", passwordFlag)
+
+	var dumpErr, loadErr strings.Builder
+	dump.Stderr = &dumpErr
+	load.Stderr = &loadErr
+	stdout, err := dump.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to set up mysqldump: %w", err)
+	}
+	load.Stdin = stdout
+
+	if err := dump.Start(); err != nil {
+		return fmt.Errorf("failed to start mysqldump: %w", err)
+	}
+	if err := load.Run(); err != nil {
+		dump.Process.Kill()
+		dump.Wait()
+		return fmt.Errorf("loading mirror failed: %w, output: %s", err, strings.TrimSpace(loadErr.String()))
+	}
+	if err := dump.Wait(); err != nil {
+		return fmt.Errorf("mysqldump failed: %w, output: %s", err, strings.TrimSpace(dumpErr.String()))
+	}
+	return nil
+}
+
+// mirrorSQL runs a statement on the mirror as root
+func (dsm *DynamicShardManager) mirrorSQL(mirror *MirrorInfo, statement string) error {
+	_, rootPassword := dsm.credentials()
+	output, err := dsm.dockerCommand(mirror.ShardID, "exec", dsm.containerName(mirror.ID),
+		"mysql", "-u", "root", fmt.Sprintf("-p%s", rootPassword),
+		"-e", statement).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// RemoveMirror removes a mirror's container and data and forgets it,
+// returning it
+func (dsm *DynamicShardManager) RemoveMirror(mirrorID string) (*MirrorInfo, error) {
+	dsm.mutex.RLock()
+	mirror, exists := dsm.mirrors[mirrorID]
+	dsm.mutex.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("mirror %s not found", mirrorID)
+	}
+
+	output, err := dsm.dockerCommand(mirror.ShardID, "rm", "-f", "-v", dsm.containerName(mirrorID)).CombinedOutput()
+	if err != nil && !strings.Contains(string(output), "No such container") {
+		return nil, fmt.Errorf("failed to remove mirror %s: %w, output: %s", mirrorID, err, strings.TrimSpace(string(output)))
+	}
+
+	dsm.mutex.Lock()
+	delete(dsm.mirrors, mirrorID)
+	copied := *mirror
+	dsm.mutex.Unlock()
+
+	log.Printf("🗑️  Removed mirror %s of shard %s", mirrorID, mirror.ShardID)
+	return &copied, nil
+}
+
+// Mirrors returns the mirrors of a shard, or of every shard when shardID is
+// empty, ordered by creation
+func (dsm *DynamicShardManager) Mirrors(shardID string) []MirrorInfo {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	var mirrors []MirrorInfo
+	for _, mirror := range dsm.mirrors {
+		if shardID == "" || mirror.ShardID == shardID {
+			mirrors = append(mirrors, *mirror)
+		}
+	}
+	sort.Slice(mirrors, func(i, j int) bool {
+		return mirrors[i].CreatedAt.Before(mirrors[j].CreatedAt)
+	})
+	return mirrors
+}
+
+// RestoreMirrors tracks mirrors created by a previous run again, so that new
+// mirrors don't reuse their names or ports
+func (dsm *DynamicShardManager) RestoreMirrors(mirrors []MirrorInfo) {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	for _, mirror := range mirrors {
+		restored := mirror
+		dsm.mirrors[mirror.ID] = &restored
+		if num := mirrorNumber(mirror.ID); num >= dsm.nextMirrorNum {
+			dsm.nextMirrorNum = num + 1
+		}
+	}
+}
+
+// mirrorNumber extracts the number of a mirror ID, or 0 if it has none
+func mirrorNumber(mirrorID string) int {
+	num, err := strconv.Atoi(strings.TrimPrefix(mirrorID, "mirror-"))
+	if err != nil {
+		return 0
+	}
+	return num
+}
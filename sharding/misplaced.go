@@ -0,0 +1,283 @@
+package sharding
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// misplacedSampleKeys is the number of keys listed per group of misplaced rows
+const misplacedSampleKeys = 10
+
+// MisplacedOptions controls an audit of rows living on the wrong shard
+type MisplacedOptions struct {
+	// ShardKeys maps each sharded table of the default database to its key
+	// column; other tables aren't audited
+	ShardKeys map[string]string
+	// Shards restricts the audit; empty audits every active shard
+	Shards []string
+	// Relocate moves misplaced rows to the shard routing sends their key to
+	Relocate bool
+	// BatchSize is the number of keys relocated per copy and DELETE
+	BatchSize int
+	// MaxRowsPerSecond caps the relocation rate; zero is unthrottled
+	MaxRowsPerSecond int
+}
+
+// MisplacedRows are the rows of a table on a shard that routing sends to
+// another shard, the owner
+type MisplacedRows struct {
+	Shard string `json:"shard"`
+	Table string `json:"table"`
+	Owner string `json:"owner"`
+	Keys  int64  `json:"keys"`
+	Rows  int64  `json:"rows"`
+	// SampleKeys lists some of the misplaced keys
+	SampleKeys    []string `json:"sample_keys"`
+	RowsRelocated int64    `json:"rows_relocated,omitempty"`
+	Error         string   `json:"error,omitempty"`
+
+	keys []interface{}
+}
+
+// MisplacedAudit reports the progress and findings of a misplaced rows audit
+type MisplacedAudit struct {
+	Relocate      bool            `json:"relocate"`
+	Shard         string          `json:"shard,omitempty"`
+	Table         string          `json:"table,omitempty"`
+	ShardsTotal   int             `json:"shards_total"`
+	ShardsDone    int             `json:"shards_done"`
+	KeysChecked   int64           `json:"keys_checked"`
+	RowsChecked   int64           `json:"rows_checked"`
+	MisplacedRows int64           `json:"misplaced_rows"`
+	RowsRelocated int64           `json:"rows_relocated"`
+	Misplaced     []MisplacedRows `json:"misplaced"`
+	// Errors lists shards and tables that couldn't be audited
+	Errors     []string   `json:"errors,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// AuditMisplacedRows scans every sharded table of each active shard, looks up
+// the shard routing sends each key to, and reports the rows living anywhere
+// else, such as rows loaded before the autoscaler managed the data or left
+// behind by a failed move. With Relocate, each shard's misplaced rows are
+// then copied to their owner and deleted, in throttled batches of keys.
+func (dsm *DynamicShardManager) AuditMisplacedRows(opts MisplacedOptions) (*MisplacedAudit, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	shardIDs := opts.Shards
+	if len(shardIDs) == 0 {
+		shardIDs = dsm.GetAllShards()
+		sort.Strings(shardIDs)
+	}
+
+	dsm.misplacedMutex.Lock()
+	if dsm.misplaced != nil && dsm.misplaced.FinishedAt == nil {
+		dsm.misplacedMutex.Unlock()
+		return nil, fmt.Errorf("an audit of misplaced rows is already running")
+	}
+	// Rows being moved by a split are expected on both shards
+	for _, split := range dsm.Splits() {
+		if split.FinishedAt == nil {
+			dsm.misplacedMutex.Unlock()
+			return nil, fmt.Errorf("shard %s is being split; audit once it completes", split.Source)
+		}
+	}
+	audit := &MisplacedAudit{
+		Relocate:    opts.Relocate,
+		ShardsTotal: len(shardIDs),
+		Misplaced:   []MisplacedRows{},
+		StartedAt:   time.Now(),
+	}
+	dsm.misplaced = audit
+	dsm.misplacedMutex.Unlock()
+
+	log.Printf("🔍 Auditing %d shards for misplaced rows (relocate: %t)", len(shardIDs), opts.Relocate)
+	for _, shardID := range shardIDs {
+		dsm.updateMisplaced(func(a *MisplacedAudit) { a.Shard = shardID })
+		if err := dsm.auditShard(shardID, opts); err != nil {
+			log.Printf("Warning: Failed to audit shard %s for misplaced rows: %v", shardID, err)
+			dsm.updateMisplaced(func(a *MisplacedAudit) { a.Errors = append(a.Errors, fmt.Sprintf("%s: %v", shardID, err)) })
+		}
+		dsm.updateMisplaced(func(a *MisplacedAudit) { a.ShardsDone++ })
+	}
+
+	dsm.updateMisplaced(func(a *MisplacedAudit) {
+		now := time.Now()
+		a.FinishedAt = &now
+		a.Shard, a.Table = "", ""
+	})
+	result := dsm.MisplacedStatus()
+	log.Printf("✅ Misplaced rows audit found %d rows on the wrong shard, relocated %d", result.MisplacedRows, result.RowsRelocated)
+	return result, nil
+}
+
+// auditShard finds the misplaced rows of every sharded table of a shard,
+// relocating them if asked to
+func (dsm *DynamicShardManager) auditShard(shardID string, opts MisplacedOptions) error {
+	shardInfo := dsm.copyShardInfo(shardID)
+	if shardInfo == nil || shardInfo.Status != "active" {
+		return fmt.Errorf("shard is not active")
+	}
+	db, err := sql.Open("mysql", shardInfo.DSN)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer db.Close()
+
+	tables := make([]string, 0, len(opts.ShardKeys))
+	for table := range opts.ShardKeys {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	for _, table := range tables {
+		dsm.updateMisplaced(func(a *MisplacedAudit) { a.Table = table })
+		groups, err := dsm.misplacedKeys(db, shardID, table, opts.ShardKeys[table])
+		if err != nil {
+			dsm.updateMisplaced(func(a *MisplacedAudit) {
+				a.Errors = append(a.Errors, fmt.Sprintf("%s.%s: %v", shardID, table, err))
+			})
+			continue
+		}
+		for _, group := range groups {
+			if opts.Relocate {
+				dsm.relocateRows(db, group, opts)
+			}
+			group.keys = nil
+			dsm.updateMisplaced(func(a *MisplacedAudit) { a.Misplaced = append(a.Misplaced, *group) })
+		}
+	}
+	return nil
+}
+
+// misplacedKeys groups the keys of a table on a shard that routing sends
+// elsewhere by the shard they belong on
+func (dsm *DynamicShardManager) misplacedKeys(db *sql.DB, shardID, table, keyColumn string) ([]*MisplacedRows, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT %s, COUNT(*) FROM %s WHERE %s IS NOT NULL GROUP BY %s",
+		quoteIdentifier(keyColumn), quoteIdentifier(table), quoteIdentifier(keyColumn), quoteIdentifier(keyColumn)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys: %w", err)
+	}
+	defer rows.Close()
+
+	byOwner := make(map[string]*MisplacedRows)
+	var keysChecked, rowsChecked, misplacedRows int64
+	for rows.Next() {
+		var key string
+		var count int64
+		if err := rows.Scan(&key, &count); err != nil {
+			return nil, fmt.Errorf("failed to read keys: %w", err)
+		}
+		keysChecked++
+		rowsChecked += count
+
+		owner, err := dsm.GetShardForTable(table, key)
+		if err != nil || owner == shardID {
+			continue
+		}
+		group, exists := byOwner[owner]
+		if !exists {
+			group = &MisplacedRows{Shard: shardID, Table: table, Owner: owner, SampleKeys: []string{}}
+			byOwner[owner] = group
+		}
+		group.Keys++
+		group.Rows += count
+		group.keys = append(group.keys, key)
+		if len(group.SampleKeys) < misplacedSampleKeys {
+			group.SampleKeys = append(group.SampleKeys, key)
+		}
+		misplacedRows += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read keys: %w", err)
+	}
+
+	dsm.updateMisplaced(func(a *MisplacedAudit) {
+		a.KeysChecked += keysChecked
+		a.RowsChecked += rowsChecked
+		a.MisplacedRows += misplacedRows
+	})
+	groups := make([]*MisplacedRows, 0, len(byOwner))
+	for _, group := range byOwner {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Owner < groups[j].Owner })
+	if len(groups) > 0 {
+		log.Printf("🔍 Shard %s holds %d rows of %s that belong on other shards", shardID, misplacedRows, table)
+	}
+	return groups, nil
+}
+
+// relocateRows copies a group's misplaced rows to their owner and deletes
+// them from the shard, a batch of keys at a time. Keys are only deleted once
+// the owner committed their rows; a batch the owner rejects, e.g. because it
+// already holds rows with the same primary key, stops the group's relocation.
+func (dsm *DynamicShardManager) relocateRows(db *sql.DB, group *MisplacedRows, opts MisplacedOptions) {
+	owner := dsm.copyShardInfo(group.Owner)
+	if owner == nil || owner.Status != "active" {
+		group.Error = fmt.Sprintf("owner %s is not active", group.Owner)
+		return
+	}
+	ownerDB, err := sql.Open("mysql", owner.DSN)
+	if err != nil {
+		group.Error = fmt.Sprintf("failed to connect to %s: %v", group.Owner, err)
+		return
+	}
+	defer ownerDB.Close()
+
+	keyColumn := opts.ShardKeys[group.Table]
+	for start := 0; start < len(group.keys); start += opts.BatchSize {
+		batch := group.keys[start:min(start+opts.BatchSize, len(group.keys))]
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(batch)), ", ")
+		where := fmt.Sprintf("%s IN (%s)", quoteIdentifier(keyColumn), placeholders)
+
+		copied, err := copyMatchingRows(db, ownerDB, group.Table, where, batch)
+		if err != nil {
+			group.Error = fmt.Sprintf("failed to copy rows to %s: %v", group.Owner, err)
+			return
+		}
+		deleted, err := deleteMatchingRows(db, group.Table, where, batch)
+		if err != nil {
+			group.Error = fmt.Sprintf("rows were copied to %s but could not be deleted: %v", group.Owner, err)
+			return
+		}
+		if deleted != copied {
+			log.Printf("Warning: Deleted %d misplaced %s rows from %s but copied %d", deleted, group.Table, group.Shard, copied)
+		}
+		group.RowsRelocated += copied
+		dsm.updateMisplaced(func(a *MisplacedAudit) { a.RowsRelocated += copied })
+
+		if opts.MaxRowsPerSecond > 0 {
+			time.Sleep(time.Duration(float64(copied) / float64(opts.MaxRowsPerSecond) * float64(time.Second)))
+		}
+	}
+	log.Printf("🚚 Relocated %d misplaced %s rows from %s to %s", group.RowsRelocated, group.Table, group.Shard, group.Owner)
+}
+
+// MisplacedStatus returns the latest misplaced rows audit, or nil
+func (dsm *DynamicShardManager) MisplacedStatus() *MisplacedAudit {
+	dsm.misplacedMutex.Lock()
+	defer dsm.misplacedMutex.Unlock()
+
+	if dsm.misplaced == nil {
+		return nil
+	}
+	audit := *dsm.misplaced
+	audit.Misplaced = append([]MisplacedRows{}, dsm.misplaced.Misplaced...)
+	audit.Errors = append([]string(nil), dsm.misplaced.Errors...)
+	return &audit
+}
+
+// updateMisplaced changes the running audit under the misplaced mutex
+func (dsm *DynamicShardManager) updateMisplaced(update func(*MisplacedAudit)) {
+	dsm.misplacedMutex.Lock()
+	defer dsm.misplacedMutex.Unlock()
+	update(dsm.misplaced)
+}
@@ -0,0 +1,215 @@
+package sharding
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// MoveResult describes rows moved by MoveKeys
+type MoveResult struct {
+	Entry     DirectoryEntry   `json:"entry"`
+	RowsMoved map[string]int64 `json:"rows_moved"`
+	Duration  string           `json:"duration"`
+}
+
+// MoveKeys moves the rows covered by a directory entry to the entry's shard:
+// rows are copied and verified, the override is installed so routing follows
+// the rows, and the rows are then deleted from their previous shards. Source
+// shards are read-only while the move runs.
+func (dsm *DynamicShardManager) MoveKeys(entry DirectoryEntry, keyColumn string) (*MoveResult, error) {
+	if err := entry.Validate(); err != nil {
+		return nil, err
+	}
+
+	dst, exists := dsm.GetShardInfo(entry.ShardID)
+	if !exists || dst.Status != "active" {
+		return nil, fmt.Errorf("destination shard %s is not active", entry.ShardID)
+	}
+	dstCopy := *dst
+
+	// A single key lives on its current owner; a range can be spread across
+	// every shard by the hash ring
+	var sources []string
+	if entry.IsRange() {
+		if err := dsm.checkRangeMove(entry); err != nil {
+			return nil, err
+		}
+		for _, shardID := range dsm.GetAllShards() {
+			if shardID != entry.ShardID {
+				sources = append(sources, shardID)
+			}
+		}
+	} else {
+		owner, err := dsm.GetShardForTable(entry.Table, entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		if owner == entry.ShardID {
+			return nil, fmt.Errorf("key %s of %s is already routed to %s", entry.Key, entry.Table, owner)
+		}
+		sources = []string{owner}
+	}
+
+	where, args := fmt.Sprintf("`%s` = ?", keyColumn), []interface{}{entry.Key}
+	if entry.IsRange() {
+		where, args = fmt.Sprintf("`%s` BETWEEN ? AND ?", keyColumn), []interface{}{*entry.RangeStart, *entry.RangeEnd}
+	}
+
+	start := time.Now()
+	log.Printf("🚚 Moving %s rows (%s) from %v to %s", entry.Table, where, sources, entry.ShardID)
+
+	restore, err := dsm.freezeShards(sources, fmt.Sprintf("moving %s keys to %s", entry.Table, entry.ShardID))
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+
+	dstDB, err := sql.Open("mysql", dstCopy.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to destination: %w", err)
+	}
+	defer dstDB.Close()
+
+	rowsMoved := make(map[string]int64)
+	sourceDBs := make(map[string]*sql.DB)
+	defer func() {
+		for _, db := range sourceDBs {
+			db.Close()
+		}
+	}()
+
+	for _, sourceID := range sources {
+		source, exists := dsm.GetShardInfo(sourceID)
+		if !exists {
+			continue
+		}
+		srcDB, err := sql.Open("mysql", source.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to shard %s: %w", sourceID, err)
+		}
+		sourceDBs[sourceID] = srcDB
+
+		// DDL commits implicitly, so the table is created before the copy starts
+		if err := ensureTable(srcDB, dstDB, entry.Table); err != nil {
+			return nil, err
+		}
+		copied, err := copyMatchingRows(srcDB, dstDB, entry.Table, where, args)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy rows from %s: %w", sourceID, err)
+		}
+		rowsMoved[sourceID] = copied
+	}
+
+	// Routing now follows the copied rows, including keys of the range that
+	// had their own overrides
+	if entry.IsRange() {
+		dropped, err := dsm.setMovedRange(entry)
+		if err != nil {
+			return nil, fmt.Errorf("rows were copied but the override could not be installed: %w", err)
+		}
+		for _, existing := range dropped {
+			log.Printf("Dropped the override routing %s %s to %s, now covered by the moved range", existing.Table, existing.describe(), existing.ShardID)
+		}
+	} else if err := dsm.SetDirectoryEntry(entry); err != nil {
+		return nil, fmt.Errorf("rows were copied but the override could not be installed: %w", err)
+	}
+
+	for sourceID, srcDB := range sourceDBs {
+		deleted, err := deleteMatchingRows(srcDB, entry.Table, where, args)
+		if err != nil {
+			return nil, fmt.Errorf("override installed but rows could not be deleted from %s: %w", sourceID, err)
+		}
+		if deleted != rowsMoved[sourceID] {
+			log.Printf("Warning: Deleted %d rows from %s but copied %d", deleted, sourceID, rowsMoved[sourceID])
+		}
+	}
+
+	log.Printf("✅ Moved %s rows to %s in %s", entry.Table, entry.ShardID, time.Since(start).Round(time.Millisecond))
+	return &MoveResult{
+		Entry:     entry,
+		RowsMoved: rowsMoved,
+		Duration:  time.Since(start).Round(time.Millisecond).String(),
+	}, nil
+}
+
+// freezeShards puts shards into read-only maintenance and returns a function
+// restoring their previous maintenance windows
+func (dsm *DynamicShardManager) freezeShards(shardIDs []string, reason string) (func(), error) {
+	previous := make(map[string]*MaintenanceWindow)
+	restore := func() {
+		for _, shardID := range shardIDs {
+			if window, had := previous[shardID]; had {
+				dsm.SetMaintenance(*window)
+			} else {
+				dsm.ClearMaintenance(shardID)
+			}
+		}
+	}
+
+	now := time.Now()
+	for i, shardID := range shardIDs {
+		if window, had := dsm.GetMaintenance(shardID); had {
+			previous[shardID] = window
+		}
+		if err := dsm.SetMaintenance(MaintenanceWindow{
+			ShardID: shardID,
+			Start:   now,
+			End:     now.Add(24 * time.Hour),
+			Mode:    MaintenanceReadOnly,
+			Reason:  reason,
+		}); err != nil {
+			shardIDs = shardIDs[:i]
+			restore()
+			return nil, fmt.Errorf("failed to make shard %s read-only: %w", shardID, err)
+		}
+	}
+	return restore, nil
+}
+
+// copyMatchingRows copies the rows matching where from the source into the
+// destination in one transaction, verifying the destination gained them all
+func copyMatchingRows(srcDB, dstDB *sql.DB, table, where string, args []interface{}) (int64, error) {
+	tx, err := dstDB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM `%s` WHERE %s", table, where)
+	var before, after, sourceCount int64
+	if err := tx.QueryRow(countSQL, args...).Scan(&before); err != nil {
+		return 0, fmt.Errorf("failed to count destination rows: %w", err)
+	}
+
+	copied, err := copyTable(srcDB, tx, table, where, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := srcDB.QueryRow(countSQL, args...).Scan(&sourceCount); err != nil {
+		return 0, fmt.Errorf("failed to count source rows: %w", err)
+	}
+	if err := tx.QueryRow(countSQL, args...).Scan(&after); err != nil {
+		return 0, fmt.Errorf("failed to count destination rows: %w", err)
+	}
+	if copied != sourceCount || after-before != sourceCount {
+		return 0, fmt.Errorf("verification failed: source has %d rows, copied %d, destination gained %d",
+			sourceCount, copied, after-before)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit copied rows: %w", err)
+	}
+	return copied, nil
+}
+
+// deleteMatchingRows deletes the rows matching where, returning how many were removed
+func deleteMatchingRows(db *sql.DB, table, where string, args []interface{}) (int64, error) {
+	result, err := db.Exec(fmt.Sprintf("DELETE FROM `%s` WHERE %s", table, where), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,144 @@
+package sharding
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// OwnerLabel is the Docker label marking containers, volumes and networks
+// created by the autoscaler; its value is the container prefix, so clusters
+// sharing a daemon only clean up their own resources
+const OwnerLabel = "sql-autoscaler.owner"
+
+// DockerResource is a container, volume or network on a Docker daemon
+type DockerResource struct {
+	// Host is the daemon's DOCKER_HOST, empty for the local daemon
+	Host string `json:"host,omitempty"`
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// ownerLabel returns the label assignment marking a resource as owned
+func (dsm *DynamicShardManager) ownerLabel() string {
+	return OwnerLabel + "=" + dsm.config.ContainerPrefix
+}
+
+// dockerHosts returns the daemons shards may run on: the Docker host of
+// each zone, and "" for the local daemon used by shards outside zones or in
+// zones without a Docker host
+func (dsm *DynamicShardManager) dockerHosts() []string {
+	var hosts []string
+	useLocal := len(dsm.config.Zones) == 0
+	for _, zone := range dsm.config.Zones {
+		if zone.DockerHost != "" {
+			hosts = append(hosts, zone.DockerHost)
+		} else {
+			useLocal = true
+		}
+	}
+	if useLocal {
+		hosts = append(hosts, "")
+	}
+	return hosts
+}
+
+// hostCommand builds a docker command against a daemon, the local one when
+// host is empty
+func hostCommand(host string, args ...string) *exec.Cmd {
+	cmd := exec.Command("docker", args...)
+	if host != "" {
+		cmd.Env = append(os.Environ(), "DOCKER_HOST="+host)
+	}
+	return cmd
+}
+
+// EnsureNetwork creates the shard network on every daemon where it is
+// missing, labelled as owned. An existing network is used as is. It only
+// applies to the docker provisioner.
+func (dsm *DynamicShardManager) EnsureNetwork() error {
+	if dsm.provisioner.Name() != ProvisionerDocker {
+		return nil
+	}
+
+	name := dsm.config.NetworkName
+	for _, host := range dsm.dockerHosts() {
+		if hostCommand(host, "network", "inspect", name).Run() == nil {
+			continue
+		}
+
+		args := []string{"network", "create", "--label", dsm.ownerLabel()}
+		if dsm.config.NetworkDriver != "" {
+			args = append(args, "--driver", dsm.config.NetworkDriver)
+		}
+		if dsm.config.NetworkSubnet != "" {
+			args = append(args, "--subnet", dsm.config.NetworkSubnet)
+		}
+		args = append(args, name)
+
+		if output, err := hostCommand(host, args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("docker network create failed: %w, output: %s", err, strings.TrimSpace(string(output)))
+		}
+		log.Printf("🌐 Created Docker network %s on %s", name, daemonName(host))
+	}
+	return nil
+}
+
+// Cleanup removes the containers, volumes and networks the autoscaler owns
+// on every daemon, returning them. With dryRun set it only lists them;
+// keepVolumes leaves the shards' data in place. Bind mounts, and resources
+// created before ownership was recorded, are left alone.
+func (dsm *DynamicShardManager) Cleanup(dryRun, keepVolumes bool) ([]DockerResource, error) {
+	if dsm.provisioner.Name() != ProvisionerDocker {
+		return nil, fmt.Errorf("cleanup only applies to the docker provisioner, not %s", dsm.provisioner.Name())
+	}
+
+	// Containers go first, as volumes and networks in use can't be removed
+	kinds := []struct {
+		kind   string
+		list   []string
+		format string
+		remove []string
+	}{
+		{"container", []string{"ps", "-a"}, "{{.Names}}", []string{"rm", "-f"}},
+		{"volume", []string{"volume", "ls"}, "{{.Name}}", []string{"volume", "rm"}},
+		{"network", []string{"network", "ls"}, "{{.Name}}", []string{"network", "rm"}},
+	}
+
+	var removed []DockerResource
+	for _, host := range dsm.dockerHosts() {
+		for _, k := range kinds {
+			if k.kind == "volume" && keepVolumes {
+				continue
+			}
+
+			listArgs := append(append([]string{}, k.list...), "--filter", "label="+dsm.ownerLabel(), "--format", k.format)
+			output, err := hostCommand(host, listArgs...).CombinedOutput()
+			if err != nil {
+				return removed, fmt.Errorf("listing %ss on %s failed: %w, output: %s", k.kind, daemonName(host), err, strings.TrimSpace(string(output)))
+			}
+
+			for _, name := range strings.Fields(string(output)) {
+				if !dryRun {
+					args := append(append([]string{}, k.remove...), name)
+					if output, err := hostCommand(host, args...).CombinedOutput(); err != nil {
+						return removed, fmt.Errorf("removing %s %s on %s failed: %w, output: %s", k.kind, name, daemonName(host), err, strings.TrimSpace(string(output)))
+					}
+					log.Printf("🗑️  Removed %s %s on %s", k.kind, name, daemonName(host))
+				}
+				removed = append(removed, DockerResource{Host: host, Kind: k.kind, Name: name})
+			}
+		}
+	}
+	return removed, nil
+}
+
+// daemonName describes a Docker host in logs and errors
+func daemonName(host string) string {
+	if host == "" {
+		return "the local daemon"
+	}
+	return host
+}
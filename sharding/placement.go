@@ -0,0 +1,123 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ZoneLabel is the shard label mirroring a shard's zone, so zones can be used
+// in label selectors
+const ZoneLabel = "zone"
+
+// Zone is a failure domain shards are spread across. For the docker
+// provisioner a zone is a Docker host; for managed databases it is the
+// provider's availability zone.
+type Zone struct {
+	Name string
+	// DockerHost is the DOCKER_HOST of the zone's daemon (e.g.
+	// "ssh://user@host-b"); empty uses the local daemon
+	DockerHost string
+	// Host is the address at which the zone's published ports are reachable
+	Host string
+	// Region is the region the zone belongs to, given to shards placed in it
+	Region string
+}
+
+// placeShard picks the zone for a new shard: the requested zone if set,
+// otherwise the zone currently holding the fewest live shards. Callers must
+// hold dsm.mutex.
+func (dsm *DynamicShardManager) placeShard(requested string) string {
+	zones := dsm.config.Zones
+	if requested != "" || len(zones) == 0 {
+		return requested
+	}
+
+	counts := make(map[string]int, len(zones))
+	for _, shardInfo := range dsm.shards {
+		switch shardInfo.Status {
+		case "failed", "removed", "merged":
+			continue
+		}
+		counts[shardInfo.Zone]++
+	}
+
+	best := zones[0].Name
+	for _, zone := range zones[1:] {
+		if counts[zone.Name] < counts[best] {
+			best = zone.Name
+		}
+	}
+	return best
+}
+
+// zoneFor returns the configuration of a shard's zone, if it has one
+func (dsm *DynamicShardManager) zoneFor(shardID string) (Zone, bool) {
+	dsm.mutex.RLock()
+	zoneName := ""
+	if shardInfo, exists := dsm.shards[shardID]; exists {
+		zoneName = shardInfo.Zone
+	}
+	dsm.mutex.RUnlock()
+
+	for _, zone := range dsm.config.Zones {
+		if zone.Name == zoneName && zoneName != "" {
+			return zone, true
+		}
+	}
+	return Zone{}, false
+}
+
+// hasZone reports whether a zone is configured
+func (dsm *DynamicShardManager) hasZone(name string) bool {
+	for _, zone := range dsm.config.Zones {
+		if zone.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// dockerCommand builds a docker command against the daemon of the shard's zone
+func (dsm *DynamicShardManager) dockerCommand(shardID string, args ...string) *exec.Cmd {
+	return dsm.dockerCommandContext(context.Background(), shardID, args...)
+}
+
+// dockerCommandContext builds a docker command against the daemon of the
+// shard's zone, killed when ctx is done
+func (dsm *DynamicShardManager) dockerCommandContext(ctx context.Context, shardID string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	if zone, ok := dsm.zoneFor(shardID); ok && zone.DockerHost != "" {
+		cmd.Env = append(os.Environ(), "DOCKER_HOST="+zone.DockerHost)
+	}
+	return cmd
+}
+
+// shardHost returns the address at which a docker shard's published port is reachable
+func (dsm *DynamicShardManager) shardHost(shardID string) string {
+	if zone, ok := dsm.zoneFor(shardID); ok && zone.Host != "" {
+		return zone.Host
+	}
+	return "127.0.0.1"
+}
+
+// CheckDocker verifies that the Docker daemon of every zone answers. It only
+// applies to the docker provisioner and returns nil for the others.
+func (dsm *DynamicShardManager) CheckDocker() error {
+	if dsm.provisioner.Name() != ProvisionerDocker {
+		return nil
+	}
+
+	for _, host := range dsm.dockerHosts() {
+		cmd := hostCommand(host, "info", "--format", "{{.ServerVersion}}")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			if host == "" {
+				host = "local daemon"
+			}
+			return fmt.Errorf("docker %s unavailable: %w, output: %s", host, err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package sharding
+
+import (
+	"fmt"
+)
+
+// Provisioner creates and manages the database instances backing shards
+type Provisioner interface {
+	// Name identifies the provisioner in logs and configuration
+	Name() string
+	// Provision creates the instance and fills in the shard's Host, Port and DSN
+	Provision(shardInfo *ShardInfo) error
+	// WaitReady blocks until the instance accepts connections
+	WaitReady(shardInfo *ShardInfo) error
+	// SetupSchema creates the tables on the instance
+	SetupSchema(shardInfo *ShardInfo) error
+	// Exists reports whether the backing instance still exists
+	Exists(shardInfo *ShardInfo) bool
+	// Remove deletes the backing instance and its data
+	Remove(shardInfo *ShardInfo) error
+}
+
+// dockerProvisioner provisions shards as local MySQL containers
+type dockerProvisioner struct {
+	dsm *DynamicShardManager
+}
+
+// Name implements Provisioner
+func (dp *dockerProvisioner) Name() string {
+	return ProvisionerDocker
+}
+
+// Provision starts a MySQL container publishing the shard's port on localhost
+func (dp *dockerProvisioner) Provision(shardInfo *ShardInfo) error {
+	shardInfo.Host = dp.dsm.shardHost(shardInfo.ID)
+	shardInfo.Port = dp.dsm.config.BasePort + shardNumber(shardInfo.ID) - 1
+
+	dsn, err := dp.dsm.buildDSN(shardInfo.ID, shardInfo.Host, shardInfo.Port, shardInfo.DatabaseName)
+	if err != nil {
+		return fmt.Errorf("failed to build DSN: %w", err)
+	}
+	shardInfo.DSN = dsn
+
+	return dp.dsm.provisionDockerShard(shardInfo)
+}
+
+// WaitReady implements Provisioner
+func (dp *dockerProvisioner) WaitReady(shardInfo *ShardInfo) error {
+	return dp.dsm.waitForShardReady(shardInfo)
+}
+
+// SetupSchema implements Provisioner
+func (dp *dockerProvisioner) SetupSchema(shardInfo *ShardInfo) error {
+	return dp.dsm.setupShardSchema(shardInfo)
+}
+
+// Exists reports whether the Docker container for a shard exists
+func (dp *dockerProvisioner) Exists(shardInfo *ShardInfo) bool {
+	return dp.dsm.dockerCommand(shardInfo.ID, "inspect", dp.dsm.containerName(shardInfo.ID)).Run() == nil
+}
+
+// Remove force-removes the Docker container for a shard and applies the
+// retention policy to its volume
+func (dp *dockerProvisioner) Remove(shardInfo *ShardInfo) error {
+	if err := dp.dsm.removeContainer(shardInfo.ID); err != nil {
+		return err
+	}
+	return dp.dsm.releaseVolume(shardInfo.ID)
+}
@@ -0,0 +1,100 @@
+package sharding
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// provisioningLogTail is the number of container log lines kept with a
+// failed provisioning
+const provisioningLogTail = 50
+
+// ProvisioningError reports a new shard that failed to come up. Its instance
+// was torn down and its ID and port freed for the next attempt.
+type ProvisioningError struct {
+	ShardID string
+	Err     error
+	// Logs holds the last lines the shard's container logged
+	Logs string
+	// CleanupErr is set when the instance couldn't be removed
+	CleanupErr error
+}
+
+// Error implements error
+func (e *ProvisioningError) Error() string {
+	if e.CleanupErr != nil {
+		return fmt.Sprintf("%v (cleanup failed: %v)", e.Err, e.CleanupErr)
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the failure that abandoned the shard
+func (e *ProvisioningError) Unwrap() error {
+	return e.Err
+}
+
+// AbandonShard tears down a shard that was just created but can't be used,
+// such as one the datastore couldn't connect to: it leaves the ring, its
+// instance is removed and it is forgotten
+func (dsm *DynamicShardManager) AbandonShard(shardID string, cause error) error {
+	shardInfo := dsm.copyShardInfo(shardID)
+	if shardInfo == nil {
+		return cause
+	}
+	dsm.ring.Remove(shardID)
+	dsm.ring.SetWeight(shardID, 1)
+	dsm.setShardStatus(shardID, "failed")
+	return dsm.abandonShard(shardInfo, cause)
+}
+
+// abandonShard undoes a failed provisioning: the container's last logs are
+// kept for the error, the instance and its volume are removed, and the shard
+// is forgotten. When it holds the last number handed out, that number (and
+// so its port) goes to the next shard created.
+func (dsm *DynamicShardManager) abandonShard(shardInfo *ShardInfo, cause error) error {
+	provisioningErr := &ProvisioningError{ShardID: shardInfo.ID, Err: cause}
+
+	if dsm.provisioner.Name() == ProvisionerDocker {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		var logs bytes.Buffer
+		if err := dsm.ContainerLogs(ctx, shardInfo.ID, provisioningLogTail, false, &logs); err == nil {
+			provisioningErr.Logs = logs.String()
+		}
+		cancel()
+	}
+
+	// A container that failed to start may not exist, while its volume does
+	var err error
+	if dsm.provisioner.Exists(shardInfo) {
+		err = dsm.provisioner.Remove(shardInfo)
+	} else if dsm.provisioner.Name() == ProvisionerDocker {
+		err = dsm.releaseVolume(shardInfo.ID)
+	}
+	if err != nil {
+		provisioningErr.CleanupErr = err
+		log.Printf("Warning: Failed to remove instance of failed shard %s: %v", shardInfo.ID, err)
+		return provisioningErr
+	}
+
+	dsm.mutex.Lock()
+	delete(dsm.shards, shardInfo.ID)
+	if num := shardNumber(shardInfo.ID); num > 0 && num == dsm.nextShardNum-1 {
+		dsm.nextShardNum--
+	}
+	dsm.mutex.Unlock()
+
+	log.Printf("🧹 Removed failed shard %s: %v", shardInfo.ID, cause)
+	return provisioningErr
+}
+
+// readyDeadline returns when waiting for a new shard to accept connections
+// gives up regardless of attempts left, or the zero time for no deadline
+func (dsm *DynamicShardManager) readyDeadline() time.Time {
+	if dsm.config.ReadyTimeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(dsm.config.ReadyTimeout)
+}
@@ -0,0 +1,234 @@
+package sharding
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReconcileAction describes what the reconciler did with an orphaned container
+type ReconcileAction struct {
+	ContainerName string     `json:"container_name"`
+	ShardID       string     `json:"shard_id"`
+	Action        string     `json:"action"`
+	Shard         *ShardInfo `json:"shard,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// Reconciler periodically compares shard containers against known shards and
+// adopts or removes orphans left behind by crashed scale-out attempts
+type Reconciler struct {
+	manager  *DynamicShardManager
+	interval time.Duration
+	policy   string
+	onAction func(ReconcileAction)
+	stopChan chan struct{}
+}
+
+// NewReconciler creates a reconciler. Policy is "adopt", "remove" or "report".
+func NewReconciler(manager *DynamicShardManager, interval time.Duration, policy string, onAction func(ReconcileAction)) *Reconciler {
+	return &Reconciler{
+		manager:  manager,
+		interval: interval,
+		policy:   policy,
+		onAction: onAction,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start starts the reconciliation loop
+func (r *Reconciler) Start() {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stopChan:
+				return
+			case <-ticker.C:
+				r.ReconcileOnce()
+			}
+		}
+	}()
+}
+
+// Stop stops the reconciliation loop
+func (r *Reconciler) Stop() {
+	close(r.stopChan)
+}
+
+// ReconcileOnce runs a single reconciliation pass
+func (r *Reconciler) ReconcileOnce() {
+	orphans, err := r.manager.FindOrphanContainers()
+	if err != nil {
+		log.Printf("Warning: Failed to list shard containers: %v", err)
+		return
+	}
+
+	for _, containerName := range orphans {
+		r.onAction(r.handleOrphan(containerName))
+	}
+
+	volumes, err := r.manager.FindOrphanVolumes()
+	if err != nil {
+		log.Printf("Warning: Failed to list shard volumes: %v", err)
+		return
+	}
+
+	for _, shardID := range volumes {
+		r.onAction(r.handleOrphanVolume(shardID))
+	}
+}
+
+// handleOrphanVolume adopts a shard volume left without a container. Volumes
+// are never removed by the reconciler; the retention policy only applies to
+// shards decommissioned through the manager.
+func (r *Reconciler) handleOrphanVolume(shardID string) ReconcileAction {
+	action := ReconcileAction{ContainerName: r.manager.containerName(shardID), ShardID: shardID}
+
+	if r.policy != "adopt" {
+		log.Printf("🔎 Found orphaned volume of shard %s", shardID)
+		action.Action = "volume_detected"
+		return action
+	}
+
+	log.Printf("🔎 Adopting orphaned volume of shard %s", shardID)
+	shardInfo, err := r.manager.AdoptVolume(shardID)
+	if err != nil {
+		action.Action = "adopt_failed"
+		action.Error = err.Error()
+		return action
+	}
+	action.Action = "volume_adopted"
+	action.Shard = shardInfo
+	return action
+}
+
+// handleOrphan applies the configured policy to a single orphaned container
+func (r *Reconciler) handleOrphan(containerName string) ReconcileAction {
+	shardID := strings.TrimPrefix(containerName, r.manager.config.ContainerPrefix+"-")
+	action := ReconcileAction{ContainerName: containerName, ShardID: shardID}
+
+	switch r.policy {
+	case "adopt":
+		log.Printf("🔎 Adopting orphaned container %s", containerName)
+		shardInfo, err := r.manager.AdoptContainer(containerName, shardID)
+		if err != nil {
+			action.Action = "adopt_failed"
+			action.Error = err.Error()
+			return action
+		}
+		action.Action = "adopted"
+		action.Shard = shardInfo
+	case "remove":
+		log.Printf("🔎 Removing orphaned container %s", containerName)
+		if err := r.manager.removeContainer(shardID); err != nil {
+			action.Action = "remove_failed"
+			action.Error = err.Error()
+			return action
+		}
+		action.Action = "removed"
+	default:
+		log.Printf("🔎 Found orphaned container %s", containerName)
+		action.Action = "detected"
+	}
+
+	return action
+}
+
+// FindOrphanContainers lists shard containers on the local Docker daemon that
+// do not belong to a known shard
+func (dsm *DynamicShardManager) FindOrphanContainers() ([]string, error) {
+	// Managed cloud instances are not discovered through Docker
+	if dsm.provisioner.Name() != ProvisionerDocker {
+		return nil, nil
+	}
+
+	prefix := dsm.config.ContainerPrefix + "-shard-"
+
+	cmd := exec.Command("docker", "ps", "-a", "--filter", "name="+prefix, "--format", "{{.Names}}")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("docker ps failed: %w, output: %s", err, string(output))
+	}
+
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	var orphans []string
+	for _, containerName := range strings.Fields(string(output)) {
+		if !strings.HasPrefix(containerName, prefix) {
+			continue
+		}
+
+		shardID := strings.TrimPrefix(containerName, dsm.config.ContainerPrefix+"-")
+		if shardInfo, exists := dsm.shards[shardID]; exists && shardInfo.Status != "failed" {
+			continue
+		}
+		orphans = append(orphans, containerName)
+	}
+
+	return orphans, nil
+}
+
+// AdoptContainer re-registers an orphaned shard container using its published port
+func (dsm *DynamicShardManager) AdoptContainer(containerName, shardID string) (*ShardInfo, error) {
+	num := shardNumber(shardID)
+	if num == 0 {
+		return nil, fmt.Errorf("container %s does not follow the shard naming scheme", containerName)
+	}
+
+	port, err := containerPort(containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	dbName := fmt.Sprintf("shard%d_db", num)
+	dsn, err := dsm.buildDSN(shardID, "127.0.0.1", port, dbName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build DSN for shard %s: %w", shardID, err)
+	}
+
+	// Make sure the container is running before waiting on it
+	if output, err := dsm.dockerCommand(shardID, "start", containerName).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("docker start failed: %w, output: %s", err, string(output))
+	}
+
+	return dsm.ResumeShard(ShardInfo{
+		ID:           shardID,
+		Host:         "127.0.0.1",
+		Port:         port,
+		DSN:          dsn,
+		DatabaseName: dbName,
+		Status:       "provisioning",
+		CreatedAt:    time.Now(),
+	})
+}
+
+// containerPort returns the host port published for the container's MySQL port
+func containerPort(containerName string) (int, error) {
+	output, err := exec.Command("docker", "inspect", "--format",
+		`{{(index (index .HostConfig.PortBindings "3306/tcp") 0).HostPort}}`, containerName).CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect container %s: %w, output: %s", containerName, err, string(output))
+	}
+
+	port, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return 0, fmt.Errorf("container %s has no published MySQL port", containerName)
+	}
+	return port, nil
+}
+
+// removeContainer force-removes the Docker container of a shard
+func (dsm *DynamicShardManager) removeContainer(shardID string) error {
+	output, err := dsm.dockerCommand(shardID, "rm", "-f", dsm.containerName(shardID)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("docker rm failed: %w, output: %s", err, string(output))
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package sharding
+
+import "sort"
+
+// RegionLabel is the shard label naming a shard's region, so configured
+// shards can be given one and regions can be used in label selectors
+const RegionLabel = "region"
+
+// zoneRegion returns the region of a zone, or fallback when the zone has none
+func (dsm *DynamicShardManager) zoneRegion(zoneName, fallback string) string {
+	for _, zone := range dsm.config.Zones {
+		if zone.Name == zoneName && zoneName != "" && zone.Region != "" {
+			return zone.Region
+		}
+	}
+	return fallback
+}
+
+// ShardRegion returns the region of a shard, or "" if it has none
+func (dsm *DynamicShardManager) ShardRegion(shardID string) string {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	if shardInfo, exists := dsm.shards[shardID]; exists {
+		return shardInfo.Region
+	}
+	return ""
+}
+
+// Regions returns the regions of the shards in the cluster, sorted
+func (dsm *DynamicShardManager) Regions() []string {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	seen := make(map[string]bool)
+	var regions []string
+	for _, shardInfo := range dsm.shards {
+		if shardInfo.Region != "" && !seen[shardInfo.Region] {
+			seen[shardInfo.Region] = true
+			regions = append(regions, shardInfo.Region)
+		}
+	}
+	sort.Strings(regions)
+	return regions
+}
@@ -0,0 +1,465 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// resyncInsertBatch is the number of rows copied per INSERT statement
+const resyncInsertBatch = 100
+
+// ResyncOptions controls a checksum resync of a shard
+type ResyncOptions struct {
+	// Source names the good copy in progress reports, e.g. a mirror ID
+	Source string
+	// SourceDSN connects to the good copy: a mirror, or a server a backup
+	// was restored to. Its default database is compared with the shard's.
+	SourceDSN string
+	// ChunkSize is the number of source rows compared per checksum
+	ChunkSize int
+	// MaxBytesPerSecond throttles the rows copied; zero is unthrottled
+	MaxBytesPerSecond int64
+}
+
+// ResyncProgress reports the progress of a resync
+type ResyncProgress struct {
+	Target            string `json:"target"`
+	Source            string `json:"source"`
+	Table             string `json:"table,omitempty"`
+	TablesTotal       int    `json:"tables_total"`
+	TablesDone        int    `json:"tables_done"`
+	ChunksChecked     int64  `json:"chunks_checked"`
+	ChunksDiverged    int64  `json:"chunks_diverged"`
+	RowsCopied        int64  `json:"rows_copied"`
+	RowsDeleted       int64  `json:"rows_deleted"`
+	BytesCopied       int64  `json:"bytes_copied"`
+	MaxBytesPerSecond int64  `json:"max_bytes_per_second"`
+	// Skipped lists tables without a single-column primary key, which
+	// can't be chunked
+	Skipped    []string   `json:"skipped,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// resyncState tracks a running resync; the throttle is read without the
+// resync mutex
+type resyncState struct {
+	progress          ResyncProgress
+	maxBytesPerSecond int64
+}
+
+// resyncTable is a table compared chunk by chunk along its primary key
+type resyncTable struct {
+	name    string
+	key     string
+	columns []string
+}
+
+// chunkRange selects the rows of a chunk: keys above lower, unless it is
+// nil, up to and including upper, unless it is nil
+type chunkRange struct {
+	lower, upper *string
+}
+
+// ResyncShard brings a shard back in line with a good copy of its data
+// without reloading it: each table is walked along its primary key in chunks
+// whose row counts and checksums are compared on both sides, and only the
+// chunks that differ are replaced on the shard with the source's rows. This
+// suits rebuilding a shard that is mostly intact, such as one restored from
+// an older backup or one that missed writes. Rows written to the shard while
+// it runs may be overwritten.
+func (dsm *DynamicShardManager) ResyncShard(targetID string, opts ResyncOptions) (*ResyncProgress, error) {
+	target := dsm.copyShardInfo(targetID)
+	if target == nil {
+		return nil, fmt.Errorf("shard %s not found", targetID)
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = 1000
+	}
+
+	dsm.resyncMutex.Lock()
+	if state, running := dsm.resyncs[targetID]; running && state.progress.FinishedAt == nil {
+		dsm.resyncMutex.Unlock()
+		return nil, fmt.Errorf("shard %s is already being resynced from %s", targetID, state.progress.Source)
+	}
+	state := &resyncState{
+		progress: ResyncProgress{
+			Target:    targetID,
+			Source:    opts.Source,
+			StartedAt: time.Now(),
+		},
+		maxBytesPerSecond: opts.MaxBytesPerSecond,
+	}
+	dsm.resyncs[targetID] = state
+	dsm.resyncMutex.Unlock()
+
+	log.Printf("🔍 Resyncing shard %s from %s in chunks of %d rows", targetID, opts.Source, opts.ChunkSize)
+	err := dsm.runResync(target, opts, state)
+	dsm.finishResync(state, err)
+
+	dsm.resyncMutex.Lock()
+	progress := state.snapshot()
+	dsm.resyncMutex.Unlock()
+	if err != nil {
+		return progress, err
+	}
+	log.Printf("✅ Resynced shard %s: %d of %d chunks differed, %d rows copied, %d deleted",
+		targetID, progress.ChunksDiverged, progress.ChunksChecked, progress.RowsCopied, progress.RowsDeleted)
+	return progress, nil
+}
+
+// runResync compares and repairs every table of the source
+func (dsm *DynamicShardManager) runResync(target *ShardInfo, opts ResyncOptions, state *resyncState) error {
+	source, err := sql.Open("mysql", opts.SourceDSN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to source: %w", err)
+	}
+	defer source.Close()
+
+	db, err := sql.Open("mysql", target.DSN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to shard %s: %w", target.ID, err)
+	}
+	defer db.Close()
+
+	// One connection, so foreign key checks stay off for every chunk
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to shard %s: %w", target.ID, err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SET SESSION foreign_key_checks = 0"); err != nil {
+		return fmt.Errorf("failed to disable foreign key checks: %w", err)
+	}
+
+	tables, skipped, err := resyncTables(source)
+	if err != nil {
+		return err
+	}
+	dsm.updateResync(state, func(p *ResyncProgress) {
+		p.TablesTotal = len(tables)
+		p.Skipped = skipped
+	})
+	for _, table := range skipped {
+		log.Printf("Warning: Not resyncing table %s of shard %s, which has no single-column primary key", table, target.ID)
+	}
+
+	for _, table := range tables {
+		dsm.updateResync(state, func(p *ResyncProgress) { p.Table = table.name })
+		if err := dsm.resyncTable(ctx, source, conn, table, opts.ChunkSize, state); err != nil {
+			return fmt.Errorf("table %s: %w", table.name, err)
+		}
+		dsm.updateResync(state, func(p *ResyncProgress) { p.TablesDone++ })
+	}
+	return nil
+}
+
+// resyncTables lists the source's tables with their primary key and columns,
+// and separately the tables that can't be chunked
+func resyncTables(source *sql.DB) ([]resyncTable, []string, error) {
+	rows, err := source.Query(`SELECT c.TABLE_NAME, c.COLUMN_NAME, c.COLUMN_KEY = 'PRI'
+FROM information_schema.COLUMNS c
+JOIN information_schema.TABLES t ON t.TABLE_SCHEMA = c.TABLE_SCHEMA AND t.TABLE_NAME = c.TABLE_NAME
+WHERE c.TABLE_SCHEMA = DATABASE() AND t.TABLE_TYPE = 'BASE TABLE'
+ORDER BY c.TABLE_NAME, c.ORDINAL_POSITION`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list source tables: %w", err)
+	}
+	defer rows.Close()
+
+	var order []string
+	byName := make(map[string]*resyncTable)
+	keys := make(map[string]int)
+	for rows.Next() {
+		var name, column string
+		var primary bool
+		if err := rows.Scan(&name, &column, &primary); err != nil {
+			return nil, nil, fmt.Errorf("failed to list source tables: %w", err)
+		}
+		table, exists := byName[name]
+		if !exists {
+			table = &resyncTable{name: name}
+			byName[name] = table
+			order = append(order, name)
+		}
+		table.columns = append(table.columns, column)
+		if primary {
+			table.key = column
+			keys[name]++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to list source tables: %w", err)
+	}
+
+	var tables []resyncTable
+	var skipped []string
+	for _, name := range order {
+		if keys[name] != 1 {
+			skipped = append(skipped, name)
+			continue
+		}
+		tables = append(tables, *byName[name])
+	}
+	return tables, skipped, nil
+}
+
+// resyncTable walks a table in chunks of the source's keys, replacing the
+// chunks whose checksums differ. The last chunk is open-ended, so rows the
+// shard has beyond the source's last key are caught too.
+func (dsm *DynamicShardManager) resyncTable(ctx context.Context, source *sql.DB, conn *sql.Conn, table resyncTable, chunkSize int, state *resyncState) error {
+	var lower *string
+	for {
+		var upper sql.NullString
+		query := fmt.Sprintf("SELECT %s FROM %s", quoteIdentifier(table.key), quoteIdentifier(table.name))
+		var args []interface{}
+		if lower != nil {
+			query += fmt.Sprintf(" WHERE %s > ?", quoteIdentifier(table.key))
+			args = append(args, *lower)
+		}
+		query += fmt.Sprintf(" ORDER BY %s LIMIT 1 OFFSET %d", quoteIdentifier(table.key), chunkSize-1)
+		err := source.QueryRowContext(ctx, query, args...).Scan(&upper)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to find chunk bounds: %w", err)
+		}
+
+		chunk := chunkRange{lower: lower}
+		if err == nil {
+			chunk.upper = &upper.String
+		}
+		if err := dsm.resyncChunk(ctx, source, conn, table, chunk, state); err != nil {
+			return err
+		}
+		if chunk.upper == nil {
+			return nil
+		}
+		lower = chunk.upper
+	}
+}
+
+// resyncChunk compares one chunk and replaces it on the shard if it differs
+func (dsm *DynamicShardManager) resyncChunk(ctx context.Context, source *sql.DB, conn *sql.Conn, table resyncTable, chunk chunkRange, state *resyncState) error {
+	where, args := chunk.where(table.key)
+	checksum := fmt.Sprintf("SELECT COUNT(*), COALESCE(BIT_XOR(CRC32(CONCAT_WS('#', %s))), 0) FROM %s%s",
+		checksumColumns(table.columns), quoteIdentifier(table.name), where)
+
+	var sourceCount, targetCount int64
+	var sourceSum, targetSum string
+	if err := source.QueryRowContext(ctx, checksum, args...).Scan(&sourceCount, &sourceSum); err != nil {
+		return fmt.Errorf("failed to checksum source chunk: %w", err)
+	}
+	if err := conn.QueryRowContext(ctx, checksum, args...).Scan(&targetCount, &targetSum); err != nil {
+		return fmt.Errorf("failed to checksum shard chunk: %w", err)
+	}
+
+	dsm.updateResync(state, func(p *ResyncProgress) { p.ChunksChecked++ })
+	if sourceCount == targetCount && sourceSum == targetSum {
+		return nil
+	}
+	dsm.updateResync(state, func(p *ResyncProgress) { p.ChunksDiverged++ })
+	return dsm.copyChunk(ctx, source, conn, table, where, args, state)
+}
+
+// copyChunk replaces a chunk's rows on the shard with the source's in one
+// transaction, throttling the rows copied
+func (dsm *DynamicShardManager) copyChunk(ctx context.Context, source *sql.DB, conn *sql.Conn, table resyncTable, where string, args []interface{}, state *resyncState) error {
+	columns := make([]string, len(table.columns))
+	for i, column := range table.columns {
+		columns[i] = quoteIdentifier(column)
+	}
+	rows, err := source.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s%s", strings.Join(columns, ", "), quoteIdentifier(table.name), where), args...)
+	if err != nil {
+		return fmt.Errorf("failed to read source chunk: %w", err)
+	}
+	defer rows.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s%s", quoteIdentifier(table.name), where), args...)
+	if err != nil {
+		return fmt.Errorf("failed to clear shard chunk: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+
+	rowPlaceholder := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	insert := func(batch []interface{}, count int) error {
+		if count == 0 {
+			return nil
+		}
+		statement := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", quoteIdentifier(table.name), strings.Join(columns, ", "),
+			strings.TrimSuffix(strings.Repeat(rowPlaceholder+", ", count), ", "))
+		_, err := tx.ExecContext(ctx, statement, batch...)
+		return err
+	}
+
+	var copied, copiedBytes int64
+	var batch []interface{}
+	count, batchBytes := 0, 0
+	for rows.Next() {
+		values := make([]sql.RawBytes, len(columns))
+		scan := make([]interface{}, len(columns))
+		for i := range values {
+			scan[i] = &values[i]
+		}
+		if err := rows.Scan(scan...); err != nil {
+			return fmt.Errorf("failed to read source row: %w", err)
+		}
+		for _, value := range values {
+			if value == nil {
+				batch = append(batch, nil)
+				continue
+			}
+			batch = append(batch, append([]byte{}, value...))
+			batchBytes += len(value)
+		}
+		count++
+
+		if count == resyncInsertBatch {
+			if err := insert(batch, count); err != nil {
+				return fmt.Errorf("failed to copy rows: %w", err)
+			}
+			copied += int64(count)
+			copiedBytes += int64(batchBytes)
+			throttleResync(state, batchBytes)
+			batch, count, batchBytes = batch[:0], 0, 0
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read source chunk: %w", err)
+	}
+	if err := insert(batch, count); err != nil {
+		return fmt.Errorf("failed to copy rows: %w", err)
+	}
+	copied += int64(count)
+	copiedBytes += int64(batchBytes)
+	throttleResync(state, batchBytes)
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit chunk: %w", err)
+	}
+	dsm.updateResync(state, func(p *ResyncProgress) {
+		p.RowsCopied += copied
+		p.RowsDeleted += deleted
+		p.BytesCopied += copiedBytes
+	})
+	return nil
+}
+
+// throttleResync sleeps long enough to keep the bytes copied under the
+// resync's current rate
+func throttleResync(state *resyncState, n int) {
+	if rate := atomic.LoadInt64(&state.maxBytesPerSecond); rate > 0 && n > 0 {
+		time.Sleep(time.Duration(float64(n) / float64(rate) * float64(time.Second)))
+	}
+}
+
+// where returns the WHERE clause selecting the chunk and its arguments
+func (c chunkRange) where(key string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if c.lower != nil {
+		conditions = append(conditions, quoteIdentifier(key)+" > ?")
+		args = append(args, *c.lower)
+	}
+	if c.upper != nil {
+		conditions = append(conditions, quoteIdentifier(key)+" <= ?")
+		args = append(args, *c.upper)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// quoteIdentifier quotes a table or column name
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// checksumColumns lists a table's columns for CONCAT_WS, each followed by
+// its NULL flag since CONCAT_WS skips NULLs
+func checksumColumns(columns []string) string {
+	parts := make([]string, 0, 2*len(columns))
+	for _, column := range columns {
+		parts = append(parts, quoteIdentifier(column), "ISNULL("+quoteIdentifier(column)+")")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Resyncs returns the progress of every resync, running or finished
+func (dsm *DynamicShardManager) Resyncs() []ResyncProgress {
+	dsm.resyncMutex.Lock()
+	defer dsm.resyncMutex.Unlock()
+
+	resyncs := make([]ResyncProgress, 0, len(dsm.resyncs))
+	for _, state := range dsm.resyncs {
+		resyncs = append(resyncs, *state.snapshot())
+	}
+	return resyncs
+}
+
+// ResyncStatus returns the progress of a shard's latest resync, or nil
+func (dsm *DynamicShardManager) ResyncStatus(targetID string) *ResyncProgress {
+	dsm.resyncMutex.Lock()
+	defer dsm.resyncMutex.Unlock()
+
+	state, exists := dsm.resyncs[targetID]
+	if !exists {
+		return nil
+	}
+	return state.snapshot()
+}
+
+// SetResyncThrottle changes the copy byte rate of a running resync; zero
+// removes the throttle
+func (dsm *DynamicShardManager) SetResyncThrottle(targetID string, maxBytesPerSecond int64) error {
+	dsm.resyncMutex.Lock()
+	defer dsm.resyncMutex.Unlock()
+
+	state, exists := dsm.resyncs[targetID]
+	if !exists || state.progress.FinishedAt != nil {
+		return fmt.Errorf("shard %s is not being resynced", targetID)
+	}
+	atomic.StoreInt64(&state.maxBytesPerSecond, maxBytesPerSecond)
+	log.Printf("🔍 Resync of shard %s throttled to %d bytes/s", targetID, maxBytesPerSecond)
+	return nil
+}
+
+// snapshot copies the progress with the current throttle. Must be called
+// with the resync mutex held.
+func (s *resyncState) snapshot() *ResyncProgress {
+	progress := s.progress
+	progress.MaxBytesPerSecond = atomic.LoadInt64(&s.maxBytesPerSecond)
+	progress.Skipped = append([]string(nil), s.progress.Skipped...)
+	return &progress
+}
+
+// updateResync changes a resync's progress under the resync mutex
+func (dsm *DynamicShardManager) updateResync(state *resyncState, update func(*ResyncProgress)) {
+	dsm.resyncMutex.Lock()
+	defer dsm.resyncMutex.Unlock()
+	update(&state.progress)
+}
+
+// finishResync marks a resync finished, with its error if it failed
+func (dsm *DynamicShardManager) finishResync(state *resyncState, err error) {
+	dsm.updateResync(state, func(p *ResyncProgress) {
+		now := time.Now()
+		p.FinishedAt = &now
+		p.Table = ""
+		if err != nil {
+			p.Error = err.Error()
+		}
+	})
+}
@@ -0,0 +1,290 @@
+package sharding
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// ringReplicas is the number of tokens, or virtual nodes, a shard gets on
+// the ring unless a layout says otherwise
+const ringReplicas = 20
+
+// errEmptyRing is returned when looking up a key on a ring without shards
+var errEmptyRing = errors.New("empty ring")
+
+// RingToken is one position on the ring and the shard owning the keys that
+// hash up to it
+type RingToken struct {
+	Token uint32 `json:"token"`
+	Shard string `json:"shard"`
+}
+
+// RingLayout is the exported token ownership of the ring. Importing it makes
+// a router or a restarted coordinator place every key exactly as it did.
+type RingLayout struct {
+	// Checksum identifies the layout; equal checksums route identically
+	Checksum string      `json:"checksum"`
+	Tokens   []RingToken `json:"tokens"`
+}
+
+// hashRing is a consistent hash ring with explicit token ownership. A shard's
+// tokens are hashed from its ID the way earlier versions placed them, unless
+// an imported layout pins them. The ring is rebuilt from its members in ID
+// order on every change, so two tokens colliding always resolve to the same
+// shard whatever order shards were added or removed in.
+type hashRing struct {
+	members map[string]bool
+	// pinned holds the tokens an imported layout assigns to shards
+	pinned map[string][]uint32
+	// weights holds the fraction of their tokens shards being ramped up use;
+	// other shards use all of them
+	weights map[string]float64
+	owners  map[uint32]string
+	sorted  []uint32
+	mutex   sync.RWMutex
+}
+
+// newHashRing creates an empty ring
+func newHashRing() *hashRing {
+	return &hashRing{
+		members: make(map[string]bool),
+		pinned:  make(map[string][]uint32),
+		weights: make(map[string]float64),
+		owners:  make(map[uint32]string),
+	}
+}
+
+// Add places a shard on the ring
+func (hr *hashRing) Add(shardID string) {
+	hr.mutex.Lock()
+	defer hr.mutex.Unlock()
+
+	hr.members[shardID] = true
+	hr.rebuild()
+}
+
+// Remove takes a shard off the ring
+func (hr *hashRing) Remove(shardID string) {
+	hr.mutex.Lock()
+	defer hr.mutex.Unlock()
+
+	delete(hr.members, shardID)
+	hr.rebuild()
+}
+
+// Members returns the shards on the ring in ID order
+func (hr *hashRing) Members() []string {
+	hr.mutex.RLock()
+	defer hr.mutex.RUnlock()
+
+	return hr.memberIDs()
+}
+
+// Get returns the shard owning a key: the owner of the first token after the
+// key's hash, wrapping around
+func (hr *hashRing) Get(key string) (string, error) {
+	hr.mutex.RLock()
+	defer hr.mutex.RUnlock()
+
+	if len(hr.sorted) == 0 {
+		return "", errEmptyRing
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(hr.sorted), func(i int) bool { return hr.sorted[i] > hash })
+	if i == len(hr.sorted) {
+		i = 0
+	}
+	return hr.owners[hr.sorted[i]], nil
+}
+
+// withMember returns a copy of the ring with one more shard, to compute
+// ownership before the shard joins
+func (hr *hashRing) withMember(shardID string) *hashRing {
+	hr.mutex.RLock()
+	defer hr.mutex.RUnlock()
+
+	future := newHashRing()
+	for member := range hr.members {
+		future.members[member] = true
+	}
+	for member, tokens := range hr.pinned {
+		future.pinned[member] = tokens
+	}
+	for member, weight := range hr.weights {
+		future.weights[member] = weight
+	}
+	future.members[shardID] = true
+	future.rebuild()
+	return future
+}
+
+// SetWeight makes a shard use a fraction of its tokens, the first ones of its
+// token list, so raising the weight only ever adds keys to the shard. A
+// weight of 1 or more restores all of them.
+func (hr *hashRing) SetWeight(shardID string, weight float64) {
+	hr.mutex.Lock()
+	defer hr.mutex.Unlock()
+
+	if weight >= 1 {
+		delete(hr.weights, shardID)
+	} else {
+		hr.weights[shardID] = math.Max(weight, 0)
+	}
+	hr.rebuild()
+}
+
+// Weights returns the weights of the shards using part of their tokens
+func (hr *hashRing) Weights() map[string]float64 {
+	hr.mutex.RLock()
+	defer hr.mutex.RUnlock()
+
+	weights := make(map[string]float64, len(hr.weights))
+	for shardID, weight := range hr.weights {
+		weights[shardID] = weight
+	}
+	return weights
+}
+
+// Layout exports the ring's tokens in token order
+func (hr *hashRing) Layout() RingLayout {
+	hr.mutex.RLock()
+	defer hr.mutex.RUnlock()
+
+	layout := RingLayout{Tokens: make([]RingToken, 0, len(hr.sorted))}
+	hash := fnv.New64a()
+	for _, token := range hr.sorted {
+		layout.Tokens = append(layout.Tokens, RingToken{Token: token, Shard: hr.owners[token]})
+		fmt.Fprintf(hash, "%d=%s;", token, hr.owners[token])
+	}
+	layout.Checksum = fmt.Sprintf("%016x", hash.Sum64())
+	return layout
+}
+
+// Import pins the tokens of every shard in a layout. Shards already on the
+// ring move to their pinned tokens; the others take them when they join.
+func (hr *hashRing) Import(layout RingLayout) error {
+	pinned := make(map[string][]uint32)
+	seen := make(map[uint32]bool, len(layout.Tokens))
+	for _, token := range layout.Tokens {
+		if token.Shard == "" {
+			return fmt.Errorf("token %d has no shard", token.Token)
+		}
+		if seen[token.Token] {
+			return fmt.Errorf("token %d is assigned more than once", token.Token)
+		}
+		seen[token.Token] = true
+		pinned[token.Shard] = append(pinned[token.Shard], token.Token)
+	}
+
+	hr.mutex.Lock()
+	defer hr.mutex.Unlock()
+
+	hr.pinned = pinned
+	hr.rebuild()
+	return nil
+}
+
+// rebuild recomputes token ownership from the members. Must be called with
+// the mutex held.
+func (hr *hashRing) rebuild() {
+	owners := make(map[uint32]string)
+	for _, member := range hr.memberIDs() {
+		for _, token := range hr.tokens(member) {
+			if _, taken := owners[token]; !taken {
+				owners[token] = member
+			}
+		}
+	}
+
+	sorted := make([]uint32, 0, len(owners))
+	for token := range owners {
+		sorted = append(sorted, token)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	hr.owners, hr.sorted = owners, sorted
+}
+
+// tokens returns a shard's pinned tokens, or those hashed from its ID, cut to
+// its weight. Must be called with the mutex held.
+func (hr *hashRing) tokens(shardID string) []uint32 {
+	tokens, exists := hr.pinned[shardID]
+	if !exists {
+		tokens = make([]uint32, ringReplicas)
+		for i := range tokens {
+			tokens[i] = crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + shardID))
+		}
+	}
+	if weight, weighted := hr.weights[shardID]; weighted {
+		tokens = tokens[:int(math.Round(weight*float64(len(tokens))))]
+	}
+	return tokens
+}
+
+// memberIDs returns the members in ID order. Must be called with the mutex
+// held.
+func (hr *hashRing) memberIDs() []string {
+	ids := make([]string, 0, len(hr.members))
+	for member := range hr.members {
+		ids = append(ids, member)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// RingLayout exports the token ownership of the ring
+func (dsm *DynamicShardManager) RingLayout() RingLayout {
+	return dsm.ring.Layout()
+}
+
+// ImportRingLayout pins shards to the tokens of a saved layout
+func (dsm *DynamicShardManager) ImportRingLayout(layout RingLayout) error {
+	if err := dsm.ring.Import(layout); err != nil {
+		return fmt.Errorf("invalid ring layout: %w", err)
+	}
+	return nil
+}
+
+// SetRingWeight makes a shard own a fraction of the keys it would own at
+// full weight, for ramping traffic onto a new shard
+func (dsm *DynamicShardManager) SetRingWeight(shardID string, weight float64) {
+	dsm.ring.SetWeight(shardID, weight)
+}
+
+// RingWeights returns the weights of shards owning only part of their keys
+func (dsm *DynamicShardManager) RingWeights() map[string]float64 {
+	return dsm.ring.Weights()
+}
+
+// RestoreRingWeights adopts the weights of another process's ring, restoring
+// full weight to shards not listed
+func (dsm *DynamicShardManager) RestoreRingWeights(weights map[string]float64) {
+	for shardID := range dsm.ring.Weights() {
+		if _, exists := weights[shardID]; !exists {
+			dsm.ring.SetWeight(shardID, 1)
+		}
+	}
+	for shardID, weight := range weights {
+		dsm.ring.SetWeight(shardID, weight)
+	}
+}
+
+// LoadRingLayout reads a layout saved from GET /topology/ring
+func LoadRingLayout(path string) (RingLayout, error) {
+	var layout RingLayout
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return layout, fmt.Errorf("failed to read ring layout: %w", err)
+	}
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return layout, fmt.Errorf("failed to parse ring layout: %w", err)
+	}
+	return layout, nil
+}
@@ -0,0 +1,134 @@
+package sharding
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"sql-horizontal-autoscaler/config"
+)
+
+// mysqldumpFlags dump table definitions, including indexes, constraints and
+// triggers, without any rows
+var mysqldumpFlags = []string{
+	"--no-data",
+	"--skip-comments",
+	"--skip-add-drop-table",
+	"--no-tablespaces",
+	"--triggers",
+}
+
+// schemaSource picks an active shard to copy the schema from, never the new
+// shard itself. Shards are tried in ID order so the choice is stable.
+func (dsm *DynamicShardManager) schemaSource(exclude string) (*ShardInfo, bool) {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	shardIDs := make([]string, 0, len(dsm.shards))
+	for shardID, shardInfo := range dsm.shards {
+		if shardID != exclude && shardInfo.Status == "active" {
+			shardIDs = append(shardIDs, shardID)
+		}
+	}
+	if len(shardIDs) == 0 {
+		return nil, false
+	}
+	sort.Strings(shardIDs)
+
+	copied := *dsm.shards[shardIDs[0]]
+	return &copied, true
+}
+
+// shardSchema returns the DDL to apply to a new shard, dumped from a healthy
+// existing shard so new shards match the live schema. The bundled DDL is only
+// used when there is no shard to copy from.
+func (dsm *DynamicShardManager) shardSchema(shardInfo *ShardInfo) (string, error) {
+	source, exists := dsm.schemaSource(shardInfo.ID)
+	if !exists {
+		log.Printf("Warning: No active shard to copy the schema from, using the bundled schema for %s", shardInfo.ID)
+		return strings.Join(shardSchemaStatements(shardInfo.ID), ";\n") + ";", nil
+	}
+
+	dump, err := dsm.dumpSchema(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to dump schema from shard %s: %w", source.ID, err)
+	}
+
+	log.Printf("📐 Copying schema from shard %s to shard %s", source.ID, shardInfo.ID)
+	return adaptSchema(dump, source.ID, shardInfo.ID), nil
+}
+
+// dumpSchema runs mysqldump --no-data against a shard. Docker shards are
+// dumped inside their container; other shards with a local mysqldump.
+func (dsm *DynamicShardManager) dumpSchema(source *ShardInfo) (string, error) {
+	var cmd *exec.Cmd
+	if dsm.provisioner.Name() == ProvisionerDocker {
+		password, _ := dsm.credentials()
+		args := append([]string{"exec", dsm.containerName(source.ID),
+			"mysqldump", "-u", dsm.config.DatabaseUsername,
+			fmt.Sprintf("-p%s", password)}, mysqldumpFlags...)
+		cmd = dsm.dockerCommand(source.ID, append(args, source.DatabaseName)...)
+	} else {
+		localCmd, err := localMySQLCommand("mysqldump", source.DSN, mysqldumpFlags...)
+		if err != nil {
+			return "", err
+		}
+		cmd = localCmd
+	}
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("mysqldump failed: %w, output: %s", err, stderr.String())
+	}
+	if !strings.Contains(string(output), "CREATE TABLE") {
+		return "", fmt.Errorf("mysqldump returned no table definitions")
+	}
+	return string(output), nil
+}
+
+// adaptSchema rewrites a dump for the target shard: tables are only created if
+// missing, so a shard re-using an existing volume keeps its data, and column
+// defaults naming the source shard name the target instead
+func adaptSchema(dump, sourceID, targetID string) string {
+	dump = strings.ReplaceAll(dump, "CREATE TABLE `", "CREATE TABLE IF NOT EXISTS `")
+	return strings.ReplaceAll(dump, fmt.Sprintf("DEFAULT '%s'", sourceID), fmt.Sprintf("DEFAULT '%s'", targetID))
+}
+
+// shardMySQLCommand builds a mysql client command connected to a shard's
+// database: inside the container for Docker shards, a local client otherwise
+func (dsm *DynamicShardManager) shardMySQLCommand(shardInfo *ShardInfo) (*exec.Cmd, error) {
+	if dsm.provisioner.Name() == ProvisionerDocker {
+		password, _ := dsm.credentials()
+		return dsm.dockerCommand(shardInfo.ID, "exec", "-i", dsm.containerName(shardInfo.ID),
+			"mysql", "-u", dsm.config.DatabaseUsername,
+			fmt.Sprintf("-p%s", password), shardInfo.DatabaseName), nil
+	}
+	return localMySQLCommand("mysql", shardInfo.DSN)
+}
+
+// localMySQLCommand builds a mysql or mysqldump command connecting to the
+// database named in a DSN. The password is passed in the environment so it
+// does not show up in the process list.
+func localMySQLCommand(binary, dsn string, args ...string) (*exec.Cmd, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %w", err)
+	}
+	host, port, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		host, port = cfg.Addr, "3306"
+	}
+
+	cmdArgs := append([]string{"-h", host, "-P", port, "-u", cfg.User}, config.MySQLClientTLSArgs(dsn)...)
+	cmdArgs = append(cmdArgs, args...)
+	cmd := exec.Command(binary, append(cmdArgs, cfg.DBName)...)
+	cmd.Env = append(os.Environ(), "MYSQL_PWD="+cfg.Passwd)
+	return cmd, nil
+}
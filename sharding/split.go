@@ -0,0 +1,449 @@
+package sharding
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Split phases
+const (
+	SplitProvisioning   = "provisioning"
+	SplitCloning        = "cloning"
+	SplitCleaningTarget = "cleaning_target"
+	SplitCleaningSource = "cleaning_source"
+	SplitCompleted      = "completed"
+	SplitFailed         = "failed"
+)
+
+// mysqldumpCloneFlags dump a consistent snapshot of a shard's tables and rows.
+// DROP TABLE statements are kept so the dump replaces the new shard's tables.
+var mysqldumpCloneFlags = []string{
+	"--single-transaction",
+	"--skip-comments",
+	"--no-tablespaces",
+	"--triggers",
+}
+
+// SplitOptions controls a clone split
+type SplitOptions struct {
+	// Zone places the new shard; empty uses the source's zone
+	Zone string
+	// ShardKeys maps each sharded table of the default database to its key
+	// column; rows of other tables are left as cloned
+	ShardKeys map[string]string
+	// MaxBytesPerSecond throttles the clone stream; zero is unthrottled
+	MaxBytesPerSecond int64
+	// DeleteBatchSize is the number of keys removed per DELETE statement
+	DeleteBatchSize int
+	// DeletePause is slept between DELETE batches
+	DeletePause time.Duration
+}
+
+// SplitProgress reports the progress of a clone split
+type SplitProgress struct {
+	Source            string           `json:"source"`
+	Target            string           `json:"target,omitempty"`
+	Phase             string           `json:"phase"`
+	BytesCloned       int64            `json:"bytes_cloned"`
+	MaxBytesPerSecond int64            `json:"max_bytes_per_second"`
+	RowsDeleted       map[string]int64 `json:"rows_deleted"`
+	StartedAt         time.Time        `json:"started_at"`
+	FinishedAt        *time.Time       `json:"finished_at,omitempty"`
+	Error             string           `json:"error,omitempty"`
+}
+
+// splitState tracks a running split; the clone counters are updated without
+// the split mutex
+type splitState struct {
+	progress          SplitProgress
+	bytesCloned       int64
+	maxBytesPerSecond int64
+}
+
+// SplitShard splits a shard by cloning it: a new shard is provisioned outside
+// the ring and loaded from a mysqldump of the source, rows it won't own are
+// deleted from it, it joins the ring, and the rows it took over are deleted
+// from the source. The source is read-only throughout. This is much faster
+// than copying rows one by one for large shards, but like any scale-out the
+// new shard also takes over keys of other shards, whose rows are not moved.
+func (dsm *DynamicShardManager) SplitShard(sourceID string, opts SplitOptions) (*SplitProgress, error) {
+	source, exists := dsm.GetShardInfo(sourceID)
+	if !exists || source.Status != "active" {
+		return nil, fmt.Errorf("shard %s is not active", sourceID)
+	}
+	sourceCopy := *source
+	if opts.Zone == "" {
+		opts.Zone = sourceCopy.Zone
+	}
+	if opts.DeleteBatchSize <= 0 {
+		opts.DeleteBatchSize = 1000
+	}
+
+	dsm.splitMutex.Lock()
+	if state, running := dsm.splits[sourceID]; running && state.progress.FinishedAt == nil {
+		dsm.splitMutex.Unlock()
+		return nil, fmt.Errorf("shard %s is already being split into %s", sourceID, state.progress.Target)
+	}
+	state := &splitState{
+		progress: SplitProgress{
+			Source:      sourceID,
+			Phase:       SplitProvisioning,
+			RowsDeleted: make(map[string]int64),
+			StartedAt:   time.Now(),
+		},
+		maxBytesPerSecond: opts.MaxBytesPerSecond,
+	}
+	dsm.splits[sourceID] = state
+	dsm.splitMutex.Unlock()
+
+	target, err := dsm.runSplit(&sourceCopy, opts, state)
+	if err != nil {
+		dsm.finishSplit(state, err)
+		return dsm.SplitStatus(sourceID), err
+	}
+
+	dsm.finishSplit(state, nil)
+	log.Printf("✅ Split shard %s into %s in %s", sourceID, target.ID, time.Since(state.progress.StartedAt).Round(time.Second))
+	return dsm.SplitStatus(sourceID), nil
+}
+
+// runSplit performs the phases of a split
+func (dsm *DynamicShardManager) runSplit(source *ShardInfo, opts SplitOptions, state *splitState) (*ShardInfo, error) {
+	restore, err := dsm.freezeShards([]string{source.ID}, "splitting")
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+
+	log.Printf("✂️  Splitting shard %s by cloning it", source.ID)
+	target, err := dsm.createShard(opts.Zone)
+	if err != nil {
+		return nil, err
+	}
+	dsm.setSplitTarget(state, target.ID)
+
+	// Until the target joins the ring a failed split is undone by removing it
+	abandon := func(err error) (*ShardInfo, error) {
+		dsm.setShardStatus(target.ID, "failed")
+		return nil, dsm.abandonShard(target, err)
+	}
+
+	if err := dsm.provisioner.WaitReady(target); err != nil {
+		return abandon(fmt.Errorf("shard %s failed to become ready: %w", target.ID, err))
+	}
+	dsm.setShardStatus(target.ID, "cloning")
+
+	dsm.setSplitPhase(state, SplitCloning)
+	if err := dsm.cloneShard(source, target, state); err != nil {
+		return abandon(fmt.Errorf("failed to clone shard %s into %s: %w", source.ID, target.ID, err))
+	}
+
+	// Ownership is decided by the ring as it will be once the target joins
+	future := dsm.ring.withMember(target.ID)
+	owner := func(table, key string) string {
+		if shardID, exists := dsm.lookupDirectory(table, key); exists {
+			return dsm.resolveMerged(shardID)
+		}
+		if shardID, isTimeRange, _ := dsm.timeRangeShard(table, key); isTimeRange {
+			return shardID
+		}
+		shardID, err := future.Get(key)
+		if err != nil {
+			return ""
+		}
+		return dsm.resolveMerged(shardID)
+	}
+
+	dsm.setSplitPhase(state, SplitCleaningTarget)
+	err = dsm.deleteRowsWhere(target, opts, state, func(table, key string) bool {
+		return owner(table, key) != target.ID
+	})
+	if err != nil {
+		return abandon(fmt.Errorf("failed to remove foreign rows from %s: %w", target.ID, err))
+	}
+
+	// Routing now sends the target's keys to it; the source still holds
+	// copies of them until cleaned, which scatter-gather reads may see
+	dsm.ring.Add(target.ID)
+	dsm.setShardStatus(target.ID, "active")
+	log.Printf("✂️  Shard %s joined the ring", target.ID)
+
+	dsm.setSplitPhase(state, SplitCleaningSource)
+	err = dsm.deleteRowsWhere(source, opts, state, func(table, key string) bool {
+		return owner(table, key) == target.ID
+	})
+	if err != nil {
+		return target, fmt.Errorf("shard %s is active but rows it took over could not be removed from %s: %w", target.ID, source.ID, err)
+	}
+	return target, nil
+}
+
+// cloneShard streams a mysqldump of the source's default database into the
+// target, throttled to the split's byte rate
+func (dsm *DynamicShardManager) cloneShard(source, target *ShardInfo, state *splitState) error {
+	dump, err := dsm.mysqlCommand(source, "mysqldump", mysqldumpCloneFlags...)
+	if err != nil {
+		return err
+	}
+	load, err := dsm.mysqlCommand(target, "mysql")
+	if err != nil {
+		return err
+	}
+
+	var dumpErr, loadErr strings.Builder
+	dump.Stderr = &dumpErr
+	load.Stderr = &loadErr
+	stdout, err := dump.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to set up mysqldump: %w", err)
+	}
+	load.Stdin = &throttledReader{
+		r:     newDefaultsRewriter(stdout, source.ID, target.ID),
+		state: state,
+	}
+
+	if err := dump.Start(); err != nil {
+		return fmt.Errorf("failed to start mysqldump: %w", err)
+	}
+	if err := load.Run(); err != nil {
+		dump.Process.Kill()
+		dump.Wait()
+		return fmt.Errorf("mysql load failed: %w, output: %s", err, loadErr.String())
+	}
+	if err := dump.Wait(); err != nil {
+		return fmt.Errorf("mysqldump failed: %w, output: %s", err, dumpErr.String())
+	}
+
+	log.Printf("✂️  Cloned %d bytes from shard %s into %s", atomic.LoadInt64(&state.bytesCloned), source.ID, target.ID)
+	return nil
+}
+
+// mysqlCommand builds a mysql or mysqldump command against a shard's default
+// database, run inside the container for Docker shards
+func (dsm *DynamicShardManager) mysqlCommand(shardInfo *ShardInfo, binary string, args ...string) (*exec.Cmd, error) {
+	if dsm.provisioner.Name() != ProvisionerDocker {
+		return localMySQLCommand(binary, shardInfo.DSN, args...)
+	}
+
+	password, _ := dsm.credentials()
+	dockerArgs := []string{"exec", "-i", dsm.containerName(shardInfo.ID),
+		binary, "-u", dsm.config.DatabaseUsername,
+		fmt.Sprintf("-p%s", password)}
+	dockerArgs = append(dockerArgs, args...)
+	return dsm.dockerCommand(shardInfo.ID, append(dockerArgs, shardInfo.DatabaseName)...), nil
+}
+
+// deleteRowsWhere deletes the rows of every sharded table whose key matches
+// remove, in batches with a pause between them
+func (dsm *DynamicShardManager) deleteRowsWhere(shardInfo *ShardInfo, opts SplitOptions, state *splitState, remove func(table, key string) bool) error {
+	db, err := sql.Open("mysql", shardInfo.DSN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to shard %s: %w", shardInfo.ID, err)
+	}
+	defer db.Close()
+
+	for table, keyColumn := range opts.ShardKeys {
+		keys, err := distinctKeys(db, table, keyColumn)
+		if err != nil {
+			return err
+		}
+
+		var doomed []interface{}
+		for _, key := range keys {
+			if remove(table, key) {
+				doomed = append(doomed, key)
+			}
+		}
+
+		for start := 0; start < len(doomed); start += opts.DeleteBatchSize {
+			end := start + opts.DeleteBatchSize
+			if end > len(doomed) {
+				end = len(doomed)
+			}
+			batch := doomed[start:end]
+			placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(batch)), ", ")
+			deleted, err := deleteMatchingRows(db, table, fmt.Sprintf("`%s` IN (%s)", keyColumn, placeholders), batch)
+			if err != nil {
+				return fmt.Errorf("failed to delete from %s: %w", table, err)
+			}
+			dsm.addSplitDeletes(state, shardInfo.ID, deleted)
+			if opts.DeletePause > 0 {
+				time.Sleep(opts.DeletePause)
+			}
+		}
+		if len(doomed) > 0 {
+			log.Printf("   Removed %d keys of %s from shard %s", len(doomed), table, shardInfo.ID)
+		}
+	}
+	return nil
+}
+
+// distinctKeys returns the distinct non-NULL shard key values of a table
+func distinctKeys(db *sql.DB, table, keyColumn string) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT DISTINCT `%s` FROM `%s` WHERE `%s` IS NOT NULL", keyColumn, table, keyColumn))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to read keys of %s: %w", table, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// SplitStatus returns the progress of the latest split of a shard, or nil
+func (dsm *DynamicShardManager) SplitStatus(sourceID string) *SplitProgress {
+	dsm.splitMutex.Lock()
+	defer dsm.splitMutex.Unlock()
+
+	state, exists := dsm.splits[sourceID]
+	if !exists {
+		return nil
+	}
+	return state.snapshot()
+}
+
+// Splits returns the progress of every split since startup
+func (dsm *DynamicShardManager) Splits() []SplitProgress {
+	dsm.splitMutex.Lock()
+	defer dsm.splitMutex.Unlock()
+
+	splits := make([]SplitProgress, 0, len(dsm.splits))
+	for _, state := range dsm.splits {
+		splits = append(splits, *state.snapshot())
+	}
+	return splits
+}
+
+// SetSplitThrottle changes the clone byte rate of a running split; zero
+// removes the throttle
+func (dsm *DynamicShardManager) SetSplitThrottle(sourceID string, maxBytesPerSecond int64) error {
+	dsm.splitMutex.Lock()
+	defer dsm.splitMutex.Unlock()
+
+	state, exists := dsm.splits[sourceID]
+	if !exists || state.progress.FinishedAt != nil {
+		return fmt.Errorf("shard %s is not being split", sourceID)
+	}
+	atomic.StoreInt64(&state.maxBytesPerSecond, maxBytesPerSecond)
+	log.Printf("✂️  Split of shard %s throttled to %d bytes/s", sourceID, maxBytesPerSecond)
+	return nil
+}
+
+// snapshot copies the progress with the current counters. Must be called
+// with the split mutex held.
+func (s *splitState) snapshot() *SplitProgress {
+	progress := s.progress
+	progress.BytesCloned = atomic.LoadInt64(&s.bytesCloned)
+	progress.MaxBytesPerSecond = atomic.LoadInt64(&s.maxBytesPerSecond)
+	progress.RowsDeleted = make(map[string]int64, len(s.progress.RowsDeleted))
+	for shardID, deleted := range s.progress.RowsDeleted {
+		progress.RowsDeleted[shardID] = deleted
+	}
+	return &progress
+}
+
+// setSplitTarget records the shard a split creates
+func (dsm *DynamicShardManager) setSplitTarget(state *splitState, targetID string) {
+	dsm.splitMutex.Lock()
+	defer dsm.splitMutex.Unlock()
+	state.progress.Target = targetID
+}
+
+// setSplitPhase moves a split to its next phase
+func (dsm *DynamicShardManager) setSplitPhase(state *splitState, phase string) {
+	dsm.splitMutex.Lock()
+	defer dsm.splitMutex.Unlock()
+	state.progress.Phase = phase
+}
+
+// addSplitDeletes counts rows a split deleted from a shard
+func (dsm *DynamicShardManager) addSplitDeletes(state *splitState, shardID string, deleted int64) {
+	dsm.splitMutex.Lock()
+	defer dsm.splitMutex.Unlock()
+	state.progress.RowsDeleted[shardID] += deleted
+}
+
+// finishSplit marks a split completed or failed
+func (dsm *DynamicShardManager) finishSplit(state *splitState, err error) {
+	dsm.splitMutex.Lock()
+	defer dsm.splitMutex.Unlock()
+
+	now := time.Now()
+	state.progress.FinishedAt = &now
+	state.progress.Phase = SplitCompleted
+	if err != nil {
+		state.progress.Phase = SplitFailed
+		state.progress.Error = err.Error()
+	}
+}
+
+// throttledReader counts the bytes read through it and sleeps to keep them
+// under the split's current rate
+type throttledReader struct {
+	r     io.Reader
+	state *splitState
+}
+
+// Read implements io.Reader
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	// Small reads keep the rate smooth
+	if len(p) > 64*1024 {
+		p = p[:64*1024]
+	}
+	n, err := tr.r.Read(p)
+	atomic.AddInt64(&tr.state.bytesCloned, int64(n))
+	if rate := atomic.LoadInt64(&tr.state.maxBytesPerSecond); rate > 0 && n > 0 {
+		time.Sleep(time.Duration(float64(n) / float64(rate) * float64(time.Second)))
+	}
+	return n, err
+}
+
+// defaultsRewriter streams a dump, rewriting column defaults naming the
+// source shard to name the target as adaptSchema does. Row data is left alone.
+type defaultsRewriter struct {
+	r        *bufio.Reader
+	from, to []byte
+	pending  []byte
+	err      error
+}
+
+// newDefaultsRewriter wraps a dump of sourceID for loading into targetID
+func newDefaultsRewriter(r io.Reader, sourceID, targetID string) *defaultsRewriter {
+	return &defaultsRewriter{
+		r:    bufio.NewReaderSize(r, 1<<20),
+		from: []byte(fmt.Sprintf("DEFAULT '%s'", sourceID)),
+		to:   []byte(fmt.Sprintf("DEFAULT '%s'", targetID)),
+	}
+}
+
+// Read implements io.Reader
+func (dr *defaultsRewriter) Read(p []byte) (int, error) {
+	for len(dr.pending) == 0 {
+		if dr.err != nil {
+			return 0, dr.err
+		}
+		line, err := dr.r.ReadBytes('\n')
+		if !bytes.HasPrefix(line, []byte("INSERT INTO")) {
+			line = bytes.ReplaceAll(line, dr.from, dr.to)
+		}
+		dr.pending, dr.err = line, err
+	}
+	n := copy(p, dr.pending)
+	dr.pending = dr.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,356 @@
+package sharding
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time range periods, the granularity of range boundaries
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// timeKeyLayouts are the timestamp formats accepted as time range shard keys
+var timeKeyLayouts = []string{
+	"2006-01-02 15:04:05.999999",
+	"2006-01-02 15:04:05",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// TimeRangePolicy shards a time-series table by a timestamp column
+type TimeRangePolicy struct {
+	Column string
+	Period string
+}
+
+// TimeRange maps rows of a table whose timestamp falls in [Start, End) to a
+// shard. The current range has no End. Archived ranges are detached from
+// routing: their rows stay on the shard but queries no longer reach them.
+type TimeRange struct {
+	Table    string     `json:"table"`
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end,omitempty"`
+	ShardID  string     `json:"shard_id"`
+	Archived bool       `json:"archived,omitempty"`
+}
+
+// Contains reports whether a timestamp falls in the range
+func (tr *TimeRange) Contains(t time.Time) bool {
+	return !t.Before(tr.Start) && (tr.End == nil || t.Before(*tr.End))
+}
+
+// PeriodStart truncates a time to the start of its period, in UTC
+func PeriodStart(t time.Time, period string) time.Time {
+	t = t.UTC()
+	switch period {
+	case PeriodDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case PeriodWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		// Weeks start on Monday
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// AddPeriods moves a period start forward (or back, for negative n) by n periods
+func AddPeriods(t time.Time, period string, n int) time.Time {
+	switch period {
+	case PeriodDay:
+		return t.AddDate(0, 0, n)
+	case PeriodWeek:
+		return t.AddDate(0, 0, 7*n)
+	default:
+		return t.AddDate(0, n, 0)
+	}
+}
+
+// ParseTimeKey parses a shard key value of a time range table. Timestamps
+// without a zone are taken as UTC; integers are Unix seconds.
+func ParseTimeKey(key string) (time.Time, error) {
+	key = strings.Trim(key, "'\"")
+	for _, layout := range timeKeyLayouts {
+		if t, err := time.ParseInLocation(layout, key, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	if seconds, err := strconv.ParseInt(key, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", key)
+}
+
+// SetTimeRangePolicies registers the time range tables. Tables without ranges
+// start with a single open range on the first active shard.
+func (dsm *DynamicShardManager) SetTimeRangePolicies(policies map[string]TimeRangePolicy) {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	dsm.timePolicies = policies
+	for table := range policies {
+		if len(dsm.timeRanges[table]) > 0 {
+			continue
+		}
+		if shardID := dsm.firstActiveShard(); shardID != "" {
+			dsm.timeRanges[table] = []TimeRange{{Table: table, ShardID: shardID}}
+		}
+	}
+}
+
+// firstActiveShard returns the lowest active shard ID. Callers hold the mutex.
+func (dsm *DynamicShardManager) firstActiveShard() string {
+	shardIDs := make([]string, 0, len(dsm.shards))
+	for shardID, shardInfo := range dsm.shards {
+		if shardInfo.Status == "active" {
+			shardIDs = append(shardIDs, shardID)
+		}
+	}
+	if len(shardIDs) == 0 {
+		return ""
+	}
+	sort.Slice(shardIDs, func(i, j int) bool { return shardNumber(shardIDs[i]) < shardNumber(shardIDs[j]) })
+	return shardIDs[0]
+}
+
+// IsTimeRangeTable reports whether a table is sharded by time range
+func (dsm *DynamicShardManager) IsTimeRangeTable(table string) bool {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	_, exists := dsm.timePolicies[table]
+	return exists
+}
+
+// GetTimeRanges returns every table's time ranges, ordered by table and start
+func (dsm *DynamicShardManager) GetTimeRanges() []TimeRange {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	tables := make([]string, 0, len(dsm.timeRanges))
+	for table := range dsm.timeRanges {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	var ranges []TimeRange
+	for _, table := range tables {
+		ranges = append(ranges, dsm.timeRanges[table]...)
+	}
+	return ranges
+}
+
+// RestoreTimeRanges replaces the ranges of every table present in the
+// persisted ranges
+func (dsm *DynamicShardManager) RestoreTimeRanges(ranges []TimeRange) {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	restored := make(map[string][]TimeRange)
+	for _, tr := range ranges {
+		restored[tr.Table] = append(restored[tr.Table], tr)
+	}
+	for table, tableRanges := range restored {
+		sort.Slice(tableRanges, func(i, j int) bool { return tableRanges[i].Start.Before(tableRanges[j].Start) })
+		dsm.timeRanges[table] = tableRanges
+	}
+}
+
+// timeRangeShard returns the shard holding a table's rows for a timestamp
+// key. Timestamps before the first range go to the first range.
+func (dsm *DynamicShardManager) timeRangeShard(table, key string) (string, bool, error) {
+	dsm.mutex.RLock()
+	_, isTimeRange := dsm.timePolicies[table]
+	ranges := dsm.timeRanges[table]
+	dsm.mutex.RUnlock()
+
+	if !isTimeRange {
+		return "", false, nil
+	}
+	if len(ranges) == 0 {
+		return "", true, fmt.Errorf("table %s has no time ranges", table)
+	}
+
+	t, err := ParseTimeKey(key)
+	if err != nil {
+		return "", true, err
+	}
+
+	match := ranges[0]
+	for _, tr := range ranges {
+		if tr.Contains(t) {
+			match = tr
+			break
+		}
+	}
+	if match.Archived {
+		return "", true, fmt.Errorf("time range of %s starting %s is archived", table, match.Start.Format(time.RFC3339))
+	}
+	return dsm.resolveMerged(match.ShardID), true, nil
+}
+
+// TimeRangeShards returns the shards holding a time range table's routable
+// rows, for scatter-gather; ok is false for other tables
+func (dsm *DynamicShardManager) TimeRangeShards(table string) ([]string, bool) {
+	dsm.mutex.RLock()
+	_, isTimeRange := dsm.timePolicies[table]
+	ranges := dsm.timeRanges[table]
+	dsm.mutex.RUnlock()
+
+	if !isTimeRange {
+		return nil, false
+	}
+
+	seen := make(map[string]bool)
+	var shards []string
+	for _, tr := range ranges {
+		shardID := dsm.resolveMerged(tr.ShardID)
+		if !tr.Archived && !seen[shardID] {
+			seen[shardID] = true
+			shards = append(shards, shardID)
+		}
+	}
+	return shards, true
+}
+
+// CurrentTimeRange returns the open range new rows of a table are written to
+func (dsm *DynamicShardManager) CurrentTimeRange(table string) (TimeRange, bool) {
+	dsm.mutex.RLock()
+	defer dsm.mutex.RUnlock()
+
+	ranges := dsm.timeRanges[table]
+	if len(ranges) == 0 {
+		return TimeRange{}, false
+	}
+	return ranges[len(ranges)-1], true
+}
+
+// RollOverTimeRange closes a table's current range at the next period
+// boundary and opens a new range on the given shard from that boundary on.
+// Rows up to the boundary keep going to the current shard.
+func (dsm *DynamicShardManager) RollOverTimeRange(table, shardID string, now time.Time) (TimeRange, error) {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	policy, exists := dsm.timePolicies[table]
+	if !exists {
+		return TimeRange{}, fmt.Errorf("table %s is not sharded by time range", table)
+	}
+	if shardInfo, exists := dsm.shards[shardID]; !exists || shardInfo.Status != "active" {
+		return TimeRange{}, fmt.Errorf("shard %s is not active", shardID)
+	}
+
+	// Ranges are copied on write since readers use them without the mutex
+	ranges := append([]TimeRange{}, dsm.timeRanges[table]...)
+	if len(ranges) == 0 {
+		return TimeRange{}, fmt.Errorf("table %s has no time ranges", table)
+	}
+	current := &ranges[len(ranges)-1]
+	boundary := AddPeriods(PeriodStart(now, policy.Period), policy.Period, 1)
+	if !current.Start.Before(boundary) {
+		return TimeRange{}, fmt.Errorf("table %s already rolls over at %s", table, current.Start.Format(time.RFC3339))
+	}
+
+	current.End = &boundary
+	next := TimeRange{Table: table, Start: boundary, ShardID: shardID}
+	dsm.timeRanges[table] = append(ranges, next)
+	return next, nil
+}
+
+// ArchiveTimeRanges archives a table's ranges that ended before the cutoff,
+// returning the newly archived ranges
+func (dsm *DynamicShardManager) ArchiveTimeRanges(table string, cutoff time.Time) []TimeRange {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	ranges := append([]TimeRange{}, dsm.timeRanges[table]...)
+	var archived []TimeRange
+	for i := range ranges {
+		tr := &ranges[i]
+		if !tr.Archived && tr.End != nil && !tr.End.After(cutoff) {
+			tr.Archived = true
+			archived = append(archived, *tr)
+		}
+	}
+	dsm.timeRanges[table] = ranges
+	return archived
+}
+
+// ArchiveTimeRangesOnShard archives a table's closed ranges held by a shard,
+// detaching the shard from the table. The current range cannot be archived.
+func (dsm *DynamicShardManager) ArchiveTimeRangesOnShard(table, shardID string) ([]TimeRange, error) {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	ranges := append([]TimeRange{}, dsm.timeRanges[table]...)
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("table %s has no time ranges", table)
+	}
+	if ranges[len(ranges)-1].ShardID == shardID {
+		return nil, fmt.Errorf("shard %s holds the current time range of %s", shardID, table)
+	}
+
+	var archived []TimeRange
+	for i := range ranges {
+		if ranges[i].ShardID == shardID && !ranges[i].Archived {
+			ranges[i].Archived = true
+			archived = append(archived, ranges[i])
+		}
+	}
+	if len(archived) == 0 {
+		return nil, fmt.Errorf("shard %s holds no routable time ranges of %s", shardID, table)
+	}
+	dsm.timeRanges[table] = ranges
+	return archived, nil
+}
+
+// CountTimeRangeRows counts the rows of a table's range on the range's shard
+func (dsm *DynamicShardManager) CountTimeRangeRows(tr TimeRange) (int64, error) {
+	shardID := dsm.resolveMerged(tr.ShardID)
+	dsm.mutex.RLock()
+	policy, exists := dsm.timePolicies[tr.Table]
+	shardInfo, shardExists := dsm.shards[shardID]
+	dsm.mutex.RUnlock()
+
+	if !exists {
+		return 0, fmt.Errorf("table %s is not sharded by time range", tr.Table)
+	}
+	if !shardExists {
+		return 0, fmt.Errorf("shard %s not found", tr.ShardID)
+	}
+
+	db, err := sql.Open("mysql", shardInfo.DSN)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open connection to shard %s: %w", shardInfo.ID, err)
+	}
+	defer db.Close()
+
+	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM `%s` WHERE `%s` >= ?", tr.Table, policy.Column)
+	args := []interface{}{tr.Start.Format("2006-01-02 15:04:05")}
+	if tr.Start.IsZero() {
+		countSQL = fmt.Sprintf("SELECT COUNT(*) FROM `%s`", tr.Table)
+		args = nil
+	}
+	if tr.End != nil {
+		if tr.Start.IsZero() {
+			countSQL += fmt.Sprintf(" WHERE `%s` < ?", policy.Column)
+		} else {
+			countSQL += fmt.Sprintf(" AND `%s` < ?", policy.Column)
+		}
+		args = append(args, tr.End.Format("2006-01-02 15:04:05"))
+	}
+
+	var count int64
+	if err := db.QueryRow(countSQL, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count %s rows on shard %s: %w", tr.Table, shardInfo.ID, err)
+	}
+	return count, nil
+}
@@ -0,0 +1,193 @@
+package sharding
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Topology is the routing view shared with query routers
+type Topology struct {
+	Version    string            `json:"version"`
+	Shards     map[string]string `json:"shards"`
+	Directory  []DirectoryEntry  `json:"directory"`
+	TimeRanges []TimeRange       `json:"time_ranges,omitempty"`
+	// Ring is the checksum of the ring layout, which routers compare with
+	// their own
+	Ring string `json:"ring"`
+	// RingWeights holds the shards still being ramped up, which own only
+	// part of their keys
+	RingWeights map[string]float64 `json:"ring_weights,omitempty"`
+	// Endpoints locates the shards the coordinator provisioned, so routers
+	// running apart from it can connect with their own credentials
+	Endpoints map[string]ShardEndpoint `json:"endpoints,omitempty"`
+}
+
+// TopologySignatureHeader carries the signature of a pushed topology
+const TopologySignatureHeader = "X-Topology-Signature"
+
+// SignTopology returns the HMAC-SHA256 of an encoded topology keyed by the
+// routers' push secret
+func SignTopology(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyTopology reports whether an encoded topology was signed with the
+// push secret
+func VerifyTopology(secret string, body []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil || secret == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// ShardEndpoint is the address and database of a shard, without credentials
+type ShardEndpoint struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Database string `json:"database"`
+}
+
+// Topology returns the current shard statuses, directory and ring, versioned by a
+// hash of their content so routers can detect stale views
+func (dsm *DynamicShardManager) Topology() Topology {
+	dsm.mutex.RLock()
+	topology := Topology{
+		Shards:    make(map[string]string, len(dsm.shards)),
+		Directory: append([]DirectoryEntry{}, dsm.directory...),
+	}
+	for shardID, shardInfo := range dsm.shards {
+		status := shardInfo.Status
+		if shardInfo.MergedInto != "" {
+			status += ":" + shardInfo.MergedInto
+		}
+		topology.Shards[shardID] = status
+		if shardInfo.Host != "" {
+			if topology.Endpoints == nil {
+				topology.Endpoints = make(map[string]ShardEndpoint)
+			}
+			topology.Endpoints[shardID] = ShardEndpoint{Host: shardInfo.Host, Port: shardInfo.Port, Database: shardInfo.DatabaseName}
+		}
+	}
+	dsm.mutex.RUnlock()
+	topology.TimeRanges = dsm.GetTimeRanges()
+	topology.Ring = dsm.ring.Layout().Checksum
+	if weights := dsm.ring.Weights(); len(weights) > 0 {
+		topology.RingWeights = weights
+	}
+
+	shardIDs := make([]string, 0, len(topology.Shards))
+	for shardID := range topology.Shards {
+		shardIDs = append(shardIDs, shardID)
+	}
+	sort.Strings(shardIDs)
+
+	hash := fnv.New64a()
+	for _, shardID := range shardIDs {
+		fmt.Fprintf(hash, "%s=%s;", shardID, topology.Shards[shardID])
+		if endpoint, exists := topology.Endpoints[shardID]; exists {
+			fmt.Fprintf(hash, "%s:%d/%s;", endpoint.Host, endpoint.Port, endpoint.Database)
+		}
+	}
+	directory, _ := json.Marshal(topology.Directory)
+	hash.Write(directory)
+	timeRanges, _ := json.Marshal(topology.TimeRanges)
+	hash.Write(timeRanges)
+	hash.Write([]byte(topology.Ring))
+
+	topology.Version = fmt.Sprintf("%016x", hash.Sum64())
+	return topology
+}
+
+// onRing reports whether a shard with the given status is a ring member:
+// active shards, and merged ones whose key space resolves to their destination
+func onRing(status string) bool {
+	return status == "active" || status == "merging" || status == "merged"
+}
+
+// AdoptShards follows the shards of a coordinator's topology, for routers
+// running apart from the coordinator. Shards this manager doesn't know are
+// registered once they are active, after connect opens their connection; a
+// shard whose connection fails is skipped, and AdoptShards reports false so
+// the topology is applied again. Known shards take the coordinator's status,
+// joining or leaving the ring with it.
+func (dsm *DynamicShardManager) AdoptShards(topology Topology, connect func(shardInfo *ShardInfo) error) bool {
+	adopted := true
+	for shardID, status := range topology.Shards {
+		status, mergedInto, _ := strings.Cut(status, ":")
+
+		dsm.mutex.Lock()
+		shardInfo, exists := dsm.shards[shardID]
+		if exists {
+			wasOnRing := onRing(shardInfo.Status)
+			shardInfo.Status, shardInfo.MergedInto = status, mergedInto
+			dsm.mutex.Unlock()
+
+			if onRing(status) && !wasOnRing {
+				dsm.ring.Add(shardID)
+				log.Printf("Shard %s joined the ring as %s", shardID, status)
+			} else if !onRing(status) && wasOnRing {
+				dsm.ring.Remove(shardID)
+				log.Printf("Shard %s left the ring as %s", shardID, status)
+			}
+			continue
+		}
+		dsm.mutex.Unlock()
+
+		if !onRing(status) {
+			continue
+		}
+		endpoint, located := topology.Endpoints[shardID]
+		if !located {
+			log.Printf("Warning: Topology has no endpoint for shard %s, not adopting it", shardID)
+			continue
+		}
+		dsn, err := dsm.buildDSN(shardID, endpoint.Host, endpoint.Port, endpoint.Database)
+		if err != nil {
+			log.Printf("Warning: Failed to build DSN for shard %s: %v", shardID, err)
+			adopted = false
+			continue
+		}
+
+		shardInfo = &ShardInfo{
+			ID:           shardID,
+			Host:         endpoint.Host,
+			Port:         endpoint.Port,
+			DSN:          dsn,
+			DatabaseName: endpoint.Database,
+			Status:       status,
+			CreatedAt:    time.Now(),
+			MergedInto:   mergedInto,
+			Labels:       copyLabels(dsm.config.ShardLabels[shardID]),
+			Zone:         dsm.config.ShardLabels[shardID][ZoneLabel],
+			Region:       dsm.config.ShardLabels[shardID][RegionLabel],
+		}
+		if err := connect(shardInfo); err != nil {
+			log.Printf("Warning: Failed to connect to shard %s from topology: %v", shardID, err)
+			adopted = false
+			continue
+		}
+
+		dsm.mutex.Lock()
+		dsm.shards[shardID] = shardInfo
+		if num := shardNumber(shardID); num >= dsm.nextShardNum {
+			dsm.nextShardNum = num + 1
+		}
+		dsm.mutex.Unlock()
+		dsm.ring.Add(shardID)
+		log.Printf("📥 Adopted shard %s at %s:%d from the coordinator's topology", shardID, endpoint.Host, endpoint.Port)
+	}
+	return adopted
+}
@@ -0,0 +1,244 @@
+package sharding
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Volume modes for shard containers
+const (
+	VolumeModeNone  = "none"
+	VolumeModeNamed = "named"
+	VolumeModeBind  = "bind"
+)
+
+// Retention policies applied to a shard's volume when it is decommissioned
+const (
+	RetentionDelete  = "delete"
+	RetentionArchive = "archive"
+)
+
+// VolumeConfig controls where shard containers keep their data directory
+type VolumeConfig struct {
+	// Mode is "none" (data lives in the container), "named" (a Docker volume
+	// per shard) or "bind" (a directory per shard under BindDir)
+	Mode    string
+	BindDir string
+	// Shards overrides the volume of individual shards; values containing a
+	// slash are bind mount paths, anything else a named volume
+	Shards     map[string]string
+	Retention  string
+	ArchiveDir string
+	// ArchiveImage runs tar when archiving named volumes
+	ArchiveImage string
+}
+
+// shardVolume is the storage backing a shard's data directory
+type shardVolume struct {
+	source string
+	bind   bool
+}
+
+// volumeFor returns the volume of a shard, or nil when its data is not
+// persisted outside the container
+func (dsm *DynamicShardManager) volumeFor(shardID string) *shardVolume {
+	volumes := dsm.config.Volumes
+	if source, exists := volumes.Shards[shardID]; exists {
+		return &shardVolume{source: source, bind: strings.Contains(source, "/")}
+	}
+
+	switch volumes.Mode {
+	case VolumeModeNamed:
+		return &shardVolume{source: dsm.containerName(shardID) + "-data"}
+	case VolumeModeBind:
+		return &shardVolume{source: filepath.Join(volumes.BindDir, shardID), bind: true}
+	}
+	return nil
+}
+
+// volumeArgs returns the docker run arguments mounting a shard's volume
+func (dsm *DynamicShardManager) volumeArgs(shardID string) ([]string, error) {
+	volume := dsm.volumeFor(shardID)
+	if volume == nil {
+		return nil, nil
+	}
+
+	source := volume.source
+	if volume.bind {
+		abs, err := filepath.Abs(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bind mount %s: %w", source, err)
+		}
+		if err := os.MkdirAll(abs, 0750); err != nil {
+			return nil, fmt.Errorf("failed to create bind mount %s: %w", abs, err)
+		}
+		source = abs
+	} else if !dsm.volumeExists(shardID) {
+		// Created up front so the volume carries the owner label
+		if output, err := dsm.dockerCommand(shardID, "volume", "create", "--label", dsm.ownerLabel(), source).CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("docker volume create failed: %w, output: %s", err, string(output))
+		}
+	}
+
+	return []string{"-v", source + ":/var/lib/mysql"}, nil
+}
+
+// volumeExists reports whether a shard's volume holds data from an earlier container
+func (dsm *DynamicShardManager) volumeExists(shardID string) bool {
+	volume := dsm.volumeFor(shardID)
+	if volume == nil {
+		return false
+	}
+
+	if volume.bind {
+		entries, err := os.ReadDir(volume.source)
+		return err == nil && len(entries) > 0
+	}
+	return dsm.dockerCommand(shardID, "volume", "inspect", volume.source).Run() == nil
+}
+
+// releaseVolume applies the retention policy to a decommissioned shard's volume
+func (dsm *DynamicShardManager) releaseVolume(shardID string) error {
+	volume := dsm.volumeFor(shardID)
+	if volume == nil || !dsm.volumeExists(shardID) {
+		return nil
+	}
+
+	if dsm.config.Volumes.Retention == RetentionArchive {
+		archive, err := dsm.archiveVolume(shardID, volume)
+		if err != nil {
+			return err
+		}
+		log.Printf("🗄️  Archived volume of shard %s to %s", shardID, archive)
+		if volume.bind {
+			// The directory itself was moved into the archive
+			return nil
+		}
+	}
+
+	if volume.bind {
+		if err := os.RemoveAll(volume.source); err != nil {
+			return fmt.Errorf("failed to delete bind mount %s: %w", volume.source, err)
+		}
+	} else if output, err := dsm.dockerCommand(shardID, "volume", "rm", volume.source).CombinedOutput(); err != nil {
+		return fmt.Errorf("docker volume rm failed: %w, output: %s", err, string(output))
+	}
+
+	log.Printf("🗑️  Deleted volume of shard %s", shardID)
+	return nil
+}
+
+// archiveVolume moves a bind mount into the archive directory, or tars a named
+// volume into it, and returns the archive path
+func (dsm *DynamicShardManager) archiveVolume(shardID string, volume *shardVolume) (string, error) {
+	archiveDir, err := filepath.Abs(dsm.config.Volumes.ArchiveDir)
+	if err != nil {
+		return "", fmt.Errorf("invalid archive directory: %w", err)
+	}
+	if err := os.MkdirAll(archiveDir, 0750); err != nil {
+		return "", fmt.Errorf("failed to create archive directory %s: %w", archiveDir, err)
+	}
+
+	name := fmt.Sprintf("%s-%s", shardID, time.Now().UTC().Format("20060102T150405Z"))
+
+	if volume.bind {
+		archive := filepath.Join(archiveDir, name)
+		if err := os.Rename(volume.source, archive); err != nil {
+			return "", fmt.Errorf("failed to archive bind mount %s: %w", volume.source, err)
+		}
+		return archive, nil
+	}
+
+	archive := name + ".tar.gz"
+	output, err := dsm.dockerCommand(shardID, "run", "--rm",
+		"-v", volume.source+":/data:ro",
+		"-v", archiveDir+":/archive",
+		dsm.config.Volumes.ArchiveImage,
+		"tar", "czf", "/archive/"+archive, "-C", "/data", ".").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to archive volume %s: %w, output: %s", volume.source, err, string(output))
+	}
+	return filepath.Join(archiveDir, archive), nil
+}
+
+// FindOrphanVolumes lists shard IDs whose volume survives without a container
+// or a known shard
+func (dsm *DynamicShardManager) FindOrphanVolumes() ([]string, error) {
+	if dsm.provisioner.Name() != ProvisionerDocker {
+		return nil, nil
+	}
+
+	var candidates []string
+	switch dsm.config.Volumes.Mode {
+	case VolumeModeNamed:
+		prefix := dsm.config.ContainerPrefix + "-shard-"
+		output, err := exec.Command("docker", "volume", "ls", "--filter", "name="+prefix, "--format", "{{.Name}}").CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("docker volume ls failed: %w, output: %s", err, string(output))
+		}
+		for _, name := range strings.Fields(string(output)) {
+			if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, "-data") {
+				candidates = append(candidates, strings.TrimSuffix(strings.TrimPrefix(name, dsm.config.ContainerPrefix+"-"), "-data"))
+			}
+		}
+	case VolumeModeBind:
+		entries, err := os.ReadDir(dsm.config.Volumes.BindDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to list bind mounts: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() && shardNumber(entry.Name()) > 0 {
+				candidates = append(candidates, entry.Name())
+			}
+		}
+	}
+
+	var orphans []string
+	for _, shardID := range candidates {
+		if shardInfo, exists := dsm.GetShardInfo(shardID); exists && shardInfo.Status != "failed" {
+			continue
+		}
+		if dsm.dockerCommand(shardID, "inspect", dsm.containerName(shardID)).Run() == nil {
+			// Orphaned containers are adopted together with their volume
+			continue
+		}
+		orphans = append(orphans, shardID)
+	}
+
+	return orphans, nil
+}
+
+// AdoptVolume starts a new container on an orphaned shard volume and
+// re-registers the shard with the data it holds
+func (dsm *DynamicShardManager) AdoptVolume(shardID string) (*ShardInfo, error) {
+	num := shardNumber(shardID)
+	if num == 0 {
+		return nil, fmt.Errorf("volume of %s does not follow the shard naming scheme", shardID)
+	}
+
+	shardInfo := ShardInfo{
+		ID:           shardID,
+		Host:         "127.0.0.1",
+		Port:         dsm.config.BasePort + num - 1,
+		DatabaseName: fmt.Sprintf("shard%d_db", num),
+		Status:       "provisioning",
+		CreatedAt:    time.Now(),
+	}
+
+	dsn, err := dsm.buildDSN(shardID, shardInfo.Host, shardInfo.Port, shardInfo.DatabaseName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build DSN for shard %s: %w", shardID, err)
+	}
+	shardInfo.DSN = dsn
+
+	if err := dsm.provisionDockerShard(&shardInfo); err != nil {
+		return nil, err
+	}
+
+	return dsm.ResumeShard(shardInfo)
+}
@@ -0,0 +1,90 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WarmupConfig runs queries against a new shard before it joins the ring, to
+// load its buffer pool and indexes ahead of real traffic
+type WarmupConfig struct {
+	Queries []string
+	// VirtualUsers is the number of connections running the queries at once
+	VirtualUsers int
+	// Iterations is how many times each virtual user runs the queries
+	Iterations int
+	// Timeout bounds the whole warmup
+	Timeout time.Duration
+}
+
+// warmShard runs the warmup queries on a shard, holding it in the "warming"
+// state meanwhile. Warmup is best effort: failing queries are logged and the
+// shard is activated once the queries finish or the timeout expires.
+func (dsm *DynamicShardManager) warmShard(shardInfo *ShardInfo) {
+	warmup := dsm.config.Warmup
+	if len(warmup.Queries) == 0 {
+		return
+	}
+
+	db, err := sql.Open("mysql", shardInfo.DSN)
+	if err != nil {
+		log.Printf("Warning: Skipping warmup of shard %s: %v", shardInfo.ID, err)
+		return
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(warmup.VirtualUsers)
+
+	dsm.setShardStatus(shardInfo.ID, "warming")
+	log.Printf("🔥 Warming up shard %s with %d virtual users", shardInfo.ID, warmup.VirtualUsers)
+
+	ctx, cancel := context.WithTimeout(context.Background(), warmup.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	var executed, failed int64
+	var wg sync.WaitGroup
+	for user := 0; user < warmup.VirtualUsers; user++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < warmup.Iterations; i++ {
+				for _, query := range warmup.Queries {
+					if ctx.Err() != nil {
+						return
+					}
+					if err := drainQuery(ctx, db, query); err != nil {
+						if atomic.AddInt64(&failed, 1) == 1 {
+							log.Printf("Warning: Warmup query failed on shard %s: %v", shardInfo.ID, err)
+						}
+						continue
+					}
+					atomic.AddInt64(&executed, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		log.Printf("Warning: Warmup of shard %s timed out after %s", shardInfo.ID, warmup.Timeout)
+	}
+	log.Printf("🔥 Warmed up shard %s in %s: %d queries, %d failed",
+		shardInfo.ID, time.Since(start).Round(time.Millisecond), executed, failed)
+}
+
+// drainQuery runs a query and reads all of its rows, so the pages it touches
+// are actually loaded
+func drainQuery(ctx context.Context, db *sql.DB, query string) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}